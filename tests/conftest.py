@@ -0,0 +1,54 @@
+"""
+Shared fixtures for the snippet staging test suites.
+
+  - PassingExecutor: succeeds every run, echoing the source as its output
+    (or a fixed `output`), and records the sources it ran in `codes`
+  - passing_executor: a fresh PassingExecutor
+  - make_pipeline: factory fixture — make_pipeline(executors=None, root='',
+    **kwargs) builds a StagingPipeline on a fresh session ledger and node
+    registry, storing snippets and the audit log under tmp_path / root;
+    executors default to {'go': passing_executor}, kwargs go to the pipeline
+  - pipeline: make_pipeline() with the defaults
+"""
+
+import pytest
+
+from visual_editor_core.execution_engine import ExecutionResult
+from visual_editor_core.node_registry import NodeRegistry
+from visual_editor_core.session_ledger import SessionLedger
+from visual_editor_core.snippet_staging import StagingPipeline
+
+
+class PassingExecutor:
+    """Succeeds with `output` (the source itself when None)."""
+
+    def __init__(self, output=None):
+        self.output = output
+        self.codes = []
+
+    def execute(self, code):
+        self.codes.append(code)
+        return ExecutionResult(success=True, output=code if self.output is None else self.output,
+                               error=None, execution_time=0.01)
+
+
+@pytest.fixture
+def passing_executor():
+    return PassingExecutor()
+
+
+@pytest.fixture
+def make_pipeline(tmp_path, passing_executor):
+    def make(executors=None, root='', **kwargs):
+        base = tmp_path / root if root else tmp_path
+        ledger = SessionLedger()
+        return StagingPipeline(executors if executors is not None else {'go': passing_executor},
+                               NodeRegistry(ledger), ledger,
+                               snippets_dir=str(base / 'snippets'),
+                               audit_log_path=str(base / 'audit.jsonl'), **kwargs)
+    return make
+
+
+@pytest.fixture
+def pipeline(make_pipeline):
+    return make_pipeline()
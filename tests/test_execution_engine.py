@@ -2,11 +2,15 @@
 Tests for the ExecutionEngine with property-based testing.
 """
 
+import os
+import sys
+import time
 import pytest
 import keyword
 from hypothesis import given, strategies as st, assume
 from visual_editor_core.execution_engine import (
-    ExecutionEngine, ExecutionResult, ExecutionState, VisualDebugger
+    ExecutionEngine, ExecutionResult, ExecutionState, VisualDebugger,
+    _run_with_deadline,
 )
 from visual_editor_core.models import (
     VisualModel, VisualNode, NodeType, InputPort, OutputPort
@@ -107,6 +111,46 @@ class TestExecutionEngine:
         assert engine.is_executing is False
 
 
+class TestRunWithDeadline:
+    """Test cases for the hard-deadline subprocess runner."""
+
+    def test_completes_within_deadline(self):
+        proc, timed_out = _run_with_deadline(
+            [sys.executable, '-c', 'print("done")'], timeout=10)
+        assert timed_out is False
+        assert proc.returncode == 0
+        assert proc.stdout.strip() == 'done'
+
+    def test_deadline_kills_runaway_process(self):
+        start = time.time()
+        proc, timed_out = _run_with_deadline(
+            [sys.executable, '-c', 'import time\nwhile True: time.sleep(0.05)'],
+            timeout=0.3, kill_grace=0.5)
+        assert timed_out is True
+        assert time.time() - start < 3
+
+    @pytest.mark.skipif(os.name != 'posix', reason='process groups are POSIX-only')
+    def test_deadline_kills_grandchildren(self):
+        # Mimics `go run`: the launcher spawns the real program as a child
+        script = (
+            'import subprocess, sys, time\n'
+            'child = subprocess.Popen([sys.executable, "-c", "import time; time.sleep(60)"])\n'
+            'print(child.pid, flush=True)\n'
+            'time.sleep(60)\n'
+        )
+        proc, timed_out = _run_with_deadline([sys.executable, '-c', script],
+                                             timeout=0.5, kill_grace=0.5)
+        assert timed_out is True
+        grandchild = int(proc.stdout.split()[0])
+        time.sleep(0.2)
+        try:
+            os.kill(grandchild, 0)
+            alive = not open(f'/proc/{grandchild}/stat').read().split()[2] == 'Z'
+        except (ProcessLookupError, FileNotFoundError):
+            alive = False
+        assert alive is False
+
+
 class TestVisualDebugger:
     """Test cases for VisualDebugger class."""
     
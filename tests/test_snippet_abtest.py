@@ -0,0 +1,200 @@
+"""
+Test suite for A/B tests.
+
+Tests cover:
+  - start_ab_test(): live control, newest PASSED treatment, invalid inputs,
+    one test per slot and no canary alongside
+  - route_slot() splits executions by split_ratio; record_slot_run() feeds
+    per-variant runs, pass rate and mean / p95 time
+  - recommend(): min_samples, pass rate first, then mean time, ties keep control
+  - end_ab_test(): the treatment winning is promoted and the control archived;
+    the control winning leaves it live and rejects the treatment
+  - abort_ab_test(), control rollback, namespaces and the archival sweep
+"""
+
+import pytest
+
+from visual_editor_core.snippet_staging import StagingPhase
+from visual_editor_core.snippet_canary import CanaryConfig, CanaryController
+from visual_editor_core.snippet_archive import ArchivalPolicy
+from visual_editor_core.snippet_abtest import (
+    ABTestController, ABTestError, ABTestStatus, ABVariantStats, recommend,
+    VARIANT_CONTROL, VARIANT_TREATMENT,
+)
+
+
+class FakeRandom:
+    """rng stub: returns `value` on every call."""
+
+    def __init__(self, value: float = 0.0):
+        self.value = value
+
+    def __call__(self) -> float:
+        return self.value
+
+
+@pytest.fixture
+def rng():
+    return FakeRandom()
+
+
+@pytest.fixture
+def pipeline(make_pipeline, rng):
+    return make_pipeline(canary_controller=CanaryController(background=False),
+                         ab_test_controller=ABTestController(rng=rng))
+
+
+def stage(pipeline, code, label, namespace='default'):
+    snippet = pipeline.queue_snippet('i', 'go', code, label, namespace=namespace)
+    return pipeline.speculate(snippet.staging_id)
+
+
+@pytest.fixture
+def control(pipeline):
+    return pipeline.promote(stage(pipeline, 'package main // a', 'pricing').staging_id)
+
+
+@pytest.fixture
+def treatment(pipeline, control):
+    return stage(pipeline, 'package main // b', 'pricing-fast')
+
+
+def serve(pipeline, slot_id, rng, value, n, success=True, t=0.01):
+    rng.value = value
+    for _ in range(n):
+        routed = pipeline.route_slot(slot_id)
+        pipeline.record_slot_run(slot_id, routed, success, t)
+
+
+def stats(runs, passes, times):
+    return ABVariantStats('stg', 'x', runs=runs, passes=passes, times=times)
+
+
+class TestStart:
+    def test_variants(self, pipeline, control, treatment):
+        test = pipeline.start_ab_test('i', 'pricing', 'pricing-fast', split_ratio=0.2,
+                                      min_samples=5)
+        assert test.status == ABTestStatus.RUNNING and test.test_id.startswith('ab-')
+        assert test.control.staging_id == control.staging_id
+        assert test.treatment.staging_id == treatment.staging_id
+        assert test.slot_id == control.registry_slot_id
+        assert treatment.phase == StagingPhase.PASSED
+        events = [e['event'] for e in pipeline.get_audit_trail(treatment.staging_id)]
+        assert 'ab_test_started' in events
+
+    def test_invalid(self, pipeline, control, treatment):
+        with pytest.raises(ABTestError, match='differ'):
+            pipeline.start_ab_test('i', 'pricing', 'pricing')
+        with pytest.raises(ABTestError, match='not live'):
+            pipeline.start_ab_test('i', 'nope', 'pricing-fast')
+        with pytest.raises(ABTestError, match='No PASSED'):
+            pipeline.start_ab_test('i', 'pricing', 'nope')
+        for ratio in (0, 1, 1.5, True):
+            with pytest.raises(ABTestError, match='split_ratio'):
+                pipeline.start_ab_test('i', 'pricing', 'pricing-fast', split_ratio=ratio)
+        with pytest.raises(ABTestError, match='min_samples'):
+            pipeline.start_ab_test('i', 'pricing', 'pricing-fast', min_samples=0)
+
+    def test_one_per_slot_and_no_canary(self, pipeline, control, treatment):
+        pipeline.start_ab_test('i', 'pricing', 'pricing-fast')
+        other = stage(pipeline, 'package main // c', 'pricing-other')
+        with pytest.raises(ABTestError, match='already has an A/B test'):
+            pipeline.start_ab_test('i', 'pricing', 'pricing-other')
+        v2 = stage(pipeline, 'package main // a2', 'pricing')
+        with pytest.raises(ValueError, match='A/B test running'):
+            pipeline.promote(v2.staging_id, canary=CanaryConfig())
+        assert other.phase == StagingPhase.PASSED
+
+
+def test_routing_and_stats(pipeline, control, treatment, rng):
+    test = pipeline.start_ab_test('i', 'pricing', 'pricing-fast', split_ratio=0.3)
+    rng.value = 0.29
+    assert pipeline.route_slot(test.slot_id).staging_id == treatment.staging_id
+    rng.value = 0.3
+    assert pipeline.route_slot(test.slot_id) is None
+
+    serve(pipeline, test.slot_id, rng, 0.9, 4, t=0.02)
+    serve(pipeline, test.slot_id, rng, 0.1, 1, success=False, t=0.5)
+    serve(pipeline, test.slot_id, rng, 0.1, 1, t=0.1)
+    report = pipeline.ab_test_result(test.test_id)
+    assert report.control.runs == 4 and report.control.pass_rate == 1.0
+    assert report.control.mean_time == pytest.approx(0.02)
+    assert report.treatment.runs == 2 and report.treatment.pass_rate == 0.5
+    assert report.treatment.p95_time == pytest.approx(0.48)
+    assert report.recommended_winner == ''
+    assert report.to_dict()['treatment']['mean_spec_time'] == pytest.approx(0.3)
+
+
+class TestRecommend:
+    def test_min_samples(self):
+        assert recommend(stats(3, 3, [1] * 3), stats(2, 2, [1] * 2), 3) == ''
+
+    def test_pass_rate_then_time(self):
+        assert recommend(stats(3, 2, [1] * 3), stats(3, 3, [9] * 3), 3) == VARIANT_TREATMENT
+        assert recommend(stats(3, 3, [2] * 3), stats(3, 3, [1] * 3), 3) == VARIANT_TREATMENT
+        assert recommend(stats(3, 3, [1] * 3), stats(3, 3, [2] * 3), 3) == VARIANT_CONTROL
+        assert recommend(stats(3, 3, [1] * 3), stats(3, 3, [1] * 3), 3) == VARIANT_CONTROL
+
+
+class TestEnd:
+    def test_treatment_wins(self, pipeline, control, treatment, rng):
+        test = pipeline.start_ab_test('i', 'pricing', 'pricing-fast', min_samples=3)
+        serve(pipeline, test.slot_id, rng, 0.9, 3, t=0.05)
+        serve(pipeline, test.slot_id, rng, 0.1, 3, t=0.01)
+        with pytest.raises(ABTestError, match='winner must be'):
+            pipeline.end_ab_test(test.test_id, 'both')
+
+        ended = pipeline.end_ab_test(test.test_id)
+        assert ended.status == ABTestStatus.ENDED and ended.winner == VARIANT_TREATMENT
+        promoted = pipeline.get_snippet(treatment.staging_id)
+        assert promoted.phase == StagingPhase.PROMOTED and promoted.registry_slot_id
+        archived = pipeline.get_snippet(control.staging_id)
+        assert archived.phase == StagingPhase.ARCHIVED and archived.archived_at > 0
+        assert pipeline.route_slot(test.slot_id) is None
+        entry = [e for e in pipeline.get_audit_trail(treatment.staging_id)
+                 if e['event'] == 'ab_test_ended'][0]
+        assert entry['data']['winner'] == 'treatment'
+        with pytest.raises(ABTestError, match='already ended'):
+            pipeline.end_ab_test(test.test_id)
+
+    def test_control_wins(self, pipeline, control, treatment, rng):
+        test = pipeline.start_ab_test('i', 'pricing', 'pricing-fast', min_samples=2)
+        serve(pipeline, test.slot_id, rng, 0.9, 2)
+        serve(pipeline, test.slot_id, rng, 0.1, 2, success=False)
+        pipeline.end_ab_test(test.test_id)
+        assert control.phase == StagingPhase.PROMOTED
+        rejected = pipeline.get_snippet(treatment.staging_id)
+        assert rejected.phase == StagingPhase.REJECTED
+        assert test.test_id in rejected.rejection_reason
+
+    def test_needs_samples_or_winner(self, pipeline, control, treatment):
+        test = pipeline.start_ab_test('i', 'pricing', 'pricing-fast', min_samples=50)
+        with pytest.raises(ABTestError, match='needs 50 runs'):
+            pipeline.end_ab_test(test.test_id)
+        assert pipeline.end_ab_test(test.test_id, VARIANT_CONTROL).winner == VARIANT_CONTROL
+
+
+def test_abort_and_rollback(pipeline, control, treatment):
+    test = pipeline.start_ab_test('i', 'pricing', 'pricing-fast')
+    aborted = pipeline.abort_ab_test(test.test_id, 'changed my mind')
+    assert aborted.status == ABTestStatus.ABORTED and aborted.reason == 'changed my mind'
+    assert treatment.phase == StagingPhase.PASSED
+    with pytest.raises(ABTestError, match='not running'):
+        pipeline.abort_ab_test(test.test_id)
+
+    again = pipeline.start_ab_test('i', 'pricing', 'pricing-fast')
+    pipeline.rollback(control.staging_id)
+    assert again.status == ABTestStatus.ABORTED and 'rolled back' in again.reason
+
+
+def test_namespaces_and_archival(pipeline, control, treatment, tmp_path):
+    test = pipeline.start_ab_test('i', 'pricing', 'pricing-fast')
+    assert pipeline.get_ab_test(test.test_id, namespace='team-b') is None
+    with pytest.raises(ABTestError):
+        pipeline.ab_test_result(test.test_id, namespace='team-b')
+    assert pipeline.list_ab_tests('team-b') == []
+    assert [t.test_id for t in pipeline.list_ab_tests('default')] == [test.test_id]
+
+    policy = ArchivalPolicy(max_age=1, cold_storage_path=str(tmp_path / 'cold'))
+    archived = pipeline.apply_archival_policy(policy, now=control.promoted_at + 100)
+    assert control not in archived and control.phase == StagingPhase.PROMOTED
@@ -0,0 +1,172 @@
+"""
+Test suite for the v1 Snippet REST API.
+
+Tests cover:
+  - Request types: unknown / missing / wrongly-typed fields, cross-field rules
+  - Structured error bodies (error_code, details)
+  - ETag computation and If-None-Match matching (*, W/, lists)
+  - The request dataclasses agree with snippet_api.yaml
+  - Blueprint round trip (stage → get/304 → promote → rollback, delete)
+    when Flask is installed
+"""
+
+import pytest
+
+from web_interface.snippet_api_types import (
+    ApiError, StageRequest, PromoteRequest, REQUEST_TYPES, OPENAPI_PATH,
+    request_fields, compute_etag, etag_matches,
+)
+
+
+def error_of(request_type, data) -> ApiError:
+    with pytest.raises(ApiError) as exc:
+        request_type.from_json(data)
+    return exc.value
+
+
+# ─────────────────────────────────────────────────────────────────────
+# Request types
+# ─────────────────────────────────────────────────────────────────────
+
+class TestRequestTypes:
+    def test_stage_request_defaults(self):
+        req = StageRequest.from_json({'code': 'x = 1', 'language': 'python'})
+        assert req.speculate is True
+        assert req.auto_promote is False
+        assert req.parameters == [] and req.arguments == {}
+
+    def test_unknown_field_rejected(self):
+        err = error_of(StageRequest, {'code': 'x', 'language': 'python', 'colour': 'red'})
+        assert err.status == 400 and err.error_code == 'invalid_request'
+        assert err.details == {'fields': ['colour']}
+
+    def test_missing_required_field(self):
+        err = error_of(StageRequest, {'language': 'python'})
+        assert err.details == {'field': 'code'}
+
+    def test_wrong_type(self):
+        err = error_of(StageRequest, {'code': 'x', 'language': 'python', 'speculate': 'yes'})
+        assert "'speculate' must be a boolean" in err.message
+
+    def test_language_or_engine_letter_required(self):
+        assert 'engine_letter' in error_of(StageRequest, {'code': 'x'}).message
+        assert StageRequest.from_json({'code': 'x', 'engine_letter': 'a'}).engine_letter == 'a'
+
+    def test_bad_label_policy(self):
+        err = error_of(StageRequest, {'code': 'x', 'language': 'go', 'label_policy': 'merge'})
+        assert err.details == {'field': 'label_policy'}
+
+    def test_auto_promote_requires_speculate(self):
+        error_of(StageRequest, {'code': 'x', 'language': 'go',
+                                'speculate': False, 'auto_promote': True})
+
+    def test_body_must_be_object(self):
+        assert error_of(PromoteRequest, ['skip_lint']).error_code == 'invalid_request'
+        assert PromoteRequest.from_json(None).skip_lint is False
+
+    def test_error_body_shape(self):
+        assert ApiError(404, 'not_found', 'gone').to_dict() == {
+            'success': False, 'error_code': 'not_found', 'error': 'gone'}
+        assert ApiError(409, 'invalid_state', 'no', {'phase': 'promoted'}).to_dict()['details'] \
+            == {'phase': 'promoted'}
+
+
+# ─────────────────────────────────────────────────────────────────────
+# ETags
+# ─────────────────────────────────────────────────────────────────────
+
+class TestETags:
+    def test_etag_is_stable_and_key_order_independent(self):
+        a = compute_etag({'x': 1, 'y': [1, 2]})
+        assert a == compute_etag({'y': [1, 2], 'x': 1})
+        assert a.startswith('"') and a.endswith('"')
+        assert a != compute_etag({'x': 2, 'y': [1, 2]})
+
+    def test_if_none_match(self):
+        etag = compute_etag({'x': 1})
+        assert etag_matches(etag, etag)
+        assert etag_matches('W/' + etag, etag)
+        assert etag_matches(f'"other", {etag}', etag)
+        assert etag_matches('*', etag)
+        assert not etag_matches('"other"', etag)
+        assert not etag_matches(None, etag)
+
+
+# ─────────────────────────────────────────────────────────────────────
+# OpenAPI document
+# ─────────────────────────────────────────────────────────────────────
+
+class TestOpenApiDocument:
+    def test_request_types_match_yaml_schemas(self):
+        yaml = pytest.importorskip('yaml')
+        with open(OPENAPI_PATH, encoding='utf-8') as f:
+            spec = yaml.safe_load(f)
+        schemas = spec['components']['schemas']
+        for name, request_type in REQUEST_TYPES.items():
+            schema = schemas[name]
+            assert set(schema['properties']) == set(request_fields(request_type)), name
+            required = {n for n, (_, req) in request_type._SCHEMA.items() if req}
+            assert set(schema.get('required', [])) == required, name
+            assert schema['additionalProperties'] is False
+
+
+# ─────────────────────────────────────────────────────────────────────
+# Blueprint (needs Flask)
+# ─────────────────────────────────────────────────────────────────────
+
+@pytest.fixture
+def client(make_pipeline, monkeypatch):
+    flask = pytest.importorskip('flask')
+    from web_interface import runtime
+    from web_interface.snippet_api import snippet_api_bp
+
+    pipeline = make_pipeline({})
+    monkeypatch.setattr(runtime, 'staging_pipeline', pipeline)
+    app = flask.Flask(__name__)
+    app.register_blueprint(snippet_api_bp)
+    return app.test_client()
+
+
+class TestBlueprint:
+    def test_stage_get_promote_rollback(self, client):
+        resp = client.post('/api/v1/snippets/stage',
+                           json={'code': 'print(1)', 'language': 'python', 'label': 'one'})
+        assert resp.status_code == 201
+        staging_id = resp.get_json()['snippet']['staging_id']
+        assert resp.headers['Location'].endswith(f'/api/v1/snippets/{staging_id}')
+
+        resp = client.get(f'/api/v1/snippets/{staging_id}')
+        etag = resp.headers['ETag']
+        assert client.get(f'/api/v1/snippets/{staging_id}',
+                          headers={'If-None-Match': etag}).status_code == 304
+
+        resp = client.post(f'/api/v1/snippets/{staging_id}/promote', json={'skip_lint': True})
+        assert resp.status_code == 200
+        assert client.get(f'/api/v1/snippets/{staging_id}',
+                          headers={'If-None-Match': etag}).status_code == 200
+
+        assert client.delete(f'/api/v1/snippets/{staging_id}').status_code == 409
+        resp = client.post(f'/api/v1/snippets/{staging_id}/rollback', json={'reason': 'test'})
+        assert resp.status_code == 200 and resp.get_json()['restored'] is None
+
+    def test_errors_are_structured(self, client):
+        resp = client.get('/api/v1/snippets/nope')
+        assert resp.status_code == 404 and resp.get_json()['error_code'] == 'not_found'
+        resp = client.post('/api/v1/snippets/stage', data='{', content_type='application/json')
+        assert resp.get_json()['error_code'] == 'invalid_json'
+        resp = client.post('/api/v1/snippets/stage', json={'code': 'x', 'bogus': 1})
+        assert resp.status_code == 400 and resp.get_json()['details'] == {'fields': ['bogus']}
+
+    def test_delete_withdraws_unpromoted_snippet(self, client):
+        resp = client.post('/api/v1/snippets/stage',
+                           json={'code': 'print(2)', 'language': 'python', 'speculate': False})
+        staging_id = resp.get_json()['snippet']['staging_id']
+        assert client.delete(f'/api/v1/snippets/{staging_id}').status_code == 204
+        assert client.get(f'/api/v1/snippets/{staging_id}').get_json()['snippet']['phase'] \
+            == 'rejected'
+
+    def test_openapi_yaml_served(self, client):
+        resp = client.get('/api/v1/openapi.yaml')
+        assert resp.status_code == 200
+        assert resp.headers['Content-Type'].startswith('application/yaml')
+        assert b'openapi: 3.0.3' in resp.data
@@ -0,0 +1,183 @@
+"""
+Test suite for promotion approvals.
+
+Tests cover:
+  - SlotConfig.require_approvals validation; unprotected slots promote directly
+  - promote() on a protected slot: PendingApprovalError, one pending record,
+    snippet stays PASSED, repeated calls reuse the record
+  - approve_promotion(): each approver once, the last one promotes (with the
+    original skip_lint), timestamps and audit entries
+  - reject_promotion(): record REJECTED, snippet REJECTED, no more approvals
+  - Namespaces, list_approvals(), batch_promote() and the dry-run approval gate
+"""
+
+import pytest
+
+from visual_editor_core.snippet_staging import BatchPromotionError, StagingPhase
+from visual_editor_core.snippet_capacity import SlotConfig
+from visual_editor_core.snippet_lint import LintDiagnostic, LintResult, SnippetLinter
+from visual_editor_core.snippet_dryrun import GATE_APPROVAL, GateStatus
+from visual_editor_core.snippet_approvals import (
+    ApprovalError, ApprovalStatus, PendingApprovalError,
+)
+
+
+class MarkerLinter(SnippetLinter):
+    def lint(self, code):
+        diags = [LintDiagnostic('printf', 1, 1, 'flagged')] if 'lint:' in code else []
+        return LintResult(passed=not diags, diagnostics=diags)
+
+
+@pytest.fixture
+def pipeline(make_pipeline):
+    pipeline = make_pipeline(linters={'go': MarkerLinter()})
+    pipeline.configure_slot('i', SlotConfig(require_approvals=2))
+    return pipeline
+
+
+def passed(pipeline, code='package main', label='svc', namespace='default'):
+    snippet = pipeline.queue_snippet('i', 'go', code, label, namespace=namespace)
+    return pipeline.speculate(snippet.staging_id)
+
+
+def events(pipeline, staging_id):
+    return [e['event'] for e in pipeline.get_audit_trail(staging_id)]
+
+
+def test_require_approvals_validated():
+    with pytest.raises(ValueError, match='require_approvals'):
+        SlotConfig(require_approvals=-1)
+    assert SlotConfig(require_approvals=3).to_dict()['require_approvals'] == 3
+
+
+def test_unprotected_slot_promotes(pipeline):
+    pipeline.configure_slot('i', SlotConfig())
+    snippet = passed(pipeline)
+    assert pipeline.promote(snippet.staging_id).phase == StagingPhase.PROMOTED
+    assert pipeline.get_approval(snippet.staging_id) is None
+
+
+class TestPending:
+    def test_promote_opens_record(self, pipeline):
+        snippet = passed(pipeline)
+        with pytest.raises(PendingApprovalError) as exc:
+            pipeline.promote(snippet.staging_id)
+        record = exc.value.record
+        assert record.status == ApprovalStatus.PENDING and record.required == 2
+        assert record.slot == 'i' and record.approvals == []
+        assert snippet.phase == StagingPhase.PASSED and not snippet.registry_slot_id
+        assert 'approval_requested' in events(pipeline, snippet.staging_id)
+
+        with pytest.raises(PendingApprovalError) as again:
+            pipeline.promote(snippet.staging_id)
+        assert again.value.record is record
+        assert events(pipeline, snippet.staging_id).count('approval_requested') == 1
+
+    def test_gates_run_first(self, pipeline):
+        snippet = passed(pipeline, 'package main // lint:')
+        with pytest.raises(ValueError) as exc:
+            pipeline.promote(snippet.staging_id)
+        assert not isinstance(exc.value, PendingApprovalError)
+        assert pipeline.get_approval(snippet.staging_id) is None
+
+
+class TestApprove:
+    def test_quorum_promotes(self, pipeline):
+        snippet = passed(pipeline)
+        with pytest.raises(PendingApprovalError):
+            pipeline.promote(snippet.staging_id)
+
+        record = pipeline.approve_promotion(snippet.staging_id, 'alice')
+        assert record.status == ApprovalStatus.PENDING
+        assert snippet.phase == StagingPhase.PASSED
+        with pytest.raises(ApprovalError, match='already approved'):
+            pipeline.approve_promotion(snippet.staging_id, 'alice')
+
+        record = pipeline.approve_promotion(snippet.staging_id, 'bob')
+        assert record.status == ApprovalStatus.APPROVED
+        assert record.approver_ids == ['alice', 'bob']
+        assert all(a.approved_at > 0 for a in record.approvals)
+        assert record.resolved_at == record.approvals[-1].approved_at
+        promoted = pipeline.get_snippet(snippet.staging_id)
+        assert promoted.phase == StagingPhase.PROMOTED and promoted.registry_slot_id
+
+        granted = [e['data'] for e in pipeline.get_audit_trail(snippet.staging_id)
+                   if e['event'] == 'approval_granted']
+        assert sorted((g['approvals'], g['approver_id']) for g in granted) == [(1, 'alice'),
+                                                                             (2, 'bob')]
+        with pytest.raises(ApprovalError, match='approved'):
+            pipeline.approve_promotion(snippet.staging_id, 'carol')
+
+    def test_keeps_promote_options(self, pipeline):
+        snippet = passed(pipeline, 'package main // lint:')
+        with pytest.raises(PendingApprovalError):
+            pipeline.promote(snippet.staging_id, skip_lint=True)
+        pipeline.approve_promotion(snippet.staging_id, 'alice')
+        pipeline.approve_promotion(snippet.staging_id, 'bob')
+        assert pipeline.get_snippet(snippet.staging_id).phase == StagingPhase.PROMOTED
+
+    def test_unknown_and_invalid(self, pipeline):
+        snippet = passed(pipeline)
+        with pytest.raises(ApprovalError, match='No approval'):
+            pipeline.approve_promotion(snippet.staging_id, 'alice')
+        with pytest.raises(PendingApprovalError):
+            pipeline.promote(snippet.staging_id)
+        with pytest.raises(ApprovalError, match='approver_id'):
+            pipeline.approve_promotion(snippet.staging_id, '  ')
+
+
+def test_reject(pipeline):
+    snippet = passed(pipeline)
+    with pytest.raises(PendingApprovalError):
+        pipeline.promote(snippet.staging_id)
+    pipeline.approve_promotion(snippet.staging_id, 'alice')
+
+    record = pipeline.reject_promotion(snippet.staging_id, 'bob', 'not during the freeze')
+    assert record.status == ApprovalStatus.REJECTED
+    assert record.rejected_by == 'bob' and record.reason == 'not during the freeze'
+    rejected = pipeline.get_snippet(snippet.staging_id)
+    assert rejected.phase == StagingPhase.REJECTED
+    assert rejected.rejection_reason == 'not during the freeze'
+    assert 'approval_rejected' in events(pipeline, snippet.staging_id)
+    with pytest.raises(ApprovalError):
+        pipeline.approve_promotion(snippet.staging_id, 'carol')
+
+
+def test_scoped_and_listed(pipeline):
+    a = passed(pipeline, namespace='team-a')
+    b = passed(pipeline, 'package main // b', 'other', namespace='team-b')
+    for snippet in (a, b):
+        with pytest.raises(PendingApprovalError):
+            pipeline.promote(snippet.staging_id)
+    with pytest.raises(ApprovalError):
+        pipeline.approve_promotion(a.staging_id, 'alice', namespace='team-b')
+    assert pipeline.get_approval(a.staging_id, namespace='team-b') is None
+
+    pipeline.reject_promotion(b.staging_id, 'alice')
+    assert [r.staging_id for r in pipeline.list_approvals()] == [a.staging_id, b.staging_id]
+    assert [r.staging_id for r in pipeline.list_approvals('pending')] == [a.staging_id]
+    assert [r.staging_id for r in pipeline.list_approvals(namespace='team-b')] == [b.staging_id]
+    with pytest.raises(ValueError):
+        pipeline.list_approvals('maybe')
+
+
+def test_batch_refuses_protected(pipeline):
+    snippet = passed(pipeline)
+    with pytest.raises(BatchPromotionError, match='not promotable'):
+        pipeline.batch_promote([snippet.staging_id])
+    assert pipeline.get_approval(snippet.staging_id) is None
+
+
+def test_dry_run_gate(pipeline):
+    snippet = passed(pipeline)
+    gate = pipeline.dry_run_promote(snippet.staging_id).gate(GATE_APPROVAL)
+    assert gate.status == GateStatus.FAILED and gate.detail == '0/2 approvals'
+
+    pipeline.configure_slot('i', SlotConfig(require_approvals=1))
+    with pytest.raises(PendingApprovalError):
+        pipeline.promote(snippet.staging_id)
+    assert pipeline.get_approval(snippet.staging_id).required == 1
+
+    pipeline.configure_slot('i', None)
+    assert pipeline.dry_run_promote(snippet.staging_id).gate(GATE_APPROVAL).status == \
+        GateStatus.SKIPPED
@@ -0,0 +1,157 @@
+"""
+Test suite for archiving old promotions.
+
+Tests cover:
+  - select_for_archive(): max_age, max_versions_per_label, slot size (retired first)
+  - ArchivalPolicy validation (MOVE needs a cold_storage_path, no negative limits)
+  - apply_archival_policy() with MOVE: sources copied to cold storage, records ARCHIVED,
+    on_archive called, left out of queries unless include_archived (memory + SQLite)
+  - Archived records stop counting against slot capacity; live ones leave the registry
+  - DELETE removes the record, and its payload once nothing else shares it
+  - Archivist.sweep() and status()
+"""
+
+import os
+import pytest
+
+from visual_editor_core.snippet_staging import StagingPhase
+from visual_editor_core.snippet_sqlite import SQLiteSnippetIndex
+from visual_editor_core.snippet_query import SnippetFilter
+from visual_editor_core.snippet_archive import (
+    ArchivalPolicy, ArchiveAction, Archivist, REASON_MAX_AGE, REASON_MAX_SIZE,
+    REASON_MAX_VERSIONS, select_for_archive,
+)
+
+
+def promote_versions(pipeline, label, count):
+    """Promote `count` versions of `label`; promoted_at = 100, 200, …"""
+    versions = []
+    for n in range(count):
+        snippet = pipeline.queue_snippet('i', 'go', f'package main // {label} v{n}', label)
+        pipeline.speculate(snippet.staging_id)
+        promoted = pipeline.promote(snippet.staging_id)
+        promoted.promoted_at = 100.0 * (n + 1)
+        versions.append(promoted)
+    return versions
+
+
+class TestSelectForArchive:
+    def test_max_age(self, make_pipeline):
+        pipeline = make_pipeline()
+        old, new = promote_versions(pipeline, 'svc', 2)
+        chosen = select_for_archive(ArchivalPolicy(max_age=150, action='delete'),
+                                    [old, new], now=300)
+        assert chosen == [(old, REASON_MAX_AGE)]
+
+    def test_max_versions(self, make_pipeline):
+        pipeline = make_pipeline()
+        a1, a2, a3 = promote_versions(pipeline, 'a', 3)
+        (b1,) = promote_versions(pipeline, 'b', 1)
+        chosen = select_for_archive(ArchivalPolicy(max_versions_per_label=1, action='delete'),
+                                    [a3, b1, a1, a2], now=0)
+        assert chosen == [(a1, REASON_MAX_VERSIONS), (a2, REASON_MAX_VERSIONS)]
+
+    def test_max_size_retired_first(self, make_pipeline):
+        pipeline = make_pipeline()
+        (live,) = promote_versions(pipeline, 'a', 1)
+        older, retired_newer = promote_versions(pipeline, 'b', 2)
+        live.promoted_at = 1.0                                  # oldest, but live
+        size = len(live.code.encode('utf-8'))
+        chosen = select_for_archive(ArchivalPolicy(max_size_per_slot_bytes=2 * size,
+                                                   action='delete'),
+                                    [live, older, retired_newer], now=0)
+        assert older.phase == StagingPhase.SUPERSEDED
+        assert chosen == [(older, REASON_MAX_SIZE)]
+
+    def test_ignores_pipeline_phases(self, make_pipeline):
+        pipeline = make_pipeline()
+        queued = pipeline.queue_snippet('i', 'go', 'package main', 'q')
+        assert select_for_archive(ArchivalPolicy(max_age=1, action='delete'),
+                                  [queued], now=1e12) == []
+
+
+class TestPolicy:
+    def test_validation(self):
+        with pytest.raises(ValueError, match='cold_storage_path'):
+            ArchivalPolicy(max_age=60)
+        with pytest.raises(ValueError, match='>= 0'):
+            ArchivalPolicy(max_versions_per_label=-1, action='delete')
+        with pytest.raises(ValueError):
+            ArchivalPolicy(action='shred')
+        assert not ArchivalPolicy(action=ArchiveAction.DELETE).enabled
+
+
+class TestApplyPolicy:
+    @pytest.mark.parametrize('sqlite', [False, True])
+    def test_move(self, tmp_path, make_pipeline, sqlite):
+        pipeline = make_pipeline(snippet_index=SQLiteSnippetIndex(str(tmp_path / 'idx.db'))
+                                 if sqlite else None)
+        v1, v2, v3 = promote_versions(pipeline, 'svc', 3)
+        old_path = v1.saved_file_path
+        calls = []
+        policy = ArchivalPolicy(max_versions_per_label=1, cold_storage_path=str(tmp_path / 'cold'),
+                                on_archive=lambda s, reason: calls.append((s.staging_id, reason)))
+        archived = pipeline.apply_archival_policy(policy, now=500)
+
+        assert archived == [v1, v2]
+        assert calls == [(v1.staging_id, REASON_MAX_VERSIONS), (v2.staging_id, REASON_MAX_VERSIONS)]
+        assert v1.phase == StagingPhase.ARCHIVED and v1.archived_at == 500
+        assert v1.saved_file_path.startswith(str(tmp_path / 'cold' / 'default' / 'i'))
+        with open(v1.saved_file_path, encoding='utf-8') as f:
+            cold = f.read()
+        assert f'staging_id:  {v1.staging_id}' in cold and cold.endswith(v1.program)
+        assert os.path.exists(old_path)                   # The store's blob stays
+        assert v3.phase == StagingPhase.PROMOTED
+
+        default = {s.staging_id for s in pipeline.query(SnippetFilter()).snippets}
+        everything = {s.staging_id for s in
+                      pipeline.query(SnippetFilter(include_archived=True)).snippets}
+        assert default == {v3.staging_id}
+        assert everything == {v1.staging_id, v2.staging_id, v3.staging_id}
+
+        # A second pass finds nothing new
+        assert pipeline.apply_archival_policy(policy, now=600) == []
+
+    def test_live_record_leaves_slot(self, tmp_path, make_pipeline):
+        pipeline = make_pipeline()
+        (live,) = promote_versions(pipeline, 'svc', 1)
+        slot_id = live.registry_slot_id
+        assert pipeline.get_slot_usage('i')['live'] == 1
+        archived = pipeline.apply_archival_policy(
+            ArchivalPolicy(max_age=60, cold_storage_path=str(tmp_path / 'cold')), now=1000)
+        assert archived == [live]
+        assert pipeline.get_slot_usage('i')['snippets'] == 0
+        assert pipeline._registry.get_slot(slot_id) is None              # cleared
+        events = [e['event'] for e in pipeline.get_audit_trail(live.staging_id)]
+        assert 'archived' in events
+
+    def test_delete(self, tmp_path, make_pipeline):
+        pipeline = make_pipeline(snippet_index=SQLiteSnippetIndex(str(tmp_path / 'idx.db')))
+        v1, v2 = promote_versions(pipeline, 'svc', 2)
+        path = v1.saved_file_path
+        archived = pipeline.apply_archival_policy(
+            ArchivalPolicy(max_versions_per_label=1, action='delete'), now=500)
+        assert archived == [v1]
+        assert not os.path.exists(path)
+        assert pipeline.get_snippet(v1.staging_id) is None
+        assert [s.staging_id for s in
+                pipeline.query(SnippetFilter(include_archived=True)).snippets] == [v2.staging_id]
+
+
+class TestArchivist:
+    def test_sweep_and_status(self, make_pipeline):
+        pipeline = make_pipeline()
+        promote_versions(pipeline, 'svc', 2)
+        now = [150.0]
+        archivist = Archivist(pipeline, ArchivalPolicy(max_age=100, action='delete'),
+                              interval=3600, clock=lambda: now[0])
+        assert archivist.sweep() == []
+        now[0] = 1000.0
+        assert len(archivist.sweep()) == 2
+        status = archivist.status()
+        assert status['archived_total'] == 2 and status['last_sweep_at'] == 1000.0
+        assert status['policy']['action'] == 'delete' and not status['running']
+
+    def test_interval_validated(self, make_pipeline):
+        with pytest.raises(ValueError):
+            Archivist(make_pipeline(), ArchivalPolicy(action='delete'), interval=0)
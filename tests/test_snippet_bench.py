@@ -0,0 +1,179 @@
+"""
+Test suite for Go snippet benchmarks.
+
+Tests cover:
+  - BenchmarkConfig validation
+  - has_go_benchmarks() and split_go_tests() moving Benchmark functions
+  - parse_bench_output(): GOMAXPROCS suffix, -benchmem columns, extra metrics
+  - find_regressions(): percentage over the previous ns/op, new benchmarks pass
+  - speculate() records benchmarks; the regression gate fails promotion with
+    BENCH_REGRESSION against the live version, passes within it and when off;
+    dry_run_promote() reports the benchmarks gate
+  - GoExecutor end to end: a real `go test -bench` run, a panicking benchmark
+"""
+
+import shutil
+import textwrap
+import pytest
+
+from visual_editor_core.execution_engine import ExecutionResult, GoExecutor
+from visual_editor_core.snippet_staging import StagingPhase, SpecResult
+from visual_editor_core.snippet_coverage import split_go_tests
+from visual_editor_core.snippet_dryrun import GATE_BENCHMARKS, GateStatus
+from visual_editor_core.snippet_bench import (
+    BenchmarkConfig, BenchmarkRegressionError, BenchmarkResult, find_regressions,
+    has_go_benchmarks, parse_bench_output,
+)
+
+
+SNIPPET = textwrap.dedent('''\
+    package main
+
+    import (
+    \t"fmt"
+    \t"testing"
+    )
+
+    func fib(n int) int {
+    \tif n < 2 {
+    \t\treturn n
+    \t}
+    \treturn fib(n-1) + fib(n-2)
+    }
+
+    func main() {
+    \tfmt.Println(fib(10))
+    }
+
+    func BenchmarkFib(b *testing.B) {
+    \tfor i := 0; i < b.N; i++ {
+    \t\tfib(10)
+    \t}
+    }
+''')
+
+OUTPUT = textwrap.dedent('''\
+    goos: linux
+    goarch: amd64
+    BenchmarkFib-8          	 1000000	      1234 ns/op	      16 B/op	       1 allocs/op
+    BenchmarkCopy-8         	   50000	     20000 ns/op	 512.00 MB/s	       0 B/op	       0 allocs/op
+    BenchmarkPlain          	     100	    5.5 ns/op
+    PASS
+    ok  	command-line-arguments	3.512s
+''')
+
+
+class BenchExecutor:
+    """Reports BenchmarkFib at `ns_per_op`."""
+
+    def __init__(self, ns_per_op):
+        self.ns_per_op = ns_per_op
+
+    def execute(self, code):
+        return ExecutionResult(success=True, output='55\n', execution_time=0.01,
+                               benchmarks=[BenchmarkResult('BenchmarkFib', 1000, self.ns_per_op,
+                                                           0, 0)])
+
+
+def stage(pipeline, ns_per_op, version):
+    pipeline._executors['go'].ns_per_op = ns_per_op
+    snippet = pipeline.queue_snippet('i', 'go', SNIPPET + f'// v{version}\n', 'fib')
+    pipeline.speculate(snippet.staging_id)
+    return snippet
+
+
+def test_config():
+    assert BenchmarkConfig().benchtime == '3s'
+    BenchmarkConfig(benchtime='100x')
+    for bad in ('', '3', 'fast'):
+        with pytest.raises(ValueError, match='Invalid benchtime'):
+            BenchmarkConfig(benchtime=bad)
+    with pytest.raises(ValueError):
+        BenchmarkConfig(timeout=0)
+
+
+def test_split():
+    assert has_go_benchmarks(SNIPPET)
+    assert not has_go_benchmarks('package main\nfunc Benchmarking(x int) {}\n')
+    main_src, test_src = split_go_tests(SNIPPET)
+    assert 'BenchmarkFib' not in main_src and '"testing"' not in main_src
+    assert 'func BenchmarkFib(b *testing.B)' in test_src and '"testing"' in test_src
+
+
+def test_parse():
+    assert parse_bench_output(OUTPUT) == [
+        BenchmarkResult('BenchmarkFib', 1000000, 1234.0, 16, 1),
+        BenchmarkResult('BenchmarkCopy', 50000, 20000.0, 0, 0),
+        BenchmarkResult('BenchmarkPlain', 100, 5.5),
+    ]
+
+
+def test_find_regressions():
+    before = [BenchmarkResult('BenchmarkA', ns_per_op=100), BenchmarkResult('BenchmarkB', ns_per_op=100)]
+    after = [BenchmarkResult('BenchmarkA', ns_per_op=111), BenchmarkResult('BenchmarkB', ns_per_op=109),
+             BenchmarkResult('BenchmarkNew', ns_per_op=1e9)]
+    regressions = find_regressions(before, after, 10)
+    assert [r.name for r in regressions] == ['BenchmarkA']
+    assert regressions[0].to_dict()['percent'] == 11.0
+    assert regressions[0].describe() == 'BenchmarkA: 100 → 111 ns/op (+11.0%)'
+
+
+class TestPipeline:
+    def test_records_benchmarks(self, make_pipeline):
+        pipeline = make_pipeline({'go': BenchExecutor(1000.0)})
+        snippet = stage(pipeline, 1000.0, 1)
+        assert snippet.benchmarks == [{'name': 'BenchmarkFib', 'iterations': 1000,
+                                       'ns_per_op': 1000.0, 'bytes_per_op': 0,
+                                       'allocs_per_op': 0}]
+        entry = [e for e in pipeline.get_audit_trail(snippet.staging_id)
+                 if e['event'] == 'spec_exec_completed'][0]
+        assert entry['data']['benchmarks'] == snippet.benchmarks
+
+    def test_regression_gate(self, make_pipeline):
+        pipeline = make_pipeline({'go': BenchExecutor(0)}, benchmark_regression_gate=10)
+        v1 = stage(pipeline, 1000.0, 1)
+        pipeline.promote(v1.staging_id)                       # Nothing live to compare with
+        within = stage(pipeline, 1090.0, 2)
+        pipeline.promote(within.staging_id)
+        slower = stage(pipeline, 1500.0, 3)
+        with pytest.raises(BenchmarkRegressionError) as exc:
+            pipeline.promote(slower.staging_id)
+        assert exc.value.regressions[0].previous_ns_per_op == 1090.0
+        assert slower.phase == StagingPhase.FAILED
+        assert slower.spec_result == SpecResult.BENCH_REGRESSION
+        assert slower.spec_error == 'BenchmarkFib: 1090 → 1500 ns/op (+37.6%)'
+        assert within.phase == StagingPhase.PROMOTED
+        events = [e['event'] for e in pipeline.get_audit_trail(slower.staging_id)]
+        assert 'benchmark_regressed' in events
+
+    def test_gate_off(self, make_pipeline):
+        pipeline = make_pipeline({'go': BenchExecutor(0)})
+        pipeline.promote(stage(pipeline, 1000.0, 1).staging_id)
+        assert pipeline.promote(stage(pipeline, 9000.0, 2).staging_id).phase == \
+            StagingPhase.PROMOTED
+
+    def test_dry_run(self, make_pipeline):
+        pipeline = make_pipeline({'go': BenchExecutor(0)}, benchmark_regression_gate=10)
+        pipeline.promote(stage(pipeline, 1000.0, 1).staging_id)
+        snippet = stage(pipeline, 2000.0, 2)
+        gate = pipeline.dry_run_promote(snippet.staging_id).gate(GATE_BENCHMARKS)
+        assert gate.status == GateStatus.FAILED and gate.data['regressions'][0]['percent'] == 100.0
+        assert snippet.phase == StagingPhase.PASSED
+
+
+@pytest.mark.skipif(shutil.which('go') is None, reason='go toolchain not installed')
+class TestGoExecutor:
+    def test_benchmarks(self):
+        result = GoExecutor(execution_timeout=120,
+                            benchmark=BenchmarkConfig(benchtime='100x')).execute(SNIPPET)
+        assert result.success, result.error
+        assert result.output == '55\n'
+        [bench] = result.benchmarks
+        assert bench.name == 'BenchmarkFib' and bench.iterations == 100
+        assert bench.ns_per_op > 0 and bench.allocs_per_op == 0
+
+    def test_failing_benchmark(self):
+        code = SNIPPET.replace('\t\tfib(10)', '\t\tpanic("boom")')
+        result = GoExecutor(execution_timeout=120,
+                            benchmark=BenchmarkConfig(benchtime='1x')).execute(code)
+        assert not result.success and 'Snippet benchmarks failed' in str(result.error)
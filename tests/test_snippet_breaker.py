@@ -0,0 +1,217 @@
+"""
+Test suite for the snippet circuit breaker.
+
+Tests cover:
+  - CLOSED → OPEN after N consecutive failures inside the window
+  - Failures outside the window, or broken by a success, don't trip it
+  - OPEN → HALF_OPEN after the recovery delay; one trial, then CLOSED or OPEN
+  - Pipeline: open circuits refuse speculate() / promote() / run_full_pipeline()
+  - circuit_state(), reset_circuit() and the audit trail
+"""
+
+import pytest
+
+from visual_editor_core.execution_engine import ExecutionResult
+from visual_editor_core.snippet_staging import StagingPhase, AuditEventType
+from visual_editor_core.snippet_breaker import (
+    CircuitBreaker, BreakerConfig, BreakerState, CircuitOpenError,
+)
+
+
+class FakeClock:
+    def __init__(self, now: float = 1000.0):
+        self.now = now
+
+    def __call__(self) -> float:
+        return self.now
+
+
+class SwitchExecutor:
+    """Go executor stub whose runs fail while `failing` is set."""
+
+    def __init__(self):
+        self.failing = True
+        self.runs = 0
+
+    def execute(self, code):
+        self.runs += 1
+        return ExecutionResult(success=not self.failing, output='',
+                               error='panic: boom' if self.failing else None,
+                               execution_time=0.01)
+
+
+@pytest.fixture
+def clock():
+    return FakeClock()
+
+
+@pytest.fixture
+def breaker(clock):
+    return CircuitBreaker(BreakerConfig(failure_threshold=3, window=60, recovery_delay=30),
+                          clock=clock)
+
+
+@pytest.fixture
+def executor():
+    return SwitchExecutor()
+
+
+@pytest.fixture
+def pipeline(make_pipeline, breaker, executor):
+    return make_pipeline({'go': executor}, circuit_breaker=breaker)
+
+
+GO_CODE = 'package main\nfunc main() { panic("boom") }\n'
+
+
+def fail_n(pipeline, n):
+    ids = []
+    for _ in range(n):
+        snippet = pipeline.queue_snippet('i', 'go', GO_CODE)
+        pipeline.speculate(snippet.staging_id)
+        ids.append(snippet.staging_id)
+    return ids
+
+
+# ─────────────────────────────────────────────────────────────────────
+# CircuitBreaker
+# ─────────────────────────────────────────────────────────────────────
+
+class TestCircuitBreaker:
+    def test_opens_after_consecutive_failures(self, breaker):
+        assert breaker.record_failure('k') is None
+        assert breaker.record_failure('k') is None
+        assert breaker.state('k') == BreakerState.CLOSED
+        assert breaker.record_failure('k') == BreakerState.OPEN
+        with pytest.raises(CircuitOpenError) as exc:
+            breaker.allow('k')
+        assert exc.value.retry_at == 1030.0
+        assert breaker.state('other') == BreakerState.CLOSED
+
+    def test_failures_outside_window_do_not_count(self, breaker, clock):
+        breaker.record_failure('k')
+        breaker.record_failure('k')
+        clock.now += 61
+        breaker.record_failure('k')
+        assert breaker.state('k') == BreakerState.CLOSED
+        assert breaker.describe('k')['consecutive_failures'] == 1
+
+    def test_success_resets_the_streak(self, breaker):
+        breaker.record_failure('k')
+        breaker.record_failure('k')
+        breaker.record_success('k')
+        breaker.record_failure('k')
+        assert breaker.state('k') == BreakerState.CLOSED
+
+    def test_half_open_allows_one_trial(self, breaker, clock):
+        for _ in range(3):
+            breaker.record_failure('k')
+        clock.now += 30
+        assert breaker.state('k') == BreakerState.HALF_OPEN
+        breaker.allow('k')                               # the trial
+        with pytest.raises(CircuitOpenError):
+            breaker.allow('k')
+        assert breaker.record_success('k') == BreakerState.CLOSED
+        breaker.allow('k')
+
+    def test_failed_trial_reopens_and_restarts_the_delay(self, breaker, clock):
+        for _ in range(3):
+            breaker.record_failure('k')
+        clock.now += 30
+        breaker.allow('k')
+        assert breaker.record_failure('k') == BreakerState.OPEN
+        clock.now += 29
+        assert breaker.state('k') == BreakerState.OPEN
+        clock.now += 1
+        assert breaker.state('k') == BreakerState.HALF_OPEN
+
+    def test_check_does_not_consume_the_trial(self, breaker, clock):
+        for _ in range(3):
+            breaker.record_failure('k')
+        clock.now += 30
+        with pytest.raises(CircuitOpenError):
+            breaker.check('k')
+        breaker.allow('k')
+
+    def test_threshold_zero_disables(self, clock):
+        breaker = CircuitBreaker(BreakerConfig(failure_threshold=0), clock=clock)
+        for _ in range(10):
+            breaker.record_failure('k')
+        breaker.allow('k')
+        assert breaker.state('k') == BreakerState.CLOSED
+
+    def test_invalid_config(self):
+        with pytest.raises(ValueError):
+            BreakerConfig(failure_threshold=-1)
+        with pytest.raises(ValueError):
+            BreakerConfig(window=0)
+
+
+# ─────────────────────────────────────────────────────────────────────
+# Pipeline integration
+# ─────────────────────────────────────────────────────────────────────
+
+class TestPipelineBreaker:
+    def test_open_circuit_refuses_speculation(self, pipeline, executor):
+        ids = fail_n(pipeline, 3)
+        assert pipeline.circuit_state(ids[0]) == BreakerState.OPEN
+
+        snippet = pipeline.queue_snippet('i', 'go', GO_CODE)
+        with pytest.raises(CircuitOpenError):
+            pipeline.speculate(snippet.staging_id)
+        assert snippet.phase == StagingPhase.QUEUED
+        assert executor.runs == 3
+
+        events = [e['event'] for e in pipeline.get_audit_trail(ids[-1])]
+        assert AuditEventType.CIRCUIT_OPENED.value in events
+        assert pipeline.get_pipeline_summary()['open_circuits'] == 1
+
+    def test_other_code_and_slots_are_unaffected(self, pipeline, executor):
+        fail_n(pipeline, 3)
+        executor.failing = False
+        other = pipeline.queue_snippet('i', 'go', GO_CODE + '// v2\n')
+        assert pipeline.speculate(other.staging_id).phase == StagingPhase.PASSED
+
+    def test_open_circuit_refuses_promotion(self, pipeline, executor):
+        executor.failing = False
+        passed = pipeline.queue_snippet('i', 'go', GO_CODE)
+        pipeline.speculate(passed.staging_id)
+        executor.failing = True
+        fail_n(pipeline, 3)
+        with pytest.raises(CircuitOpenError):
+            pipeline.promote(passed.staging_id)
+        assert passed.phase == StagingPhase.PASSED
+
+    def test_trial_run_after_recovery_closes_circuit(self, pipeline, executor, clock):
+        ids = fail_n(pipeline, 3)
+        clock.now += 30
+        assert pipeline.circuit_state(ids[0]) == BreakerState.HALF_OPEN
+        executor.failing = False
+        snippet = pipeline.queue_snippet('i', 'go', GO_CODE)
+        assert pipeline.speculate(snippet.staging_id).phase == StagingPhase.PASSED
+        assert pipeline.circuit_state(snippet.staging_id) == BreakerState.CLOSED
+        assert pipeline.promote(snippet.staging_id).phase == StagingPhase.PROMOTED
+
+    def test_run_full_pipeline_rejects_when_open(self, pipeline):
+        fail_n(pipeline, 3)
+        before = pipeline.get_reserved_positions()
+        with pytest.raises(CircuitOpenError):
+            pipeline.run_full_pipeline('i', 'go', GO_CODE)
+        assert pipeline.get_reserved_positions() == before
+        rejected = pipeline.get_history()[-1]
+        assert rejected.phase == StagingPhase.REJECTED
+
+    def test_reset_circuit(self, pipeline, executor):
+        ids = fail_n(pipeline, 3)
+        assert pipeline.reset_circuit(ids[0]) is True
+        assert pipeline.circuit_state(ids[0]) == BreakerState.CLOSED
+        assert pipeline.reset_circuit(ids[0]) is False
+        with pytest.raises(ValueError):
+            pipeline.circuit_state('nope')
+
+    def test_timeout_cancellation_counts_as_failure(self, pipeline, breaker):
+        for _ in range(3):
+            snippet = pipeline.queue_snippet('i', 'go', GO_CODE)
+            snippet.phase = StagingPhase.SPECULATING           # run in flight
+            pipeline.cancel_speculation(snippet.staging_id, 'deadline exceeded')
+        assert pipeline.circuit_state(snippet.staging_id) == BreakerState.OPEN
@@ -0,0 +1,198 @@
+"""
+Test suite for the speculation result cache.
+
+Tests cover:
+  - cache_key(): stable across argument / env ordering, changes with any input
+    (resource limits, isolation and namespace included)
+  - ResultCache: TTL expiry, LRU eviction past max_entries, stats, copies
+  - speculate() of a deterministic snippet: a hit skips the executor and sets
+    spec_cached, the audit entry says cached, lookups are counted
+  - Snippets not marked deterministic are never cached
+  - force_run re-executes and replaces the entry; timed-out runs aren't stored
+  - NamespaceAdmin.clear_result_cache()
+"""
+
+import pytest
+
+from visual_editor_core.execution_engine import ExecutionResult
+from visual_editor_core.snippet_metrics import CACHE_LOOKUPS, PipelineMetrics
+from visual_editor_core.snippet_namespace import NamespaceAdmin
+from visual_editor_core.snippet_staging import SpecResult
+from visual_editor_core.snippet_cache import ResultCache, cache_key
+from visual_editor_core.snippet_capacity import SlotConfig
+from visual_editor_core.snippet_isolation import IsolationLevel
+
+
+GO = 'package main\n\nimport "fmt"\n\nfunc main() {\n\tfmt.Println(6 * 7)\n}\n'
+
+
+class FakeClock:
+    def __init__(self):
+        self.now = 1000.0
+
+    def __call__(self):
+        return self.now
+
+
+class CountingExecutor:
+    """Counts its runs; prints the run number so a re-run is visible."""
+    limitable = True
+
+    def __init__(self):
+        self.runs = 0
+        self.timed_out = False
+
+    def execute(self, code, resource_limits=None):
+        self.runs += 1
+        return ExecutionResult(success=not self.timed_out, output=f'run {self.runs}\n',
+                               execution_time=0.25, timed_out=self.timed_out)
+
+
+def cached_pipeline(make_pipeline, cache=None, **kwargs):
+    return make_pipeline({'go': CountingExecutor()}, metrics=PipelineMetrics(),
+                         result_cache=cache if cache is not None else ResultCache(), **kwargs)
+
+
+def stage(pipeline, label='calc', deterministic=True, slot='i', **kwargs):
+    return pipeline.queue_snippet(slot, 'go', GO, label, deterministic=deterministic, **kwargs)
+
+
+def test_cache_key():
+    key = cache_key('abc', {'n': 1, 'm': 2}, {'B': '1', 'A': '2'})
+    assert key == cache_key('abc', {'m': 2, 'n': 1}, {'A': '2', 'B': '1'})
+    assert key != cache_key('abd', {'n': 1, 'm': 2}, {'B': '1', 'A': '2'})
+    assert key != cache_key('abc', {'n': 1, 'm': 3}, {'B': '1', 'A': '2'})
+    assert key != cache_key('abc', {'n': 1, 'm': 2}, {'B': '1'})
+    assert cache_key('abc', None, None) == cache_key('abc', {}, {})
+    base = cache_key('abc', {}, {}, {'max_cpu_time': 2}, 'process', 'team-a')
+    assert base != cache_key('abc', {}, {}, {'max_cpu_time': 1}, 'process', 'team-a')
+    assert base != cache_key('abc', {}, {}, {'max_cpu_time': 2}, 'namespace', 'team-a')
+    assert base != cache_key('abc', {}, {}, {'max_cpu_time': 2}, 'process', 'team-b')
+
+
+class TestResultCache:
+    def test_ttl(self):
+        clock = FakeClock()
+        cache = ResultCache(ttl=10, clock=clock)
+        cache.put('k', {'output': 'x'})
+        clock.now += 9.9
+        assert cache.get('k') == {'output': 'x'}
+        clock.now += 0.1
+        assert cache.get('k') is None
+        assert cache.stats().to_dict() == {'hits': 1, 'misses': 1, 'evictions': 0,
+                                           'expirations': 1, 'invalidations': 0, 'entries': 0}
+
+    def test_lru(self):
+        cache = ResultCache(max_entries=2)
+        cache.put('a', {})
+        cache.put('b', {})
+        cache.get('a')                                        # b is now the least recent
+        cache.put('c', {})
+        assert cache.get('b') is None and cache.get('a') == {} and cache.get('c') == {}
+        assert cache.stats().evictions == 1
+
+    def test_copies(self):
+        cache = ResultCache()
+        result = {'benchmarks': [{'name': 'BenchmarkA'}]}
+        cache.put('k', result)
+        result['benchmarks'].clear()
+        cache.get('k')['benchmarks'].clear()
+        assert cache.get('k') == {'benchmarks': [{'name': 'BenchmarkA'}]}
+
+    def test_invalidate_and_clear(self):
+        cache = ResultCache()
+        cache.put('a', {})
+        cache.put('b', {})
+        assert cache.invalidate('a') and not cache.invalidate('a')
+        assert cache.clear() == 1
+        assert cache.describe()['invalidations'] == 2 and cache.describe()['entries'] == 0
+
+    @pytest.mark.parametrize('kwargs', [{'ttl': 0}, {'max_entries': 0}])
+    def test_refused(self, kwargs):
+        with pytest.raises(ValueError):
+            ResultCache(**kwargs)
+
+
+class TestSpeculate:
+    def test_hit(self, make_pipeline):
+        pipeline = cached_pipeline(make_pipeline)
+        first = stage(pipeline, 'first')
+        pipeline.speculate(first.staging_id)
+        assert not first.spec_cached and first.spec_output == 'run 1\n'
+
+        second = stage(pipeline, 'second')
+        pipeline.speculate(second.staging_id)
+        assert pipeline._executors['go'].runs == 1
+        assert second.spec_cached and second.spec_result == SpecResult.PASS
+        assert second.spec_output == 'run 1\n' and second.spec_execution_time == 0.25
+        entry = [e for e in pipeline.get_audit_trail(second.staging_id)
+                 if e['event'] == 'spec_exec_completed'][0]
+        assert entry['data']['cached'] is True
+
+        lookups = pipeline.metrics.snapshot()[CACHE_LOOKUPS]
+        assert [(r['outcome'], r['value']) for r in lookups] == [('hit', 1), ('miss', 1)]
+        assert 'snippet_result_cache_lookups_total{language="go",outcome="hit"} 1' in \
+            pipeline.metrics.render_text()
+
+    @pytest.mark.parametrize('kwargs', [
+        {'resource_limits': {'max_cpu_time': 1}},
+        {'namespace': 'team-b'},
+        {'slot': 'j'},                                  # PROCESS isolation
+    ])
+    def test_run_conditions_miss(self, make_pipeline, kwargs):
+        pipeline = cached_pipeline(make_pipeline)
+        pipeline.configure_slot('j', SlotConfig(isolation=IsolationLevel.PROCESS))
+        pipeline.speculate(stage(pipeline, 'first').staging_id)
+        other = stage(pipeline, 'other', **kwargs)
+        pipeline.speculate(other.staging_id)
+        assert not other.spec_cached and pipeline._executors['go'].runs == 2
+
+    def test_not_deterministic(self, make_pipeline):
+        pipeline = cached_pipeline(make_pipeline)
+        for label in ('one', 'two'):
+            snippet = stage(pipeline, label, deterministic=False)
+            pipeline.speculate(snippet.staging_id)
+            assert not snippet.spec_cached
+        assert pipeline._executors['go'].runs == 2
+        assert pipeline.result_cache.stats().entries == 0
+
+    def test_no_cache(self, make_pipeline):
+        pipeline = make_pipeline({'go': CountingExecutor()})
+        for label in ('one', 'two'):
+            pipeline.speculate(stage(pipeline, label).staging_id)
+        assert pipeline._executors['go'].runs == 2
+
+    def test_force_run(self, make_pipeline):
+        pipeline = cached_pipeline(make_pipeline)
+        pipeline.speculate(stage(pipeline, 'first').staging_id)
+        forced = stage(pipeline, 'forced')
+        pipeline.speculate(forced.staging_id, force_run=True)
+        assert not forced.spec_cached and forced.spec_output == 'run 2\n'
+        later = stage(pipeline, 'later')
+        pipeline.speculate(later.staging_id)
+        assert later.spec_cached and later.spec_output == 'run 2\n'
+        assert pipeline.result_cache.stats().invalidations == 1
+
+    def test_timeout_not_stored(self, make_pipeline):
+        pipeline = cached_pipeline(make_pipeline)
+        pipeline._executors['go'].timed_out = True
+        snippet = stage(pipeline, 'slow')
+        pipeline.speculate(snippet.staging_id)
+        assert snippet.spec_result == SpecResult.TIMEOUT
+        assert pipeline.result_cache.stats().entries == 0
+
+    def test_expired(self, make_pipeline):
+        clock = FakeClock()
+        pipeline = cached_pipeline(make_pipeline, ResultCache(ttl=60, clock=clock))
+        pipeline.speculate(stage(pipeline, 'first').staging_id)
+        clock.now += 60
+        again = stage(pipeline, 'again')
+        pipeline.speculate(again.staging_id)
+        assert not again.spec_cached and pipeline._executors['go'].runs == 2
+
+
+def test_admin_clear(make_pipeline):
+    pipeline = cached_pipeline(make_pipeline, namespace_admin_credential='root')
+    pipeline.speculate(stage(pipeline).staging_id)
+    assert NamespaceAdmin(pipeline, 'root').clear_result_cache() == 1
+    assert pipeline.result_cache.stats().entries == 0
@@ -0,0 +1,207 @@
+"""
+Test suite for canary promotions.
+
+Tests cover:
+  - promote(canary=...) leaves the baseline live and puts the snippet in CANARY
+  - Weighted routing between baseline and canary (route_slot)
+  - Stepping on schedule up to 1.0, then a real promotion superseding the baseline
+  - Rollback when the canary's error rate exceeds the threshold
+  - min_executions, abort, baseline rollback, and invalid configurations
+"""
+
+import pytest
+
+from visual_editor_core.snippet_staging import (
+    StagingPhase, AuditEventType, LabelConflictPolicy,
+)
+from visual_editor_core.snippet_canary import (
+    CanaryController, CanaryConfig, CanaryStatus,
+)
+
+
+class FakeClock:
+    def __init__(self, now: float = 1000.0):
+        self.now = now
+
+    def __call__(self) -> float:
+        return self.now
+
+
+class FakeRandom:
+    """rng stub: returns `value` on every call."""
+
+    def __init__(self, value: float = 0.0):
+        self.value = value
+
+    def __call__(self) -> float:
+        return self.value
+
+
+@pytest.fixture
+def clock():
+    return FakeClock()
+
+
+@pytest.fixture
+def rng():
+    return FakeRandom()
+
+
+@pytest.fixture
+def pipeline(make_pipeline, clock, rng):
+    return make_pipeline(canary_controller=CanaryController(rng=rng, clock=clock,
+                                                            background=False))
+
+
+CONFIG = CanaryConfig(start_weight=0.25, step_size=0.25, step_interval=10,
+                      error_threshold=0.1)
+
+
+def stage(pipeline, code, label='svc'):
+    snippet = pipeline.queue_snippet('i', 'go', code, label)
+    return pipeline.speculate(snippet.staging_id)
+
+
+@pytest.fixture
+def baseline(pipeline):
+    return pipeline.promote(stage(pipeline, 'package main // v1').staging_id)
+
+
+@pytest.fixture
+def canary(pipeline, baseline):
+    return pipeline.promote(stage(pipeline, 'package main // v2').staging_id, canary=CONFIG)
+
+
+class TestCanaryStart:
+    def test_canary_phase_and_baseline_stays_live(self, pipeline, baseline, canary):
+        assert canary.phase == StagingPhase.CANARY
+        assert canary.canary_weight == 0.25
+        assert canary.canary_baseline_id == baseline.staging_id
+        assert baseline.phase == StagingPhase.PROMOTED
+        rollout = pipeline.get_canary(canary.staging_id)
+        assert rollout.slot_id == baseline.registry_slot_id
+        assert pipeline.get_pipeline_summary()['running_canaries'] == 1
+
+    def test_requires_live_baseline(self, pipeline):
+        snippet = stage(pipeline, 'package main // lonely')
+        with pytest.raises(ValueError, match='live version'):
+            pipeline.promote(snippet.staging_id, canary=CONFIG)
+        assert snippet.phase == StagingPhase.PASSED
+
+    def test_one_canary_per_slot(self, pipeline, canary):
+        other = stage(pipeline, 'package main // v3')
+        with pytest.raises(ValueError, match='already has a canary'):
+            pipeline.promote(other.staging_id, canary=CONFIG)
+
+    def test_requires_overwrite_policy(self, pipeline):
+        # Queued under REJECT before the label went live
+        snippet = pipeline.queue_snippet('i', 'go', 'package main // v2', 'svc',
+                                         label_policy=LabelConflictPolicy.REJECT)
+        pipeline.speculate(snippet.staging_id)
+        pipeline.promote(stage(pipeline, 'package main // v1').staging_id)
+        with pytest.raises(ValueError, match="'overwrite' label policy"):
+            pipeline.promote(snippet.staging_id, canary=CONFIG)
+
+
+class TestRouting:
+    def test_weighted_selection(self, pipeline, baseline, canary, rng):
+        slot_id = baseline.registry_slot_id
+        rng.value = 0.24
+        assert pipeline.route_slot(slot_id) is canary
+        rng.value = 0.25
+        assert pipeline.route_slot(slot_id) is None
+
+    def test_other_slots_unaffected(self, pipeline, canary, rng):
+        rng.value = 0.0
+        assert pipeline.route_slot('nri99') is None
+
+    def test_results_feed_error_rates(self, pipeline, baseline, canary):
+        slot_id = baseline.registry_slot_id
+        pipeline.record_slot_run(slot_id, canary, False)
+        pipeline.record_slot_run(slot_id, canary, True)
+        pipeline.record_slot_run(slot_id, None, True)
+        rollout = pipeline.get_canary(canary.staging_id)
+        assert (rollout.canary_runs, rollout.canary_errors) == (2, 1)
+        assert rollout.error_rate == 0.5
+        assert rollout.baseline_runs == 1
+
+
+class TestStepping:
+    def test_ramps_to_full_promotion(self, pipeline, baseline, canary, clock):
+        controller = pipeline.canaries
+        controller.tick()
+        assert canary.canary_weight == 0.25                  # interval not elapsed
+        for expected in (0.5, 0.75):
+            clock.now += 10
+            controller.tick()
+            assert canary.canary_weight == expected
+        clock.now += 10
+        controller.tick()
+
+        assert canary.phase == StagingPhase.PROMOTED
+        assert baseline.phase == StagingPhase.SUPERSEDED
+        assert canary.reserved_address == baseline.reserved_address
+        rollout = pipeline.get_canary(canary.staging_id)
+        assert rollout.status == CanaryStatus.COMPLETED
+        assert [s['weight'] for s in rollout.steps] == [0.25, 0.5, 0.75, 1.0]
+        assert pipeline.route_slot(canary.registry_slot_id) is None
+
+        events = [e['event'] for e in pipeline.get_audit_trail(canary.staging_id)]
+        assert AuditEventType.CANARY_STARTED.value in events
+        assert AuditEventType.CANARY_COMPLETED.value in events
+
+    def test_error_rate_over_threshold_rolls_back(self, pipeline, baseline, canary, clock):
+        slot_id = baseline.registry_slot_id
+        for ok in (True, False, True):
+            pipeline.record_slot_run(slot_id, canary, ok)
+        clock.now += 10
+        pipeline.canaries.tick()
+
+        assert canary.phase == StagingPhase.REJECTED
+        assert 'error rate' in canary.rejection_reason
+        assert baseline.phase == StagingPhase.PROMOTED
+        assert pipeline.get_canary(canary.staging_id).status == CanaryStatus.ROLLED_BACK
+        assert pipeline.route_slot(slot_id) is None
+
+    def test_min_executions_holds_the_step(self, pipeline, baseline, clock):
+        config = CanaryConfig(start_weight=0.5, step_size=0.5, step_interval=10,
+                              min_executions=2)
+        snippet = pipeline.promote(stage(pipeline, 'package main // v2').staging_id,
+                                   canary=config)
+        clock.now += 10
+        pipeline.canaries.tick()
+        assert snippet.canary_weight == 0.5
+        pipeline.record_slot_run(baseline.registry_slot_id, snippet, True)
+        pipeline.record_slot_run(baseline.registry_slot_id, snippet, True)
+        pipeline.canaries.tick()
+        assert snippet.phase == StagingPhase.PROMOTED
+
+
+class TestStopping:
+    def test_abort(self, pipeline, baseline, canary):
+        rollout = pipeline.abort_canary(canary.staging_id, 'not today')
+        assert rollout.status == CanaryStatus.ABORTED
+        assert canary.phase == StagingPhase.REJECTED
+        assert baseline.phase == StagingPhase.PROMOTED
+        with pytest.raises(ValueError):
+            pipeline.abort_canary(canary.staging_id)
+
+    def test_baseline_rollback_aborts_canary(self, pipeline, baseline, canary):
+        pipeline.rollback(baseline.staging_id)
+        assert pipeline.get_canary(canary.staging_id).status == CanaryStatus.ABORTED
+        assert canary.phase == StagingPhase.REJECTED
+
+
+class TestCanaryConfig:
+    @pytest.mark.parametrize('kwargs', [
+        {'start_weight': 0}, {'start_weight': 1.5}, {'step_size': 0},
+        {'step_interval': 0}, {'error_threshold': 2}, {'min_executions': -1},
+    ])
+    def test_invalid(self, kwargs):
+        with pytest.raises(ValueError):
+            CanaryConfig(**kwargs)
+
+    def test_from_dict_rejects_unknown_keys(self):
+        assert CanaryConfig.from_dict({'start_weight': 0.1}).start_weight == 0.1
+        with pytest.raises(ValueError, match='Unknown canary option'):
+            CanaryConfig.from_dict({'weight': 0.1})
@@ -0,0 +1,282 @@
+"""
+Test suite for table-driven test cases declared at stage time.
+
+Tests cover:
+  - TestCase validation: names, expected_output vs. regex, exit codes,
+    unknown fields
+  - validate_cases(): unique names, params checked against the specs
+  - check_case(): exact output (trailing newlines ignored), regex, exit
+    code (reported or derived), timeouts
+  - queue_snippet(test_cases=...) refusing params that don't fit
+  - speculate(): every case run with its params, spec_cases recorded,
+    CASE_FAIL naming the failing cases; no cases after a failed run
+  - dry_run_promote(), revalidate_all(), scheduled reruns (HEALTH_ALERT) and
+    replay() holding snippets to their cases
+  - Manifests and export / import carrying test_cases
+  - GoExecutor exit codes (with a toolchain)
+"""
+
+import io
+import re
+import shutil
+import pytest
+
+from visual_editor_core.execution_engine import ExecutionResult, GoExecutor
+from visual_editor_core.snippet_staging import SpecResult, StagingPhase
+from visual_editor_core.snippet_params import ParameterError, ParameterSpec, ParamType
+from visual_editor_core.snippet_manifest import ManifestEntry, yaml
+from visual_editor_core.snippet_query import SnippetFilter
+from visual_editor_core.snippet_cases import (
+    TestCase, check_case, describe_failures, validate_cases,
+)
+
+
+FIB = '''package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func fib(k int) int {
+	if k < 2 {
+		return k
+	}
+	return fib(k-1) + fib(k-2)
+}
+
+func main() {
+	if n < 0 {
+		fmt.Println("n must be >= 0")
+		os.Exit(2)
+	}
+	fmt.Println(fib(n))
+}
+'''
+
+SPECS = [ParameterSpec('n', ParamType.INT, required=False, default=5)]
+
+
+def fib(k):
+    return k if k < 2 else fib(k - 1) + fib(k - 2)
+
+
+class FibExecutor:
+    """Runs FIB by reading the bound `n` out of the injected var block."""
+
+    def __init__(self, off_by_one_above=None):
+        self.ran = []
+        self.off_by_one_above = off_by_one_above     # Simulates a bug for large n
+
+    def execute(self, code, **kwargs):
+        n = int(re.search(r'n\s+int\s*=\s*(-?\d+)', code).group(1))
+        self.ran.append(n)
+        if n < 0:
+            return ExecutionResult(success=False, output='n must be >= 0\n',
+                                   error=Exception('exit status 2'), execution_time=0.01,
+                                   exit_code=2)
+        value = fib(n)
+        if self.off_by_one_above is not None and n > self.off_by_one_above:
+            value += 1
+        return ExecutionResult(success=True, output=f'{value}\n', execution_time=0.01,
+                               exit_code=0)
+
+
+CASES = [
+    {'name': 'zero', 'params': {'n': 0}, 'expected_output': '0'},
+    {'name': 'ten', 'params': {'n': 10}, 'expected_output': '55\n'},
+    {'name': 'negative', 'params': {'n': -1}, 'expected_exit_code': 2,
+     'expected_output_regex': r'^n must be >= 0$'},
+]
+
+
+def fib_pipeline(make_pipeline, executor=None, root=''):
+    executor = executor or FibExecutor()
+    return make_pipeline({'go': executor}, root=root), executor
+
+
+def stage(pipeline, cases=CASES, label='fib'):
+    return pipeline.queue_snippet('i', 'go', FIB, label, parameters=SPECS, test_cases=cases)
+
+
+class TestTestCase:
+    @pytest.mark.parametrize('kwargs, message', [
+        ({'name': ''}, 'Invalid test case name'),
+        ({'name': 'a b'}, 'Invalid test case name'),
+        ({'name': 'x', 'expected_output': '1', 'expected_output_regex': '1'}, 'not both'),
+        ({'name': 'x', 'expected_output_regex': '('}, 'invalid expected_output_regex'),
+        ({'name': 'x', 'expected_exit_code': 256}, '0-255'),
+        ({'name': 'x', 'expected_exit_code': True}, '0-255'),
+        ({'name': 'x', 'params': [1]}, 'must be a mapping'),
+    ])
+    def test_refused(self, kwargs, message):
+        with pytest.raises(ValueError, match=message):
+            TestCase(**kwargs)
+
+    def test_from_dict(self):
+        case = TestCase.from_dict(CASES[2])
+        assert case.to_dict() == {'name': 'negative', 'params': {'n': -1},
+                                  'expected_output': None,
+                                  'expected_output_regex': r'^n must be >= 0$',
+                                  'expected_exit_code': 2}
+        with pytest.raises(ValueError, match='Unknown test case field'):
+            TestCase.from_dict({'name': 'x', 'stdin': ''})
+
+    def test_validate_cases(self):
+        cases = validate_cases([{'name': 'd'}, TestCase('e', {'n': 7.0})], SPECS)
+        assert [c.params for c in cases] == [{'n': 5}, {'n': 7}]      # Default; 7.0 coerced
+        with pytest.raises(ValueError, match="Duplicate test case name 'd'"):
+            validate_cases([{'name': 'd'}, {'name': 'd'}], SPECS)
+        with pytest.raises(ParameterError, match='Unknown parameter'):
+            validate_cases([{'name': 'x', 'params': {'m': 1}}], SPECS)
+        with pytest.raises(ParameterError, match='Unknown parameter'):
+            validate_cases([{'name': 'x', 'params': {'m': 1}}])          # No specs at all
+        assert validate_cases(None) == []
+
+
+class TestCheckCase:
+    def test_output(self):
+        case = TestCase('t', expected_output='55')
+        assert check_case(case, {'success': True, 'output': '55\n', 'exit_code': 0}).passed
+        result = check_case(case, {'success': True, 'output': '56\n', 'exit_code': 0})
+        assert not result.passed and result.failures == ["output '56', expected '55'"]
+
+    def test_regex_and_exit_code(self):
+        case = TestCase('t', expected_output_regex=r'^boom$', expected_exit_code=3)
+        assert check_case(case, {'success': False, 'output': 'x\nboom\n', 'exit_code': 3}).passed
+        result = check_case(case, {'success': True, 'output': 'fine\n', 'exit_code': 0})
+        assert result.failures == ['exit code 0, expected 3', 'output does not match /^boom$/']
+
+    def test_exit_code_derived(self):
+        case = TestCase('t')
+        assert check_case(case, {'success': True}).exit_code == 0
+        assert check_case(case, {'success': False}).exit_code == 1
+
+    def test_timeout(self):
+        result = check_case(TestCase('t', expected_exit_code=1),
+                            {'success': False, 'timed_out': True})
+        assert result.failures == ['timed out']
+
+
+class TestSpeculate:
+    def test_all_cases_pass(self, make_pipeline):
+        pipeline, executor = fib_pipeline(make_pipeline)
+        snippet = pipeline.speculate(stage(pipeline).staging_id)
+        assert snippet.phase == StagingPhase.PASSED and snippet.spec_result == SpecResult.PASS
+        assert executor.ran == [5, 0, 10, -1]            # Own run, then the cases in order
+        assert [(c['name'], c['passed'], c['exit_code']) for c in snippet.spec_cases] == [
+            ('zero', True, 0), ('ten', True, 0), ('negative', True, 2)]
+        assert snippet.to_dict()['test_cases'][1]['params'] == {'n': 10}
+
+    def test_failing_case(self, make_pipeline):
+        pipeline, executor = fib_pipeline(make_pipeline, FibExecutor(off_by_one_above=5))
+        snippet = pipeline.speculate(stage(pipeline).staging_id)
+        assert snippet.phase == StagingPhase.FAILED
+        assert snippet.spec_result == SpecResult.CASE_FAIL
+        assert snippet.spec_error == "1 of 3 test cases failed: ten (output '56', expected '55')"
+        assert [c['passed'] for c in snippet.spec_cases] == [True, False, True]
+        failed = [e['data'] for e in pipeline.get_audit_trail(snippet.staging_id)
+                  if e['event'] == 'spec_exec_failed'][-1]
+        assert failed['failed_cases'] == ['ten'] and failed['spec_result'] == 'CASE_FAIL'
+
+    def test_no_cases_after_a_failed_run(self, make_pipeline):
+        pipeline, executor = fib_pipeline(make_pipeline)
+        snippet = pipeline.speculate(stage(pipeline).staging_id, arguments={'n': -3})
+        assert snippet.spec_result == SpecResult.FAIL and snippet.spec_cases == []
+        assert executor.ran == [-3]
+
+    def test_stage_refuses_bad_params(self, make_pipeline):
+        pipeline, _ = fib_pipeline(make_pipeline)
+        with pytest.raises(ParameterError, match="Missing required parameter 'n'"):
+            pipeline.queue_snippet('i', 'go', FIB, 'fib',
+                                   parameters=[ParameterSpec('n', ParamType.INT)],
+                                   test_cases=[{'name': 'empty'}])
+
+    def test_dry_run(self, make_pipeline):
+        pipeline, _ = fib_pipeline(make_pipeline, FibExecutor(off_by_one_above=5))
+        snippet = pipeline.speculate(stage(pipeline, cases=CASES[:1]).staging_id)
+        pipeline._staged[snippet.staging_id].test_cases = CASES   # As if declared after
+        report = pipeline.dry_run_promote(snippet.staging_id)
+        gate = next(g for g in report.gates if g.gate == 'speculation')
+        assert gate.status.value == 'failed' and gate.data['spec_result'] == 'CASE_FAIL'
+        assert [c['name'] for c in gate.data['cases']] == ['zero', 'ten', 'negative']
+
+    def test_revalidate(self, make_pipeline):
+        executor = FibExecutor()
+        pipeline, _ = fib_pipeline(make_pipeline, executor)
+        snippet = pipeline.speculate(stage(pipeline).staging_id)
+        pipeline.promote(snippet.staging_id)
+        executor.off_by_one_above = 5                  # The engine upgrade broke fib(10)
+        events = list(pipeline.revalidate_all('i'))
+        assert events[0].spec_result == 'CASE_FAIL' and 'ten' in events[0].error
+        assert events[-1].summary.failed == 1
+
+    def test_rerun_health_alert(self, make_pipeline):
+        executor = FibExecutor()
+        pipeline, _ = fib_pipeline(make_pipeline, executor)
+        snippet = pipeline.speculate(stage(pipeline).staging_id)
+        pipeline.promote(snippet.staging_id)
+        assert pipeline.rerun_snippet(snippet.staging_id).spec_result == 'PASS'
+        executor.off_by_one_above = 5
+        record = pipeline.rerun_snippet(snippet.staging_id)
+        assert record.spec_result == 'CASE_FAIL' and record.health_alert
+        assert 'ten' in record.error
+        assert any(e['event'] == 'health_alert'
+                   for e in pipeline.get_audit_trail(snippet.staging_id))
+
+    def test_replay(self, make_pipeline):
+        executor = FibExecutor()
+        pipeline, _ = fib_pipeline(make_pipeline, executor)
+        snippet = pipeline.speculate(stage(pipeline).staging_id)
+        pipeline.promote(snippet.staging_id)
+        executor.off_by_one_above = 5
+        executor.ran.clear()
+        replayed = pipeline.replay(snippet.staging_id)
+        assert replayed.spec_result == 'CASE_FAIL' and not replayed.success
+        assert executor.ran == [5, 0, 10, -1]
+
+    def test_import(self, make_pipeline):
+        source, _ = fib_pipeline(make_pipeline, root='dev')
+        stage(source)
+        out = io.StringIO()
+        source.export_snippets(SnippetFilter(), out)
+        target, executor = fib_pipeline(make_pipeline, FibExecutor(off_by_one_above=5),
+                                         root='prod')
+        record = target.import_snippets(out.getvalue().splitlines()).records[0]
+        imported = target.get_snippet(record.staging_id)
+        assert [c['name'] for c in imported.test_cases] == ['zero', 'ten', 'negative']
+        assert record.spec_result == 'CASE_FAIL'
+
+
+class TestManifest:
+    def test_entry(self):
+        entry = ManifestEntry.from_dict({'label': 'fib', 'language': 'go', 'slot': 'i',
+                                         'source': FIB, 'test_cases': CASES[:1]})
+        assert entry.test_cases == CASES[:1] and entry.to_dict()['test_cases'] == CASES[:1]
+        with pytest.raises(ValueError, match="'test_cases' must be a list"):
+            ManifestEntry.from_dict({'label': 'fib', 'language': 'go', 'slot': 'i',
+                                     'source': FIB, 'test_cases': {'name': 'x'}})
+
+    @pytest.mark.skipif(yaml is None, reason='PyYAML not installed')
+    def test_load(self, make_pipeline):
+        pipeline, _ = fib_pipeline(make_pipeline)
+        text = yaml.safe_dump({'format': 'spokedpy-manifest/1', 'snippets': [
+            {'label': 'fib', 'language': 'go', 'slot': 'i', 'source': FIB,
+             'parameters': [s.to_dict() for s in SPECS], 'test_cases': CASES}]})
+        snippet = pipeline.get_snippet(pipeline.load_manifest(text)[0])
+        assert [c['name'] for c in snippet.test_cases] == ['zero', 'ten', 'negative']
+
+
+def test_describe_failures():
+    assert describe_failures([]) == ''
+
+
+@pytest.mark.skipif(shutil.which('go') is None, reason='go toolchain not installed')
+def test_go_exit_codes(make_pipeline):
+    executor = GoExecutor(execution_timeout=120)
+    pipeline, _ = fib_pipeline(make_pipeline, executor)
+    snippet = pipeline.speculate(stage(pipeline).staging_id)
+    assert snippet.spec_result == SpecResult.PASS, snippet.spec_error
+    assert [c['exit_code'] for c in snippet.spec_cases] == [0, 0, 2]
+    assert executor.execute('package main\n\nfunc main() {}\n').exit_code == 0
+    assert executor.execute('package main\n\nfunc main( {\n').exit_code is None   # Build failed
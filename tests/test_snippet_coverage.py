@@ -0,0 +1,173 @@
+"""
+Test suite for the Go coverage gate.
+
+Tests cover:
+  - CoverageGate validation and describe()
+  - has_go_tests() / split_go_tests(): Test functions move to the test file,
+    each file keeps only the imports it uses
+  - parse_cover_profile(): statement weighting, repeated blocks, empty profiles
+  - speculate() records coverage_percent, COVERAGE_FAIL with the percentage in
+    spec_error, the 4006 stream close and the slot report count; dry_run_promote()
+  - GoExecutor end to end: measured coverage, a failing test, a snippet without tests
+"""
+
+import shutil
+import textwrap
+import pytest
+
+from visual_editor_core.execution_engine import ExecutionResult, GoExecutor
+from visual_editor_core.snippet_staging import SpecResult
+from visual_editor_core.snippet_stream import CloseCode
+from visual_editor_core.snippet_dryrun import GATE_SPECULATION, GateStatus
+from visual_editor_core.snippet_coverage import (
+    CoverageGate, has_go_tests, parse_cover_profile, split_go_tests,
+)
+
+
+SNIPPET = textwrap.dedent('''\
+    package main
+
+    import (
+    \t"fmt"
+    \t"strings"
+    \t"testing"
+    )
+
+    func shout(s string) string {
+    \tif s == "" {
+    \t\treturn "!"
+    \t}
+    \treturn strings.ToUpper(s) + "!"
+    }
+
+    func main() {
+    \tfmt.Println(shout("hi"))
+    }
+
+    func TestShout(t *testing.T) {
+    \tif got := shout("a"); got != "A!" {
+    \t\tt.Fatalf("got %q", got)
+    \t}
+    }
+''')
+
+
+class CoverageExecutor:
+    """Reports a measured coverage; below 50% it fails the gate."""
+
+    def __init__(self, percent):
+        self.percent = percent
+
+    def execute(self, code):
+        gate = CoverageGate(min_coverage_percent=50)
+        shortfall = gate.describe(self.percent)
+        return ExecutionResult(success=not shortfall, output='ok',
+                               error=Exception(shortfall) if shortfall else None,
+                               execution_time=0.01, coverage_percent=self.percent,
+                               coverage_failed=bool(shortfall))
+
+
+class TestCoverageGate:
+    def test_validation(self):
+        for bad in (-1, 101, True):
+            with pytest.raises(ValueError, match='between 0 and 100'):
+                CoverageGate(min_coverage_percent=bad)
+        with pytest.raises(ValueError):
+            CoverageGate(timeout=0)
+
+    def test_describe(self):
+        gate = CoverageGate(min_coverage_percent=80)
+        assert gate.describe(80) == ''
+        assert gate.describe(66.666) == 'Coverage 66.7% is below the minimum of 80%'
+
+
+class TestSplit:
+    def test_detects_tests(self):
+        assert has_go_tests(SNIPPET)
+        assert not has_go_tests('package main\nfunc main() {}\n')
+        assert not has_go_tests('package main\nfunc Testify(x int) {}\n')
+
+    def test_split(self):
+        main_src, test_src = split_go_tests(SNIPPET)
+        assert 'func TestShout' not in main_src and 'func main()' in main_src
+        assert '"testing"' not in main_src
+        assert '"fmt"' in main_src and '"strings"' in main_src
+        assert test_src.startswith('package main\n')
+        assert '"testing"' in test_src and '"fmt"' not in test_src
+        assert 'func TestShout' in test_src and test_src.rstrip().endswith('}')
+
+    def test_single_line_imports(self):
+        main_src, test_src = split_go_tests(
+            'package main\nimport "testing"\nimport _ "embed"\n'
+            'func main() {}\nfunc TestX(t *testing.T) { t.Log("}") }\n')
+        assert '_ "embed"' in main_src and '_ "embed"' not in test_src
+        assert 't.Log("}") }' in test_src and 'TestX' not in main_src
+
+
+def test_parse_cover_profile():
+    profile = textwrap.dedent('''\
+        mode: set
+        command-line-arguments/main.go:9.30,10.13 1 1
+        command-line-arguments/main.go:10.13,12.3 1 0
+        command-line-arguments/main.go:13.2,13.34 2 1
+        command-line-arguments/main.go:10.13,12.3 1 1
+        command-line-arguments/main.go:16.13,18.2 4 0
+    ''')
+    assert parse_cover_profile(profile) == pytest.approx(100.0 * 4 / 8)
+    assert parse_cover_profile('mode: set\n') == 100.0
+
+
+class TestSpeculation:
+    def test_records_coverage(self, make_pipeline):
+        pipeline = make_pipeline({'go': CoverageExecutor(75.0)})
+        snippet = pipeline.queue_snippet('i', 'go', SNIPPET, 'shout')
+        pipeline.speculate(snippet.staging_id)
+        assert snippet.spec_result == SpecResult.PASS
+        assert snippet.coverage_percent == 75.0
+        entry = [e for e in pipeline.get_audit_trail(snippet.staging_id)
+                 if e['event'] == 'spec_exec_completed'][0]
+        assert entry['data']['coverage_percent'] == 75.0
+
+    def test_coverage_fail(self, make_pipeline):
+        pipeline = make_pipeline({'go': CoverageExecutor(25.0)})
+        snippet = pipeline.queue_snippet('i', 'go', SNIPPET, 'shout')
+        pipeline.speculate(snippet.staging_id)
+        assert snippet.spec_result == SpecResult.COVERAGE_FAIL
+        assert '25.0%' in snippet.spec_error and snippet.coverage_percent == 25.0
+        assert pipeline.output_stream(snippet.staging_id).close_code == CloseCode.COVERAGE_FAIL
+        assert pipeline.slot_report('i', since=0).coverage_fail_count == 1
+
+    def test_dry_run(self, make_pipeline):
+        pipeline = make_pipeline({'go': CoverageExecutor(25.0)})
+        snippet = pipeline.queue_snippet('i', 'go', SNIPPET, 'shout')
+        gate = pipeline.dry_run_promote(snippet.staging_id).gate(GATE_SPECULATION)
+        assert gate.status == GateStatus.FAILED and gate.data['spec_result'] == 'COVERAGE_FAIL'
+        assert gate.detail == 'Coverage 25.0% is below the minimum of 50%'
+        assert gate.data['coverage_percent'] == 25.0
+
+
+@pytest.mark.skipif(shutil.which('go') is None, reason='go toolchain not installed')
+class TestGoExecutor:
+    def test_measures_coverage(self):
+        result = GoExecutor(execution_timeout=60,
+                            coverage_gate=CoverageGate(min_coverage_percent=50)).execute(SNIPPET)
+        assert result.success, result.error
+        assert result.output.strip() == 'HI!'
+        assert 50 <= result.coverage_percent < 100            # The empty-string branch never ran
+
+    def test_below_minimum(self):
+        result = GoExecutor(execution_timeout=60,
+                            coverage_gate=CoverageGate(min_coverage_percent=95)).execute(SNIPPET)
+        assert not result.success and result.coverage_failed
+        assert 'below the minimum of 95%' in str(result.error)
+
+    def test_failing_test(self):
+        code = SNIPPET.replace('"A!"', '"B!"')
+        result = GoExecutor(execution_timeout=60, coverage_gate=CoverageGate()).execute(code)
+        assert not result.success and not result.coverage_failed
+        assert 'Snippet tests failed' in str(result.error) and 'TestShout' in str(result.error)
+
+    def test_without_tests(self):
+        result = GoExecutor(execution_timeout=60, coverage_gate=CoverageGate(
+            min_coverage_percent=100)).execute('package main\nfunc main() {}\n')
+        assert result.success and result.coverage_percent is None
@@ -0,0 +1,97 @@
+"""
+Test suite for the slot dependency graph.
+
+Tests cover:
+  - DepGraph.build(): nodes, edges, missing required labels
+  - topological_order(): dependencies first; a cycle raises with its path
+  - critical_path(): the chain with the most promotions
+  - to_dot() / write_dot(): quoting, dashed missing labels, bold critical path
+  - dependency_graph() over a pipeline: newest live version per label, promotion
+    counts from the history, namespaces kept apart, unknown slots
+"""
+
+import io
+import pytest
+
+from visual_editor_core.snippet_deps import CyclicDependencyError
+from visual_editor_core.snippet_depgraph import DepGraph, DepNode
+
+
+class Live:
+    """The StagedSnippet fields DepGraph.build() reads."""
+
+    def __init__(self, label, requires=(), staging_id=''):
+        self.label = label
+        self.requires = list(requires)
+        self.staging_id = staging_id or f'stg-{label}'
+
+
+def promote(pipeline, label, code='package main\nfunc main() {}\n', **kwargs):
+    snippet = pipeline.queue_snippet('i', 'go', code, label, **kwargs)
+    pipeline.speculate(snippet.staging_id)
+    return pipeline.promote(snippet.staging_id)
+
+
+def diamond(promotions=None):
+    return DepGraph.build('i', 'default', [
+        Live('app', ['http', 'db']), Live('http', ['utils']), Live('db', ['utils', 'driver']),
+        Live('utils')], promotions)
+
+
+class TestGraph:
+    def test_build(self):
+        graph = diamond()
+        assert graph.edges['db'] == ['utils', 'driver']
+        assert graph.nodes['driver'] == DepNode('driver', missing=True)
+        assert graph.nodes['app'].staging_id == 'stg-app'
+
+    def test_topological_order(self):
+        order = diamond().topological_order()
+        assert order == ['driver', 'utils', 'db', 'http', 'app']
+
+    def test_cycle(self):
+        with pytest.raises(CyclicDependencyError) as exc:
+            DepGraph.build('i', 'default', [Live('a', ['b']), Live('b', ['c']),
+                                            Live('c', ['a']), Live('d', ['a'])])
+        assert exc.value.chain == ['a', 'b', 'c', 'a']
+
+    def test_critical_path(self):
+        assert diamond({'app': 1, 'http': 1, 'db': 1, 'utils': 1}).critical_path() == [
+            'utils', 'db', 'app']                             # Even: the first label wins
+        assert diamond({'app': 1, 'http': 5, 'db': 1, 'utils': 1}).critical_path() == [
+            'utils', 'http', 'app']
+        assert DepGraph('i', 'default').critical_path() == []
+
+    def test_dot(self):
+        graph = DepGraph.build('i', 'default', [Live('say "hi"', ['fmt']), Live('fmt')],
+                               {'fmt': 3})
+        out = io.StringIO()
+        graph.write_dot(out)
+        assert out.getvalue() == (
+            'digraph "slot i" {\n'
+            '  rankdir=LR;\n'
+            '  node [shape=box];\n'
+            '  "fmt" [label="fmt\\n3 promotions", style=bold];\n'
+            '  "say \\"hi\\"" [label="say \\"hi\\"\\n0 promotions", style=bold];\n'
+            '  "say \\"hi\\"" -> "fmt" [style=bold];\n'
+            '}\n')
+        assert 'style=dashed' in diamond().to_dot()
+
+
+class TestPipeline:
+    def test_graph(self, make_pipeline):
+        pipeline = make_pipeline()
+        promote(pipeline, 'utils')
+        promote(pipeline, 'utils', 'package main\nfunc main() {} // v2\n')
+        fib = promote(pipeline, 'fib', requires=['utils'])
+        promote(pipeline, 'other', namespace='team-b')
+
+        graph = pipeline.dependency_graph('i')
+        assert graph.topological_order() == ['utils', 'fib']
+        assert graph.nodes['utils'].promotions == 2 and graph.nodes['fib'].promotions == 1
+        assert graph.nodes['fib'].staging_id == fib.staging_id
+        assert graph.critical_path() == ['utils', 'fib']
+        assert graph.to_dict()['edges'] == [{'from': 'fib', 'to': 'utils'}]
+        assert pipeline.dependency_graph('i', namespace='team-b').topological_order() == ['other']
+        with pytest.raises(ValueError, match='Unknown slot'):
+            pipeline.dependency_graph('?')
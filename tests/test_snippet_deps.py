@@ -0,0 +1,166 @@
+"""
+Test suite for snippet dependencies.
+
+Tests cover:
+  - validate_requires() and resolve_dependencies(): order, transitive labels, cycles
+  - merge_go_sources(): one package clause, imports de-duplicated into one block,
+    dependencies' main (and the imports only it used) dropped
+  - queue_snippet(requires=…): merged program and its code_hash, missing labels
+  - speculate / promote run and store the merged program
+  - Promoting a dependency again makes dependents staged against it stale
+  - GoExecutor end to end (the merged program compiles and calls the helper)
+"""
+
+import shutil
+import pytest
+
+from visual_editor_core.snippet_staging import StagingPhase
+from visual_editor_core.snippet_deps import (
+    CyclicDependencyError, DependencyError, MissingDependencyError, StaleDependencyError,
+    merge_go_sources, resolve_dependencies, source_hash, validate_requires,
+)
+
+
+MATHUTILS = '''package main
+
+import (
+\t"os"
+\t"strconv"
+)
+
+func Square(n int) int { return n * n }
+
+func Show(n int) string { return strconv.Itoa(n) }
+
+func main() {
+\tif Square(3) != 9 { // self-check; a "}" in a comment must not end main
+\t\tos.Exit(1)
+\t}
+}
+'''
+
+FIB = '''package main
+
+import (
+\t"fmt"
+\t"strconv"
+)
+
+func main() {
+\tfmt.Println(Show(Square(7)), strconv.Itoa(1))
+}
+'''
+
+
+class Node:
+    def __init__(self, label, requires=()):
+        self.label = label
+        self.requires = list(requires)
+
+
+def promote(pipeline, code, label, requires=None):
+    snippet = pipeline.queue_snippet('i', 'go', code, label, requires=requires)
+    pipeline.speculate(snippet.staging_id)
+    return pipeline.promote(snippet.staging_id)
+
+
+class TestResolve:
+    def test_validate_requires(self):
+        assert validate_requires([' a', 'b', 'a']) == ['a', 'b']
+        assert validate_requires(None) == []
+        with pytest.raises(DependencyError):
+            validate_requires('mathutils')
+        with pytest.raises(DependencyError):
+            validate_requires(['ok', ''])
+
+    def test_transitive_order(self):
+        graph = {'fmt2': Node('fmt2', ['base']), 'base': Node('base'),
+                 'math': Node('math', ['base'])}
+        ordered = resolve_dependencies('app', ['math', 'fmt2'], graph.get)
+        assert [n.label for n in ordered] == ['base', 'math', 'fmt2']
+
+    def test_cycle(self):
+        graph = {'b': Node('b', ['c']), 'c': Node('c', ['app'])}
+        with pytest.raises(CyclicDependencyError) as exc:
+            resolve_dependencies('app', ['b'], graph.get)
+        assert exc.value.chain == ['app', 'b', 'c', 'app']
+        with pytest.raises(CyclicDependencyError):
+            resolve_dependencies('app', ['app'], graph.get)
+
+    def test_missing(self):
+        with pytest.raises(MissingDependencyError, match="'gone'"):
+            resolve_dependencies('app', ['gone'], {}.get)
+
+
+def test_merge_go_sources():
+    merged = merge_go_sources([MATHUTILS, FIB])
+    assert merged.count('package main') == 1
+    assert merged.count('"strconv"') == 1 and '"fmt"' in merged
+    assert merged.count('func main') == 1 and 'Square(3)' not in merged
+    assert '"os"' not in merged                   # only the dropped main used it
+    assert merged.index('func Square') < merged.index('func main')
+    assert merged.startswith('package main\n\nimport (\n')
+
+
+class TestPipeline:
+    def test_merged_program(self, pipeline, passing_executor):
+        util = promote(pipeline, MATHUTILS, 'mathutils')
+        fib = pipeline.queue_snippet('i', 'go', FIB, 'fib', requires=['mathutils'])
+
+        assert fib.code == FIB
+        assert fib.requires == ['mathutils']
+        assert fib.dependencies == [{'label': 'mathutils', 'staging_id': util.staging_id,
+                                     'code_hash': source_hash(MATHUTILS)}]
+        assert fib.code_hash != source_hash(FIB)
+        assert fib.program == fib.merged_code and 'func Square' in fib.merged_code
+
+        pipeline.speculate(fib.staging_id)
+        assert passing_executor.codes[-1] == fib.merged_code
+        promoted = pipeline.promote(fib.staging_id)
+        with open(promoted.saved_file_path, encoding='utf-8') as f:
+            assert fib.merged_code in f.read()
+
+    def test_hash_is_deterministic(self, pipeline):
+        promote(pipeline, MATHUTILS, 'mathutils')
+        a = pipeline.queue_snippet('i', 'go', FIB, 'fib', requires=['mathutils'])
+        b = pipeline.queue_snippet('i', 'go', FIB, 'fib2', requires=['mathutils'])
+        assert a.code_hash == b.code_hash
+
+    def test_missing_and_cyclic(self, pipeline):
+        with pytest.raises(MissingDependencyError):
+            pipeline.queue_snippet('i', 'go', FIB, 'fib', requires=['mathutils'])
+        promote(pipeline, MATHUTILS, 'mathutils', requires=None)
+        promote(pipeline, 'package main\n\nfunc Helper() int { return Square(2) }\n',
+                'helpers', requires=['mathutils'])
+        # A new mathutils that needs helpers would close the loop
+        with pytest.raises(CyclicDependencyError):
+            pipeline.queue_snippet('i', 'go', MATHUTILS, 'mathutils', requires=['helpers'])
+        with pytest.raises(DependencyError, match="not supported"):
+            pipeline.queue_snippet('a', 'brainfuck', '+', 'x', requires=['mathutils'])
+
+    def test_promoting_dependency_invalidates_dependents(self, pipeline):
+        promote(pipeline, MATHUTILS, 'mathutils')
+        fib = pipeline.queue_snippet('i', 'go', FIB, 'fib', requires=['mathutils'])
+        pipeline.speculate(fib.staging_id)
+
+        util_v2 = promote(pipeline, MATHUTILS + '\nfunc Cube(n int) int { return n * n * n }\n',
+                          'mathutils')
+        with pytest.raises(StaleDependencyError, match=util_v2.staging_id):
+            pipeline.promote(fib.staging_id)
+        assert pipeline.get_snippet(fib.staging_id).phase == StagingPhase.PASSED
+
+        restaged = pipeline.queue_snippet('i', 'go', FIB, 'fib', requires=['mathutils'])
+        assert restaged.code_hash != fib.code_hash
+        pipeline.speculate(restaged.staging_id)
+        assert pipeline.promote(restaged.staging_id).phase == StagingPhase.PROMOTED
+
+
+@pytest.mark.skipif(shutil.which('go') is None, reason='go toolchain not installed')
+def test_go_end_to_end(make_pipeline):
+    from visual_editor_core.execution_engine import GoExecutor
+    pipeline = make_pipeline({'go': GoExecutor(execution_timeout=60)})
+    promote(pipeline, MATHUTILS, 'mathutils')
+    fib = pipeline.queue_snippet('i', 'go', FIB, 'fib', requires=['mathutils'])
+    fib = pipeline.speculate(fib.staging_id)
+    assert fib.phase == StagingPhase.PASSED, fib.spec_error
+    assert fib.spec_output.strip() == '49 1'
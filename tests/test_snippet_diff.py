@@ -0,0 +1,154 @@
+"""
+Test suite for label version history and source diffs.
+
+Tests cover:
+  - History lists every promotion of a label in order, with source bytes
+  - Rolled-back versions are flagged; other labels / slots excluded
+  - Unified patch and structured hunks for consecutive versions
+  - Version selection and error cases
+  - diff_against_production(): the staged source against the live version,
+    semantic equivalence, is_new without one, refused once promoted
+"""
+
+import pytest
+
+from visual_editor_core.snippet_staging import PhaseError
+from visual_editor_core.snippet_diff import (
+    VersionRecord, VersionDiffError, diff, parse_hunks, preview,
+)
+
+
+V1 = 'def fib(n):\n    return n\n\nx = fib(1)\n'
+V2 = 'def fib(n):\n    return n if n < 2 else n * 2\n\nx = fib(1)\n'
+V3 = V2 + 'y = fib(10)'
+
+
+@pytest.fixture
+def pipeline(make_pipeline):
+    return make_pipeline({})
+
+
+def _record(version, source):
+    return VersionRecord(version=version, staging_id=f'stg-{version}', slot='a',
+                         label='Fib', address='a1', code_hash='0' * 64,
+                         promoted_at=float(version),
+                         source=source.encode() if source is not None else None)
+
+
+class TestHistory:
+
+    def test_versions_in_promotion_order(self, pipeline):
+        promoted = [pipeline.run_full_pipeline('a', 'python', code, 'Fibonacci')
+                    for code in (V1, V2, V3)]
+        pipeline.run_full_pipeline('a', 'python', 'z = 0', 'Other')
+
+        versions = pipeline.get_version_history('Fibonacci', 'a')
+        assert [v.staging_id for v in versions] == [s.staging_id for s in promoted]
+        assert [v.version for v in versions] == [1, 2, 3]
+        assert versions[1].source == V2.encode()
+        assert pipeline.get_version_history('Fibonacci', 'i') == []
+
+    def test_rolled_back_versions_flagged(self, pipeline):
+        pipeline.run_full_pipeline('a', 'python', V1, 'Fibonacci')
+        bad = pipeline.run_full_pipeline('a', 'python', V2, 'Fibonacci')
+        pipeline.rollback(bad.staging_id, 'broken')
+        flags = [v.rolled_back for v in pipeline.get_version_history('Fibonacci', 'a')]
+        assert flags == [False, True]
+
+
+class TestDiff:
+
+    def test_consecutive_versions(self, pipeline):
+        for code in (V1, V2):
+            pipeline.run_full_pipeline('a', 'python', code, 'Fibonacci')
+        result = pipeline.diff_versions('Fibonacci', 'a')
+
+        assert (result.old.version, result.new.version) == (1, 2)
+        assert result.patch.startswith(b'--- Fibonacci@v1')
+        [hunk] = result.hunks
+        assert (hunk.old_start, hunk.new_start) == (1, 1)
+        assert [(l.op, l.text.strip()) for l in hunk.lines if l.op != ' '] == [
+            ('-', 'return n'),
+            ('+', 'return n if n < 2 else n * 2'),
+        ]
+        assert (result.added, result.removed) == (1, 1)
+
+    def test_missing_trailing_newline_marked(self):
+        result = diff(_record(1, V2), _record(2, V3))
+        assert b'\\ No newline at end of file' in result.patch
+        assert result.added == 1 and result.removed == 0
+
+    def test_identical_sources(self):
+        result = diff(_record(1, V1), _record(2, V1))
+        assert result.identical and result.patch == b''
+
+    def test_missing_source_is_an_error(self):
+        with pytest.raises(VersionDiffError):
+            diff(_record(1, V1), _record(2, None))
+
+    def test_version_selection(self, pipeline):
+        for code in (V1, V2, V3):
+            pipeline.run_full_pipeline('a', 'python', code, 'Fibonacci')
+        result = pipeline.diff_versions('Fibonacci', 'a', old_version=1, new_version=3)
+        assert (result.old.version, result.new.version) == (1, 3)
+        with pytest.raises(ValueError):
+            pipeline.diff_versions('Fibonacci', 'a', old_version=0, new_version=3)
+
+    def test_single_version_cannot_diff(self, pipeline):
+        pipeline.run_full_pipeline('a', 'python', V1, 'Fibonacci')
+        with pytest.raises(ValueError):
+            pipeline.diff_versions('Fibonacci', 'a')
+
+
+def test_parse_hunks_single_line_ranges():
+    [hunk] = parse_hunks('--- a\n+++ b\n@@ -3 +3,2 @@\n-x\n+y\n+z\n')
+    assert (hunk.old_lines, hunk.new_lines) == (1, 2)
+    assert [l.op for l in hunk.lines] == ['-', '+', '+']
+
+
+class TestDiffAgainstProduction:
+
+    def test_against_live_version(self, pipeline):
+        live = pipeline.run_full_pipeline('a', 'python', V1, 'Fibonacci')
+        staged = pipeline.queue_snippet('a', 'python', V2, 'Fibonacci')
+        result = pipeline.diff_against_production(staged.staging_id)
+
+        assert not result.is_new and result.production_id == live.staging_id
+        assert result.production_hash == live.code_hash
+        assert (result.lines_added, result.lines_removed) == (1, 1)
+        assert result.unified_diff.startswith(f'--- Fibonacci (production {live.staging_id})')
+        assert not result.is_semantically_equivalent
+        assert result.to_dict()['equivalence'] == {'equivalent': False, 'method': 'python_ast',
+                                                   'error': ''}
+
+    def test_layout_only_change(self, pipeline):
+        pipeline.run_full_pipeline('a', 'python', V1, 'Fibonacci')
+        reformatted = V1.replace('x = fib(1)', 'x = fib( 1 )  # first')
+        staged = pipeline.queue_snippet('a', 'python', reformatted, 'Fibonacci')
+        result = pipeline.diff_against_production(staged.staging_id)
+        assert result.lines_added == 1 and result.is_semantically_equivalent
+
+    def test_new_label(self, pipeline):
+        pipeline.run_full_pipeline('b', 'python', V1, 'Fibonacci')     # Other slot
+        staged = pipeline.queue_snippet('a', 'python', V1, 'Fibonacci')
+        result = pipeline.diff_against_production(staged.staging_id)
+        assert result.is_new and result.production_id == ''
+        assert result.lines_added == 4 and result.lines_removed == 0
+        assert result.unified_diff.startswith('--- /dev/null')
+        assert not result.is_semantically_equivalent and result.equivalence is None
+
+    def test_refused(self, pipeline):
+        live = pipeline.run_full_pipeline('a', 'python', V1, 'Fibonacci')
+        with pytest.raises(PhaseError):
+            pipeline.diff_against_production(live.staging_id)
+        with pytest.raises(ValueError):
+            pipeline.diff_against_production('stg-missing')
+        staged = pipeline.queue_snippet('a', 'python', V2, 'Fibonacci', namespace='team-b')
+        with pytest.raises(ValueError):
+            pipeline.diff_against_production(staged.staging_id, namespace='default')
+        # team-b has no live Fibonacci of its own
+        assert pipeline.diff_against_production(staged.staging_id, namespace='team-b').is_new
+
+    def test_identical(self):
+        result = preview('stg-1', 'a', 'Fib', V1, 'stg-0', '0' * 64, V1)
+        assert result.unified_diff == '' and result.hunks == []
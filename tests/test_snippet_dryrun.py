@@ -0,0 +1,162 @@
+"""
+Test suite for dry-run promotion.
+
+Tests cover:
+  - A clean snippet: every gate passes or is skipped, would_succeed
+  - Nothing is committed: phase, spec fields, files, audit trail, webhooks
+  - Failing gates are all reported: speculation, lint, capacity, label conflict
+  - Parameter violations skip the run and the lint gate
+  - Engine.promote(PromoteOptions(dry_run=True)) returns the report
+  - Concurrent dry runs don't block or break a real promotion
+"""
+
+import os
+import threading
+import pytest
+
+from visual_editor_core.execution_engine import ExecutionResult
+from visual_editor_core.snippet_staging import StagingPhase
+from visual_editor_core.snippet_capacity import SlotConfig
+from visual_editor_core.snippet_lint import LintDiagnostic, LintResult, SnippetLinter
+from visual_editor_core.snippet_webhooks import WebhookDispatcher
+from visual_editor_core.snippet_engines import EngineRegistry, GoEngine, PromoteOptions
+from visual_editor_core.snippet_dryrun import (
+    GateStatus, GATE_CAPACITY, GATE_LABEL, GATE_LINT, GATE_ORDER, GATE_PARAMETERS,
+    GATE_PHASE, GATE_SPECULATION,
+)
+
+
+class ScriptedExecutor:
+    """Fails any program containing `boom`."""
+
+    def __init__(self):
+        self.runs = 0
+
+    def execute(self, code):
+        self.runs += 1
+        if 'boom' in code:
+            return ExecutionResult(success=False, output='', error='panic: boom',
+                                   execution_time=0.01)
+        return ExecutionResult(success=True, output='ok', error=None, execution_time=0.01)
+
+
+class MarkerLinter(SnippetLinter):
+    def lint(self, code):
+        diags = [LintDiagnostic('printf', n, 1, 'flagged')
+                 for n, line in enumerate(code.splitlines(), 1) if 'lint:' in line]
+        return LintResult(passed=not diags, diagnostics=diags)
+
+
+class NoopTransport:
+    def __call__(self, url, body, headers, timeout):
+        return 200
+
+
+@pytest.fixture
+def executor():
+    return ScriptedExecutor()
+
+
+@pytest.fixture
+def pipeline(tmp_path, make_pipeline, executor):
+    engines = EngineRegistry()
+    engines.register('go', GoEngine())
+    return make_pipeline({'go': executor}, linters={'go': MarkerLinter()}, engines=engines,
+                         webhooks=WebhookDispatcher(str(tmp_path / 'hooks.jsonl'),
+                                                    transport=NoopTransport()))
+
+
+def statuses(report):
+    return {g.gate: g.status for g in report.gates}
+
+
+class TestDryRun:
+    def test_clean_snippet(self, pipeline, executor, tmp_path):
+        pipeline.add_webhook_target('http://hooks.example/a', 's')
+        snippet = pipeline.queue_snippet('i', 'go', 'package main', 'svc')
+        audit_before = len(pipeline.get_audit_trail())
+
+        report = pipeline.dry_run_promote(snippet.staging_id)
+
+        assert report.would_succeed and not report.failed()
+        assert [g.gate for g in report.gates] == list(GATE_ORDER)
+        assert report.gate(GATE_SPECULATION).data['spec_result'] == 'PASS'
+        assert report.gate(GATE_LINT).status == GateStatus.PASSED
+        assert report.to_dict()['would_succeed'] is True
+        # Nothing committed
+        assert snippet.phase == StagingPhase.QUEUED and snippet.spec_completed_at == 0.0
+        assert len(pipeline.get_audit_trail()) == audit_before
+        assert pipeline.webhooks.pending() == []
+        assert [f for _, _, files in os.walk(tmp_path / 'snippets') for f in files] == []
+        assert executor.runs == 1
+
+    def test_reports_every_failure(self, pipeline):
+        first = pipeline.queue_snippet('i', 'go', 'package main', 'live', label_policy='reject')
+        bad = pipeline.queue_snippet('i', 'go', 'package main // boom lint:', 'live',
+                                     label_policy='reject')
+        pipeline.speculate(first.staging_id)
+        pipeline.promote(first.staging_id)
+        pipeline.configure_slot('i', SlotConfig(max_snippets=1))
+
+        report = pipeline.dry_run_promote(bad.staging_id)
+        got = statuses(report)
+        assert not report.would_succeed
+        assert {g.gate for g in report.failed()} == {GATE_LABEL, GATE_CAPACITY,
+                                                     GATE_SPECULATION, GATE_LINT}
+        assert got[GATE_PHASE] == GateStatus.PASSED
+        assert 'boom' in report.gate(GATE_SPECULATION).detail
+        assert bad.phase == StagingPhase.QUEUED
+
+        # Like promote(), only staged snippets can be dry-run
+        with pytest.raises(ValueError):
+            pipeline.dry_run_promote(first.staging_id)
+
+    def test_skip_lint_and_parameters(self, pipeline, executor):
+        code = 'package main\n\nfunc main() {\n\tprintln(n) // lint:\n}\n'
+        snippet = pipeline.queue_snippet('i', 'go', code, 'p',
+                                         parameters=[{'name': 'n', 'type': 'int',
+                                                      'required': True}])
+        report = pipeline.dry_run_promote(snippet.staging_id)
+        assert statuses(report)[GATE_PARAMETERS] == GateStatus.FAILED
+        assert report.gate(GATE_SPECULATION).status == GateStatus.SKIPPED
+        assert executor.runs == 0
+
+        report = pipeline.dry_run_promote(snippet.staging_id, skip_lint=True,
+                                          arguments={'n': 3})
+        assert report.would_succeed
+        assert report.gate(GATE_PARAMETERS).data == {'arguments': {'n': 3}}
+        assert report.gate(GATE_LINT).detail == 'skip_lint override'
+
+    def test_scoped(self, pipeline):
+        snippet = pipeline.queue_snippet('i', 'go', 'package main', namespace='team-a')
+        with pytest.raises(ValueError):
+            pipeline.dry_run_promote(snippet.staging_id, namespace='team-b')
+
+    def test_engine_promote_option(self, pipeline):
+        snippet = pipeline.queue_snippet('i', 'go', 'package main', 'svc')
+        engine = pipeline.get_engine('go')
+        report = engine.promote(snippet.staging_id, PromoteOptions(dry_run=True))
+        assert report.would_succeed and snippet.phase == StagingPhase.QUEUED
+
+
+def test_concurrent_with_promotion(pipeline):
+    snippet = pipeline.queue_snippet('i', 'go', 'package main', 'svc')
+    pipeline.speculate(snippet.staging_id)
+    reports, errors = [], []
+
+    def dry():
+        try:
+            reports.append(pipeline.dry_run_promote(snippet.staging_id))
+        except Exception as exc:                         # pragma: no cover
+            errors.append(exc)
+
+    threads = [threading.Thread(target=dry) for _ in range(8)]
+    for t in threads:
+        t.start()
+    promoted = pipeline.promote(snippet.staging_id)
+    for t in threads:
+        t.join(10)
+    assert not errors and len(reports) == 8
+    assert promoted.phase == StagingPhase.PROMOTED
+    for report in reports:
+        assert report.gate(GATE_SPECULATION).status == GateStatus.PASSED
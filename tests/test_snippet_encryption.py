@@ -0,0 +1,201 @@
+"""
+Test suite for encrypting the stored source of sensitive slots.
+
+Tests cover:
+  - seal() / unseal(): the SEALED_MAGIC prefix; unsealed blobs refused
+  - validate_recipient() / validate_identity() / read_identities()
+  - SlotConfig recipient validation; configure_slot() refusing
+    encrypt_at_rest without a recipient or key provider
+  - Stores: a sealed put isn't verified, replaces a plain copy and is
+    never replaced by a plain one
+  - promote() onto a sealed slot: only ciphertext in the store, code_hash
+    of the plaintext, FILE_WRITTEN audited as sealed
+  - get_source(), version history and replay() decrypting; a payload no
+    provider opens (or that doesn't match its hash) raises EncryptionError
+  - Archived copies (ArchiveAction.MOVE) sealed
+  - AgeKeyProvider: RuntimeError without pyrage, round trip with it
+"""
+
+import pytest
+
+from visual_editor_core.snippet_archive import ArchivalPolicy
+from visual_editor_core.snippet_capacity import SlotConfig
+from visual_editor_core.snippet_store import (
+    FileSnippetStore, MemorySnippetStore, compute_code_hash,
+)
+from visual_editor_core.snippet_encryption import (
+    SEALED_MAGIC, AgeKeyProvider, EncryptionError, KeyProvider, is_sealed, pyrage,
+    read_identities, seal, unseal, validate_identity, validate_recipient,
+)
+
+
+RECIPIENT = 'age1' + 'q' * 58
+IDENTITY = 'AGE-SECRET-KEY-1' + 'Q' * 58
+SECRET = 'package main // token = "s3cr3t"\n'
+
+
+class XorProvider(KeyProvider):
+    """Stands in for age: XOR with a one-byte key."""
+
+    def __init__(self, key=0x5a):
+        self.key = key
+
+    def encrypt(self, plaintext):
+        return bytes([self.key]) + bytes(b ^ self.key for b in plaintext)
+
+    def decrypt(self, ciphertext):
+        if ciphertext[:1] != bytes([self.key]):
+            raise EncryptionError("Not sealed with this key")
+        return bytes(b ^ self.key for b in ciphertext[1:])
+
+
+def sealed_pipeline(make_pipeline, providers=None, **kwargs):
+    pipeline = make_pipeline(key_providers=providers if providers is not None
+                             else {'i': XorProvider()}, **kwargs)
+    if providers is None:
+        pipeline.configure_slot('i', SlotConfig(encrypt_at_rest=True))
+    return pipeline
+
+
+def promote(pipeline, code=SECRET, label='svc'):
+    snippet = pipeline.queue_snippet('i', 'go', code, label)
+    pipeline.speculate(snippet.staging_id)
+    return pipeline.promote(snippet.staging_id)
+
+
+def test_seal_roundtrip():
+    blob = seal(XorProvider(), b'hello')
+    assert is_sealed(blob) and blob.startswith(SEALED_MAGIC) and b'hello' not in blob
+    assert unseal(XorProvider(), blob) == b'hello'
+    with pytest.raises(EncryptionError, match='not sealed'):
+        unseal(XorProvider(), b'hello')
+    with pytest.raises(EncryptionError):
+        unseal(XorProvider(0x11), blob)
+
+
+class TestKeys:
+    def test_recipient(self):
+        assert validate_recipient(f' {RECIPIENT} ') == RECIPIENT
+        for bad in ('', 'age1short', RECIPIENT.upper(), 'age1' + 'b' * 58):   # 'b' isn't bech32
+            with pytest.raises(ValueError, match='Invalid age recipient'):
+                validate_recipient(bad)
+
+    def test_identity(self):
+        assert validate_identity(IDENTITY) == IDENTITY
+        with pytest.raises(ValueError, match='Invalid age identity'):
+            validate_identity(RECIPIENT)
+
+    def test_read_identities(self, tmp_path):
+        path = tmp_path / 'keys.txt'
+        path.write_text(f'# created: 2026-01-01\n# public key: {RECIPIENT}\n{IDENTITY}\n\n')
+        assert read_identities(str(path)) == [IDENTITY]
+
+    def test_slot_config(self):
+        assert SlotConfig(encrypt_at_rest=True, recipient=f'{RECIPIENT}\n').recipient == RECIPIENT
+        with pytest.raises(ValueError):
+            SlotConfig(encrypt_at_rest=True, recipient='age1nope')
+
+    def test_configure_slot_needs_a_key(self, make_pipeline):
+        pipeline = sealed_pipeline(make_pipeline)
+        with pytest.raises(ValueError, match='no recipient'):
+            pipeline.configure_slot('j', SlotConfig(encrypt_at_rest=True))
+        pipeline.configure_slot('j', SlotConfig(encrypt_at_rest=True, recipient=RECIPIENT))
+
+
+@pytest.mark.parametrize('store_type', ['memory', 'file'])
+def test_store_sealed_put(tmp_path, store_type):
+    store = MemorySnippetStore() if store_type == 'memory' else FileSnippetStore(str(tmp_path))
+    body = SECRET.encode('utf-8')
+    code_hash = compute_code_hash(body)
+    sealed = seal(XorProvider(), body)
+
+    assert store.put(code_hash, body)
+    assert store.put(code_hash, sealed, sealed=True)             # Replaces the plain copy
+    assert store.get(code_hash) == sealed
+    assert not store.put(code_hash, body)                        # The sealed copy is kept
+    assert not store.put(code_hash, seal(XorProvider(0x11), body), sealed=True)
+    assert store.get(code_hash) == sealed
+
+
+class TestPromote:
+    def test_only_ciphertext_stored(self, make_pipeline):
+        pipeline = sealed_pipeline(make_pipeline)
+        snippet = promote(pipeline)
+        assert snippet.code_hash == compute_code_hash(SECRET.encode('utf-8'))
+        with open(snippet.saved_file_path, 'rb') as f:
+            stored = f.read()
+        assert is_sealed(stored) and b's3cr3t' not in stored
+        entry = [e for e in pipeline.get_audit_trail(snippet.staging_id)
+                 if e['event'] == 'file_written'][0]
+        assert entry['data']['sealed'] is True and entry['data']['size'] == len(SECRET)
+
+    def test_unsealed_slot(self, make_pipeline):
+        pipeline = sealed_pipeline(make_pipeline)
+        pipeline.configure_slot('i', None)
+        snippet = promote(pipeline)
+        with open(snippet.saved_file_path, 'rb') as f:
+            assert f.read() == SECRET.encode('utf-8')
+        assert pipeline.seal_source('i', SECRET) is None
+
+    def test_reads_decrypt(self, make_pipeline):
+        pipeline = sealed_pipeline(make_pipeline)
+        v1 = promote(pipeline)
+        v2 = promote(pipeline, SECRET.replace('s3cr3t', 'rotated'))
+        assert pipeline.get_source(v1.code_hash) == SECRET
+        assert [v.source for v in pipeline.get_version_history('svc', 'i')] == [
+            SECRET.encode('utf-8'), v2.program.encode('utf-8')]
+        pipeline.replay(v1.staging_id)
+        assert pipeline._executors['go'].codes[-1] == SECRET
+
+    def test_no_key_opens_it(self, make_pipeline):
+        pipeline = sealed_pipeline(make_pipeline)
+        snippet = promote(pipeline)
+        pipeline._key_providers = {'i': XorProvider(0x11)}
+        with pytest.raises(EncryptionError, match='Cannot decrypt'):
+            pipeline.get_source(snippet.code_hash)
+
+    def test_hash_checked(self, make_pipeline):
+        pipeline = sealed_pipeline(make_pipeline)
+        blob = pipeline.seal_source('i', SECRET)
+        assert pipeline.open_sealed(blob, 'i') == SECRET.encode('utf-8')
+        assert pipeline.open_sealed(blob, 'i', compute_code_hash(SECRET.encode('utf-8')))
+        with pytest.raises(EncryptionError):
+            pipeline.open_sealed(blob, 'i', compute_code_hash(b'something else'))
+        assert pipeline.open_sealed(b'plain', 'i') == b'plain'
+
+    def test_archive_move_sealed(self, tmp_path, make_pipeline):
+        pipeline = sealed_pipeline(make_pipeline)
+        old, _ = promote(pipeline), promote(pipeline, SECRET + '// v2\n')
+        pipeline.apply_archival_policy(ArchivalPolicy(max_versions_per_label=1,
+                                                      cold_storage_path=str(tmp_path / 'cold')))
+        assert old.saved_file_path.endswith('.sealed')
+        with open(old.saved_file_path, 'rb') as f:
+            blob = f.read()
+        assert b's3cr3t' not in blob
+        assert pipeline.open_sealed(blob, 'i').decode('utf-8').endswith(SECRET)
+
+
+class TestAgeKeyProvider:
+    def test_validates_keys(self):
+        with pytest.raises(ValueError):
+            AgeKeyProvider(recipient='age1nope')
+        assert AgeKeyProvider(identities=[IDENTITY]).identities == [IDENTITY]
+
+    @pytest.mark.skipif(pyrage is not None, reason='pyrage installed')
+    def test_needs_pyrage(self):
+        with pytest.raises(RuntimeError, match='pyrage is not installed'):
+            AgeKeyProvider(RECIPIENT).encrypt(b'x')
+
+    @pytest.mark.skipif(pyrage is None, reason='pyrage not installed')
+    def test_roundtrip(self, make_pipeline):
+        identity = pyrage.x25519.Identity.generate()
+        recipient = str(identity.to_public())
+        provider = AgeKeyProvider(recipient, [str(identity)])
+        assert provider.decrypt(provider.encrypt(b'hello')) == b'hello'
+
+        pipeline = sealed_pipeline(make_pipeline, providers={}, age_identities=[str(identity)])
+        pipeline.configure_slot('i', SlotConfig(encrypt_at_rest=True, recipient=recipient))
+        snippet = promote(pipeline)
+        with open(snippet.saved_file_path, 'rb') as f:
+            assert b's3cr3t' not in f.read()
+        assert pipeline.get_source(snippet.code_hash) == SECRET
@@ -0,0 +1,121 @@
+"""
+Test suite for the language-engine registry.
+
+Tests cover:
+  - Registration, duplicate rejection, lookup by language
+  - The built-in Python engine (isolation, params, validate, timeout)
+  - Pipeline dispatch to a third-party engine for a snippet's language
+  - GoEngine parameter binding (skipped without `go`)
+"""
+
+import time
+import shutil
+import pytest
+
+from visual_editor_core.snippet_staging import StagingPhase
+from visual_editor_core.snippet_engines import (
+    Engine, EngineRegistry, EngineRegistrationError, PythonEngine, GoEngine,
+    RunResult, Diagnostic, StageOptions, EngineExecutor, DEFAULT_ENGINES,
+)
+
+
+class UpperEngine(Engine):
+    """Third-party engine: 'runs' code by upper-casing it; rejects 'bad'."""
+
+    engine_letter = 'n'                      # borrows the bash row
+    file_extension = '.up'
+
+    def __init__(self):
+        self.runs = []
+
+    def run(self, src, params=None, timeout=None):
+        self.runs.append(src)
+        return RunResult(success=True, output=src.decode().upper(), execution_time=0.01)
+
+    def validate(self, src):
+        return [Diagnostic('upper', 1, 1, 'bad word')] if b'bad' in src else []
+
+
+@pytest.fixture
+def engines():
+    registry = EngineRegistry()
+    registry.register('python', PythonEngine())
+    registry.register('upper', UpperEngine())
+    return registry
+
+
+@pytest.fixture
+def pipeline(make_pipeline, engines):
+    return make_pipeline({}, engines=engines)
+
+
+class TestRegistry:
+
+    def test_duplicate_registration_is_an_error(self, engines):
+        with pytest.raises(EngineRegistrationError):
+            engines.register('Upper', UpperEngine())
+
+    def test_rejects_non_engines(self, engines):
+        with pytest.raises(EngineRegistrationError):
+            engines.register('thing', object())
+
+    def test_lookup_is_case_insensitive(self, engines):
+        assert isinstance(engines.get(' UPPER '), UpperEngine)
+        assert engines.get('cobol') is None
+        assert engines.names() == ['python', 'upper']
+
+    def test_defaults_ship_python_and_go(self):
+        assert {'python', 'go'} <= set(DEFAULT_ENGINES.names())
+
+
+class TestPythonEngine:
+
+    def test_params_and_variables(self):
+        result = PythonEngine().run(b'y = x * 2\nprint(y)', {'x': 21})
+        assert result.success and result.output.strip() == '42'
+        assert result.variables == {'y': 42}
+
+    def test_validate_reports_syntax_errors(self):
+        [diag] = PythonEngine().validate(b'def f(:\n  pass')
+        assert diag.analyzer == 'syntax' and diag.line == 1
+
+    def test_timeout_abandons_run(self):
+        result = PythonEngine().run(b'import time\ntime.sleep(0.5)', timeout=0.05)
+        assert result.timed_out and not result.success
+        time.sleep(0.6)   # let the abandoned run restore sys.stdout
+
+
+class TestPipelineDispatch:
+
+    def test_plugin_language_staged_and_promoted(self, pipeline, engines):
+        upper = engines.get('upper')
+        staging_id = upper.stage(b'hello', StageOptions(label='Shout'))
+        snippet = pipeline.get_snippet(staging_id)
+        assert snippet.engine_letter == 'n' and snippet.reserved_address.startswith('n')
+
+        pipeline.speculate(staging_id)
+        assert snippet.spec_output == 'HELLO'
+        promoted = pipeline.promote(staging_id)
+        assert promoted.phase == StagingPhase.PROMOTED
+        assert pipeline.get_source(promoted.code_hash) == 'hello'
+
+    def test_python_speculation_goes_through_engine(self, pipeline):
+        snippet = pipeline.run_full_pipeline('a', 'python', 'z = 5', 'Calc')
+        assert snippet.phase == StagingPhase.PROMOTED
+        assert snippet.spec_variables == {'z': 5}
+
+    def test_unknown_language_still_rejected(self, pipeline):
+        with pytest.raises(ValueError):
+            pipeline.queue_snippet('', 'cobol', 'DISPLAY 1', 'x')
+
+    def test_executor_adapter(self, engines):
+        result = EngineExecutor(engines.get('upper')).execute('abc')
+        assert result.success and result.output == 'ABC'
+
+
+@pytest.mark.skipif(shutil.which('go') is None, reason='go toolchain not installed')
+def test_go_engine_binds_params():
+    src = b'package main\n\nimport "fmt"\n\nfunc main() { fmt.Println(n + 1, who) }\n'
+    result = GoEngine(default_timeout=120).run(src, {'n': 41, 'who': 'go'})
+    assert result.success, result.error
+    assert result.output.split() == ['42', 'go']
@@ -0,0 +1,177 @@
+"""
+Test suite for per-snippet environment injection.
+
+Tests cover:
+  - validate_env(): accepted names, malformed and reserved names, bad values
+  - env_hash() is order-independent; child_environ() drops host variables
+  - queue_snippet(env=…): Go only, env_hash recorded, values redacted in to_dict()
+  - The same source with different envs: distinct breaker keys and index records
+  - speculate() and slot_env() hand the env to the executor
+  - Engine.run_with(): engines without supports_env refuse an env
+  - State checkpoints: env names and env_hash only; restore_env() reads the
+    values back from SPOKEDPY_SNIPPET_ENV_<NAME> and checks the hash
+  - GoEngine end to end (the program reads the injected variable)
+"""
+
+import shutil
+import pytest
+
+from visual_editor_core.execution_engine import ExecutionResult
+from visual_editor_core.snippet_sqlite import SQLiteSnippetIndex
+from visual_editor_core.snippet_query import SnippetFilter
+from visual_editor_core.snippet_engines import GoEngine, PythonEngine, RunOptions
+from visual_editor_core.snippet_env import (
+    EnvSpecError, RESERVED_ENV_NAMES, child_environ, env_hash, validate_env,
+)
+from web_interface.state_persistence import build_promoted_snapshots, restore_env
+
+
+class EnvRecordingExecutor:
+    def __init__(self):
+        self.envs = []
+
+    def execute(self, code, env=None):
+        self.envs.append(env)
+        return ExecutionResult(success=True, output='', error=None, execution_time=0.01)
+
+
+@pytest.fixture
+def executor():
+    return EnvRecordingExecutor()
+
+
+@pytest.fixture
+def pipeline(tmp_path, make_pipeline, executor):
+    return make_pipeline({'go': executor},
+                         snippet_index=SQLiteSnippetIndex(str(tmp_path / 'idx.db')))
+
+
+class TestValidateEnv:
+    def test_accepts(self):
+        assert validate_env({'B_2': 'x', '_A': ''}) == {'_A': '', 'B_2': 'x'}
+        assert validate_env(None) == {}
+
+    @pytest.mark.parametrize('name', ['lower', '2LEAD', 'HAS-DASH', 'A B', ''])
+    def test_bad_names(self, name):
+        with pytest.raises(EnvSpecError, match='Invalid environment variable name'):
+            validate_env({name: 'x'})
+
+    @pytest.mark.parametrize('name', ['GOPATH', 'GOPROXY', 'PATH', 'LD_PRELOAD'])
+    def test_reserved(self, name):
+        assert name in RESERVED_ENV_NAMES
+        with pytest.raises(EnvSpecError, match='reserved'):
+            validate_env({name: 'x'})
+
+    def test_bad_values(self):
+        with pytest.raises(EnvSpecError, match='must be a string'):
+            validate_env({'PORT': 8080})
+        with pytest.raises(EnvSpecError, match='NUL'):
+            validate_env({'KEY': 'a\x00b'})
+
+    def test_hash_and_child_environ(self):
+        assert env_hash({'A': '1', 'B': '2'}) == env_hash({'B': '2', 'A': '1'})
+        assert env_hash({'A': '1'}) != env_hash({'A': '2'})
+        assert env_hash({}) == ''
+        child = child_environ({'API_KEY': 'k'}, host={'PATH': '/bin', 'AWS_SECRET': 's'})
+        assert child == {'PATH': '/bin', 'API_KEY': 'k'}
+
+
+class TestQueueWithEnv:
+    def test_go_only(self, pipeline):
+        with pytest.raises(EnvSpecError, match="not supported for 'python'"):
+            pipeline.queue_snippet('a', 'python', 'x = 1', env={'FLAG': 'on'})
+        with pytest.raises(EnvSpecError):
+            pipeline.queue_snippet('i', 'go', 'package main', env={'GOFLAGS': '-x'})
+
+    def test_recorded_and_redacted(self, pipeline):
+        snippet = pipeline.queue_snippet('i', 'go', 'package main', 'svc',
+                                         env={'API_KEY': 'hunter2'})
+        assert snippet.env_hash == env_hash({'API_KEY': 'hunter2'})
+        d = snippet.to_dict()
+        assert d['env'] == ['API_KEY']
+        assert 'hunter2' not in repr(d)
+
+    def test_distinct_executions(self, pipeline):
+        a = pipeline.speculate(pipeline.queue_snippet('i', 'go', 'package main', 'svc',
+                                                      env={'MODE': 'a'}).staging_id)
+        b = pipeline.speculate(pipeline.queue_snippet('i', 'go', 'package main', 'svc2',
+                                                      env={'MODE': 'b'}).staging_id)
+        plain = pipeline.queue_snippet('i', 'go', 'package main', 'svc3')
+        assert a.code_hash == b.code_hash == plain.code_hash
+        keys = {pipeline._breaker_key(s) for s in (a, b, plain)}
+        assert len(keys) == 3
+        records = {r.staging_id: r.env_hash for r in pipeline.query(SnippetFilter()).snippets}
+        assert records[a.staging_id] == a.env_hash != records[b.staging_id]
+        assert records[plain.staging_id] == ''
+
+
+class TestCheckpoint:
+    def snapshot(self, pipeline):
+        snippet = pipeline.run_full_pipeline('i', 'go', 'package main', 'svc',
+                                             env={'API_KEY': 'hunter2', 'MODE': 'a'})
+        snap, = build_promoted_snapshots(pipeline, {}, {})
+        assert snap['staging_id'] == snippet.staging_id
+        return snap
+
+    def test_values_not_persisted(self, pipeline):
+        snap = self.snapshot(pipeline)
+        assert snap['env_names'] == ['API_KEY', 'MODE']
+        assert snap['env_hash'] == env_hash({'API_KEY': 'hunter2', 'MODE': 'a'})
+        assert 'env' not in snap and 'hunter2' not in repr(snap)
+
+    def test_restore(self, pipeline):
+        snap = self.snapshot(pipeline)
+        assert restore_env(snap, {'SPOKEDPY_SNIPPET_ENV_API_KEY': 'hunter2',
+                                  'SPOKEDPY_SNIPPET_ENV_MODE': 'a', 'MODE': 'b'}) \
+            == {'API_KEY': 'hunter2', 'MODE': 'a'}
+
+    def test_restore_refused(self, pipeline):
+        snap = self.snapshot(pipeline)
+        with pytest.raises(ValueError, match='set SPOKEDPY_SNIPPET_ENV_MODE$'):
+            restore_env(snap, {'SPOKEDPY_SNIPPET_ENV_API_KEY': 'hunter2', 'MODE': 'a'})
+        with pytest.raises(ValueError, match='do not match'):
+            restore_env(snap, {'SPOKEDPY_SNIPPET_ENV_API_KEY': 'rotated',
+                               'SPOKEDPY_SNIPPET_ENV_MODE': 'a'})
+
+    def test_no_env(self):
+        assert restore_env({'env_names': [], 'env_hash': ''}, {}) == {}
+        assert restore_env({'env': {'MODE': 'a'}}, {}) == {'MODE': 'a'}
+
+
+class TestExecution:
+    def test_speculate_passes_env(self, pipeline, executor):
+        pipeline.speculate(pipeline.queue_snippet('i', 'go', 'package main', 'svc',
+                                                  env={'MODE': 'fast'}).staging_id)
+        pipeline.speculate(pipeline.queue_snippet('i', 'go', 'package main // b', 'b').staging_id)
+        assert executor.envs == [{'MODE': 'fast'}, None]
+
+    def test_slot_env(self, pipeline):
+        snippet = pipeline.speculate(pipeline.queue_snippet('i', 'go', 'package main', 'svc',
+                                                            env={'MODE': 'fast'}).staging_id)
+        promoted = pipeline.promote(snippet.staging_id)
+        assert pipeline.slot_env(promoted.registry_slot_id) == {'MODE': 'fast'}
+        assert pipeline.slot_env('nri99') == {}
+
+    def test_engine_without_env_support(self):
+        with pytest.raises(EnvSpecError, match='does not support'):
+            PythonEngine().run_with(b'x = 1', RunOptions(env={'FLAG': 'on'}))
+        assert PythonEngine().run_with(b'x = 1').success
+
+
+@pytest.mark.skipif(shutil.which('go') is None, reason='go toolchain not installed')
+def test_go_engine_reads_env(monkeypatch):
+    monkeypatch.setenv('SPOKEDPY_HOST_ONLY', 'leaked')
+    src = b'''package main
+
+import (
+\t"fmt"
+\t"os"
+)
+
+func main() {
+\tfmt.Printf("%s|%s", os.Getenv("GREETING"), os.Getenv("SPOKEDPY_HOST_ONLY"))
+}
+'''
+    result = GoEngine().run_with(src, RunOptions(env={'GREETING': 'hello'}, timeout=60))
+    assert result.success, result.error
+    assert result.output.strip() == 'hello|'
@@ -0,0 +1,84 @@
+"""
+Test suite for semantic equivalence of two snippet sources.
+
+Tests cover:
+  - Python: layout and comments ignored, any real change detected,
+    syntax errors reported
+  - Go (with a toolchain): gofmt-style layout, comments and import
+    grouping ignored; changed literals, identifiers and statements detected;
+    parse errors reported
+  - Other languages and Go without a toolchain compare as text
+"""
+
+import shutil
+import pytest
+
+from visual_editor_core.snippet_equivalence import GO_AST, PYTHON_AST, TEXT, equivalent
+
+
+GO = '''package main
+
+import "fmt"
+
+// Fib is the naive recursive Fibonacci.
+func Fib(n int) int {
+	if n < 2 {
+		return n
+	}
+	return Fib(n-1) + Fib(n-2)
+}
+
+func main() {
+	fmt.Println(Fib(10))
+}
+'''
+
+GO_RELAID = '''package main
+import ("fmt")
+func Fib(n int) int { if n < 2 { return n } /* base case */
+    return Fib(n - 1) + Fib(n - 2) }
+func main() { fmt.Println(Fib(10)) }
+'''
+
+
+class TestPython:
+    def test_layout_and_comments(self):
+        result = equivalent('x=[1,2]  # two\nprint( x )\n', 'x = [1, 2]\n\nprint(x)\n', 'python')
+        assert result.equivalent and result.method == PYTHON_AST
+
+    def test_changes(self):
+        assert not equivalent('x = 1\n', 'x = 2\n', 'python').equivalent
+        assert not equivalent('x = 1\n', 'y = 1\n', 'python').equivalent
+
+    def test_syntax_error(self):
+        result = equivalent('x = 1\n', 'x = (\n', 'python')
+        assert not result.equivalent and result.error.startswith('line 1:')
+
+
+@pytest.mark.skipif(shutil.which('go') is None, reason='go toolchain not installed')
+class TestGo:
+    def test_layout_and_comments(self):
+        result = equivalent(GO, GO_RELAID, 'go')
+        assert result.equivalent and result.method == GO_AST and result.error == ''
+
+    @pytest.mark.parametrize('change', [('Fib(10)', 'Fib(11)'), ('n < 2', 'n <= 2'),
+                                        ('Fib(n-2)', 'Fib(n-1)'), ('fmt.Println', 'fmt.Print')])
+    def test_changes(self, change):
+        assert not equivalent(GO, GO.replace(*change), 'go').equivalent
+
+    def test_parse_error(self):
+        result = equivalent(GO, 'package main\n\nfunc {\n', 'go')
+        assert not result.equivalent and result.method == GO_AST
+        assert 'new.go:3' in result.error
+
+
+def test_go_without_toolchain(tmp_path):
+    result = equivalent(GO, GO_RELAID, 'go', go_path=str(tmp_path / 'no-go'))
+    assert result.method == TEXT and not result.equivalent
+    assert equivalent(GO, GO, 'go', go_path=str(tmp_path / 'no-go')).equivalent
+
+
+def test_other_languages_compare_text():
+    assert equivalent('a', 'a', 'rust').to_dict() == {'equivalent': True, 'method': TEXT,
+                                                      'error': ''}
+    assert not equivalent('a', 'a ', 'rust').equivalent
@@ -0,0 +1,99 @@
+"""
+Test suite for gofmt formatting on stage.
+
+Tests cover:
+  - parse_gofmt_errors() positions and messages
+  - dry_run_format() output, and FormatFailedError for invalid Go
+  - GoEngine(format_on_stage=True): whitespace-only edits share a code_hash
+  - Invalid Go is refused at queue time without reserving a slot
+  - format_on_stage=False and a missing gofmt leave the source untouched
+"""
+
+import shutil
+
+import pytest
+
+from visual_editor_core.snippet_staging import AuditEventType
+from visual_editor_core.snippet_engines import EngineRegistry, GoEngine
+from visual_editor_core.snippet_format import (
+    GoFormatter, FormatFailedError, FormatUnavailableError,
+    dry_run_format, parse_gofmt_errors,
+)
+
+
+needs_gofmt = pytest.mark.skipif(shutil.which('gofmt') is None, reason='gofmt not installed')
+
+UGLY = 'package main\nimport "fmt"\nfunc main() {\nfmt.Println( "hi" )\n}\n'
+PRETTY = 'package main\n\nimport "fmt"\n\nfunc main() {\n\tfmt.Println("hi")\n}\n'
+
+
+@pytest.fixture
+def engine():
+    return GoEngine(format_on_stage=True)
+
+
+@pytest.fixture
+def pipeline(make_pipeline, engine):
+    engines = EngineRegistry()
+    engines.register('go', engine)
+    return make_pipeline({}, engines=engines)
+
+
+class TestParseErrors:
+    def test_positions(self):
+        output = ('main.go:3:14: expected \'}\', found \'EOF\'\n'
+                  'main.go:5:1: expected declaration, found x\n')
+        diagnostics = parse_gofmt_errors(output)
+        assert [(d.line, d.column) for d in diagnostics] == [(3, 14), (5, 1)]
+        assert diagnostics[0].analyzer == 'gofmt'
+        assert diagnostics[1].message == 'expected declaration, found x'
+
+    def test_unrecognised_lines_ignored(self):
+        assert parse_gofmt_errors('something odd happened') == []
+
+
+@needs_gofmt
+class TestDryRunFormat:
+    def test_formats(self):
+        assert dry_run_format(UGLY.encode()) == PRETTY.encode()
+        assert dry_run_format(PRETTY) == PRETTY.encode()
+
+    def test_invalid_go(self):
+        with pytest.raises(FormatFailedError) as exc:
+            dry_run_format(b'package main\nfunc main() {\n')
+        assert 'not valid Go' in str(exc.value)
+        assert 'main.go:' in exc.value.cause
+        assert exc.value.diagnostics[0].line == 2
+
+
+@needs_gofmt
+class TestFormatOnStage:
+    def test_whitespace_edits_share_a_hash(self, pipeline):
+        a = pipeline.queue_snippet('i', 'go', UGLY)
+        b = pipeline.queue_snippet('i', 'go', PRETTY)
+        assert a.code == PRETTY
+        assert a.code_hash == b.code_hash
+
+        queued = [e for e in pipeline.get_audit_trail(a.staging_id)
+                  if e['event'] == AuditEventType.SNIPPET_QUEUED.value][0]
+        assert queued['data']['formatted'] is True
+
+    def test_invalid_go_is_refused(self, pipeline):
+        before = pipeline.get_reserved_positions()
+        with pytest.raises(FormatFailedError):
+            pipeline.queue_snippet('i', 'go', 'package main\nfunc main() {\n')
+        assert pipeline.get_reserved_positions() == before
+        assert pipeline.get_history() == []
+
+    def test_disabled_keeps_source(self, pipeline, engine):
+        engine.format_on_stage = False
+        assert pipeline.queue_snippet('i', 'go', UGLY).code == UGLY
+
+
+def test_missing_gofmt(monkeypatch):
+    monkeypatch.setattr(shutil, 'which', lambda name: None)
+    formatter = GoFormatter()
+    with pytest.raises(FormatUnavailableError):
+        formatter.format(b'package main\n')
+    engine = GoEngine(format_on_stage=True, formatter=formatter)
+    assert engine.format_for_stage(UGLY.encode()) == UGLY.encode()
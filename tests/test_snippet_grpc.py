@@ -0,0 +1,171 @@
+"""
+Test suite for the SnippetService gRPC servicer.
+
+The servicer is exercised with stand-in message classes (the protoc
+stubs are generated at install time) and a fake servicer context.
+
+Tests cover:
+  - Each RPC delegates to the StagingPipeline
+  - Pipeline errors map onto gRPC status codes by exception type
+  - Namespace metadata needs the namespace's credential
+  - Bearer token matching; serve() refuses a public address without tokens
+"""
+
+import types
+import pytest
+
+from visual_editor_core.snippet_capacity import SlotConfig
+from visual_editor_core.snippet_approvals import PendingApprovalError
+from visual_editor_core.snippet_breaker import CircuitOpenError
+from visual_editor_core.snippet_grpc import (
+    SnippetServicer, is_loopback, serve, status_for, token_matches,
+)
+
+
+class _Message:
+    """protobuf-like message: keyword fields, unset fields read as defaults."""
+
+    def __init__(self, **fields):
+        self.__dict__.update(fields)
+
+    def __getattr__(self, name):
+        return ''
+
+
+class _Request(_Message):
+    def __getattr__(self, name):
+        return 0 if name == 'limit' else False if name in (
+            'speculate', 'skip_lint', 'include_history', 'suppress_credential_check') else ''
+
+
+FAKE_PB2 = types.SimpleNamespace(
+    Snippet=_Message,
+    ListSnippetsResponse=_Message,
+    RollbackSnippetResponse=_Message,
+)
+
+
+class Aborted(Exception):
+    pass
+
+
+class FakeContext:
+    def __init__(self, metadata=()):
+        self.metadata = tuple(metadata)
+
+    def invocation_metadata(self):
+        return self.metadata
+
+    def abort(self, code, details):
+        self.code, self.details = code, details
+        raise Aborted(code)
+
+
+@pytest.fixture
+def servicer(make_pipeline):
+    return SnippetServicer(make_pipeline({}), pb2=FAKE_PB2)
+
+
+def _stage(servicer, code='x = 1', label='Calc', **kw):
+    return servicer.StageSnippet(_Request(engine_letter='a', language='python',
+                                          code=code, label=label, **kw), FakeContext())
+
+
+class TestSnippetServicer:
+
+    def test_stage_promote_get(self, servicer):
+        staged = _stage(servicer, speculate=True)
+        assert staged.phase == 'passed'
+        promoted = servicer.PromoteSnippet(_Request(staging_id=staged.staging_id), FakeContext())
+        assert promoted.phase == 'promoted' and promoted.spec_result == 'PASS'
+        fetched = servicer.GetSnippet(_Request(staging_id=staged.staging_id), FakeContext())
+        assert fetched.reserved_address == promoted.reserved_address
+
+    def test_suppress_credential_check(self, servicer):
+        code = 'password = "hunter22"'
+        with pytest.raises(Aborted) as exc:
+            _stage(servicer, code=code)
+        assert exc.value.args[0] == 'INVALID_ARGUMENT'
+        staged = _stage(servicer, code=code, suppress_credential_check=True)
+        assert staged.suppress_credential_check is True
+
+    def test_list_snippets(self, servicer):
+        queued = _stage(servicer)
+        done = _stage(servicer, label='Other', speculate=True)
+        servicer.PromoteSnippet(_Request(staging_id=done.staging_id), FakeContext())
+        active = servicer.ListSnippets(_Request(), FakeContext()).snippets
+        assert [s.staging_id for s in active] == [queued.staging_id]
+        everything = servicer.ListSnippets(_Request(include_history=True), FakeContext()).snippets
+        assert len(everything) == 2
+
+    def test_rollback_returns_restored(self, servicer):
+        ids = []
+        for code in ('x = 1', 'x = 2'):
+            s = _stage(servicer, code=code, speculate=True)
+            servicer.PromoteSnippet(_Request(staging_id=s.staging_id), FakeContext())
+            ids.append(s.staging_id)
+        resp = servicer.RollbackSnippet(_Request(staging_id=ids[1]), FakeContext())
+        assert resp.snippet.phase == 'rolled_back'
+        assert resp.restored.staging_id == ids[0]
+
+    @pytest.mark.parametrize('setup, call, expected', [
+        (None, lambda s: s.GetSnippet(_Request(staging_id='stg-missing'), FakeContext()), 'NOT_FOUND'),
+        (None, lambda s: s.PromoteSnippet(_Request(staging_id=_stage(s).staging_id), FakeContext()),
+         'FAILED_PRECONDITION'),
+        (None, lambda s: _stage(s, code='  '), 'INVALID_ARGUMENT'),
+        ('reject', lambda s: _stage(s, label_policy='reject'), 'ALREADY_EXISTS'),
+        ('full', lambda s: _stage(s, label='Second'), 'RESOURCE_EXHAUSTED'),
+    ])
+    def test_error_codes(self, servicer, setup, call, expected):
+        pipeline = servicer._pipeline
+        if setup == 'reject':
+            pipeline.run_full_pipeline('a', 'python', 'x = 1', 'Calc')
+        elif setup == 'full':
+            pipeline.configure_slot('a', SlotConfig(max_snippets=1))
+            _stage(servicer)
+        with pytest.raises(Aborted) as exc:
+            call(servicer)
+        assert exc.value.args[0] == expected
+
+
+class TestTokenMatches:
+
+    def test_valid_token(self):
+        assert token_matches('Bearer abc123', ['other', 'abc123'])
+
+    def test_rejects_wrong_or_missing(self):
+        assert not token_matches('Bearer nope', ['abc123'])
+        assert not token_matches('abc123', ['abc123'])
+        assert not token_matches('', ['abc123'])
+
+
+def test_namespace_needs_credential(make_pipeline):
+    pipeline = make_pipeline({}, namespace_credentials={'team-a': 'tok-a'})
+    servicer = SnippetServicer(pipeline, pb2=FAKE_PB2)
+    request = _Request(engine_letter='a', language='python', code='x = 1', label='Calc')
+    for metadata in ([('x-spokedpy-namespace', 'team-a')],
+                     [('x-spokedpy-namespace', 'team-a'),
+                      ('x-spokedpy-namespace-credential', 'wrong')],
+                     [('x-spokedpy-namespace', 'team-b'),
+                      ('x-spokedpy-namespace-credential', 'tok-a')]):
+        with pytest.raises(Aborted) as exc:
+            servicer.StageSnippet(request, FakeContext(metadata))
+        assert exc.value.args[0] == 'UNAUTHENTICATED'
+    staged = servicer.StageSnippet(request, FakeContext([
+        ('x-spokedpy-namespace', 'team-a'), ('x-spokedpy-namespace-credential', 'tok-a')]))
+    assert pipeline.get_snippet(staged.staging_id, 'team-a') is not None
+
+
+def test_status_by_type():
+    assert status_for(CircuitOpenError('circuit open', 'a', 0.0)) == 'UNAVAILABLE'
+    record = types.SimpleNamespace(staging_id='stg-1', slot='a1', approvals=[], required=2)
+    assert status_for(PendingApprovalError(record)) == 'FAILED_PRECONDITION'
+    # a message mentioning "phase" is not enough on its own
+    assert status_for(ValueError("bad phase name")) == 'INVALID_ARGUMENT'
+
+
+def test_public_address_needs_tokens():
+    assert is_loopback('localhost:50051') and is_loopback('[::1]:50051')
+    assert not is_loopback('[::]:50051') and not is_loopback('0.0.0.0:50051')
+    with pytest.raises(ValueError, match='bearer tokens'):
+        serve(None, '[::]:50051')
@@ -0,0 +1,155 @@
+"""
+Test suite for per-slot execution isolation.
+
+Tests cover:
+  - SlotConfig.isolation validation and to_dict()
+  - select_strategy(): NAMESPACE falls back to PROCESS with a warning where unsupported
+  - PythonProcessExecutor: a child interpreter, bound variables, returned globals,
+    errors and deadlines
+  - The pipeline picks the strategy when the slot is configured and speculates
+    Python out of process on an isolated slot; non-isolatable executors still run
+  - NAMESPACE on Linux: PID 1 of its own PID namespace, a private /proc, killed
+    on its deadline; Go end to end
+"""
+
+import logging
+import os
+import shutil
+import sys
+import time
+import pytest
+
+from visual_editor_core import snippet_isolation
+from visual_editor_core.execution_engine import (
+    GoExecutor, PythonExecutor, PythonProcessExecutor, _run_with_deadline,
+)
+from visual_editor_core.snippet_capacity import SlotConfig
+from visual_editor_core.snippet_engines import PythonEngine, RunOptions
+from visual_editor_core.snippet_staging import SpecResult
+from visual_editor_core.snippet_isolation import (
+    IsolationLevel, NamespaceIsolation, ProcessIsolation, namespace_support, select_strategy,
+)
+
+
+NAMESPACES = namespace_support()[0]
+
+
+class Captured(logging.Handler):
+    def __init__(self):
+        super().__init__()
+        self.messages = []
+
+    def emit(self, record):
+        self.messages.append(record.getMessage())
+
+
+def test_slot_config():
+    config = SlotConfig(isolation='namespace')
+    assert config.isolation == IsolationLevel.NAMESPACE
+    assert config.to_dict()['isolation'] == 'namespace'
+    assert SlotConfig().isolation == IsolationLevel.NONE
+    with pytest.raises(ValueError):
+        SlotConfig(isolation='container')
+
+
+def test_downgrade_warns(monkeypatch):
+    monkeypatch.setattr(snippet_isolation, 'namespace_support',
+                        lambda: (False, 'namespaces need Linux'))
+    captured = Captured()
+    snippet_isolation.logger.addHandler(captured)
+    try:
+        strategy = select_strategy('namespace', 'a')
+    finally:
+        snippet_isolation.logger.removeHandler(captured)
+    assert strategy.level == IsolationLevel.PROCESS
+    assert captured.messages == ['Slot a: namespace isolation unavailable '
+                                 '(namespaces need Linux); using process isolation']
+    assert select_strategy('process').describe() == {'level': 'process',
+                                                     'class': 'ProcessIsolation'}
+
+
+class TestPythonProcessExecutor:
+    def test_runs_in_a_child(self):
+        executor = PythonProcessExecutor(execution_timeout=30)
+        executor.set_variable_value('_slot_input', [1, 2])
+        result = executor.execute('import os\npid = os.getpid()\ntotal = sum(_slot_input)\n'
+                                  'print("hi")\n', isolation=ProcessIsolation())
+        assert result.success, result.error
+        assert result.output == 'hi\n'
+        assert result.variables['pid'] != os.getpid() and result.variables['total'] == 3
+
+    def test_errors_and_deadline(self):
+        result = PythonProcessExecutor(execution_timeout=30).execute('raise KeyError("k")')
+        assert not result.success and str(result.error) == "KeyError: 'k'"
+        start = time.time()
+        result = PythonProcessExecutor(execution_timeout=0.5).execute('while True: pass')
+        assert result.timed_out and time.time() - start < 5
+
+    def test_engine(self):
+        result = PythonEngine().run_with(b'x = n * 2\n', RunOptions(
+            params={'n': 21}, isolation=ProcessIsolation()))
+        assert result.success and result.variables == {'x': 42}
+
+
+class TestPipeline:
+    def test_strategy_per_slot(self, make_pipeline):
+        pipeline = make_pipeline(slot_configs={'a': SlotConfig(isolation='process')})
+        assert pipeline.isolation_for('a').level == IsolationLevel.PROCESS
+        assert pipeline.isolation_for('b').level == IsolationLevel.NONE
+        pipeline.configure_slot('a', SlotConfig())
+        assert pipeline.isolation_for('a').level == IsolationLevel.NONE
+
+    def test_speculates_out_of_process(self, make_pipeline):
+        pipeline = make_pipeline({'python': PythonExecutor()})
+        code = 'import os\nprint(os.getpid())\n'
+        inline = pipeline.queue_snippet('a', 'python', code, 'inline')
+        pipeline.speculate(inline.staging_id)
+        pipeline.configure_slot('a', SlotConfig(isolation='process'))
+        isolated = pipeline.queue_snippet('a', 'python', code + '# isolated\n', 'isolated')
+        pipeline.speculate(isolated.staging_id)
+        assert isolated.spec_result == SpecResult.PASS, isolated.spec_error
+        assert inline.spec_output.strip() == str(os.getpid())
+        assert isolated.spec_output.strip() != str(os.getpid())
+
+    def test_executor_without_isolation(self, make_pipeline):
+        pipeline = make_pipeline(slot_configs={'i': SlotConfig(isolation='process')})
+        snippet = pipeline.queue_snippet('i', 'go', 'package main', 'svc')
+        pipeline.speculate(snippet.staging_id)
+        assert snippet.spec_result == SpecResult.PASS
+        pipeline.promote(snippet.staging_id)
+        assert pipeline.slot_isolation(snippet.registry_slot_id).level == IsolationLevel.PROCESS
+
+
+@pytest.mark.skipif(not NAMESPACES, reason='user namespaces unavailable')
+class TestNamespaces:
+    def test_own_pid_namespace(self):
+        proc, timed_out = _run_with_deadline(
+            [sys.executable, '-c', 'import os; print(os.getpid(), '
+             'sum(p.isdigit() for p in os.listdir("/proc")))'],
+            timeout=30, isolation=NamespaceIsolation())
+        assert not timed_out and proc.returncode == 0, proc.stderr
+        pid, processes = proc.stdout.split()
+        assert pid == '1' and int(processes) <= 2
+
+    def test_killed_on_deadline(self):
+        start = time.time()
+        proc, timed_out = _run_with_deadline([sys.executable, '-c', 'import time; time.sleep(60)'],
+                                             timeout=0.5, kill_grace=5,
+                                             isolation=NamespaceIsolation())
+        assert timed_out and time.time() - start < 4
+
+    def test_python_slot(self, make_pipeline):
+        pipeline = make_pipeline({'python': PythonExecutor()},
+                                 slot_configs={'a': SlotConfig(isolation='namespace')})
+        assert pipeline.isolation_for('a').level == IsolationLevel.NAMESPACE
+        snippet = pipeline.queue_snippet('a', 'python', 'import os\nprint(os.getpid())\n', 'pid')
+        pipeline.speculate(snippet.staging_id)
+        assert snippet.spec_output.strip() == '1', snippet.spec_error
+
+    @pytest.mark.skipif(shutil.which('go') is None, reason='go toolchain not installed')
+    def test_go(self):
+        result = GoExecutor(execution_timeout=60).execute(
+            'package main\nimport (\n\t"fmt"\n\t"os"\n)\nfunc main() { fmt.Println(os.Getpid()) }\n',
+            isolation=NamespaceIsolation())
+        assert result.success, result.error
+        assert result.output.strip() == '1'
@@ -0,0 +1,172 @@
+"""
+Test suite for snippet resource limits.
+
+Tests cover:
+  - ResourceLimits validation and describe()
+  - _run_with_deadline(limits=…): RLIMIT_CPU, RLIMIT_AS and the output cap each
+    stop the child and name the violation; output exactly at the cap is fine
+  - The wall-clock CPU fallback where rlimits aren't available
+  - speculate() records RESOURCE_EXCEEDED and resource_violation; the stream
+    closes with 4004; the slot report counts it; dry_run_promote() reports it
+  - GoExecutor end to end, and execute(resource_limits=…) for one run
+"""
+
+import shutil
+import sys
+import pytest
+
+from visual_editor_core import snippet_limits
+from visual_editor_core.execution_engine import ExecutionResult, _run_with_deadline
+from visual_editor_core.snippet_staging import StagingPhase, SpecResult
+from visual_editor_core.snippet_stream import CloseCode
+from visual_editor_core.snippet_dryrun import GATE_SPECULATION
+from visual_editor_core.snippet_limits import (
+    ResourceLimits, VIOLATION_CPU_TIME, VIOLATION_MEMORY, VIOLATION_OUTPUT,
+)
+
+
+needs_rlimits = pytest.mark.skipif(not snippet_limits.RLIMITS_SUPPORTED,
+                                   reason='setrlimit() limits are Linux-only')
+
+
+def run_python(source, limits, timeout=30):
+    return _run_with_deadline([sys.executable, '-c', source], timeout=timeout,
+                              kill_grace=1.0, limits=limits)
+
+
+class LimitedExecutor:
+    """Stands in for an executor whose child ran out of memory."""
+
+    def execute(self, code):
+        if 'hog' in code:
+            return ExecutionResult(success=False, output='partial',
+                                   error=Exception('memory limit of 1024 bytes exceeded'),
+                                   execution_time=0.02, resource_violation=VIOLATION_MEMORY)
+        return ExecutionResult(success=True, output='ok', error=None, execution_time=0.01)
+
+
+@pytest.fixture
+def pipeline(make_pipeline):
+    return make_pipeline({'go': LimitedExecutor()})
+
+
+class TestResourceLimits:
+    def test_validation(self):
+        with pytest.raises(ValueError, match='max_cpu_time'):
+            ResourceLimits(max_cpu_time=-1)
+        with pytest.raises(ValueError, match='max_output_bytes'):
+            ResourceLimits(max_output_bytes='10')
+        assert not ResourceLimits().enabled
+        assert ResourceLimits(max_output_bytes=1).enabled
+
+    def test_describe(self):
+        limits = ResourceLimits(max_cpu_time=1.5, max_memory_bytes=64, max_output_bytes=10)
+        assert limits.describe(VIOLATION_CPU_TIME) == 'CPU time limit of 1.5s exceeded'
+        assert '64 bytes' in limits.describe(VIOLATION_MEMORY)
+        assert limits.describe('') == ''
+
+
+class TestRunWithDeadline:
+    @needs_rlimits
+    def test_cpu_time(self):
+        proc, timed_out = run_python('while True: pass', ResourceLimits(max_cpu_time=1))
+        assert not timed_out
+        assert proc.resource_violation == VIOLATION_CPU_TIME
+
+    @needs_rlimits
+    def test_memory(self):
+        proc, timed_out = run_python('x = bytearray(512 << 20)',
+                                     ResourceLimits(max_memory_bytes=256 << 20))
+        assert not timed_out and proc.returncode != 0
+        assert proc.resource_violation == VIOLATION_MEMORY
+
+    def test_output(self):
+        proc, timed_out = run_python('while True: print("x" * 99)',
+                                     ResourceLimits(max_output_bytes=1000))
+        assert not timed_out
+        assert proc.resource_violation == VIOLATION_OUTPUT
+        assert len(proc.stdout) == 1000
+
+    def test_output_at_cap(self):
+        proc, _ = run_python('import sys; sys.stdout.write("y" * 100)',
+                             ResourceLimits(max_output_bytes=100))
+        assert proc.returncode == 0 and proc.resource_violation == ''
+        assert proc.stdout == 'y' * 100
+
+    def test_streams_capped_output(self):
+        lines = []
+        proc, _ = _run_with_deadline([sys.executable, '-c', 'print("a" * 30)'], timeout=30,
+                                     on_output=lambda s, line: lines.append(line),
+                                     limits=ResourceLimits(max_output_bytes=10))
+        assert proc.resource_violation == VIOLATION_OUTPUT
+        assert lines == ['a' * 10]
+
+    def test_wall_clock_fallback(self, monkeypatch):
+        monkeypatch.setattr(snippet_limits, 'RLIMITS_SUPPORTED', False)
+        proc, timed_out = run_python('import time; time.sleep(30)',
+                                     ResourceLimits(max_cpu_time=0.5))
+        assert not timed_out
+        assert proc.resource_violation == VIOLATION_CPU_TIME
+
+    def test_deadline_is_still_timeout(self):
+        proc, timed_out = run_python('import time; time.sleep(30)',
+                                     ResourceLimits(max_cpu_time=10), timeout=0.5)
+        assert timed_out and proc.resource_violation == ''
+
+
+class TestPipeline:
+    def test_speculate_records_violation(self, pipeline):
+        snippet = pipeline.queue_snippet('i', 'go', 'package main // hog', 'hog')
+        snippet = pipeline.speculate(snippet.staging_id)
+
+        assert snippet.phase == StagingPhase.FAILED
+        assert snippet.spec_result == SpecResult.RESOURCE_EXCEEDED
+        assert snippet.resource_violation == VIOLATION_MEMORY
+        assert snippet.to_dict()['resource_violation'] == 'memory'
+        assert pipeline.output_stream(snippet.staging_id).close_code == \
+            CloseCode.RESOURCE_EXCEEDED
+        failed = [e for e in pipeline.get_audit_trail(snippet.staging_id)
+                  if e['event'] == 'spec_exec_failed']
+        assert failed[-1]['data']['resource_violation'] == 'memory'
+        assert pipeline.slot_report('i', since=0).resource_exceeded_count == 1
+
+    def test_dry_run_reports_violation(self, pipeline):
+        snippet = pipeline.queue_snippet('i', 'go', 'package main // hog', 'hog')
+        gate = pipeline.dry_run_promote(snippet.staging_id).gate(GATE_SPECULATION)
+        assert gate.data['spec_result'] == 'RESOURCE_EXCEEDED'
+        assert 'memory limit' in gate.detail
+
+
+@pytest.mark.skipif(shutil.which('go') is None, reason='go toolchain not installed')
+class TestGoExecutor:
+    def test_output_limit(self):
+        from visual_editor_core.execution_engine import GoExecutor
+        executor = GoExecutor(execution_timeout=60,
+                              resource_limits=ResourceLimits(max_output_bytes=64))
+        result = executor.execute('package main\n\nimport "fmt"\n\n'
+                                  'func main() {\n\tfor {\n\t\tfmt.Println("spam")\n\t}\n}\n')
+        assert not result.success and not result.timed_out
+        assert result.resource_violation == VIOLATION_OUTPUT
+        assert len(result.output) == 64
+
+    def test_per_run_limits(self):
+        from visual_editor_core.execution_engine import GoExecutor
+        code = ('package main\n\nimport "fmt"\n\n'
+                'func main() {\n\tfor i := 0; i < 100; i++ {\n\t\tfmt.Println("spam")\n\t}\n}\n')
+        executor = GoExecutor(execution_timeout=60)
+        assert executor.execute(code).success
+        result = executor.execute(code, resource_limits=ResourceLimits(max_output_bytes=32))
+        assert result.resource_violation == VIOLATION_OUTPUT
+        assert str(result.error) == 'output limit of 32 bytes exceeded'
+
+    @needs_rlimits
+    def test_cpu_limit(self):
+        from visual_editor_core.execution_engine import GoExecutor
+        executor = GoExecutor(execution_timeout=60,
+                              resource_limits=ResourceLimits(max_cpu_time=1))
+        result = executor.execute('package main\n\nfunc main() {\n\tfor {\n\t}\n}\n')
+        assert result.resource_violation == VIOLATION_CPU_TIME
+        assert 'CPU time limit' in str(result.error)
+
+        clean = executor.execute('package main\n\nfunc main() {}\n')
+        assert clean.success and clean.resource_violation == ''
@@ -0,0 +1,103 @@
+"""
+Test suite for the pre-promotion lint gate.
+
+Tests cover:
+  - Parsing `go vet -json` output into structured diagnostics
+  - Compile errors reported as 'compile' diagnostics
+  - Analyzers that cannot run fail the gate unless allow_unavailable
+  - GoVetLinter against the real toolchain (skipped without `go`)
+"""
+
+import shutil
+import pytest
+
+from visual_editor_core.snippet_lint import GoVetLinter, parse_vet_json
+
+
+VET_JSON = """# command-line-arguments
+{
+	"command-line-arguments": {
+		"printf": [
+			{
+				"posn": "/tmp/vpyd_lint_x/main.go:12:18",
+				"end": "/tmp/vpyd_lint_x/main.go:12:20",
+				"message": "fmt.Printf format %s reads arg #2, but call has 1 arg"
+			}
+		],
+		"unusedresult": [
+			{
+				"posn": "/tmp/vpyd_lint_x/main.go:14:2",
+				"message": "result of errors.New call not used"
+			}
+		]
+	}
+}
+"""
+
+BUGGY_GO = '''package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+func main() {
+	fmt.Printf("%d %s\\n", 1)
+	errors.New("dropped")
+}
+'''
+
+
+class TestParseVetJson:
+
+    def test_diagnostics(self):
+        diags = parse_vet_json(VET_JSON)
+        assert [(d.analyzer, d.line, d.column) for d in diags] == [
+            ('printf', 12, 18), ('unusedresult', 14, 2)]
+        assert 'reads arg #2' in diags[0].message
+
+    def test_clean_output(self):
+        assert parse_vet_json('', 0) == []
+
+    def test_compile_error(self):
+        out = "# command-line-arguments\nvet: ./main.go:2:20: expected operand, found '}'\n"
+        diags = parse_vet_json(out, returncode=1)
+        assert len(diags) == 1
+        assert (diags[0].analyzer, diags[0].line, diags[0].column) == ('compile', 2, 20)
+
+
+@pytest.mark.skipif(shutil.which('go') is None, reason='Go toolchain not installed')
+class TestGoVetLinter:
+
+    def test_flags_printf_and_unusedresult(self):
+        result = GoVetLinter(analyzers=('printf', 'unusedresult')).lint(BUGGY_GO)
+        assert not result.passed
+        assert {d.analyzer for d in result.diagnostics} == {'printf', 'unusedresult'}
+
+    def test_clean_snippet_passes(self):
+        code = 'package main\n\nimport "fmt"\n\nfunc main() { fmt.Println("ok") }\n'
+        assert GoVetLinter(allow_unavailable=True).lint(code).passed
+
+    def test_missing_shadow_tool(self):
+        linter = GoVetLinter(analyzers=('printf', 'shadow'))
+        linter._shadow_path = None   # as on a host without the shadow binary
+        result = linter.lint('package main\n\nfunc main() {}\n')
+        assert not result.passed and result.skipped == []
+        assert [(d.analyzer, d.message) for d in result.diagnostics] == [
+            ('shadow', 'analyzer unavailable: its vettool is not installed')]
+
+        linter._allow_unavailable = True
+        result = linter.lint('package main\n\nfunc main() {}\n')
+        assert result.passed and result.skipped == ['shadow']
+
+
+def test_no_toolchain():
+    linter = GoVetLinter(analyzers=('printf', 'shadow'))
+    linter._go_path = None           # as on a host without `go`
+    result = linter.lint('package main\n')
+    assert not result.passed
+    assert [d.analyzer for d in result.diagnostics] == ['printf', 'shadow']
+    assert 'no Go toolchain' in result.diagnostics[0].message
+
+    linter._allow_unavailable = True
+    assert linter.lint('package main\n').skipped == ['printf', 'shadow']
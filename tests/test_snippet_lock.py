@@ -0,0 +1,328 @@
+"""
+Test suite for per-slot promotion locks.
+
+Tests cover:
+  - LocalLocker: exclusive leases, waiting for an unlock, expired leases
+    taken over (the old holder's unlock raises LockLostError)
+  - RedisLocker: SET NX PX, compare-and-delete unlock (against a fake client)
+  - EtcdLocker: acquire / release of the client's lock (fake client)
+  - locker_from_settings(): backends, missing URL, unknown backend
+  - promote(): holds the slot's lock and releases it; a held slot refuses
+    the promotion (snippet stays PASSED, audited); a lost lease is audited;
+    concurrent promotions onto one slot run one at a time
+  - rollback() and a failed batch's undo hold the slot's lock too; a held
+    slot refuses the rollback and leaves the chain untouched
+"""
+
+import threading
+import time
+import pytest
+
+from visual_editor_core.snippet_staging import StagingPhase
+from visual_editor_core.snippet_lock import (
+    EtcdLocker, LocalLocker, LockLostError, LockToken, LockUnavailableError, RedisLocker,
+    locker_from_settings, redis, slot_lock_resource,
+)
+
+
+class FakeClock:
+    def __init__(self):
+        self.now = 1000.0
+
+    def __call__(self):
+        return self.now
+
+
+class FakeRedis:
+    """The two commands RedisLocker uses, with PX expiry on a fake clock."""
+
+    def __init__(self, clock):
+        self.clock = clock
+        self.keys = {}
+        self.calls = []
+
+    def _live(self, key):
+        value, expires = self.keys.get(key, (None, 0))
+        return value if self.clock() < expires else None
+
+    def set(self, key, value, nx=False, px=None):
+        self.calls.append(('set', key, nx, px))
+        if nx and self._live(key) is not None:
+            return None
+        self.keys[key] = (value, self.clock() + px / 1000)
+        return True
+
+    def eval(self, script, numkeys, key, value):
+        assert numkeys == 1 and "redis.call('del'" in script
+        if self._live(key) == value:
+            del self.keys[key]
+            return 1
+        return 0
+
+
+class FakeEtcdLock:
+    def __init__(self, name, ttl, held):
+        self.name, self.ttl, self.held = name, ttl, held
+        self.uuid = b''
+
+    def acquire(self, timeout=None):
+        if self.name in self.held:
+            return False
+        self.uuid = b'etcd-uuid'
+        self.held.add(self.name)
+        return True
+
+    def release(self):
+        if self.name not in self.held:
+            return False
+        self.held.discard(self.name)
+        return True
+
+
+class FakeEtcd:
+    def __init__(self):
+        self.held = set()
+
+    def lock(self, name, ttl=60):
+        return FakeEtcdLock(name, ttl, self.held)
+
+
+class RecordingLocker(LocalLocker):
+    """Counts how many leases are held at once."""
+
+    def __init__(self, hold=0.0):
+        super().__init__()
+        self.hold = hold
+        self.resources = []
+        self.active = self.max_active = 0
+        self._count = threading.Lock()
+
+    def lock(self, resource, ttl=30.0, wait=10.0):
+        token = super().lock(resource, ttl, wait)
+        with self._count:
+            self.resources.append(resource)
+            self.active += 1
+            self.max_active = max(self.max_active, self.active)
+        time.sleep(self.hold)                  # Stretch the critical section
+        return token
+
+    def unlock(self, token):
+        with self._count:
+            self.active -= 1
+        super().unlock(token)
+
+
+def passed(pipeline, label):
+    snippet = pipeline.queue_snippet('i', 'go', f'package main // {label}\n', label)
+    pipeline.speculate(snippet.staging_id)
+    return snippet
+
+
+class TestLocalLocker:
+    def test_exclusive(self):
+        locker = LocalLocker()
+        token = locker.lock('r', ttl=30, wait=0)
+        with pytest.raises(LockUnavailableError) as exc:
+            locker.lock('r', wait=0)
+        assert exc.value.resource == 'r'
+        other = locker.lock('s', wait=0)                     # Other resources are free
+        locker.unlock(token)
+        assert locker.lock('r', wait=0).value != token.value
+        assert set(locker.held()) == {'r', 's'} and other.resource == 's'
+
+    def test_waits_for_unlock(self):
+        locker = LocalLocker()
+        token = locker.lock('r')
+        threading.Timer(0.1, locker.unlock, (token,)).start()
+        started = time.monotonic()
+        locker.lock('r', wait=5)
+        assert time.monotonic() - started < 4
+
+    def test_expired_lease(self):
+        clock = FakeClock()
+        locker = LocalLocker(clock=clock)
+        first = locker.lock('r', ttl=10, wait=0)
+        assert first.expires_at == 1010
+        clock.now += 10
+        second = locker.lock('r', ttl=10, wait=0)            # Taken over
+        with pytest.raises(LockLostError):
+            locker.unlock(first)
+        locker.unlock(second)
+        assert locker.held() == {}
+
+    @pytest.mark.parametrize('kwargs', [{'ttl': 0}, {'wait': -1}])
+    def test_refused(self, kwargs):
+        with pytest.raises(ValueError):
+            LocalLocker().lock('r', **kwargs)
+
+
+class TestRedisLocker:
+    def test_set_nx_px(self):
+        clock = FakeClock()
+        client = FakeRedis(clock)
+        locker = RedisLocker(client, retry_interval=0.01)
+        token = locker.lock('spokedpy/slot/i', ttl=2.5, wait=0)
+        assert client.calls == [('set', 'spokedpy:lock:spokedpy/slot/i', True, 2500)]
+        with pytest.raises(LockUnavailableError):
+            locker.lock('spokedpy/slot/i', wait=0.05)
+        locker.unlock(token)
+        locker.lock('spokedpy/slot/i', wait=0)
+
+    def test_unlock_only_own_lease(self):
+        clock = FakeClock()
+        locker = RedisLocker(FakeRedis(clock))
+        first = locker.lock('r', ttl=1, wait=0)
+        clock.now += 1
+        second = locker.lock('r', ttl=1, wait=0)
+        with pytest.raises(LockLostError):
+            locker.unlock(first)                             # Leaves the new holder's key
+        locker.unlock(second)
+
+    @pytest.mark.skipif(redis is not None, reason='redis installed')
+    def test_from_url_needs_redis(self):
+        with pytest.raises(RuntimeError, match='redis is not installed'):
+            RedisLocker.from_url('redis://localhost:6379/0')
+
+
+def test_etcd_locker():
+    client = FakeEtcd()
+    locker = EtcdLocker(client)
+    token = locker.lock('r', ttl=2.5, wait=0)
+    assert token.value == 'etcd-uuid' and token.handle.ttl == 3
+    assert client.held == {'/spokedpy/locks/r'}
+    with pytest.raises(LockUnavailableError):
+        locker.lock('r', wait=0)
+    locker.unlock(token)
+    with pytest.raises(LockLostError):
+        locker.unlock(token)
+    with pytest.raises(LockLostError):
+        locker.unlock(LockToken('r', 'v', 1, 0))
+
+
+def test_locker_from_settings():
+    assert isinstance(locker_from_settings('', ''), LocalLocker)
+    assert isinstance(locker_from_settings('Local'), LocalLocker)
+    with pytest.raises(ValueError, match='needs a lock_url'):
+        locker_from_settings('redis', '')
+    with pytest.raises(ValueError, match='Unknown lock_backend'):
+        locker_from_settings('zookeeper', 'zk://x')
+
+
+class TestPromote:
+    def test_holds_and_releases(self, make_pipeline):
+        locker = RecordingLocker()
+        pipeline = make_pipeline(locker=locker)
+        snippet = pipeline.promote(passed(pipeline, 'svc').staging_id)
+        assert snippet.phase == StagingPhase.PROMOTED
+        assert locker.resources == [slot_lock_resource('i')] == ['spokedpy/slot/i']
+        assert locker.held() == {} and pipeline.locker is locker
+
+    def test_slot_busy(self, make_pipeline):
+        locker = LocalLocker()
+        pipeline = make_pipeline(locker=locker, promotion_lock_wait=0)
+        snippet = passed(pipeline, 'svc')
+        locker.lock('spokedpy/slot/i')                       # Another instance's promotion
+        with pytest.raises(LockUnavailableError, match='locked by another promotion'):
+            pipeline.promote(snippet.staging_id)
+        assert snippet.phase == StagingPhase.PASSED
+        errors = [e['data'] for e in pipeline.get_audit_trail(snippet.staging_id)
+                  if e['event'] == 'error']
+        assert errors[-1]['step'] == 'slot_lock'
+        # Other slots are not held up
+        other = pipeline.queue_snippet('j', 'go', 'package main // j\n', 'other')
+        pipeline.speculate(other.staging_id)
+        assert pipeline.promote(other.staging_id).phase == StagingPhase.PROMOTED
+
+    def test_lost_lease_audited(self, make_pipeline):
+        clock = FakeClock()
+        locker = LocalLocker(clock=clock)
+
+        pipeline = make_pipeline(locker=locker, promotion_lock_ttl=5)
+        snippet = passed(pipeline, 'svc')
+        original = pipeline._create_ledger_node
+
+        def slow_step(s):
+            clock.now += 5                                   # The promotion outlives its lease
+            return original(s)
+
+        pipeline._create_ledger_node = slow_step
+        pipeline.promote(snippet.staging_id)
+        assert snippet.phase == StagingPhase.PROMOTED
+        errors = [e['data'] for e in pipeline.get_audit_trail(snippet.staging_id)
+                  if e['event'] == 'error']
+        assert errors[-1]['step'] == 'slot_unlock' and errors[-1]['lock']['ttl'] == 5
+
+    def test_serialised(self, make_pipeline):
+        locker = RecordingLocker(hold=0.05)
+        pipeline = make_pipeline(locker=locker)
+        snippets = [passed(pipeline, f'svc{n}') for n in range(4)]
+        threads = [threading.Thread(target=pipeline.promote, args=(s.staging_id,))
+                   for s in snippets]
+        for t in threads:
+            t.start()
+        for t in threads:
+            t.join()
+        assert all(s.phase == StagingPhase.PROMOTED for s in snippets)
+        assert locker.max_active == 1 and len(locker.resources) == 4
+        assert len({s.reserved_address for s in snippets}) == 4
+
+    def test_refused(self, make_pipeline):
+        with pytest.raises(ValueError):
+            make_pipeline(promotion_lock_ttl=0)
+
+
+class TestRollback:
+    def test_holds_and_releases(self, make_pipeline):
+        locker = RecordingLocker()
+        pipeline = make_pipeline(locker=locker)
+        first = pipeline.promote(passed(pipeline, 'svc').staging_id)
+        second = pipeline.promote(passed(pipeline, 'svc').staging_id)
+        pipeline.rollback(second.staging_id)
+        assert locker.resources == ['spokedpy/slot/i'] * 3 and locker.held() == {}
+        assert second.phase == StagingPhase.ROLLED_BACK and first.phase == StagingPhase.PROMOTED
+
+    def test_slot_busy(self, make_pipeline):
+        locker = LocalLocker()
+        pipeline = make_pipeline(locker=locker, promotion_lock_wait=0)
+        first = pipeline.promote(passed(pipeline, 'svc').staging_id)
+        second = pipeline.promote(passed(pipeline, 'svc').staging_id)
+        token = locker.lock('spokedpy/slot/i')               # A promotion in flight
+        with pytest.raises(LockUnavailableError):
+            pipeline.rollback(second.staging_id)
+        assert second.phase == StagingPhase.PROMOTED and first.phase == StagingPhase.SUPERSEDED
+        locker.unlock(token)
+        pipeline.rollback(second.staging_id)
+        assert first.phase == StagingPhase.PROMOTED
+
+    def test_serialised_with_promotion(self, make_pipeline):
+        locker = RecordingLocker(hold=0.05)
+        pipeline = make_pipeline(locker=locker)
+        live = pipeline.promote(passed(pipeline, 'svc').staging_id)
+        pending = passed(pipeline, 'other')
+        threads = [threading.Thread(target=pipeline.rollback, args=(live.staging_id,)),
+                   threading.Thread(target=pipeline.promote, args=(pending.staging_id,))]
+        for t in threads:
+            t.start()
+        for t in threads:
+            t.join()
+        assert locker.max_active == 1
+        assert live.phase == StagingPhase.ROLLED_BACK and pending.phase == StagingPhase.PROMOTED
+
+    def test_batch_undo(self, make_pipeline):
+        locker = RecordingLocker()
+        pipeline = make_pipeline(locker=locker)
+        ok = passed(pipeline, 'ok')
+        bad = passed(pipeline, 'bad')
+        original = pipeline._promote_locked
+
+        def failing(staging_id):
+            if staging_id == bad.staging_id:
+                raise RuntimeError('disk full')
+            return original(staging_id)
+
+        pipeline._promote_locked = failing
+        with pytest.raises(ValueError, match='rolled back'):
+            pipeline.batch_promote([ok.staging_id, bad.staging_id])
+        # Two promotions and the undo of the first
+        assert locker.resources == ['spokedpy/slot/i'] * 3 and locker.held() == {}
+        assert ok.phase == StagingPhase.PASSED
@@ -0,0 +1,206 @@
+"""
+Test suite for structured logging from the staging pipeline.
+
+Tests cover:
+  - audit_level(): DEBUG steps and cache hits, INFO lifecycle, WARNING
+    gate refusals, ERROR execution failures
+  - log(): every ATTRS attribute on the record, key=value message,
+    quoting, exc_info inside an except block
+  - StagingPipeline(logger=...): audit events logged with staging_id,
+    slot, label, spec_result and duration_ms; the default logger
+  - Lint refusals at WARNING, failed runs and executor exceptions at ERROR
+  - Cache hits at DEBUG
+  - Engine runs and store writes logged through the pipeline's logger
+"""
+
+import logging
+import pytest
+
+from visual_editor_core.execution_engine import ExecutionResult
+from visual_editor_core.snippet_cache import ResultCache
+from visual_editor_core.snippet_lint import LintDiagnostic, LintResult, SnippetLinter
+from visual_editor_core.snippet_store import FileSnippetStore, HashMismatchError, compute_code_hash
+from visual_editor_core.snippet_log import (
+    ATTRS, DEFAULT_LOGGER_NAME, audit_level, log,
+)
+
+
+class Records(logging.Handler):
+    def __init__(self):
+        super().__init__(logging.DEBUG)
+        self.records = []
+
+    def emit(self, record):
+        self.records.append(record)
+
+    def named(self, message):
+        return [r for r in self.records if r.args[0] == message]
+
+
+def capture(name='test.snippets'):
+    logger = logging.getLogger(name)
+    logger.setLevel(logging.DEBUG)
+    logger.propagate = False
+    handler = Records()
+    logger.handlers = [handler]
+    return logger, handler
+
+
+class Executor:
+    def __init__(self):
+        self.runs = 0
+
+    def execute(self, code):
+        self.runs += 1
+        if 'crash' in code:
+            raise RuntimeError('executor crashed')
+        if 'broken' in code:
+            return ExecutionResult(success=False, output='', error='exit status 1',
+                                   execution_time=0.25)
+        return ExecutionResult(success=True, output='ok\n', error=None, execution_time=0.25)
+
+
+class MarkerLinter(SnippetLinter):
+    def lint(self, code):
+        diags = [LintDiagnostic('printf', 1, 1, 'flagged')] if 'lint:' in code else []
+        return LintResult(passed=not diags, diagnostics=diags)
+
+
+def logged_pipeline(make_pipeline, logger=None, **kwargs):
+    return make_pipeline({'go': Executor()}, logger=logger, **kwargs)
+
+
+def stage(pipeline, label, body='', **kwargs):
+    return pipeline.queue_snippet('i', 'go', f'package main // {body or label}\n', label,
+                                  **kwargs)
+
+
+class TestLevels:
+    @pytest.mark.parametrize('event, data, level', [
+        ('slot_reserved', {}, logging.DEBUG),
+        ('spec_exec_completed', {'cached': True}, logging.DEBUG),
+        ('spec_exec_completed', {'cached': False}, logging.INFO),
+        ('promotion_completed', {}, logging.INFO),
+        ('lint_failed', {}, logging.WARNING),
+        ('spec_exec_failed', {'spec_result': 'CASE_FAIL'}, logging.WARNING),
+        ('spec_exec_failed', {'spec_result': 'TIMEOUT'}, logging.ERROR),
+        ('spec_exec_failed', {}, logging.ERROR),               # Executor raised
+        ('error', {'step': 'revalidate'}, logging.ERROR),
+    ])
+    def test_audit_level(self, event, data, level):
+        assert audit_level(event, data) == level
+
+
+class TestLog:
+    def test_attributes_and_message(self):
+        logger, handler = capture()
+        log(logger, logging.INFO, 'promoted', staging_id='stg-1', slot='i', duration_ms=12.34,
+            error='two words', skipped=None)
+        record = handler.records[0]
+        assert {name: getattr(record, name) for name in ATTRS} == {
+            'staging_id': 'stg-1', 'slot': 'i', 'label': '', 'spec_result': '',
+            'duration_ms': 12.34}
+        assert record.getMessage() == 'promoted staging_id=stg-1 slot=i duration_ms=12.3 ' \
+                                      'error="two words"'
+        assert record.snippet == {'staging_id': 'stg-1', 'slot': 'i', 'duration_ms': 12.34,
+                                  'error': 'two words'}
+
+    def test_exc_info(self):
+        logger, handler = capture()
+        try:
+            raise RuntimeError('boom')
+        except RuntimeError:
+            log(logger, logging.ERROR, 'failed')
+            log(logger, logging.WARNING, 'refused')
+        assert handler.records[0].exc_info[0] is RuntimeError
+        assert not handler.records[1].exc_info
+        log(logger, logging.ERROR, 'outside')
+        assert not handler.records[2].exc_info
+
+    def test_disabled_level(self):
+        logger, handler = capture()
+        logger.setLevel(logging.INFO)
+        log(logger, logging.DEBUG, 'noise')
+        assert handler.records == []
+
+
+class TestPipeline:
+    def test_lifecycle(self, make_pipeline):
+        logger, handler = capture()
+        pipeline = logged_pipeline(make_pipeline, logger)
+        snippet = stage(pipeline, 'fib')
+        pipeline.speculate(snippet.staging_id)
+        pipeline.promote(snippet.staging_id)
+
+        queued = handler.named('snippet_queued')[0]
+        assert queued.levelno == logging.INFO
+        assert (queued.staging_id, queued.slot, queued.label) == (snippet.staging_id, 'i', 'fib')
+        passed = handler.named('spec_exec_completed')[0]
+        assert passed.levelno == logging.INFO
+        assert passed.spec_result == 'PASS' and passed.duration_ms == 250.0
+        promoted = handler.named('promotion_completed')[0]
+        assert promoted.levelno == logging.INFO and promoted.label == 'fib'
+        assert handler.named('registry_slot_committed')[0].levelno == logging.DEBUG
+        assert pipeline.logger is logger
+
+    def test_failures(self, make_pipeline):
+        logger, handler = capture()
+        pipeline = logged_pipeline(make_pipeline, logger, linters={'go': MarkerLinter()})
+        broken = stage(pipeline, 'broken')
+        pipeline.speculate(broken.staging_id)
+        failed = handler.named('spec_exec_failed')[-1]
+        assert failed.levelno == logging.ERROR and failed.spec_result == 'FAIL'
+        assert failed.staging_id == broken.staging_id and 'error="exit status 1"' in \
+            failed.getMessage()
+
+        crash = stage(pipeline, 'crash')
+        pipeline.speculate(crash.staging_id)
+        raised = handler.named('spec_exec_failed')[-1]
+        assert raised.levelno == logging.ERROR and raised.exc_info[0] is RuntimeError
+
+        flagged = stage(pipeline, 'flagged', 'lint: flagged')
+        pipeline.speculate(flagged.staging_id)
+        with pytest.raises(ValueError):
+            pipeline.promote(flagged.staging_id)
+        lint = handler.named('lint_failed')[-1]
+        assert lint.levelno == logging.WARNING and lint.label == 'flagged'
+
+    def test_cache_hit(self, make_pipeline):
+        logger, handler = capture()
+        pipeline = logged_pipeline(make_pipeline, logger, result_cache=ResultCache())
+        for label in ('a', 'b'):
+            snippet = stage(pipeline, label, 'same source', deterministic=True)
+            pipeline.speculate(snippet.staging_id)
+        first, second = handler.named('spec_exec_completed')
+        assert first.levelno == logging.INFO and second.levelno == logging.DEBUG
+        assert 'cached' not in second.snippet and second.label == 'b'
+
+    def test_default_logger(self, make_pipeline):
+        pipeline = logged_pipeline(make_pipeline)
+        assert pipeline.logger.name == DEFAULT_LOGGER_NAME
+
+    def test_engine_and_store(self, make_pipeline):
+        logger, handler = capture()
+        pipeline = logged_pipeline(make_pipeline, logger)
+        snippet = pipeline.queue_snippet('a', 'python', 'x = 1\n', 'py')
+        pipeline.speculate(snippet.staging_id)
+        run = handler.named('engine_run')[-1]
+        assert run.levelno == logging.DEBUG and run.snippet['language'] == 'python'
+        assert run.duration_ms is not None and run.snippet['success'] is True
+        pipeline.promote(snippet.staging_id)
+        stored = handler.named('payload_stored')[0]
+        assert stored.levelno == logging.DEBUG and stored.snippet['bytes'] == len('x = 1\n')
+
+
+def test_store_refused_write(tmp_path):
+    logger, handler = capture()
+    store = FileSnippetStore(str(tmp_path / 'objects'), logger=logger)
+    body = b'package main\n'
+    code_hash = compute_code_hash(body)
+    assert store.put(code_hash, body)
+    assert not store.put(code_hash, body)
+    assert [r.args[0] for r in handler.records] == ['payload_stored', 'payload_deduplicated']
+    with pytest.raises(HashMismatchError):
+        store.put(code_hash, b'something else')
+    failed = handler.records[-1]
+    assert failed.levelno == logging.ERROR and failed.args[0] == 'payload_write_failed'
@@ -0,0 +1,165 @@
+"""
+Test suite for YAML snippet manifests.
+
+Tests cover:
+  - parse_manifest(): format, entry fields, source_file under base_dir only,
+    conflicting slot schedules
+  - load_manifest(): staged in declaration order with position, parameters,
+    tags, resource_limits and the slot schedule; audited
+  - All or nothing: a refused entry rejects the ones queued before it
+  - Per-snippet resource limits reach a limitable executor; other languages
+    refuse them
+  - dump_manifest() round-trips into another pipeline
+"""
+
+import io
+import textwrap
+import pytest
+
+from visual_editor_core.execution_engine import ExecutionResult
+from visual_editor_core.snippet_limits import ResourceLimits
+from visual_editor_core.snippet_query import SnippetFilter
+from visual_editor_core.snippet_staging import StagingPhase
+from visual_editor_core.snippet_manifest import ManifestError, parse_manifest
+
+
+GO = 'package main\n\nimport "fmt"\n\nfunc main() {\n\tfmt.Println(n)\n}\n'
+
+MANIFEST = textwrap.dedent('''\
+    format: spokedpy-manifest/1
+    snippets:
+      - label: count
+        language: go
+        slot: i
+        position: 4
+        source_file: go/count.go
+        parameters:
+          - {name: n, type: int, required: false, default: 3}
+        tags: [math/count]
+        schedule: '*/15 * * * *'
+        resource_limits:
+          max_cpu_time: 2
+      - label: greet
+        language: python
+        slot: a
+        source: |
+          print("hello")
+''')
+
+
+class LimitsExecutor:
+    """Records the resource_limits each run was given."""
+    limitable = True
+
+    def __init__(self):
+        self.limits = []
+
+    def execute(self, code, resource_limits=None):
+        self.limits.append(resource_limits)
+        return ExecutionResult(success=True, output='3\n', execution_time=0.01)
+
+
+@pytest.fixture
+def base_dir(tmp_path):
+    (tmp_path / 'go').mkdir()
+    (tmp_path / 'go' / 'count.go').write_text(GO)
+    return str(tmp_path)
+
+
+class TestParse:
+    def test_entries(self, base_dir):
+        count, greet = parse_manifest(MANIFEST, base_dir)
+        assert count.source == GO and count.source_file == 'go/count.go'
+        assert count.position == 4 and count.resource_limits == ResourceLimits(max_cpu_time=2)
+        assert greet.source == 'print("hello")\n' and greet.position == 0
+
+    @pytest.mark.parametrize('text, error', [
+        ('format: other/1\nsnippets: []\n', 'Unsupported manifest format'),
+        ('format: spokedpy-manifest/1\nsnippets: []\n', 'lists no snippets'),
+        ('format: spokedpy-manifest/1\nsnippets: [{label: x, language: go, slot: i}]\n',
+         "exactly one of 'source_file' or 'source'"),
+        ('format: spokedpy-manifest/1\nsnippets: [{label: x, language: go, slot: i, '
+         'source: x, colour: red}]\n', 'Unknown field'),
+        ('format: spokedpy-manifest/1\nsnippets: [{label: x, language: go, slot: i, '
+         'source: x, resource_limits: {max_cpu_time: -1}}]\n', 'must be a number >= 0'),
+        ('format: spokedpy-manifest/1\nsnippets: [{label: x, language: go, slot: i, '
+         'source_file: ../x.go}]\n', 'outside the manifest'),
+        ('format: spokedpy-manifest/1\nsnippets: [{label: x, language: go, slot: i, '
+         'source_file: missing.go}]\n', 'Cannot read'),
+        ('{', 'Not valid YAML'),
+    ])
+    def test_refused(self, base_dir, text, error):
+        with pytest.raises(ManifestError, match=error):
+            parse_manifest(text, base_dir)
+
+    def test_no_base_dir(self):
+        with pytest.raises(ManifestError, match='give the source inline') as exc:
+            parse_manifest(MANIFEST)
+        assert exc.value.entry == 1
+
+    def test_conflicting_schedules(self):
+        text = MANIFEST.replace('source_file: go/count.go', 'source: x') + \
+            "    schedule: '@daily'\n"
+        text = text.replace('slot: a', 'slot: i')
+        with pytest.raises(ManifestError, match="already given the schedule") as exc:
+            parse_manifest(text)
+        assert exc.value.entry == 2
+
+
+class TestLoad:
+    def test_stages_in_order(self, make_pipeline, base_dir):
+        pipeline = make_pipeline({'go': LimitsExecutor()})
+        ids = pipeline.load_manifest(io.StringIO(MANIFEST), base_dir)
+        count, greet = (pipeline.get_snippet(sid) for sid in ids)
+        assert count.label == 'count' and count.reserved_address == 'i4'
+        assert count.parameters[0]['name'] == 'n' and count.tags == ['math/count']
+        assert count.resource_limits == ResourceLimits(max_cpu_time=2).to_dict()
+        assert greet.phase == StagingPhase.QUEUED and greet.engine_letter == 'a'
+        assert pipeline.get_slot_config('i').schedule == '*/15 * * * *'
+        entry = [e for e in pipeline.get_audit_trail(ids[0]) if e['event'] == 'manifest_loaded']
+        assert entry[0]['data']['entry'] == 1
+
+        pipeline.speculate(ids[0])
+        assert pipeline._executors['go'].limits == [ResourceLimits(max_cpu_time=2)]
+
+    def test_all_or_nothing(self, make_pipeline, base_dir):
+        pipeline = make_pipeline({'go': LimitsExecutor()})
+        taken = pipeline.queue_snippet('a', 'python', 'print(0)\n', 'first', position=1)
+        with pytest.raises(ManifestError, match=r'Entry 2 \(greet\)') as exc:
+            pipeline.load_manifest(MANIFEST.replace('slot: a', 'slot: a\n    position: 1'),
+                                   base_dir)
+        assert exc.value.entry == 2
+        assert [s for s in pipeline._staged.values() if s.label == 'count'] == []
+        rolled_back = [s for s in pipeline._history if s.label == 'count']
+        assert rolled_back[0].phase == StagingPhase.REJECTED
+        assert 'Manifest rolled back' in rolled_back[0].rejection_reason
+        assert pipeline.get_slot_config('i') is None
+        assert pipeline.get_snippet(taken.staging_id).phase == StagingPhase.QUEUED
+        # The rolled back position is free again
+        assert pipeline.queue_snippet('i', 'go', GO, 'again', position=4).reserved_position == 4
+
+    def test_limits_need_a_limitable_runtime(self, make_pipeline):
+        pipeline = make_pipeline({'go': LimitsExecutor()})
+        with pytest.raises(ValueError, match="not supported for 'python'"):
+            pipeline.queue_snippet('a', 'python', 'print(1)\n', 'capped',
+                                   resource_limits=ResourceLimits(max_output_bytes=10))
+
+
+def test_dump_round_trip(make_pipeline, base_dir):
+    source = make_pipeline({'go': LimitsExecutor()}, root='source')
+    source.load_manifest(MANIFEST, base_dir)
+    out = io.StringIO()
+    assert source.dump_manifest(SnippetFilter(), out) == 2
+    text = out.getvalue()
+    assert 'source: |' in text and 'source_file' not in text
+
+    target = make_pipeline({'go': LimitsExecutor()}, root='target')
+    ids = target.load_manifest(text)
+    count, greet = (target.get_snippet(sid) for sid in ids)
+    assert count.code == GO and count.reserved_position == 4
+    assert count.resource_limits == {'max_cpu_time': 2, 'max_memory_bytes': 0,
+                                     'max_output_bytes': 0}
+    assert greet.code == 'print("hello")\n'
+    assert target.get_slot_config('i').schedule == '*/15 * * * *'
+    with pytest.raises(ValueError, match='No snippets match'):
+        target.dump_manifest(SnippetFilter(label='nothing'), io.StringIO())
@@ -0,0 +1,103 @@
+"""
+Test suite for staging pipeline metrics.
+
+Tests cover:
+  - Counter / histogram / gauge bookkeeping in PipelineMetrics
+  - Prometheus text exposition
+  - Pipeline instrumentation (promotions, rollbacks, spec durations, queue depth)
+  - register_metrics() with prometheus_client (skipped when not installed)
+"""
+
+import pytest
+
+from visual_editor_core import snippet_metrics
+from visual_editor_core.snippet_metrics import (
+    PipelineMetrics, register_metrics,
+    PROMOTIONS, ROLLBACKS, SPEC_DURATION, QUEUE_DEPTH,
+)
+
+
+@pytest.fixture
+def metrics():
+    return PipelineMetrics(buckets=(0.1, 1.0))
+
+
+@pytest.fixture
+def pipeline(make_pipeline, metrics):
+    return make_pipeline({}, metrics=metrics)
+
+
+class TestPipelineMetrics:
+
+    def test_unused_metrics_are_zero(self, metrics):
+        snap = metrics.snapshot()
+        assert snap[PROMOTIONS] == [] and snap[ROLLBACKS] == []
+        assert snap[SPEC_DURATION] == [] and snap[QUEUE_DEPTH] == 0
+
+    def test_histogram_buckets_are_cumulative(self, metrics):
+        for seconds in (0.05, 0.5, 0.7, 3.0):
+            metrics.observe_spec_duration('go', seconds)
+        [row] = metrics.snapshot()[SPEC_DURATION]
+        assert row['buckets'] == {'0.1': 1, '1.0': 3, '+Inf': 4}
+        assert row['count'] == 4 and row['sum'] == pytest.approx(4.25)
+
+    def test_render_text(self, metrics):
+        metrics.record_promotion('go', 'i', 'PASS')
+        metrics.record_promotion('go', 'i', 'PASS')
+        metrics.record_rollback('go', 'i')
+        metrics.set_queue_depth(3)
+        text = metrics.render_text()
+        assert 'snippet_promotions_total{language="go",slot="i",spec_result="PASS"} 2' in text
+        assert 'snippet_rollbacks_total{language="go",slot="i"} 1' in text
+        assert 'snippet_staging_queue_depth 3' in text
+        assert '# TYPE snippet_spec_duration_seconds histogram' in text
+
+
+class TestInstrumentation:
+
+    def test_promotion_and_rollback_counted(self, pipeline, metrics):
+        snippet = pipeline.run_full_pipeline('a', 'python', 'x = 1', 'Calc')
+        pipeline.rollback(snippet.staging_id, 'bad')
+        snap = metrics.snapshot()
+        assert snap[PROMOTIONS] == [
+            {'language': 'python', 'slot': 'a', 'spec_result': 'PASS', 'value': 1}]
+        assert snap[ROLLBACKS] == [{'language': 'python', 'slot': 'a', 'value': 1}]
+        assert snap[SPEC_DURATION][0]['count'] == 1
+
+    def test_failed_spec_observed_but_not_promoted(self, pipeline, metrics):
+        pipeline.run_full_pipeline('a', 'python', 'raise RuntimeError("no")', 'Bad')
+        snap = metrics.snapshot()
+        assert snap[PROMOTIONS] == []
+        assert snap[SPEC_DURATION][0]['count'] == 1
+
+    def test_queue_depth_tracks_staged_snippets(self, pipeline, metrics):
+        first = pipeline.queue_snippet('a', 'python', 'x = 1', 'One')
+        pipeline.queue_snippet('a', 'python', 'y = 2', 'Two')
+        assert metrics.queue_depth() == 2
+        pipeline.speculate(first.staging_id)
+        pipeline.promote(first.staging_id)
+        assert metrics.queue_depth() == 1
+
+    def test_default_metrics_when_none_given(self, make_pipeline):
+        assert make_pipeline({}).metrics is snippet_metrics.DEFAULT_METRICS
+
+
+@pytest.mark.skipif(snippet_metrics.CounterMetricFamily is None,
+                    reason='prometheus_client not installed')
+class TestRegisterMetrics:
+
+    def test_exposes_collectors(self, metrics):
+        from prometheus_client import CollectorRegistry
+        registry = CollectorRegistry()
+        register_metrics(registry, metrics)
+        metrics.record_promotion('go', 'i', 'PASS')
+        value = registry.get_sample_value(
+            'snippet_promotions_total',
+            {'language': 'go', 'slot': 'i', 'spec_result': 'PASS'})
+        assert value == 1
+
+
+def test_register_without_prometheus_client(monkeypatch, metrics):
+    monkeypatch.setattr(snippet_metrics, 'CounterMetricFamily', None)
+    with pytest.raises(RuntimeError):
+        register_metrics(object(), metrics)
@@ -0,0 +1,201 @@
+"""
+Test suite for staging ID prefix migration.
+
+Tests cover:
+  - validate_id_prefix() / plan_prefix_migration() and staging_id_prefix for new IDs
+  - Dry runs: the full report, nothing renamed
+  - Applying: records, index, superseded_ids, promotion history (rollback still
+    walks back), approvals and reruns follow the new IDs; the old IDs are gone
+  - The audit trail spans the old and new IDs
+  - Conflicts: refused without force; force drops a retired record, never a live one
+  - In-flight snippets (a running canary) block the migration
+  - Pending webhook payloads are rewritten, re-signed and survive a restart
+  - A failure part way renames everything back
+  - NamespaceAdmin needs the admin credential
+"""
+
+import json
+import uuid
+import pytest
+
+from visual_editor_core.snippet_canary import CanaryController, CanaryConfig
+from visual_editor_core.snippet_approvals import PendingApprovalError
+from visual_editor_core.snippet_capacity import SlotConfig
+from visual_editor_core.snippet_namespace import NamespaceAdmin, NamespaceAdminError
+from visual_editor_core.snippet_query import SnippetFilter
+from visual_editor_core.snippet_staging import StagingPhase
+from visual_editor_core.snippet_webhooks import WebhookDispatcher, sign_payload
+from visual_editor_core.snippet_migrate import (
+    MigrateOptions, MigrationConflictError, plan_prefix_migration, validate_id_prefix,
+)
+
+
+SUFFIX = '0123456789ab'
+
+
+def fixed_uuid(monkeypatch):
+    """Every new staging ID gets the same suffix, so two prefixes can clash."""
+    monkeypatch.setattr(uuid, 'uuid4', lambda: type('U', (), {'hex': SUFFIX * 2})())
+
+
+def promote(pipeline, code, label='svc', **kwargs):
+    snippet = pipeline.queue_snippet('i', 'go', code, label)
+    pipeline.speculate(snippet.staging_id)
+    return pipeline.promote(snippet.staging_id, **kwargs)
+
+
+def test_prefixes():
+    assert validate_id_prefix(' team-alpha/stg- ') == 'team-alpha/stg-'
+    for bad in ('', '/stg-', 'a//b', 'a/../b', 'stg id', 'x' * 64):
+        with pytest.raises(ValueError, match='Invalid staging ID prefix'):
+            validate_id_prefix(bad)
+    assert plan_prefix_migration(['stg-1', 'other-2', 'stg-3'], 'stg-', 't/stg-') == {
+        'stg-1': 't/stg-1', 'stg-3': 't/stg-3'}
+    with pytest.raises(ValueError, match='the same'):
+        plan_prefix_migration([], 'stg-', 'stg-')
+
+
+def test_new_ids_use_the_prefix(make_pipeline):
+    pipeline = make_pipeline(staging_id_prefix='team-alpha/stg-')
+    snippet = pipeline.queue_snippet('i', 'go', 'package main', '')
+    assert snippet.staging_id.startswith('team-alpha/stg-') and len(snippet.staging_id) == 27
+    assert snippet.label.startswith('snippet-') and '/' not in snippet.label
+    with pytest.raises(ValueError):
+        make_pipeline(staging_id_prefix='/bad')
+
+
+class TestMigration:
+    def test_dry_run(self, make_pipeline):
+        pipeline = make_pipeline()
+        v1 = promote(pipeline, 'package main // v1')
+        v2 = promote(pipeline, 'package main // v2')
+        old1, old2 = v1.staging_id, v2.staging_id
+        report = pipeline.migrate_id_prefix('stg-', 'team-alpha/stg-',
+                                            MigrateOptions(dry_run=True))
+        assert report.mapping == {old1: 'team-alpha/' + old1, old2: 'team-alpha/' + old2}
+        assert report.references['snippets'] == 2
+        assert report.references['snippet_references'] == 1           # v2.superseded_ids
+        assert report.references['promotion_history'] == 2
+        assert not report.applied and report.to_dict()['renamed'] == 2
+        assert pipeline.get_snippet(old1) is v1 and v2.superseded_ids == [old1]
+
+    def test_apply(self, make_pipeline):
+        pipeline = make_pipeline()
+        v1 = promote(pipeline, 'package main // v1')
+        v2 = promote(pipeline, 'package main // v2')
+        old1, old2 = v1.staging_id, v2.staging_id
+        pipeline.rerun_snippet(old2, now=1.0)
+
+        report = pipeline.migrate_id_prefix('stg-', 'team-alpha/stg-')
+        assert report.applied
+        new1, new2 = report.mapping[old1], report.mapping[old2]
+        assert pipeline.get_snippet(old1) is None and pipeline.get_snippet(new2) is v2
+        assert v2.staging_id == new2 and v2.superseded_ids == [new1]
+        assert [s.staging_id for s in pipeline.query(SnippetFilter()).snippets] == [new1, new2]
+        assert [r.staging_id for r in pipeline.rerun_history(new2)] == [new2]
+
+        # Rollback still finds the version before it
+        pipeline.rollback(new2)
+        assert v1.phase == StagingPhase.PROMOTED and v2.rolled_back_to == new1
+
+        trail = [e['event'] for e in pipeline.get_audit_trail(new2)]
+        assert trail[0] == 'rollback' and trail[-1] == 'snippet_queued'
+        assert trail.index('id_migrated') < trail.index('promotion_completed')   # Newest first
+
+    def test_approvals_follow(self, make_pipeline):
+        pipeline = make_pipeline()
+        pipeline.configure_slot('i', SlotConfig(require_approvals=1))
+        snippet = pipeline.queue_snippet('i', 'go', 'package main', 'svc')
+        pipeline.speculate(snippet.staging_id)
+        with pytest.raises(PendingApprovalError):
+            pipeline.promote(snippet.staging_id)
+        old = snippet.staging_id
+        new = pipeline.migrate_id_prefix('stg-', 'b/stg-').mapping[old]
+        assert pipeline.get_approval(new).staging_id == new
+        pipeline.approve_promotion(new, 'alice')
+        assert snippet.phase == StagingPhase.PROMOTED
+
+    def test_conflicts(self, make_pipeline, monkeypatch):
+        pipeline = make_pipeline()
+        fixed_uuid(monkeypatch)
+        taken = pipeline.queue_snippet('i', 'go', 'package main // taken', 'x')
+        pipeline.migrate_id_prefix('stg-', 'a/')
+        mover = pipeline.queue_snippet('i', 'go', 'package main // mover', 'y')
+        assert (taken.staging_id, mover.staging_id) == ('a/' + SUFFIX, 'stg-' + SUFFIX)
+
+        dry = pipeline.migrate_id_prefix('stg-', 'a/', MigrateOptions(dry_run=True))
+        assert dry.conflicts == ['a/' + SUFFIX] and not dry.applied
+        with pytest.raises(MigrationConflictError, match='already exist') as exc:
+            pipeline.migrate_id_prefix('stg-', 'a/')
+        assert exc.value.report.conflicts == ['a/' + SUFFIX]
+        assert pipeline.get_snippet('a/' + SUFFIX) is taken
+
+        report = pipeline.migrate_id_prefix('stg-', 'a/', MigrateOptions(force=True))
+        assert report.overwritten == ['a/' + SUFFIX]
+        assert pipeline.get_snippet('a/' + SUFFIX) is mover
+        assert [s.staging_id for s in pipeline.query(SnippetFilter()).snippets] == ['a/' + SUFFIX]
+        trail = pipeline.get_audit_trail('a/' + SUFFIX)
+        assert {e['data'].get('label') for e in trail if e['event'] == 'snippet_queued'} == {'y'}
+
+    def test_force_never_drops_live(self, make_pipeline, monkeypatch):
+        pipeline = make_pipeline()
+        fixed_uuid(monkeypatch)
+        live = promote(pipeline, 'package main // live', 'x')
+        pipeline.migrate_id_prefix('stg-', 'a/')
+        pipeline.queue_snippet('i', 'go', 'package main // mover', 'y')
+        with pytest.raises(MigrationConflictError, match='live'):
+            pipeline.migrate_id_prefix('stg-', 'a/', MigrateOptions(force=True))
+        assert pipeline.get_snippet('a/' + SUFFIX) is live
+
+    def test_in_flight(self, make_pipeline):
+        pipeline = make_pipeline(canary_controller=CanaryController(background=False))
+        promote(pipeline, 'package main // v1')
+        canary = promote(pipeline, 'package main // v2', canary=CanaryConfig())
+        assert canary.phase == StagingPhase.CANARY
+        dry = pipeline.migrate_id_prefix('stg-', 'b/', MigrateOptions(dry_run=True))
+        assert len(dry.in_flight) == 2
+        with pytest.raises(MigrationConflictError, match='in flight'):
+            pipeline.migrate_id_prefix('stg-', 'b/')
+        assert pipeline.get_snippet(canary.staging_id) is canary
+
+    def test_failure_renames_back(self, make_pipeline):
+        pipeline = make_pipeline()
+        v1 = promote(pipeline, 'package main // v1')
+        old = v1.staging_id
+        index_put = pipeline._index.put
+
+        def failing_put(snippet):
+            raise OSError('disk full')
+        pipeline._index.put = failing_put
+        with pytest.raises(OSError):
+            pipeline.migrate_id_prefix('stg-', 'b/')
+        pipeline._index.put = index_put
+        assert v1.staging_id == old and pipeline.get_snippet(old) is v1
+        assert pipeline.rollback(old).phase == StagingPhase.ROLLED_BACK
+
+
+def test_webhooks_are_rewritten(tmp_path, make_pipeline):
+    log = str(tmp_path / 'webhooks.jsonl')
+    webhooks = WebhookDispatcher(log, transport=lambda *a: 500)
+    target = webhooks.add_target('http://hooks.example/a', 's3cret')
+    pipeline = make_pipeline(webhooks=webhooks)
+    v1 = promote(pipeline, 'package main // v1')
+    old = v1.staging_id
+
+    report = pipeline.migrate_id_prefix('stg-', 'b/')
+    assert report.references['webhooks'] == 1
+    (delivery,) = webhooks.pending()
+    assert json.loads(delivery.body)['staging_id'] == report.mapping[old]
+    assert delivery.signature == sign_payload(target.secret, delivery.body.encode('utf-8'))
+
+    (replayed,) = WebhookDispatcher(log).pending()
+    assert replayed.body == delivery.body and replayed.signature == delivery.signature
+
+
+def test_admin_only(make_pipeline):
+    pipeline = make_pipeline(namespace_admin_credential='root')
+    promote(pipeline, 'package main // v1')
+    with pytest.raises(NamespaceAdminError):
+        NamespaceAdmin(pipeline, 'guess')
+    report = NamespaceAdmin(pipeline, 'root').migrate_id_prefix('stg-', 'ops/stg-')
+    assert report.applied and pipeline.staging_id_prefix == 'stg-'
@@ -0,0 +1,189 @@
+"""
+Test suite for Go snippet mutation testing.
+
+Tests cover:
+  - MutationConfig validation and its WEAK_SPEC message
+  - find_mutations(): the four operators; strings, comments, ++, <<, <-
+    and <= left alone; remove_return only for a return that starts and
+    ends on its line
+  - select_mutations() spreading the cap over the candidates
+  - speculate() stores mutants_tested and survived_mutations; a weak run
+    is WEAK_SPEC, counted by the slot report and closed with 4008
+  - GoExecutor end to end: weak tests, tests that kill enough mutants,
+    failing tests, snippets without tests
+"""
+
+import shutil
+import textwrap
+import time
+import pytest
+
+from visual_editor_core.execution_engine import ExecutionResult, GoExecutor
+from visual_editor_core.snippet_report import build_slot_report
+from visual_editor_core.snippet_staging import StagingPhase, SpecResult
+from visual_editor_core.snippet_stream import CloseCode
+from visual_editor_core.snippet_mutation import (
+    LT_TO_LE, NEGATE_BOOL, PLUS_TO_MINUS, REMOVE_RETURN, MutantOutcome, Mutation,
+    MutationConfig, MutationReport, find_mutations, select_mutations,
+)
+
+
+SNIPPET = textwrap.dedent('''\
+    package main
+
+    import (
+    \t"fmt"
+    \t"testing"
+    )
+
+    func clamp(x, lo, hi int) int {
+    \tif x < lo {
+    \t\treturn lo
+    \t}
+    \tif x > hi {
+    \t\treturn hi
+    \t}
+    \treturn x
+    }
+
+    func main() {
+    \tfmt.Println(clamp(5, 0, 3))
+    }
+
+    func TestClamp(t *testing.T) {
+    \tif clamp(5, 0, 3) != 3 || clamp(-1, 0, 3) != 0 || clamp(2, 0, 3) != 2 {
+    \t\tt.Fatal("clamp")
+    \t}
+    }
+''')
+
+# Only the in-range case: neither bound is checked
+WEAK = SNIPPET.replace('clamp(5, 0, 3) != 3 || clamp(-1, 0, 3) != 0 || ', '')
+
+
+class WeakExecutor:
+    """Reports one of two mutants surviving, over the threshold."""
+
+    def execute(self, code):
+        survivor = Mutation(LT_TO_LE, 9, '<', '<=', 120)
+        return ExecutionResult(success=False, output='3\n', execution_time=0.01,
+                               error=Exception('1 of 2 mutants survived the tests'),
+                               mutants_tested=2, survived_mutations=[survivor],
+                               weak_spec=True)
+
+
+def test_config():
+    assert MutationConfig().max_mutations == 20
+    for bad in ({'max_mutations': 0}, {'threshold': 1.5}, {'threshold': -0.1}, {'timeout': 0}):
+        with pytest.raises(ValueError):
+            MutationConfig(**bad)
+
+    survivor = Mutation(PLUS_TO_MINUS, 3, '+', '-')
+    report = MutationReport(True, [(survivor, MutantOutcome.SURVIVED),
+                                   (Mutation(NEGATE_BOOL, 4, 'true', 'false'), MutantOutcome.KILLED),
+                                   (Mutation(REMOVE_RETURN, 5, 'return x', ''),
+                                    MutantOutcome.STILLBORN)])
+    assert report.counted == 2 and report.survival_ratio == 0.5
+    assert MutationConfig(threshold=0.5).describe(report) == ''
+    assert MutationConfig(threshold=0.25).describe(report) == (
+        "1 of 2 mutants survived the tests (50%, over the 25% threshold): line 3: `+` → `-`")
+
+
+class TestFindMutations:
+    def test_operators(self):
+        source = 'func f(a, b int) bool {\n\tif a < b {\n\t\treturn a+b > 0\n\t}\n\treturn true\n}\n'
+        assert [(m.operator, m.line, m.original) for m in find_mutations(source)] == [
+            (LT_TO_LE, 2, '<'),
+            (REMOVE_RETURN, 3, 'return a+b > 0'),
+            (PLUS_TO_MINUS, 3, '+'),
+            (REMOVE_RETURN, 5, 'return true'),
+            (NEGATE_BOOL, 5, 'true'),
+        ]
+
+    def test_left_alone(self):
+        source = textwrap.dedent('''\
+            // a < b + true
+            /* x + y
+               < z */
+            s := "a + b < c true" + `raw
+            < true`
+            r := '+'
+            i++
+            n := 1 << 2
+            v := <-ch
+            ok := a <= b
+            x := pkg.true
+            if done { return }
+        ''')
+        assert [(m.operator, m.line) for m in find_mutations(source)] == [(PLUS_TO_MINUS, 4)]
+
+    def test_return_statements(self):
+        source = ('\treturn fmt.Sprintf("%d // x", n) // why\n'
+                  '\treturn f(\n\t\tn)\n'
+                  '\treturn n;\n')
+        removals = [m for m in find_mutations(source) if m.operator == REMOVE_RETURN]
+        assert [m.original for m in removals] == ['return fmt.Sprintf("%d // x", n)', 'return n']
+        assert removals[1].apply(source).endswith('\t;\n')
+
+    def test_apply(self):
+        source = 'x := a < b\n'
+        (mutation,) = find_mutations(source)
+        assert mutation.apply(source) == 'x := a <= b\n'
+        assert mutation.describe() == 'line 1: `<` → `<=`'
+
+
+def test_select():
+    mutations = [Mutation(PLUS_TO_MINUS, line, '+', '-') for line in range(1, 11)]
+    assert [m.line for m in select_mutations(mutations, 4)] == [1, 3, 6, 8]
+    assert select_mutations(mutations[:3], 4) == mutations[:3]
+
+
+class TestPipeline:
+    def test_weak_spec(self, make_pipeline):
+        pipeline = make_pipeline({'go': WeakExecutor()})
+        snippet = pipeline.queue_snippet('i', 'go', SNIPPET, 'clamp')
+        since = time.time() - 1
+        pipeline.speculate(snippet.staging_id)
+        assert snippet.phase == StagingPhase.FAILED
+        assert snippet.spec_result == SpecResult.WEAK_SPEC
+        assert snippet.mutants_tested == 2
+        assert snippet.survived_mutations == [{'operator': LT_TO_LE, 'line': 9, 'original': '<',
+                                               'replacement': '<=', 'offset': 120}]
+        entry = [e for e in pipeline.get_audit_trail(snippet.staging_id)
+                 if e['event'] == 'spec_exec_failed'][0]
+        assert entry['data']['survived_mutations'] == snippet.survived_mutations
+        assert CloseCode.for_result('WEAK_SPEC') == CloseCode.WEAK_SPEC == 4008
+        report = build_slot_report('i', since, [snippet], [])
+        assert report.weak_spec_count == 1
+
+
+@pytest.mark.skipif(shutil.which('go') is None, reason='go toolchain not installed')
+class TestGoExecutor:
+    def run(self, code, threshold):
+        return GoExecutor(execution_timeout=120,
+                          mutation=MutationConfig(threshold=threshold)).execute(code)
+
+    def test_weak_tests(self):
+        result = self.run(WEAK, 0.25)
+        assert not result.success and result.weak_spec
+        # `return x` can't be removed without breaking the build
+        assert result.mutants_tested == 3
+        assert {m.operator for m in result.survived_mutations} == {LT_TO_LE, REMOVE_RETURN}
+        assert '3 of 3 mutants survived' in str(result.error)
+
+    def test_strong_tests(self):
+        result = self.run(SNIPPET, 0.5)
+        assert result.success, result.error
+        assert result.output == '3\n' and result.mutants_tested == 3
+        # x <= lo returns lo == x: no test can tell the difference
+        assert [m.operator for m in result.survived_mutations] == [LT_TO_LE]
+
+    def test_failing_tests(self):
+        result = self.run(SNIPPET.replace('!= 2', '!= 9'), 0.5)
+        assert not result.success and not result.weak_spec
+        assert 'Snippet tests failed' in str(result.error)
+
+    def test_no_tests(self):
+        code = 'package main\n\nimport "fmt"\n\nfunc main() {\n\tfmt.Println(1 + 2)\n}\n'
+        result = self.run(code, 0)
+        assert result.success and result.mutants_tested == 0
@@ -0,0 +1,200 @@
+"""
+Test suite for namespace isolation.
+
+Tests cover:
+  - validate_namespace() normalisation and rejection
+  - Snippets of another namespace are invisible to get_snippet / get_source / query / history
+  - promote, rollback, verdict and speculate refuse another namespace's snippet
+  - Labels and lineages are per namespace (REJECT does not conflict across them)
+  - The SQLite index stores and filters by namespace
+  - NamespaceAdmin: credential check and cross-namespace access
+  - Namespace credentials: parsing, and which namespaces they open
+  - Engine.stage / Engine.promote with StageOptions / PromoteOptions.namespace
+"""
+
+import pytest
+
+from visual_editor_core.snippet_staging import (
+    StagingPhase, LabelConflictPolicy, LabelConflictError,
+)
+from visual_editor_core.snippet_store import PayloadNotFoundError
+from visual_editor_core.snippet_query import SnippetFilter
+from visual_editor_core.snippet_sqlite import SQLiteSnippetIndex
+from visual_editor_core.snippet_engines import StageOptions, PromoteOptions
+from visual_editor_core.snippet_namespace import (
+    DEFAULT_NAMESPACE, NamespaceAdmin, NamespaceAdminError, validate_namespace,
+    parse_namespace_credentials,
+)
+
+
+CREDENTIAL = 's3cret-operator'
+
+
+@pytest.fixture
+def pipeline(make_pipeline):
+    return make_pipeline(namespace_admin_credential=CREDENTIAL)
+
+
+def stage(pipeline, code, namespace, label='svc', **kwargs):
+    snippet = pipeline.queue_snippet('i', 'go', code, label, namespace=namespace, **kwargs)
+    return pipeline.speculate(snippet.staging_id)
+
+
+class TestValidateNamespace:
+    def test_default_and_case(self):
+        assert validate_namespace('') == DEFAULT_NAMESPACE
+        assert validate_namespace(None) == DEFAULT_NAMESPACE
+        assert validate_namespace(' Payments ') == 'payments'
+
+    @pytest.mark.parametrize('name', ['-leading', 'has space', 'a/b', 'x' * 64])
+    def test_invalid(self, name):
+        with pytest.raises(ValueError, match='Invalid namespace'):
+            validate_namespace(name)
+
+    def test_queue_rejects_invalid(self, pipeline):
+        with pytest.raises(ValueError):
+            pipeline.queue_snippet('i', 'go', 'package main', namespace='no/slashes')
+
+
+class TestVisibility:
+    def test_lookups(self, pipeline):
+        a = pipeline.promote(stage(pipeline, 'package main // a', 'team-a').staging_id)
+        assert a.namespace == 'team-a'
+        assert pipeline.get_snippet(a.staging_id, 'team-a') is a
+        assert pipeline.get_snippet(a.staging_id, 'team-b') is None
+        assert pipeline.get_snippet(a.staging_id) is a                  # unscoped
+        assert pipeline.get_source(a.code_hash, 'team-a') == a.code
+        with pytest.raises(PayloadNotFoundError):
+            pipeline.get_source(a.code_hash, 'team-b')
+
+    def test_query_and_listing(self, pipeline):
+        a = stage(pipeline, 'package main // a', 'team-a')
+        b = stage(pipeline, 'package main // b', 'team-b')
+        ids = lambda page: [s.staging_id for s in page.snippets]
+        assert ids(pipeline.query(SnippetFilter(), 'team-a')) == [a.staging_id]
+        # A scoped caller cannot widen the search through the filter
+        assert ids(pipeline.query(SnippetFilter(namespace='team-b'), 'team-a')) == [a.staging_id]
+        assert ids(pipeline.query(SnippetFilter())) == [a.staging_id, b.staging_id]
+        assert [s.staging_id for s in pipeline.get_active('team-b')] == [b.staging_id]
+
+    def test_actions_refused(self, pipeline):
+        a = stage(pipeline, 'package main // a', 'team-a')
+        with pytest.raises(ValueError, match='No staged snippet'):
+            pipeline.promote(a.staging_id, namespace='team-b')
+        with pytest.raises(ValueError):
+            pipeline.verdict(a.staging_id, 'reject', namespace='team-b')
+        assert a.phase == StagingPhase.PASSED
+
+        pipeline.promote(a.staging_id, namespace='team-a')
+        with pytest.raises(ValueError):
+            pipeline.rollback(a.staging_id, namespace='team-b')
+        assert a.phase == StagingPhase.PROMOTED
+        assert pipeline.get_promotion_history('i', namespace='team-b') == []
+        assert len(pipeline.get_promotion_history('i', namespace='team-a')) == 1
+
+    def test_audit_trail_filtered(self, pipeline):
+        a = stage(pipeline, 'package main // a', 'team-a')
+        assert pipeline.get_audit_trail(a.staging_id, namespace='team-b') == []
+        assert pipeline.get_audit_trail(a.staging_id, namespace='team-a')
+
+    def test_slot_namespace(self, pipeline):
+        a = pipeline.promote(stage(pipeline, 'package main // a', 'team-a').staging_id)
+        assert pipeline.slot_namespace(a.registry_slot_id) == 'team-a'
+        assert pipeline.slot_namespace('nri99') is None
+
+
+class TestLabelsPerNamespace:
+    def test_reject_policy_is_per_namespace(self, pipeline):
+        a = pipeline.promote(stage(pipeline, 'package main // a', 'team-a',
+                                   label_policy=LabelConflictPolicy.REJECT).staging_id)
+        b = pipeline.promote(stage(pipeline, 'package main // b', 'team-b',
+                                   label_policy=LabelConflictPolicy.REJECT).staging_id)
+        assert a.phase == b.phase == StagingPhase.PROMOTED
+        assert a.reserved_address != b.reserved_address
+        with pytest.raises(LabelConflictError):
+            pipeline.queue_snippet('i', 'go', 'package main // a2', 'svc', namespace='team-a',
+                                   label_policy=LabelConflictPolicy.REJECT)
+
+    def test_overwrite_and_rollback_stay_in_namespace(self, pipeline):
+        a1 = pipeline.promote(stage(pipeline, 'package main // a1', 'team-a').staging_id)
+        b1 = pipeline.promote(stage(pipeline, 'package main // b1', 'team-b').staging_id)
+        a2 = pipeline.promote(stage(pipeline, 'package main // a2', 'team-a').staging_id)
+        assert a1.phase == StagingPhase.SUPERSEDED
+        assert b1.phase == StagingPhase.PROMOTED
+        assert a2.reserved_address == a1.reserved_address
+
+        pipeline.rollback(a2.staging_id, namespace='team-a')
+        assert a2.rolled_back_to == a1.staging_id
+        assert [v.staging_id for v in pipeline.get_version_history('svc', 'i', 'team-b')] \
+            == [b1.staging_id]
+
+
+class TestSQLiteIndex:
+    def test_namespace_column(self, tmp_path, make_pipeline):
+        pipeline = make_pipeline(namespace_admin_credential=CREDENTIAL,
+                                 snippet_index=SQLiteSnippetIndex(str(tmp_path / 'idx.db')))
+        a = stage(pipeline, 'package main // a', 'team-a')
+        stage(pipeline, 'package main // b', 'team-b')
+        page = pipeline.query(SnippetFilter(), 'team-a')
+        assert [r.staging_id for r in page.snippets] == [a.staging_id]
+        assert page.snippets[0].namespace == 'team-a'
+        assert len(pipeline.query(SnippetFilter(namespace='team-b')).snippets) == 1
+
+
+class TestNamespaceAdmin:
+    def test_credential_required(self, pipeline, make_pipeline):
+        with pytest.raises(NamespaceAdminError):
+            NamespaceAdmin(pipeline, 'wrong')
+        with pytest.raises(NamespaceAdminError):
+            NamespaceAdmin(pipeline, '')
+        # No configured credential: admin access is disabled altogether
+        disabled = make_pipeline({}, root='other')
+        with pytest.raises(NamespaceAdminError):
+            NamespaceAdmin(disabled, '')
+
+    def test_cross_namespace_access(self, pipeline):
+        a = stage(pipeline, 'package main // a', 'team-a')
+        stage(pipeline, 'package main // b', 'team-b')
+        stage(pipeline, 'package main // c', 'team-b', label='other')
+        admin = NamespaceAdmin(pipeline, CREDENTIAL)
+        assert admin.namespaces() == {'team-a': 1, 'team-b': 2}
+        assert admin.get_snippet(a.staging_id) is a
+        assert len(admin.query(SnippetFilter()).snippets) == 3
+        assert len(admin.get_active('team-b')) == 2
+        assert admin.promote(a.staging_id).phase == StagingPhase.PROMOTED
+
+
+class TestNamespaceCredentials:
+    def test_parse(self):
+        assert parse_namespace_credentials('') == {}
+        assert parse_namespace_credentials(' Team-A = tok-a , team-b=tok=b ') == {
+            'team-a': 'tok-a', 'team-b': 'tok=b'}
+        for bad in ('team-a', 'team-a=', '=tok', 'bad name=tok'):
+            with pytest.raises(ValueError):
+                parse_namespace_credentials(bad)
+
+    def test_check(self, make_pipeline):
+        pipeline = make_pipeline(namespace_admin_credential=CREDENTIAL,
+                                 namespace_credentials={'team-a': 'tok-a'})
+        assert pipeline.check_namespace_credential('team-a', 'tok-a')
+        assert not pipeline.check_namespace_credential('team-a', 'tok-b')
+        assert not pipeline.check_namespace_credential('team-a', None)
+        # no credential configured: default is open, everything else closed
+        assert pipeline.check_namespace_credential(DEFAULT_NAMESPACE, None)
+        assert not pipeline.check_namespace_credential('team-b', 'tok-a')
+        guarded = make_pipeline(root='g', namespace_admin_credential=CREDENTIAL,
+                                namespace_credentials={'default': 'tok-d'})
+        assert not guarded.check_namespace_credential(DEFAULT_NAMESPACE, None)
+        assert guarded.check_namespace_credential(DEFAULT_NAMESPACE, 'tok-d')
+
+
+class TestEngineOptions:
+    def test_stage_and_promote(self, pipeline):
+        engine = pipeline.get_engine('go')
+        staging_id = engine.stage(b'package main // e', StageOptions(label='svc', namespace='team-e'))
+        assert pipeline.get_snippet(staging_id, 'team-e') is not None
+        pipeline.speculate(staging_id)
+        with pytest.raises(ValueError):
+            engine.promote(staging_id, PromoteOptions(namespace='team-f'))
+        promoted = engine.promote(staging_id, PromoteOptions(namespace='team-e'))
+        assert promoted.phase == StagingPhase.PROMOTED
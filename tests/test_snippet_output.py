@@ -0,0 +1,202 @@
+"""
+Test suite for structured snippet output.
+
+Tests cover:
+  - snippet_schema: load_schema() rejects malformed schemas, $ref and unknown
+    keywords; validate() keywords and JSON Pointer paths; schema_hash()
+  - check_output(): absent, invalid JSON and schema failures
+  - _run_with_deadline(result_fd=True) captures fd 3 apart from stdout
+  - queue_snippet(output_schema=…): validation, Go only, canonical form and hash
+  - speculate(): spec_output_value on PASS, SCHEMA_FAIL with spec_output_errors,
+    close code 4005, the slot report and the dry-run speculation gate
+  - GoExecutor end to end
+"""
+
+import json
+import shutil
+import sys
+import pytest
+
+from visual_editor_core.execution_engine import ExecutionResult, _run_with_deadline
+from visual_editor_core.snippet_staging import StagingPhase, SpecResult
+from visual_editor_core.snippet_stream import CloseCode
+from visual_editor_core.snippet_dryrun import GATE_SPECULATION, GateStatus
+from visual_editor_core.snippet_output import check_output
+from visual_editor_core.snippet_schema import (
+    SchemaError, ValidationError, load_schema, pointer, schema_hash, validate,
+)
+
+
+TOTAL_SCHEMA = {'type': 'object', 'required': ['total'],
+                'properties': {'total': {'type': 'integer', 'minimum': 0}}}
+
+
+class ResultExecutor:
+    """Writes the JSON after 'result:' in the code to fd 3."""
+
+    def execute(self, code):
+        _, _, result = code.partition('// result:')
+        return ExecutionResult(success=True, output='ok', error=None, execution_time=0.01,
+                               structured_output=result.strip())
+
+
+@pytest.fixture
+def pipeline(make_pipeline):
+    return make_pipeline({'go': ResultExecutor()})
+
+
+def run(pipeline, result, schema=TOTAL_SCHEMA, label='pricing'):
+    snippet = pipeline.queue_snippet('i', 'go', f'package main // result: {result}', label,
+                                     output_schema=schema)
+    return pipeline.speculate(snippet.staging_id)
+
+
+class TestSchema:
+    def test_load(self):
+        assert load_schema('{"type": "string"}') == {'type': 'string'}
+        assert load_schema(True) is True
+        for bad, match in (('{', 'not valid JSON'), ([], 'object or a boolean'),
+                           ({'$ref': '#/x'}, "keyword '\\$ref'"),
+                           ({'properties': {'a': {'format': 'date'}}}, '/properties/a/format'),
+                           ({'type': 'float'}, 'must name types'),
+                           ({'minLength': -1}, '>= 0'), ({'multipleOf': 0}, '> 0'),
+                           ({'pattern': '('}, 'not a valid pattern'),
+                           ({'anyOf': []}, 'non-empty')):
+            with pytest.raises(SchemaError, match=match):
+                load_schema(bad)
+        load_schema({'title': 'x', 'description': 'y', '$schema': 'z', 'default': 1})
+
+    def test_types(self):
+        assert validate(3, {'type': 'integer'}) == []
+        assert validate(3.0, {'type': 'integer'}) == []
+        assert validate(3, {'type': 'number'}) == []
+        assert validate(True, {'type': ['integer', 'null']}) == [
+            ValidationError('', 'expected integer or null, got boolean')]
+        assert validate(None, False) == [ValidationError('', 'no value is allowed here')]
+
+    def test_objects_and_pointers(self):
+        schema = {'type': 'object', 'required': ['items'], 'additionalProperties': False,
+                  'properties': {'items': {'type': 'array', 'items': {
+                      'type': 'object', 'properties': {'a/b': {'type': 'string'}}}}}}
+        errors = validate({'items': [{'a/b': 'ok'}, {'a/b': 1}], 'extra': 1}, schema)
+        assert [(e.path, e.message) for e in errors] == [
+            ('/items/1/a~1b', 'expected string, got integer'),
+            ('/extra', 'unexpected property')]
+        assert [str(e) for e in validate({}, schema)] == ["/: missing required property 'items'"]
+        assert pointer(['x~y', 0]) == '/x~0y/0'
+
+    def test_ranges_and_combinators(self):
+        assert validate(5, {'minimum': 1, 'maximum': 5, 'multipleOf': 0.5}) == []
+        assert len(validate(5, {'exclusiveMaximum': 5, 'multipleOf': 2})) == 2
+        assert validate('abc', {'pattern': '^a', 'maxLength': 2})[0].message == \
+            'must be at most 2 characters'
+        assert validate([1, 1.0], {'uniqueItems': True}) == [ValidationError('/1', 'duplicate item')]
+        assert validate('EUR', {'enum': ['EUR', 'USD']}) == []
+        assert validate(1, {'const': True}) != []
+        assert validate(2, {'oneOf': [{'minimum': 1}, {'maximum': 3}]})[0].message == \
+            'must match exactly one schema in oneOf (matched 2)'
+        assert validate('x', {'anyOf': [{'type': 'integer'}, {'type': 'string'}]}) == []
+        assert validate('x', {'not': {'type': 'string'}}) != []
+
+    def test_hash(self):
+        assert schema_hash(None) == '' and len(schema_hash(TOTAL_SCHEMA)) == 64
+        assert schema_hash(json.dumps(TOTAL_SCHEMA, indent=2)) == schema_hash(
+            dict(reversed(list(TOTAL_SCHEMA.items()))))
+        assert schema_hash({'type': 'string'}) != schema_hash({'type': 'integer'})
+
+
+class TestCheckOutput:
+    def test_without_schema(self):
+        assert check_output('').passed and check_output('').value is None
+        check = check_output('{"total": 1}')
+        assert check.present and check.value == {'total': 1}
+        assert 'not valid JSON' in check_output('{').describe()
+
+    def test_with_schema(self):
+        assert check_output(' ', TOTAL_SCHEMA).describe() == '/: no result was written to fd 3'
+        check = check_output('{"total": -1}', TOTAL_SCHEMA)
+        assert check.value == {'total': -1}
+        assert check.error_dicts() == [{'path': '/total', 'message': 'must be >= 0'}]
+
+
+@pytest.mark.skipif(sys.platform == 'win32', reason='fd 3 is POSIX only')
+def test_run_with_deadline_result_fd():
+    source = 'import os; print("hello"); os.write(3, b\'{"total": 7}\')'
+    proc, timed_out = _run_with_deadline([sys.executable, '-c', source], timeout=30,
+                                         result_fd=True)
+    assert not timed_out and proc.returncode == 0
+    assert proc.stdout == 'hello\n' and proc.structured_output == '{"total": 7}'
+
+    proc, _ = _run_with_deadline([sys.executable, '-c', 'print(1)'], timeout=30)
+    assert proc.structured_output == ''
+
+
+class TestQueue:
+    def test_rejected(self, pipeline):
+        with pytest.raises(SchemaError, match="not supported for 'python'"):
+            pipeline.queue_snippet('a', 'python', 'x = 1', output_schema=TOTAL_SCHEMA)
+        with pytest.raises(SchemaError, match='\\$ref'):
+            pipeline.queue_snippet('i', 'go', 'package main', output_schema={'$ref': '#'})
+
+    def test_recorded(self, pipeline):
+        snippet = pipeline.queue_snippet('i', 'go', 'package main', 'svc',
+                                         output_schema=json.dumps(TOTAL_SCHEMA, indent=4))
+        assert json.loads(snippet.output_schema) == TOTAL_SCHEMA
+        assert snippet.output_schema_hash == schema_hash(TOTAL_SCHEMA)
+        queued = [e for e in pipeline.get_audit_trail(snippet.staging_id)
+                  if e['event'] == 'snippet_queued'][0]
+        assert queued['data']['output_schema_hash'] == snippet.output_schema_hash
+        assert pipeline.queue_snippet('i', 'go', 'package main // b').output_schema == ''
+
+
+class TestSpeculate:
+    def test_pass_records_value(self, pipeline):
+        snippet = run(pipeline, '{"total": 42, "currency": "EUR"}')
+        assert snippet.spec_result == SpecResult.PASS and snippet.phase == StagingPhase.PASSED
+        assert snippet.spec_output_value == {'total': 42, 'currency': 'EUR'}
+        assert snippet.to_dict()['spec_output_value']['total'] == 42
+        assert snippet.spec_output_errors == []
+
+    def test_schema_fail(self, pipeline):
+        snippet = run(pipeline, '{"total": "lots"}')
+        assert snippet.phase == StagingPhase.FAILED
+        assert snippet.spec_result == SpecResult.SCHEMA_FAIL
+        assert snippet.spec_output_errors == [
+            {'path': '/total', 'message': 'expected integer, got string'}]
+        assert 'failed its schema' in snippet.spec_error
+        assert pipeline.output_stream(snippet.staging_id).close_code == CloseCode.SCHEMA_FAIL
+        failed = [e for e in pipeline.get_audit_trail(snippet.staging_id)
+                  if e['event'] == 'spec_exec_failed']
+        assert failed[-1]['data']['output_errors'] == snippet.spec_output_errors
+        assert pipeline.slot_report('i', since=0).schema_fail_count == 1
+
+    def test_missing_result(self, pipeline):
+        snippet = run(pipeline, '')
+        assert snippet.spec_result == SpecResult.SCHEMA_FAIL
+        assert snippet.spec_output_errors[0]['message'] == 'no result was written to fd 3'
+
+    def test_without_schema_never_fails(self, pipeline):
+        snippet = run(pipeline, 'not json', schema=None)
+        assert snippet.spec_result == SpecResult.PASS and snippet.spec_output_value is None
+        assert 'not valid JSON' in snippet.spec_output_errors[0]['message']
+
+    def test_dry_run_gate(self, pipeline):
+        snippet = pipeline.queue_snippet('i', 'go', 'package main // result: {"total": -3}',
+                                         'pricing', output_schema=TOTAL_SCHEMA)
+        gate = pipeline.dry_run_promote(snippet.staging_id).gate(GATE_SPECULATION)
+        assert gate.status == GateStatus.FAILED and gate.data['spec_result'] == 'SCHEMA_FAIL'
+        assert gate.data['output_value'] == {'total': -3}
+        assert gate.detail == '/total: must be >= 0'
+        assert snippet.phase == StagingPhase.QUEUED
+
+
+@pytest.mark.skipif(shutil.which('go') is None, reason='go toolchain not installed')
+def test_go_executor_result_fd():
+    from visual_editor_core.execution_engine import GoExecutor
+    result = GoExecutor(execution_timeout=60).execute(
+        'package main\n\nimport (\n\t"encoding/json"\n\t"fmt"\n\t"os"\n)\n\n'
+        'func main() {\n\tfmt.Println("computing")\n'
+        '\tjson.NewEncoder(os.NewFile(3, "result")).Encode(map[string]int{"total": 42})\n}\n')
+    assert result.success, result.error
+    assert result.output == 'computing\n'
+    assert json.loads(result.structured_output) == {'total': 42}
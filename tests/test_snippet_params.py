@@ -0,0 +1,246 @@
+"""
+Test suite for typed snippet parameters.
+
+Tests cover:
+  - Argument validation (types, required / default, unknown names, int range)
+  - Hygienic var-block injection above func main()
+  - Package-level identifier clashes
+  - Pipeline: parameters at queue time, arguments bound at speculation
+  - State checkpoints carry the parameters and the speculated arguments
+  - JSON Schemas on specs: JSON Pointer errors, defaults, the schema hash,
+    speculation and dry runs refusing before anything executes
+  - Compiling the bound source with the Go toolchain (skipped without `go`)
+"""
+
+import shutil
+import subprocess
+import pytest
+
+from visual_editor_core.snippet_staging import StagingPhase
+from visual_editor_core.snippet_lint import LintResult
+from visual_editor_core.snippet_params import (
+    ParameterSpec, ParamType, ParameterError, ParameterSchemaError, bind_parameters,
+    validate_arguments, package_level_names, imported_names, go_quote, INJECTION_MARKER,
+    parameters_schema_hash,
+)
+from visual_editor_core.snippet_dryrun import GATE_PARAMETERS, GateStatus
+from web_interface.state_persistence import build_promoted_snapshots
+
+
+GO_SOURCE = '''package main
+
+import "fmt"
+
+var limit = 3 // func main() mentioned in a comment
+
+func main() {
+\tfmt.Println(n, ratio, who, len(tags), limit)
+}
+'''
+
+SPECS = [
+    ParameterSpec('n', ParamType.INT),
+    ParameterSpec('ratio', ParamType.FLOAT64, required=False, default=0.5),
+    ParameterSpec('who', 'string', required=False, default='world'),
+    ParameterSpec('tags', '[]string', required=False, default=[]),
+]
+
+
+class TestValidation:
+
+    def test_defaults_and_coercion(self):
+        values = validate_arguments(SPECS, {'n': 10.0, 'ratio': 2})
+        assert values == {'n': 10, 'ratio': 2.0, 'who': 'world', 'tags': []}
+
+    @pytest.mark.parametrize('arguments', [
+        {},                                   # missing required
+        {'n': True},                          # bool is not an int
+        {'n': 1.5},
+        {'n': 2 ** 63},                       # overflows Go int
+        {'n': 1, 'ratio': float('nan')},
+        {'n': 1, 'tags': ['a', 2]},
+        {'n': 1, 'extra': 'x'},               # unknown name
+    ])
+    def test_violations_rejected(self, arguments):
+        with pytest.raises(ParameterError):
+            validate_arguments(SPECS, arguments)
+
+    @pytest.mark.parametrize('name', ['len', 'main', 'func', '2x', ''])
+    def test_reserved_or_invalid_names(self, name):
+        with pytest.raises(ParameterError):
+            ParameterSpec(name, ParamType.INT)
+
+
+class TestInjection:
+
+    def test_block_inserted_above_main(self):
+        bound = bind_parameters(GO_SOURCE, SPECS, {'n': 7, 'tags': ['x', 'y"z']})
+        block = bound[bound.index(INJECTION_MARKER):bound.rindex('func main()')]
+        assert 'n     int      = 7' in block
+        assert 'tags  []string = []string{"x", "y\\"z"}' in block
+        assert bound.index('var limit') < bound.index(INJECTION_MARKER)
+
+    def test_clash_with_package_level_name(self):
+        with pytest.raises(ParameterError, match='limit'):
+            bind_parameters(GO_SOURCE, [ParameterSpec('limit', ParamType.INT)], {'limit': 1})
+
+    def test_package_level_names_ignore_locals_and_methods(self):
+        code = ('package main\n'
+                'type T struct{ x int }\n'
+                'func (t T) M() { y := 1; _ = y }\n'
+                'const (\n\tA = 1\n\tB, C = 2, 3\n)\n'
+                'func main() { z := 2; _ = z }\n')
+        assert package_level_names(code) == {'T', 'A', 'B', 'C', 'main'}
+
+    def test_clash_with_imported_package(self):
+        code = 'package main\nimport "fmt"\nfunc main() { fmt.Println(fmt) }\n'
+        with pytest.raises(ParameterError, match='imported package: fmt'):
+            bind_parameters(code, [ParameterSpec('fmt', ParamType.STRING)], {'fmt': 'x'})
+
+    def test_clash_with_import_alias(self):
+        code = ('package main\nimport (\n\t"fmt"\n\tstrs "strings" // aliased\n)\n'
+                'func main() { fmt.Println(strs.ToUpper(strings)) }\n')
+        with pytest.raises(ParameterError, match='imported package: strs'):
+            bind_parameters(code, [ParameterSpec('strs', ParamType.STRING)], {'strs': 'x'})
+        # the path's package name is bound by the alias, not by itself
+        bound = bind_parameters(code, [ParameterSpec('strings', ParamType.STRING)],
+                                {'strings': 'x'})
+        assert INJECTION_MARKER in bound
+
+    def test_imported_names(self):
+        code = ('package main\n'
+                'import "math/rand"\n'
+                'import (\n\t_ "embed"\n\t. "os"\n\tyaml "gopkg.in/yaml.v3"\n'
+                '\t"github.com/x/y/v2"\n\t// "net/http"\n)\n'
+                'func main() {}\n')
+        assert imported_names(code) == {'rand', 'yaml', 'y'}
+
+    def test_requires_func_main(self):
+        with pytest.raises(ParameterError):
+            bind_parameters('package lib\nfunc F() {}\n', SPECS, {'n': 1})
+
+    def test_go_quote_escapes(self):
+        assert go_quote('a\\b\n\x01') == '"a\\\\b\\n\\x01"'
+
+    @pytest.mark.skipif(shutil.which('go') is None, reason='go toolchain not installed')
+    def test_bound_source_compiles(self, tmp_path):
+        src = tmp_path / 'main.go'
+        src.write_text(bind_parameters(GO_SOURCE, SPECS, {'n': 42, 'who': 'é\t"q"'}))
+        proc = subprocess.run(['go', 'run', str(src)], capture_output=True, text=True,
+                              timeout=120, cwd=str(tmp_path))
+        assert proc.returncode == 0, proc.stderr
+        assert proc.stdout.split()[0] == '42'
+
+
+SCHEMA_SPECS = [
+    ParameterSpec('n', ParamType.INT, schema={'minimum': 1, 'maximum': 90}),
+    ParameterSpec('who', 'string', required=False, default='ABC',
+                  schema='{"pattern": "^[A-Z]{3}$"}'),
+    ParameterSpec('tags', '[]string', required=False, default=[],
+                  schema={'items': {'enum': ['a', 'b']}, 'maxItems': 2}),
+]
+
+
+class TestSchemas:
+
+    def test_spec_schema(self):
+        spec = ParameterSpec('n', 'int', schema='{ "maximum": 9, "minimum": 1 }')
+        assert spec.schema == '{"maximum":9,"minimum":1}'
+        assert ParameterSpec.from_dict(spec.to_dict()) == spec
+        with pytest.raises(ParameterError, match="'n' has an invalid schema"):
+            ParameterSpec('n', 'int', schema={'minimum': 'one'})
+        with pytest.raises(ParameterError, match="Default of 'who' fails its schema"):
+            ParameterSpec('who', 'string', required=False, default='abc',
+                          schema={'pattern': '^[A-Z]+$'})
+
+    def test_errors_point_into_the_arguments(self):
+        assert validate_arguments(SCHEMA_SPECS, {'n': 4}) == {'n': 4, 'who': 'ABC', 'tags': []}
+        with pytest.raises(ParameterSchemaError) as exc:
+            validate_arguments(SCHEMA_SPECS, {'n': 0, 'who': 'abcd', 'tags': ['a', 'c', 'b']})
+        assert [e['path'] for e in exc.value.error_dicts()] == ['/n', '/who', '/tags/1', '/tags']
+        assert str(exc.value).startswith('Arguments fail their schema: /n: ')
+        with pytest.raises(ParameterError, match='must be an int'):       # Types come first
+            validate_arguments(SCHEMA_SPECS, {'n': 'ten'})
+
+    def test_schema_hash(self):
+        assert parameters_schema_hash(SPECS) == ''
+        reordered = [ParameterSpec('n', ParamType.INT, schema={'maximum': 90, 'minimum': 1})]
+        assert parameters_schema_hash(SCHEMA_SPECS[:1]) == parameters_schema_hash(reordered)
+        assert len(parameters_schema_hash(SCHEMA_SPECS)) == 64
+
+    def test_pipeline(self, pipeline, passing_executor):
+        snippet = pipeline.queue_snippet('i', 'go', GO_SOURCE.replace('ratio, ', ''), 'Params',
+                                         parameters=SCHEMA_SPECS)
+        assert snippet.parameters_schema_hash == parameters_schema_hash(SCHEMA_SPECS)
+        queued = [e for e in pipeline.get_audit_trail(snippet.staging_id)
+                  if e['event'] == 'snippet_queued'][0]
+        assert queued['data']['parameters_schema_hash'] == snippet.parameters_schema_hash
+
+        with pytest.raises(ParameterSchemaError):
+            pipeline.speculate(snippet.staging_id, arguments={'n': 100})
+        assert snippet.phase == StagingPhase.QUEUED and passing_executor.codes == []
+
+        gate = pipeline.dry_run_promote(snippet.staging_id,
+                                        arguments={'n': 100}).gate(GATE_PARAMETERS)
+        assert gate.status == GateStatus.FAILED
+        assert gate.data['errors'] == [{'path': '/n', 'message': 'must be <= 90'}]
+
+        pipeline.speculate(snippet.staging_id, arguments={'n': 90, 'tags': ['b']})
+        assert snippet.phase == StagingPhase.PASSED
+
+
+class TestPipelineBinding:
+
+    def test_arguments_bound_for_speculation(self, pipeline, passing_executor):
+        snippet = pipeline.queue_snippet('i', 'go', GO_SOURCE, 'Params',
+                                         parameters=[s.to_dict() for s in SPECS])
+        pipeline.speculate(snippet.staging_id, arguments={'n': 5})
+        assert snippet.phase == StagingPhase.PASSED
+        assert snippet.spec_arguments['n'] == 5
+        assert 'n     int      = 5' in passing_executor.codes[-1]
+        assert snippet.code == GO_SOURCE                 # stored source is unbound
+
+    def test_bad_arguments_fail_before_execution(self, pipeline, passing_executor):
+        snippet = pipeline.queue_snippet('i', 'go', GO_SOURCE, 'Params', parameters=SPECS)
+        with pytest.raises(ParameterError):
+            pipeline.speculate(snippet.staging_id, arguments={'n': 'ten'})
+        assert snippet.phase == StagingPhase.QUEUED
+        assert passing_executor.codes == []
+
+    def test_clash_rejected_at_queue_time(self, pipeline):
+        with pytest.raises(ParameterError):
+            pipeline.queue_snippet('i', 'go', GO_SOURCE, 'Params',
+                                   parameters=[{'name': 'limit', 'type': 'int'}])
+
+    def test_slot_execution_binds_arguments(self, pipeline):
+        snippet = pipeline.run_full_pipeline('i', 'go', GO_SOURCE, 'Params',
+                                             parameters=SPECS, arguments={'n': 1})
+        bound = pipeline.bind_slot_arguments(snippet.registry_slot_id, GO_SOURCE, {'n': 9})
+        assert 'n     int      = 9' in bound
+        with pytest.raises(ParameterError):
+            pipeline.bind_slot_arguments('no-such-slot', GO_SOURCE, {'n': 9})
+
+    def test_lint_gate_sees_bound_source(self, pipeline):
+        seen = []
+
+        class Capture:
+            def lint(self, code):
+                seen.append(code)
+                return LintResult(passed=True)
+        pipeline._linters['go'] = Capture()
+        pipeline.run_full_pipeline('i', 'go', GO_SOURCE, 'Params',
+                                   parameters=SPECS, arguments={'n': 3})
+        assert 'n     int      = 3' in seen[0]
+
+    def test_checkpoint_carries_binding(self, pipeline, passing_executor):
+        pipeline.run_full_pipeline('i', 'go', GO_SOURCE, 'Params',
+                                   parameters=SPECS, arguments={'n': 4})
+        snap, = build_promoted_snapshots(pipeline, {}, {})
+        assert snap['parameters'] == [s.to_dict() for s in SPECS]
+        assert snap['spec_arguments']['n'] == 4
+        pipeline.rollback(snap['staging_id'])
+        restored = pipeline.run_full_pipeline('i', 'go', snap['code'], 'Params',
+                                              parameters=snap['parameters'],
+                                              arguments=snap['spec_arguments'])
+        assert restored.phase == StagingPhase.PROMOTED
+        assert 'n     int      = 4' in passing_executor.codes[-1]
@@ -0,0 +1,118 @@
+"""
+Test suite for snippet search.
+
+Tests cover:
+  - Filter fields (language, label substring, slot, spec_result, dates, hash prefix)
+  - Cursor pagination, including snippets queued mid-scan
+  - Invalid page tokens and limits
+  - Pipeline keeps the index in step with speculative results
+
+Every test runs against both the in-memory and the SQLite index.
+"""
+
+import time
+import pytest
+
+from visual_editor_core.snippet_query import (
+    SnippetFilter, InMemorySnippetIndex, encode_page_token,
+)
+from visual_editor_core.snippet_sqlite import SQLiteSnippetIndex
+
+
+@pytest.fixture(params=['memory', 'sqlite'])
+def pipeline(request, tmp_path, make_pipeline):
+    index = (SQLiteSnippetIndex(str(tmp_path / 'index.db')) if request.param == 'sqlite'
+             else InMemorySnippetIndex())
+    return make_pipeline({}, snippet_index=index)
+
+
+def _drain(pipeline, **criteria):
+    """Follow page tokens to the end; returns (staging_ids, page_count)."""
+    ids, pages, token = [], 0, ''
+    while True:
+        page = pipeline.query(SnippetFilter(page_token=token, **criteria))
+        ids += [s.staging_id for s in page.snippets]
+        pages += 1
+        token = page.next_page_token
+        if not token:
+            return ids, pages
+
+
+class TestFilters:
+
+    def test_language_slot_and_label(self, pipeline):
+        fib = pipeline.queue_snippet('a', 'python', 'x = 1', 'Fibonacci')
+        pipeline.queue_snippet('a', 'python', 'x = 2', 'Sorter')
+        pipeline.queue_snippet('b', 'javascript', 'let x = 1', 'fib-js')
+
+        page = pipeline.query(SnippetFilter(label='FIB'))
+        assert len(page.snippets) == 2
+        page = pipeline.query(SnippetFilter(label='fib', slot='a'))
+        assert [s.staging_id for s in page.snippets] == [fib.staging_id]
+        page = pipeline.query(SnippetFilter(language='JavaScript'))
+        assert [s.label for s in page.snippets] == ['fib-js']
+
+    def test_spec_result_follows_speculation(self, pipeline):
+        ok = pipeline.run_full_pipeline('a', 'python', 'x = 1', 'Good')
+        pipeline.run_full_pipeline('a', 'python', 'raise ValueError()', 'Bad')
+        page = pipeline.query(SnippetFilter(spec_result='pass'))
+        assert [s.staging_id for s in page.snippets] == [ok.staging_id]
+        assert [s.label for s in pipeline.query(SnippetFilter(spec_result='FAIL')).snippets] == ['Bad']
+
+    def test_created_window_and_hash_prefix(self, pipeline, monkeypatch):
+        now = [100.0]
+        monkeypatch.setattr(time, 'time', lambda: now[0])
+        s1 = pipeline.queue_snippet('a', 'python', 'x = 1', 'One')
+        now[0] = 200.0
+        s2 = pipeline.queue_snippet('a', 'python', 'x = 2', 'Two')
+        monkeypatch.undo()
+        assert pipeline.query(SnippetFilter(created_after=150)).snippets == [s2]
+        assert pipeline.query(SnippetFilter(created_before=150)).snippets == [s1]
+        page = pipeline.query(SnippetFilter(code_hash_prefix=s2.code_hash[:8].upper()))
+        assert page.snippets == [s2]
+
+
+class TestPagination:
+
+    def test_pages_cover_every_match_once(self, pipeline):
+        queued = [pipeline.queue_snippet('a', 'python', f'x = {n}', f'n{n}').staging_id
+                  for n in range(7)]
+        ids, pages = _drain(pipeline, limit=3)
+        assert ids == queued
+        assert pages == 3
+
+    def test_exact_page_boundary_has_no_trailing_token(self, pipeline):
+        for n in range(4):
+            pipeline.queue_snippet('a', 'python', f'x = {n}', f'n{n}')
+        first = pipeline.query(SnippetFilter(limit=2))
+        second = pipeline.query(SnippetFilter(limit=2, page_token=first.next_page_token))
+        assert len(second.snippets) == 2 and second.next_page_token == ''
+
+    def test_snippets_queued_mid_scan_are_not_skipped(self, pipeline):
+        for n in range(3):
+            pipeline.queue_snippet('a', 'python', f'x = {n}', f'n{n}')
+        first = pipeline.query(SnippetFilter(limit=2))
+        late = pipeline.queue_snippet('a', 'python', 'x = 99', 'late')
+        rest = pipeline.query(SnippetFilter(limit=10, page_token=first.next_page_token))
+        assert rest.snippets[-1] is late
+        assert not set(s.staging_id for s in first.snippets) & set(
+            s.staging_id for s in rest.snippets)
+
+    def test_bad_input_is_a_value_error(self, pipeline):
+        with pytest.raises(ValueError):
+            pipeline.query(SnippetFilter(page_token='not-a-token!'))
+        with pytest.raises(ValueError):
+            SnippetFilter(limit=0)
+
+
+def test_index_remove():
+    class Stub:
+        def __init__(self, sid, t):
+            self.staging_id, self.created_at = sid, t
+    index = InMemorySnippetIndex()
+    index.put(Stub('b', 2.0))
+    index.put(Stub('a', 1.0))
+    index.remove('a')
+    index.remove('missing')
+    assert len(index) == 1
+    assert encode_page_token(1.0, 'a') != encode_page_token(1.0, 'b')
@@ -0,0 +1,180 @@
+"""
+Test suite for the asynchronous speculation queue.
+
+Tests cover:
+  - stage() returns before speculation runs; wait_for_result() blocks
+  - Priority ordering (HIGH before NORMAL before LOW, FIFO within a level)
+  - Backpressure: QueueFullError, or blocking until a place frees
+  - auto_promote, worker crashes, close() draining / cancelling
+  - Finished results are retained only up to retain_results
+"""
+
+import threading
+import time
+import pytest
+
+from visual_editor_core.execution_engine import ExecutionResult
+from visual_editor_core.snippet_staging import StagingPhase, SpecResult
+from visual_editor_core.snippet_queue import (
+    SpeculationQueue, Priority, QueueFullError, QueueClosedError,
+)
+
+
+class GatedExecutor:
+    """Records run order; every run waits for `gate` to open."""
+
+    def __init__(self):
+        self.gate = threading.Event()
+        self.started = threading.Event()
+        self.order = []
+
+    def execute(self, code):
+        self.started.set()
+        self.gate.wait(5)
+        self.order.append(code)
+        if code == 'crash':
+            raise RuntimeError('boom')
+        return ExecutionResult(success=code != 'fail', output=code, error=None,
+                               execution_time=0.01)
+
+
+@pytest.fixture
+def go_executor():
+    return GatedExecutor()
+
+
+@pytest.fixture
+def pipeline(make_pipeline, go_executor):
+    return make_pipeline({'go': go_executor})
+
+
+@pytest.fixture
+def queue(pipeline):
+    q = SpeculationQueue(pipeline, workers=1, max_queue_depth=3)
+    yield q
+    q.close(timeout=5, drain=False)
+
+
+def _occupy_worker(queue, go_executor):
+    """Stage a snippet and wait until the single worker is stuck on it."""
+    staging_id = queue.stage('i', 'go', 'first', 'First')
+    assert go_executor.started.wait(5)
+    return staging_id
+
+
+class TestStaging:
+
+    def test_stage_returns_before_speculation(self, queue, pipeline, go_executor):
+        staging_id = _occupy_worker(queue, go_executor)
+        assert pipeline.get_snippet(staging_id).phase == StagingPhase.SPECULATING
+        with pytest.raises(TimeoutError):
+            queue.wait_for_result(staging_id, timeout=0.05)
+
+        go_executor.gate.set()
+        assert queue.wait_for_result(staging_id, timeout=5) == SpecResult.PASS
+        assert queue.status(staging_id) == 'done'
+
+    def test_priority_order(self, queue, go_executor):
+        _occupy_worker(queue, go_executor)
+        ids = [queue.stage('i', 'go', code, code, priority=p) for code, p in (
+            ('low', Priority.LOW), ('normal', Priority.NORMAL), ('high', Priority.HIGH))]
+        assert [j['priority'] for j in queue.pending()] == ['high', 'normal', 'low']
+
+        go_executor.gate.set()
+        for staging_id in ids:
+            queue.wait_for_result(staging_id, timeout=5)
+        assert go_executor.order == ['first', 'high', 'normal', 'low']
+
+    def test_auto_promote(self, queue, pipeline, go_executor):
+        go_executor.gate.set()
+        staging_id = queue.stage('i', 'go', 'ok', 'Promoted', auto_promote=True)
+        assert queue.wait_for_result(staging_id, timeout=5) == SpecResult.PASS
+        assert pipeline.get_snippet(staging_id).phase == StagingPhase.PROMOTED
+
+    def test_worker_crash_is_a_fail(self, queue, pipeline, go_executor):
+        go_executor.gate.set()
+        staging_id = queue.stage('i', 'go', 'crash', 'Crash')
+        assert queue.wait_for_result(staging_id, timeout=5) == SpecResult.FAIL
+        assert pipeline.get_snippet(staging_id).phase == StagingPhase.FAILED
+
+    def test_submit_existing_snippet(self, queue, pipeline, go_executor):
+        go_executor.gate.set()
+        snippet = pipeline.queue_snippet('i', 'go', 'fail', 'Later')
+        queue.submit(snippet.staging_id, priority=Priority.HIGH)
+        assert queue.wait_for_result(snippet.staging_id, timeout=5) == SpecResult.FAIL
+
+    def test_unknown_ids(self, queue):
+        with pytest.raises(ValueError):
+            queue.wait_for_result('stg-missing')
+
+
+def test_finished_results_are_bounded(pipeline, go_executor):
+    go_executor.gate.set()
+    queue = SpeculationQueue(pipeline, workers=1, retain_results=2)
+    try:
+        ids = [queue.stage('i', 'go', f'r{n}', f'R{n}') for n in range(4)]
+        for staging_id in ids:
+            queue.wait_for_result(staging_id, timeout=5)
+        assert queue.close(timeout=5)
+        assert sorted(queue._done) == sorted(ids[2:])
+        assert [queue.status(i) for i in ids] == ['unknown', 'unknown', 'done', 'done']
+        # a forgotten id still answers from the pipeline's record
+        assert queue.wait_for_result(ids[0]) == SpecResult.PASS
+    finally:
+        queue.close(timeout=5)
+
+
+class TestBackpressure:
+
+    def test_full_queue_rejects(self, queue, go_executor):
+        _occupy_worker(queue, go_executor)
+        for n in range(3):
+            queue.stage('i', 'go', f'p{n}', f'P{n}')
+        with pytest.raises(QueueFullError):
+            queue.stage('i', 'go', 'overflow', 'Overflow')
+        assert queue.depth == 3
+        with pytest.raises(QueueFullError):
+            queue.stage('i', 'go', 'overflow', 'Overflow', block=True, timeout=0.05)
+
+    def test_blocking_stage_waits_for_a_place(self, queue, go_executor):
+        _occupy_worker(queue, go_executor)
+        for n in range(3):
+            queue.stage('i', 'go', f'p{n}', f'P{n}')
+
+        staged = []
+        blocked = threading.Thread(target=lambda: staged.append(
+            queue.stage('i', 'go', 'late', 'Late', block=True, timeout=5)))
+        blocked.start()
+        time.sleep(0.1)
+        assert staged == []
+
+        go_executor.gate.set()
+        blocked.join(5)
+        assert len(staged) == 1
+        assert queue.wait_for_result(staged[0], timeout=5) == SpecResult.PASS
+
+    def test_invalid_snippet_releases_its_place(self, queue, go_executor):
+        _occupy_worker(queue, go_executor)
+        with pytest.raises(ValueError):
+            queue.stage('', 'cobol', 'x', 'Bad')
+        assert queue.depth == 0
+
+
+class TestClose:
+
+    def test_close_drains_pending(self, pipeline, go_executor):
+        queue = SpeculationQueue(pipeline, workers=2)
+        ids = [queue.stage('i', 'go', f'c{n}', f'C{n}') for n in range(4)]
+        go_executor.gate.set()
+        assert queue.close(timeout=5)
+        assert all(pipeline.get_snippet(i).phase == StagingPhase.PASSED for i in ids)
+        with pytest.raises(QueueClosedError):
+            queue.stage('i', 'go', 'after', 'After')
+
+    def test_close_without_drain_cancels(self, queue, pipeline, go_executor):
+        _occupy_worker(queue, go_executor)
+        pending = queue.stage('i', 'go', 'never', 'Never')
+        go_executor.gate.set()
+        assert queue.close(timeout=5, drain=False)
+        assert queue.wait_for_result(pending, timeout=1) == SpecResult.FAIL
+        assert 'never' not in go_executor.order
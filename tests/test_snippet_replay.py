@@ -0,0 +1,131 @@
+"""
+Test suite for replaying historical promotions.
+
+Tests cover:
+  - ReplayOptions: snapshot name validation, from_dict()
+  - replay(): the stored source at the promoted version, original env and
+    arguments, the slot's isolation level
+  - Slot state untouched: phase, spec_* fields, registry; superseded
+    versions replay their own source
+  - Counted in snippet_replays_total, not snippet_promotions_total; audited
+  - record_as snapshots: per namespace, no duplicates, compare_snapshots()
+  - Refusals: never promoted, payload gone, unknown / other namespace
+"""
+
+import os
+import shutil
+import pytest
+
+from visual_editor_core.execution_engine import GoExecutor, PythonExecutor
+from visual_editor_core.snippet_capacity import SlotConfig
+from visual_editor_core.snippet_metrics import PipelineMetrics, PROMOTIONS, REPLAYS
+from visual_editor_core.snippet_staging import AuditEventType, StagingPhase, SpecResult
+from visual_editor_core.snippet_replay import (
+    ReplayError, ReplayOptions, compare_snapshots,
+)
+
+
+def python_pipeline(make_pipeline, **kwargs):
+    return make_pipeline({'python': PythonExecutor()}, metrics=PipelineMetrics(), **kwargs)
+
+
+def promote(pipeline, label, code, **kwargs):
+    snippet = pipeline.queue_snippet('a', 'python', code, label, **kwargs)
+    pipeline.speculate(snippet.staging_id)
+    assert snippet.spec_result == SpecResult.PASS, snippet.spec_error
+    return pipeline.promote(snippet.staging_id)
+
+
+def test_options():
+    assert ReplayOptions.from_dict({'record_as': ' before '}).record_as == 'before'
+    assert ReplayOptions.from_dict({}).to_dict() == {'record_as': ''}
+    with pytest.raises(ValueError, match='Invalid snapshot name'):
+        ReplayOptions(record_as='no spaces')
+
+
+class TestReplay:
+    def test_replays_the_promoted_version(self, make_pipeline):
+        pipeline = python_pipeline(make_pipeline)
+        v1 = promote(pipeline, 'greet', 'print("v1")\n')
+        phase, output = v1.phase, v1.spec_output
+        v2 = promote(pipeline, 'greet', 'print("v2")\n')
+        assert v1.phase == StagingPhase.SUPERSEDED
+
+        result = pipeline.replay(v1.staging_id, now=100.0)
+        assert result.spec_result == 'PASS' and result.output == 'v1\n'
+        assert result.matches_original and result.code_hash == v1.code_hash
+        assert result.isolation == 'none' and result.replayed_at == 100.0
+        assert v1.phase == StagingPhase.SUPERSEDED and v1.spec_output == output
+        assert v2.phase == StagingPhase.PROMOTED and phase == StagingPhase.PROMOTED
+        assert pipeline.replay(v2.staging_id).output == 'v2\n'
+
+    def test_slot_isolation(self, make_pipeline):
+        pipeline = python_pipeline(make_pipeline,
+                                   slot_configs={'a': SlotConfig(isolation='process')})
+        snippet = promote(pipeline, 'pid', 'import os\nprint(os.getpid())\n')
+        result = pipeline.replay(snippet.staging_id)
+        assert result.isolation == 'process'
+        assert result.output.strip() != str(os.getpid())
+
+    @pytest.mark.skipif(shutil.which('go') is None, reason='go toolchain not installed')
+    def test_original_env_and_arguments(self, make_pipeline):
+        pipeline = make_pipeline({'go': GoExecutor(execution_timeout=60)})
+        snippet = pipeline.queue_snippet(
+            'i', 'go', 'package main\nimport (\n\t"fmt"\n\t"os"\n)\n'
+            'func main() { fmt.Println(os.Getenv("GREETING"), who) }\n', 'hello',
+            parameters=[{'name': 'who', 'type': 'string', 'default': 'world'}],
+            env={'GREETING': 'hello'})
+        pipeline.speculate(snippet.staging_id, arguments={'who': 'gopher'})
+        pipeline.promote(snippet.staging_id)
+        result = pipeline.replay(snippet.staging_id)
+        assert result.output == 'hello gopher\n' and result.matches_original, result.error
+
+    def test_metrics_and_audit(self, make_pipeline):
+        pipeline = python_pipeline(make_pipeline)
+        snippet = promote(pipeline, 'once', 'print(1)\n')
+        pipeline.replay(snippet.staging_id)
+        pipeline.replay(snippet.staging_id)
+        snap = pipeline.metrics.snapshot()
+        assert snap[REPLAYS] == [{'language': 'python', 'slot': 'a', 'spec_result': 'PASS',
+                                  'value': 2}]
+        assert sum(row['value'] for row in snap[PROMOTIONS]) == 1
+        assert 'snippet_replays_total{language="python",slot="a",spec_result="PASS"} 2' in \
+            pipeline.metrics.render_text()
+        events = [e['event'] for e in pipeline.get_audit_trail(snippet.staging_id)]
+        assert events.count(AuditEventType.SNIPPET_REPLAYED.value) == 2
+
+    def test_refusals(self, make_pipeline):
+        pipeline = python_pipeline(make_pipeline)
+        queued = pipeline.queue_snippet('a', 'python', 'print(1)\n', 'queued')
+        with pytest.raises(ReplayError, match='never promoted'):
+            pipeline.replay(queued.staging_id)
+        snippet = promote(pipeline, 'scoped', 'print(2)\n', namespace='team-b')
+        with pytest.raises(ValueError, match='No staged snippet'):
+            pipeline.replay(snippet.staging_id, namespace='default')
+        pipeline._store.delete(snippet.code_hash)
+        with pytest.raises(ReplayError, match='no longer stored'):
+            pipeline.replay(snippet.staging_id)
+
+
+class TestSnapshots:
+    def test_record_and_compare(self, make_pipeline):
+        pipeline = python_pipeline(make_pipeline)
+        snippet = promote(pipeline, 'clock', 'import time\nprint(time.time())\n')
+        before = pipeline.replay(snippet.staging_id, ReplayOptions(record_as='before'), now=1.0)
+        after = pipeline.replay(snippet.staging_id, ReplayOptions(record_as='after'), now=2.0)
+        assert not after.matches_original
+        assert pipeline.replay_snapshot('before') is before
+        assert pipeline.replay_snapshots() == [before, after]
+        assert pipeline.replay_snapshots(staging_id='stg-other') == []
+        assert set(compare_snapshots(before, after)) == {'output'}
+        with pytest.raises(ValueError, match='already exists'):
+            pipeline.replay(snippet.staging_id, ReplayOptions(record_as='before'))
+
+    def test_per_namespace(self, make_pipeline):
+        pipeline = python_pipeline(make_pipeline)
+        snippet = promote(pipeline, 'scoped', 'print(3)\n', namespace='team-b')
+        pipeline.replay(snippet.staging_id, ReplayOptions(record_as='base'))
+        assert pipeline.replay_snapshot('base', 'team-b').staging_id == snippet.staging_id
+        with pytest.raises(ValueError, match='No replay snapshot'):
+            pipeline.replay_snapshot('base')
+        assert pipeline.replay_snapshots() == []
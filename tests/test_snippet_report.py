@@ -0,0 +1,127 @@
+"""
+Test suite for slot health reports.
+
+Tests cover:
+  - percentile() interpolation and linear_slope() on known series
+  - trend_direction(): improving, degrading, stable, too few samples
+  - build_slot_report(): counts, percentiles, slowest / last promoted label, `since`
+  - StagingPipeline.slot_report() end to end, namespace scoping, unknown slots
+"""
+
+import pytest
+
+from visual_editor_core.execution_engine import ExecutionResult
+from visual_editor_core.snippet_history import PromotionRecord
+from visual_editor_core.snippet_staging import StagedSnippet, SpecResult
+from visual_editor_core.snippet_report import (
+    TrendDirection, build_slot_report, linear_slope, percentile, trend_direction,
+)
+
+
+def run(label, seconds, result=SpecResult.PASS, at=100.0, slot='i'):
+    return StagedSnippet(staging_id=f'{label}-{at}', language='go', engine_letter=slot,
+                         label=label, code='', code_hash='', spec_result=result,
+                         spec_execution_time=seconds, spec_completed_at=at)
+
+
+def promotion(label, at, event='promote'):
+    return PromotionRecord(event=event, staging_id=label, slot='i', label=label,
+                           address='i1', code_hash='', timestamp=at)
+
+
+class TestStatistics:
+    def test_percentile(self):
+        values = [4.0, 1.0, 3.0, 2.0, 5.0]
+        assert percentile(values, 50) == 3.0
+        assert percentile(values, 0) == 1.0
+        assert percentile(values, 100) == 5.0
+        assert percentile(values, 95) == pytest.approx(4.8)
+        assert percentile([], 99) == 0.0
+
+    def test_linear_slope(self):
+        assert linear_slope([1.0, 2.0, 3.0, 4.0]) == pytest.approx(1.0)
+        assert linear_slope([5.0, 5.0, 5.0]) == 0.0
+        assert linear_slope([2.0]) == 0.0
+
+    def test_trend(self):
+        assert trend_direction([1.0, 1.2, 1.4, 1.6]) == TrendDirection.DEGRADING
+        assert trend_direction([1.6, 1.4, 1.2, 1.0]) == TrendDirection.IMPROVING
+        assert trend_direction([1.0, 1.02, 0.99, 1.01]) == TrendDirection.STABLE
+        assert trend_direction([1.0, 9.0]) == TrendDirection.STABLE      # too few samples
+
+
+class TestBuildSlotReport:
+    def test_aggregates(self):
+        snippets = [
+            run('fast', 0.1, at=1), run('fast', 0.3, at=2),
+            run('slow', 2.0, SpecResult.FAIL, at=3),
+            run('slow', 4.0, SpecResult.TIMEOUT, at=4),
+            run('other-slot', 9.0, at=5, slot='a'),
+        ]
+        report = build_slot_report('i', 0.0, snippets,
+                                   [promotion('fast', 2), promotion('fast', 3, 'rollback'),
+                                    promotion('slow', 5)])
+        assert report.samples == 4
+        assert (report.pass_count, report.fail_count, report.timeout_count) == (2, 1, 1)
+        assert report.spec_time_p50 == pytest.approx(1.15)
+        assert report.spec_time_p99 == pytest.approx(3.94)
+        assert report.total_promotions == 2
+        assert report.last_promoted_label == 'slow'
+        assert report.slowest_label == 'slow'
+        assert report.slowest_label_avg_time == pytest.approx(3.0)
+        assert report.trend == TrendDirection.DEGRADING
+        assert report.to_dict()['trend'] == 'degrading'
+
+    def test_since_and_window(self):
+        old = [run('svc', 10.0, at=t) for t in range(1, 6)]
+        recent = [run('svc', 1.0 - 0.01 * t, at=100 + t) for t in range(25)]
+        report = build_slot_report('i', 50.0, old + recent, [promotion('svc', 10)])
+        assert report.samples == 25
+        assert report.total_promotions == 0
+        assert report.spec_time_p99 < 1.0
+        assert report.trend == TrendDirection.IMPROVING
+
+    def test_empty(self):
+        report = build_slot_report('i', 0.0, [], [])
+        assert report.samples == 0 and report.slowest_label == ''
+        assert report.trend == TrendDirection.STABLE
+
+
+class TimedExecutor:
+    """Each run reports the next duration in `times`, failing on negative ones."""
+
+    def __init__(self, times):
+        self.times = list(times)
+
+    def execute(self, code):
+        t = self.times.pop(0)
+        return ExecutionResult(success=t >= 0, output='', error=None if t >= 0 else 'boom',
+                               execution_time=abs(t))
+
+
+@pytest.fixture
+def pipeline(make_pipeline):
+    return make_pipeline({'go': TimedExecutor([0.5, -1.5, 1.0])})
+
+
+class TestPipelineSlotReport:
+    def test_report(self, pipeline):
+        a = pipeline.speculate(pipeline.queue_snippet('i', 'go', 'package main // a', 'a').staging_id)
+        pipeline.speculate(pipeline.queue_snippet('i', 'go', 'package main // b', 'b').staging_id)
+        pipeline.speculate(pipeline.queue_snippet('i', 'go', 'package main // c', 'c',
+                                                  namespace='team-c').staging_id)
+        pipeline.promote(a.staging_id)
+
+        report = pipeline.slot_report('i')
+        assert report.samples == 3
+        assert (report.pass_count, report.fail_count) == (2, 1)
+        assert report.spec_time_p50 == pytest.approx(1.0)
+        assert report.last_promoted_label == 'a'
+        assert report.slowest_label == 'b'
+
+        scoped = pipeline.slot_report('i', namespace='team-c')
+        assert scoped.samples == 1 and scoped.total_promotions == 0
+
+    def test_unknown_slot(self, pipeline):
+        with pytest.raises(ValueError, match='Unknown slot'):
+            pipeline.slot_report('zz')
@@ -0,0 +1,188 @@
+"""
+Test suite for revalidating every production snippet.
+
+Tests cover:
+  - RevalidateOptions validation and from_dict()
+  - revalidate_all(): one event per PROMOTED snippet then the summary;
+    slot filter; unknown slot refused; other namespaces and unpromoted
+    snippets left out
+  - Summary counts and the mean spec_time delta
+  - update_metadata overwriting spec_result / spec_execution_time (and
+    leaving them alone without it); SNIPPET_REVALIDATED audited
+  - fail_fast and cancel() skipping the rest; concurrency respected
+  - An executor that raises reported as a failed event
+"""
+
+import threading
+import time
+import pytest
+
+from visual_editor_core.execution_engine import ExecutionResult
+from visual_editor_core.snippet_staging import SpecResult, StagingPhase
+from visual_editor_core.snippet_revalidate import (
+    STOPPED_CANCELLED, STOPPED_FAIL_FAST, RevalidateOptions,
+)
+
+
+class UpgradeExecutor:
+    """Passes everything until `upgraded`; then fails sources containing 'legacy'."""
+
+    def __init__(self, hold=0.0):
+        self.upgraded = False
+        self.hold = hold
+        self.active = self.max_active = 0
+        self._count = threading.Lock()
+
+    def execute(self, code):
+        with self._count:
+            self.active += 1
+            self.max_active = max(self.max_active, self.active)
+        time.sleep(self.hold)
+        with self._count:
+            self.active -= 1
+        if 'boom' in code and self.upgraded:
+            raise RuntimeError('executor crashed')
+        if 'legacy' in code and self.upgraded:
+            return ExecutionResult(success=False, output='', error='undefined: ioutil',
+                                   execution_time=0.5)
+        return ExecutionResult(success=True, output='ok\n', error=None,
+                               execution_time=0.3 if self.upgraded else 0.1)
+
+
+def upgrade_pipeline(make_pipeline, executor=None):
+    executor = executor or UpgradeExecutor()
+    return make_pipeline({'go': executor}), executor
+
+
+def promote(pipeline, label, code=None, slot='i', namespace='default'):
+    snippet = pipeline.queue_snippet(slot, 'go', code or f'package main // {label}\n', label,
+                                     namespace=namespace)
+    pipeline.speculate(snippet.staging_id)
+    return pipeline.promote(snippet.staging_id)
+
+
+def collect(run):
+    events = list(run)
+    return events[:-1], events[-1].summary
+
+
+class TestOptions:
+    @pytest.mark.parametrize('concurrency', [0, 65])
+    def test_concurrency_bounds(self, concurrency):
+        with pytest.raises(ValueError, match='concurrency'):
+            RevalidateOptions(concurrency=concurrency)
+
+    def test_from_dict(self):
+        options = RevalidateOptions.from_dict({'concurrency': '2', 'fail_fast': True})
+        assert options.to_dict() == {'concurrency': 2, 'fail_fast': True,
+                                     'update_metadata': False}
+
+
+class TestRevalidateAll:
+    def test_events_and_summary(self, make_pipeline):
+        pipeline, executor = upgrade_pipeline(make_pipeline)
+        ok = promote(pipeline, 'ok')
+        legacy = promote(pipeline, 'legacy', 'package main // legacy\n')
+        queued = pipeline.queue_snippet('i', 'go', 'package main // queued\n', 'queued')
+        executor.upgraded = True
+
+        results, summary = collect(pipeline.revalidate_all())
+        by_id = {e.staging_id: e for e in results}
+        assert set(by_id) == {ok.staging_id, legacy.staging_id}
+        assert queued.staging_id not in by_id
+        assert by_id[ok.staging_id].spec_result == 'PASS'
+        assert by_id[legacy.staging_id].spec_result == 'FAIL'
+        assert by_id[legacy.staging_id].original_result == 'PASS'
+        assert by_id[legacy.staging_id].error == 'undefined: ioutil'
+        assert sorted(e.completed for e in results) == [1, 2]
+        assert (summary.total, summary.passed, summary.failed, summary.skipped) == (2, 1, 1, 0)
+        assert summary.mean_spec_time_delta == pytest.approx(0.3)   # (0.2 + 0.4) / 2
+        assert summary.stopped == '' and summary.finished_at >= summary.started_at
+
+    def test_metadata_untouched_by_default(self, make_pipeline):
+        pipeline, executor = upgrade_pipeline(make_pipeline)
+        legacy = promote(pipeline, 'legacy', 'package main // legacy\n')
+        executor.upgraded = True
+        run = pipeline.revalidate_all()
+        assert run.wait(10).failed == 1
+        assert legacy.spec_result == SpecResult.PASS and legacy.spec_execution_time == 0.1
+        assert legacy.phase == StagingPhase.PROMOTED
+        entry = [e for e in pipeline.get_audit_trail(legacy.staging_id)
+                 if e['event'] == 'snippet_revalidated'][0]['data']
+        assert entry['spec_result'] == 'FAIL' and entry['updated'] is False
+
+    def test_update_metadata(self, make_pipeline):
+        pipeline, executor = upgrade_pipeline(make_pipeline)
+        legacy = promote(pipeline, 'legacy', 'package main // legacy\n')
+        executor.upgraded = True
+        results, _ = collect(pipeline.revalidate_all(
+            options=RevalidateOptions(update_metadata=True)))
+        assert results[0].updated
+        assert legacy.spec_result == SpecResult.FAIL and legacy.spec_execution_time == 0.5
+        assert legacy.phase == StagingPhase.PROMOTED               # Still live
+
+    def test_slot_filter(self, make_pipeline):
+        pipeline, _ = upgrade_pipeline(make_pipeline)
+        on_i = promote(pipeline, 'a')
+        promote(pipeline, 'b', slot='j')
+        results, summary = collect(pipeline.revalidate_all('i'))
+        assert [e.staging_id for e in results] == [on_i.staging_id] and summary.total == 1
+        assert results[0].slot == 'i' and results[0].address == on_i.reserved_address
+        with pytest.raises(ValueError, match="Unknown slot 'zz'"):
+            pipeline.revalidate_all('zz')
+
+    def test_namespace(self, make_pipeline):
+        pipeline, _ = upgrade_pipeline(make_pipeline)
+        promote(pipeline, 'a')
+        theirs = promote(pipeline, 'b', namespace='team-b')
+        results, _ = collect(pipeline.revalidate_all(namespace='team-b'))
+        assert [e.staging_id for e in results] == [theirs.staging_id]
+
+    def test_nothing_to_run(self, make_pipeline):
+        pipeline, _ = upgrade_pipeline(make_pipeline)
+        events = list(pipeline.revalidate_all())
+        assert len(events) == 1 and events[0].summary.total == 0
+        assert events[0].to_dict()['event'] == 'summary'
+
+    def test_executor_error(self, make_pipeline):
+        pipeline, executor = upgrade_pipeline(make_pipeline)
+        boom = promote(pipeline, 'boom', 'package main // boom\n')
+        executor.upgraded = True
+        results, summary = collect(pipeline.revalidate_all())
+        assert results[0].success is False and summary.failed == 1
+        assert results[0].spec_result == '' and results[0].error == 'executor crashed'
+        assert results[0].spec_time_delta == 0.0 and summary.mean_spec_time_delta == 0.0
+        assert boom.phase == StagingPhase.PROMOTED
+        errors = [e['data'] for e in pipeline.get_audit_trail(boom.staging_id)
+                  if e['event'] == 'error']
+        assert errors[-1]['step'] == 'revalidate'
+
+
+class TestStopping:
+    def test_fail_fast(self, make_pipeline):
+        pipeline, executor = upgrade_pipeline(make_pipeline)
+        promote(pipeline, 'legacy', 'package main // legacy\n')
+        for n in range(5):
+            promote(pipeline, f'ok{n}')
+        executor.upgraded = True
+        results, summary = collect(pipeline.revalidate_all(
+            options=RevalidateOptions(concurrency=1, fail_fast=True)))
+        assert summary.stopped == STOPPED_FAIL_FAST and summary.failed == 1
+        assert summary.skipped == 6 - len(results) > 0
+
+    def test_cancel(self, make_pipeline):
+        pipeline, executor = upgrade_pipeline(make_pipeline, UpgradeExecutor(hold=0.05))
+        for n in range(6):
+            promote(pipeline, f'ok{n}')
+        run = pipeline.revalidate_all(options=RevalidateOptions(concurrency=1))
+        run.cancel()
+        summary = run.wait(10)
+        assert summary.stopped == STOPPED_CANCELLED and summary.skipped >= 5
+
+    def test_concurrency(self, make_pipeline):
+        pipeline, executor = upgrade_pipeline(make_pipeline, UpgradeExecutor(hold=0.05))
+        for n in range(6):
+            promote(pipeline, f'ok{n}')
+        executor.max_active = 0
+        summary = pipeline.revalidate_all(options=RevalidateOptions(concurrency=2)).wait(10)
+        assert summary.passed == 6 and executor.max_active == 2
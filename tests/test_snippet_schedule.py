@@ -0,0 +1,199 @@
+"""
+Test suite for scheduled reruns of promoted snippets.
+
+Tests cover:
+  - CronSchedule.parse(): steps, ranges, lists, names, shorthands, bad expressions
+  - next_after() / matches(), including the day-of-month OR day-of-week rule
+  - due_for_rerun() and SlotConfig.schedule validation
+  - run_scheduled_reruns(): only promoted snippets on scheduled slots, once per firing;
+    phase and spec_* fields untouched, last_rerun_at and RerunHistory updated
+  - The history is a bounded ring buffer
+  - PASS → FAIL raises a HEALTH_ALERT audit entry and webhook; FAIL → FAIL doesn't
+  - RerunScheduler.tick() and status()
+"""
+
+import json
+import pytest
+from datetime import datetime, timezone
+
+from visual_editor_core.execution_engine import ExecutionResult
+from visual_editor_core.snippet_capacity import SlotConfig
+from visual_editor_core.snippet_staging import StagingPhase, PhaseError
+from visual_editor_core.snippet_webhooks import WebhookDispatcher
+from visual_editor_core.snippet_schedule import (
+    CronError, CronSchedule, RerunHistory, RerunRecord, RerunScheduler, due_for_rerun,
+)
+
+
+def utc(*args) -> float:
+    return datetime(*args, tzinfo=timezone.utc).timestamp()
+
+
+class ToggleExecutor:
+    """Passes until `passing` is switched off."""
+
+    def __init__(self):
+        self.passing = True
+        self.runs = 0
+
+    def execute(self, code):
+        self.runs += 1
+        return ExecutionResult(success=self.passing, output='',
+                               error=None if self.passing else 'upstream is down',
+                               execution_time=0.01)
+
+
+def promote(pipeline, label, promoted_at, letter='i'):
+    snippet = pipeline.queue_snippet(letter, 'go', f'package main // {label}', label)
+    pipeline.speculate(snippet.staging_id)
+    promoted = pipeline.promote(snippet.staging_id)
+    promoted.promoted_at = promoted_at
+    return promoted
+
+
+class TestCronSchedule:
+    def test_parse(self):
+        cron = CronSchedule.parse('*/15 9-17 1,15 * mon-fri')
+        assert cron.minutes == {0, 15, 30, 45}
+        assert cron.hours == set(range(9, 18))
+        assert cron.days == {1, 15} and cron.weekdays == {1, 2, 3, 4, 5}
+        assert CronSchedule.parse('0 0 * jan,jul 7').weekdays == {0}
+        assert CronSchedule.parse('5/20 * * * *').minutes == {5, 25, 45}
+        assert CronSchedule.parse('@daily').minutes == {0}
+        for bad in ('', '* * * *', '60 * * * *', '* * * 13 *', '5-1 * * * *',
+                    '*/0 * * * *', 'x * * * *', '@often'):
+            with pytest.raises(CronError):
+                CronSchedule.parse(bad)
+
+    def test_next_after(self):
+        noon = utc(2026, 3, 2, 12, 0)                            # a Monday
+        assert CronSchedule.parse('*/15 * * * *').next_after(noon) == utc(2026, 3, 2, 12, 15)
+        assert CronSchedule.parse('*/15 * * * *').next_after(noon + 1) == utc(2026, 3, 2, 12, 15)
+        assert CronSchedule.parse('0 9 * * *').next_after(noon) == utc(2026, 3, 3, 9, 0)
+        assert CronSchedule.parse('0 0 1 * *').next_after(utc(2026, 12, 5)) == utc(2027, 1, 1)
+        assert CronSchedule.parse('0 0 29 2 *').next_after(noon) == utc(2028, 2, 29)
+        assert CronSchedule.parse('0 0 31 2 *').next_after(noon) is None
+        assert CronSchedule.parse('30 12 * * *').matches(utc(2026, 3, 2, 12, 30, 59))
+
+    def test_day_fields_or(self):
+        # Restricted day of month and day of week: either one fires
+        cron = CronSchedule.parse('0 0 13 * fri')
+        assert cron.next_after(utc(2026, 3, 1)) == utc(2026, 3, 6)       # first Friday
+        assert cron.next_after(utc(2026, 3, 12, 1)) == utc(2026, 3, 13)  # the 13th
+        assert CronSchedule.parse('0 0 * * fri').next_after(utc(2026, 3, 1)) == utc(2026, 3, 6)
+
+    def test_due(self):
+        cron = CronSchedule.parse('0 * * * *')
+        assert due_for_rerun(cron, utc(2026, 1, 1, 10, 30), utc(2026, 1, 1, 11, 0))
+        assert not due_for_rerun(cron, utc(2026, 1, 1, 11, 0), utc(2026, 1, 1, 11, 59))
+
+    def test_slot_config(self):
+        assert SlotConfig(schedule=' @hourly ').to_dict()['schedule'] == '@hourly'
+        assert SlotConfig().cron is None
+        with pytest.raises(ValueError, match='Bad schedule'):
+            SlotConfig(schedule='61 * * * *')
+
+
+def test_history_is_bounded():
+    history = RerunHistory(size=3)
+    for n in range(5):
+        history.append(RerunRecord('stg', float(n), 'PASS', 0.1, True))
+    assert [r.ran_at for r in history.records()] == [2.0, 3.0, 4.0]
+    assert history.last().ran_at == 4.0 and len(history) == 3
+    with pytest.raises(ValueError):
+        RerunHistory(size=0)
+
+
+class TestScheduledReruns:
+    def test_reruns_due_snippets(self, make_pipeline):
+        executor = ToggleExecutor()
+        pipeline = make_pipeline({'go': executor})
+        pipeline.configure_slot('i', SlotConfig(schedule='0 * * * *'))
+        live = promote(pipeline, 'svc', utc(2026, 1, 1, 10, 30))
+        unscheduled = promote(pipeline, 'other', utc(2026, 1, 1, 10, 30), letter='j')
+        runs = executor.runs
+
+        assert pipeline.run_scheduled_reruns(now=utc(2026, 1, 1, 10, 59)) == []
+        (record,) = pipeline.run_scheduled_reruns(now=utc(2026, 1, 1, 11, 0, 30))
+        assert record.staging_id == live.staging_id and record.spec_result == 'PASS'
+        assert executor.runs == runs + 1
+        assert live.last_rerun_at == utc(2026, 1, 1, 11, 0, 30)
+        assert live.phase == StagingPhase.PROMOTED
+        assert unscheduled.last_rerun_at == 0.0
+
+        # Once per firing
+        assert pipeline.run_scheduled_reruns(now=utc(2026, 1, 1, 11, 30)) == []
+        assert len(pipeline.run_scheduled_reruns(now=utc(2026, 1, 1, 12, 0))) == 1
+        assert [r.ran_at for r in pipeline.rerun_history(live.staging_id)] == [
+            utc(2026, 1, 1, 11, 0, 30), utc(2026, 1, 1, 12, 0)]
+        entry = [e for e in pipeline.get_audit_trail(live.staging_id)
+                 if e['event'] == 'snippet_rerun'][-1]
+        assert entry['data']['spec_result'] == 'PASS'
+
+    def test_history_size(self, make_pipeline):
+        pipeline = make_pipeline({'go': ToggleExecutor()}, rerun_history_size=2)
+        live = promote(pipeline, 'svc', 0.0)
+        for n in range(4):
+            pipeline.rerun_snippet(live.staging_id, now=float(n))
+        assert [r.ran_at for r in pipeline.rerun_history(live.staging_id)] == [2.0, 3.0]
+
+    def test_only_live_snippets(self, make_pipeline):
+        pipeline = make_pipeline({'go': ToggleExecutor()})
+        queued = pipeline.queue_snippet('i', 'go', 'package main', 'svc')
+        with pytest.raises(PhaseError, match='PROMOTED'):
+            pipeline.rerun_snippet(queued.staging_id)
+        with pytest.raises(ValueError, match='No staged snippet'):
+            pipeline.rerun_history('stg-missing')
+        live = promote(pipeline, 'other', 0.0)
+        with pytest.raises(ValueError, match='No staged snippet'):
+            pipeline.rerun_snippet(live.staging_id, namespace='team-b')
+
+    def test_health_alert(self, tmp_path, make_pipeline):
+        sent = []
+        webhooks = WebhookDispatcher(str(tmp_path / 'webhooks.jsonl'), base_delay=0.0,
+                                     transport=lambda url, body, headers, timeout:
+                                     sent.append(json.loads(body)) or 200)
+        webhooks.add_target('http://hooks.example/a', 's', events=['health_alert'])
+        executor = ToggleExecutor()
+        pipeline = make_pipeline({'go': executor}, webhooks=webhooks)
+        live = promote(pipeline, 'svc', 0.0)
+
+        assert not pipeline.rerun_snippet(live.staging_id, now=1.0).health_alert
+        executor.passing = False
+        failed = pipeline.rerun_snippet(live.staging_id, now=2.0)
+        assert failed.health_alert and failed.spec_result == 'FAIL'
+        assert failed.error == 'upstream is down'
+        assert not pipeline.rerun_snippet(live.staging_id, now=3.0).health_alert
+        assert live.spec_result.value == 'PASS'                  # Spec fields untouched
+
+        webhooks.dispatch_due()
+        assert len(sent) == 1
+        payload = sent[0]
+        assert payload['event'] == 'health_alert' and payload['staging_id'] == live.staging_id
+        assert payload['spec_result'] == 'FAIL' and payload['previous_result'] == 'PASS'
+        alerts = [e for e in pipeline.get_audit_trail(live.staging_id)
+                  if e['event'] == 'health_alert']
+        assert len(alerts) == 1 and alerts[0]['data']['label'] == 'svc'
+
+    def test_first_rerun_compares_with_speculation(self, make_pipeline):
+        executor = ToggleExecutor()
+        pipeline = make_pipeline({'go': executor})
+        live = promote(pipeline, 'svc', 0.0)
+        executor.passing = False
+        assert pipeline.rerun_snippet(live.staging_id, now=1.0).health_alert
+
+
+def test_scheduler(make_pipeline):
+    now = [utc(2026, 1, 1, 10, 30)]
+    pipeline = make_pipeline({'go': ToggleExecutor()})
+    pipeline.configure_slot('i', SlotConfig(schedule='@hourly'))
+    promote(pipeline, 'svc', now[0])
+    scheduler = RerunScheduler(pipeline, interval=60, clock=lambda: now[0])
+    assert scheduler.tick() == []
+    now[0] = utc(2026, 1, 1, 11, 0)
+    assert len(scheduler.tick()) == 1
+    status = scheduler.status()
+    assert status['reruns_total'] == 1 and status['last_tick_at'] == now[0]
+    assert not status['running']
+    with pytest.raises(ValueError):
+        RerunScheduler(pipeline, interval=0)
@@ -0,0 +1,149 @@
+"""
+Test suite for planning a slot's state after a set of promotions.
+
+Tests cover:
+  - slot_diff(): added / updated / unchanged labels and the byte delta
+  - An identical source counts as unchanged; the later of two pending
+    snippets with one label wins
+  - Requires that would close a cycle (introduces_cycle, cycle) and
+    labels left missing
+  - Refusals: unknown slot, unknown or repeated ID, another slot or
+    namespace, already promoted, REJECT label that would be live
+  - Read-only: no phase changes and no promotion lock taken
+"""
+
+import pytest
+
+from visual_editor_core.snippet_staging import (
+    LabelConflictError, LabelConflictPolicy, PhaseError, StagingPhase,
+)
+from visual_editor_core.snippet_lock import LocalLocker
+
+
+class CountingLocker(LocalLocker):
+    def __init__(self):
+        super().__init__()
+        self.locks = 0
+
+    def lock(self, *args, **kwargs):
+        self.locks += 1
+        return super().lock(*args, **kwargs)
+
+
+def source(body):
+    return f'package main\n\nfunc main() {{\n\t// {body}\n}}\n'
+
+
+def stage(pipeline, label, body=None, slot='i', **kwargs):
+    snippet = pipeline.queue_snippet(slot, 'go', source(body or label), label, **kwargs)
+    pipeline.speculate(snippet.staging_id)
+    return snippet
+
+
+def promote(pipeline, label, body=None, **kwargs):
+    return pipeline.promote(stage(pipeline, label, body, **kwargs).staging_id)
+
+
+class TestSlotDiff:
+    def test_labels_and_size(self, make_pipeline):
+        pipeline = make_pipeline()
+        promote(pipeline, 'fib')
+        promote(pipeline, 'mathutils')
+        fib = stage(pipeline, 'fib', 'fib, faster')
+        report_ = stage(pipeline, 'report')
+        same = stage(pipeline, 'mathutils')             # Identical source
+
+        report = pipeline.slot_diff('i', [fib.staging_id, report_.staging_id, same.staging_id])
+        assert report.added == ['report']
+        assert report.updated == ['fib']
+        assert report.unchanged == ['mathutils']
+        assert report.removed == []
+        assert report.size_delta == len(source('report')) + len(', faster')
+        assert report.size_after - report.size_before == report.size_delta
+        d = report.to_dict()
+        assert d['pending_ids'] == [fib.staging_id, report_.staging_id, same.staging_id]
+        assert [(l['label'], l['change']) for l in d['labels']] == [
+            ('fib', 'updated'), ('mathutils', 'unchanged'), ('report', 'added')]
+        assert d['labels'][0]['after_id'] == fib.staging_id
+
+    def test_empty_plan(self, make_pipeline):
+        pipeline = make_pipeline()
+        promote(pipeline, 'fib')
+        report = pipeline.slot_diff('i', [])
+        assert report.unchanged == ['fib'] and report.size_delta == 0
+
+    def test_later_pending_wins(self, make_pipeline):
+        pipeline = make_pipeline()
+        first = stage(pipeline, 'fib', 'one')
+        second = stage(pipeline, 'fib', 'two, longer')
+        report = pipeline.slot_diff('i', [first.staging_id, second.staging_id])
+        assert report.added == ['fib'] and report.labels['fib'].after_id == second.staging_id
+        assert report.size_after == len(source('two, longer'))
+
+    def test_cycle(self, make_pipeline):
+        pipeline = make_pipeline()
+        promote(pipeline, 'fib')
+        promote(pipeline, 'report')
+        # Each staged against the other's current (dependency-free) version
+        fib = stage(pipeline, 'fib', 'fib v2', requires=['report'])
+        report_ = stage(pipeline, 'report', 'report v2', requires=['fib'])
+        assert not pipeline.slot_diff('i', [fib.staging_id]).introduces_cycle
+
+        report = pipeline.slot_diff('i', [fib.staging_id, report_.staging_id])
+        assert report.introduces_cycle
+        assert report.cycle in (['fib', 'report', 'fib'], ['report', 'fib', 'report'])
+        assert report.to_dict()['cycle'] == report.cycle and report.existing_cycle == []
+
+    def test_missing(self, make_pipeline):
+        pipeline = make_pipeline()
+        promote(pipeline, 'mathutils')
+        fib = stage(pipeline, 'fib', requires=['mathutils'])
+        pipeline._live_labels('i', 'default')['mathutils'].requires = ['gone']
+        report = pipeline.slot_diff('i', [fib.staging_id])
+        assert report.missing == ['gone'] and not report.introduces_cycle
+
+
+class TestRefusals:
+    def test_bad_ids(self, make_pipeline):
+        pipeline = make_pipeline()
+        fib = stage(pipeline, 'fib')
+        other_slot = stage(pipeline, 'fib', slot='j')
+        theirs = stage(pipeline, 'fib', namespace='team-b')
+        with pytest.raises(ValueError, match="Unknown slot 'zz'"):
+            pipeline.slot_diff('zz', [fib.staging_id])
+        with pytest.raises(ValueError, match="No staged snippet 'stg-nope'"):
+            pipeline.slot_diff('i', ['stg-nope'])
+        with pytest.raises(ValueError, match='only once'):
+            pipeline.slot_diff('i', [fib.staging_id, fib.staging_id])
+        with pytest.raises(ValueError, match="slot 'j'"):
+            pipeline.slot_diff('i', [other_slot.staging_id])
+        with pytest.raises(ValueError, match="No staged snippet"):
+            pipeline.slot_diff('i', [theirs.staging_id], namespace='default')
+        with pytest.raises(ValueError, match="namespace 'team-b'"):
+            pipeline.slot_diff('i', [theirs.staging_id])          # Unscoped: default slot
+
+    def test_promoted(self, make_pipeline):
+        pipeline = make_pipeline()
+        live = promote(pipeline, 'fib')
+        with pytest.raises(PhaseError, match='not yet promoted'):
+            pipeline.slot_diff('i', [live.staging_id])
+
+    def test_reject_label(self, make_pipeline):
+        pipeline = make_pipeline()
+        first = stage(pipeline, 'fib', 'one')
+        pipeline.set_label_policy('i', LabelConflictPolicy.REJECT)
+        second = stage(pipeline, 'fib', 'two')
+        with pytest.raises(LabelConflictError, match="would already be live"):
+            pipeline.slot_diff('i', [first.staging_id, second.staging_id])
+
+
+def test_read_only(make_pipeline):
+    locker = CountingLocker()
+    pipeline = make_pipeline(locker=locker)
+    promote(pipeline, 'fib')
+    pending = stage(pipeline, 'fib', 'fib v2')
+    locks = locker.locks
+    pipeline.slot_diff('i', [pending.staging_id])
+    assert locker.locks == locks
+    assert pending.phase == StagingPhase.PASSED
+    assert pipeline.slot_diff('i', [pending.staging_id]).updated == ['fib']
@@ -0,0 +1,121 @@
+"""
+Test suite for the SQLite snippet metadata index.
+
+Tests cover:
+  - Migrations applied once, in order; an older database is upgraded
+  - A failing migration rolls back and leaves the prior version
+  - Records survive reopening the database (and a new pipeline)
+  - Persisted columns track speculation and promotion
+  - CRUD: put / get / remove
+
+The filter and pagination behaviour shared with the in-memory index is
+covered by test_snippet_query.py, which runs against both.
+"""
+
+import shutil
+import sqlite3
+import pytest
+
+from visual_editor_core.snippet_query import SnippetFilter
+from visual_editor_core.snippet_sqlite import (
+    SQLiteSnippetIndex, SnippetRecord, MigrationError, MIGRATIONS_DIR, load_migrations,
+)
+
+
+@pytest.fixture
+def db_path(tmp_path):
+    return str(tmp_path / 'index.db')
+
+
+class TestMigrations:
+
+    def test_fresh_database_at_latest_version(self, db_path):
+        index = SQLiteSnippetIndex(db_path)
+        assert index.schema_version() == load_migrations()[-1][0]
+        index.close()
+        assert SQLiteSnippetIndex(db_path).schema_version() == load_migrations()[-1][0]
+
+    def test_older_database_upgraded(self, tmp_path, db_path):
+        v1_only = tmp_path / 'v1'
+        v1_only.mkdir()
+        shutil.copy(f'{MIGRATIONS_DIR}/0001_initial.sql', v1_only)
+        SQLiteSnippetIndex(db_path, migrations_dir=str(v1_only)).close()
+
+        index = SQLiteSnippetIndex(db_path)
+        assert index.schema_version() == load_migrations()[-1][0]
+        indexes = {row[0] for row in index._conn.execute(
+            "SELECT name FROM sqlite_master WHERE type = 'index'")}
+        assert 'idx_snippets_cursor' in indexes
+
+    def test_failed_migration_rolls_back(self, tmp_path, db_path):
+        broken = tmp_path / 'broken'
+        broken.mkdir()
+        shutil.copy(f'{MIGRATIONS_DIR}/0001_initial.sql', broken)
+        (broken / '0002_bad.sql').write_text(
+            'CREATE TABLE half_done (x INTEGER);\nALTER TABLE nope ADD COLUMN y;\n')
+        with pytest.raises(MigrationError):
+            SQLiteSnippetIndex(db_path, migrations_dir=str(broken))
+
+        conn = sqlite3.connect(db_path)
+        assert [r[0] for r in conn.execute('SELECT version FROM schema_migrations')] == [1]
+        assert conn.execute("SELECT COUNT(*) FROM sqlite_master "
+                            "WHERE name = 'half_done'").fetchone()[0] == 0
+
+
+class TestPersistence:
+
+    def test_records_survive_restart(self, make_pipeline, db_path):
+        pipeline = make_pipeline({}, snippet_index=SQLiteSnippetIndex(db_path))
+        promoted = pipeline.run_full_pipeline('a', 'python', 'x = 1', 'Kept')
+        pipeline.queue_snippet('a', 'python', 'x = 2', 'Queued')
+
+        reopened = make_pipeline({}, snippet_index=SQLiteSnippetIndex(db_path))
+        page = reopened.query(SnippetFilter(label='kept'))
+        [record] = page.snippets
+        assert isinstance(record, SnippetRecord)
+        assert record.staging_id == promoted.staging_id
+        assert record.reserved_address == promoted.reserved_address
+        assert record.engine == promoted.reserved_engine
+        assert record.spec_result == 'PASS'
+        assert record.promoted_at == promoted.promoted_at
+        assert record.source_path == promoted.saved_file_path
+        assert record.spec_time_ms is not None
+        assert len(reopened.query(SnippetFilter()).snippets) == 2
+
+    def test_live_snippets_returned_while_held(self, make_pipeline, db_path):
+        pipeline = make_pipeline({}, snippet_index=SQLiteSnippetIndex(db_path))
+        snippet = pipeline.queue_snippet('a', 'python', 'x = 1', 'Live')
+        assert pipeline.query(SnippetFilter()).snippets == [snippet]
+
+    def test_unspeculated_columns_are_null(self, make_pipeline, db_path):
+        index = SQLiteSnippetIndex(db_path)
+        snippet = make_pipeline({}, snippet_index=index).queue_snippet('a', 'python', 'x = 1', 'New')
+        record = index.get(snippet.staging_id)
+        assert record.promoted_at is None and record.spec_time_ms is None
+        assert record.language == 'python' and record.slot == 'a'
+
+
+class TestCrud:
+
+    def test_put_get_update_remove(self, db_path):
+        index = SQLiteSnippetIndex(db_path)
+        record = SnippetRecord('stg-1', 'go', 'GO', 'i', 3, 'Fib', 'ab' * 32, 10.0)
+        index.put(record)
+        assert index.get('stg-1') == record
+
+        record.spec_result, record.spec_time_ms = 'PASS', 12
+        index.put(record)
+        assert index.get('stg-1').spec_result == 'PASS'
+        assert len(index) == 1
+
+        index.remove('stg-1')
+        index.remove('missing')
+        assert index.get('stg-1') is None and len(index) == 0
+
+    def test_languages_and_slots_are_shared_rows(self, db_path):
+        index = SQLiteSnippetIndex(db_path)
+        for n in range(3):
+            index.put(SnippetRecord(f'stg-{n}', 'go', 'GO', 'i', 1, 'Fib', 'ab' * 32, float(n)))
+        conn = index._conn
+        assert conn.execute('SELECT COUNT(*) FROM languages').fetchone()[0] == 1
+        assert conn.execute('SELECT COUNT(*) FROM slots').fetchone()[0] == 1
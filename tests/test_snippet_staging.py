@@ -0,0 +1,607 @@
+"""
+Test suite for the Snippet Staging Pipeline.
+
+Tests cover:
+  - Concurrent batch speculation (SnippetExecutionPool)
+  - Crash / timeout isolation inside a batch, stragglers killed on timeout
+  - Content-addressable payload storage on promotion
+  - Atomic batch promotion with rollback; webhooks and metrics only on commit
+  - TIMEOUT spec results
+  - Rollback to the previously promoted version
+  - Label conflict policies (reject / overwrite / version suffix)
+  - Slot capacity limits and eviction
+  - Pre-promotion lint gate (LINT_FAIL, skip_lint override)
+"""
+
+import os
+import time
+import threading
+import pytest
+
+from visual_editor_core.execution_engine import ExecutionResult
+from visual_editor_core.snippet_staging import (
+    StagingPhase, SpecResult, BatchPromotionError,
+    LabelConflictPolicy, LabelConflictError,
+)
+from visual_editor_core.snippet_lint import (
+    SnippetLinter, LintResult, LintDiagnostic, LintFailedError,
+)
+from visual_editor_core.snippet_metrics import PipelineMetrics, PROMOTIONS
+from visual_editor_core.snippet_webhooks import WebhookDispatcher
+from visual_editor_core.snippet_capacity import (
+    SlotConfig, EvictionPolicy, SlotFullError, SlotEvictionError,
+)
+
+
+# =============================================================================
+# FIXTURES
+# =============================================================================
+
+class FakeExecutor:
+    """Subprocess-style executor stand-in.
+
+    Source directives (one per line) control behaviour:
+        sleep <seconds>   — block for that long
+        fail              — return an unsuccessful result
+        timeout           — return a result killed by the deadline
+        crash             — raise from execute()
+    Anything else is echoed back as output.
+    """
+
+    def __init__(self):
+        self.calls = []
+        self._lock = threading.Lock()
+
+    def execute(self, code: str, capture_output: bool = True) -> ExecutionResult:
+        with self._lock:
+            self.calls.append(code)
+        start = time.time()
+        for line in code.splitlines():
+            parts = line.split()
+            if parts[:1] == ['sleep']:
+                time.sleep(float(parts[1]))
+            elif parts[:1] == ['crash']:
+                raise RuntimeError('executor blew up')
+            elif parts[:1] == ['fail']:
+                return ExecutionResult(success=False, error=Exception('spec failed'),
+                                       execution_time=time.time() - start)
+            elif parts[:1] == ['timeout']:
+                return ExecutionResult(success=False, error=Exception('timed out after 10s'),
+                                       execution_time=10.0, timed_out=True)
+        return ExecutionResult(success=True, output=code,
+                               execution_time=time.time() - start)
+
+
+class CancellableExecutor(FakeExecutor):
+    """FakeExecutor whose `sleep` ends early when cancel_event is set (like Go's)."""
+
+    cancellable = True
+
+    def __init__(self):
+        super().__init__()
+        self.cancelled = []
+
+    def execute(self, code: str, capture_output: bool = True,
+                cancel_event=None) -> ExecutionResult:
+        parts = code.split()
+        if parts[:1] == ['sleep'] and cancel_event is not None:
+            if cancel_event.wait(float(parts[1])):
+                self.cancelled.append(code)
+                return ExecutionResult(success=False, error=Exception('cancelled'),
+                                       cancelled=True)
+            return ExecutionResult(success=True, output=code)
+        return super().execute(code, capture_output)
+
+
+@pytest.fixture
+def go_executor():
+    return FakeExecutor()
+
+
+@pytest.fixture
+def pipeline(make_pipeline, go_executor):
+    return make_pipeline({'go': go_executor})
+
+
+def _queue(pipeline, code, label=''):
+    return pipeline.queue_snippet('i', 'go', code, label)
+
+
+# =============================================================================
+# BATCH SPECULATION
+# =============================================================================
+
+class TestSpeculateBatch:
+
+    def test_runs_concurrently(self, pipeline):
+        ids = [_queue(pipeline, 'sleep 0.3').staging_id for _ in range(4)]
+        start = time.time()
+        results = pipeline.speculate_batch(ids, max_workers=4)
+        elapsed = time.time() - start
+        assert all(s.phase == StagingPhase.PASSED for s in results)
+        assert elapsed < 0.9  # serial would be >= 1.2s
+
+    def test_respects_concurrency_limit(self, pipeline):
+        ids = [_queue(pipeline, 'sleep 0.2').staging_id for _ in range(4)]
+        start = time.time()
+        pipeline.speculate_batch(ids, max_workers=2)
+        assert time.time() - start >= 0.4
+
+    def test_results_in_slot_order(self, pipeline):
+        ids = [_queue(pipeline, f'sleep {d}').staging_id for d in (0.2, 0.0, 0.1)]
+        results = pipeline.speculate_batch(ids, max_workers=3)
+        positions = [s.reserved_position for s in results]
+        assert positions == sorted(positions)
+        assert [s.staging_id for s in results] == ids
+
+    def test_crash_becomes_fail(self, pipeline):
+        ok = _queue(pipeline, 'hello').staging_id
+        bad = _queue(pipeline, 'crash').staging_id
+        results = {s.staging_id: s for s in pipeline.speculate_batch([ok, bad])}
+        assert results[ok].phase == StagingPhase.PASSED
+        assert results[bad].phase == StagingPhase.FAILED
+        assert 'executor blew up' in results[bad].spec_error
+
+    def test_timeout_does_not_block_batch(self, pipeline):
+        slow = _queue(pipeline, 'sleep 2').staging_id
+        fast = _queue(pipeline, 'hello').staging_id
+        start = time.time()
+        results = {s.staging_id: s for s in
+                   pipeline.speculate_batch([slow, fast], timeout_per_snippet=0.2)}
+        assert time.time() - start < 1.0
+        assert results[fast].phase == StagingPhase.PASSED
+        assert results[slow].phase == StagingPhase.FAILED
+        assert results[slow].spec_result == SpecResult.TIMEOUT
+        assert 'deadline' in results[slow].spec_error
+
+    def test_timeout_kills_cancellable_run(self, make_pipeline):
+        executor = CancellableExecutor()
+        pipeline = make_pipeline({'go': executor})
+        slow = _queue(pipeline, 'sleep 2').staging_id
+        fast = _queue(pipeline, 'hello').staging_id
+        start = time.time()
+        results = {s.staging_id: s for s in pipeline.speculate_batch(
+            [slow, fast], max_workers=1, timeout_per_snippet=0.2)}
+        # The single worker is freed by the kill, so 'hello' is not stuck behind 'sleep 2'
+        assert time.time() - start < 1.0
+        assert executor.cancelled == ['sleep 2']
+        assert results[fast].phase == StagingPhase.PASSED
+        assert results[slow].spec_result == SpecResult.TIMEOUT
+
+    def test_deadline_excludes_lock_wait(self, pipeline):
+        code = 'import time\ntime.sleep(0.3)'
+        ids = [pipeline.queue_snippet('a', 'python', code, 'Nap').staging_id for _ in range(2)]
+        results = pipeline.speculate_batch(ids, max_workers=2, timeout_per_snippet=0.5)
+        # The second run waits ~0.3s for the in-process lock; that is not its runtime
+        assert [s.phase for s in results] == [StagingPhase.PASSED] * 2
+
+    def test_late_result_is_discarded(self, pipeline):
+        slow = _queue(pipeline, 'sleep 0.4').staging_id
+        pipeline.speculate_batch([slow], timeout_per_snippet=0.1)
+        time.sleep(0.5)  # let the abandoned worker finish
+        snippet = pipeline.get_snippet(slow)
+        assert snippet.phase == StagingPhase.FAILED
+        assert 'deadline' in snippet.spec_error
+
+    def test_batch_cancel_event(self, pipeline):
+        ids = [_queue(pipeline, 'sleep 1').staging_id for _ in range(2)]
+        cancel = threading.Event()
+        threading.Timer(0.1, cancel.set).start()
+        start = time.time()
+        results = pipeline.speculate_batch(ids, cancel_event=cancel)
+        assert time.time() - start < 0.8
+        assert all(s.phase == StagingPhase.FAILED for s in results)
+        assert all('cancelled' in s.spec_error for s in results)
+
+    def test_invalid_worker_count(self, pipeline):
+        with pytest.raises(ValueError):
+            pipeline.speculate_batch(['x'], max_workers=0)
+
+
+# =============================================================================
+# CONTENT-ADDRESSABLE PAYLOADS
+# =============================================================================
+
+class TestPayloadStore:
+
+    def test_duplicate_bodies_stored_once(self, pipeline):
+        a = pipeline.run_full_pipeline('i', 'go', 'hello', 'first')
+        b = pipeline.run_full_pipeline('i', 'go', 'hello', 'second')
+        assert a.phase == b.phase == StagingPhase.PROMOTED
+        assert a.code_hash == b.code_hash
+        assert pipeline.get_source(a.code_hash) == 'hello'
+
+        written = [e for e in pipeline.get_audit_trail(b.staging_id)
+                   if e['event'] == 'file_written']
+        assert written[0]['data']['payload_deduplicated'] is True
+        written = [e for e in pipeline.get_audit_trail(a.staging_id)
+                   if e['event'] == 'file_written']
+        assert written[0]['data']['payload_deduplicated'] is False
+
+    def test_body_only_in_store(self, pipeline, tmp_path):
+        snippet = pipeline.run_full_pipeline('i', 'go', 'hello', 'once')
+        assert snippet.saved_file_path == pipeline._store.locate(snippet.code_hash)
+        with open(snippet.saved_file_path, encoding='utf-8') as f:
+            assert f.read() == 'hello'
+        files = [os.path.join(d, f) for d, _, fs in os.walk(tmp_path / 'snippets') for f in fs]
+        assert files == [snippet.saved_file_path]
+        node = pipeline._ledger.get_node_snapshot(snippet.ledger_node_id)
+        assert node.source_file == f'sha256:{snippet.code_hash}'
+
+
+# =============================================================================
+# BATCH PROMOTION
+# =============================================================================
+
+def _passed(pipeline, code='hello', label=''):
+    snippet = _queue(pipeline, code, label)
+    pipeline.speculate(snippet.staging_id)
+    return snippet.staging_id
+
+
+class TestBatchPromote:
+
+    def test_all_promoted(self, pipeline):
+        ids = [_passed(pipeline, f'hello {n}') for n in range(3)]
+        results = pipeline.batch_promote(ids)
+        assert [r.staging_id for r in results] == ids
+        assert all(r.success for r in results)
+        assert all(pipeline.get_snippet(i).phase == StagingPhase.PROMOTED for i in ids)
+
+    def test_failure_rolls_back_earlier_promotions(self, pipeline, monkeypatch):
+        ids = [_passed(pipeline, f'hello {n}') for n in range(3)]
+        original = pipeline._create_ledger_node
+
+        def flaky(snippet):
+            if snippet.staging_id == ids[2]:
+                raise RuntimeError('ledger unavailable')
+            return original(snippet)
+        monkeypatch.setattr(pipeline, '_create_ledger_node', flaky)
+
+        with pytest.raises(BatchPromotionError) as exc_info:
+            pipeline.batch_promote(ids)
+        results = exc_info.value.results
+        assert not any(r.success for r in results)
+        assert [r.rolled_back for r in results] == [True, True, False]
+
+        # Production is untouched and the survivors can be promoted again
+        assert pipeline._registry.get_occupied_slots() == []
+        for sid in ids[:2]:
+            assert pipeline.get_snippet(sid).phase == StagingPhase.PASSED
+        monkeypatch.undo()
+        assert pipeline.promote(ids[0]).phase == StagingPhase.PROMOTED
+
+    def test_rejects_unpromotable_without_side_effects(self, pipeline):
+        good = _passed(pipeline)
+        queued = _queue(pipeline, 'hello').staging_id
+        with pytest.raises(BatchPromotionError):
+            pipeline.batch_promote([good, queued])
+        assert pipeline.get_snippet(good).phase == StagingPhase.PASSED
+        assert pipeline._registry.get_occupied_slots() == []
+
+    def test_announced_only_when_committed(self, tmp_path, make_pipeline, go_executor,
+                                           monkeypatch):
+        dispatcher = WebhookDispatcher(str(tmp_path / 'hooks.jsonl'),
+                                       transport=lambda *a: 200)
+        metrics = PipelineMetrics()
+        pipeline = make_pipeline({'go': go_executor}, webhooks=dispatcher, metrics=metrics)
+        pipeline.add_webhook_target('http://hooks.example/a', 's')
+        ids = [_passed(pipeline, f'hello {n}') for n in range(2)]
+        original = pipeline._create_ledger_node
+        monkeypatch.setattr(pipeline, '_create_ledger_node', lambda snippet: (
+            original(snippet) if snippet.staging_id == ids[0] else 1 / 0))
+
+        with pytest.raises(BatchPromotionError):
+            pipeline.batch_promote(ids)
+        # ids[0] was promoted and undone: nobody may hear about it
+        assert dispatcher.pending() == [] and metrics.snapshot()[PROMOTIONS] == []
+
+        monkeypatch.undo()
+        pipeline.speculate(ids[1])                        # FAILED by the aborted batch
+        pipeline.batch_promote(ids)
+        assert len(dispatcher.pending()) == 2
+        assert metrics.snapshot()[PROMOTIONS][0]['value'] == 2
+        assert pipeline._promotion_locks == {}
+
+    def test_overlapping_concurrent_batches(self, pipeline):
+        x, y, z = (_passed(pipeline, f'hello {n}') for n in range(3))
+        outcomes = {}
+
+        def run(name, ids):
+            try:
+                pipeline.batch_promote(ids)
+                outcomes[name] = True
+            except BatchPromotionError:
+                outcomes[name] = False
+
+        threads = [threading.Thread(target=run, args=('a', [x, y])),
+                   threading.Thread(target=run, args=('b', [y, z]))]
+        for t in threads:
+            t.start()
+        for t in threads:
+            t.join()
+
+        # Exactly one batch wins the shared snippet; the loser changes nothing
+        assert sorted(outcomes.values()) == [False, True]
+        loser_only = z if outcomes['a'] else x
+        assert pipeline.get_snippet(y).phase == StagingPhase.PROMOTED
+        assert pipeline.get_snippet(loser_only).phase == StagingPhase.PASSED
+
+
+# =============================================================================
+# SPEC RESULTS
+# =============================================================================
+
+class TestSpecResult:
+
+    def test_pass_and_fail(self, pipeline):
+        ok = pipeline.speculate(_queue(pipeline, 'hello').staging_id)
+        bad = pipeline.speculate(_queue(pipeline, 'fail').staging_id)
+        assert ok.spec_result == SpecResult.PASS
+        assert bad.spec_result == SpecResult.FAIL
+
+    def test_timeout_recorded(self, pipeline):
+        snippet = pipeline.speculate(_queue(pipeline, 'timeout').staging_id)
+        assert snippet.phase == StagingPhase.FAILED
+        assert snippet.spec_result == SpecResult.TIMEOUT
+        assert snippet.spec_execution_time == 10.0
+        assert snippet.to_dict()['spec_result'] == 'TIMEOUT'
+        failed = [e for e in pipeline.get_audit_trail(snippet.staging_id)
+                  if e['event'] == 'spec_exec_failed']
+        assert failed[0]['data']['spec_result'] == 'TIMEOUT'
+
+
+# =============================================================================
+# ROLLBACK
+# =============================================================================
+
+class TestRollback:
+
+    def test_reinstalls_prior_version(self, pipeline):
+        v1 = pipeline.run_full_pipeline('i', 'go', 'v1', 'Fibonacci')
+        v2 = pipeline.run_full_pipeline('i', 'go', 'v2', 'Fibonacci')
+        assert pipeline.get_snippet(v1.staging_id).phase == StagingPhase.SUPERSEDED
+
+        rolled = pipeline.rollback(v2.staging_id, 'broken')
+        assert rolled.phase == StagingPhase.ROLLED_BACK
+        assert rolled.rolled_back_to == v1.staging_id
+
+        restored = pipeline.get_snippet(v1.staging_id)
+        assert restored.phase == StagingPhase.PROMOTED
+        assert restored.reserved_address == v2.reserved_address
+        slot = pipeline._registry.get_slot(restored.registry_slot_id)
+        assert slot.node_id == restored.ledger_node_id
+
+    def test_successive_rollbacks_walk_back(self, pipeline):
+        versions = [pipeline.run_full_pipeline('i', 'go', f'v{n}', 'Fib') for n in range(3)]
+        assert pipeline.rollback(versions[2].staging_id).rolled_back_to == versions[1].staging_id
+        assert pipeline.rollback(versions[1].staging_id).rolled_back_to == versions[0].staging_id
+        assert pipeline.rollback(versions[0].staging_id).rolled_back_to == ''
+
+    def test_rolled_back_versions_are_skipped(self, pipeline):
+        a = pipeline.run_full_pipeline('i', 'go', 'a', 'Fib')
+        b = pipeline.run_full_pipeline('i', 'go', 'b', 'Fib')
+        pipeline.rollback(b.staging_id)
+        c = pipeline.run_full_pipeline('i', 'go', 'c', 'Fib')
+        assert pipeline.rollback(c.staging_id).rolled_back_to == a.staging_id
+
+    def test_other_labels_unaffected(self, pipeline):
+        pipeline.run_full_pipeline('i', 'go', 'x', 'Other')
+        only = pipeline.run_full_pipeline('i', 'go', 'y', 'Fib')
+        assert pipeline.rollback(only.staging_id).rolled_back_to == ''
+
+    def test_history_records(self, pipeline):
+        a = pipeline.run_full_pipeline('i', 'go', 'a', 'Fib')
+        b = pipeline.run_full_pipeline('i', 'go', 'b', 'Fib')
+        pipeline.rollback(b.staging_id)
+        records = pipeline.get_promotion_history('i', 'Fib')
+        assert [r['event'] for r in records] == ['promote', 'promote', 'rollback']
+        assert records[-1]['restored_staging_id'] == a.staging_id
+
+    def test_history_depth_bounded(self, make_pipeline, go_executor):
+        pipeline = make_pipeline({'go': go_executor}, history_depth=3)
+        for n in range(5):
+            pipeline.run_full_pipeline('i', 'go', f'v{n}', 'Fib')
+        assert len(pipeline.get_promotion_history('i', 'Fib')) == 3
+
+
+# =============================================================================
+# LABEL CONFLICT POLICY
+# =============================================================================
+
+class TestLabelPolicy:
+
+    def test_reject_live_label(self, pipeline):
+        pipeline.run_full_pipeline('i', 'go', 'v1', 'Fib')
+        with pytest.raises(LabelConflictError):
+            pipeline.queue_snippet('i', 'go', 'v2', 'Fib',
+                                   label_policy=LabelConflictPolicy.REJECT)
+        # No reservation leaked by the refused call
+        assert pipeline.get_reserved_positions() == {}
+
+    def test_reject_only_applies_to_same_slot(self, pipeline):
+        pipeline.run_full_pipeline('i', 'go', 'v1', 'Fib')
+        pipeline.set_label_policy('a', LabelConflictPolicy.REJECT)
+        snippet = pipeline.queue_snippet('a', 'python', 'x = 1', 'Fib')
+        assert snippet.label == 'Fib'
+
+    def test_reject_rechecked_at_promotion(self, pipeline):
+        pipeline.set_label_policy('i', LabelConflictPolicy.REJECT)
+        first = _queue(pipeline, 'v1', 'Fib')
+        second = _queue(pipeline, 'v2', 'Fib')
+        for s in (first, second):
+            pipeline.speculate(s.staging_id)
+        pipeline.promote(first.staging_id)
+        with pytest.raises(LabelConflictError):
+            pipeline.promote(second.staging_id)
+        assert pipeline.get_snippet(second.staging_id).phase == StagingPhase.PASSED
+
+    def test_version_suffix(self, pipeline):
+        pipeline.set_label_policy('i', LabelConflictPolicy.VERSION_SUFFIX)
+        labels = [pipeline.run_full_pipeline('i', 'go', f'v{n}', 'Fib').label
+                  for n in range(3)]
+        assert labels == ['Fib', 'Fib-v2', 'Fib-v3']
+
+    def test_version_suffix_counts_in_flight(self, pipeline):
+        pipeline.set_label_policy('i', LabelConflictPolicy.VERSION_SUFFIX)
+        labels = [_queue(pipeline, f'v{n}', 'Fib').label for n in range(3)]
+        assert labels == ['Fib', 'Fib-v2', 'Fib-v3']
+
+    def test_per_call_override(self, pipeline):
+        pipeline.set_label_policy('i', LabelConflictPolicy.REJECT)
+        pipeline.run_full_pipeline('i', 'go', 'v1', 'Fib')
+        snippet = pipeline.queue_snippet('i', 'go', 'v2', 'Fib',
+                                         label_policy=LabelConflictPolicy.VERSION_SUFFIX)
+        assert snippet.label == 'Fib-v2'
+        assert snippet.label_policy == LabelConflictPolicy.VERSION_SUFFIX
+
+    def test_overwrite_replaces_in_place(self, pipeline):
+        v1 = pipeline.run_full_pipeline('i', 'go', 'v1', 'Fib')
+        v2 = pipeline.run_full_pipeline('i', 'go', 'v2', 'Fib')
+        assert v2.reserved_address == v1.reserved_address
+        assert v2.superseded_ids == [v1.staging_id]
+        assert pipeline.get_snippet(v1.staging_id).phase == StagingPhase.SUPERSEDED
+        slot = pipeline._registry.get_slot(v2.registry_slot_id)
+        assert slot.node_id == v2.ledger_node_id
+        assert pipeline.get_reserved_positions() == {}
+
+    def test_batch_abort_restores_superseded(self, pipeline, monkeypatch):
+        v1 = pipeline.run_full_pipeline('i', 'go', 'v1', 'Fib')
+        v2 = _queue(pipeline, 'v2', 'Fib')
+        bad = _queue(pipeline, 'bad')
+        pipeline.speculate_batch([v2.staging_id, bad.staging_id])
+
+        real_node = pipeline._create_ledger_node
+        def node(snippet):
+            if snippet.staging_id == bad.staging_id:
+                raise RuntimeError('ledger unavailable')
+            return real_node(snippet)
+        monkeypatch.setattr(pipeline, '_create_ledger_node', node)
+
+        with pytest.raises(BatchPromotionError):
+            pipeline.batch_promote([v2.staging_id, bad.staging_id])
+        restored = pipeline.get_snippet(v1.staging_id)
+        assert restored.phase == StagingPhase.PROMOTED
+        assert pipeline._registry.get_slot(restored.registry_slot_id).node_id == restored.ledger_node_id
+        assert pipeline.get_snippet(v2.staging_id).phase == StagingPhase.PASSED
+
+
+# =============================================================================
+# SLOT CAPACITY
+# =============================================================================
+
+class TestSlotCapacity:
+
+    def test_max_snippets_counts_queued_and_live(self, pipeline):
+        pipeline.configure_slot('i', SlotConfig(max_snippets=2))
+        pipeline.run_full_pipeline('i', 'go', 'live', 'A')
+        _queue(pipeline, 'queued', 'B')
+        with pytest.raises(SlotFullError) as exc:
+            _queue(pipeline, 'one too many', 'C')
+        assert exc.value.limit == 'max_snippets'
+        assert pipeline.get_slot_usage('i')['snippets'] == 2
+
+    def test_max_total_bytes(self, pipeline):
+        pipeline.configure_slot('i', SlotConfig(max_total_bytes=10))
+        _queue(pipeline, '12345678', 'A')
+        with pytest.raises(SlotFullError) as exc:
+            _queue(pipeline, '123', 'B')
+        assert exc.value.limit == 'max_total_bytes'
+
+    def test_rejected_snippets_free_capacity(self, pipeline):
+        pipeline.configure_slot('i', SlotConfig(max_snippets=1))
+        failed = _queue(pipeline, 'fail', 'A')
+        pipeline.speculate(failed.staging_id)
+        pipeline.verdict(failed.staging_id)
+        assert _queue(pipeline, 'ok', 'B').phase == StagingPhase.QUEUED
+
+    def test_overwrite_does_not_count_replaced_entry(self, pipeline):
+        pipeline.configure_slot('i', SlotConfig(max_snippets=1))
+        pipeline.run_full_pipeline('i', 'go', 'v1', 'Fib')
+        assert pipeline.run_full_pipeline('i', 'go', 'v2', 'Fib').phase == StagingPhase.PROMOTED
+
+    def test_evict_oldest_first(self, pipeline):
+        pipeline.configure_slot('i', SlotConfig(max_snippets=2,
+                                                eviction=EvictionPolicy.OLDEST_FIRST))
+        a = pipeline.run_full_pipeline('i', 'go', 'a', 'A')
+        pipeline.run_full_pipeline('i', 'go', 'b', 'B')
+        evicted = pipeline.evict('i', 1)
+        assert [s.staging_id for s in evicted] == [a.staging_id]
+        assert a.phase == StagingPhase.EVICTED
+        assert pipeline._registry.get_slot(a.registry_slot_id) is None
+        assert pipeline.run_full_pipeline('i', 'go', 'c', 'C').phase == StagingPhase.PROMOTED
+
+    def test_evict_lru(self, pipeline):
+        pipeline.configure_slot('i', SlotConfig(eviction=EvictionPolicy.LRU))
+        a = pipeline.run_full_pipeline('i', 'go', 'a', 'A')
+        b = pipeline.run_full_pipeline('i', 'go', 'b', 'B')
+        # Executing A makes B the least recently used
+        pipeline._registry.record_execution(a.registry_slot_id, success=True)
+        assert [s.staging_id for s in pipeline.evict('i', 1)] == [b.staging_id]
+
+    def test_manual_policy_refuses(self, pipeline):
+        pipeline.configure_slot('i', SlotConfig(max_snippets=1))
+        pipeline.run_full_pipeline('i', 'go', 'a', 'A')
+        with pytest.raises(SlotEvictionError):
+            pipeline.evict('i', 1)
+
+
+# =============================================================================
+# LINT GATE
+# =============================================================================
+
+class FakeLinter(SnippetLinter):
+    """Flags every line containing `lint:` with that line's remainder."""
+
+    def __init__(self):
+        self.calls = 0
+
+    def lint(self, code):
+        self.calls += 1
+        diags = [LintDiagnostic('printf', n, 1, line.split('lint:', 1)[1].strip())
+                 for n, line in enumerate(code.splitlines(), 1) if 'lint:' in line]
+        return LintResult(passed=not diags, diagnostics=diags)
+
+
+@pytest.fixture
+def linted(pipeline):
+    linter = FakeLinter()
+    pipeline._linters['go'] = linter
+    return pipeline, linter
+
+
+class TestLintGate:
+
+    def test_clean_snippet_promotes(self, linted):
+        pipeline, linter = linted
+        snippet = pipeline.run_full_pipeline('i', 'go', 'fine', 'A')
+        assert snippet.phase == StagingPhase.PROMOTED
+        assert linter.calls == 1
+
+    def test_findings_block_promotion(self, linted):
+        pipeline, _ = linted
+        snippet = _queue(pipeline, 'ok\nlint: missing arg')
+        pipeline.speculate(snippet.staging_id)
+        with pytest.raises(LintFailedError) as exc:
+            pipeline.promote(snippet.staging_id)
+        assert exc.value.result.diagnostics[0].line == 2
+        assert snippet.phase == StagingPhase.FAILED
+        assert snippet.spec_result == SpecResult.LINT_FAIL
+        assert snippet.lint_diagnostics == [
+            {'analyzer': 'printf', 'line': 2, 'column': 1, 'message': 'missing arg'}]
+        assert snippet.registry_slot_id == ''
+
+    def test_skip_lint_override(self, linted):
+        pipeline, linter = linted
+        snippet = _queue(pipeline, 'lint: ignored')
+        pipeline.speculate(snippet.staging_id)
+        assert pipeline.promote(snippet.staging_id, skip_lint=True).phase == StagingPhase.PROMOTED
+        assert linter.calls == 0
+        events = [e['event'] for e in pipeline.get_audit_trail(snippet.staging_id)]
+        assert 'lint_skipped' in events
+
+    def test_other_languages_ungated(self, linted):
+        pipeline, linter = linted
+        snippet = pipeline.run_full_pipeline('a', 'python', 'x = 1  # lint: nope', 'P')
+        assert snippet.phase == StagingPhase.PROMOTED
+        assert linter.calls == 0
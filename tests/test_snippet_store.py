@@ -0,0 +1,64 @@
+"""
+Test suite for the content-addressable Snippet Store.
+
+Tests cover:
+  - put/get round trips for memory and file backends
+  - Deduplication of identical payloads
+  - Hash verification and collision detection
+"""
+
+import os
+import pytest
+
+from visual_editor_core.snippet_store import (
+    MemorySnippetStore, FileSnippetStore, compute_code_hash,
+    PayloadNotFoundError, HashMismatchError, HashCollisionError,
+)
+
+BODY = b'package main\nimport "fmt"\nfunc main() { fmt.Println("hi") }\n'
+
+
+def _stores(tmp_path):
+    return [MemorySnippetStore(), FileSnippetStore(str(tmp_path / 'objects'))]
+
+
+class TestSnippetStore:
+
+    def test_round_trip(self, tmp_path):
+        for store in _stores(tmp_path):
+            h = compute_code_hash(BODY)
+            assert store.put(h, BODY) is True
+            assert store.contains(h)
+            assert store.get(h) == BODY
+
+    def test_identical_payload_stored_once(self, tmp_path):
+        for store in _stores(tmp_path):
+            h = compute_code_hash(BODY)
+            assert store.put(h, BODY) is True
+            assert store.put(h, BODY) is False
+
+    def test_missing_payload(self, tmp_path):
+        for store in _stores(tmp_path):
+            with pytest.raises(PayloadNotFoundError):
+                store.get(compute_code_hash(b'nope'))
+
+    def test_hash_mismatch_rejected(self, tmp_path):
+        for store in _stores(tmp_path):
+            with pytest.raises(HashMismatchError):
+                store.put(compute_code_hash(b'other'), BODY)
+
+    def test_collision_detected(self, tmp_path):
+        store = FileSnippetStore(str(tmp_path / 'objects'))
+        h = compute_code_hash(BODY)
+        store.put(h, BODY)
+        # Simulate on-disk corruption: different bytes under the same key
+        with open(os.path.join(store.root, h[:2], h), 'wb') as f:
+            f.write(b'tampered')
+        with pytest.raises(HashCollisionError):
+            store.put(h, BODY)
+
+    def test_file_layout(self, tmp_path):
+        store = FileSnippetStore(str(tmp_path / 'objects'))
+        h = compute_code_hash(BODY)
+        store.put(h, BODY)
+        assert os.path.isfile(os.path.join(store.root, h[:2], h))
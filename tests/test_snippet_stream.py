@@ -0,0 +1,130 @@
+"""
+Test suite for live output streams.
+
+Tests cover:
+  - OutputStream: seq numbering across stdout / stderr, close codes, byte cap
+  - follow(): live frames, resuming after a seq, idle polls, ending on close
+  - close() writes the run's full output when nothing was streamed live
+  - StreamHub keeps open streams and the most recent finished ones
+  - _run_with_deadline(on_output=…) forwards lines as the child writes them
+  - StagingPipeline: streaming executors, replay after the run, queued snippets
+"""
+
+import sys
+import threading
+import pytest
+
+from visual_editor_core.execution_engine import ExecutionResult, _run_with_deadline
+from visual_editor_core.snippet_stream import CloseCode, OutputStream, StreamHub
+
+
+class TestOutputStream:
+    def test_frames_and_close(self):
+        stream = OutputStream('stg-1')
+        stream.write('stdout', 'a\n')
+        stream.write('stderr', 'oops\n')
+        stream.write('stdout', '')                       # ignored
+        stream.close('TIMEOUT', 1.5)
+        stream.write('stdout', 'late\n')                 # ignored after close
+        assert [(f.seq, f.stream, f.data) for f in stream.frames()] == [
+            (1, 'stdout', 'a\n'), (2, 'stderr', 'oops\n')]
+        assert stream.close_code == CloseCode.TIMEOUT == 4002
+        assert stream.close_info() == {'spec_result': 'TIMEOUT', 'spec_time': 1.5,
+                                       'truncated': False}
+
+    def test_close_fallback_output(self):
+        stream = OutputStream('stg-1')
+        stream.close('FAIL', 0.2, output='partial', error='boom')
+        assert [(f.stream, f.data) for f in stream.frames()] == [
+            ('stdout', 'partial'), ('stderr', 'boom')]
+        assert CloseCode.for_result('nonsense') == CloseCode.FAIL
+
+    def test_byte_cap(self):
+        stream = OutputStream('stg-1', max_bytes=10)
+        stream.write('stdout', '12345678')
+        stream.write('stdout', '9abc')
+        assert len(stream.frames()) == 1 and stream.truncated
+
+    def test_follow(self):
+        stream = OutputStream('stg-1')
+        stream.write('stdout', 'first\n')
+        seen = []
+
+        def reader():
+            for frame in stream.follow(poll_interval=0.01):
+                seen.append(frame and frame.data)
+
+        thread = threading.Thread(target=reader)
+        thread.start()
+        stream.write('stdout', 'second\n')
+        stream.close('PASS', 0.1)
+        thread.join(5)
+        assert not thread.is_alive()
+        assert [s for s in seen if s] == ['first\n', 'second\n']
+        assert [f.data for f in stream.follow(after_seq=1)] == ['second\n']
+
+
+class TestStreamHub:
+    def test_retention(self):
+        hub = StreamHub(max_finished=1)
+        waiting = hub.open('q')
+        assert hub.open('q') is waiting                  # reused while open
+        for sid in ('a', 'b'):
+            hub.open(sid).close('PASS', 0.0)
+        hub.open('c')
+        assert hub.get('a') is None
+        assert hub.get('b') is not None and hub.get('q') is waiting
+
+
+def test_run_with_deadline_streams_lines():
+    lines = []
+    script = "import sys; print('out'); sys.stdout.flush(); print('err', file=sys.stderr)"
+    proc, timed_out = _run_with_deadline([sys.executable, '-c', script], timeout=30,
+                                         on_output=lambda s, line: lines.append((s, line)))
+    assert not timed_out
+    assert proc.stdout == 'out\n' and proc.stderr == 'err\n'
+    assert sorted(lines) == [('stderr', 'err\n'), ('stdout', 'out\n')]
+
+
+class StreamingExecutor:
+    streams_output = True
+
+    def execute(self, code, on_output=None):
+        on_output('stdout', 'hello\n')
+        on_output('stderr', 'warn\n')
+        return ExecutionResult(success=True, output='hello\n', execution_time=0.3)
+
+
+class PlainExecutor:
+    def execute(self, code):
+        return ExecutionResult(success=False, output='partial', error='exit status 2',
+                               execution_time=0.1)
+
+
+class TestPipelineStreams:
+    def test_streaming_executor(self, make_pipeline):
+        pipeline = make_pipeline({'go': StreamingExecutor()})
+        snippet = pipeline.queue_snippet('i', 'go', 'package main', 'svc')
+        waiting = pipeline.output_stream(snippet.staging_id)      # before the run
+        pipeline.speculate(snippet.staging_id)
+        assert waiting.closed and waiting.close_code == CloseCode.PASS
+        assert [(f.seq, f.stream) for f in waiting.frames()] == [(1, 'stdout'), (2, 'stderr')]
+        assert waiting.spec_time == pytest.approx(0.3)
+
+    def test_plain_executor_and_replay(self, make_pipeline):
+        pipeline = make_pipeline({'go': PlainExecutor()})
+        snippet = pipeline.speculate(pipeline.queue_snippet('i', 'go', 'package main').staging_id)
+        stream = pipeline.output_stream(snippet.staging_id)
+        assert stream.close_code == CloseCode.FAIL
+        assert [f.data for f in stream.frames()] == ['partial', 'exit status 2']
+
+        # Evicted from the hub: rebuilt from the recorded spec output
+        pipeline._streams = StreamHub()
+        replay = pipeline.output_stream(snippet.staging_id)
+        assert replay.closed and [f.data for f in replay.frames()] == ['partial', 'exit status 2']
+
+    def test_scoped(self, make_pipeline):
+        pipeline = make_pipeline({'go': PlainExecutor()})
+        snippet = pipeline.queue_snippet('i', 'go', 'package main', namespace='team-a')
+        with pytest.raises(ValueError):
+            pipeline.output_stream(snippet.staging_id, 'team-b')
@@ -0,0 +1,196 @@
+"""
+Test suite for graceful swaps.
+
+Tests cover:
+  - GracefulSwap validation and from_dict()
+  - promote(graceful_swap=…) with nothing in flight, without a live version
+    and together with a canary
+  - Draining: PENDING_SWAP while an execution holds its lease, promotion when
+    the last one ends, executions that waited run the new version
+  - drain_timeout: forced swap cancels the outstanding leases
+  - Audit entries, list_swaps() / get_swap() namespaces, a second swap on a
+    slot, the approval record carrying the swap
+  - _run_with_deadline(cancel_event=…) kills the process
+"""
+
+import sys
+import threading
+import time
+import pytest
+
+from visual_editor_core.execution_engine import _run_with_deadline
+from visual_editor_core.snippet_staging import StagingPhase
+from visual_editor_core.snippet_approvals import PendingApprovalError
+from visual_editor_core.snippet_canary import CanaryConfig
+from visual_editor_core.snippet_capacity import SlotConfig
+from visual_editor_core.snippet_swap import GracefulSwap, SwapController, SwapStatus
+
+
+class FakeClock:
+    def __init__(self):
+        self.now = 1000.0
+
+    def __call__(self):
+        return self.now
+
+
+@pytest.fixture
+def clock():
+    return FakeClock()
+
+
+@pytest.fixture
+def pipeline(make_pipeline, clock):
+    return make_pipeline(swap_controller=SwapController(poll_interval=0.01, clock=clock,
+                                                        background=False))
+
+
+def passed(pipeline, code, label='svc', **kwargs):
+    snippet = pipeline.queue_snippet('i', 'go', code, label, **kwargs)
+    return pipeline.speculate(snippet.staging_id)
+
+
+@pytest.fixture
+def v1(pipeline):
+    return pipeline.promote(passed(pipeline, 'package main // v1').staging_id)
+
+
+@pytest.fixture
+def v2(pipeline, v1):
+    return passed(pipeline, 'package main // v2')
+
+
+def events(pipeline, staging_id, name):
+    return [e for e in pipeline.get_audit_trail(staging_id) if e['event'] == name]
+
+
+class TestGracefulSwap:
+    def test_validation(self):
+        assert GracefulSwap().drain_timeout == 30.0
+        for bad in (0, -1, True, '5'):
+            with pytest.raises(ValueError, match='drain_timeout'):
+                GracefulSwap(drain_timeout=bad)
+        assert GracefulSwap.from_dict({'drain_timeout': 5}).to_dict() == {'drain_timeout': 5}
+        with pytest.raises(ValueError, match='grace'):
+            GracefulSwap.from_dict({'grace': 5})
+
+
+class TestPromote:
+    def test_nothing_in_flight(self, pipeline, v1, v2):
+        snippet = pipeline.promote(v2.staging_id, graceful_swap=GracefulSwap())
+        assert snippet.phase == StagingPhase.PROMOTED
+        assert v1.phase == StagingPhase.SUPERSEDED
+        swap = pipeline.get_swap(v2.staging_id)
+        assert swap.status == SwapStatus.COMPLETED and swap.in_flight_at_request == 0
+        assert not events(pipeline, v2.staging_id, 'swap_pending')
+
+    def test_no_live_version(self, pipeline):
+        snippet = passed(pipeline, 'package main')
+        assert pipeline.promote(snippet.staging_id, graceful_swap=GracefulSwap()).phase == \
+            StagingPhase.PROMOTED
+        assert pipeline.get_swap(snippet.staging_id) is None
+
+    def test_not_with_canary(self, pipeline, v2):
+        with pytest.raises(ValueError, match='not both'):
+            pipeline.promote(v2.staging_id, canary=CanaryConfig(), graceful_swap=GracefulSwap())
+        assert v2.phase == StagingPhase.PASSED
+
+
+class TestDrain:
+    def test_last_execution_completes_swap(self, pipeline, v1, v2, clock):
+        slot_id = v1.registry_slot_id
+        first = pipeline.begin_slot_run(slot_id)
+        second = pipeline.begin_slot_run(slot_id)
+        snippet = pipeline.promote(v2.staging_id, graceful_swap=GracefulSwap(drain_timeout=10))
+        assert snippet.phase == StagingPhase.PENDING_SWAP
+        assert v1.phase == StagingPhase.PROMOTED
+        swap = pipeline.get_swap(v2.staging_id)
+        assert swap.status == SwapStatus.PENDING and swap.in_flight_at_request == 2
+        assert events(pipeline, v2.staging_id, 'swap_pending')[0]['data']['in_flight'] == 2
+
+        pipeline.end_slot_run(first)
+        assert snippet.phase == StagingPhase.PENDING_SWAP
+        clock.now += 3
+        pipeline.end_slot_run(second)
+        assert snippet.phase == StagingPhase.PROMOTED and v1.phase == StagingPhase.SUPERSEDED
+        assert swap.installed_slot_id == snippet.registry_slot_id != slot_id
+        assert swap.status == SwapStatus.COMPLETED and swap.cancelled == 0
+        completed = events(pipeline, v2.staging_id, 'swap_completed')[0]['data']
+        assert completed['status'] == 'completed' and completed['waited'] == 3
+        assert not first.cancelled and not second.cancelled
+
+    def test_waiting_execution_runs_new_version(self, pipeline, v1, v2):
+        running = pipeline.begin_slot_run(v1.registry_slot_id)
+        pipeline.promote(v2.staging_id, graceful_swap=GracefulSwap())
+        leases = []
+        waiter = threading.Thread(
+            target=lambda: leases.append(pipeline.begin_slot_run(v1.registry_slot_id)))
+        waiter.start()
+        time.sleep(0.1)
+        assert not leases and pipeline.swaps.in_flight(v1.registry_slot_id) == 1
+        pipeline.end_slot_run(running)
+        waiter.join(5)
+        assert leases and leases[0].swapped
+        assert v2.phase == StagingPhase.PROMOTED
+        assert leases[0].slot_id == v2.registry_slot_id
+        assert pipeline.swaps.in_flight(v2.registry_slot_id) == 1
+        pipeline.end_slot_run(leases[0])
+        assert not pipeline.begin_slot_run(v2.registry_slot_id).swapped
+
+    def test_drain_timeout_forces(self, pipeline, v1, v2, clock):
+        slot_id = v1.registry_slot_id
+        lease = pipeline.begin_slot_run(slot_id)
+        pipeline.promote(v2.staging_id, graceful_swap=GracefulSwap(drain_timeout=5))
+        clock.now += 4
+        pipeline.swaps.tick()
+        assert v2.phase == StagingPhase.PENDING_SWAP and not lease.cancelled
+        clock.now += 2
+        pipeline.swaps.tick()
+        assert lease.cancelled and v2.phase == StagingPhase.PROMOTED
+        swap = pipeline.get_swap(v2.staging_id)
+        assert swap.status == SwapStatus.FORCED and swap.cancelled == 1
+        assert 'still running after 5s' in swap.reason
+        assert events(pipeline, v2.staging_id, 'swap_completed')[0]['data']['cancelled'] == 1
+        pipeline.end_slot_run(lease)                 # Late release is harmless
+        assert pipeline.swaps.in_flight(slot_id) == 0
+
+    def test_one_swap_per_slot(self, pipeline, v1, v2):
+        lease = pipeline.begin_slot_run(v1.registry_slot_id)
+        pipeline.promote(v2.staging_id, graceful_swap=GracefulSwap())
+        v3 = passed(pipeline, 'package main // v3')
+        with pytest.raises(ValueError, match='already has a swap pending'):
+            pipeline.promote(v3.staging_id, graceful_swap=GracefulSwap())
+        assert v3.phase == StagingPhase.PASSED
+        pipeline.end_slot_run(lease)
+
+    def test_namespaces(self, pipeline, v1, v2):
+        pipeline.promote(v2.staging_id, graceful_swap=GracefulSwap())
+        assert [s.staging_id for s in pipeline.list_swaps()] == [v2.staging_id]
+        assert pipeline.list_swaps(namespace='team-b') == []
+        assert pipeline.get_swap(v2.staging_id, namespace='team-b') is None
+
+
+def test_approval_carries_swap(pipeline, v1, v2):
+    pipeline.configure_slot('i', SlotConfig(require_approvals=1))
+    lease = pipeline.begin_slot_run(v1.registry_slot_id)
+    with pytest.raises(PendingApprovalError) as exc:
+        pipeline.promote(v2.staging_id, graceful_swap=GracefulSwap(drain_timeout=7))
+    assert exc.value.record.graceful_swap.drain_timeout == 7
+    pipeline.approve_promotion(v2.staging_id, 'alice')
+    assert v2.phase == StagingPhase.PENDING_SWAP
+    pipeline.end_slot_run(lease)
+    assert v2.phase == StagingPhase.PROMOTED
+
+
+def test_run_with_deadline_cancel_event():
+    cancel = threading.Event()
+    threading.Timer(0.2, cancel.set).start()
+    started = time.time()
+    proc, timed_out = _run_with_deadline([sys.executable, '-c', 'import time; time.sleep(30)'],
+                                         timeout=30, cancel_event=cancel)
+    assert proc.cancelled and not timed_out and proc.resource_violation == ''
+    assert time.time() - started < 10
+
+    proc, _ = _run_with_deadline([sys.executable, '-c', 'print(1)'], timeout=30,
+                                 cancel_event=threading.Event())
+    assert not proc.cancelled and proc.stdout == '1\n'
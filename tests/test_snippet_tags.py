@@ -0,0 +1,196 @@
+"""
+Test suite for snippet tags.
+
+Tests cover:
+  - validate_tags(): normalisation, sorting, bad segments, depth and count
+  - TagPattern / parse_tag_query(): globs within a segment, `**` across
+    segments, AND binding tighter than OR, malformed queries
+  - TagIndex: exact, subtree and wildcard lookups, removal
+  - queue_snippet(tags=…) and tag_query() against both snippet indexes,
+    SnippetFilter.tags with pagination and namespaces
+  - retag(): audited, immutable once promoted for scoped callers,
+    NamespaceAdmin may still retag; the SQLite index keeps tags across reopen
+  - Export / import carries tags
+"""
+
+import io
+import pytest
+
+from visual_editor_core.snippet_namespace import NamespaceAdmin
+from visual_editor_core.snippet_query import InMemorySnippetIndex, SnippetFilter
+from visual_editor_core.snippet_sqlite import SQLiteSnippetIndex
+from visual_editor_core.snippet_tags import (
+    TagError, TagIndex, TagPattern, TagsImmutableError, parse_tag_query, validate_tags,
+)
+
+
+@pytest.fixture(params=['memory', 'sqlite'])
+def pipeline(request, tmp_path, make_pipeline):
+    index = (SQLiteSnippetIndex(str(tmp_path / 'index.db')) if request.param == 'sqlite'
+             else InMemorySnippetIndex())
+    return make_pipeline(snippet_index=index)
+
+
+@pytest.fixture
+def library(pipeline):
+    """label → snippet for a small tagged library."""
+    tagged = {
+        'gcd': ['math/number-theory', 'pure'],
+        'primes': ['math/number-theory/sieve'],
+        'matmul': ['math/linear-algebra', 'pure'],
+        'read': ['io/file'],
+        'fetch': ['io/net/http'],
+        'plain': [],
+    }
+    return {label: pipeline.queue_snippet('i', 'go', f'package main // {label}', label,
+                                          tags=tags)
+            for label, tags in tagged.items()}
+
+
+def labels(snippets):
+    return sorted(s.label for s in snippets)
+
+
+class TestValidation:
+    def test_tags(self):
+        assert validate_tags(None) == []
+        assert validate_tags([' Math/Number-Theory ', 'pure', 'math/number-theory']) == [
+            'math/number-theory', 'pure']
+        for bad in (['math//x'], ['/math'], ['-x'], ['a b'], [3], ['/'.join('abcdefghi')]):
+            with pytest.raises(TagError):
+                validate_tags(bad)
+        with pytest.raises(TagError, match='list'):
+            validate_tags('math')
+        with pytest.raises(TagError, match='At most'):
+            validate_tags([f't{i}' for i in range(33)])
+
+    def test_patterns(self):
+        assert TagPattern.parse('math/*').matches('math/algebra')
+        assert not TagPattern.parse('math/*').matches('math/algebra/groups')
+        assert not TagPattern.parse('math/*').matches('math')
+        deep = TagPattern.parse('math/**')
+        assert deep.matches('math') and deep.matches('math/a/b') and not deep.matches('mathx')
+        assert deep.subtree == 'math' and TagPattern.parse('**').subtree == ''
+        assert TagPattern.parse('io/**/http').matches('io/net/http')
+        assert TagPattern.parse('io/n?t/[hf]*').matches('io/net/http')
+        assert TagPattern.parse('a/b').exact and TagPattern.parse('a/*/c').literal_prefix == 'a'
+        for bad in ('a//b', 'a/**x', 'a b'):
+            with pytest.raises(TagError):
+                TagPattern.parse(bad)
+
+    def test_queries(self):
+        query = parse_tag_query('math/** and pure OR io/file')
+        assert [[p.text for p in g] for g in query.any_of] == [['math/**', 'pure'], ['io/file']]
+        assert query.matches(['math/x', 'pure']) and query.matches(['io/file'])
+        assert not query.matches(['math/x'])
+        for bad in ('', 'AND pure', 'math OR', 'a OR  OR b'):
+            with pytest.raises(TagError):
+                parse_tag_query(bad)
+
+
+def test_tag_index():
+    index = TagIndex()
+    index.put('s1', ['math/number-theory', 'pure'])
+    index.put('s2', ['math/linear-algebra'])
+    index.put('s3', ['io/file'])
+    assert index.query(parse_tag_query('pure')) == {'s1'}
+    assert index.query(parse_tag_query('math/**')) == {'s1', 's2'}
+    assert index.query(parse_tag_query('math/*-theory OR io/*')) == {'s1', 's3'}
+    assert index.query(parse_tag_query('math/** AND pure')) == {'s1'}
+    index.put('s1', ['io/file'])
+    assert index.query(parse_tag_query('math/**')) == {'s2'}
+    index.remove('s3')
+    assert index.tags() == {'io/file': 1, 'math/linear-algebra': 1}
+    index.remove('s1')
+    index.remove('s2')
+    assert len(index) == 0 and not index._under
+
+
+class TestTagQuery:
+    def test_patterns(self, pipeline, library):
+        assert library['gcd'].tags == ['math/number-theory', 'pure']
+        assert labels(pipeline.tag_query('math/*')) == ['gcd', 'matmul']
+        assert labels(pipeline.tag_query('math/**')) == ['gcd', 'matmul', 'primes']
+        assert labels(pipeline.tag_query('math/number-theory')) == ['gcd']
+        assert labels(pipeline.tag_query('math/** AND pure')) == ['gcd', 'matmul']
+        assert labels(pipeline.tag_query('io/file OR io/net/*')) == ['fetch', 'read']
+        assert labels(pipeline.tag_query('**/http OR pure AND math/linear-algebra')) == [
+            'fetch', 'matmul']
+        assert pipeline.tag_query('science/**') == []
+        with pytest.raises(TagError):
+            pipeline.tag_query('math//x')
+
+    def test_filter_pages(self, pipeline, library):
+        page = pipeline.query(SnippetFilter(tags='math/**', limit=2))
+        assert len(page.snippets) == 2 and page.next_page_token
+        rest = pipeline.query(SnippetFilter(tags='math/**', limit=2,
+                                            page_token=page.next_page_token))
+        assert labels(page.snippets + rest.snippets) == ['gcd', 'matmul', 'primes']
+        assert not rest.next_page_token
+        assert labels(pipeline.query(SnippetFilter(tags='pure', label='gcd')).snippets) == ['gcd']
+        with pytest.raises(ValueError):
+            SnippetFilter(tags='OR')
+
+    def test_namespaces(self, pipeline, library):
+        pipeline.queue_snippet('i', 'go', 'package main // b', 'other', tags=['pure'],
+                               namespace='team-b')
+        assert labels(pipeline.tag_query('pure', namespace='team-b')) == ['other']
+        assert 'other' not in labels(pipeline.tag_query('pure', namespace='default'))
+        assert 'other' in labels(pipeline.tag_query('pure'))
+
+
+class TestRetag:
+    def test_before_promotion(self, pipeline, library):
+        snippet = pipeline.retag(library['read'].staging_id, ['io/disk', 'pure'],
+                                 namespace='default')
+        assert snippet.tags == ['io/disk', 'pure']
+        assert pipeline.tag_query('io/file') == []
+        assert 'read' in labels(pipeline.tag_query('pure'))
+        entry = [e for e in pipeline.get_audit_trail(snippet.staging_id)
+                 if e['event'] == 'snippet_retagged'][0]
+        assert entry['data'] == {'previous': ['io/file'], 'tags': ['io/disk', 'pure'],
+                                 'promoted': False}
+        with pytest.raises(ValueError, match='No staged snippet'):
+            pipeline.retag(snippet.staging_id, ['x'], namespace='team-b')
+
+    def test_immutable_once_promoted(self, pipeline, library):
+        gcd = library['gcd']
+        pipeline.speculate(gcd.staging_id)
+        pipeline.promote(gcd.staging_id)
+        with pytest.raises(TagsImmutableError, match='admin credential'):
+            pipeline.retag(gcd.staging_id, ['math'], namespace='default')
+        assert gcd.tags == ['math/number-theory', 'pure']
+        assert pipeline.retag(gcd.staging_id, ['math']).tags == ['math']
+        assert labels(pipeline.tag_query('math')) == ['gcd']
+
+
+def test_admin_retag(make_pipeline):
+    pipeline = make_pipeline(namespace_admin_credential='s3cret')
+    snippet = pipeline.run_full_pipeline('i', 'go', 'package main', 'svc', tags=['a'])
+    NamespaceAdmin(pipeline, 's3cret').retag(snippet.staging_id, ['b/c'])
+    assert pipeline.get_snippet(snippet.staging_id).tags == ['b/c']
+
+
+def test_sqlite_keeps_tags(tmp_path, make_pipeline):
+    path = str(tmp_path / 'index.db')
+    pipeline = make_pipeline(snippet_index=SQLiteSnippetIndex(path))
+    snippet = pipeline.queue_snippet('i', 'go', 'package main', 'svc', tags=['math/x', 'pure'])
+    pipeline.retag(snippet.staging_id, ['math/y'])
+
+    index = SQLiteSnippetIndex(path)
+    assert index.get(snippet.staging_id).tags == ['math/y']
+    page = index.query(SnippetFilter(tags='math/*'))
+    assert [r.staging_id for r in page.snippets] == [snippet.staging_id]
+    assert index.query(SnippetFilter(tags='pure')).snippets == []
+    index.remove(snippet.staging_id)
+    assert index._conn.execute('SELECT COUNT(*) FROM snippet_tags').fetchone()[0] == 0
+
+
+def test_transfer_carries_tags(make_pipeline):
+    dev = make_pipeline(root='dev')
+    prod = make_pipeline(root='prod')
+    dev.queue_snippet('i', 'go', 'package main', 'svc', tags=['io/file'])
+    out = io.StringIO()
+    dev.export_snippets(SnippetFilter(), out)
+    record = prod.import_snippets(out.getvalue().splitlines()).records[0]
+    assert prod.get_snippet(record.staging_id).tags == ['io/file']
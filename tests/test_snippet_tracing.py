@@ -0,0 +1,137 @@
+"""
+Test suite for OpenTelemetry tracing of the staging pipeline.
+
+Tests cover:
+  - Top-level spans for stage / speculate / promote / rollback
+  - Child spans (hash_source, execute, lint_gate) nest under their operation
+  - staging_id and slot attributes on every top-level span
+  - Exceptions recorded on the span that raised them
+  - No-op behaviour without opentelemetry installed
+"""
+
+from contextlib import contextmanager
+import pytest
+
+from visual_editor_core import snippet_tracing
+from visual_editor_core.snippet_lint import LintResult, LintDiagnostic
+
+
+class RecordedSpan:
+
+    def __init__(self, name, parent, attributes):
+        self.name = name
+        self.parent = parent
+        self.attributes = dict(attributes or {})
+        self.exceptions = []
+
+    def set_attribute(self, key, value):
+        self.attributes[key] = value
+
+    def record_exception(self, exc):
+        self.exceptions.append(exc)
+
+
+class RecordingTracer:
+    """Minimal tracer: tracks the current span so parents can be checked."""
+
+    def __init__(self):
+        self.spans = []
+        self._stack = []
+
+    @contextmanager
+    def start_as_current_span(self, name, attributes=None):
+        current = RecordedSpan(name, self._stack[-1] if self._stack else None, attributes)
+        self.spans.append(current)
+        self._stack.append(current)
+        try:
+            yield current
+        except Exception as exc:
+            current.record_exception(exc)      # the SDK's default behaviour
+            raise
+        finally:
+            self._stack.pop()
+
+    def named(self, name):
+        return [s for s in self.spans if s.name == name]
+
+
+class FakeTrace:
+
+    def __init__(self, tracer):
+        self.tracer = tracer
+
+    def get_tracer(self, name):
+        assert name == snippet_tracing.TRACER_NAME
+        return self.tracer
+
+
+@pytest.fixture
+def tracer(monkeypatch):
+    recording = RecordingTracer()
+    monkeypatch.setattr(snippet_tracing, 'trace', FakeTrace(recording))
+    return recording
+
+
+@pytest.fixture
+def pipeline(make_pipeline):
+    return make_pipeline({})
+
+
+class TestSpans:
+
+    def test_full_pipeline_span_tree(self, pipeline, tracer):
+        snippet = pipeline.run_full_pipeline('a', 'python', 'x = 1', 'Traced')
+
+        [stage] = tracer.named('StageSnippet')
+        [spec] = tracer.named('RunSpec')
+        [promote] = tracer.named('PromoteSnippet')
+        for top in (stage, spec, promote):
+            assert top.attributes['staging_id'] == snippet.staging_id
+            assert top.attributes['slot'] == 'a'
+
+        assert promote.attributes['slot.address'] == snippet.reserved_address
+        assert spec.attributes['spec_result'] == 'PASS'
+        assert tracer.named('hash_source')[0].parent is stage
+        assert tracer.named('execute')[0].parent is spec
+        assert tracer.named('lint_gate')[0].parent is promote
+
+    def test_rollback_span(self, pipeline, tracer):
+        snippet = pipeline.run_full_pipeline('a', 'python', 'x = 1', 'Traced')
+        pipeline.rollback(snippet.staging_id, reason='test')
+        [rollback] = tracer.named('Rollback')
+        assert rollback.parent is None
+        assert rollback.attributes['staging_id'] == snippet.staging_id
+        assert rollback.attributes['phase'] == 'rolled_back'
+
+    def test_lint_failure_recorded_on_spans(self, pipeline, tracer):
+        class Rejecting:
+            def lint(self, code):
+                return LintResult(passed=False, diagnostics=[
+                    LintDiagnostic('test', 1, 1, 'nope')])
+        pipeline._linters['python'] = Rejecting()
+
+        snippet = pipeline.queue_snippet('a', 'python', 'x = 1', 'Traced')
+        pipeline.speculate(snippet.staging_id)
+        with pytest.raises(Exception):
+            pipeline.promote(snippet.staging_id)
+
+        [promote] = tracer.named('PromoteSnippet')
+        [gate] = tracer.named('lint_gate')
+        assert gate.exceptions and promote.exceptions
+        assert promote.attributes['staging_id'] == snippet.staging_id
+
+    def test_unknown_staging_id_still_tagged(self, pipeline, tracer):
+        with pytest.raises(ValueError):
+            pipeline.speculate('stg_missing')
+        [spec] = tracer.named('RunSpec')
+        assert spec.attributes['staging_id'] == 'stg_missing'
+        assert spec.exceptions
+
+
+def test_noop_without_opentelemetry(monkeypatch, pipeline):
+    monkeypatch.setattr(snippet_tracing, 'trace', None)
+    with snippet_tracing.span('anything', staging_id='x') as current:
+        current.set_attribute('k', 'v')
+        assert not current.is_recording()
+    snippet = pipeline.run_full_pipeline('a', 'python', 'x = 1', 'Untraced')
+    assert snippet.registry_slot_id
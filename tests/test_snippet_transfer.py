@@ -0,0 +1,258 @@
+"""
+Test suite for snippet export / import.
+
+Tests cover:
+  - export_snippets(): one JSON Lines record per matching snippet, every page,
+    base64 source with source_hash, metadata without code, env names only,
+    promotion history, namespace scoping
+  - ExportRecord.decode(): format, JSON, base64 and hash checks
+  - import_snippets(): live records promoted, others staged, metadata carried
+    (requires, output schema, env values from the options, deterministic,
+    resource limits, suppress_credential_check),
+    namespaces, audit entry
+  - ConflictPolicy: skip, reject, overwrite, version_suffix
+  - The lint gate, failed records, stop_on_error and protected slots
+"""
+
+import base64
+import hashlib
+import io
+import json
+import pytest
+
+from visual_editor_core.execution_engine import ExecutionResult
+from visual_editor_core.snippet_staging import StagingPhase
+from visual_editor_core.snippet_capacity import SlotConfig
+from visual_editor_core.snippet_lint import LintDiagnostic, LintResult, SnippetLinter
+from visual_editor_core.snippet_query import SnippetFilter
+from visual_editor_core.snippet_transfer import (
+    ConflictPolicy, ExportRecord, FORMAT_VERSION, ImportOptions, ImportStatus,
+    SnippetImportError,
+)
+
+
+class MarkerExecutor:
+    """Fails code containing 'boom'."""
+    limitable = True
+
+    def execute(self, code, env=None, resource_limits=None):
+        if 'boom' in code:
+            return ExecutionResult(success=False, output='', error=Exception('boom'),
+                                   execution_time=0.01)
+        return ExecutionResult(success=True, output='ok', error=None, execution_time=0.01,
+                               structured_output='{}')
+
+
+class MarkerLinter(SnippetLinter):
+    def lint(self, code):
+        diags = [LintDiagnostic('printf', 1, 1, 'flagged')] if 'lint:' in code else []
+        return LintResult(passed=not diags, diagnostics=diags)
+
+
+def environment(make_pipeline, name):
+    return make_pipeline({'go': MarkerExecutor()}, root=name, linters={'go': MarkerLinter()})
+
+
+@pytest.fixture
+def dev(make_pipeline):
+    return environment(make_pipeline, 'dev')
+
+
+@pytest.fixture
+def prod(make_pipeline):
+    return environment(make_pipeline, 'prod')
+
+
+def live(pipeline, code, label, **kwargs):
+    snippet = pipeline.queue_snippet('i', 'go', code, label, **kwargs)
+    pipeline.speculate(snippet.staging_id)
+    return pipeline.promote(snippet.staging_id)
+
+
+def export(pipeline, snippet_filter=None, namespace=None):
+    out = io.StringIO()
+    count = pipeline.export_snippets(snippet_filter or SnippetFilter(), out, namespace)
+    lines = out.getvalue().splitlines()
+    assert len(lines) == count
+    return lines
+
+
+class TestExport:
+    def test_records(self, dev):
+        promoted = live(dev, 'package main // v1', 'pricing', env={'API_KEY': 'secret'})
+        dev.queue_snippet('i', 'go', 'package main // draft', 'draft')
+        lines = export(dev)
+        assert len(lines) == 2
+        raw = json.loads(lines[0])
+        assert raw['format'] == FORMAT_VERSION
+        assert base64.b64decode(raw['source']).decode() == 'package main // v1'
+        assert raw['snippet']['staging_id'] == promoted.staging_id
+        assert raw['snippet']['phase'] == 'promoted'
+        assert raw['snippet']['env'] == ['API_KEY'] and 'secret' not in lines[0]
+        assert 'code' not in raw['snippet'] and 'merged_code' not in raw['snippet']
+        assert [h['event'] for h in raw['promotion_history']] == ['promote']
+
+    def test_filter_pages_and_namespace(self, dev):
+        for i in range(3):
+            dev.queue_snippet('i', 'go', f'package main // {i}', f'svc-{i}')
+        dev.queue_snippet('i', 'go', 'package main // b', 'svc-b', namespace='team-b')
+        assert len(export(dev, SnippetFilter(label='svc', limit=1))) == 4
+        assert len(export(dev, namespace='team-b')) == 1
+        assert len(export(dev, SnippetFilter(label='svc-1'))) == 1
+
+
+class TestDecode:
+    def test_checks(self, dev):
+        dev.queue_snippet('i', 'go', 'package main', 'svc')
+        raw = json.loads(export(dev)[0])
+        assert ExportRecord.decode(json.dumps(raw)).code == 'package main'
+        for change, match in (({'format': 'other/9'}, 'Unsupported record format'),
+                              ({'source': '%%%'}, 'base64'),
+                              ({'source_hash': '0' * 64}, 'source_hash'),
+                              ({'snippet': None}, 'metadata')):
+            with pytest.raises(ValueError, match=match):
+                ExportRecord.decode(json.dumps({**raw, **change}))
+        with pytest.raises(ValueError, match='JSON'):
+            ExportRecord.decode('{')
+
+
+class TestImport:
+    def test_round_trip(self, dev, prod):
+        promoted = live(dev, 'package main // v1', 'pricing',
+                        output_schema={'type': 'object'}, env={'API_KEY': 'secret'})
+        dev.speculate(dev.queue_snippet('i', 'go', 'package main // v2', 'draft').staging_id)
+
+        report = prod.import_snippets(export(dev), ImportOptions(env={'API_KEY': 'prod-key'}))
+        assert report.counts()['promoted'] == 1 and report.counts()['staged'] == 1
+        first, second = report.records
+        assert (first.line, first.source_staging_id) == (1, promoted.staging_id)
+        imported = prod.get_snippet(first.staging_id)
+        assert imported.phase == StagingPhase.PROMOTED and imported.registry_slot_id
+        assert imported.code_hash == promoted.code_hash
+        assert imported.env == {'API_KEY': 'prod-key'}
+        assert imported.output_schema_hash == promoted.output_schema_hash
+        assert prod.get_snippet(second.staging_id).phase == StagingPhase.PASSED
+
+        entry = [e for e in prod.get_audit_trail(first.staging_id)
+                 if e['event'] == 'snippet_imported'][0]
+        assert entry['data']['source_staging_id'] == promoted.staging_id
+        assert entry['data']['promotion_history'][0]['event'] == 'promote'
+
+    def test_requires(self, dev, prod, make_pipeline):
+        live(dev, 'package main // lib', 'lib')
+        dependent = dev.queue_snippet('i', 'go', 'package main // app', 'app', requires=['lib'])
+        dev.speculate(dependent.staging_id)
+        dev.promote(dependent.staging_id)
+        report = prod.import_snippets(export(dev))
+        assert [r.status for r in report.records] == [ImportStatus.PROMOTED] * 2
+        assert prod.get_snippet(report.records[1].staging_id).requires == ['lib']
+
+        report = environment(make_pipeline, 'fresh').import_snippets(export(dev)[1:])
+        assert report.records[0].status == ImportStatus.FAILED
+        assert 'lib' in report.records[0].error
+
+    def test_deterministic(self, dev, prod):
+        dev.queue_snippet('i', 'go', 'package main // pure', 'pure', deterministic=True)
+        dev.queue_snippet('i', 'go', 'package main // clock', 'clock')
+        pure, clock = prod.import_snippets(export(dev)).records
+        assert prod.get_snippet(pure.staging_id).deterministic
+        assert not prod.get_snippet(clock.staging_id).deterministic
+
+    def test_resource_limits(self, dev, prod):
+        dev.queue_snippet('i', 'go', 'package main', 'svc',
+                          resource_limits={'max_cpu_time': 2, 'max_memory_bytes': 1 << 28})
+        record = prod.import_snippets(export(dev)).records[0]
+        assert prod.get_snippet(record.staging_id).resource_limits == {
+            'max_cpu_time': 2, 'max_memory_bytes': 1 << 28, 'max_output_bytes': 0}
+
+    def test_suppressed_credential_check(self, dev, prod):
+        dev.queue_snippet('i', 'go', 'package main // password := "hunter22"', 'fixture',
+                          suppress_credential_check=True)
+        record = prod.import_snippets(export(dev)).records[0]
+        assert record.status != ImportStatus.FAILED, record.error
+        assert prod.get_snippet(record.staging_id).suppress_credential_check
+
+    def test_missing_env(self, dev, prod):
+        dev.queue_snippet('i', 'go', 'package main', 'svc', env={'API_KEY': 'x'})
+        result = prod.import_snippets(export(dev)).records[0]
+        assert result.status == ImportStatus.FAILED and 'API_KEY' in result.error
+        assert not result.staging_id
+
+    def test_bad_lines_are_reported(self, dev, prod):
+        dev.queue_snippet('i', 'go', 'package main', 'svc')
+        lines = ['{"nope": 1}', '', export(dev)[0]]
+        report = prod.import_snippets(lines)
+        assert [(r.line, r.status) for r in report.records] == [
+            (1, ImportStatus.FAILED), (3, ImportStatus.STAGED)]
+        with pytest.raises(SnippetImportError, match='line 1') as exc:
+            prod.import_snippets(lines, ImportOptions(stop_on_error=True))
+        assert len(exc.value.report.records) == 1
+
+    def test_failed_live_record(self, dev, prod):
+        snippet = live(dev, 'package main', 'svc')
+        raw = json.loads(export(dev)[0])
+        source = b'package main // boom'
+        raw.update(source=base64.b64encode(source).decode(),
+                   source_hash=hashlib.sha256(source).hexdigest())
+        result = prod.import_snippets([json.dumps(raw)]).records[0]
+        assert result.status == ImportStatus.FAILED and result.spec_result == 'FAIL'
+        assert result.source_staging_id == snippet.staging_id
+        assert prod.get_snippet(result.staging_id).phase == StagingPhase.FAILED
+
+    def test_lint_gate(self, dev, prod):
+        dev.queue_snippet('i', 'go', 'package main // lint:', 'svc')
+        result = prod.import_snippets(export(dev)).records[0]
+        assert result.status == ImportStatus.FAILED and result.spec_result == 'LINT_FAIL'
+        assert prod.import_snippets(export(dev), ImportOptions(skip_lint=True)) \
+            .records[0].status == ImportStatus.STAGED
+
+    def test_protected_slot(self, dev, prod):
+        live(dev, 'package main', 'svc')
+        prod.configure_slot('i', SlotConfig(require_approvals=1))
+        result = prod.import_snippets(export(dev)).records[0]
+        assert result.status == ImportStatus.PENDING_APPROVAL
+        assert prod.get_approval(result.staging_id) is not None
+
+    def test_namespace(self, dev, prod):
+        dev.queue_snippet('i', 'go', 'package main', 'svc', namespace='team-a')
+        kept = prod.import_snippets(export(dev)).records[0]
+        assert prod.get_snippet(kept.staging_id).namespace == 'team-a'
+        pinned = prod.import_snippets(export(dev), namespace='team-b').records[0]
+        assert prod.get_snippet(pinned.staging_id).namespace == 'team-b'
+
+
+class TestConflictPolicy:
+    @pytest.fixture
+    def exported(self, dev):
+        live(dev, 'package main // dev', 'svc')
+        return export(dev)
+
+    @pytest.fixture
+    def existing(self, prod):
+        return live(prod, 'package main // prod', 'svc')
+
+    def test_skip(self, prod, exported, existing):
+        result = prod.import_snippets(exported).records[0]
+        assert result.status == ImportStatus.SKIPPED and not result.staging_id
+        assert existing.phase == StagingPhase.PROMOTED
+
+    def test_reject(self, prod, exported, existing):
+        result = prod.import_snippets(exported, ImportOptions(conflict_policy='reject')) \
+            .records[0]
+        assert result.status == ImportStatus.FAILED and 'already in production' in result.error
+
+    def test_overwrite(self, prod, exported, existing):
+        result = prod.import_snippets(
+            exported, ImportOptions(conflict_policy=ConflictPolicy.OVERWRITE)).records[0]
+        assert result.status == ImportStatus.PROMOTED
+        assert prod.get_snippet(existing.staging_id).phase == StagingPhase.SUPERSEDED
+
+    def test_version_suffix(self, prod, exported, existing):
+        result = prod.import_snippets(
+            exported, ImportOptions(conflict_policy='version_suffix')).records[0]
+        assert result.status == ImportStatus.PROMOTED and result.label == 'svc-v2'
+        assert existing.phase == StagingPhase.PROMOTED
+
+    def test_invalid(self):
+        with pytest.raises(ValueError):
+            ImportOptions(conflict_policy='merge')
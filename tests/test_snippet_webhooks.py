@@ -0,0 +1,124 @@
+"""
+Test suite for snippet promotion webhooks.
+
+Tests cover:
+  - HMAC signing of delivered payloads
+  - Event filtering per target
+  - Exponential backoff retries and dead-lettering
+  - Replay of undelivered events from the append-only log
+  - Pipeline promotion → webhook payload
+"""
+
+import hmac
+import json
+import pytest
+
+from visual_editor_core.snippet_staging import AuditEventType
+from visual_editor_core.snippet_webhooks import (
+    WebhookDispatcher, sign_payload, SIGNATURE_HEADER,
+)
+
+
+class FakeTransport:
+    """Records requests; returns queued status codes (default 200)."""
+
+    def __init__(self, statuses=()):
+        self.statuses = list(statuses)
+        self.requests = []
+
+    def __call__(self, url, body, headers, timeout):
+        self.requests.append((url, body, headers))
+        status = self.statuses.pop(0) if self.statuses else 200
+        if isinstance(status, Exception):
+            raise status
+        return status
+
+
+def _dispatcher(tmp_path, transport, **kwargs):
+    kwargs.setdefault('base_delay', 0.0)
+    return WebhookDispatcher(str(tmp_path / 'webhooks.jsonl'),
+                             transport=transport, **kwargs)
+
+
+class TestWebhookDispatcher:
+
+    def test_signed_delivery(self, tmp_path):
+        transport = FakeTransport()
+        dispatcher = _dispatcher(tmp_path, transport)
+        dispatcher.add_target('http://hooks.example/a', 's3cret')
+        dispatcher.publish('promotion_completed', {'staging_id': 'stg-1'})
+        assert dispatcher.dispatch_due() == 1
+
+        url, body, headers = transport.requests[0]
+        assert url == 'http://hooks.example/a'
+        assert json.loads(body)['staging_id'] == 'stg-1'
+        expected = 'sha256=' + hmac.new(b's3cret', body, 'sha256').hexdigest()
+        assert headers[SIGNATURE_HEADER] == expected == sign_payload('s3cret', body)
+        assert dispatcher.pending() == []
+
+    def test_event_filter(self, tmp_path):
+        dispatcher = _dispatcher(tmp_path, FakeTransport())
+        dispatcher.add_target('http://hooks.example/a', 's', events=[AuditEventType.ROLLBACK])
+        assert dispatcher.publish('promotion_completed', {}) == []
+        assert len(dispatcher.publish(AuditEventType.ROLLBACK, {})) == 1
+
+    def test_retries_then_dead_letters(self, tmp_path):
+        transport = FakeTransport([500, ConnectionError('refused'), 503])
+        dispatcher = _dispatcher(tmp_path, transport, max_retries=2)
+        dispatcher.add_target('http://hooks.example/a', 's')
+        dispatcher.publish('promotion_completed', {})
+        for _ in range(3):
+            dispatcher.dispatch_due(now=float('inf'))
+        assert len(transport.requests) == 3
+        dead = dispatcher.dead_letters()
+        assert len(dead) == 1 and dead[0].attempts == 3
+        assert dispatcher.pending() == []
+
+    def test_backoff_grows_with_jitter(self, tmp_path):
+        dispatcher = _dispatcher(tmp_path, FakeTransport(), base_delay=1.0, max_delay=8.0)
+        for attempt, cap in ((1, 1.0), (2, 2.0), (3, 4.0), (4, 8.0), (9, 8.0)):
+            delay = dispatcher.backoff(attempt)
+            assert cap / 2 <= delay <= cap
+
+    def test_undelivered_events_survive_restart(self, tmp_path):
+        first = _dispatcher(tmp_path, FakeTransport([500]))
+        first.add_target('http://hooks.example/a', 's')
+        first.publish('promotion_completed', {'staging_id': 'stg-1'})
+        first.publish('promotion_completed', {'staging_id': 'stg-2'})
+        first.dispatch_due()  # one fails (500), one is delivered
+
+        transport = FakeTransport()
+        second = _dispatcher(tmp_path, transport)
+        pending = second.pending()
+        assert len(pending) == 1 and pending[0].attempts == 1
+        second.dispatch_due(now=float('inf'))
+        assert json.loads(transport.requests[0][1])['staging_id'] == 'stg-1'
+        assert _dispatcher(tmp_path, FakeTransport()).pending() == []
+
+    def test_validation(self, tmp_path):
+        dispatcher = _dispatcher(tmp_path, FakeTransport())
+        with pytest.raises(ValueError):
+            dispatcher.add_target('ftp://nope', 's')
+        with pytest.raises(ValueError):
+            dispatcher.add_target('http://hooks.example', '')
+
+
+class TestPipelineWebhooks:
+
+    def test_promotion_publishes_payload(self, tmp_path, make_pipeline):
+        transport = FakeTransport()
+        dispatcher = _dispatcher(tmp_path, transport)
+        pipeline = make_pipeline({}, webhooks=dispatcher)
+        pipeline.add_webhook_target('http://hooks.example/a', 's')
+        snippet = pipeline.run_full_pipeline('a', 'python', 'x = 1', 'Calc')
+        dispatcher.dispatch_due()
+
+        payload = json.loads(transport.requests[0][1])
+        assert payload == {
+            'event': 'promotion_completed',
+            'staging_id': snippet.staging_id,
+            'label': 'Calc',
+            'promoted_at': snippet.promoted_at,
+            'spec_result': 'PASS',
+            'slot': snippet.reserved_address,
+        }
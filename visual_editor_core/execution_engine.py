@@ -3,6 +3,7 @@ Execution Engine for live execution and debugging of visual programs.
 
 Supports multiple language executors:
   • PythonExecutor     — in-process exec() with shared namespace (REPL-style)
+  • PythonProcessExecutor — a child interpreter per run (isolated slots)
   • JavaScriptExecutor — subprocess via Node.js
   • RustExecutor       — subprocess via rustc (compile + run)
   • PerlExecutor       — subprocess via perl (Strawberry Perl)
@@ -11,16 +12,19 @@ Supports multiple language executors:
 import sys
 import io
 import os
+import signal
+import threading
 import tempfile
 import subprocess
 import shutil
 import traceback
 import ast
 import time
-from typing import Dict, Any, Optional, List, Callable
+from typing import Dict, Any, Optional, List, Callable, Tuple
 from contextlib import redirect_stdout, redirect_stderr
 from .models import VisualModel, VisualNode, NodeType, InputPort, OutputPort
 from .data_flow_visualizer import DataFlowVisualizer
+from .snippet_tracing import span
 
 
 def _run_subprocess(*args, **kwargs):
@@ -33,16 +37,248 @@ def _run_subprocess(*args, **kwargs):
     return subprocess.run(*args, **kwargs)
 
 
+def _kill_process_tree(proc: subprocess.Popen, kill_grace: float):
+    """Stop a child and everything it spawned: SIGTERM, then SIGKILL.
+
+    Toolchain launchers (`go run`, `cargo run`, …) spawn the compiled
+    binary as a grandchild, so killing only the direct child would leave
+    an infinite loop running.  On POSIX the child leads its own session,
+    so the whole process group is signalled.  Windows uses taskkill /T.
+    """
+    if proc.poll() is not None:
+        return
+    if os.name == 'posix':
+        try:
+            os.killpg(proc.pid, signal.SIGTERM)
+        except (ProcessLookupError, PermissionError):
+            return
+        try:
+            proc.wait(timeout=kill_grace)
+        except subprocess.TimeoutExpired:
+            pass
+        try:
+            # The group may outlive its leader — always finish it off
+            os.killpg(proc.pid, signal.SIGKILL)
+        except (ProcessLookupError, PermissionError):
+            pass
+    else:
+        subprocess.run(['taskkill', '/T', '/F', '/PID', str(proc.pid)],
+                       capture_output=True)
+        try:
+            proc.wait(timeout=kill_grace)
+        except subprocess.TimeoutExpired:
+            proc.kill()
+
+
+def _pump(pipe, name: str, sink: List[str], on_output: Optional[Callable[[str, str], None]],
+          limit: int = 0, on_exceed: Optional[Callable[[], None]] = None):
+    """Forward `pipe` line by line to on_output(name, line), keeping a copy in `sink`.
+
+    With `limit`, at most that many bytes are read (like io.LimitReader):
+    the stream is cut there and on_exceed() called if the child wrote more.
+    """
+    kept = 0
+    while True:
+        line = pipe.readline(limit - kept + 1) if limit else pipe.readline()
+        if not line:
+            break
+        exceeded = False
+        if limit:
+            data = line.encode('utf-8', 'replace')
+            if kept + len(data) > limit:
+                line = data[:limit - kept].decode('utf-8', 'ignore')
+                exceeded = True
+            kept += len(data)
+        if line:
+            sink.append(line)
+            if on_output is not None:
+                try:
+                    on_output(name, line)
+                except Exception:
+                    pass              # A broken listener must not stall the child
+        if exceeded:
+            if on_exceed is not None:
+                on_exceed()
+            break
+    pipe.close()
+
+
+def _run_with_deadline(cmd: List[str], timeout: float, kill_grace: float = 2.0,
+                       on_output: Optional[Callable[[str, str], None]] = None,
+                       limits=None, result_fd: bool = False,
+                       cancel_event: Optional[threading.Event] = None,
+                       isolation=None,
+                       **kwargs) -> Tuple[subprocess.CompletedProcess, bool]:
+    """Run `cmd` with a hard deadline and clean process-tree teardown.
+
+    Returns (completed_process, timed_out).  When the deadline passes the
+    process tree is terminated (see _kill_process_tree) and whatever output
+    was produced so far is returned with timed_out=True.
+
+    With `on_output`, each line is also passed to on_output('stdout' |
+    'stderr', line) as soon as the child writes it.
+
+    With `limits` (a snippet_limits.ResourceLimits) the child runs under
+    them; completed_process.resource_violation names the one that stopped
+    it, or is '' (see snippet_limits).
+
+    With `result_fd` (POSIX) the child also gets a pipe on fd 3, read
+    separately into completed_process.structured_output (see
+    snippet_output); elsewhere that is always ''.
+
+    Setting `cancel_event` tears the process tree down early;
+    completed_process.cancelled is then True.
+
+    With `isolation` (a snippet_isolation.IsolationStrategy) `cmd` is
+    started the way the strategy wraps it, e.g. in new namespaces.
+    """
+    from .snippet_limits import (ResourceLimits, VIOLATION_CPU_TIME, VIOLATION_MEMORY,
+                                 VIOLATION_OUTPUT, classify_exit, cpu_deadline,
+                                 out_of_memory, rlimit_preexec)
+    limits = limits or ResourceLimits()
+    kwargs.setdefault('encoding', 'utf-8')
+    kwargs.setdefault('errors', 'replace')
+    if os.name == 'posix':
+        kwargs['start_new_session'] = True
+    else:
+        kwargs['creationflags'] = kwargs.get('creationflags', 0) | subprocess.CREATE_NEW_PROCESS_GROUP
+    preexec = rlimit_preexec(limits)
+    if preexec is not None:
+        kwargs['preexec_fn'] = preexec
+    cpu_wait = cpu_deadline(limits, timeout)
+    if isolation is not None:
+        cmd = isolation.wrap(cmd)
+
+    result_read = None
+    if result_fd and os.name == 'posix':
+        result_read, result_write = os.pipe()
+        kwargs['preexec_fn'] = _result_fd_preexec(result_write, kwargs.get('preexec_fn'))
+        # Popen would close fd 3 after preexec_fn; the host's own fds are
+        # non-inheritable (PEP 446), so nothing else leaks into the child
+        kwargs['close_fds'] = False
+    try:
+        proc = subprocess.Popen(cmd, stdout=subprocess.PIPE, stderr=subprocess.PIPE, **kwargs)
+    except BaseException:
+        if result_read is not None:
+            os.close(result_read)
+        raise
+    finally:
+        if result_read is not None:
+            os.close(result_write)
+    violation = ''
+    timed_out = False
+    results: List[str] = []
+    cancelled = threading.Event()
+    if cancel_event is not None:
+        def watch():
+            while proc.poll() is None:
+                if cancel_event.wait(0.05):
+                    cancelled.set()
+                    _kill_process_tree(proc, kill_grace)
+                    return
+        threading.Thread(target=watch, daemon=True).start()
+    if on_output is not None or limits.max_output_bytes or result_read is not None:
+        out: List[str] = []
+        err: List[str] = []
+        overflowed = threading.Event()
+
+        def overflow():
+            overflowed.set()
+            _kill_process_tree(proc, kill_grace)
+
+        pumps = [threading.Thread(target=_pump, daemon=True,
+                                  args=(proc.stdout, 'stdout', out, on_output,
+                                        limits.max_output_bytes, overflow)),
+                 threading.Thread(target=_pump, daemon=True,
+                                  args=(proc.stderr, 'stderr', err, on_output,
+                                        limits.max_output_bytes, overflow))]
+        if result_read is not None:
+            result_pipe = os.fdopen(result_read, 'r', encoding='utf-8', errors='replace')
+            pumps.append(threading.Thread(target=_pump, daemon=True,
+                                          args=(result_pipe, 'result', results, None,
+                                                limits.max_output_bytes, overflow)))
+        for pump in pumps:
+            pump.start()
+        try:
+            proc.wait(timeout=cpu_wait or timeout)
+        except subprocess.TimeoutExpired:
+            _kill_process_tree(proc, kill_grace)
+            if cpu_wait:
+                violation = VIOLATION_CPU_TIME
+            else:
+                timed_out = True
+        for pump in pumps:
+            pump.join(kill_grace if timed_out or violation else None)
+        completed = subprocess.CompletedProcess(cmd, proc.returncode, ''.join(out), ''.join(err))
+        if overflowed.is_set():
+            # An out-of-memory crash dump can itself overflow stderr
+            violation = (VIOLATION_MEMORY if out_of_memory(limits, completed.stderr)
+                         else VIOLATION_OUTPUT)
+    else:
+        try:
+            stdout, stderr = proc.communicate(timeout=cpu_wait or timeout)
+        except subprocess.TimeoutExpired:
+            _kill_process_tree(proc, kill_grace)
+            if cpu_wait:
+                violation = VIOLATION_CPU_TIME
+            else:
+                timed_out = True
+            try:
+                stdout, stderr = proc.communicate(timeout=kill_grace)
+            except subprocess.TimeoutExpired:
+                stdout, stderr = '', ''
+        completed = subprocess.CompletedProcess(cmd, proc.returncode, stdout or '', stderr or '')
+    if cancelled.is_set():
+        violation = ''
+    elif not violation and not timed_out:
+        violation = classify_exit(limits, proc.returncode, completed.stderr)
+    completed.resource_violation = violation
+    completed.cancelled = cancelled.is_set()
+    completed.structured_output = ''.join(results)
+    return completed, timed_out
+
+
+def _result_fd_preexec(write_fd: int, then: Optional[Callable[[], None]]) -> Callable[[], None]:
+    """A preexec_fn putting `write_fd` on fd 3 of the child (after `then`)."""
+    from .snippet_output import RESULT_FD
+
+    def apply():
+        if then is not None:
+            then()
+        if write_fd == RESULT_FD:
+            os.set_inheritable(RESULT_FD, True)
+        else:
+            os.dup2(write_fd, RESULT_FD)             # dup2() targets are inheritable
+    return apply
+
+
 class ExecutionResult:
     """Represents the result of executing a visual model."""
     
     def __init__(self, success: bool, output: str = "", error: Optional[Exception] = None, 
-                 variables: Optional[Dict[str, Any]] = None, execution_time: float = 0.0):
+                 variables: Optional[Dict[str, Any]] = None, execution_time: float = 0.0,
+                 timed_out: bool = False, resource_violation: str = '',
+                 structured_output: str = '', cancelled: bool = False,
+                 coverage_percent: Optional[float] = None, coverage_failed: bool = False,
+                 benchmarks: Optional[List[Any]] = None, mutants_tested: int = 0,
+                 survived_mutations: Optional[List[Any]] = None, weak_spec: bool = False,
+                 exit_code: Optional[int] = None):
         self.success = success
         self.output = output
         self.error = error
         self.variables = variables or {}
         self.execution_time = execution_time
+        self.timed_out = timed_out        # Killed for exceeding its deadline
+        self.resource_violation = resource_violation   # snippet_limits.VIOLATION_*, or ''
+        self.structured_output = structured_output     # Written to fd 3 (snippet_output)
+        self.cancelled = cancelled        # Stopped through its cancel_event (snippet_swap)
+        self.coverage_percent = coverage_percent   # Measured by a CoverageGate (snippet_coverage)
+        self.coverage_failed = coverage_failed     # Below the gate's min_coverage_percent
+        self.benchmarks = benchmarks or []         # BenchmarkResults (snippet_bench)
+        self.mutants_tested = mutants_tested       # Mutants its tests were run against (snippet_mutation)
+        self.survived_mutations = survived_mutations or []   # Mutations the tests didn't catch
+        self.weak_spec = weak_spec                 # More survivors than the MutationConfig threshold
+        self.exit_code = exit_code                 # The program's exit status (None = not reported)
         self.traceback = None
         
         if error:
@@ -341,6 +577,99 @@ class PythonExecutor:
         self.global_namespace['__builtins__'] = __builtins__
 
 
+_PY_PROCESS_RUNNER = '''\
+import json, sys
+with open(sys.argv[1], encoding='utf-8') as f:
+    code = f.read()
+with open(sys.argv[2], encoding='utf-8') as f:
+    namespace = json.load(f)
+namespace['__name__'] = '__main__'
+exec(compile(code, '<snippet>', 'exec'), namespace)
+variables = {}
+for name, value in namespace.items():
+    if name.startswith('__') or callable(value) or type(value).__name__ == 'module':
+        continue
+    try:
+        json.dumps(value)
+        variables[name] = value
+    except (TypeError, ValueError):
+        variables[name] = repr(value)[:200]
+with open(sys.argv[3], 'w', encoding='utf-8') as f:
+    json.dump(variables, f)
+'''
+
+
+class PythonProcessExecutor:
+    """Executes Python in a child interpreter of its own, for isolated slots.
+
+    Unlike PythonExecutor nothing is shared with the host: each execute()
+    starts `sys.executable` on the code with the variables set through
+    set_variable_value() (JSON values) bound, and returns the JSON-able
+    globals it leaves behind.  The child has a deadline
+    (`execution_timeout`, None for none), is killed by `cancel_event`,
+    gets only `env` (plus snippet_env's passthrough list) when given one,
+    and starts the way `isolation` (snippet_isolation) wraps it.
+    """
+
+    DEFAULT_KILL_GRACE = 2.0
+    cancellable = True                   # execute() takes cancel_event=
+    isolatable = True                    # execute() takes isolation=
+
+    def __init__(self, execution_timeout: Optional[float] = None,
+                 kill_grace: float = DEFAULT_KILL_GRACE):
+        self.execution_timeout = execution_timeout
+        self.kill_grace = kill_grace
+        self._variables: Dict[str, Any] = {}
+
+    def execute(self, code: str, capture_output: bool = True,
+                env: Optional[Dict[str, str]] = None,
+                cancel_event: Optional[threading.Event] = None,
+                isolation=None) -> ExecutionResult:
+        import json
+        start_time = time.time()
+        tmp_dir = tempfile.mkdtemp(prefix='vpyd_py_')
+        try:
+            paths = [os.path.join(tmp_dir, name)
+                     for name in ('runner.py', 'main.py', 'variables.json', 'result.json')]
+            for path, text in zip(paths, (_PY_PROCESS_RUNNER, code,
+                                          json.dumps(self._variables, default=repr))):
+                with open(path, 'w', encoding='utf-8') as f:
+                    f.write(text)
+            from .snippet_env import child_environ
+            proc, timed_out = _run_with_deadline(
+                [sys.executable, *paths], timeout=self.execution_timeout,
+                kill_grace=self.kill_grace, cancel_event=cancel_event, isolation=isolation,
+                cwd=tmp_dir, env=child_environ(env) if env is not None else None)
+            execution_time = time.time() - start_time
+
+            if proc.cancelled:
+                return ExecutionResult(success=False, output=proc.stdout or '',
+                    error=Exception('Python execution cancelled'),
+                    execution_time=execution_time, cancelled=True)
+            if timed_out:
+                return ExecutionResult(success=False, output=proc.stdout or '',
+                    error=Exception(f'Python execution timed out after {self.execution_timeout:g}s'),
+                    execution_time=execution_time, timed_out=True)
+            if proc.returncode != 0:
+                lines = (proc.stderr or '').strip().splitlines()
+                return ExecutionResult(success=False, output=proc.stdout or '',
+                    error=Exception(lines[-1] if lines else f'python exited with code {proc.returncode}'),
+                    execution_time=execution_time)
+            with open(paths[3], encoding='utf-8') as f:
+                variables = json.load(f)
+            return ExecutionResult(success=True, output=proc.stdout or '', variables=variables,
+                                   execution_time=execution_time)
+        except Exception as e:
+            return ExecutionResult(success=False, error=e, execution_time=time.time() - start_time)
+        finally:
+            shutil.rmtree(tmp_dir, ignore_errors=True)
+
+    def execute_single_statement(self, s): return self.execute(s)
+    def reset_namespace(self): self._variables.clear()
+    def set_variable_value(self, n, v): self._variables[n] = v
+    def get_variable_value(self, n): return self._variables.get(n)
+
+
 # =========================================================================
 # JAVASCRIPT EXECUTOR — Node.js subprocess
 # =========================================================================
@@ -900,22 +1229,83 @@ class CppExecutor:
 
 
 # =========================================================================
-# GO EXECUTOR — go run
+# GO EXECUTOR — go build + run
 # =========================================================================
 
 class GoExecutor:
-    """Executes Go code via `go run`."""
-
-    TIMEOUT_SECONDS = 30
+    """Executes Go code via `go build` + the resulting binary.
+
+    Every run has a hard deadline (`execution_timeout`, default 10 s) shared
+    by the build and the run.  When it is exceeded the process tree is sent
+    SIGTERM, then SIGKILL after `kill_grace` seconds, and the result comes
+    back with timed_out=True so the staging pipeline can record a TIMEOUT verdict.
+
+    execute(code, env=...) starts the built program with only those
+    variables (plus snippet_env's passthrough list) instead of the host
+    environment; the build itself still sees the host's Go settings.
+    execute(code, on_output=...) streams the program's output line by
+    line while it runs (see snippet_stream).
+
+    `resource_limits` (snippet_limits.ResourceLimits) cap the built
+    program's CPU time, memory and output; a run that hits one fails with
+    resource_violation naming it.  execute(code, resource_limits=...)
+    replaces them for one run (a snippet's own limits).
+
+    Whatever the program writes to fd 3 comes back, unparsed, as the
+    result's structured_output (see snippet_output).
+
+    execute(code, cancel_event=...) kills the build or run as soon as the
+    event is set; the result comes back with cancelled=True.
+
+    With a `coverage_gate` (snippet_coverage.CoverageGate), a program that
+    ran cleanly and declares Test functions then has them run under
+    `go test -coverprofile`; the result carries coverage_percent, and
+    fails with coverage_failed=True below the gate's minimum.
+
+    With `benchmark` (snippet_bench.BenchmarkConfig), a program that ran
+    cleanly and declares Benchmark functions then has them run under
+    `go test -bench`; the result carries their BenchmarkResults in
+    `benchmarks`, and a failing benchmark fails the run.
+
+    With `mutation` (snippet_mutation.MutationConfig), a program that ran
+    cleanly and declares Test functions then has them run against
+    mutants of its source; the result carries mutants_tested and
+    survived_mutations, and fails with weak_spec=True when more of them
+    survive than the config's threshold.
+
+    execute(code, isolation=...) starts the built program the way the
+    snippet_isolation strategy wraps it; the build is not isolated.
+    """
 
-    def __init__(self):
+    DEFAULT_EXECUTION_TIMEOUT = 10.0
+    DEFAULT_KILL_GRACE = 2.0
+    streams_output = True                # execute() takes on_output=
+    cancellable = True                   # execute() takes cancel_event=
+    isolatable = True                    # execute() takes isolation=
+    limitable = True                     # execute() takes resource_limits=
+
+    def __init__(self, execution_timeout: float = DEFAULT_EXECUTION_TIMEOUT,
+                 kill_grace: float = DEFAULT_KILL_GRACE,
+                 resource_limits=None, coverage_gate=None, benchmark=None, mutation=None):
+        from .snippet_limits import ResourceLimits
         self._go_path: Optional[str] = shutil.which('go')
-
-    def execute(self, code: str, capture_output: bool = True) -> ExecutionResult:
+        self.execution_timeout = execution_timeout
+        self.kill_grace = kill_grace
+        self.resource_limits = resource_limits or ResourceLimits()
+        self.coverage_gate = coverage_gate
+        self.benchmark = benchmark
+        self.mutation = mutation
+
+    def execute(self, code: str, capture_output: bool = True,
+                env: Optional[Dict[str, str]] = None,
+                on_output: Optional[Callable[[str, str], None]] = None,
+                cancel_event: Optional[threading.Event] = None,
+                isolation=None, resource_limits=None) -> ExecutionResult:
         if not self._go_path:
             return ExecutionResult(success=False, error=Exception(
                 'go not found on PATH — install the Go toolchain'))
 
+        limits = resource_limits or self.resource_limits
         start_time = time.time()
         tmp_dir = None
         try:
@@ -925,25 +1315,121 @@ class GoExecutor:
             with open(src_path, 'w', encoding='utf-8') as f:
                 f.write(code)
 
-            proc = _run_subprocess([self._go_path, 'run', src_path],
-                                  capture_output=True, text=True, timeout=self.TIMEOUT_SECONDS)
+            # Build and run separately (one shared deadline) so the compile
+            # step gets its own trace span
+            bin_path = os.path.join(tmp_dir, 'main.exe' if os.name == 'nt' else 'main')
+            with span('compile', language='go'):
+                proc, timed_out = _run_with_deadline(
+                    [self._go_path, 'build', '-o', bin_path, src_path],
+                    timeout=self.execution_timeout, kill_grace=self.kill_grace, cwd=tmp_dir,
+                    cancel_event=cancel_event)
+            ran = not timed_out and proc.returncode == 0 and not proc.cancelled
+            if ran:
+                remaining = max(0.0, self.execution_timeout - (time.time() - start_time))
+                from .snippet_env import child_environ
+                proc, timed_out = _run_with_deadline(
+                    [bin_path], timeout=remaining, kill_grace=self.kill_grace,
+                    on_output=on_output, limits=limits, result_fd=True,
+                    cancel_event=cancel_event, isolation=isolation,
+                    env=child_environ(env) if env is not None else None)
             execution_time = time.time() - start_time
 
+            if proc.cancelled:
+                return ExecutionResult(success=False, output=proc.stdout or '',
+                    error=Exception('Go execution cancelled'),
+                    execution_time=execution_time, cancelled=True)
+
+            violation = getattr(proc, 'resource_violation', '')
+            if violation:
+                return ExecutionResult(success=False, output=proc.stdout or '',
+                    error=Exception(limits.describe(violation)),
+                    execution_time=execution_time, resource_violation=violation)
+
+            if timed_out:
+                return ExecutionResult(success=False, output=proc.stdout or '',
+                    error=Exception(f'Go execution timed out after {self.execution_timeout:g}s'),
+                    execution_time=execution_time, timed_out=True)
+
             if proc.returncode != 0:
                 return ExecutionResult(success=False, output=proc.stdout or '',
-                    error=Exception(proc.stderr.strip() or f'go run exited with code {proc.returncode}'),
-                    execution_time=execution_time)
+                    error=Exception(proc.stderr.strip() or f'go program exited with code {proc.returncode}'),
+                    execution_time=execution_time,
+                    exit_code=proc.returncode if ran and proc.returncode > 0 else None)
 
-            return ExecutionResult(success=True, output=proc.stdout or '', variables={}, execution_time=execution_time)
-        except subprocess.TimeoutExpired:
-            return ExecutionResult(success=False, error=Exception('Go execution timed out'),
-                                  execution_time=time.time() - start_time)
+            coverage = self._measure_coverage(code, env, cancel_event)
+            if coverage is not None and not coverage.tests_passed:
+                return ExecutionResult(success=False, output=proc.stdout or '',
+                    error=Exception(coverage.error), execution_time=time.time() - start_time,
+                    timed_out=coverage.timed_out)
+            percent = coverage.percent if coverage is not None else None
+            shortfall = self.coverage_gate.describe(percent) if percent is not None else ''
+            if shortfall:
+                return ExecutionResult(success=False, output=proc.stdout or '',
+                    error=Exception(shortfall), execution_time=time.time() - start_time,
+                    coverage_percent=percent, coverage_failed=True)
+
+            bench = self._run_benchmarks(code, env, cancel_event)
+            if bench is not None and not bench.passed:
+                return ExecutionResult(success=False, output=proc.stdout or '',
+                    error=Exception(bench.error), execution_time=time.time() - start_time,
+                    timed_out=bench.timed_out, coverage_percent=percent)
+            benchmarks = bench.results if bench is not None else None
+
+            mutants = self._run_mutations(code, env, cancel_event)
+            if mutants is not None and not mutants.tests_passed:
+                return ExecutionResult(success=False, output=proc.stdout or '',
+                    error=Exception(mutants.error), execution_time=time.time() - start_time,
+                    timed_out=mutants.timed_out, coverage_percent=percent, benchmarks=benchmarks)
+            tested = mutants.counted if mutants is not None else 0
+            survived = mutants.survived if mutants is not None else None
+            weakness = self.mutation.describe(mutants) if mutants is not None else ''
+            if weakness:
+                return ExecutionResult(success=False, output=proc.stdout or '',
+                    error=Exception(weakness), execution_time=time.time() - start_time,
+                    coverage_percent=percent, benchmarks=benchmarks, mutants_tested=tested,
+                    survived_mutations=survived, weak_spec=True)
+
+            return ExecutionResult(success=True, output=proc.stdout or '', variables={}, execution_time=execution_time,
+                                   structured_output=getattr(proc, 'structured_output', ''),
+                                   coverage_percent=percent, benchmarks=benchmarks,
+                                   mutants_tested=tested, survived_mutations=survived, exit_code=0)
         except Exception as e:
             return ExecutionResult(success=False, error=e, execution_time=time.time() - start_time)
         finally:
             if tmp_dir:
                 shutil.rmtree(tmp_dir, ignore_errors=True)
 
+    def _measure_coverage(self, code: str, env: Optional[Dict[str, str]],
+                          cancel_event: Optional[threading.Event]):
+        """The snippet's tests under `go test -cover`, or None when there is nothing to measure."""
+        from .snippet_coverage import has_go_tests, run_go_coverage
+        if self.coverage_gate is None or not has_go_tests(code):
+            return None
+        with span('coverage', language='go'):
+            return run_go_coverage(self._go_path, code, self.coverage_gate, env=env,
+                                   kill_grace=self.kill_grace, cancel_event=cancel_event)
+
+    def _run_benchmarks(self, code: str, env: Optional[Dict[str, str]],
+                        cancel_event: Optional[threading.Event]):
+        """The snippet's benchmarks under `go test -bench`, or None when there are none to run."""
+        from .snippet_bench import has_go_benchmarks, run_go_benchmarks
+        if self.benchmark is None or not has_go_benchmarks(code):
+            return None
+        with span('benchmark', language='go'):
+            return run_go_benchmarks(self._go_path, code, self.benchmark, env=env,
+                                     kill_grace=self.kill_grace, cancel_event=cancel_event)
+
+    def _run_mutations(self, code: str, env: Optional[Dict[str, str]],
+                       cancel_event: Optional[threading.Event]):
+        """The snippet's tests against mutants of it, or None when there are no tests."""
+        from .snippet_coverage import has_go_tests
+        from .snippet_mutation import run_go_mutations
+        if self.mutation is None or not has_go_tests(code):
+            return None
+        with span('mutation', language='go'):
+            return run_go_mutations(self._go_path, code, self.mutation, env=env,
+                                    kill_grace=self.kill_grace, cancel_event=cancel_event)
+
     def execute_single_statement(self, s): return self.execute(s)
     def reset_namespace(self): pass
     def set_variable_value(self, n, v): pass
@@ -0,0 +1,290 @@
+"""
+Snippet A/B Tests — run two labels side by side on one slot and compare.
+
+A canary ramps a new version of the *same* label in; an A/B test splits
+one slot's traffic between two different labels for as long as it takes
+to tell which is better:
+
+    test = pipeline.start_ab_test('i', 'pricing', 'pricing-fast', split_ratio=0.2)
+
+        executions of the control's registry slot
+              │
+              ├── 80 % ──► control    (live version of 'pricing')
+              └── 20 % ──► treatment  (PASSED snippet labelled 'pricing-fast')
+
+The control is the live (PROMOTED) version of its label; the treatment is
+the newest PASSED snippet carrying the other label on the same slot and
+namespace.  Each execution of the control's slot runs the treatment with
+probability `split_ratio` (see StagingPipeline.route_slot()) and reports
+its outcome and execution time back.
+
+ab_test_result() summarises both variants — runs, pass rate, mean and p95
+execution time — and, once each has `min_samples` runs, names a
+recommended winner: the higher pass rate, then the lower mean time; an
+exact tie keeps the control.  end_ab_test() acts on it (or on an explicit
+winner): a winning treatment is promoted and the control's record is
+archived, leaving its registry slot; a winning control stays live and
+the treatment is rejected.
+"""
+
+import random
+import threading
+import time
+import uuid
+from enum import Enum
+from dataclasses import dataclass, field
+from typing import Callable, Dict, List, Optional
+
+from .snippet_report import percentile
+
+
+DEFAULT_MIN_SAMPLES = 30             # runs per variant before a winner is recommended
+
+VARIANT_CONTROL = 'control'
+VARIANT_TREATMENT = 'treatment'
+VARIANTS = (VARIANT_CONTROL, VARIANT_TREATMENT)
+
+
+class ABTestStatus(str, Enum):
+    RUNNING = 'running'
+    ENDED   = 'ended'                # A winner was applied
+    ABORTED = 'aborted'              # Stopped without a winner; both variants kept
+
+
+class ABTestError(ValueError):
+    """An A/B test that can't be started, read or ended as asked."""
+
+
+@dataclass
+class ABVariantStats:
+    """The executions one variant of a test has served."""
+    staging_id: str
+    label: str
+    runs: int = 0
+    passes: int = 0
+    times: List[float] = field(default_factory=list)
+
+    @property
+    def pass_rate(self) -> float:
+        return self.passes / self.runs if self.runs else 0.0
+
+    @property
+    def mean_time(self) -> float:
+        return sum(self.times) / len(self.times) if self.times else 0.0
+
+    @property
+    def p95_time(self) -> float:
+        return percentile(self.times, 95)
+
+    def to_dict(self) -> Dict:
+        return {
+            'staging_id': self.staging_id,
+            'label': self.label,
+            'runs': self.runs,
+            'passes': self.passes,
+            'pass_rate': self.pass_rate,
+            'mean_spec_time': self.mean_time,
+            'p95_spec_time': self.p95_time,
+        }
+
+
+@dataclass
+class ABTest:
+    test_id: str
+    slot: str                        # Engine letter
+    namespace: str
+    slot_id: str                     # Control's registry slot (the one routed)
+    split_ratio: float               # Share of executions sent to the treatment
+    min_samples: int
+    control: ABVariantStats
+    treatment: ABVariantStats
+    status: ABTestStatus = ABTestStatus.RUNNING
+    started_at: float = 0.0
+    ended_at: float = 0.0
+    winner: str = ''                 # VARIANT_CONTROL / VARIANT_TREATMENT once ended
+    reason: str = ''
+
+    def variant(self, name: str) -> ABVariantStats:
+        return self.control if name == VARIANT_CONTROL else self.treatment
+
+    def to_dict(self) -> Dict:
+        return {
+            'test_id': self.test_id,
+            'slot': self.slot,
+            'namespace': self.namespace,
+            'slot_id': self.slot_id,
+            'split_ratio': self.split_ratio,
+            'min_samples': self.min_samples,
+            'control': self.control.to_dict(),
+            'treatment': self.treatment.to_dict(),
+            'status': self.status.value,
+            'started_at': self.started_at,
+            'ended_at': self.ended_at,
+            'winner': self.winner,
+            'reason': self.reason,
+        }
+
+
+@dataclass
+class ABTestReport:
+    """ab_test_result(): both variants and, with enough samples, a winner."""
+    test_id: str
+    status: ABTestStatus
+    min_samples: int
+    control: ABVariantStats
+    treatment: ABVariantStats
+    recommended_winner: str = ''     # '' until both variants have min_samples runs
+
+    @property
+    def conclusive(self) -> bool:
+        return bool(self.recommended_winner)
+
+    def to_dict(self) -> Dict:
+        return {
+            'test_id': self.test_id,
+            'status': self.status.value,
+            'min_samples': self.min_samples,
+            'control': self.control.to_dict(),
+            'treatment': self.treatment.to_dict(),
+            'recommended_winner': self.recommended_winner,
+        }
+
+
+def recommend(control: ABVariantStats, treatment: ABVariantStats, min_samples: int) -> str:
+    """The better variant, or '' while either has fewer than `min_samples` runs."""
+    if min(control.runs, treatment.runs) < max(min_samples, 1):
+        return ''
+    if treatment.pass_rate != control.pass_rate:
+        return VARIANT_TREATMENT if treatment.pass_rate > control.pass_rate else VARIANT_CONTROL
+    return VARIANT_TREATMENT if treatment.mean_time < control.mean_time else VARIANT_CONTROL
+
+
+def validate_split(split_ratio, min_samples):
+    if isinstance(split_ratio, bool) or not isinstance(split_ratio, (int, float)) \
+            or not 0 < split_ratio < 1:
+        raise ABTestError("split_ratio must be in (0, 1)")
+    if isinstance(min_samples, bool) or not isinstance(min_samples, int) or min_samples < 1:
+        raise ABTestError("min_samples must be an integer >= 1")
+
+
+class ABTestController:
+    """
+    Tracks A/B tests (at most one running per registry slot) and their
+    samples.  The pipeline decides what a test compares and what ending
+    it does; the controller only routes and counts.
+    """
+
+    def __init__(self, rng: Callable[[], float] = random.random,
+                 clock: Callable[[], float] = time.time):
+        self._rng = rng
+        self._clock = clock
+        self._lock = threading.Lock()
+        self._tests: Dict[str, ABTest] = {}          # test_id → test
+        self._by_slot: Dict[str, str] = {}           # slot_id → running test_id
+
+    def start(self, slot: str, namespace: str, slot_id: str, split_ratio: float,
+              min_samples: int, control: ABVariantStats,
+              treatment: ABVariantStats) -> ABTest:
+        validate_split(split_ratio, min_samples)
+        test = ABTest(test_id=f"ab-{uuid.uuid4().hex[:12]}", slot=slot, namespace=namespace,
+                      slot_id=slot_id, split_ratio=float(split_ratio),
+                      min_samples=min_samples, control=control, treatment=treatment,
+                      started_at=self._clock())
+        with self._lock:
+            running = self._by_slot.get(slot_id)
+            if running is not None:
+                raise ABTestError(f"Slot {slot_id} already has an A/B test running ({running})")
+            self._tests[test.test_id] = test
+            self._by_slot[slot_id] = test.test_id
+        return test
+
+    def finish(self, test_id: str, status: ABTestStatus, winner: str = '',
+               reason: str = '') -> Optional[ABTest]:
+        """Stop routing for a running test; None if it isn't running."""
+        with self._lock:
+            test = self._tests.get(test_id)
+            if test is None or test.status != ABTestStatus.RUNNING:
+                return None
+            test.status = status
+            test.winner = winner
+            test.reason = reason
+            test.ended_at = self._clock()
+            self._by_slot.pop(test.slot_id, None)
+        return test
+
+    # ── Dispatch ─────────────────────────────────────────────────────
+
+    def choose(self, slot_id: str) -> Optional[str]:
+        """The treatment's staging_id if this execution of `slot_id` should run it."""
+        with self._lock:
+            test_id = self._by_slot.get(slot_id)
+            if test_id is None:
+                return None
+            test = self._tests[test_id]
+            return test.treatment.staging_id if self._rng() < test.split_ratio else None
+
+    def record(self, slot_id: str, treatment: bool, success: bool, execution_time: float):
+        with self._lock:
+            test_id = self._by_slot.get(slot_id)
+            if test_id is None:
+                return
+            stats = self._tests[test_id].variant(
+                VARIANT_TREATMENT if treatment else VARIANT_CONTROL)
+            stats.runs += 1
+            stats.passes += 1 if success else 0
+            stats.times.append(execution_time)
+
+    # ── Queries ──────────────────────────────────────────────────────
+
+    def report(self, test_id: str) -> Optional[ABTestReport]:
+        with self._lock:
+            test = self._tests.get(test_id)
+            if test is None:
+                return None
+            control = ABVariantStats(**{**test.control.__dict__,
+                                        'times': list(test.control.times)})
+            treatment = ABVariantStats(**{**test.treatment.__dict__,
+                                          'times': list(test.treatment.times)})
+            return ABTestReport(test_id=test_id, status=test.status,
+                                min_samples=test.min_samples, control=control,
+                                treatment=treatment,
+                                recommended_winner=recommend(control, treatment,
+                                                             test.min_samples))
+
+    def get(self, test_id: str) -> Optional[ABTest]:
+        with self._lock:
+            return self._tests.get(test_id)
+
+    def for_slot(self, slot_id: str) -> Optional[ABTest]:
+        with self._lock:
+            test_id = self._by_slot.get(slot_id)
+            return self._tests[test_id] if test_id else None
+
+    def involving(self, staging_id: str) -> Optional[ABTest]:
+        """The running test `staging_id` is a variant of, if any."""
+        with self._lock:
+            for test_id in self._by_slot.values():
+                test = self._tests[test_id]
+                if staging_id in (test.control.staging_id, test.treatment.staging_id):
+                    return test
+            return None
+
+    def running(self) -> List[ABTest]:
+        with self._lock:
+            return [self._tests[t] for t in self._by_slot.values()]
+
+    def all(self) -> List[ABTest]:
+        with self._lock:
+            return sorted(self._tests.values(), key=lambda t: t.started_at)
+
+    def rename_ids(self, mapping: Dict[str, str], dry_run: bool = False) -> int:
+        """Rewrite the variants' staging IDs (see snippet_migrate); returns the count."""
+        changed = 0
+        with self._lock:
+            for test in self._tests.values():
+                for variant in (test.control, test.treatment):
+                    if variant.staging_id in mapping:
+                        changed += 1
+                        if not dry_run:
+                            variant.staging_id = mapping[variant.staging_id]
+        return changed
@@ -0,0 +1,116 @@
+"""
+Snippet Approvals — human sign-off before a protected slot changes.
+
+A slot whose SlotConfig sets require_approvals is protected:
+
+    pipeline.configure_slot('i', SlotConfig(require_approvals=2))
+
+Promoting a snippet onto it runs every gate as usual (circuit breaker,
+dependencies, lint) and then, instead of committing, opens a PENDING
+ApprovalRecord and raises PendingApprovalError.  The snippet stays
+PASSED.  Each approve_promotion(staging_id, approver_id) adds one
+Approval; the one that brings the count to `required` completes the
+promotion — with the skip_lint / canary / graceful_swap options it was first asked for —
+before returning.  reject_promotion(staging_id, approver_id, reason)
+closes the record as REJECTED and rejects the snippet.
+
+An approver counts once per record.  Every request, approval and
+rejection is written to the audit trail with the approver ID and its
+timestamp, and the record keeps the same history.
+"""
+
+import time
+from enum import Enum
+from dataclasses import dataclass, field
+from typing import Any, Dict, List, Optional
+
+
+class ApprovalStatus(str, Enum):
+    PENDING  = 'pending'
+    APPROVED = 'approved'                # Reached `required`; promotion was run
+    REJECTED = 'rejected'
+
+
+class ApprovalError(ValueError):
+    """An approval or rejection that can't be applied to the record."""
+
+
+class PendingApprovalError(ValueError):
+    """The snippet's slot needs approvals before it can be promoted."""
+
+    def __init__(self, record: 'ApprovalRecord'):
+        self.record = record
+        super().__init__(
+            f"Promotion of {record.staging_id} to slot '{record.slot}' is waiting for "
+            f"approval ({len(record.approvals)}/{record.required})")
+
+
+@dataclass
+class Approval:
+    approver_id: str
+    approved_at: float
+
+    def to_dict(self) -> Dict:
+        return {'approver_id': self.approver_id, 'approved_at': self.approved_at}
+
+
+@dataclass
+class ApprovalRecord:
+    """The sign-off state of one snippet's promotion."""
+    staging_id: str
+    slot: str
+    namespace: str
+    required: int
+    requested_at: float = field(default_factory=time.time)
+    approvals: List[Approval] = field(default_factory=list)
+    status: ApprovalStatus = ApprovalStatus.PENDING
+    resolved_at: float = 0.0
+    rejected_by: str = ''
+    reason: str = ''
+    # The promote() call the approvals complete
+    skip_lint: bool = False
+    canary: Optional[Any] = None         # CanaryConfig
+    graceful_swap: Optional[Any] = None  # GracefulSwap
+
+    @property
+    def approver_ids(self) -> List[str]:
+        return [a.approver_id for a in self.approvals]
+
+    def approve(self, approver_id: str, now: Optional[float] = None) -> bool:
+        """Add `approver_id`'s approval; True once the record has enough of them."""
+        approver_id = validate_approver(approver_id)
+        if self.status != ApprovalStatus.PENDING:
+            raise ApprovalError(f"Approval for {self.staging_id} is already {self.status.value}")
+        if approver_id in self.approver_ids:
+            raise ApprovalError(f"'{approver_id}' has already approved {self.staging_id}")
+        self.approvals.append(Approval(approver_id, time.time() if now is None else now))
+        return len(self.approvals) >= self.required
+
+    def reject(self, approver_id: str, reason: str, now: Optional[float] = None):
+        approver_id = validate_approver(approver_id)
+        if self.status != ApprovalStatus.PENDING:
+            raise ApprovalError(f"Approval for {self.staging_id} is already {self.status.value}")
+        self.status = ApprovalStatus.REJECTED
+        self.rejected_by = approver_id
+        self.reason = reason
+        self.resolved_at = time.time() if now is None else now
+
+    def to_dict(self) -> Dict:
+        return {
+            'staging_id': self.staging_id,
+            'slot': self.slot,
+            'namespace': self.namespace,
+            'required': self.required,
+            'status': self.status.value,
+            'approvals': [a.to_dict() for a in self.approvals],
+            'requested_at': self.requested_at,
+            'resolved_at': self.resolved_at,
+            'rejected_by': self.rejected_by,
+            'reason': self.reason,
+        }
+
+
+def validate_approver(approver_id) -> str:
+    if not isinstance(approver_id, str) or not approver_id.strip():
+        raise ApprovalError("approver_id must be a non-empty string")
+    return approver_id.strip()
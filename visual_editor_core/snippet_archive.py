@@ -0,0 +1,178 @@
+"""
+Snippet Archive — expire old promotions instead of keeping them forever.
+
+Every promotion leaves a record behind (and its payload in the snippet
+store) long after a newer version supersedes it.  An ArchivalPolicy bounds that per
+slot (engine letter):
+
+    policy = ArchivalPolicy(max_age=30 * 86400, max_versions_per_label=5,
+                            max_size_per_slot_bytes=1_000_000,
+                            cold_storage_path='/var/spokedpy/cold')
+    archivist = Archivist(pipeline, policy, interval=3600)
+    archivist.start()
+
+Records considered are the slot's promotions — live (PROMOTED) or
+retired (SUPERSEDED, ROLLED_BACK, EVICTED).  A record is archived when
+
+    max_age                  it was promoted more than max_age seconds ago
+    max_versions_per_label   its label has that many newer promotions
+    max_size_per_slot_bytes  the slot's records hold more source than that
+                             (retired records go first, then oldest first)
+
+0 disables a limit.  ArchiveAction.MOVE writes the record's source, with
+a metadata header, under cold_storage_path/<namespace>/<slot>/ and keeps
+the record, in the ARCHIVED phase, queryable with
+SnippetFilter(include_archived=True); ArchiveAction.DELETE removes the
+record, and its payload once no other record shares that code_hash.  Either way a live
+record leaves its registry slot and stops counting against SlotConfig
+capacity, and `on_archive(snippet, reason)` is called for each one.
+
+select_for_archive() is a pure function over records, so the rules can
+be tested without a pipeline; the Archivist just applies them on a timer
+(sweep() runs one pass synchronously).
+"""
+
+import threading
+import time
+from enum import Enum
+from dataclasses import dataclass
+from typing import Callable, Dict, Iterable, List, Optional, Tuple
+
+
+DEFAULT_ARCHIVE_INTERVAL = 3600.0        # seconds between Archivist sweeps
+
+REASON_MAX_AGE = 'max_age'
+REASON_MAX_VERSIONS = 'max_versions_per_label'
+REASON_MAX_SIZE = 'max_size_per_slot_bytes'
+
+# Phases of records a policy may archive (PROMOTED is the only live one)
+ARCHIVABLE_PHASES = ('promoted', 'superseded', 'rolled_back', 'evicted')
+
+
+class ArchiveAction(str, Enum):
+    MOVE   = 'move'                      # To cold storage; record stays queryable
+    DELETE = 'delete'                    # File and record removed
+
+
+# on_archive(snippet, reason) — called after each record is archived
+ArchiveHandler = Callable[[object, str], None]
+
+
+@dataclass
+class ArchivalPolicy:
+    """When a slot's promotion records get archived.  0 disables a limit."""
+    max_age: float = 0.0                 # Seconds since promotion
+    max_versions_per_label: int = 0
+    max_size_per_slot_bytes: int = 0
+    action: ArchiveAction = ArchiveAction.MOVE
+    cold_storage_path: str = ''          # Required for MOVE
+    on_archive: Optional[ArchiveHandler] = None
+
+    def __post_init__(self):
+        self.action = ArchiveAction(self.action)
+        if self.max_age < 0 or self.max_versions_per_label < 0 or self.max_size_per_slot_bytes < 0:
+            raise ValueError("Archival limits must be >= 0 (0 = unlimited)")
+        if self.action == ArchiveAction.MOVE and not self.cold_storage_path:
+            raise ValueError("ArchiveAction.MOVE needs a cold_storage_path")
+
+    @property
+    def enabled(self) -> bool:
+        return bool(self.max_age or self.max_versions_per_label or self.max_size_per_slot_bytes)
+
+    def to_dict(self) -> Dict:
+        return {
+            'max_age': self.max_age,
+            'max_versions_per_label': self.max_versions_per_label,
+            'max_size_per_slot_bytes': self.max_size_per_slot_bytes,
+            'action': self.action.value,
+            'cold_storage_path': self.cold_storage_path,
+        }
+
+
+def select_for_archive(policy: ArchivalPolicy, records: Iterable,
+                       now: float) -> List[Tuple[object, str]]:
+    """
+    (record, reason) for every record of ONE slot that `policy` archives,
+    oldest first.  `records` are that slot's StagedSnippets; ones in other
+    phases (still in the pipeline, already archived) are ignored.
+    """
+    candidates = sorted((r for r in records if r.phase.value in ARCHIVABLE_PHASES),
+                        key=lambda r: (r.promoted_at, r.staging_id))
+    chosen: Dict[str, str] = {}
+
+    if policy.max_age:
+        for r in candidates:
+            if r.promoted_at and now - r.promoted_at > policy.max_age:
+                chosen[r.staging_id] = REASON_MAX_AGE
+
+    if policy.max_versions_per_label:
+        by_label: Dict[Tuple[str, str], List] = {}
+        for r in candidates:
+            by_label.setdefault((r.namespace, r.label), []).append(r)
+        for versions in by_label.values():
+            for r in versions[:-policy.max_versions_per_label]:
+                chosen.setdefault(r.staging_id, REASON_MAX_VERSIONS)
+
+    if policy.max_size_per_slot_bytes:
+        kept = [r for r in candidates if r.staging_id not in chosen]
+        total = sum(len(r.code.encode('utf-8')) for r in kept)
+        # Retired records before live ones, oldest first within each
+        for r in sorted(kept, key=lambda r: (r.phase.value == 'promoted', r.promoted_at)):
+            if total <= policy.max_size_per_slot_bytes:
+                break
+            chosen[r.staging_id] = REASON_MAX_SIZE
+            total -= len(r.code.encode('utf-8'))
+
+    return [(r, chosen[r.staging_id]) for r in candidates if r.staging_id in chosen]
+
+
+class Archivist:
+    """Applies an ArchivalPolicy to every slot of a pipeline on a background thread."""
+
+    def __init__(self, pipeline, policy: ArchivalPolicy,
+                 interval: float = DEFAULT_ARCHIVE_INTERVAL,
+                 clock: Callable[[], float] = time.time):
+        if interval <= 0:
+            raise ValueError("interval must be > 0")
+        self._pipeline = pipeline
+        self.policy = policy
+        self._interval = interval
+        self._clock = clock
+        self._stop = threading.Event()
+        self._thread: Optional[threading.Thread] = None
+        self.last_sweep_at = 0.0
+        self.archived_total = 0
+
+    def sweep(self) -> List:
+        """Archive everything the policy selects now; returns the archived snippets."""
+        now = self._clock()
+        archived = self._pipeline.apply_archival_policy(self.policy, now=now)
+        self.last_sweep_at = now
+        self.archived_total += len(archived)
+        return archived
+
+    def start(self):
+        if self._thread is None:
+            self._thread = threading.Thread(target=self._loop, daemon=True, name='archivist')
+            self._thread.start()
+
+    def close(self, timeout: Optional[float] = None):
+        self._stop.set()
+        if self._thread is not None:
+            self._thread.join(timeout)
+
+    def _loop(self):
+        while not self._stop.wait(self._interval):
+            try:
+                self.sweep()
+            except Exception:
+                pass                                  # next sweep retries
+
+    def status(self) -> Dict:
+        return {
+            'policy': self.policy.to_dict(),
+            'interval': self._interval,
+            'running': self._thread is not None and not self._stop.is_set(),
+            'last_sweep_at': self.last_sweep_at,
+            'archived_total': self.archived_total,
+        }
@@ -0,0 +1,199 @@
+"""
+Snippet Benchmarks — how fast a Go snippet's own benchmarks run.
+
+A speculative run's execution_time is the wall clock of one run of
+`main`, which says little about the cost of the code it exercises.  A
+snippet can carry benchmarks alongside `main`:
+
+    func fib(n int) int { … }
+    func main() { fmt.Println(fib(20)) }
+
+    func BenchmarkFib(b *testing.B) {
+        for i := 0; i < b.N; i++ { fib(20) }
+    }
+
+With a BenchmarkConfig on the executor (or GoEngine) — the
+benchmark_on_promote setting —
+
+    GoExecutor(benchmark=BenchmarkConfig(benchtime='3s'))
+
+a Go snippet that declares `func BenchmarkXxx(b *testing.B)` functions
+is, once its program has run cleanly, split into main.go and
+main_test.go (snippet_coverage.split_go_tests()) and run under
+`go test -run=^$ -bench=. -benchtime=3s -benchmem`.  The parsed results
+(parse_bench_output()) are stored on the snippet as `benchmarks`, one
+BenchmarkResult per function.  A benchmark that fails or panics fails
+the run as FAIL; snippets without benchmarks are not measured.
+
+The pipeline's benchmark_regression_gate (a percentage; 0 disables it)
+then holds promotion to the live version of the label: a benchmark
+whose ns/op grew by more than that percentage over the live version's
+result for the same benchmark fails the snippet with spec_result
+BENCH_REGRESSION (find_regressions()).  Benchmarks the live version
+didn't have, or a live version that was never measured, pass.
+"""
+
+import os
+import re
+import shutil
+import tempfile
+import threading
+from dataclasses import dataclass, asdict
+from typing import Dict, Iterable, List, Optional
+
+from .snippet_coverage import split_go_tests
+
+
+DEFAULT_BENCHTIME = '3s'
+DEFAULT_BENCH_TIMEOUT = 300.0            # Seconds `go test -bench` may take
+
+_GO_BENCH_FUNC = re.compile(r'^func\s+Benchmark[A-Z_0-9]\w*\s*\(\s*\w+\s+\*testing\.B\s*\)\s*\{',
+                            re.MULTILINE)
+# `BenchmarkFib-8   1000000   1234 ns/op   16 B/op   1 allocs/op` (-8 is GOMAXPROCS)
+_BENCH_LINE = re.compile(r'^(Benchmark\S*?)(?:-\d+)?\s+(\d+)\s+(.+)$')
+_BENCHTIME = re.compile(r'^(\d+x|\d+(\.\d+)?(ns|us|µs|ms|s|m|h))$')
+
+
+@dataclass
+class BenchmarkConfig:
+    """How a Go snippet's Benchmark functions are run."""
+    benchtime: str = DEFAULT_BENCHTIME   # go test -benchtime: a duration or `<N>x`
+    timeout: float = DEFAULT_BENCH_TIMEOUT
+
+    def __post_init__(self):
+        if not _BENCHTIME.match(self.benchtime or ''):
+            raise ValueError(f"Invalid benchtime '{self.benchtime}': use a duration (3s, "
+                             f"500ms) or an iteration count (100x)")
+        if self.timeout <= 0:
+            raise ValueError("Benchmark timeout must be > 0")
+
+    def to_dict(self) -> Dict:
+        return asdict(self)
+
+
+@dataclass
+class BenchmarkResult:
+    """One benchmark function's line of `go test -bench -benchmem` output."""
+    name: str                            # BenchmarkFib (without the -GOMAXPROCS suffix)
+    iterations: int = 0
+    ns_per_op: float = 0.0
+    bytes_per_op: Optional[int] = None   # None without -benchmem
+    allocs_per_op: Optional[int] = None
+
+    def to_dict(self) -> Dict:
+        return asdict(self)
+
+    @classmethod
+    def from_dict(cls, d: Dict) -> 'BenchmarkResult':
+        return cls(name=d['name'], iterations=int(d.get('iterations', 0)),
+                   ns_per_op=float(d.get('ns_per_op', 0.0)),
+                   bytes_per_op=d.get('bytes_per_op'), allocs_per_op=d.get('allocs_per_op'))
+
+
+@dataclass
+class BenchmarkRun:
+    """Outcome of running a snippet's benchmarks under `go test -bench`."""
+    passed: bool
+    results: List[BenchmarkResult]
+    output: str = ''
+    error: str = ''
+    timed_out: bool = False
+
+
+@dataclass
+class BenchmarkRegression:
+    """A benchmark that got slower than the live version's by more than the gate allows."""
+    name: str
+    previous_ns_per_op: float
+    ns_per_op: float
+
+    @property
+    def percent(self) -> float:
+        return 100.0 * (self.ns_per_op - self.previous_ns_per_op) / self.previous_ns_per_op
+
+    def describe(self) -> str:
+        return (f"{self.name}: {self.previous_ns_per_op:g} → {self.ns_per_op:g} ns/op "
+                f"(+{self.percent:.1f}%)")
+
+    def to_dict(self) -> Dict:
+        return {**asdict(self), 'percent': round(self.percent, 2)}
+
+
+class BenchmarkRegressionError(ValueError):
+    """Promotion blocked: benchmarks regressed past benchmark_regression_gate."""
+
+    def __init__(self, message: str, regressions: List[BenchmarkRegression]):
+        super().__init__(message)
+        self.regressions = regressions
+
+
+def has_go_benchmarks(code: str) -> bool:
+    return bool(_GO_BENCH_FUNC.search(code))
+
+
+def parse_bench_output(output: str) -> List[BenchmarkResult]:
+    """The benchmark lines of `go test -bench` output; other lines are ignored."""
+    results = []
+    for line in output.splitlines():
+        match = _BENCH_LINE.match(line.strip())
+        if not match:
+            continue
+        result = BenchmarkResult(match.group(1), iterations=int(match.group(2)))
+        fields = match.group(3).split()
+        for value, unit in zip(fields[::2], fields[1::2]):
+            try:
+                number = float(value)
+            except ValueError:
+                continue
+            if unit == 'ns/op':
+                result.ns_per_op = number
+            elif unit == 'B/op':
+                result.bytes_per_op = int(number)
+            elif unit == 'allocs/op':
+                result.allocs_per_op = int(number)
+        results.append(result)
+    return results
+
+
+def find_regressions(previous: Iterable[BenchmarkResult], current: Iterable[BenchmarkResult],
+                     max_percent: float) -> List[BenchmarkRegression]:
+    """Benchmarks in both whose ns/op grew by more than `max_percent` percent."""
+    before = {r.name: r for r in previous if r.ns_per_op > 0}
+    regressions = []
+    for result in current:
+        old = before.get(result.name)
+        if old is None:
+            continue
+        regression = BenchmarkRegression(result.name, old.ns_per_op, result.ns_per_op)
+        if regression.percent > max_percent:
+            regressions.append(regression)
+    return regressions
+
+
+def run_go_benchmarks(go_path: str, code: str, config: BenchmarkConfig,
+                      env: Optional[Dict[str, str]] = None, kill_grace: float = 2.0,
+                      cancel_event: Optional[threading.Event] = None) -> BenchmarkRun:
+    """Split `code`, run its benchmarks (tests skipped) with -benchmem and parse the output."""
+    from .execution_engine import _run_with_deadline
+    main_src, test_src = split_go_tests(code)
+    tmp_dir = tempfile.mkdtemp(prefix='vpyd_bench_')
+    try:
+        for name, text in (('main.go', main_src), ('main_test.go', test_src)):
+            with open(os.path.join(tmp_dir, name), 'w', encoding='utf-8') as f:
+                f.write(text)
+        proc, timed_out = _run_with_deadline(
+            [go_path, 'test', '-count=1', '-run=^$', '-bench=.', f'-benchtime={config.benchtime}',
+             '-benchmem', 'main.go', 'main_test.go'],
+            timeout=config.timeout, kill_grace=kill_grace, cwd=tmp_dir,
+            cancel_event=cancel_event,
+            env={**os.environ, **env} if env else None)
+        output = f"{proc.stdout or ''}{proc.stderr or ''}".strip()
+        if timed_out:
+            return BenchmarkRun(False, [], output=output, timed_out=True,
+                                error=f"go test -bench timed out after {config.timeout:g}s")
+        if proc.returncode != 0:
+            return BenchmarkRun(False, [], output=output,
+                                error=f"Snippet benchmarks failed:\n{output}")
+        return BenchmarkRun(True, parse_bench_output(proc.stdout or ''), output=output)
+    finally:
+        shutil.rmtree(tmp_dir, ignore_errors=True)
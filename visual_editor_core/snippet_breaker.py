@@ -0,0 +1,222 @@
+"""
+Snippet Circuit Breaker — stop re-running code that keeps failing.
+
+A snippet that panics or times out on every speculative run would
+otherwise be retried forever, each attempt holding a worker (and, for
+subprocess engines, a process) for up to its full deadline.  The breaker
+counts failed runs per snippet and, once `failure_threshold` of them
+happen in a row within `window` seconds, opens:
+
+        CLOSED ── N consecutive failures within window ──► OPEN
+          ▲                                                  │
+          │ trial passes                   recovery_delay    │
+          │                                    elapsed       ▼
+          └──────────────────────────────────────────── HALF_OPEN
+                               trial fails ──► OPEN (delay restarts)
+
+While OPEN, speculate() and promote() raise CircuitOpenError at once.
+After `recovery_delay` one trial run is let through (HALF_OPEN); its
+outcome closes the circuit again or re-opens it.
+
+"Per snippet" means per (slot, code_hash): re-staging the same failing
+code on the same slot gets a new staging_id but the same breaker.
+"""
+
+import threading
+import time
+from enum import Enum
+from dataclasses import dataclass, field, asdict
+from typing import Callable, Dict, List, Optional
+
+
+DEFAULT_FAILURE_THRESHOLD = 3
+DEFAULT_WINDOW = 300.0               # seconds
+DEFAULT_RECOVERY_DELAY = 60.0        # seconds
+
+
+class BreakerState(str, Enum):
+    CLOSED    = 'closed'             # Runs allowed; failures being counted
+    OPEN      = 'open'               # Runs refused until recovery_delay passes
+    HALF_OPEN = 'half_open'          # One trial run allowed
+
+
+class CircuitOpenError(ValueError):
+    """The snippet's circuit is open; the run or promotion was refused."""
+
+    def __init__(self, message: str, key: str, retry_at: float):
+        super().__init__(message)
+        self.key = key
+        self.retry_at = retry_at         # when the circuit goes HALF_OPEN (0 = trial in flight)
+
+
+@dataclass
+class BreakerConfig:
+    """Trip and recovery settings.  failure_threshold 0 disables the breaker."""
+    failure_threshold: int = DEFAULT_FAILURE_THRESHOLD
+    window: float = DEFAULT_WINDOW
+    recovery_delay: float = DEFAULT_RECOVERY_DELAY
+
+    def __post_init__(self):
+        if self.failure_threshold < 0:
+            raise ValueError("failure_threshold must be >= 0 (0 = disabled)")
+        if self.window <= 0 or self.recovery_delay < 0:
+            raise ValueError("window must be > 0 and recovery_delay >= 0")
+
+    def to_dict(self) -> Dict:
+        return asdict(self)
+
+
+@dataclass
+class _Circuit:
+    state: BreakerState = BreakerState.CLOSED
+    failures: List[float] = field(default_factory=list)   # timestamps of the failure streak
+    opened_at: float = 0.0
+    trial_in_flight: bool = False
+
+
+class CircuitBreaker:
+    """Per-key circuit breakers sharing one BreakerConfig; thread-safe."""
+
+    def __init__(self, config: Optional[BreakerConfig] = None,
+                 clock: Callable[[], float] = time.time):
+        self._config = config or BreakerConfig()
+        self._clock = clock
+        self._lock = threading.Lock()
+        self._circuits: Dict[str, _Circuit] = {}
+
+    @property
+    def config(self) -> BreakerConfig:
+        return self._config
+
+    @property
+    def enabled(self) -> bool:
+        return self._config.failure_threshold > 0
+
+    def configure(self, config: BreakerConfig):
+        with self._lock:
+            self._config = config
+
+    # ── Queries ──────────────────────────────────────────────────────
+
+    def state(self, key: str) -> BreakerState:
+        with self._lock:
+            circuit = self._circuits.get(key)
+            if circuit is None:
+                return BreakerState.CLOSED
+            self._advance(circuit)
+            return circuit.state
+
+    def describe(self, key: str) -> Dict:
+        """State plus the numbers behind it (for the API)."""
+        with self._lock:
+            circuit = self._circuits.get(key) or _Circuit()
+            self._advance(circuit)
+            retry_at = (circuit.opened_at + self._config.recovery_delay
+                        if circuit.state == BreakerState.OPEN else 0.0)
+            return {
+                'state': circuit.state.value,
+                'consecutive_failures': len(circuit.failures),
+                'failure_threshold': self._config.failure_threshold,
+                'opened_at': circuit.opened_at or None,
+                'retry_at': retry_at or None,
+                'trial_in_flight': circuit.trial_in_flight,
+            }
+
+    def open_circuits(self) -> List[str]:
+        with self._lock:
+            for circuit in self._circuits.values():
+                self._advance(circuit)
+            return sorted(k for k, c in self._circuits.items()
+                          if c.state != BreakerState.CLOSED)
+
+    # ── Gates ────────────────────────────────────────────────────────
+
+    def allow(self, key: str):
+        """
+        Admit one run for `key`, or raise CircuitOpenError.
+
+        In HALF_OPEN the first caller becomes the trial run; others are
+        refused until its outcome is recorded.
+        """
+        if not self.enabled:
+            return
+        with self._lock:
+            circuit = self._circuits.get(key)
+            if circuit is None:
+                return
+            self._advance(circuit)
+            if circuit.state == BreakerState.CLOSED:
+                return
+            if circuit.state == BreakerState.HALF_OPEN and not circuit.trial_in_flight:
+                circuit.trial_in_flight = True
+                return
+            raise self._open_error(key, circuit)
+
+    def check(self, key: str):
+        """Raise CircuitOpenError unless the circuit is CLOSED (consumes no trial)."""
+        if not self.enabled:
+            return
+        with self._lock:
+            circuit = self._circuits.get(key)
+            if circuit is None:
+                return
+            self._advance(circuit)
+            if circuit.state != BreakerState.CLOSED:
+                raise self._open_error(key, circuit)
+
+    # ── Outcomes ─────────────────────────────────────────────────────
+
+    def record_success(self, key: str) -> Optional[BreakerState]:
+        """A run passed.  Returns the new state if the circuit changed."""
+        with self._lock:
+            circuit = self._circuits.pop(key, None)
+        if circuit is not None and circuit.state != BreakerState.CLOSED:
+            return BreakerState.CLOSED
+        return None
+
+    def record_failure(self, key: str) -> Optional[BreakerState]:
+        """A run failed or timed out.  Returns the new state if the circuit changed."""
+        if not self.enabled:
+            return None
+        now = self._clock()
+        with self._lock:
+            circuit = self._circuits.setdefault(key, _Circuit())
+            self._advance(circuit)
+            if circuit.state == BreakerState.HALF_OPEN:
+                circuit.state = BreakerState.OPEN
+                circuit.opened_at = now
+                circuit.trial_in_flight = False
+                return BreakerState.OPEN
+            if circuit.state == BreakerState.OPEN:
+                return None
+            horizon = now - self._config.window
+            circuit.failures = [t for t in circuit.failures if t >= horizon] + [now]
+            if len(circuit.failures) >= self._config.failure_threshold:
+                circuit.state = BreakerState.OPEN
+                circuit.opened_at = now
+                return BreakerState.OPEN
+            return None
+
+    def reset(self, key: str) -> bool:
+        """Force the circuit CLOSED; True if there was anything to clear."""
+        with self._lock:
+            return self._circuits.pop(key, None) is not None
+
+    # ── Internals ────────────────────────────────────────────────────
+
+    def _advance(self, circuit: _Circuit):
+        if (circuit.state == BreakerState.OPEN
+                and self._clock() - circuit.opened_at >= self._config.recovery_delay):
+            circuit.state = BreakerState.HALF_OPEN
+            circuit.trial_in_flight = False
+
+    def _open_error(self, key: str, circuit: _Circuit) -> CircuitOpenError:
+        if circuit.state == BreakerState.HALF_OPEN:
+            return CircuitOpenError(
+                f"Circuit for {key} is half-open and its trial run is in progress",
+                key, 0.0)
+        retry_at = circuit.opened_at + self._config.recovery_delay
+        return CircuitOpenError(
+            f"Circuit for {key} is open after {len(circuit.failures)} consecutive "
+            f"failure(s); retry in {max(0.0, retry_at - self._clock()):.0f}s",
+            key, retry_at)
@@ -0,0 +1,146 @@
+"""
+Snippet Result Cache — skip re-running deterministic snippets on the same inputs.
+
+    pipeline = StagingPipeline(..., result_cache=ResultCache(ttl=300, max_entries=1000))
+    pipeline.queue_snippet('i', 'go', code, 'fib', deterministic=True)
+
+A snippet staged with deterministic=True promises that the same source,
+arguments and env always produce the same run.  Its speculation is then
+looked up by
+
+    sha256(code_hash, sorted-key JSON of the bound arguments, sorted-key JSON of env,
+           sorted-key JSON of its own resource_limits, the slot's isolation level,
+           namespace)
+
+(cache_key()) before anything executes.  The limits and isolation are
+part of the key because they change what a run may do — a PASS under
+loose limits says nothing about tighter ones — and the namespace because
+a hit would otherwise tell one tenant that another already ran the code.  On a hit the stored run — its
+output, error, execution time, fd 3 structured output, coverage and
+benchmarks — is applied exactly as a fresh one would be, so spec_result,
+spec_execution_time and spec_output_value come back without running the
+snippet; the snippet's `spec_cached` says so and the audit entry carries
+`cached: true`.  On a miss the run's result is stored.  Runs that timed
+out, were cancelled or raised are never stored — they say more about the
+host than about the snippet.
+
+speculate(force_run=True) runs the snippet regardless and drops the
+entry for its key first, so the fresh result replaces it.
+
+Entries expire `ttl` seconds after they were stored (DEFAULT_CACHE_TTL,
+five minutes); past `max_entries` the least recently used entry is
+evicted.  Snippets not marked deterministic are never looked up, and
+reruns and replays always execute — they exist to see what happens now.
+"""
+
+import copy
+import hashlib
+import json
+import threading
+import time
+from collections import OrderedDict
+from dataclasses import dataclass
+from typing import Any, Callable, Dict, Optional, Tuple
+
+
+DEFAULT_CACHE_TTL = 300.0                # Seconds an entry is served
+DEFAULT_CACHE_MAX_ENTRIES = 1000
+
+
+def cache_key(code_hash: str, arguments: Optional[Dict[str, Any]],
+              env: Optional[Dict[str, str]],
+              resource_limits: Optional[Dict[str, float]] = None,
+              isolation: str = '', namespace: str = '') -> str:
+    """The cache key of one run: source, arguments, env, limits, isolation, namespace."""
+    params = json.dumps(arguments or {}, sort_keys=True, separators=(',', ':'), default=str)
+    environ = json.dumps(env or {}, sort_keys=True, separators=(',', ':'))
+    limits = json.dumps(resource_limits or {}, sort_keys=True, separators=(',', ':'))
+    parts = (code_hash, params, environ, limits, isolation, namespace)
+    return hashlib.sha256('\n'.join(parts).encode('utf-8')).hexdigest()
+
+
+@dataclass
+class CacheStats:
+    hits: int = 0
+    misses: int = 0
+    evictions: int = 0                   # Dropped for max_entries
+    expirations: int = 0                 # Dropped for ttl
+    invalidations: int = 0               # Dropped by force_run / invalidate()
+    entries: int = 0
+
+    def to_dict(self) -> Dict[str, int]:
+        return {
+            'hits': self.hits,
+            'misses': self.misses,
+            'evictions': self.evictions,
+            'expirations': self.expirations,
+            'invalidations': self.invalidations,
+            'entries': self.entries,
+        }
+
+
+class ResultCache:
+    """Thread-safe TTL + LRU map of cache_key() → a run's result dict."""
+
+    def __init__(self, ttl: float = DEFAULT_CACHE_TTL,
+                 max_entries: int = DEFAULT_CACHE_MAX_ENTRIES,
+                 clock: Callable[[], float] = time.time):
+        if ttl <= 0:
+            raise ValueError("Result cache ttl must be > 0")
+        if max_entries < 1:
+            raise ValueError("Result cache max_entries must be >= 1")
+        self.ttl = ttl
+        self.max_entries = max_entries
+        self._clock = clock
+        self._lock = threading.Lock()
+        self._entries: 'OrderedDict[str, Tuple[float, Dict[str, Any]]]' = OrderedDict()
+        self._stats = CacheStats()
+
+    def get(self, key: str) -> Optional[Dict[str, Any]]:
+        """A copy of the stored result, or None (counted as a miss) if absent or expired."""
+        now = self._clock()
+        with self._lock:
+            entry = self._entries.get(key)
+            if entry is not None and now - entry[0] >= self.ttl:
+                del self._entries[key]
+                self._stats.expirations += 1
+                entry = None
+            if entry is None:
+                self._stats.misses += 1
+                return None
+            self._entries.move_to_end(key)
+            self._stats.hits += 1
+            return copy.deepcopy(entry[1])
+
+    def put(self, key: str, result: Dict[str, Any]):
+        with self._lock:
+            self._entries[key] = (self._clock(), copy.deepcopy(result))
+            self._entries.move_to_end(key)
+            while len(self._entries) > self.max_entries:
+                self._entries.popitem(last=False)
+                self._stats.evictions += 1
+
+    def invalidate(self, key: str) -> bool:
+        """Drop `key`; whether there was an entry."""
+        with self._lock:
+            if self._entries.pop(key, None) is None:
+                return False
+            self._stats.invalidations += 1
+            return True
+
+    def clear(self) -> int:
+        """Drop every entry; how many there were."""
+        with self._lock:
+            count = len(self._entries)
+            self._entries.clear()
+            self._stats.invalidations += count
+            return count
+
+    def stats(self) -> CacheStats:
+        with self._lock:
+            stats = CacheStats(**self._stats.to_dict())
+            stats.entries = len(self._entries)
+            return stats
+
+    def describe(self) -> Dict[str, Any]:
+        return {'ttl': self.ttl, 'max_entries': self.max_entries, **self.stats().to_dict()}
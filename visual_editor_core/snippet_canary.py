@@ -0,0 +1,292 @@
+"""
+Snippet Canary — promote a new version gradually instead of all at once.
+
+A normal promotion swaps the code behind a slot address in one step.  A
+canary promotion leaves the live version (the baseline) in place and
+sends a growing share of the slot's executions to the new snippet:
+
+    promote(id, canary=CanaryConfig(start_weight=0.05, step_size=0.10,
+                                    step_interval=60, error_threshold=0.02))
+
+        weight 0.05 ──60 s──► 0.15 ──60 s──► 0.25 … ──► 1.0  → promoted
+                 │
+                 └── canary error rate > error_threshold  → rolled back
+
+Each execution of the slot picks the canary with probability `weight`
+(see StagingPipeline.route_slot()) and reports the outcome back.  Every
+`step_interval` the controller checks the canary's error rate: above the
+threshold the canary is rejected and the baseline keeps the slot;
+otherwise the weight grows by `step_size`, and once it reaches 1.0 the
+canary is promoted for real (superseding the baseline, so rollback()
+works as usual).
+
+The controller runs its steps on a background thread; tick() performs
+one pass synchronously (tests drive it that way).
+"""
+
+import random
+import threading
+import time
+from enum import Enum
+from dataclasses import dataclass, field, asdict
+from typing import Callable, Dict, List, Optional
+
+
+DEFAULT_POLL_INTERVAL = 1.0          # seconds between controller passes
+
+
+class CanaryStatus(str, Enum):
+    RUNNING     = 'running'
+    COMPLETED   = 'completed'        # Weight reached 1.0; canary promoted
+    ROLLED_BACK = 'rolled_back'      # Error threshold exceeded; canary rejected
+    ABORTED     = 'aborted'          # Stopped by an operator
+
+
+@dataclass
+class CanaryConfig:
+    """Traffic ramp for one canary promotion."""
+    start_weight: float = 0.05        # Share of executions sent to the canary at first
+    step_size: float = 0.05           # Added to the weight at every healthy step
+    step_interval: float = 60.0       # Seconds between steps
+    error_threshold: float = 0.05     # Max canary error rate (failed / executions)
+    min_executions: int = 0           # Canary runs needed before a step (0 = step on schedule)
+
+    def __post_init__(self):
+        if not 0 < self.start_weight <= 1:
+            raise ValueError("start_weight must be in (0, 1]")
+        if not 0 < self.step_size <= 1:
+            raise ValueError("step_size must be in (0, 1]")
+        if self.step_interval <= 0:
+            raise ValueError("step_interval must be > 0")
+        if not 0 <= self.error_threshold <= 1:
+            raise ValueError("error_threshold must be in [0, 1]")
+        if self.min_executions < 0:
+            raise ValueError("min_executions must be >= 0")
+
+    @classmethod
+    def from_dict(cls, d: Dict) -> 'CanaryConfig':
+        known = {k: d[k] for k in cls.__dataclass_fields__ if k in d}
+        unknown = sorted(set(d) - set(known))
+        if unknown:
+            raise ValueError(f"Unknown canary option(s): {', '.join(unknown)}")
+        return cls(**known)
+
+    def to_dict(self) -> Dict:
+        return asdict(self)
+
+
+@dataclass
+class CanaryRollout:
+    """One canary promotion in progress (or finished)."""
+    canary_id: str                    # staging_id of the new version
+    baseline_id: str                  # staging_id of the live version it replaces
+    slot_id: str                      # Registry slot both are served from
+    config: CanaryConfig
+    weight: float = 0.0
+    started_at: float = 0.0
+    last_step_at: float = 0.0
+    canary_runs: int = 0
+    canary_errors: int = 0
+    baseline_runs: int = 0
+    baseline_errors: int = 0
+    status: CanaryStatus = CanaryStatus.RUNNING
+    finished_at: float = 0.0
+    reason: str = ''
+    steps: List[Dict] = field(default_factory=list)     # {at, weight, error_rate}
+
+    @property
+    def error_rate(self) -> float:
+        return self.canary_errors / self.canary_runs if self.canary_runs else 0.0
+
+    @property
+    def baseline_error_rate(self) -> float:
+        return self.baseline_errors / self.baseline_runs if self.baseline_runs else 0.0
+
+    def to_dict(self) -> Dict:
+        d = asdict(self)
+        d['status'] = self.status.value
+        d['error_rate'] = self.error_rate
+        d['baseline_error_rate'] = self.baseline_error_rate
+        return d
+
+
+class CanaryController:
+    """
+    Tracks running canaries (at most one per slot) and steps them.
+
+    bind() attaches the pipeline; the pipeline calls start() from
+    promote(canary=...) and finish_canary() is called back on it when a
+    rollout completes or rolls back.
+    """
+
+    def __init__(self, poll_interval: float = DEFAULT_POLL_INTERVAL,
+                 rng: Callable[[], float] = random.random,
+                 clock: Callable[[], float] = time.time,
+                 background: bool = True):
+        self._poll_interval = poll_interval
+        self._rng = rng
+        self._clock = clock
+        self._background = background
+        self._pipeline = None
+        self._lock = threading.Lock()
+        self._rollouts: Dict[str, CanaryRollout] = {}     # canary_id → rollout
+        self._by_slot: Dict[str, str] = {}                # slot_id → running canary_id
+        self._stop = threading.Event()
+        self._thread: Optional[threading.Thread] = None
+
+    def bind(self, pipeline):
+        self._pipeline = pipeline
+
+    # ── Lifecycle ────────────────────────────────────────────────────
+
+    def start(self, canary_id: str, baseline_id: str, slot_id: str,
+              config: CanaryConfig) -> CanaryRollout:
+        now = self._clock()
+        rollout = CanaryRollout(canary_id=canary_id, baseline_id=baseline_id,
+                                slot_id=slot_id, config=config,
+                                weight=config.start_weight,
+                                started_at=now, last_step_at=now)
+        rollout.steps.append({'at': now, 'weight': rollout.weight, 'error_rate': 0.0})
+        with self._lock:
+            running = self._by_slot.get(slot_id)
+            if running is not None:
+                raise ValueError(f"Slot {slot_id} already has a canary running ({running})")
+            self._rollouts[canary_id] = rollout
+            self._by_slot[slot_id] = canary_id
+            if self._background and self._thread is None:
+                self._thread = threading.Thread(target=self._loop, daemon=True,
+                                                name='canary-controller')
+                self._thread.start()
+        return rollout
+
+    def abort(self, canary_id: str, reason: str = '') -> CanaryRollout:
+        """Stop a running canary; the baseline keeps the slot."""
+        rollout = self._finish(canary_id, CanaryStatus.ABORTED,
+                               reason or 'Aborted by operator')
+        if rollout is None:
+            raise ValueError(f"No running canary '{canary_id}'")
+        return rollout
+
+    def close(self, timeout: Optional[float] = None):
+        self._stop.set()
+        if self._thread is not None:
+            self._thread.join(timeout)
+
+    # ── Dispatch ─────────────────────────────────────────────────────
+
+    def choose(self, slot_id: str) -> Optional[str]:
+        """canary_id if this execution of `slot_id` should run the canary."""
+        with self._lock:
+            canary_id = self._by_slot.get(slot_id)
+            if canary_id is None:
+                return None
+            return canary_id if self._rng() < self._rollouts[canary_id].weight else None
+
+    def record(self, slot_id: str, canary: bool, success: bool):
+        with self._lock:
+            canary_id = self._by_slot.get(slot_id)
+            if canary_id is None:
+                return
+            rollout = self._rollouts[canary_id]
+            if canary:
+                rollout.canary_runs += 1
+                rollout.canary_errors += 0 if success else 1
+            else:
+                rollout.baseline_runs += 1
+                rollout.baseline_errors += 0 if success else 1
+
+    # ── Stepping ─────────────────────────────────────────────────────
+
+    def tick(self):
+        """Step every running canary whose step_interval has elapsed."""
+        now = self._clock()
+        due, stepped = [], []
+        with self._lock:
+            for canary_id in list(self._by_slot.values()):
+                rollout = self._rollouts[canary_id]
+                if now - rollout.last_step_at < rollout.config.step_interval:
+                    continue
+                config = rollout.config
+                if rollout.canary_runs and rollout.error_rate > config.error_threshold:
+                    due.append((canary_id, CanaryStatus.ROLLED_BACK,
+                                f"Canary error rate {rollout.error_rate:.1%} exceeded "
+                                f"{config.error_threshold:.1%} "
+                                f"({rollout.canary_errors}/{rollout.canary_runs} failed)"))
+                    continue
+                if rollout.canary_runs < config.min_executions:
+                    continue                              # not enough evidence yet
+                rollout.weight = min(1.0, round(rollout.weight + config.step_size, 6))
+                rollout.last_step_at = now
+                rollout.steps.append({'at': now, 'weight': rollout.weight,
+                                      'error_rate': rollout.error_rate})
+                if rollout.weight >= 1.0:
+                    due.append((canary_id, CanaryStatus.COMPLETED, ''))
+                else:
+                    stepped.append(rollout)
+        if self._pipeline is not None:
+            for rollout in stepped:
+                self._pipeline.canary_stepped(rollout)
+        for canary_id, status, reason in due:
+            self._finish(canary_id, status, reason)
+
+    def _finish(self, canary_id: str, status: CanaryStatus,
+                reason: str) -> Optional[CanaryRollout]:
+        with self._lock:
+            rollout = self._rollouts.get(canary_id)
+            if rollout is None or rollout.status != CanaryStatus.RUNNING:
+                return None
+            rollout.status = status
+            rollout.reason = reason
+            rollout.finished_at = self._clock()
+            self._by_slot.pop(rollout.slot_id, None)
+        if self._pipeline is not None:
+            try:
+                self._pipeline.finish_canary(rollout)
+            except Exception as exc:
+                # The promotion itself failed — the baseline is still live
+                rollout.status = CanaryStatus.ROLLED_BACK
+                rollout.reason = f"Promotion failed: {exc}"
+        return rollout
+
+    def _loop(self):
+        while not self._stop.wait(self._poll_interval):
+            try:
+                self.tick()
+            except Exception:
+                pass                                  # next pass retries
+
+    # ── Queries ──────────────────────────────────────────────────────
+
+    def get(self, canary_id: str) -> Optional[CanaryRollout]:
+        with self._lock:
+            return self._rollouts.get(canary_id)
+
+    def for_slot(self, slot_id: str) -> Optional[CanaryRollout]:
+        with self._lock:
+            canary_id = self._by_slot.get(slot_id)
+            return self._rollouts[canary_id] if canary_id else None
+
+    def running(self) -> List[CanaryRollout]:
+        with self._lock:
+            return [self._rollouts[c] for c in self._by_slot.values()]
+
+    def all(self) -> List[CanaryRollout]:
+        with self._lock:
+            return sorted(self._rollouts.values(), key=lambda r: r.started_at)
+
+    def rename_ids(self, mapping: Dict[str, str], dry_run: bool = False) -> int:
+        """Rewrite staging IDs in the rollouts (see snippet_migrate); returns the count."""
+        changed = 0
+        with self._lock:
+            for rollout in list(self._rollouts.values()):
+                for attr in ('canary_id', 'baseline_id'):
+                    sid = getattr(rollout, attr)
+                    if sid in mapping:
+                        changed += 1
+                        if not dry_run:
+                            setattr(rollout, attr, mapping[sid])
+            if not dry_run:
+                self._rollouts = {r.canary_id: r for r in self._rollouts.values()}
+                self._by_slot = {slot: mapping.get(cid, cid)
+                                 for slot, cid in self._by_slot.items()}
+        return changed
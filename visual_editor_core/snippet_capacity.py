@@ -0,0 +1,129 @@
+"""
+Slot Capacity — per-slot limits on how many snippets (and bytes) one
+engine slot may hold, plus the eviction rules used to make room.
+
+A "slot" here is an engine row addressed by its letter (the `i` in
+`slot: i1 (position 1)`).  Positions within it are bounded only by the
+engine row's max_slots, which says nothing about how much code an
+operator is willing to keep live.  A SlotConfig caps that:
+
+    configure_slot('i', SlotConfig(max_snippets=8, max_total_bytes=64_000,
+                                   eviction=EvictionPolicy.LRU))
+
+Usage counts every live production entry on the slot plus every snippet
+still in the pipeline for it (queued → promoting), so a burst of queued
+snippets can't overshoot the limit once they all promote.
+
+Eviction never happens implicitly — the staging path raises SlotFullError
+and the operator decides when to call evict(slot, count).
+
+require_approvals makes the slot protected: promotions onto it wait for
+that many approvers (see snippet_approvals).
+
+schedule is a cron expression; a RerunScheduler re-runs the slot's live
+entries whenever it fires (see snippet_schedule).
+
+isolation is how far the slot's executions are kept apart from the host
+(see snippet_isolation).
+
+encrypt_at_rest keeps the slot's stored payloads encrypted, by default
+with age to the X25519 public key in `recipient` (see snippet_encryption).
+"""
+
+from enum import Enum
+from dataclasses import dataclass, asdict
+from typing import Callable, Dict, List, Optional, Sequence
+
+from .snippet_schedule import CronSchedule
+from .snippet_isolation import IsolationLevel
+from .snippet_encryption import validate_recipient
+
+
+class EvictionPolicy(str, Enum):
+    """Which live entries evict() removes first."""
+    LRU          = 'lru'             # Least recently executed
+    OLDEST_FIRST = 'oldest_first'    # Earliest promoted
+    MANUAL       = 'manual'          # Never chosen automatically
+
+
+class SlotFullError(ValueError):
+    """Staging a snippet would exceed its slot's SlotConfig limits."""
+
+    def __init__(self, message: str, slot: str, limit: str):
+        super().__init__(message)
+        self.slot = slot
+        self.limit = limit               # 'max_snippets' | 'max_total_bytes'
+
+
+class SlotEvictionError(ValueError):
+    """evict() was refused (e.g. the slot's policy is MANUAL)."""
+
+
+@dataclass
+class SlotConfig:
+    """Capacity limits for one slot.  0 means unlimited."""
+    max_snippets: int = 0
+    max_total_bytes: int = 0
+    eviction: EvictionPolicy = EvictionPolicy.MANUAL
+    require_approvals: int = 0           # Approvers a promotion needs (0 = none)
+    schedule: str = ''                   # Cron expression for reruns ('' = never)
+    isolation: IsolationLevel = IsolationLevel.NONE
+    encrypt_at_rest: bool = False        # Seal the slot's stored payloads
+    recipient: str = ''                  # age X25519 public key (age1…) to seal them to
+
+    def __post_init__(self):
+        self.eviction = EvictionPolicy(self.eviction)
+        self.isolation = IsolationLevel(self.isolation)
+        if self.max_snippets < 0 or self.max_total_bytes < 0:
+            raise ValueError("Slot limits must be >= 0 (0 = unlimited)")
+        if self.require_approvals < 0:
+            raise ValueError("require_approvals must be >= 0 (0 = no approval needed)")
+        self.schedule = (self.schedule or '').strip()
+        if self.schedule:
+            CronSchedule.parse(self.schedule)
+        self.recipient = (self.recipient or '').strip()
+        if self.recipient:
+            validate_recipient(self.recipient)
+
+    @property
+    def cron(self) -> Optional[CronSchedule]:
+        """The parsed schedule, or None for a slot without one."""
+        return CronSchedule.parse(self.schedule) if self.schedule else None
+
+    def to_dict(self) -> Dict:
+        d = asdict(self)
+        d['eviction'] = self.eviction.value
+        d['isolation'] = self.isolation.value
+        return d
+
+    def check(self, slot: str, count: int, total_bytes: int, incoming_bytes: int):
+        """Raise SlotFullError if adding one snippet of `incoming_bytes` breaks a limit."""
+        if self.max_snippets and count + 1 > self.max_snippets:
+            raise SlotFullError(
+                f"Slot '{slot}' is full: {count}/{self.max_snippets} snippets",
+                slot, 'max_snippets')
+        if self.max_total_bytes and total_bytes + incoming_bytes > self.max_total_bytes:
+            raise SlotFullError(
+                f"Slot '{slot}' is full: {total_bytes} + {incoming_bytes} bytes "
+                f"exceeds {self.max_total_bytes}",
+                slot, 'max_total_bytes')
+
+
+def choose_victims(policy: EvictionPolicy, entries: Sequence,
+                   count: int, last_used: Callable[[object], float]) -> List:
+    """
+    Pick up to `count` live entries to evict under `policy`.
+
+    `entries` are StagedSnippets in production on one slot and
+    `last_used` returns an entry's most recent execution time.
+    """
+    if policy == EvictionPolicy.MANUAL:
+        raise SlotEvictionError(
+            "Eviction policy is MANUAL — roll back snippets explicitly")
+    if count < 1:
+        return []
+    if policy == EvictionPolicy.LRU:
+        ordered = sorted(entries, key=lambda s: (last_used(s), s.promoted_at))
+    else:
+        ordered = sorted(entries, key=lambda s: s.promoted_at)
+    return list(ordered[:count])
@@ -0,0 +1,177 @@
+"""
+Snippet Test Cases — table-driven specs declared next to the snippet.
+
+A snippet's spec is normally its own run.  With test cases the pipeline
+also runs it once per case, with that case's parameter arguments bound,
+and holds the output and exit code to the case's expectations — the
+snippet itself needs no test logic:
+
+    pipeline.queue_snippet('i', 'go', FIB_SOURCE, 'fib',
+                           parameters=[ParameterSpec('n', ParamType.INT)],
+                           test_cases=[TestCase('zero', {'n': 0}, expected_output='0'),
+                                       TestCase('ten', {'n': 10}, expected_output='55'),
+                                       TestCase('negative', {'n': -1}, expected_exit_code=2,
+                                                expected_output_regex='n must be >= 0')])
+
+speculate() runs the cases after the snippet's own run passes, in
+declaration order.  Each one becomes a CaseResult in the snippet's
+spec_cases; if any fails the speculation is CASE_FAIL and spec_error
+names the failing cases.  Scheduled reruns, replays and revalidation run
+the cases again the same way, so a live snippet whose case starts failing
+reruns as CASE_FAIL (and raises HEALTH_ALERT); exports carry the cases and
+an import stages them with the snippet.
+
+    expected_output        stdout must equal it (trailing newlines ignored)
+    expected_output_regex  a Python regular expression that must match
+                           somewhere in stdout (anchor it with ^ / $)
+    expected_exit_code     the program's exit status (default 0)
+
+Leave both output fields unset to check the exit code alone.  Executors
+that don't report an exit status (every one but Go's) count a clean run
+as 0 and any other as 1.  A case that times out or exceeds its resource
+limits fails whatever it expected.
+"""
+
+import re
+from dataclasses import dataclass, field
+from typing import Any, Dict, Iterable, List, Optional
+
+from .snippet_params import ParameterSpec, validate_arguments
+
+
+MAX_TEST_CASES = 100
+
+# Case names: letters, digits, '_', '.', '-' (like snapshot names)
+_NAME = re.compile(r'^[A-Za-z0-9][A-Za-z0-9_.\-]{0,63}$')
+
+
+@dataclass
+class TestCase:
+    """One row of a snippet's table-driven spec."""
+    __test__ = False                     # Not a pytest class
+
+    name: str
+    params: Dict[str, Any] = field(default_factory=dict)
+    expected_output: Optional[str] = None          # None = stdout not compared
+    expected_output_regex: str = ''                # '' = no pattern
+    expected_exit_code: int = 0
+
+    def __post_init__(self):
+        self.name = (self.name or '').strip()
+        if not _NAME.match(self.name):
+            raise ValueError(f"Invalid test case name '{self.name}': use 1-64 of A-Z, a-z, "
+                             f"0-9, '_', '.', '-' starting with a letter or digit")
+        if not isinstance(self.params, dict):
+            raise ValueError(f"Test case '{self.name}': params must be a mapping")
+        if self.expected_output is not None and self.expected_output_regex:
+            raise ValueError(f"Test case '{self.name}': give expected_output or "
+                             f"expected_output_regex, not both")
+        if self.expected_output_regex:
+            try:
+                re.compile(self.expected_output_regex)
+            except re.error as exc:
+                raise ValueError(f"Test case '{self.name}': invalid expected_output_regex "
+                                 f"({exc})") from None
+        code = self.expected_exit_code
+        if isinstance(code, bool) or not isinstance(code, int) or not 0 <= code <= 255:
+            raise ValueError(f"Test case '{self.name}': expected_exit_code must be 0-255")
+
+    @classmethod
+    def from_dict(cls, d: Dict) -> 'TestCase':
+        if not isinstance(d, dict):
+            raise ValueError("A test case must be a mapping")
+        unknown = sorted(set(d) - {'name', 'params', 'expected_output',
+                                   'expected_output_regex', 'expected_exit_code'})
+        if unknown:
+            raise ValueError(f"Unknown test case field(s): {', '.join(unknown)}")
+        return cls(name=str(d.get('name') or ''),
+                   params=d.get('params') or {},
+                   expected_output=d.get('expected_output'),
+                   expected_output_regex=str(d.get('expected_output_regex') or ''),
+                   expected_exit_code=d.get('expected_exit_code', 0))
+
+    def to_dict(self) -> Dict:
+        return {
+            'name': self.name,
+            'params': dict(self.params),
+            'expected_output': self.expected_output,
+            'expected_output_regex': self.expected_output_regex,
+            'expected_exit_code': self.expected_exit_code,
+        }
+
+
+@dataclass
+class CaseResult:
+    """How one TestCase went."""
+    name: str
+    passed: bool
+    exit_code: int
+    output: str = ''
+    error: str = ''
+    execution_time: float = 0.0
+    failures: List[str] = field(default_factory=list)   # Why it failed, one line each
+
+    def to_dict(self) -> Dict:
+        return {
+            'name': self.name,
+            'passed': self.passed,
+            'exit_code': self.exit_code,
+            'output': self.output[:2000],
+            'error': self.error[:2000],
+            'execution_time': self.execution_time,
+            'failures': list(self.failures),
+        }
+
+
+def validate_cases(cases: Optional[Iterable[Any]],
+                   specs: Iterable[ParameterSpec] = ()) -> List[TestCase]:
+    """
+    TestCases (or their dicts) checked as a set: unique names, at most
+    MAX_TEST_CASES, and params that bind to `specs` (ParameterError).
+    """
+    cases = [c if isinstance(c, TestCase) else TestCase.from_dict(c) for c in (cases or [])]
+    if len(cases) > MAX_TEST_CASES:
+        raise ValueError(f"At most {MAX_TEST_CASES} test cases per snippet")
+    seen = set()
+    specs = list(specs)
+    for case in cases:
+        if case.name in seen:
+            raise ValueError(f"Duplicate test case name '{case.name}'")
+        seen.add(case.name)
+        case.params = validate_arguments(specs, case.params) if specs or case.params else {}
+    return cases
+
+
+def check_case(case: TestCase, result: Dict[str, Any]) -> CaseResult:
+    """Hold a _run_isolated() result to `case`'s expectations."""
+    output = result.get('output', '') or ''
+    exit_code = result.get('exit_code')
+    if exit_code is None:
+        exit_code = 0 if result.get('success') else 1
+    failures = []
+    if result.get('timed_out'):
+        failures.append('timed out')
+    elif result.get('resource_violation'):
+        failures.append(f"exceeded its {result['resource_violation']} limit")
+    if exit_code != case.expected_exit_code:
+        failures.append(f"exit code {exit_code}, expected {case.expected_exit_code}")
+    if case.expected_output is not None:
+        got, want = output.rstrip('\n'), case.expected_output.rstrip('\n')
+        if got != want:
+            failures.append(f"output {got[:200]!r}, expected {want[:200]!r}")
+    if case.expected_output_regex and not re.search(case.expected_output_regex, output,
+                                                    re.MULTILINE):
+        failures.append(f"output does not match /{case.expected_output_regex}/")
+    return CaseResult(name=case.name, passed=not failures, exit_code=exit_code,
+                      output=output, error=result.get('error', '') or '',
+                      execution_time=result.get('execution_time', 0.0), failures=failures)
+
+
+def describe_failures(results: Iterable[CaseResult]) -> str:
+    """spec_error for a CASE_FAIL speculation ('' when every case passed)."""
+    results = list(results)
+    failed = [r for r in results if not r.passed]
+    if not failed:
+        return ''
+    return (f"{len(failed)} of {len(results)} test cases failed: "
+            + '; '.join(f"{r.name} ({', '.join(r.failures)})" for r in failed))
@@ -0,0 +1,192 @@
+"""
+Snippet Coverage — how much of a Go snippet its own tests exercise.
+
+A speculative run passes or fails, which says nothing about how much of
+the snippet that run (or anything else) touched.  A snippet can carry
+its tests alongside `main`:
+
+    package main
+
+    import "testing"
+
+    func gcd(a, b int) int { … }
+    func main() { fmt.Println(gcd(12, 18)) }
+
+    func TestGcd(t *testing.T) { … }
+
+With a CoverageGate on the executor (or GoEngine):
+
+    GoExecutor(coverage_gate=CoverageGate(min_coverage_percent=80))
+
+a Go snippet that declares `func TestXxx(t *testing.T)` functions is,
+once its program has run cleanly, split into main.go and main_test.go
+(split_go_tests()) and run under `go test -coverprofile` in a temp
+directory.  The statement coverage parsed from the profile is stored on
+the snippet as coverage_percent.  Below min_coverage_percent the run
+fails with spec_result COVERAGE_FAIL and the measured percentage in
+spec_error; a failing test fails it as FAIL.  Snippets without tests are
+not measured (coverage_percent stays None).
+
+The tests get the gate's own deadline (`timeout`), not what is left of
+the program's.
+"""
+
+import os
+import re
+import shutil
+import tempfile
+import threading
+from dataclasses import dataclass, asdict
+from typing import Dict, List, Optional, Tuple
+
+from .snippet_deps import (
+    _GO_IMPORT_BLOCK, _GO_IMPORT_LINE, _GO_PACKAGE, _go_block_end, _go_import_name,
+)
+
+
+DEFAULT_COVERAGE_TIMEOUT = 60.0          # Seconds `go test` may take
+
+_GO_TEST_FUNC = re.compile(r'^func\s+Test[A-Z_0-9]\w*\s*\(\s*\w+\s+\*testing\.T\s*\)\s*\{',
+                           re.MULTILINE)
+# Test and Benchmark functions both belong in the _test.go file
+_GO_TESTING_FUNC = re.compile(r'^func\s+(?:Test|Benchmark)[A-Z_0-9]\w*\s*'
+                              r'\(\s*\w+\s+\*testing\.[TB]\s*\)\s*\{', re.MULTILINE)
+_PROFILE_LINE = re.compile(r'^(.+:\d+\.\d+,\d+\.\d+)\s+(\d+)\s+(\d+)$')
+
+
+@dataclass
+class CoverageGate:
+    """Minimum statement coverage for Go snippets that carry tests."""
+    min_coverage_percent: float = 0.0
+    timeout: float = DEFAULT_COVERAGE_TIMEOUT
+
+    def __post_init__(self):
+        if isinstance(self.min_coverage_percent, bool) or not 0 <= self.min_coverage_percent <= 100:
+            raise ValueError("min_coverage_percent must be between 0 and 100")
+        if self.timeout <= 0:
+            raise ValueError("Coverage timeout must be > 0")
+
+    def describe(self, percent: float) -> str:
+        """The COVERAGE_FAIL message for a run measured at `percent` ('' if it passes)."""
+        if percent >= self.min_coverage_percent:
+            return ''
+        return (f"Coverage {percent:.1f}% is below the minimum of "
+                f"{self.min_coverage_percent:g}%")
+
+    def to_dict(self) -> Dict:
+        return asdict(self)
+
+
+@dataclass
+class CoverageRun:
+    """Outcome of running a snippet's tests under `go test -cover`."""
+    tests_passed: bool
+    percent: Optional[float] = None      # None when the tests didn't get to run
+    output: str = ''
+    error: str = ''
+    timed_out: bool = False
+
+    def to_dict(self) -> Dict:
+        return asdict(self)
+
+
+def has_go_tests(code: str) -> bool:
+    return bool(_GO_TEST_FUNC.search(code))
+
+
+def _go_file(package: str, imports: List[str], body: str, keep_blank: bool) -> str:
+    used = [spec for spec in imports
+            if (_go_import_name(spec) in ('_', '.') and keep_blank)
+            or re.search(r'\b' + re.escape(_go_import_name(spec)) + r'\.', body)]
+    text = f"package {package}\n\n"
+    if used:
+        text += "import (\n" + ''.join(f"\t{spec}\n" for spec in used) + ")\n\n"
+    return text + body.strip() + '\n'
+
+
+def split_go_tests(code: str) -> Tuple[str, str]:
+    """
+    (main.go, main_test.go) for a snippet: its top-level Test and
+    Benchmark functions move to the test file, everything else stays,
+    and each file keeps only the imports it uses.
+    """
+    package = 'main'
+    match = _GO_PACKAGE.search(code)
+    if match:
+        package = match.group(1)
+        code = code[:match.start()] + code[match.end():]
+    imports: List[str] = []
+    for block in _GO_IMPORT_BLOCK.findall(code):
+        for line in block.splitlines():
+            spec = line.split('//', 1)[0].strip()
+            if spec and spec not in imports:
+                imports.append(spec)
+    for spec in _GO_IMPORT_LINE.findall(code):
+        if spec.strip() not in imports:
+            imports.append(spec.strip())
+    code = _GO_IMPORT_LINE.sub('', _GO_IMPORT_BLOCK.sub('', code))
+
+    tests, rest, pos = [], [], 0
+    for match in _GO_TESTING_FUNC.finditer(code):
+        if match.start() < pos:
+            continue                                   # Inside an earlier test's body
+        end = _go_block_end(code, match.end())
+        rest.append(code[pos:match.start()])
+        tests.append(code[match.start():end])
+        pos = end
+    rest.append(code[pos:])
+    return (_go_file(package, imports, ''.join(rest), keep_blank=True),
+            _go_file(package, imports, '\n\n'.join(tests), keep_blank=False))
+
+
+def parse_cover_profile(profile: str) -> float:
+    """
+    Statement coverage, in percent, of a `go test -coverprofile` file:
+    statements in blocks that ran over all statements.  A profile with
+    no statements is fully covered.
+    """
+    blocks: Dict[str, List[int]] = {}
+    for line in profile.splitlines():
+        match = _PROFILE_LINE.match(line.strip())
+        if not match:
+            continue                                   # mode: line, blanks
+        stmts, count = int(match.group(2)), int(match.group(3))
+        seen = blocks.setdefault(match.group(1), [stmts, 0])
+        seen[1] = max(seen[1], count)
+    total = sum(stmts for stmts, _ in blocks.values())
+    if not total:
+        return 100.0
+    covered = sum(stmts for stmts, count in blocks.values() if count)
+    return 100.0 * covered / total
+
+
+def run_go_coverage(go_path: str, code: str, gate: CoverageGate,
+                    env: Optional[Dict[str, str]] = None, kill_grace: float = 2.0,
+                    cancel_event: Optional[threading.Event] = None) -> CoverageRun:
+    """Split `code`, run its tests with -coverprofile and parse the profile."""
+    from .execution_engine import _run_with_deadline
+    main_src, test_src = split_go_tests(code)
+    tmp_dir = tempfile.mkdtemp(prefix='vpyd_cover_')
+    try:
+        for name, text in (('main.go', main_src), ('main_test.go', test_src)):
+            with open(os.path.join(tmp_dir, name), 'w', encoding='utf-8') as f:
+                f.write(text)
+        profile_path = os.path.join(tmp_dir, 'cover.out')
+        proc, timed_out = _run_with_deadline(
+            [go_path, 'test', '-count=1', f'-coverprofile={profile_path}',
+             'main.go', 'main_test.go'],
+            timeout=gate.timeout, kill_grace=kill_grace, cwd=tmp_dir,
+            cancel_event=cancel_event,
+            env={**os.environ, **env} if env else None)
+        output = f"{proc.stdout or ''}{proc.stderr or ''}".strip()
+        if timed_out:
+            return CoverageRun(False, output=output, timed_out=True,
+                               error=f"go test timed out after {gate.timeout:g}s")
+        if proc.returncode != 0 or not os.path.exists(profile_path):
+            return CoverageRun(False, output=output,
+                               error=f"Snippet tests failed:\n{output}")
+        with open(profile_path, encoding='utf-8') as f:
+            percent = parse_cover_profile(f.read())
+        return CoverageRun(True, percent=percent, output=output)
+    finally:
+        shutil.rmtree(tmp_dir, ignore_errors=True)
@@ -0,0 +1,140 @@
+"""
+Snippet Credentials — refuse sources with hard-coded secrets before they are staged.
+
+An API key pasted into a snippet would otherwise be hashed, stored,
+archived, exported and shown in version diffs for as long as the
+snippet lives.  queue_snippet() runs the pipeline's CredentialScanner
+over the source as submitted (before any formatting) and refuses it with
+CredentialDetectedError naming the pattern and the line:
+
+    Possible hard-coded credential (github_token) on line 7
+
+The built-in patterns (DEFAULT_PATTERNS):
+
+    aws_access_key_id       AKIA… / ASIA… access key IDs
+    aws_secret_access_key   aws_secret_access_key = <40 chars>
+    gcp_service_account     a service account key's "private_key" or
+                            "type": "service_account" JSON
+    private_key             -----BEGIN … PRIVATE KEY----- blocks
+    github_token            ghp_ / gho_ / ghu_ / ghs_ / ghr_ and github_pat_ tokens
+    slack_token             xoxb- / xoxp- / … tokens
+    stripe_secret_key       sk_live_ keys
+    generic_password        password / secret / api_key / token assigned a
+                            string literal ("password = 'hunter22'")
+
+A scanner takes its own list instead (CredentialScanner(patterns=[...]);
+an empty list turns the gate off).  Only the pattern name and line are
+ever reported — never the matched text.
+
+Test fixtures that need a credential-shaped string are staged with
+suppress_credential_check=True; the scan still runs, and what it found
+is audited and logged at WARNING with the staging ID.
+"""
+
+import re
+from dataclasses import dataclass
+from typing import Dict, Iterable, List, Optional, Union
+
+
+@dataclass(frozen=True)
+class CredentialPattern:
+    """A named regular expression, matched against the raw source bytes."""
+    name: str
+    pattern: str
+
+    def __post_init__(self):
+        if not re.match(r'^[a-z][a-z0-9_]{0,63}$', self.name or ''):
+            raise ValueError(f"Invalid credential pattern name '{self.name}': use 1-64 of "
+                             f"a-z, 0-9, '_' starting with a letter")
+        try:
+            object.__setattr__(self, '_regex', re.compile(self.pattern.encode('utf-8')))
+        except re.error as exc:
+            raise ValueError(f"Credential pattern '{self.name}' is not a valid regular "
+                             f"expression ({exc})") from None
+
+    @property
+    def regex(self) -> 're.Pattern[bytes]':
+        return self._regex
+
+    @classmethod
+    def from_dict(cls, d: Dict) -> 'CredentialPattern':
+        return cls(name=str(d.get('name', '')), pattern=str(d.get('pattern', '')))
+
+    def to_dict(self) -> Dict:
+        return {'name': self.name, 'pattern': self.pattern}
+
+
+DEFAULT_PATTERNS = (
+    CredentialPattern('aws_access_key_id', r'\b(?:AKIA|ASIA)[0-9A-Z]{16}\b'),
+    CredentialPattern('aws_secret_access_key',
+                      r'(?i)aws_?secret_?access_?key["\']?\s*[:=]+\s*["\']?[A-Za-z0-9/+=]{40}\b'),
+    CredentialPattern('gcp_service_account',
+                      r'"type"\s*:\s*"service_account"|"private_key"\s*:\s*"-----BEGIN'),
+    CredentialPattern('private_key',
+                      r'-----BEGIN (?:RSA |EC |DSA |OPENSSH |ENCRYPTED )?PRIVATE KEY-----'),
+    CredentialPattern('github_token',
+                      r'\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b'),
+    CredentialPattern('slack_token', r'\bxox[abposr]-[A-Za-z0-9-]{10,}'),
+    CredentialPattern('stripe_secret_key', r'\bsk_live_[A-Za-z0-9]{24,}\b'),
+    CredentialPattern('generic_password',
+                      r'(?i)\b(?:password|passwd|pwd|secret|api_?key|auth_?token|access_?token)'
+                      r'["\']?\s*(?::=|=|:)\s*["\'`][^"\'`\s]{4,}["\'`]'),
+)
+
+
+@dataclass
+class CredentialFinding:
+    """Where a pattern matched (the matched text is deliberately not kept)."""
+    pattern: str
+    line: int                            # 1-based
+    column: int                          # 1-based, in bytes
+
+    def to_dict(self) -> Dict:
+        return {'pattern': self.pattern, 'line': self.line, 'column': self.column}
+
+
+class CredentialDetectedError(ValueError):
+    """A source about to be staged contains what looks like a credential."""
+
+    def __init__(self, findings: List[CredentialFinding]):
+        self.findings = findings
+        self.pattern = findings[0].pattern
+        self.line = findings[0].line
+        more = f" (and {len(findings) - 1} more)" if len(findings) > 1 else ''
+        super().__init__(f"Possible hard-coded credential ({self.pattern}) on line "
+                         f"{self.line}{more}; remove it, or stage with "
+                         f"suppress_credential_check for an intentional fixture")
+
+
+class CredentialScanner:
+    """Applies a set of CredentialPatterns to snippet sources."""
+
+    def __init__(self, patterns: Optional[Iterable[Union[CredentialPattern, Dict]]] = None):
+        patterns = DEFAULT_PATTERNS if patterns is None else patterns
+        self._patterns = [p if isinstance(p, CredentialPattern) else CredentialPattern.from_dict(p)
+                          for p in patterns]
+        names = [p.name for p in self._patterns]
+        if len(set(names)) != len(names):
+            raise ValueError("Credential pattern names must be unique")
+
+    @property
+    def patterns(self) -> List[CredentialPattern]:
+        return list(self._patterns)
+
+    def scan(self, source: Union[str, bytes]) -> List[CredentialFinding]:
+        """Every match of every pattern, in source order."""
+        raw = source.encode('utf-8') if isinstance(source, str) else bytes(source)
+        findings = []
+        for pattern in self._patterns:
+            for match in pattern.regex.finditer(raw):
+                start = match.start()
+                line_start = raw.rfind(b'\n', 0, start) + 1
+                findings.append(CredentialFinding(pattern.name, raw.count(b'\n', 0, start) + 1,
+                                                  start - line_start + 1))
+        return sorted(findings, key=lambda f: (f.line, f.column, f.pattern))
+
+    def check(self, source: Union[str, bytes]):
+        """CredentialDetectedError if anything in `source` matches."""
+        findings = self.scan(source)
+        if findings:
+            raise CredentialDetectedError(findings)
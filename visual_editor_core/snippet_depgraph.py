@@ -0,0 +1,141 @@
+"""
+Snippet Dependency Graph — which labels on a slot build on which.
+
+    graph = pipeline.dependency_graph('i')
+    graph.topological_order()          # ['mathutils', 'fib', 'report']
+    graph.critical_path()              # ['mathutils', 'fib', 'report']
+    graph.write_dot(sys.stdout)        # digraph "slot i" { "fib" -> "mathutils"; … }
+
+The nodes are the labels live on the slot (in one namespace) plus any
+label one of them requires that is no longer live (`missing`); there is
+an edge label → required label for each entry in a live snippet's
+`requires` (see snippet_deps).  Each node carries how many times its
+label has been promoted on the slot, from the promotion history.
+
+topological_order() lists dependencies before their dependents (ties in
+label order).  critical_path() is the dependency chain with the most
+promotions along it — the labels whose churn most often forces a
+dependent to be staged again — dependency first.  A cycle raises
+CyclicDependencyError with the cycle in `chain`; staging refuses to
+create one, but a graph built from a persisted or imported state is
+checked as well.
+"""
+
+from dataclasses import dataclass, asdict, field
+from typing import Dict, Iterable, List, Optional, TextIO
+
+from .snippet_deps import CyclicDependencyError
+
+
+@dataclass
+class DepNode:
+    label: str
+    staging_id: str = ''                 # The live version ('' when missing)
+    promotions: int = 0                  # Times the label was promoted on the slot
+    missing: bool = False                # Required, but not live any more
+
+    def to_dict(self) -> Dict:
+        return asdict(self)
+
+
+@dataclass
+class DepGraph:
+    """label → required label edges between a slot's live snippets."""
+    slot: str
+    namespace: str
+    nodes: Dict[str, DepNode] = field(default_factory=dict)
+    edges: Dict[str, List[str]] = field(default_factory=dict)   # label → labels it requires
+
+    @classmethod
+    def build(cls, slot: str, namespace: str, live: Iterable,
+              promotions: Optional[Dict[str, int]] = None) -> 'DepGraph':
+        """
+        The graph of `live` (StagedSnippets, one per label) with promotion
+        counts by label; CyclicDependencyError if the edges form a cycle.
+        """
+        promotions = promotions or {}
+        graph = cls(slot, namespace)
+        for snippet in sorted(live, key=lambda s: s.label):
+            graph.nodes[snippet.label] = DepNode(snippet.label, snippet.staging_id,
+                                                 promotions.get(snippet.label, 0))
+            graph.edges[snippet.label] = list(snippet.requires)
+        for label in sorted({dep for deps in graph.edges.values() for dep in deps}):
+            if label not in graph.nodes:
+                graph.nodes[label] = DepNode(label, promotions=promotions.get(label, 0),
+                                             missing=True)
+                graph.edges[label] = []
+        graph.topological_order()
+        return graph
+
+    def topological_order(self) -> List[str]:
+        """Every label, dependencies before dependents; CyclicDependencyError on a cycle."""
+        order: List[str] = []
+        state: Dict[str, str] = {}           # label → 'visiting' | 'done'
+
+        def visit(label: str, chain: List[str]):
+            if state.get(label) == 'done':
+                return
+            if state.get(label) == 'visiting':
+                raise CyclicDependencyError(chain[chain.index(label):] + [label])
+            state[label] = 'visiting'
+            for dep in sorted(self.edges.get(label, [])):
+                visit(dep, chain + [label])
+            state[label] = 'done'
+            order.append(label)
+
+        for label in sorted(self.nodes):
+            visit(label, [])
+        return order
+
+    def critical_path(self) -> List[str]:
+        """The chain with the most promotions along it, dependency first ([] for no nodes)."""
+        best: Dict[str, List[str]] = {}
+        weight: Dict[str, int] = {}
+        for label in self.topological_order():
+            dep = max(sorted(self.edges.get(label, [])),
+                      key=lambda d: (weight[d], len(best[d])), default=None)
+            best[label] = (best[dep] if dep else []) + [label]
+            weight[label] = (weight[dep] if dep else 0) + self.nodes[label].promotions
+        if not best:
+            return []
+        return best[max(best, key=lambda label: (weight[label], len(best[label])))]
+
+    def to_dot(self) -> str:
+        """The graph in Graphviz DOT; missing labels are dashed, the critical path bold."""
+        path = self.critical_path()
+        on_path = set(zip(path[1:], path))          # (dependent, dependency) edges
+        lines = [f'digraph {_quote(f"slot {self.slot}")} {{', '  rankdir=LR;',
+                 '  node [shape=box];']
+        for label in sorted(self.nodes):
+            node = self.nodes[label]
+            count = f"{node.promotions} promotion{'' if node.promotions == 1 else 's'}"
+            attrs = ['label=' + _quote(f'{label}\n{count}')]
+            if node.missing:
+                attrs.append('style=dashed')
+            elif label in path:
+                attrs.append('style=bold')
+            lines.append(f'  {_quote(label)} [{", ".join(attrs)}];')
+        for label in sorted(self.edges):
+            for dep in sorted(self.edges[label]):
+                bold = ' [style=bold]' if (label, dep) in on_path else ''
+                lines.append(f'  {_quote(label)} -> {_quote(dep)}{bold};')
+        lines.append('}')
+        return '\n'.join(lines) + '\n'
+
+    def write_dot(self, out: TextIO):
+        out.write(self.to_dot())
+
+    def to_dict(self) -> Dict:
+        return {
+            'slot': self.slot,
+            'namespace': self.namespace,
+            'nodes': [self.nodes[label].to_dict() for label in sorted(self.nodes)],
+            'edges': [{'from': label, 'to': dep}
+                      for label in sorted(self.edges) for dep in sorted(self.edges[label])],
+            'topological_order': self.topological_order(),
+            'critical_path': self.critical_path(),
+        }
+
+
+def _quote(text: str) -> str:
+    return '"' + text.replace('\\', '\\\\').replace('"', '\\"').replace('\n', '\\n') + '"'
@@ -0,0 +1,207 @@
+"""
+Snippet Deps — snippets that build on other labeled snippets.
+
+A utility snippet is promoted under a label like any other (say
+`mathutils`, with helper functions and no entry point).  A snippet that
+needs it names the label when it is staged:
+
+    pipeline.queue_snippet('i', 'go', code, 'fib', requires=['mathutils'])
+
+Each required label resolves to its current production (PROMOTED)
+version on the same slot and namespace, and so do the labels *those*
+snippets require.  The sources are merged in dependency order —
+dependencies first, the snippet itself last — and the merged program is
+what runs, is linted and is promoted, as a single unit.  A dependency's
+own `func main` (a self-check, say — it has to pass speculation too) is
+dropped from the merge.  A label that isn't live raises
+MissingDependencyError; a chain that leads back to itself raises
+CyclicDependencyError.
+
+The snippet's code_hash is then the SHA-256 of the merged program, so it
+changes with any dependency's source; `dependencies` records which
+version of each label (and its own source hash) went in.  Promoting a
+new version of a dependency changes what the label resolves to, so a
+dependent staged against the old one fails promote() with
+StaleDependencyError and has to be staged again.
+"""
+
+import re
+import hashlib
+from dataclasses import dataclass, asdict
+from typing import Callable, Dict, List, Optional
+
+
+MAX_DEPENDENCY_DEPTH = 16
+
+
+class DependencyError(ValueError):
+    """A snippet's `requires` can't be resolved."""
+
+
+class MissingDependencyError(DependencyError):
+    """A required label has no live version on the slot."""
+
+
+class CyclicDependencyError(DependencyError):
+    """The required labels lead back to a label already in the chain."""
+
+    def __init__(self, chain: List[str]):
+        self.chain = chain
+        super().__init__(f"Cyclic dependency: {' -> '.join(chain)}")
+
+
+class StaleDependencyError(DependencyError):
+    """A dependency was promoted again after the snippet was staged."""
+
+
+@dataclass
+class ResolvedDependency:
+    """One live snippet merged in front of the dependent's own source."""
+    label: str
+    staging_id: str
+    code_hash: str                       # sha256 of the dependency's own source
+
+    def to_dict(self) -> Dict:
+        return asdict(self)
+
+
+def validate_requires(requires) -> List[str]:
+    """Stripped, de-duplicated label names in the order given."""
+    if requires is None:
+        return []
+    if isinstance(requires, str) or not all(isinstance(r, str) for r in requires):
+        raise DependencyError("'requires' must be a list of label names")
+    labels: List[str] = []
+    for label in (r.strip() for r in requires):
+        if not label:
+            raise DependencyError("'requires' contains an empty label")
+        if label not in labels:
+            labels.append(label)
+    return labels
+
+
+def resolve_dependencies(label: str, requires: List[str],
+                         lookup: Callable[[str], Optional[object]]) -> List[object]:
+    """
+    The live snippets `label` depends on, directly or not, dependencies
+    first.  `lookup(label)` returns the label's live StagedSnippet (or
+    None); each one's own `requires` is followed in turn.
+    """
+    ordered: List[object] = []
+    done: Dict[str, bool] = {}
+
+    def visit(name: str, chain: List[str]):
+        if name in chain:
+            raise CyclicDependencyError(chain[chain.index(name):] + [name])
+        if name in done:
+            return
+        if len(chain) > MAX_DEPENDENCY_DEPTH:
+            raise DependencyError(f"Dependency chain deeper than {MAX_DEPENDENCY_DEPTH}: "
+                                  f"{' -> '.join(chain)}")
+        snippet = lookup(name)
+        if snippet is None:
+            raise MissingDependencyError(
+                f"Required label '{name}' has no version in production"
+                + (f" (required by '{chain[-1]}')" if chain else ''))
+        for dep in snippet.requires:
+            visit(dep, chain + [name])
+        done[name] = True
+        ordered.append(snippet)
+
+    for name in requires:
+        visit(name, [label])
+    return ordered
+
+
+def source_hash(code: str) -> str:
+    return hashlib.sha256(code.encode('utf-8')).hexdigest()
+
+
+_GO_PACKAGE = re.compile(r'^\s*package\s+(\w+)[ \t]*;?[ \t]*$', re.MULTILINE)
+_GO_IMPORT_BLOCK = re.compile(r'^import\s*\((.*?)^\)[ \t]*$', re.MULTILINE | re.DOTALL)
+_GO_IMPORT_LINE = re.compile(r'^import\s+((?:[\w.]+\s+)?"[^"]+")[ \t]*;?[ \t]*$', re.MULTILINE)
+_GO_FUNC_MAIN = re.compile(r'^func\s+main\s*\(\s*\)\s*\{', re.MULTILINE)
+
+
+def _strip_go_main(src: str) -> str:
+    """`src` without its top-level `func main() { … }`."""
+    match = _GO_FUNC_MAIN.search(src)
+    if not match:
+        return src
+    return src[:match.start()] + src[_go_block_end(src, match.end()):]
+
+
+def _go_block_end(src: str, i: int) -> int:
+    """Index just past the `}` closing the block whose `{` ends right before `i`."""
+    depth, quote = 1, ''
+    while i < len(src) and depth:
+        ch = src[i]
+        if quote:
+            if ch == '\\' and quote != '`':
+                i += 1
+            elif ch == quote:
+                quote = ''
+        elif src.startswith('//', i):
+            i = src.find('\n', i)
+            i = len(src) if i < 0 else i
+            continue
+        elif src.startswith('/*', i):
+            i = src.find('*/', i + 2)
+            i = len(src) if i < 0 else i + 2
+            continue
+        elif ch in '"\'`':
+            quote = ch
+        elif ch == '{':
+            depth += 1
+        elif ch == '}':
+            depth -= 1
+        i += 1
+    return i
+
+
+def _go_import_name(spec: str) -> str:
+    """The name an import spec binds: its alias, else the path's last element."""
+    alias, _, path = spec.rpartition(' ')
+    if alias.strip():
+        return alias.strip()
+    parts = path.strip('"').split('/')
+    if len(parts) > 1 and re.fullmatch(r'v\d+', parts[-1]):
+        parts.pop()
+    return re.sub(r'\.v\d+$', '', parts[-1]).replace('-', '_')
+
+
+def merge_go_sources(sources: List[str]) -> str:
+    """
+    One Go file from several: the last source's package clause, every
+    import once in a single block, then each file's declarations in order.
+    Only the last source keeps its `func main`.
+    """
+    package = 'main'
+    imports: List[str] = []
+    bodies: List[str] = []
+    for n, src in enumerate(sources):
+        if n < len(sources) - 1:
+            src = _strip_go_main(src)
+        match = _GO_PACKAGE.search(src)
+        if match:
+            package = match.group(1)
+            src = src[:match.start()] + src[match.end():]
+        for block in _GO_IMPORT_BLOCK.findall(src):
+            for line in block.splitlines():
+                spec = line.split('//', 1)[0].strip()
+                if spec and spec not in imports:
+                    imports.append(spec)
+        for spec in _GO_IMPORT_LINE.findall(src):
+            if spec.strip() not in imports:
+                imports.append(spec.strip())
+        src = _GO_IMPORT_LINE.sub('', _GO_IMPORT_BLOCK.sub('', src))
+        bodies.append(src.strip())
+    # Imports only a dropped main used would no longer compile
+    body = '\n'.join(bodies)
+    imports = [spec for spec in imports
+               if _go_import_name(spec) in ('_', '.')
+               or re.search(r'\b' + re.escape(_go_import_name(spec)) + r'\.', body)]
+    merged = f"package {package}\n\n"
+    if imports:
+        merged += "import (\n" + ''.join(f"\t{spec}\n" for spec in imports) + ")\n\n"
+    return merged + '\n\n'.join(b for b in bodies if b) + '\n'
@@ -0,0 +1,218 @@
+"""
+Snippet Diff — version history and source diffs for a promoted label.
+
+Each promotion of a (slot, label) lineage is one version:
+
+    v1  stg-a  promoted 10:02   ae7a34…
+    v2  stg-b  promoted 10:15   adf8bb…      diff(v1, v2) → unified patch
+    v3  stg-c  promoted 11:40   07740f…
+
+Versions come from the pipeline's PromotionHistory (so only the most
+recent `history_depth` promotions are listed) and their source bytes
+from the content-addressable store.  Diffs are computed in-process with
+difflib and returned both as the raw unified patch and as parsed hunks.
+
+preview() diffs a snippet that is still staged against the production
+version of its label on the slot — what promoting it would change — and
+says whether the two are semantically equivalent, i.e. differ only in
+layout and comments (snippet_equivalence).  With no production version
+the preview is_new and the diff adds every line.
+"""
+
+import re
+import difflib
+from dataclasses import dataclass, field, asdict
+from typing import Dict, List, Optional
+
+from .snippet_equivalence import Equivalence
+
+
+class VersionDiffError(ValueError):
+    """Two versions can't be diffed (e.g. a source payload is missing)."""
+
+
+@dataclass
+class VersionRecord:
+    """One promoted version of a label."""
+    version: int                             # 1 = oldest retained promotion
+    staging_id: str
+    slot: str                                # Engine letter, e.g. 'i'
+    label: str
+    address: str
+    code_hash: str
+    promoted_at: float
+    rolled_back: bool = False
+    source: Optional[bytes] = None           # None if the payload is unavailable
+
+    def to_dict(self, include_source: bool = False) -> Dict:
+        d = asdict(self)
+        source = d.pop('source')
+        if include_source:
+            d['source'] = source.decode('utf-8', errors='replace') if source is not None else None
+        return d
+
+
+@dataclass
+class HunkLine:
+    """One line of a hunk: op is ' ' (context), '-' (removed) or '+' (added)."""
+    op: str
+    text: str
+
+
+@dataclass
+class Hunk:
+    """A `@@ -a,b +c,d @@` block of a unified diff."""
+    old_start: int
+    old_lines: int
+    new_start: int
+    new_lines: int
+    lines: List[HunkLine] = field(default_factory=list)
+
+    def to_dict(self) -> Dict:
+        return asdict(self)
+
+
+@dataclass
+class DiffResult:
+    """Unified diff between two versions."""
+    old: VersionRecord
+    new: VersionRecord
+    patch: bytes                             # Raw unified diff, b'' if identical
+    hunks: List[Hunk] = field(default_factory=list)
+
+    @property
+    def identical(self) -> bool:
+        return not self.hunks
+
+    @property
+    def added(self) -> int:
+        return sum(1 for h in self.hunks for l in h.lines if l.op == '+')
+
+    @property
+    def removed(self) -> int:
+        return sum(1 for h in self.hunks for l in h.lines if l.op == '-')
+
+    def to_dict(self) -> Dict:
+        return {
+            'old': self.old.to_dict(),
+            'new': self.new.to_dict(),
+            'patch': self.patch.decode('utf-8', errors='replace'),
+            'hunks': [h.to_dict() for h in self.hunks],
+            'added': self.added,
+            'removed': self.removed,
+            'identical': self.identical,
+        }
+
+
+@dataclass
+class DiffPreview:
+    """A staged snippet against the production version of its label."""
+    staging_id: str
+    slot: str
+    label: str
+    is_new: bool                             # No production version of the label
+    production_id: str = ''
+    production_hash: str = ''
+    unified_diff: str = ''                   # production → staged ('' if identical)
+    hunks: List[Hunk] = field(default_factory=list)
+    is_semantically_equivalent: bool = False
+    equivalence: Optional[Equivalence] = None    # How it was decided (None if is_new)
+
+    @property
+    def lines_added(self) -> int:
+        return sum(1 for h in self.hunks for l in h.lines if l.op == '+')
+
+    @property
+    def lines_removed(self) -> int:
+        return sum(1 for h in self.hunks for l in h.lines if l.op == '-')
+
+    def to_dict(self) -> Dict:
+        return {
+            'staging_id': self.staging_id,
+            'slot': self.slot,
+            'label': self.label,
+            'is_new': self.is_new,
+            'production_id': self.production_id,
+            'production_hash': self.production_hash,
+            'lines_added': self.lines_added,
+            'lines_removed': self.lines_removed,
+            'unified_diff': self.unified_diff,
+            'hunks': [h.to_dict() for h in self.hunks],
+            'is_semantically_equivalent': self.is_semantically_equivalent,
+            'equivalence': self.equivalence.to_dict() if self.equivalence else None,
+        }
+
+
+_HUNK_HEADER = re.compile(r'^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@')
+
+
+def parse_hunks(patch: str) -> List[Hunk]:
+    """Structured hunks of a unified diff (file headers are skipped)."""
+    hunks: List[Hunk] = []
+    for line in patch.splitlines():
+        m = _HUNK_HEADER.match(line)
+        if m:
+            hunks.append(Hunk(
+                old_start=int(m.group(1)),
+                old_lines=int(m.group(2)) if m.group(2) is not None else 1,
+                new_start=int(m.group(3)),
+                new_lines=int(m.group(4)) if m.group(4) is not None else 1,
+            ))
+        elif hunks and line[:1] in (' ', '-', '+'):
+            hunks[-1].lines.append(HunkLine(op=line[0], text=line[1:]))
+        elif hunks and line.startswith('\\'):
+            continue                          # "\ No newline at end of file"
+    return hunks
+
+
+def _version_name(record: VersionRecord) -> str:
+    return f"{record.label}@v{record.version} ({record.staging_id})"
+
+
+def diff(a: VersionRecord, b: VersionRecord, context: int = 3) -> DiffResult:
+    """
+    Unified diff of the source of version `a` → version `b`.
+
+    Raises VersionDiffError if either version's source is unavailable.
+    """
+    for record in (a, b):
+        if record.source is None:
+            raise VersionDiffError(
+                f"Source for {record.staging_id} ({record.code_hash[:12]}…) is not available")
+    patch = _unified(a.source.decode('utf-8', errors='replace'),
+                     b.source.decode('utf-8', errors='replace'),
+                     _version_name(a), _version_name(b), context)
+    return DiffResult(old=a, new=b, patch=patch.encode('utf-8'), hunks=parse_hunks(patch))
+
+
+def preview(staging_id: str, slot: str, label: str, staged: str,
+            production_id: str = '', production_hash: str = '',
+            production: Optional[str] = None,
+            equivalence: Optional[Equivalence] = None, context: int = 3) -> DiffPreview:
+    """
+    The preview of promoting source `staged`: its diff from `production`
+    (None: no production version — is_new) and the `equivalence` verdict
+    on the two.
+    """
+    is_new = production is None
+    patch = _unified('' if is_new else production, staged,
+                     '/dev/null' if is_new else f"{label} (production {production_id})",
+                     f"{label} (staged {staging_id})", context)
+    return DiffPreview(
+        staging_id=staging_id, slot=slot, label=label, is_new=is_new,
+        production_id=production_id, production_hash=production_hash,
+        unified_diff=patch, hunks=parse_hunks(patch),
+        is_semantically_equivalent=bool(equivalence and equivalence.equivalent),
+        equivalence=equivalence,
+    )
+
+
+def _unified(old_text: str, new_text: str, fromfile: str, tofile: str, context: int) -> str:
+    lines = []
+    for line in difflib.unified_diff(old_text.splitlines(keepends=True),
+                                     new_text.splitlines(keepends=True),
+                                     fromfile=fromfile, tofile=tofile, n=context):
+        lines.append(line)
+        if not line.endswith('\n'):
+            lines.append('\n\\ No newline at end of file\n')
+    return ''.join(lines)
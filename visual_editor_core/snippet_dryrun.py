@@ -0,0 +1,89 @@
+"""
+Snippet Dry Run — would this promotion succeed?
+
+A CI job that is about to promote a snippet can ask first:
+
+    report = pipeline.dry_run_promote(staging_id)
+    report.would_succeed                  # every gate passed (or was skipped)
+    [g.to_dict() for g in report.gates]   # {gate, status, detail, duration}
+
+dry_run_promote() walks the same gates promote() and speculate() would —
+in GATE_ORDER — against a snapshot of the snippet, and records each
+one's outcome instead of stopping at the first failure.  A gate that
+needs an earlier one (lint needs the bound source, the benchmark gate
+the speculative run's benchmarks) is SKIPPED when that one failed.
+
+Nothing is committed: the snippet keeps its phase and spec_* fields, no
+file is written, no registry slot or payload is touched, and neither the
+audit trail nor webhooks, metrics or the circuit breaker hear about the
+run.  The speculative run goes through the same isolated executor as
+speculate(), so the same report comes back however often (and however
+concurrently with live promotions) it is asked for.
+"""
+
+import time
+from enum import Enum
+from dataclasses import dataclass, field
+from typing import Any, Dict, List
+
+
+GATE_PHASE = 'phase'                     # Promotable: QUEUED, FAILED or PASSED
+GATE_CIRCUIT = 'circuit_breaker'         # The code's breaker is closed
+GATE_LABEL = 'label_conflict'            # REJECT policy: label not live elsewhere
+GATE_DEPENDENCIES = 'dependencies'       # requires still resolve to the staged versions
+GATE_CAPACITY = 'slot_capacity'          # SlotConfig limits hold with this snippet
+GATE_APPROVAL = 'approval'               # A protected slot's approvals are in
+GATE_FORMAT = 'format'                   # The engine's format_for_stage() accepts it
+GATE_PARAMETERS = 'parameters'           # Arguments fit the declared ParameterSpecs
+GATE_SPECULATION = 'speculation'         # Isolated run succeeds
+GATE_LINT = 'lint'                       # Pre-promotion linter is clean
+GATE_BENCHMARKS = 'benchmarks'           # No benchmark regressed past the gate
+
+GATE_ORDER = (GATE_PHASE, GATE_CIRCUIT, GATE_LABEL, GATE_DEPENDENCIES, GATE_CAPACITY,
+              GATE_APPROVAL, GATE_FORMAT, GATE_PARAMETERS, GATE_SPECULATION, GATE_LINT,
+              GATE_BENCHMARKS)
+
+
+class GateStatus(str, Enum):
+    PASSED  = 'passed'
+    FAILED  = 'failed'
+    SKIPPED = 'skipped'                  # Not configured, overridden, or blocked
+
+
+@dataclass
+class GateResult:
+    gate: str
+    status: GateStatus
+    detail: str = ''
+    duration: float = 0.0
+    data: Dict[str, Any] = field(default_factory=dict)   # Gate-specific extras
+
+    def to_dict(self) -> Dict:
+        return {'gate': self.gate, 'status': self.status.value, 'detail': self.detail,
+                'duration': self.duration, **({'data': self.data} if self.data else {})}
+
+
+@dataclass
+class DryRunReport:
+    """Every gate's outcome for one would-be promotion."""
+    staging_id: str
+    gates: List[GateResult] = field(default_factory=list)
+    created_at: float = field(default_factory=time.time)
+
+    @property
+    def would_succeed(self) -> bool:
+        return all(g.status != GateStatus.FAILED for g in self.gates)
+
+    def gate(self, name: str) -> GateResult:
+        return next(g for g in self.gates if g.gate == name)
+
+    def failed(self) -> List[GateResult]:
+        return [g for g in self.gates if g.status == GateStatus.FAILED]
+
+    def to_dict(self) -> Dict:
+        return {
+            'staging_id': self.staging_id,
+            'would_succeed': self.would_succeed,
+            'gates': [g.to_dict() for g in self.gates],
+            'created_at': self.created_at,
+        }
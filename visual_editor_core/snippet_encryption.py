@@ -0,0 +1,135 @@
+"""
+Snippet Encryption — sealing the stored source of sensitive slots.
+
+Some slots hold snippets with secrets in their source.  A slot whose
+SlotConfig has encrypt_at_rest keeps its payloads encrypted on disk:
+
+    pipeline = StagingPipeline(..., age_identities=['AGE-SECRET-KEY-1…'])
+    pipeline.configure_slot('k', SlotConfig(encrypt_at_rest=True,
+                                            recipient='age1…'))
+
+On promotion the payload is encrypted with the slot's KeyProvider
+before it reaches the snippet store, and decrypted again wherever the
+pipeline reads it back (replays, version history, get_payload()).  The
+default provider is AgeKeyProvider: age (https://age-encryption.org)
+with the slot's X25519 `recipient` public key; decryption uses the
+pipeline's `age_identities`, the matching secret keys, which never live
+in the slot config.  `key_providers` gives a slot any other KeyProvider.
+
+code_hash is still the SHA-256 of the plaintext, so deduplication,
+breakers and history keep working; a decrypted payload is checked
+against it.  Sealed blobs carry the SEALED_MAGIC prefix so the store can
+tell them from plain ones: sealing a payload already stored in the clear
+replaces the plain copy, and a sealed copy is never replaced by a plain
+one.  Archived copies of a sealed slot's snippets (ArchiveAction.MOVE)
+and state checkpoints are sealed the same way.
+
+Sealing protects the disk, not the process: a staged snippet's source
+is in memory (and in API responses) as usual.  Payloads stored before a
+slot was given encrypt_at_rest stay as they are until promoted again.
+
+pyrage (the age bindings) is optional: without it AgeKeyProvider raises
+RuntimeError when used.
+"""
+
+import re
+from abc import ABC, abstractmethod
+from typing import Iterable, List
+
+try:
+    import pyrage
+    from pyrage import x25519
+except ImportError:  # pyrage not installed — age encryption unavailable
+    pyrage = None
+
+SEALED_MAGIC = b'spokedpy-sealed/1\n'
+
+# age X25519 recipients: "age1" + 58 bech32 characters
+_AGE_RECIPIENT = re.compile(r'^age1[02-9ac-hj-np-z]{58}$')
+_AGE_IDENTITY = re.compile(r'^AGE-SECRET-KEY-1[02-9AC-HJ-NP-Z]{58}$')
+
+
+class EncryptionError(ValueError):
+    """A payload could not be sealed or opened."""
+
+
+class KeyProvider(ABC):
+    """Encrypts and decrypts the payloads of one or more slots."""
+
+    @abstractmethod
+    def encrypt(self, plaintext: bytes) -> bytes:
+        """Ciphertext of `plaintext`; EncryptionError if it can't be sealed."""
+
+    @abstractmethod
+    def decrypt(self, ciphertext: bytes) -> bytes:
+        """Plaintext of `ciphertext`; EncryptionError if this provider can't open it."""
+
+
+class AgeKeyProvider(KeyProvider):
+    """age to an X25519 `recipient` (to encrypt); `identities` (secret keys) to decrypt."""
+
+    def __init__(self, recipient: str = '', identities: Iterable[str] = ()):
+        self.recipient = validate_recipient(recipient) if recipient else ''
+        self.identities: List[str] = [validate_identity(i) for i in identities]
+
+    def encrypt(self, plaintext: bytes) -> bytes:
+        _require_pyrage()
+        if not self.recipient:
+            raise EncryptionError("No age recipient to encrypt to")
+        try:
+            return pyrage.encrypt(plaintext, [x25519.Recipient.from_str(self.recipient)])
+        except Exception as exc:
+            raise EncryptionError(f"age encryption failed: {exc}") from None
+
+    def decrypt(self, ciphertext: bytes) -> bytes:
+        _require_pyrage()
+        if not self.identities:
+            raise EncryptionError("No age identity is configured to decrypt with")
+        try:
+            return pyrage.decrypt(ciphertext,
+                                  [x25519.Identity.from_str(i) for i in self.identities])
+        except Exception as exc:
+            raise EncryptionError(f"age decryption failed: {exc}") from None
+
+
+def is_sealed(blob: bytes) -> bool:
+    return blob.startswith(SEALED_MAGIC)
+
+
+def seal(provider: KeyProvider, plaintext: bytes) -> bytes:
+    """`plaintext` encrypted by `provider`, with the SEALED_MAGIC prefix."""
+    return SEALED_MAGIC + provider.encrypt(plaintext)
+
+
+def unseal(provider: KeyProvider, blob: bytes) -> bytes:
+    if not is_sealed(blob):
+        raise EncryptionError("Payload is not sealed")
+    return provider.decrypt(blob[len(SEALED_MAGIC):])
+
+
+def validate_recipient(recipient: str) -> str:
+    recipient = (recipient or '').strip()
+    if not _AGE_RECIPIENT.match(recipient):
+        raise ValueError(f"Invalid age recipient '{recipient[:16]}': expected an X25519 "
+                         f"public key (age1…)")
+    return recipient
+
+
+def validate_identity(identity: str) -> str:
+    identity = (identity or '').strip()
+    if not _AGE_IDENTITY.match(identity):
+        raise ValueError("Invalid age identity: expected an X25519 secret key "
+                         "(AGE-SECRET-KEY-1…)")
+    return identity
+
+
+def read_identities(path: str) -> List[str]:
+    """The AGE-SECRET-KEY-1 lines of an identity file (as written by age-keygen)."""
+    with open(path, encoding='utf-8') as f:
+        lines = [line.strip() for line in f]
+    return [validate_identity(line) for line in lines if line and not line.startswith('#')]
+
+
+def _require_pyrage():
+    if pyrage is None:
+        raise RuntimeError("pyrage is not installed — pip install pyrage to use age encryption")
@@ -0,0 +1,488 @@
+"""
+Snippet Engines — runtime registry of language engines for the staging pipeline.
+
+An Engine is everything the pipeline needs to know about one language:
+
+    stage(src, opts)             queue src into the bound pipeline → staging_id
+    promote(staging_id, opts)    promote a PASSED snippet of opts.namespace
+                                 (opts.dry_run: DryRunReport, nothing committed)
+    run(src, params, timeout)    execute in isolation → RunResult
+    run_with(src, RunOptions)    run() with options, incl. injected env (supports_env),
+                                 an isolation strategy (supports_isolation) and
+                                 per-run resource limits (supports_limits)
+    validate(src)                static checks → [Diagnostic]
+    format_for_stage(src)        source as it should be hashed and stored
+    merge_sources([src, …])      one program from dependencies + the snippet (snippet_deps)
+
+Engines are registered by language name and looked up from a snippet's
+`language` field, so a third-party runtime plugs in without touching the
+pipeline or the web interface:
+
+    register_engine('tinygo', TinyGoEngine())      # EngineRegistrationError if taken
+
+Every engine promotes into one of the NodeRegistry engine rows
+(`engine_letter`); a new language borrows a row rather than adding one.
+
+run_with() logs each run at DEBUG, and a run that raises at ERROR,
+through the bound pipeline's logger (see snippet_log).
+
+Built in:
+    python   PythonEngine — fresh PythonExecutor per run (never the live REPL),
+             params become namespace variables, validate() = compile();
+             RunOptions.isolation above NONE runs it in a child interpreter
+             (PythonProcessExecutor, snippet_isolation)
+    go       GoEngine — `go build` + run with a hard deadline, params bound through
+             the snippet_params var block, validate() = go vet analyzers,
+             format_on_stage=True runs sources through gofmt (snippet_format),
+             RunOptions.env becomes the program's environment (snippet_env),
+             fd 3 is captured as RunResult.structured_output (snippet_output),
+             RunOptions.isolation wraps the built program (snippet_isolation),
+             RunOptions.resource_limits replace the engine's own (snippet_limits)
+"""
+
+import ast
+import json
+import logging
+import threading
+import time
+from abc import ABC, abstractmethod
+from dataclasses import dataclass, field, asdict
+from typing import Any, Dict, List, Optional
+
+from .snippet_lint import LintDiagnostic, SnippetLinter
+from .snippet_format import GoFormatter
+from .snippet_limits import ResourceLimits
+from .snippet_coverage import CoverageGate
+from .snippet_bench import BenchmarkConfig, BenchmarkResult
+from .snippet_mutation import Mutation, MutationConfig
+from .snippet_namespace import validate_namespace
+from .snippet_env import EnvSpecError, validate_env
+from .snippet_deps import merge_go_sources
+from .snippet_params import ParameterSpec, bind_parameters, infer_param_type
+from .snippet_isolation import IsolationLevel, IsolationStrategy, warn_not_isolatable
+from .snippet_log import default_logger, log
+
+
+# Engines report the same finding shape the lint gate does
+Diagnostic = LintDiagnostic
+
+
+class EngineRegistrationError(ValueError):
+    """An engine name is already taken (or the engine is malformed)."""
+
+
+@dataclass
+class StageOptions:
+    """Queue-time options for Engine.stage()."""
+    label: str = ''
+    engine_letter: str = ''                  # '' = the engine's own row
+    label_policy: Optional[str] = None
+    parameters: List[Dict[str, Any]] = field(default_factory=list)
+    namespace: str = ''                      # '' = the default namespace
+    env: Dict[str, str] = field(default_factory=dict)   # Injected at run time (supports_env)
+    requires: List[str] = field(default_factory=list)   # Labels merged in first (snippet_deps)
+    output_schema: str = ''                  # JSON Schema for the fd 3 result (supports_result_fd)
+    tags: List[str] = field(default_factory=list)       # Hierarchical paths (snippet_tags)
+    deterministic: bool = False              # Speculations may come from the ResultCache (snippet_cache)
+    suppress_credential_check: bool = False  # Stage a flagged fixture anyway (snippet_credentials)
+
+
+@dataclass
+class PromoteOptions:
+    """Options for Engine.promote()."""
+    namespace: str = ''                      # Must be the namespace the snippet was staged in
+    skip_lint: bool = False
+    dry_run: bool = False                    # Report every gate, commit nothing (snippet_dryrun)
+
+
+@dataclass
+class RunOptions:
+    """Options for Engine.run_with()."""
+    params: Dict[str, Any] = field(default_factory=dict)
+    timeout: Optional[float] = None          # None = the engine's default_timeout
+    env: Dict[str, str] = field(default_factory=dict)   # EnvSpec, see snippet_env
+    isolation: Optional[IsolationStrategy] = None       # snippet_isolation; None = as it is
+    resource_limits: Optional[ResourceLimits] = None    # None = the engine's own (supports_limits)
+
+
+@dataclass
+class RunResult:
+    """Outcome of one isolated run."""
+    success: bool
+    output: str = ''
+    error: str = ''
+    execution_time: float = 0.0
+    timed_out: bool = False
+    variables: Dict[str, Any] = field(default_factory=dict)
+    resource_violation: str = ''             # snippet_limits.VIOLATION_*, or ''
+    structured_output: str = ''              # Written to fd 3, unparsed (snippet_output)
+    coverage_percent: Optional[float] = None # Measured by a CoverageGate (snippet_coverage)
+    coverage_failed: bool = False            # Below the gate's min_coverage_percent
+    benchmarks: List[BenchmarkResult] = field(default_factory=list)  # snippet_bench
+    mutants_tested: int = 0                  # snippet_mutation
+    survived_mutations: List[Mutation] = field(default_factory=list)
+    weak_spec: bool = False                  # More survivors than the MutationConfig threshold
+    exit_code: Optional[int] = None          # The program's exit status (None = not reported)
+
+    def to_dict(self) -> Dict:
+        return asdict(self)
+
+
+class Engine(ABC):
+    """A language runtime the staging pipeline can dispatch to."""
+
+    language: str = ''
+    engine_letter: str = ''                  # NodeRegistry row promotions land in
+    file_extension: str = '.txt'
+    default_timeout: Optional[float] = 10.0
+    supports_env: bool = False               # run() takes env= (a subprocess to give it to)
+    supports_result_fd: bool = False         # run() captures fd 3 into structured_output
+    supports_isolation: bool = False         # run() takes isolation= (snippet_isolation)
+    supports_limits: bool = False            # run() takes resource_limits= (snippet_limits)
+
+    _pipeline = None
+
+    def bind(self, pipeline):
+        """Attach the StagingPipeline that stage() queues into."""
+        self._pipeline = pipeline
+
+    @property
+    def logger(self) -> logging.Logger:
+        """The bound pipeline's logger (snippet_log's default when unbound)."""
+        return getattr(self._pipeline, 'logger', None) or default_logger()
+
+    def stage(self, src: bytes, opts: Optional[StageOptions] = None) -> str:
+        """Queue `src` into the bound pipeline; returns the staging_id."""
+        if self._pipeline is None:
+            raise RuntimeError(f"Engine '{self.language}' is not bound to a pipeline")
+        opts = opts or StageOptions()
+        snippet = self._pipeline.queue_snippet(
+            opts.engine_letter or self.engine_letter, self.language,
+            _text(src), opts.label, label_policy=opts.label_policy,
+            parameters=opts.parameters, namespace=validate_namespace(opts.namespace),
+            env=opts.env or None, requires=opts.requires or None,
+            output_schema=opts.output_schema or None, tags=opts.tags or None,
+            deterministic=opts.deterministic,
+            suppress_credential_check=opts.suppress_credential_check)
+        return snippet.staging_id
+
+    def promote(self, staging_id: str, opts: Optional[PromoteOptions] = None):
+        """
+        Promote a snippet staged in `opts.namespace`; returns the StagedSnippet,
+        or with `opts.dry_run` the DryRunReport of a promotion that isn't made.
+        """
+        if self._pipeline is None:
+            raise RuntimeError(f"Engine '{self.language}' is not bound to a pipeline")
+        opts = opts or PromoteOptions()
+        if opts.dry_run:
+            return self._pipeline.dry_run_promote(staging_id, skip_lint=opts.skip_lint,
+                                                  namespace=validate_namespace(opts.namespace))
+        return self._pipeline.promote(staging_id, skip_lint=opts.skip_lint,
+                                      namespace=validate_namespace(opts.namespace))
+
+    @abstractmethod
+    def run(self, src: bytes, params: Optional[Dict[str, Any]] = None,
+            timeout: Optional[float] = None) -> RunResult:
+        """Execute `src` in isolation with `params` bound."""
+
+    def run_with(self, src: bytes, opts: Optional[RunOptions] = None) -> RunResult:
+        """run() with `opts`; EnvSpecError for a bad env or one this engine can't inject."""
+        opts = opts or RunOptions()
+        env = validate_env(opts.env)
+        kwargs: Dict[str, Any] = {}
+        if env:
+            if not self.supports_env:
+                raise EnvSpecError(f"Engine '{self.language}' does not support environment injection")
+            kwargs['env'] = env
+        if opts.isolation is not None and opts.isolation.level != IsolationLevel.NONE:
+            if self.supports_isolation:
+                kwargs['isolation'] = opts.isolation
+            else:
+                warn_not_isolatable(self.language, opts.isolation)
+        if opts.resource_limits is not None and opts.resource_limits.enabled:
+            if not self.supports_limits:
+                raise ValueError(f"Engine '{self.language}' does not support per-run resource limits")
+            kwargs['resource_limits'] = opts.resource_limits
+        started = time.perf_counter()
+        try:
+            result = self.run(src, opts.params or None, opts.timeout, **kwargs)
+        except Exception as exc:
+            log(self.logger, logging.ERROR, 'engine_run_failed', language=self.language,
+                duration_ms=(time.perf_counter() - started) * 1000, error=str(exc))
+            raise
+        log(self.logger, logging.DEBUG, 'engine_run', language=self.language,
+            duration_ms=(time.perf_counter() - started) * 1000, success=result.success,
+            timed_out=result.timed_out or None)
+        return result
+
+    @abstractmethod
+    def validate(self, src: bytes) -> List[Diagnostic]:
+        """Static checks; an empty list means clean."""
+
+    def format_for_stage(self, src: bytes) -> bytes:
+        """The source queue_snippet() hashes and stores (default: unchanged)."""
+        return src
+
+    def merge_sources(self, sources: List[str]) -> str:
+        """One program from a snippet's dependencies then itself (default: concatenated)."""
+        return '\n\n'.join(src.rstrip('\n') for src in sources) + '\n'
+
+    def describe(self) -> Dict:
+        return {
+            'language': self.language,
+            'engine_letter': self.engine_letter,
+            'file_extension': self.file_extension,
+            'default_timeout': self.default_timeout,
+            'supports_env': self.supports_env,
+            'supports_result_fd': self.supports_result_fd,
+            'supports_isolation': self.supports_isolation,
+            'supports_limits': self.supports_limits,
+            'class': type(self).__name__,
+        }
+
+
+def _text(src) -> str:
+    return src.decode('utf-8') if isinstance(src, (bytes, bytearray)) else src
+
+
+class EngineRegistry:
+    """Thread-safe language name → Engine map."""
+
+    def __init__(self):
+        self._lock = threading.Lock()
+        self._engines: Dict[str, Engine] = {}
+
+    def register(self, name: str, engine: Engine):
+        key = (name or '').lower().strip()
+        if not key:
+            raise EngineRegistrationError("Engine name is required")
+        if not isinstance(engine, Engine):
+            raise EngineRegistrationError(f"{engine!r} does not implement Engine")
+        with self._lock:
+            if key in self._engines:
+                raise EngineRegistrationError(f"Engine '{key}' is already registered")
+            if not engine.language:
+                engine.language = key
+            self._engines[key] = engine
+
+    def unregister(self, name: str) -> bool:
+        with self._lock:
+            return self._engines.pop(name.lower().strip(), None) is not None
+
+    def get(self, name: str) -> Optional[Engine]:
+        with self._lock:
+            return self._engines.get((name or '').lower().strip())
+
+    def names(self) -> List[str]:
+        with self._lock:
+            return sorted(self._engines)
+
+    def bind_all(self, pipeline):
+        with self._lock:
+            engines = list(self._engines.values())
+        for engine in engines:
+            engine.bind(pipeline)
+
+
+# ═══════════════════════════════════════════════════════════════════════════
+# BUILT-IN ENGINES
+# ═══════════════════════════════════════════════════════════════════════════
+
+class PythonEngine(Engine):
+    """In-process Python with a disposable namespace per run."""
+
+    language = 'python'
+    engine_letter = 'a'
+    file_extension = '.py'
+    default_timeout = None                   # exec() runs to completion unless asked
+    supports_isolation = True
+
+    def run(self, src, params=None, timeout=None, isolation=None) -> RunResult:
+        from .execution_engine import PythonExecutor, PythonProcessExecutor
+        limit = timeout if timeout is not None else self.default_timeout
+        if isolation is not None and isolation.level != IsolationLevel.NONE:
+            sandbox = PythonProcessExecutor(execution_timeout=limit)
+            for name, value in (params or {}).items():
+                sandbox.set_variable_value(name, value)
+            result = sandbox.execute(_text(src), isolation=isolation)
+            return RunResult(success=result.success, output=result.output or '',
+                             error=str(result.error) if result.error else '',
+                             execution_time=result.execution_time, timed_out=result.timed_out,
+                             variables={k: v for k, v in result.variables.items()
+                                        if k not in (params or {})})
+
+        sandbox = PythonExecutor()
+        for name, value in (params or {}).items():
+            sandbox.global_namespace[name] = value
+
+        box: Dict[str, Any] = {}
+        if limit is None:
+            box['result'] = sandbox.execute(_text(src))
+        else:
+            # exec() can't be interrupted: on timeout the run is abandoned, not
+            # killed, and keeps sys.stdout redirected until it finishes
+            worker = threading.Thread(
+                target=lambda: box.update(result=sandbox.execute(_text(src))),
+                daemon=True, name='python-engine-run')
+            start = time.time()
+            worker.start()
+            worker.join(limit)
+            if worker.is_alive():
+                return RunResult(success=False,
+                                 error=f'Python execution timed out after {limit:g}s',
+                                 execution_time=time.time() - start, timed_out=True)
+
+        result = box['result']
+        variables = {}
+        for k, v in (result.variables or {}).items():
+            if k.startswith('__') or k in (params or {}):
+                continue
+            try:
+                json.dumps(v)
+                variables[k] = v
+            except (TypeError, ValueError):
+                variables[k] = repr(v)[:200]
+        return RunResult(
+            success=result.success,
+            output=result.output or '',
+            error=str(result.error) if result.error else '',
+            execution_time=result.execution_time,
+            variables=variables,
+        )
+
+    def validate(self, src) -> List[Diagnostic]:
+        try:
+            ast.parse(_text(src))
+        except SyntaxError as exc:
+            return [Diagnostic('syntax', exc.lineno or 0, exc.offset or 0, exc.msg)]
+        return []
+
+
+class GoEngine(Engine):
+    """`go build` + run with a hard deadline; params bound as a generated var block."""
+
+    language = 'go'
+    engine_letter = 'i'
+    file_extension = '.go'
+    supports_env = True
+    supports_result_fd = True
+    supports_isolation = True
+    supports_limits = True
+
+    def __init__(self, linter: Optional[SnippetLinter] = None,
+                 default_timeout: float = 10.0,
+                 format_on_stage: bool = False,
+                 formatter: Optional[GoFormatter] = None,
+                 resource_limits: Optional[ResourceLimits] = None,
+                 coverage_gate: Optional[CoverageGate] = None,
+                 benchmark: Optional[BenchmarkConfig] = None,
+                 mutation: Optional[MutationConfig] = None):
+        self._linter = linter
+        self.default_timeout = default_timeout
+        self.format_on_stage = format_on_stage
+        self._formatter = formatter
+        self.resource_limits = resource_limits or ResourceLimits()
+        self.coverage_gate = coverage_gate
+        self.benchmark = benchmark
+        self.mutation = mutation
+
+    def run(self, src, params=None, timeout=None, env=None, isolation=None,
+            resource_limits=None) -> RunResult:
+        from .execution_engine import GoExecutor
+        code = _text(src)
+        if params:
+            specs = [ParameterSpec(name, infer_param_type(value)) for name, value in params.items()]
+            code = bind_parameters(code, specs, params)
+        executor = GoExecutor(execution_timeout=timeout if timeout is not None
+                              else self.default_timeout,
+                              resource_limits=resource_limits or self.resource_limits,
+                              coverage_gate=self.coverage_gate,
+                              benchmark=self.benchmark,
+                              mutation=self.mutation)
+        result = executor.execute(code, env=env, isolation=isolation)
+        return RunResult(
+            success=result.success,
+            output=result.output or '',
+            error=str(result.error) if result.error else '',
+            execution_time=result.execution_time,
+            timed_out=result.timed_out,
+            resource_violation=result.resource_violation,
+            structured_output=result.structured_output,
+            coverage_percent=result.coverage_percent,
+            coverage_failed=result.coverage_failed,
+            benchmarks=result.benchmarks,
+            mutants_tested=result.mutants_tested,
+            survived_mutations=result.survived_mutations,
+            weak_spec=result.weak_spec,
+            exit_code=result.exit_code,
+        )
+
+    def validate(self, src) -> List[Diagnostic]:
+        if self._linter is None:
+            from .snippet_lint import GoVetLinter
+            self._linter = GoVetLinter()
+        return self._linter.lint(_text(src)).diagnostics
+
+    def format_for_stage(self, src) -> bytes:
+        """gofmt'd source when format_on_stage is set; FormatFailedError if it isn't Go."""
+        if not self.format_on_stage:
+            return src
+        if self._formatter is None:
+            self._formatter = GoFormatter()
+        if not self._formatter.available:
+            return src                           # no gofmt: stage as submitted
+        return self._formatter.format(src.encode('utf-8') if isinstance(src, str) else src)
+
+    def merge_sources(self, sources: List[str]) -> str:
+        """One package clause and import block for the merged files."""
+        return merge_go_sources(sources)
+
+    def describe(self) -> Dict:
+        return {**super().describe(), 'format_on_stage': self.format_on_stage}
+
+
+class EngineExecutor:
+    """Executor-shaped adapter (`execute(code)`) over an Engine, for callers
+    that still speak the execution_engine protocol."""
+
+    def __init__(self, engine: Engine):
+        self.engine = engine
+
+    def execute(self, code: str, capture_output: bool = True,
+                env: Optional[Dict[str, str]] = None):
+        from .execution_engine import ExecutionResult
+        result = self.engine.run_with(code.encode('utf-8'), RunOptions(env=env or {}))
+        return ExecutionResult(success=result.success, output=result.output,
+                               error=Exception(result.error) if result.error else None,
+                               variables=result.variables,
+                               execution_time=result.execution_time,
+                               timed_out=result.timed_out,
+                               resource_violation=result.resource_violation,
+                               structured_output=result.structured_output,
+                               coverage_percent=result.coverage_percent,
+                               coverage_failed=result.coverage_failed,
+                               benchmarks=result.benchmarks,
+                               mutants_tested=result.mutants_tested,
+                               survived_mutations=result.survived_mutations,
+                               weak_spec=result.weak_spec,
+                               exit_code=result.exit_code)
+
+    def execute_single_statement(self, s): return self.execute(s)
+    def reset_namespace(self): pass
+    def set_variable_value(self, n, v): pass
+    def get_variable_value(self, n): return None
+
+
+# Process-wide registry used by pipelines not given their own
+DEFAULT_ENGINES = EngineRegistry()
+DEFAULT_ENGINES.register('python', PythonEngine())
+DEFAULT_ENGINES.register('go', GoEngine())
+
+
+def register_engine(name: str, engine: Engine):
+    """Register `engine` for `name` on the default registry (EngineRegistrationError if taken)."""
+    DEFAULT_ENGINES.register(name, engine)
+
+
+def get_engine(name: str) -> Optional[Engine]:
+    return DEFAULT_ENGINES.get(name)
@@ -0,0 +1,84 @@
+"""
+Snippet Env — environment variables injected into a snippet's own process.
+
+Configuration a snippet needs at run time (API keys, feature flags) can
+be staged next to its source instead of being hard-coded in it:
+
+    pipeline.queue_snippet('i', 'go', code, 'svc', env={'FEATURE_X': 'on'})
+
+The variables are only ever handed to the child process that runs the
+snippet — the host's os.environ is never modified — and that child does
+not inherit the host environment either: it gets child_environ(env), the
+injected variables on top of a short allow-list of host variables the
+program's runtime needs (PATH, locale, temp dir).
+
+Keys must match [A-Z_][A-Z0-9_]* and may not name a variable that
+steers the toolchain or the loader (RESERVED_ENV_NAMES).  env_hash()
+makes the variables part of a snippet's identity: the same source staged
+with different envs gets its own circuit breaker and index record.
+"""
+
+import os
+import re
+import json
+import hashlib
+from typing import Dict, Mapping, Optional
+
+
+_KEY = re.compile(r'^[A-Z_][A-Z0-9_]*$')
+
+# Variables a snippet may not set: they would change how the toolchain
+# builds it or how the loader starts it, not just what the program reads
+RESERVED_ENV_NAMES = frozenset({
+    'GOPATH', 'GOPROXY', 'GOROOT', 'GOCACHE', 'GOMODCACHE', 'GOFLAGS', 'GOENV',
+    'GOOS', 'GOARCH', 'GOTOOLCHAIN', 'GOWORK', 'GOTMPDIR', 'GOPRIVATE', 'GONOSUMDB',
+    'GONOPROXY', 'GOSUMDB', 'GOINSECURE', 'GO111MODULE', 'GODEBUG', 'GOGC',
+    'GOMAXPROCS', 'GOTRACEBACK', 'CGO_ENABLED', 'CGO_CFLAGS', 'CGO_LDFLAGS',
+    'PATH', 'HOME', 'TMPDIR', 'SHELL', 'IFS', 'LD_PRELOAD', 'LD_LIBRARY_PATH',
+    'DYLD_INSERT_LIBRARIES', 'DYLD_LIBRARY_PATH',
+})
+
+# Host variables the child keeps (its runtime needs them to start at all)
+PASSTHROUGH_ENV_NAMES = ('PATH', 'SYSTEMROOT', 'TEMP', 'TMP', 'TMPDIR', 'LANG', 'LC_ALL', 'TZ')
+
+MAX_ENV_VALUE = 32 * 1024            # bytes per value
+
+
+class EnvSpecError(ValueError):
+    """An injected environment variable has a bad or reserved name, or a bad value."""
+
+
+def validate_env(env: Optional[Mapping[str, str]]) -> Dict[str, str]:
+    """A checked copy of `env` (None → {}); EnvSpecError on the first bad entry."""
+    checked: Dict[str, str] = {}
+    for key, value in sorted((env or {}).items()):
+        if not isinstance(key, str) or not _KEY.match(key):
+            raise EnvSpecError(f"Invalid environment variable name {key!r}: "
+                               f"must match [A-Z_][A-Z0-9_]*")
+        if key in RESERVED_ENV_NAMES:
+            raise EnvSpecError(f"Environment variable '{key}' is reserved")
+        if not isinstance(value, str):
+            raise EnvSpecError(f"Environment variable '{key}' must be a string "
+                               f"(got {type(value).__name__})")
+        if '\x00' in value or len(value.encode('utf-8')) > MAX_ENV_VALUE:
+            raise EnvSpecError(f"Environment variable '{key}' has a NUL byte or is "
+                               f"over {MAX_ENV_VALUE} bytes")
+        checked[key] = value
+    return checked
+
+
+def env_hash(env: Mapping[str, str]) -> str:
+    """SHA-256 over the sorted variables; '' when there are none."""
+    if not env:
+        return ''
+    canonical = json.dumps(sorted(env.items()), separators=(',', ':'))
+    return hashlib.sha256(canonical.encode('utf-8')).hexdigest()
+
+
+def child_environ(env: Mapping[str, str],
+                  host: Optional[Mapping[str, str]] = None) -> Dict[str, str]:
+    """The complete environment a snippet's process starts with."""
+    host = os.environ if host is None else host
+    child = {name: host[name] for name in PASSTHROUGH_ENV_NAMES if name in host}
+    child.update(env)
+    return child
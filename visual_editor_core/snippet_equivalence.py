@@ -0,0 +1,161 @@
+"""
+Snippet Equivalence — whether two sources differ only in layout and comments.
+
+A diff that only re-indents, re-wraps or re-comments a snippet changes
+no behaviour.  equivalent() compares the two sources' syntax trees with
+positions and comments stripped:
+
+    go       go/parser + go/ast (a small helper program, built once per
+             process with the go toolchain on PATH)
+    python   the stdlib ast module (ast.dump without line attributes)
+
+Other languages — and Go without a toolchain — are compared as text, so
+only identical sources are equivalent.  A source that doesn't parse is
+never equivalent to anything; the parse error comes back with the
+verdict.
+"""
+
+import ast
+import os
+import shutil
+import tempfile
+import threading
+from dataclasses import dataclass, asdict
+from typing import Dict, Optional
+
+
+DEFAULT_EQUIVALENCE_TIMEOUT = 60.0       # Seconds to build and run the Go helper
+
+GO_AST = 'go_ast'
+PYTHON_AST = 'python_ast'
+TEXT = 'text'
+
+# Prints "equivalent" or "different" for two Go files; exit 2 on a parse error
+_GO_HELPER = r'''package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"reflect"
+	"strings"
+)
+
+var posType = reflect.TypeOf(token.NoPos)
+
+func dump(path string) (string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	err = ast.Fprint(&b, nil, file, func(name string, v reflect.Value) bool {
+		if v.IsValid() && v.Type() == posType {
+			return false
+		}
+		switch name {
+		case "Doc", "Comment", "Comments", "Obj", "Scope", "Unresolved", "Imports":
+			return false
+		}
+		return ast.NotNilFilter(name, v)
+	})
+	return b.String(), err
+}
+
+func main() {
+	a, err := dump(os.Args[1])
+	if err == nil {
+		var b string
+		if b, err = dump(os.Args[2]); err == nil {
+			if a == b {
+				fmt.Println("equivalent")
+			} else {
+				fmt.Println("different")
+			}
+			return
+		}
+	}
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(2)
+}
+'''
+
+_helper_lock = threading.Lock()
+_helpers: Dict[str, str] = {}            # go binary → built helper path
+
+
+@dataclass
+class Equivalence:
+    """The verdict of equivalent()."""
+    equivalent: bool
+    method: str                          # GO_AST | PYTHON_AST | TEXT
+    error: str = ''                      # Why a source couldn't be parsed
+
+    def to_dict(self) -> Dict:
+        return asdict(self)
+
+
+def _go_helper(go_path: str, timeout: float) -> str:
+    """Path of the built Go helper (built on first use)."""
+    from .execution_engine import _run_with_deadline
+    with _helper_lock:
+        helper = _helpers.get(go_path)
+        if helper and os.path.exists(helper):
+            return helper
+        build_dir = tempfile.mkdtemp(prefix='vpyd_equiv_')
+        with open(os.path.join(build_dir, 'main.go'), 'w', encoding='utf-8') as f:
+            f.write(_GO_HELPER)
+        helper = os.path.join(build_dir, 'astequal')
+        proc, timed_out = _run_with_deadline([go_path, 'build', '-o', helper, 'main.go'],
+                                             timeout=timeout, cwd=build_dir)
+        if timed_out or proc.returncode != 0:
+            shutil.rmtree(build_dir, ignore_errors=True)
+            raise RuntimeError(f"Could not build the Go AST helper: "
+                               f"{'timed out' if timed_out else (proc.stderr or '').strip()}")
+        _helpers[go_path] = helper
+        return helper
+
+
+def _go_equivalent(old: str, new: str, go_path: str, timeout: float) -> Equivalence:
+    from .execution_engine import _run_with_deadline
+    helper = _go_helper(go_path, timeout)
+    tmp_dir = tempfile.mkdtemp(prefix='vpyd_equiv_')
+    try:
+        for name, source in (('old.go', old), ('new.go', new)):
+            with open(os.path.join(tmp_dir, name), 'w', encoding='utf-8') as f:
+                f.write(source)
+        proc, timed_out = _run_with_deadline([helper, 'old.go', 'new.go'],
+                                             timeout=timeout, cwd=tmp_dir)
+    finally:
+        shutil.rmtree(tmp_dir, ignore_errors=True)
+    if timed_out:
+        return Equivalence(False, GO_AST, f"AST comparison timed out after {timeout:g}s")
+    if proc.returncode != 0:
+        return Equivalence(False, GO_AST, (proc.stderr or '').strip())
+    return Equivalence(proc.stdout.strip() == 'equivalent', GO_AST)
+
+
+def _python_equivalent(old: str, new: str) -> Equivalence:
+    try:
+        return Equivalence(ast.dump(ast.parse(old)) == ast.dump(ast.parse(new)), PYTHON_AST)
+    except SyntaxError as exc:
+        return Equivalence(False, PYTHON_AST, f"line {exc.lineno}: {exc.msg}")
+
+
+def equivalent(old: str, new: str, language: str, go_path: Optional[str] = None,
+               timeout: float = DEFAULT_EQUIVALENCE_TIMEOUT) -> Equivalence:
+    """Whether `old` and `new` (both in `language`) have the same syntax tree."""
+    language = (language or '').lower()
+    if language == 'go':
+        go_path = go_path or shutil.which('go')
+        if go_path:
+            try:
+                return _go_equivalent(old, new, go_path, timeout)
+            except (OSError, RuntimeError):
+                pass                     # No usable toolchain: compare as text
+    elif language == 'python':
+        return _python_equivalent(old, new)
+    return Equivalence(old == new, TEXT)
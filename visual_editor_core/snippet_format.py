@@ -0,0 +1,116 @@
+"""
+Snippet Format — normalise Go sources with gofmt before they are staged.
+
+A snippet's code_hash is taken over its exact bytes, so two submissions
+that differ only in indentation or spacing hash differently: the
+content-addressed store keeps both, the circuit breaker tracks them
+separately and label history shows a diff that isn't one.  With
+`GoEngine(format_on_stage=True)` the source goes through gofmt (the
+`go/format` package's command-line front end) first:
+
+    queue_snippet(code) ──► engine.format_for_stage(code)
+                                 ├── formatted ──► code_hash, persist, …
+                                 └── not valid Go ──► FormatFailedError (nothing queued)
+
+dry_run_format() formats without staging, so callers can preview what
+would be stored.  Without a gofmt binary on PATH, staging leaves the
+source as submitted (as the lint gate does for missing tools) and
+dry_run_format() raises FormatUnavailableError.
+"""
+
+import os
+import re
+import shutil
+import tempfile
+from typing import List, Optional
+
+from .snippet_lint import LintDiagnostic
+
+
+DEFAULT_FORMAT_TIMEOUT = 10.0        # seconds
+
+# gofmt error lines: "<file>:<line>:<col>: <message>"
+_ERROR_LINE = re.compile(r'^[^:\n]*:(\d+):(\d+):\s*(.*)$')
+
+
+class FormatFailedError(ValueError):
+    """The source could not be formatted (it is not valid Go)."""
+
+    def __init__(self, message: str, cause: str,
+                 diagnostics: Optional[List[LintDiagnostic]] = None):
+        super().__init__(message)
+        self.cause = cause                       # gofmt's own error output
+        self.diagnostics = diagnostics or []
+
+
+class FormatUnavailableError(RuntimeError):
+    """No gofmt binary was found to format with."""
+
+
+class GoFormatter:
+    """Runs gofmt over one source file."""
+
+    def __init__(self, gofmt_path: Optional[str] = None,
+                 timeout: float = DEFAULT_FORMAT_TIMEOUT):
+        self._gofmt_path = gofmt_path or shutil.which('gofmt')
+        self._timeout = timeout
+
+    @property
+    def available(self) -> bool:
+        return bool(self._gofmt_path)
+
+    def format(self, src: bytes) -> bytes:
+        """gofmt'd `src`; FormatFailedError if gofmt rejects it."""
+        if not self._gofmt_path:
+            raise FormatUnavailableError("gofmt not found on PATH")
+        from .execution_engine import _run_with_deadline
+
+        tmp_dir = tempfile.mkdtemp(prefix='vpyd_fmt_')
+        try:
+            src_path = os.path.join(tmp_dir, 'main.go')
+            with open(src_path, 'wb') as f:
+                f.write(src)
+            proc, timed_out = _run_with_deadline(
+                [self._gofmt_path, 'main.go'], timeout=self._timeout, cwd=tmp_dir,
+                encoding=None, errors=None)
+        finally:
+            shutil.rmtree(tmp_dir, ignore_errors=True)
+
+        if timed_out:
+            raise FormatFailedError(f"gofmt timed out after {self._timeout:g}s",
+                                    'timeout')
+        if proc.returncode != 0:
+            cause = (proc.stderr or b'').decode('utf-8', 'replace').strip()
+            diagnostics = parse_gofmt_errors(cause)
+            first = diagnostics[0] if diagnostics else None
+            where = f" at line {first.line}:{first.column}: {first.message}" if first else ''
+            raise FormatFailedError(f"Source is not valid Go{where}", cause, diagnostics)
+        return proc.stdout
+
+
+def parse_gofmt_errors(output: str) -> List[LintDiagnostic]:
+    """One LintDiagnostic (analyzer 'gofmt') per error line gofmt printed."""
+    diagnostics = []
+    for line in output.splitlines():
+        m = _ERROR_LINE.match(line.strip())
+        if m:
+            diagnostics.append(LintDiagnostic('gofmt', int(m.group(1)), int(m.group(2)),
+                                              m.group(3)))
+    return diagnostics
+
+
+_default_formatter: Optional[GoFormatter] = None
+
+
+def dry_run_format(src: bytes) -> bytes:
+    """
+    The source format_on_stage would store for `src`, without staging it.
+
+    Raises FormatFailedError if `src` is not valid Go and
+    FormatUnavailableError if gofmt is not installed.
+    """
+    global _default_formatter
+    if _default_formatter is None:
+        _default_formatter = GoFormatter()
+    text = src.encode('utf-8') if isinstance(src, str) else bytes(src)
+    return _default_formatter.format(text)
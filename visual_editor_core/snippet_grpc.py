@@ -0,0 +1,372 @@
+"""
+Snippet gRPC Service — remote access to the staging pipeline.
+
+Implements `spokedpy.staging.v1.SnippetService` (proto/snippet_service.proto)
+on top of an in-process StagingPipeline, so CLI clients, CI jobs and other
+tooling can drive queue → speculate → promote → rollback without importing
+the library:
+
+    remote client ──gRPC──►  BearerTokenInterceptor   (authorization metadata)
+                                   │
+                                   ▼
+                           SnippetServicer ──► StagingPipeline.queue_snippet()
+                                                              .promote() …
+
+Pipeline errors map onto gRPC status codes:
+
+    unknown staging_id              NOT_FOUND
+    LabelConflictError              ALREADY_EXISTS
+    SlotFullError                   RESOURCE_EXHAUSTED
+    LintFailedError / PhaseError    FAILED_PRECONDITION
+    BenchmarkRegressionError        FAILED_PRECONDITION
+    PendingApprovalError            FAILED_PRECONDITION
+    CircuitOpenError                UNAVAILABLE
+    bad namespace credential        UNAUTHENTICATED
+    bad admin credential            PERMISSION_DENIED
+    any other ValueError            INVALID_ARGUMENT
+
+Calls act inside the namespace named by `x-spokedpy-namespace` metadata
+('default' when absent), and must carry that namespace's credential in
+`x-spokedpy-namespace-credential` (see snippet_namespace); snippets in
+other namespaces are NOT_FOUND unless `x-spokedpy-admin-credential`
+carries the admin credential.
+
+The server listens on localhost unless bearer tokens are configured;
+serve() refuses a non-loopback address without them.
+
+grpcio is optional (like python-dotenv): without it this module still
+imports, but serve() / connect() raise a RuntimeError.  The message and
+stub modules are generated from the .proto by generate_stubs() (needs
+grpcio-tools) the first time they are loaded — or ahead of time with the
+command at the top of proto/snippet_service.proto.
+"""
+
+import os
+import hmac
+import json
+import collections
+from concurrent import futures
+from typing import Iterable, Optional
+
+try:
+    import grpc
+except ImportError:  # grpcio not installed — service unavailable
+    grpc = None
+
+from .snippet_capacity import SlotFullError
+from .snippet_lint import LintFailedError
+from .snippet_bench import BenchmarkRegressionError
+from .snippet_staging import LabelConflictError, PhaseError
+from .snippet_breaker import CircuitOpenError
+from .snippet_approvals import PendingApprovalError
+from .snippet_namespace import NamespaceAdminError, NamespaceAuthError, validate_namespace
+
+
+DEFAULT_ADDRESS = 'localhost:50051'
+AUTH_METADATA_KEY = 'authorization'
+NAMESPACE_METADATA_KEY = 'x-spokedpy-namespace'
+NAMESPACE_CREDENTIAL_METADATA_KEY = 'x-spokedpy-namespace-credential'
+ADMIN_METADATA_KEY = 'x-spokedpy-admin-credential'
+
+# Namespace a call stages into, and the one it can see (None = all, for admins)
+_Scope = collections.namedtuple('_Scope', ('stage', 'visible'))
+
+
+_REPO_ROOT = os.path.dirname(os.path.dirname(os.path.abspath(__file__)))
+_PROTO = os.path.join('visual_editor_core', 'proto', 'snippet_service.proto')
+_LOOPBACK_HOSTS = ('localhost', '127.0.0.1', '[::1]')
+
+
+def generate_stubs() -> None:
+    """
+    Run protoc (grpcio-tools) over proto/snippet_service.proto.
+
+    The include root is the repository root, so the generated
+    `_pb2_grpc` module imports its messages by package path and works
+    inside visual_editor_core.proto.
+    """
+    try:
+        from grpc_tools import protoc
+    except ImportError as exc:
+        raise RuntimeError(
+            "gRPC stubs not generated and grpcio-tools is not installed "
+            "(pip install grpcio-tools)") from exc
+    code = protoc.main(['grpc_tools.protoc', f'-I{_REPO_ROOT}',
+                        f'--python_out={_REPO_ROOT}', f'--grpc_python_out={_REPO_ROOT}',
+                        os.path.join(_REPO_ROOT, _PROTO)])
+    if code != 0:
+        raise RuntimeError(f"protoc failed on {_PROTO} (exit {code})")
+
+
+def load_stubs():
+    """Import the protoc-generated (pb2, pb2_grpc) modules, generating them if absent."""
+    try:
+        from .proto import snippet_service_pb2 as pb2
+        from .proto import snippet_service_pb2_grpc as pb2_grpc
+    except ImportError:
+        generate_stubs()
+        from .proto import snippet_service_pb2 as pb2
+        from .proto import snippet_service_pb2_grpc as pb2_grpc
+    return pb2, pb2_grpc
+
+
+def is_loopback(address: str) -> bool:
+    """True if a `host:port` listen address only accepts local connections."""
+    host = address.rsplit(':', 1)[0] if ':' in address else address
+    return host in _LOOPBACK_HOSTS
+
+
+def _require_grpc():
+    if grpc is None:
+        raise RuntimeError("grpcio is not installed (pip install grpcio grpcio-tools)")
+
+
+def _status(name: str):
+    """grpc.StatusCode member by name (the bare name when grpcio is absent)."""
+    return getattr(grpc.StatusCode, name) if grpc is not None else name
+
+
+def status_for(exc: Exception) -> str:
+    """Name of the gRPC status code a pipeline exception maps onto."""
+    if isinstance(exc, KeyError):
+        return 'NOT_FOUND'
+    if isinstance(exc, NamespaceAuthError):
+        return 'UNAUTHENTICATED'
+    if isinstance(exc, NamespaceAdminError):
+        return 'PERMISSION_DENIED'
+    if isinstance(exc, LabelConflictError):
+        return 'ALREADY_EXISTS'
+    if isinstance(exc, SlotFullError):
+        return 'RESOURCE_EXHAUSTED'
+    if isinstance(exc, CircuitOpenError):
+        return 'UNAVAILABLE'
+    if isinstance(exc, (LintFailedError, BenchmarkRegressionError, PhaseError,
+                        PendingApprovalError)):
+        return 'FAILED_PRECONDITION'
+    if str(exc).startswith(('No staged snippet', 'No snippet with')):
+        return 'NOT_FOUND'
+    return 'INVALID_ARGUMENT'
+
+
+# ═══════════════════════════════════════════════════════════════════════════
+# SERVICER
+# ═══════════════════════════════════════════════════════════════════════════
+
+class SnippetServicer:
+    """SnippetService RPCs, each delegating to one StagingPipeline call."""
+
+    def __init__(self, pipeline, pb2=None):
+        self._pipeline = pipeline
+        self._pb2 = pb2 if pb2 is not None else load_stubs()[0]
+
+    def StageSnippet(self, request, context):
+        return self._call(context, self._stage, request)
+
+    def PromoteSnippet(self, request, context):
+        return self._call(context, lambda r, ns: self._message(
+            self._pipeline.promote(r.staging_id, skip_lint=r.skip_lint, namespace=ns.visible)),
+            request)
+
+    def GetSnippet(self, request, context):
+        return self._call(context, lambda r, ns: self._message(
+            self._lookup(r.staging_id, ns.visible)), request)
+
+    def ListSnippets(self, request, context):
+        def list_snippets(r, ns):
+            snippets = self._pipeline.get_active(ns.visible)
+            if r.include_history:
+                snippets += self._pipeline.get_history(r.limit or 50, ns.visible)
+            return self._pb2.ListSnippetsResponse(
+                snippets=[self._message(s) for s in snippets])
+        return self._call(context, list_snippets, request)
+
+    def RollbackSnippet(self, request, context):
+        def rollback(r, ns):
+            self._lookup(r.staging_id, ns.visible)
+            snippet = self._pipeline.rollback(r.staging_id, r.reason, namespace=ns.visible)
+            fields = {'snippet': self._message(snippet)}
+            if snippet.rolled_back_to:
+                fields['restored'] = self._message(
+                    self._pipeline.get_snippet(snippet.rolled_back_to, ns.visible))
+            return self._pb2.RollbackSnippetResponse(**fields)
+        return self._call(context, rollback, request)
+
+    # ── helpers ──────────────────────────────────────────────────────
+
+    def _stage(self, r, ns):
+        if not r.code.strip():
+            raise ValueError('No code provided')
+        if not r.engine_letter and not r.language:
+            raise ValueError('engine_letter or language required')
+        snippet = self._pipeline.queue_snippet(r.engine_letter, r.language, r.code,
+                                               r.label, label_policy=r.label_policy or None,
+                                               namespace=ns.stage, env=dict(r.env or {}) or None,
+                                               requires=list(r.requires) or None,
+                                               output_schema=r.output_schema or None,
+                                               tags=list(r.tags) or None,
+                                               suppress_credential_check=r.suppress_credential_check)
+        if r.speculate:
+            snippet = self._pipeline.speculate(snippet.staging_id)
+        return self._message(snippet)
+
+    def _lookup(self, staging_id: str, namespace: Optional[str]):
+        snippet = self._pipeline.get_snippet(staging_id, namespace)
+        if snippet is None:
+            raise KeyError(f"No snippet with staging_id '{staging_id}'")
+        return snippet
+
+    def _scope(self, context) -> _Scope:
+        """Namespaces for this call, from its x-spokedpy-* metadata."""
+        metadata = dict(context.invocation_metadata() or ())
+        stage = validate_namespace(metadata.get(NAMESPACE_METADATA_KEY))
+        credential = metadata.get(ADMIN_METADATA_KEY)
+        if credential is not None:
+            if not self._pipeline.check_admin_credential(credential):
+                raise NamespaceAdminError('Invalid namespace admin credential')
+            return _Scope(stage, None)
+        if not self._pipeline.check_namespace_credential(
+                stage, metadata.get(NAMESPACE_CREDENTIAL_METADATA_KEY)):
+            raise NamespaceAuthError(f"Namespace '{stage}' needs a valid "
+                                     f"{NAMESPACE_CREDENTIAL_METADATA_KEY}")
+        return _Scope(stage, stage)
+
+    def _message(self, snippet):
+        return self._pb2.Snippet(
+            staging_id=snippet.staging_id,
+            language=snippet.language,
+            engine_letter=snippet.engine_letter,
+            label=snippet.label,
+            code_hash=snippet.code_hash,
+            phase=snippet.phase.value,
+            spec_result=snippet.spec_result.value,
+            reserved_address=snippet.reserved_address,
+            spec_output=snippet.spec_output[:5000],
+            spec_error=snippet.spec_error,
+            spec_execution_time=snippet.spec_execution_time,
+            created_at=snippet.created_at,
+            promoted_at=snippet.promoted_at,
+            rolled_back_to=snippet.rolled_back_to,
+            saved_file_path=snippet.saved_file_path,
+            registry_slot_id=snippet.registry_slot_id,
+            label_policy=snippet.label_policy.value,
+            namespace=snippet.namespace,
+            env_hash=snippet.env_hash,
+            requires=snippet.requires,
+            spec_output_value=('' if snippet.spec_output_value is None
+                               else json.dumps(snippet.spec_output_value)),
+            output_schema_hash=snippet.output_schema_hash,
+            tags=snippet.tags,
+            suppress_credential_check=snippet.suppress_credential_check,
+        )
+
+    def _call(self, context, fn, request):
+        """Run `fn(request, scope)`, translating pipeline errors into context.abort()."""
+        try:
+            return fn(request, self._scope(context))
+        except (KeyError, ValueError, NamespaceAdminError, NamespaceAuthError) as exc:
+            message = exc.args[0] if isinstance(exc, KeyError) and exc.args else str(exc)
+            context.abort(_status(status_for(exc)), message)
+
+
+# ═══════════════════════════════════════════════════════════════════════════
+# AUTHENTICATION — bearer tokens in per-RPC metadata
+# ═══════════════════════════════════════════════════════════════════════════
+
+def token_matches(header: str, tokens: Iterable[str]) -> bool:
+    """True if an `authorization` header value carries one of `tokens`."""
+    if not header or not header.startswith('Bearer '):
+        return False
+    presented = header[len('Bearer '):].strip()
+    # compare_digest against every token so timing doesn't reveal which one matched
+    matched = False
+    for token in tokens:
+        matched |= hmac.compare_digest(presented.encode(), token.encode())
+    return matched
+
+
+if grpc is not None:
+
+    class BearerTokenInterceptor(grpc.ServerInterceptor):
+        """Rejects calls without `authorization: Bearer <token>` metadata."""
+
+        def __init__(self, tokens: Iterable[str]):
+            self._tokens = [t for t in tokens if t]
+
+            def deny(request, context):
+                context.abort(grpc.StatusCode.UNAUTHENTICATED, 'Missing or invalid bearer token')
+            self._deny = grpc.unary_unary_rpc_method_handler(deny)
+
+        def intercept_service(self, continuation, handler_call_details):
+            metadata = dict(handler_call_details.invocation_metadata or ())
+            if token_matches(metadata.get(AUTH_METADATA_KEY, ''), self._tokens):
+                return continuation(handler_call_details)
+            return self._deny
+
+    class _CallDetails(collections.namedtuple(
+            '_CallDetails', ('method', 'timeout', 'metadata', 'credentials',
+                             'wait_for_ready', 'compression')),
+            grpc.ClientCallDetails):
+        pass
+
+    class _BearerClientInterceptor(grpc.UnaryUnaryClientInterceptor):
+        """Attaches the bearer token to every outgoing call."""
+
+        def __init__(self, token: str):
+            self._token = token
+
+        def intercept_unary_unary(self, continuation, client_call_details, request):
+            metadata = list(client_call_details.metadata or ())
+            metadata.append((AUTH_METADATA_KEY, f'Bearer {self._token}'))
+            details = _CallDetails(
+                client_call_details.method, client_call_details.timeout, metadata,
+                client_call_details.credentials,
+                getattr(client_call_details, 'wait_for_ready', None),
+                getattr(client_call_details, 'compression', None))
+            return continuation(details, request)
+
+
+# ═══════════════════════════════════════════════════════════════════════════
+# SERVER / CLIENT
+# ═══════════════════════════════════════════════════════════════════════════
+
+def serve(pipeline, address: str = DEFAULT_ADDRESS,
+          tokens: Optional[Iterable[str]] = None,
+          tls_cert: Optional[bytes] = None, tls_key: Optional[bytes] = None,
+          max_workers: int = 8):
+    """
+    Start a SnippetService server and return it (already started).
+
+    `tokens` enables bearer-token authentication and is required for any
+    address other than localhost; `tls_cert` / `tls_key` (PEM bytes)
+    switch the port to TLS.
+    """
+    tokens = [t for t in (tokens or []) if t]
+    if not tokens and not is_loopback(address):
+        raise ValueError(f"gRPC address {address} is not loopback — "
+                         f"configure bearer tokens to listen on it")
+    _require_grpc()
+    pb2, pb2_grpc = load_stubs()
+    interceptors = [BearerTokenInterceptor(tokens)] if tokens else []
+    server = grpc.server(futures.ThreadPoolExecutor(max_workers=max_workers,
+                                                    thread_name_prefix='grpc-snippets'),
+                         interceptors=interceptors)
+    pb2_grpc.add_SnippetServiceServicer_to_server(SnippetServicer(pipeline, pb2), server)
+    if tls_cert and tls_key:
+        server.add_secure_port(address, grpc.ssl_server_credentials([(tls_key, tls_cert)]))
+    else:
+        server.add_insecure_port(address)
+    server.start()
+    return server
+
+
+def connect(address: str, token: str = '', root_certificates: Optional[bytes] = None):
+    """Open a channel and return a SnippetServiceStub for it."""
+    _require_grpc()
+    _, pb2_grpc = load_stubs()
+    if root_certificates is not None:
+        channel = grpc.secure_channel(address, grpc.ssl_channel_credentials(root_certificates))
+    else:
+        channel = grpc.insecure_channel(address)
+    if token:
+        channel = grpc.intercept_channel(channel, _BearerClientInterceptor(token))
+    return pb2_grpc.SnippetServiceStub(channel)
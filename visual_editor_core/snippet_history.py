@@ -0,0 +1,145 @@
+"""
+Promotion History — bounded per-slot log of promotions and rollbacks.
+
+A "slot" is an engine row (the `i` in `slot: i1 (position 1)`), and a
+production entry within it is identified by its label.  Every promotion
+and rollback of a (slot, label) lineage is recorded here, so a rollback
+can find the version that was live immediately before the one being
+reverted — and successive rollbacks keep walking further back:
+
+    promote  stg-a  ──►  promote  stg-b  ──►  promote  stg-c
+                                                  │
+    rollback stg-c  ──►  stg-b re-installed  ◄────┘
+    rollback stg-b  ──►  stg-a re-installed
+
+At least `depth` (default 10) promotions are retained per lineage.
+Lineages are per namespace: two tenants' `svc` labels on the same slot
+never roll back into each other.
+"""
+
+import time
+import threading
+from dataclasses import dataclass, asdict
+from typing import Callable, Dict, List, Optional, Tuple
+
+from .snippet_namespace import DEFAULT_NAMESPACE
+
+
+DEFAULT_HISTORY_DEPTH = 10
+
+
+@dataclass
+class PromotionRecord:
+    """One entry in a slot lineage's promotion history."""
+    event: str                               # 'promote' | 'rollback'
+    staging_id: str                          # Snippet promoted / rolled back
+    slot: str                                # Engine letter, e.g. 'i'
+    label: str
+    address: str                             # e.g. 'i1'
+    code_hash: str
+    timestamp: float
+    restored_staging_id: str = ''            # rollback only: version re-installed
+    namespace: str = DEFAULT_NAMESPACE
+
+    def to_dict(self) -> Dict:
+        return asdict(self)
+
+
+class PromotionHistory:
+    """Thread-safe, bounded promotion log keyed by (namespace, slot, label)."""
+
+    def __init__(self, depth: int = DEFAULT_HISTORY_DEPTH):
+        if depth < 1:
+            raise ValueError(f"History depth must be >= 1 (got {depth})")
+        self._depth = depth
+        self._lock = threading.Lock()
+        self._lineages: Dict[Tuple[str, str, str], List[PromotionRecord]] = {}
+
+    def record_promotion(self, snippet) -> PromotionRecord:
+        """Append a 'promote' record for a freshly promoted snippet."""
+        return self._append(PromotionRecord(
+            event='promote',
+            staging_id=snippet.staging_id,
+            slot=snippet.engine_letter,
+            label=snippet.label,
+            address=snippet.reserved_address,
+            code_hash=snippet.code_hash,
+            timestamp=snippet.promoted_at or time.time(),
+            namespace=snippet.namespace,
+        ))
+
+    def record_rollback(self, snippet, restored=None) -> PromotionRecord:
+        """Append a 'rollback' record; `restored` is the re-installed snippet."""
+        return self._append(PromotionRecord(
+            event='rollback',
+            staging_id=snippet.staging_id,
+            slot=snippet.engine_letter,
+            label=snippet.label,
+            address=snippet.reserved_address,
+            code_hash=snippet.code_hash,
+            timestamp=time.time(),
+            restored_staging_id=restored.staging_id if restored else '',
+            namespace=snippet.namespace,
+        ))
+
+    def _append(self, record: PromotionRecord) -> PromotionRecord:
+        with self._lock:
+            lineage = self._lineages.setdefault(
+                (record.namespace, record.slot, record.label), [])
+            lineage.append(record)
+            # Trim the oldest entries once more than `depth` promotions are held
+            while sum(1 for r in lineage if r.event == 'promote') > self._depth:
+                lineage.pop(0)
+        return record
+
+    def records(self, slot: str, label: Optional[str] = None,
+                namespace: Optional[str] = None) -> List[PromotionRecord]:
+        """
+        Chronological records for a slot, optionally narrowed to one label
+        and / or one namespace (None = every namespace).
+        """
+        with self._lock:
+            merged = [r for (ns, s, lb), recs in self._lineages.items()
+                      if s == slot
+                      and (label is None or lb == label)
+                      and (namespace is None or ns == namespace)
+                      for r in recs]
+        return sorted(merged, key=lambda r: r.timestamp)
+
+    def rename_ids(self, mapping: Dict[str, str], dry_run: bool = False) -> int:
+        """Rewrite staging IDs in every lineage (see snippet_migrate); returns the count."""
+        changed = 0
+        with self._lock:
+            for lineage in self._lineages.values():
+                for record in lineage:
+                    for attr in ('staging_id', 'restored_staging_id'):
+                        sid = getattr(record, attr)
+                        if sid in mapping:
+                            changed += 1
+                            if not dry_run:
+                                setattr(record, attr, mapping[sid])
+        return changed
+
+    def prior_promotion(self, snippet,
+                        is_candidate: Callable[[str], bool]) -> Optional[str]:
+        """
+        Find the version that was promoted immediately before `snippet`.
+
+        Walks the lineage backwards from the snippet's promotion record and
+        returns the first earlier staging_id for which `is_candidate`
+        returns True (the pipeline uses it to skip versions that were
+        themselves rolled back).  Returns None if there is no such version.
+        """
+        with self._lock:
+            lineage = list(self._lineages.get(
+                (snippet.namespace, snippet.engine_letter, snippet.label), []))
+
+        promotes = [r for r in lineage if r.event == 'promote']
+        idx = next((i for i in range(len(promotes) - 1, -1, -1)
+                    if promotes[i].staging_id == snippet.staging_id), None)
+        if idx is None:
+            return None
+        for record in reversed(promotes[:idx]):
+            if record.staging_id != snippet.staging_id and is_candidate(record.staging_id):
+                return record.staging_id
+        return None
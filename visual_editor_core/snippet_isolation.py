@@ -0,0 +1,234 @@
+"""
+Snippet Isolation — how far a slot's executions are kept apart from the
+host and from each other.
+
+    configure_slot('a', SlotConfig(isolation=IsolationLevel.NAMESPACE))
+
+A SlotConfig's isolation is one of
+
+    NONE       the executor as it is: Python runs in the live REPL's
+               namespace (production) or a fresh in-process one
+               (speculation); subprocess languages in their own child
+    PROCESS    every execution in a child process of its own — Python
+               too, so it can't see or change the REPL (or the host
+               process) and can be killed on a deadline or forced swap
+    NAMESPACE  PROCESS, with the child in new Linux user, mount and PID
+               namespaces: it runs as root of a user namespace mapped to
+               the host's uid, sees only its own processes in /proc, and
+               its mounts stay private
+
+NAMESPACE re-executes the host's interpreter on this file (the Python
+counterpart of re-exec'ing /proc/self/exe): the helper unshares the
+namespaces, writes the uid/gid maps, forks the PID 1 of the new
+namespace, mounts a fresh /proc there and execs the snippet's command,
+relaying its exit status (or the signal that killed it).  Killing the
+process group still reaches the snippet: the helper turns SIGTERM into
+SIGKILL for its PID 1, which ignores signals it has no handler for.
+
+The strategy is chosen when the slot is configured (configure_slot(),
+or slot_configs at start-up) with select_strategy(): NAMESPACE on a host
+that can't create them — not Linux, or unprivileged user namespaces
+disabled — is downgraded to PROCESS with a logged warning.  Executors
+with `isolatable` (Go, and Python's in a child process) honour the
+level for the snippet's own process; the compile step runs outside it.
+Other subprocess executors (JavaScript, Rust, …) already give each
+execution its own process and run NAMESPACE slots at PROCESS, with a
+one-time warning per language.
+"""
+
+import ctypes
+import ctypes.util
+import functools
+import logging
+import os
+import signal
+import subprocess
+import sys
+from abc import ABC, abstractmethod
+from enum import Enum
+from typing import Dict, List, Optional, Set, Tuple
+
+
+logger = logging.getLogger(__name__)
+
+CLONE_NEWNS = 0x00020000
+CLONE_NEWUSER = 0x10000000
+CLONE_NEWPID = 0x20000000
+MS_NOSUID, MS_NODEV, MS_NOEXEC = 2, 4, 8
+MS_REC, MS_PRIVATE = 0x4000, 1 << 18
+
+HELPER_FAILED = 125                      # Exit status when the namespaces can't be set up
+PROBE_TIMEOUT = 10.0
+
+
+class IsolationLevel(str, Enum):
+    NONE      = 'none'                   # The executor as it is
+    PROCESS   = 'process'                # A separate process per execution
+    NAMESPACE = 'namespace'              # PROCESS in new user/mount/PID namespaces (Linux)
+
+
+class IsolationStrategy(ABC):
+    """How a slot's snippet process is started."""
+
+    level: IsolationLevel = IsolationLevel.NONE
+
+    @abstractmethod
+    def wrap(self, cmd: List[str]) -> List[str]:
+        """The command that runs `cmd` at this level."""
+
+    def describe(self) -> Dict:
+        return {'level': self.level.value, 'class': type(self).__name__}
+
+
+class NoIsolation(IsolationStrategy):
+    level = IsolationLevel.NONE
+
+    def wrap(self, cmd: List[str]) -> List[str]:
+        return list(cmd)
+
+
+class ProcessIsolation(IsolationStrategy):
+    """Each execution is its own child process (already true of subprocess executors)."""
+
+    level = IsolationLevel.PROCESS
+
+    def wrap(self, cmd: List[str]) -> List[str]:
+        return list(cmd)
+
+
+class NamespaceIsolation(IsolationStrategy):
+    """`cmd` under this file's helper, in new user, mount and PID namespaces."""
+
+    level = IsolationLevel.NAMESPACE
+
+    def wrap(self, cmd: List[str]) -> List[str]:
+        return [sys.executable, '-I', os.path.abspath(__file__), '--', *cmd]
+
+
+_STRATEGIES = {IsolationLevel.NONE: NoIsolation, IsolationLevel.PROCESS: ProcessIsolation,
+               IsolationLevel.NAMESPACE: NamespaceIsolation}
+
+
+@functools.lru_cache(maxsize=1)
+def namespace_support() -> Tuple[bool, str]:
+    """(supported, why not): probed once by running the helper on a no-op."""
+    if not sys.platform.startswith('linux'):
+        return False, f'namespaces need Linux (this is {sys.platform})'
+    if _libc() is None:
+        return False, 'libc unshare() is not available'
+    try:
+        proc = subprocess.run(NamespaceIsolation().wrap([sys.executable, '-I', '-c', '']),
+                              capture_output=True, text=True, timeout=PROBE_TIMEOUT)
+    except (OSError, subprocess.TimeoutExpired) as exc:
+        return False, f'namespace probe failed: {exc}'
+    if proc.returncode != 0:
+        return False, (proc.stderr.strip().splitlines() or ['namespace probe failed'])[-1]
+    return True, ''
+
+
+def select_strategy(level, slot: str = '') -> IsolationStrategy:
+    """The strategy for `level`; NAMESPACE falls back to PROCESS (with a warning) where unsupported."""
+    level = IsolationLevel(level)
+    if level == IsolationLevel.NAMESPACE:
+        supported, reason = namespace_support()
+        if not supported:
+            logger.warning("Slot %s: namespace isolation unavailable (%s); using process isolation",
+                           slot or '?', reason)
+            level = IsolationLevel.PROCESS
+    return _STRATEGIES[level]()
+
+
+_warned_languages: Set[str] = set()
+
+
+def warn_not_isolatable(language: str, strategy: Optional[IsolationStrategy]):
+    """Log, once per language, that its executor runs a NAMESPACE slot at PROCESS."""
+    if strategy is None or strategy.level != IsolationLevel.NAMESPACE:
+        return
+    if language not in _warned_languages:
+        _warned_languages.add(language)
+        logger.warning("The %s executor can't enter namespaces; its NAMESPACE slots run with "
+                       "process isolation", language)
+
+
+# ═══════════════════════════════════════════════════════════════════════════
+# HELPER — runs as `python -I snippet_isolation.py -- <cmd…>`
+# ═══════════════════════════════════════════════════════════════════════════
+
+def _libc():
+    name = ctypes.util.find_library('c')
+    if not name:
+        return None
+    libc = ctypes.CDLL(name, use_errno=True)
+    return libc if hasattr(libc, 'unshare') else None
+
+
+def _check(rc: int, what: str):
+    if rc != 0:
+        errno = ctypes.get_errno()
+        raise OSError(errno, f'{what}: {os.strerror(errno)}')
+
+
+def _write(path: str, text: str):
+    with open(path, 'w') as f:
+        f.write(text)
+
+
+def _enter_namespaces(libc):
+    uid, gid = os.getuid(), os.getgid()
+    _check(libc.unshare(CLONE_NEWUSER | CLONE_NEWNS | CLONE_NEWPID), 'unshare')
+    _write('/proc/self/setgroups', 'deny')
+    _write('/proc/self/uid_map', f'0 {uid} 1')
+    _write('/proc/self/gid_map', f'0 {gid} 1')
+
+
+def _mount_proc(libc):
+    """In the new PID namespace's init: keep mounts private, then a /proc of its own."""
+    _check(libc.mount(None, b'/', None, MS_REC | MS_PRIVATE, None), 'mount --make-rprivate /')
+    _check(libc.mount(b'proc', b'/proc', b'proc', MS_NOSUID | MS_NODEV | MS_NOEXEC, None),
+           'mount /proc')
+
+
+def main(argv: List[str]) -> int:
+    if argv[:1] != ['--'] or len(argv) < 2:
+        print('usage: snippet_isolation.py -- <command> [args…]', file=sys.stderr)
+        return 2
+    cmd = argv[1:]
+    libc = _libc()
+    try:
+        if libc is None:
+            raise OSError('libc unshare() is not available')
+        _enter_namespaces(libc)
+    except OSError as exc:
+        print(f'namespace isolation: {exc}', file=sys.stderr)
+        return HELPER_FAILED
+
+    pid = os.fork()                          # PID 1 of the new namespace
+    if pid == 0:
+        try:
+            _mount_proc(libc)
+            os.execvp(cmd[0], cmd)
+        except OSError as exc:
+            print(f'namespace isolation: {exc}', file=sys.stderr)
+        os._exit(HELPER_FAILED)
+
+    def stop(signum, frame):
+        try:
+            os.kill(pid, signal.SIGKILL)
+        except ProcessLookupError:
+            pass
+    signal.signal(signal.SIGTERM, stop)
+    signal.signal(signal.SIGINT, stop)
+    _, status = os.waitpid(pid, 0)
+    if os.WIFSIGNALED(status):
+        # Die of the same signal, so the caller sees what stopped the snippet
+        signum = os.WTERMSIG(status)
+        if signum != signal.SIGKILL:
+            signal.signal(signum, signal.SIG_DFL)
+        os.kill(os.getpid(), signum)
+        return 128 + signum
+    return os.WEXITSTATUS(status)
+
+
+if __name__ == '__main__':
+    sys.exit(main(sys.argv[1:]))
@@ -0,0 +1,122 @@
+"""
+Snippet Limits — CPU, memory and output caps for a snippet's process.
+
+    limits = ResourceLimits(max_cpu_time=2, max_memory_bytes=1 << 30,
+                            max_output_bytes=1 << 20)
+    GoExecutor(resource_limits=limits)       # or GoEngine(resource_limits=limits)
+
+A limit of 0 is no limit.  They apply to the snippet's own process
+only — a compile step runs under the executor's deadline alone:
+
+  • max_cpu_time — on Linux, setrlimit(RLIMIT_CPU) in the child: the
+    kernel sends SIGXCPU at the limit and SIGKILL a second later.  Other
+    platforms have no usable rlimit, so the child is killed when it has
+    run that long by the wall clock instead (an upper bound on its CPU).
+  • max_memory_bytes — setrlimit(RLIMIT_AS) in the child (Linux only):
+    allocations past it fail, which the runtime reports as out of memory.
+    This is address space, not resident memory: the Go runtime reserves
+    some 700 MiB of it at start-up, so Go limits belong well above that.
+  • max_output_bytes — stdout and stderr are each read through a capped
+    reader; the first byte past the cap kills the process tree and the
+    output is cut at the cap.
+
+A run stopped by any of them fails with spec_result RESOURCE_EXCEEDED,
+and the snippet's `resource_violation` names the limit that was hit
+(VIOLATION_CPU_TIME, VIOLATION_MEMORY or VIOLATION_OUTPUT).  The
+execution deadline is separate: running out of that is still TIMEOUT.
+"""
+
+import math
+import re
+import signal
+import sys
+from dataclasses import dataclass, asdict
+from typing import Callable, Dict, Optional
+
+try:
+    import resource
+except ImportError:                          # Windows
+    resource = None
+
+
+VIOLATION_CPU_TIME = 'cpu_time'
+VIOLATION_MEMORY = 'memory'
+VIOLATION_OUTPUT = 'output'
+
+# setrlimit() is only relied on where the kernel enforces both limits
+RLIMITS_SUPPORTED = resource is not None and sys.platform.startswith('linux')
+
+_OUT_OF_MEMORY = re.compile(r'out of memory|cannot allocate memory|failed to reserve'
+                            r'|MemoryError|std::bad_alloc', re.IGNORECASE)
+
+
+@dataclass
+class ResourceLimits:
+    max_cpu_time: float = 0.0            # CPU seconds
+    max_memory_bytes: int = 0            # Address space
+    max_output_bytes: int = 0            # Per stream (stdout, stderr)
+
+    def __post_init__(self):
+        for name in ('max_cpu_time', 'max_memory_bytes', 'max_output_bytes'):
+            value = getattr(self, name)
+            if isinstance(value, bool) or not isinstance(value, (int, float)) or value < 0:
+                raise ValueError(f"ResourceLimits.{name} must be a number >= 0")
+
+    @property
+    def enabled(self) -> bool:
+        return bool(self.max_cpu_time or self.max_memory_bytes or self.max_output_bytes)
+
+    def describe(self, violation: str) -> str:
+        """Human-readable message for a run stopped by `violation`."""
+        if violation == VIOLATION_CPU_TIME:
+            return f'CPU time limit of {self.max_cpu_time:g}s exceeded'
+        if violation == VIOLATION_MEMORY:
+            return f'memory limit of {self.max_memory_bytes} bytes exceeded'
+        if violation == VIOLATION_OUTPUT:
+            return f'output limit of {self.max_output_bytes} bytes exceeded'
+        return ''
+
+    def to_dict(self) -> Dict:
+        return asdict(self)
+
+
+def rlimit_preexec(limits: ResourceLimits) -> Optional[Callable[[], None]]:
+    """A Popen preexec_fn applying the rlimits in the child, or None if none apply."""
+    if not RLIMITS_SUPPORTED or not (limits.max_cpu_time or limits.max_memory_bytes):
+        return None
+    cpu = math.ceil(limits.max_cpu_time)
+    memory = int(limits.max_memory_bytes)
+
+    def apply():
+        if cpu:
+            resource.setrlimit(resource.RLIMIT_CPU, (cpu, cpu + 1))
+        if memory:
+            resource.setrlimit(resource.RLIMIT_AS, (memory, memory))
+    return apply
+
+
+def cpu_deadline(limits: ResourceLimits, timeout: float) -> Optional[float]:
+    """Wall-clock stand-in for max_cpu_time where RLIMIT_CPU isn't available."""
+    if RLIMITS_SUPPORTED or not limits.max_cpu_time or limits.max_cpu_time >= timeout:
+        return None
+    return limits.max_cpu_time
+
+
+def out_of_memory(limits: ResourceLimits, stderr: str) -> bool:
+    """Whether `stderr` reports an allocation the memory rlimit refused."""
+    return bool(limits.max_memory_bytes and RLIMITS_SUPPORTED
+                and _OUT_OF_MEMORY.search(stderr or ''))
+
+
+def classify_exit(limits: ResourceLimits, returncode: Optional[int], stderr: str) -> str:
+    """The rlimit a finished child ran into, judged by how it exited ('' if none)."""
+    if not returncode:
+        return ''
+    if out_of_memory(limits, stderr):
+        return VIOLATION_MEMORY
+    if limits.max_cpu_time and RLIMITS_SUPPORTED:
+        # SIGXCPU at the soft limit; runtimes that ignore it (Go) get
+        # SIGKILL at the hard limit one second later
+        if returncode in (-signal.SIGXCPU, -signal.SIGKILL) or 'SIGXCPU' in (stderr or ''):
+            return VIOLATION_CPU_TIME
+    return ''
@@ -0,0 +1,212 @@
+"""
+Snippet Lint — static-analysis gate run before a snippet is promoted.
+
+A snippet whose speculative run passed can still carry bugs the run did
+not exercise (a Printf with a missing argument on an untaken branch, a
+discarded error value, a shadowed variable).  The lint gate catches
+those before the code goes live:
+
+    promote(id) ──► linter.lint(code) ──► clean ──► promote as usual
+                                   └──► diagnostics ──► FAILED,
+                                        spec_result: LINT_FAIL,
+                                        lint_diagnostics stored on the snippet
+
+Linters are registered per language.  GoVetLinter drives the
+`golang.org/x/tools/go/analysis` passes through `go vet`:
+
+    printf, unusedresult   built into `go vet` (selected by flag)
+    shadow                 via `go vet -vettool=<shadow>` when the shadow
+                           binary is installed (go install
+                           golang.org/x/tools/go/analysis/passes/shadow/cmd/shadow@latest)
+
+An analyzer that cannot run (no toolchain, no shadow binary) fails the
+gate with an 'analyzer unavailable' diagnostic: a gate that passes without
+checking anything would be worse than none.  A caller that accepts running
+with fewer analyzers says so with GoVetLinter(allow_unavailable=True); the
+ones that could not run are then listed in `LintResult.skipped` (and in the
+gate's audit entry) instead.
+"""
+
+import os
+import re
+import json
+import time
+import shutil
+import tempfile
+from abc import ABC, abstractmethod
+from dataclasses import dataclass, field, asdict
+from typing import Dict, List, Optional, Sequence
+
+
+DEFAULT_GO_ANALYZERS = ('printf', 'shadow', 'unusedresult')
+
+# Analyzers `go vet` runs natively; anything else needs its own vettool
+GO_VET_BUILTIN = {
+    'appends', 'asmdecl', 'assign', 'atomic', 'bools', 'buildtag', 'cgocall',
+    'composites', 'copylocks', 'defers', 'directive', 'errorsas', 'framepointer',
+    'httpresponse', 'ifaceassert', 'loopclosure', 'lostcancel', 'nilfunc',
+    'printf', 'shift', 'sigchanyzer', 'slog', 'stdmethods', 'stringintconv',
+    'structtag', 'testinggoroutine', 'tests', 'timeformat', 'unmarshal',
+    'unreachable', 'unsafeptr', 'unusedresult',
+}
+
+_POSN = re.compile(r':(\d+):(\d+)$')
+
+
+@dataclass
+class LintDiagnostic:
+    """One finding from an analyzer."""
+    analyzer: str
+    line: int
+    column: int
+    message: str
+
+    def to_dict(self) -> Dict:
+        return asdict(self)
+
+
+@dataclass
+class LintResult:
+    """Outcome of linting one snippet."""
+    passed: bool
+    diagnostics: List[LintDiagnostic] = field(default_factory=list)
+    skipped: List[str] = field(default_factory=list)   # Analyzers that could not run
+    duration: float = 0.0
+
+    def to_dict(self) -> Dict:
+        return {
+            'passed': self.passed,
+            'diagnostics': [d.to_dict() for d in self.diagnostics],
+            'skipped': list(self.skipped),
+            'duration': self.duration,
+        }
+
+
+class LintFailedError(ValueError):
+    """Promotion was blocked by lint diagnostics."""
+
+    def __init__(self, message: str, result: LintResult):
+        super().__init__(message)
+        self.result = result
+
+
+class SnippetLinter(ABC):
+    """Static-analysis pass over one snippet's source."""
+
+    @abstractmethod
+    def lint(self, code: str) -> LintResult:
+        """Analyse `code`; never raises for findings (they go in the result)."""
+
+
+class GoVetLinter(SnippetLinter):
+    """Runs `go/analysis` passes against a Go snippet via `go vet -json`."""
+
+    def __init__(self, analyzers: Sequence[str] = DEFAULT_GO_ANALYZERS,
+                 shadow_tool: Optional[str] = None, timeout: float = 60.0,
+                 allow_unavailable: bool = False):
+        self._go_path: Optional[str] = shutil.which('go')
+        self._shadow_path: Optional[str] = shadow_tool or shutil.which('shadow')
+        self._analyzers = list(analyzers)
+        self._timeout = timeout
+        self._allow_unavailable = allow_unavailable
+
+    def lint(self, code: str) -> LintResult:
+        start = time.time()
+        if not self._go_path:
+            return self._unavailable(list(self._analyzers), 'no Go toolchain on PATH', start)
+
+        builtin = [a for a in self._analyzers if a in GO_VET_BUILTIN]
+        extra = [a for a in self._analyzers if a not in GO_VET_BUILTIN]
+        diagnostics: List[LintDiagnostic] = []
+        skipped: List[str] = []
+
+        tmp_dir = tempfile.mkdtemp(prefix='vpyd_lint_')
+        try:
+            src_path = os.path.join(tmp_dir, 'main.go')
+            with open(src_path, 'w', encoding='utf-8') as f:
+                f.write(code)
+
+            if builtin:
+                diagnostics += self._vet([f'-{a}' for a in builtin], tmp_dir)
+            for name in extra:
+                if name == 'shadow' and self._shadow_path:
+                    diagnostics += self._vet([f'-vettool={self._shadow_path}'], tmp_dir)
+                else:
+                    skipped.append(name)
+        finally:
+            shutil.rmtree(tmp_dir, ignore_errors=True)
+
+        if skipped:
+            missing = self._unavailable(skipped, 'its vettool is not installed', start)
+            diagnostics += missing.diagnostics
+            skipped = missing.skipped
+        diagnostics.sort(key=lambda d: (d.line, d.column, d.analyzer))
+        return LintResult(passed=not diagnostics, diagnostics=diagnostics,
+                          skipped=skipped, duration=time.time() - start)
+
+    def _unavailable(self, analyzers: List[str], reason: str, start: float) -> LintResult:
+        """Analyzers that could not run: skipped if allowed, otherwise diagnostics."""
+        if self._allow_unavailable:
+            return LintResult(passed=True, skipped=analyzers, duration=time.time() - start)
+        return LintResult(passed=False, diagnostics=[
+            LintDiagnostic(name, 0, 0, f"analyzer unavailable: {reason}")
+            for name in analyzers], duration=time.time() - start)
+
+    def _vet(self, flags: List[str], tmp_dir: str) -> List[LintDiagnostic]:
+        from .execution_engine import _run_with_deadline
+        proc, timed_out = _run_with_deadline(
+            [self._go_path, 'vet', '-json', *flags, 'main.go'],
+            timeout=self._timeout, cwd=tmp_dir)
+        if timed_out:
+            return [LintDiagnostic('vet', 0, 0, f'go vet timed out after {self._timeout:g}s')]
+        # Older toolchains write the JSON to stderr, newer ones to stdout
+        return parse_vet_json(f"{proc.stdout or ''}\n{proc.stderr or ''}", proc.returncode)
+
+
+def parse_vet_json(output: str, returncode: int = 0) -> List[LintDiagnostic]:
+    """
+    Parse `go vet -json` output: one JSON object per package,
+    {pkg: {analyzer: [{posn, message}, …]}}, interleaved with `#` lines.
+    Non-JSON errors (parse / type errors) become 'compile' diagnostics.
+    """
+    diagnostics: List[LintDiagnostic] = []
+    decoder = json.JSONDecoder()
+    text = '\n'.join(l for l in output.splitlines() if not l.startswith('#'))
+    idx, consumed_any = 0, False
+    while True:
+        start = text.find('{', idx)
+        if start < 0:
+            break
+        try:
+            obj, idx = decoder.raw_decode(text, start)
+        except json.JSONDecodeError:
+            break
+        consumed_any = True
+        for analyzers in obj.values():
+            for analyzer, findings in analyzers.items():
+                if isinstance(findings, dict):   # {"error": "..."} for a failed pass
+                    diagnostics.append(LintDiagnostic(analyzer, 0, 0,
+                                                      findings.get('error', '')))
+                    continue
+                for finding in findings:
+                    m = _POSN.search(finding.get('posn', ''))
+                    diagnostics.append(LintDiagnostic(
+                        analyzer=analyzer,
+                        line=int(m.group(1)) if m else 0,
+                        column=int(m.group(2)) if m else 0,
+                        message=finding.get('message', ''),
+                    ))
+
+    if returncode != 0 and not consumed_any:
+        for line in text.splitlines():
+            line = line.strip()
+            if not line:
+                continue
+            line = re.sub(r'^vet:\s*', '', line)
+            m = re.match(r'^\S*?:(\d+):(\d+):\s*(.*)$', line)
+            if m:
+                diagnostics.append(LintDiagnostic('compile', int(m.group(1)),
+                                                  int(m.group(2)), m.group(3)))
+            else:
+                diagnostics.append(LintDiagnostic('compile', 0, 0, line))
+    return diagnostics
@@ -0,0 +1,291 @@
+"""
+Snippet Locks — one promotion at a time per slot, across instances.
+
+The pipeline's own lock only serialises threads of one process.  When
+several instances share a snippet store and index, two of them can
+promote onto the same slot at once and both take the same position.
+Every promotion therefore holds the slot's lock from the moment it
+reads the slot's live entries until its registry commit is done, and
+a rollback holds it while it clears the slot and re-installs the prior
+version:
+
+    pipeline = StagingPipeline(..., locker=RedisLocker.from_url('redis://cache:6379/0'),
+                               promotion_lock_ttl=30, promotion_lock_wait=10)
+
+A DistributedLocker hands out leases:
+
+    lock(resource, ttl, wait)  → LockToken   (LockUnavailableError after `wait` seconds)
+    unlock(token)                            (LockLostError if the lease was lost)
+
+A lease ends by itself `ttl` seconds after it was taken, so an instance
+that dies mid-promotion doesn't hold its slot forever; the ttl must be
+longer than a promotion takes.  Each token carries a random value only
+its holder knows, and unlock() releases the lease only while that value
+is still the one stored — never a lease someone else took after ours
+expired.
+
+    LocalLocker   in-process leases (threading.Condition) — one instance
+    RedisLocker   SET key value NX PX ttl; unlock by a compare-and-delete
+                  script (redis-py client)
+    EtcdLocker    reference implementation on etcd leases (python-etcd3
+                  client.lock(), the counterpart of clientv3/concurrency)
+
+redis and etcd3 are optional: without them from_url() raises
+RuntimeError.
+"""
+
+import secrets
+import threading
+import time
+from abc import ABC, abstractmethod
+from dataclasses import dataclass, field
+from typing import Any, Callable, Dict, Optional
+
+try:
+    import redis
+except ImportError:  # redis-py not installed — RedisLocker.from_url() unavailable
+    redis = None
+
+try:
+    import etcd3
+except ImportError:  # python-etcd3 not installed — EtcdLocker.from_url() unavailable
+    etcd3 = None
+
+
+DEFAULT_LOCK_TTL = 30.0                  # Seconds a promotion lease lasts
+DEFAULT_LOCK_WAIT = 10.0                 # Seconds a promotion waits for its slot
+DEFAULT_RETRY_INTERVAL = 0.05            # Seconds between attempts on a remote backend
+
+# Deletes the key only while it still holds our value
+_REDIS_UNLOCK = """
+if redis.call('get', KEYS[1]) == ARGV[1] then
+    return redis.call('del', KEYS[1])
+end
+return 0
+"""
+
+
+class LockError(ValueError):
+    """A lock could not be taken or released."""
+
+
+class LockUnavailableError(LockError):
+    """Someone else held the resource for the whole wait."""
+
+    def __init__(self, message: str, resource: str):
+        super().__init__(message)
+        self.resource = resource
+
+
+class LockLostError(LockError):
+    """The lease expired (and may have been taken over) before unlock()."""
+
+
+def slot_lock_resource(engine_letter: str) -> str:
+    """The lock resource of a slot's promotions."""
+    return f"spokedpy/slot/{engine_letter}"
+
+
+@dataclass
+class LockToken:
+    """A held lease on `resource`; `value` proves who holds it."""
+    resource: str
+    value: str
+    ttl: float
+    acquired_at: float
+    handle: Any = field(default=None, repr=False, compare=False)   # Backend's own lock object
+
+    @property
+    def expires_at(self) -> float:
+        return self.acquired_at + self.ttl
+
+    def to_dict(self) -> Dict:
+        return {
+            'resource': self.resource,
+            'ttl': self.ttl,
+            'acquired_at': self.acquired_at,
+            'expires_at': self.expires_at,
+        }
+
+
+class DistributedLocker(ABC):
+    """Leases on named resources, shared by every instance using the backend."""
+
+    @abstractmethod
+    def lock(self, resource: str, ttl: float = DEFAULT_LOCK_TTL,
+             wait: float = DEFAULT_LOCK_WAIT) -> LockToken:
+        """
+        Take a `ttl`-second lease on `resource`, retrying for up to `wait`
+        seconds (0 = one attempt).  Raises LockUnavailableError if it
+        stays held.
+        """
+
+    @abstractmethod
+    def unlock(self, token: LockToken):
+        """Release `token`'s lease.  Raises LockLostError if it is no longer held."""
+
+    def describe(self) -> Dict:
+        return {'backend': type(self).__name__}
+
+
+def _check(ttl: float, wait: float):
+    if ttl <= 0:
+        raise ValueError("Lock ttl must be > 0")
+    if wait < 0:
+        raise ValueError("Lock wait must be >= 0")
+
+
+def _poll(attempt: Callable[[], Optional[LockToken]], resource: str, wait: float,
+          interval: float) -> LockToken:
+    """Call `attempt` until it returns a token or `wait` seconds have passed."""
+    deadline = time.monotonic() + wait
+    while True:
+        token = attempt()
+        if token is not None:
+            return token
+        remaining = deadline - time.monotonic()
+        if remaining <= 0:
+            raise LockUnavailableError(
+                f"'{resource}' is locked by another promotion (waited {wait:g}s)", resource)
+        time.sleep(min(interval, remaining))
+
+
+class LocalLocker(DistributedLocker):
+    """Leases held in this process — enough for a single instance."""
+
+    def __init__(self, clock: Callable[[], float] = time.time):
+        self._clock = clock
+        self._cond = threading.Condition()
+        self._held: Dict[str, LockToken] = {}
+
+    def _holder(self, resource: str) -> Optional[LockToken]:
+        token = self._held.get(resource)
+        if token is not None and self._clock() >= token.expires_at:
+            del self._held[resource]
+            return None
+        return token
+
+    def lock(self, resource: str, ttl: float = DEFAULT_LOCK_TTL,
+             wait: float = DEFAULT_LOCK_WAIT) -> LockToken:
+        _check(ttl, wait)
+        deadline = time.monotonic() + wait
+        with self._cond:
+            while True:
+                holder = self._holder(resource)
+                if holder is None:
+                    token = LockToken(resource, secrets.token_hex(16), ttl, self._clock())
+                    self._held[resource] = token
+                    return token
+                remaining = deadline - time.monotonic()
+                if remaining <= 0:
+                    raise LockUnavailableError(
+                        f"'{resource}' is locked by another promotion (waited {wait:g}s)",
+                        resource)
+                # Wake for an unlock, or when the holder's lease runs out
+                self._cond.wait(min(remaining, max(holder.expires_at - self._clock(), 0.001)))
+
+    def unlock(self, token: LockToken):
+        with self._cond:
+            holder = self._holder(token.resource)
+            if holder is None or holder.value != token.value:
+                raise LockLostError(f"The lease on '{token.resource}' expired before unlock")
+            del self._held[token.resource]
+            self._cond.notify_all()
+
+    def held(self) -> Dict[str, LockToken]:
+        """Unexpired leases by resource."""
+        with self._cond:
+            tokens = {r: self._holder(r) for r in list(self._held)}
+            return {r: t for r, t in tokens.items() if t is not None}
+
+
+class RedisLocker(DistributedLocker):
+    """
+    Leases as Redis keys: SET key value NX PX ttl takes one, a
+    compare-and-delete script releases it.  `client` is a redis-py
+    client (anything with set(name, value, nx=, px=) and eval()).
+    """
+
+    def __init__(self, client, prefix: str = 'spokedpy:lock:',
+                 retry_interval: float = DEFAULT_RETRY_INTERVAL):
+        self._client = client
+        self.prefix = prefix
+        self.retry_interval = retry_interval
+
+    @classmethod
+    def from_url(cls, url: str, **kwargs) -> 'RedisLocker':
+        if redis is None:
+            raise RuntimeError("redis is not installed — pip install redis to use RedisLocker")
+        return cls(redis.Redis.from_url(url), **kwargs)
+
+    def lock(self, resource: str, ttl: float = DEFAULT_LOCK_TTL,
+             wait: float = DEFAULT_LOCK_WAIT) -> LockToken:
+        _check(ttl, wait)
+        key = self.prefix + resource
+
+        def attempt():
+            value = secrets.token_hex(16)
+            if self._client.set(key, value, nx=True, px=max(int(ttl * 1000), 1)):
+                return LockToken(resource, value, ttl, time.time())
+            return None
+
+        return _poll(attempt, resource, wait, self.retry_interval)
+
+    def unlock(self, token: LockToken):
+        if not self._client.eval(_REDIS_UNLOCK, 1, self.prefix + token.resource, token.value):
+            raise LockLostError(f"The lease on '{token.resource}' expired before unlock")
+
+    def describe(self) -> Dict:
+        return {'backend': type(self).__name__, 'prefix': self.prefix}
+
+
+class EtcdLocker(DistributedLocker):
+    """
+    Reference implementation on etcd: python-etcd3's client.lock() puts
+    the lock key under a lease of `ttl` seconds, like a
+    clientv3/concurrency Mutex on a Session with that TTL.
+    """
+
+    def __init__(self, client, prefix: str = '/spokedpy/locks/'):
+        self._client = client
+        self.prefix = prefix
+
+    @classmethod
+    def from_url(cls, url: str, **kwargs) -> 'EtcdLocker':
+        """`url` is etcd://host:port."""
+        if etcd3 is None:
+            raise RuntimeError("etcd3 is not installed — pip install etcd3 to use EtcdLocker")
+        host, _, port = url.split('://', 1)[-1].partition(':')
+        return cls(etcd3.client(host=host or 'localhost', port=int(port or 2379)), **kwargs)
+
+    def lock(self, resource: str, ttl: float = DEFAULT_LOCK_TTL,
+             wait: float = DEFAULT_LOCK_WAIT) -> LockToken:
+        _check(ttl, wait)
+        # etcd leases are whole seconds
+        handle = self._client.lock(self.prefix + resource, ttl=max(int(ttl + 0.999), 1))
+        if not handle.acquire(timeout=wait):
+            raise LockUnavailableError(
+                f"'{resource}' is locked by another promotion (waited {wait:g}s)", resource)
+        return LockToken(resource, handle.uuid.decode('ascii') if isinstance(handle.uuid, bytes)
+                         else str(handle.uuid), ttl, time.time(), handle=handle)
+
+    def unlock(self, token: LockToken):
+        if token.handle is None or not token.handle.release():
+            raise LockLostError(f"The lease on '{token.resource}' expired before unlock")
+
+    def describe(self) -> Dict:
+        return {'backend': type(self).__name__, 'prefix': self.prefix}
+
+
+def locker_from_settings(backend: str, url: str = '') -> DistributedLocker:
+    """The locker of the lock_backend / lock_url settings."""
+    backend = (backend or 'local').strip().lower()
+    if backend == 'local':
+        return LocalLocker()
+    if not url:
+        raise ValueError(f"lock_backend '{backend}' needs a lock_url")
+    if backend == 'redis':
+        return RedisLocker.from_url(url)
+    if backend == 'etcd':
+        return EtcdLocker.from_url(url)
+    raise ValueError(f"Unknown lock_backend '{backend}' (expected local, redis or etcd)")
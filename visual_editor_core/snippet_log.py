@@ -0,0 +1,129 @@
+"""
+Snippet Logging — structured log records from the staging pipeline.
+
+Every audit event the pipeline writes is also logged, with the snippet's
+identity attached as record attributes an operator can filter or format
+on:
+
+    staging_id     stg-6ecfd6d20bfe
+    slot           i                      engine letter
+    label          fib
+    spec_result    PASS | FAIL | …        when the event has one
+    duration_ms    412.0                  run or step time, when known
+
+All five are set on every record ('' / None when they don't apply), so a
+format string may name them:
+
+    logging.basicConfig(format='%(levelname)s %(staging_id)s %(slot)s %(message)s')
+
+and the message itself ends in the same key=value pairs for plain text
+sinks.  Levels:
+
+    DEBUG    pipeline steps (slot reserved, file written, registry commit),
+             cache hits, engine runs, store writes
+    INFO     lifecycle: queued, passed, promoted, rolled back, superseded…
+    WARNING  gates refusing a snippet: lint failures, benchmark regressions,
+             schema / coverage / mutation / test case failures, rejections,
+             circuit breakers opening
+    ERROR    execution failures, timeouts, resource limit kills, and
+             exceptions — with the traceback when logged inside the handler
+
+StagingPipeline(logger=...) routes the records to the caller's logger;
+the default is logging.getLogger('visual_editor_core.snippets'), which
+propagates to the root logger — so a deployment that already configures
+logging sees them without any change, and one that doesn't still gets
+warnings and errors on stderr.  Engines log through their bound
+pipeline's logger, FileSnippetStore through the one it was given.
+"""
+
+import logging
+import sys
+from typing import Any, Dict, Optional
+
+
+DEFAULT_LOGGER_NAME = 'visual_editor_core.snippets'
+
+# Attributes set on every record (see the module docstring)
+ATTRS = ('staging_id', 'slot', 'label', 'spec_result', 'duration_ms')
+
+# spec_result values that mean a gate refused a run that itself succeeded
+_GATE_RESULTS = {'LINT_FAIL', 'SCHEMA_FAIL', 'COVERAGE_FAIL', 'BENCH_REGRESSION',
+                 'WEAK_SPEC', 'CASE_FAIL'}
+
+_DEBUG_EVENTS = {'slot_reserved', 'spec_exec_started', 'lint_passed', 'lint_skipped',
+                 'promotion_started', 'file_written', 'ledger_node_created',
+                 'registry_slot_committed', 'slot_released', 'canary_stepped'}
+_WARNING_EVENTS = {'lint_failed', 'benchmark_regressed', 'verdict_fail', 'rejection',
+                   'canary_rolled_back', 'circuit_opened', 'batch_promotion_aborted',
+                   'approval_rejected', 'ab_test_aborted', 'health_alert'}
+
+# Audit data copied into the message besides ATTRS
+_DETAIL_KEYS = ('step', 'reserved_address', 'address', 'error')
+
+
+def default_logger() -> logging.Logger:
+    return logging.getLogger(DEFAULT_LOGGER_NAME)
+
+
+def log(logger: logging.Logger, level: int, message: str, **attrs: Any):
+    """
+    Log `message` at `level` with `attrs` as record attributes (ATTRS
+    always present) and as key=value pairs after the message.  Inside an
+    except block, ERROR records carry the exception.
+    """
+    if not logger.isEnabledFor(level):
+        return
+    attrs = {k: v for k, v in attrs.items() if v not in (None, '')}
+    extra: Dict[str, Any] = {name: attrs.get(name, '') for name in ATTRS}
+    extra['duration_ms'] = attrs.get('duration_ms')
+    extra['snippet'] = attrs
+    pairs = ' '.join(f'{k}={_format(v)}' for k, v in attrs.items())
+    exc_info = level >= logging.ERROR and sys.exc_info()[0] is not None
+    logger.log(level, '%s %s' if pairs else '%s', message, *([pairs] if pairs else []),
+               extra=extra, exc_info=exc_info)
+
+
+def _format(value: Any) -> str:
+    if isinstance(value, float):
+        return f'{value:.1f}'
+    text = str(value)
+    if not text or any(c.isspace() or c in '"=' for c in text):
+        return '"' + text.replace('\\', '\\\\').replace('"', '\\"').replace('\n', '\\n') + '"'
+    return text
+
+
+def audit_level(event: str, data: Dict[str, Any]) -> int:
+    """The level an audit event is logged at."""
+    if event == 'error':
+        return logging.ERROR
+    if event == 'spec_exec_failed':
+        return logging.WARNING if data.get('spec_result') in _GATE_RESULTS else logging.ERROR
+    if event == 'spec_exec_completed' and data.get('cached'):
+        return logging.DEBUG
+    if event in _DEBUG_EVENTS:
+        return logging.DEBUG
+    if event in _WARNING_EVENTS:
+        return logging.WARNING
+    return logging.INFO
+
+
+def log_audit(logger: logging.Logger, event: str, staging_id: str,
+              data: Dict[str, Any], snippet: Optional[Dict[str, str]] = None):
+    """Log one audit event; `snippet` supplies the slot and label when known."""
+    level = audit_level(event, data)
+    if not logger.isEnabledFor(level):
+        return
+    snippet = snippet or {}
+    spec_result = data.get('spec_result') or ('PASS' if event == 'spec_exec_completed' else '')
+    seconds = data.get('execution_time', data.get('duration'))
+    attrs = {
+        'staging_id': staging_id,
+        'slot': snippet.get('slot', ''),
+        'label': snippet.get('label') or data.get('label', ''),
+        'spec_result': spec_result,
+        'duration_ms': round(seconds * 1000, 1) if isinstance(seconds, (int, float)) else None,
+    }
+    for key in _DETAIL_KEYS:
+        if data.get(key) not in (None, ''):
+            attrs[key] = str(data[key])[:500]
+    log(logger, level, event, **attrs)
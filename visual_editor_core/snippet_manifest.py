@@ -0,0 +1,258 @@
+"""
+Snippet Manifest — declare a set of snippets in one YAML file.
+
+    with open('deploy/pricing.yaml') as f:
+        ids = pipeline.load_manifest(f, base_dir='deploy')
+    with open('pricing.yaml', 'w') as f:
+        pipeline.dump_manifest(SnippetFilter(tags='pricing'), f)
+
+The file names its format and lists the snippets:
+
+    format: spokedpy-manifest/1
+    snippets:
+      - label: fib
+        language: go
+        slot: i                      # Engine letter
+        position: 3                  # Optional: the slot position to reserve
+        source_file: go/fib.go       # Relative to base_dir …
+        parameters:                  # Optional ParameterSpec dicts (Go)
+          - {name: n, type: int, default: 10}
+        tags: [math/fib]
+        schedule: '*/15 * * * *'     # Optional: the slot's rerun schedule
+        resource_limits:             # Optional: this snippet's own ResourceLimits
+          max_cpu_time: 2
+        test_cases:                  # Optional TestCase dicts (snippet_cases)
+          - {name: ten, params: {n: 10}, expected_output: '55'}
+      - label: greet
+        language: python
+        slot: a
+        source: |                    # … or the source inline
+          print("hello")
+
+load_manifest() checks the whole file before anything is staged: the
+format, every entry's fields, that each source file exists and stays
+inside base_dir (without a base_dir sources must be inline), and that
+two entries don't give one slot different schedules.  It then queues
+the entries in declaration order.  If one of them is refused (a taken
+position, a label conflict, a parameter spec that doesn't fit the
+source, a full slot), the entries already queued are rejected again and
+ManifestError names the entry; nothing of the manifest is left staged.
+Schedules are applied to the slots' SlotConfig once every entry has
+been queued.
+
+A manifest only stages — the snippets still go through speculation and
+promotion like any other.  dump_manifest() writes the snippets matching
+a filter back out in the same format with their source inline (without
+merged dependencies), so such a file loads into another pipeline as is.
+
+PyYAML is optional (like prometheus_client): without it the functions
+here raise RuntimeError.
+"""
+
+import os
+from dataclasses import dataclass, field
+from typing import Any, Dict, List, Optional, TextIO
+
+from .snippet_limits import ResourceLimits
+from .snippet_schedule import CronSchedule
+
+try:
+    import yaml
+except ImportError:  # PyYAML not installed — manifests unavailable
+    yaml = None
+
+
+MANIFEST_FORMAT = 'spokedpy-manifest/1'
+
+_ENTRY_KEYS = ('label', 'language', 'slot', 'position', 'source_file', 'source',
+               'parameters', 'tags', 'schedule', 'resource_limits', 'test_cases')
+
+
+class ManifestError(ValueError):
+    """A manifest was refused; `entry` is the 1-based entry at fault (0 = the file)."""
+
+    def __init__(self, message: str, entry: int = 0):
+        super().__init__(message)
+        self.entry = entry
+
+
+@dataclass
+class ManifestEntry:
+    """One snippet of a manifest, with its source resolved."""
+    label: str
+    language: str
+    slot: str                            # Engine letter
+    source: str
+    position: int = 0                    # 0 = the next free position
+    source_file: str = ''                # Where `source` was read from ('' = inline)
+    parameters: List[Dict[str, Any]] = field(default_factory=list)
+    tags: List[str] = field(default_factory=list)
+    schedule: str = ''                   # Cron expression for the slot ('' = leave it)
+    resource_limits: Optional[ResourceLimits] = None
+    test_cases: List[Dict[str, Any]] = field(default_factory=list)
+
+    @classmethod
+    def from_dict(cls, d: Any, base_dir: Optional[str] = None) -> 'ManifestEntry':
+        """Check one entry of the file and read its source; ValueError says what is wrong."""
+        if not isinstance(d, dict):
+            raise ValueError("Entry is not a mapping")
+        unknown = sorted(str(k) for k in d if k not in _ENTRY_KEYS)
+        if unknown:
+            raise ValueError(f"Unknown field(s) {', '.join(unknown)}")
+        for name in ('label', 'language', 'slot'):
+            if not isinstance(d.get(name), str) or not d[name].strip():
+                raise ValueError(f"'{name}' is required")
+        position = d.get('position', 0)
+        if isinstance(position, bool) or not isinstance(position, int) or position < 0:
+            raise ValueError("'position' must be a slot position (an integer >= 1)")
+        has_file, has_inline = 'source_file' in d, 'source' in d
+        if has_file == has_inline:
+            raise ValueError("Give exactly one of 'source_file' or 'source'")
+        if has_inline:
+            if not isinstance(d['source'], str) or not d['source'].strip():
+                raise ValueError("'source' must be non-empty text")
+            source, source_file = d['source'], ''
+        else:
+            source_file = d['source_file']
+            source = _read_source(source_file, base_dir)
+        parameters = d.get('parameters') or []
+        if not isinstance(parameters, list) or not all(isinstance(p, dict) for p in parameters):
+            raise ValueError("'parameters' must be a list of parameter specs")
+        tags = d.get('tags') or []
+        if isinstance(tags, str):
+            tags = [tags]
+        if not isinstance(tags, list) or not all(isinstance(t, str) for t in tags):
+            raise ValueError("'tags' must be a list of strings")
+        schedule = d.get('schedule') or ''
+        if not isinstance(schedule, str):
+            raise ValueError("'schedule' must be a cron expression")
+        schedule = schedule.strip()
+        if schedule:
+            CronSchedule.parse(schedule)
+        limits = d.get('resource_limits')
+        if limits is not None:
+            if not isinstance(limits, dict):
+                raise ValueError("'resource_limits' must be a mapping")
+            try:
+                limits = ResourceLimits(**limits)
+            except TypeError:
+                raise ValueError("'resource_limits' takes max_cpu_time, max_memory_bytes "
+                                 "and max_output_bytes") from None
+        test_cases = d.get('test_cases') or []
+        if not isinstance(test_cases, list) or not all(isinstance(c, dict) for c in test_cases):
+            raise ValueError("'test_cases' must be a list of test cases")
+        return cls(label=d['label'].strip(), language=d['language'].strip().lower(),
+                   slot=d['slot'].strip(), source=source, position=position,
+                   source_file=source_file, parameters=parameters, tags=tags,
+                   schedule=schedule, resource_limits=limits, test_cases=test_cases)
+
+    @classmethod
+    def from_snippet(cls, snippet, schedule: str = '') -> 'ManifestEntry':
+        return cls(label=snippet.label, language=snippet.language, slot=snippet.engine_letter,
+                   source=snippet.code, position=snippet.reserved_position,
+                   parameters=list(snippet.parameters), tags=list(snippet.tags),
+                   schedule=schedule,
+                   resource_limits=(ResourceLimits(**snippet.resource_limits)
+                                    if snippet.resource_limits else None),
+                   test_cases=list(snippet.test_cases))
+
+    def to_dict(self) -> Dict[str, Any]:
+        """The entry as it is written to a file: source inline, optional fields only when set."""
+        d: Dict[str, Any] = {'label': self.label, 'language': self.language, 'slot': self.slot}
+        if self.position:
+            d['position'] = self.position
+        if self.parameters:
+            d['parameters'] = self.parameters
+        if self.tags:
+            d['tags'] = self.tags
+        if self.schedule:
+            d['schedule'] = self.schedule
+        if self.resource_limits is not None and self.resource_limits.enabled:
+            d['resource_limits'] = {k: v for k, v in self.resource_limits.to_dict().items() if v}
+        if self.test_cases:
+            d['test_cases'] = self.test_cases
+        d['source'] = self.source
+        return d
+
+
+def parse_manifest(stream, base_dir: Optional[str] = None) -> List[ManifestEntry]:
+    """
+    Every entry of the manifest in `stream` (text, bytes or a stream of
+    either), checked; ManifestError at the first problem.  source_file
+    paths are read under `base_dir` (None: refused).
+    """
+    _require_yaml()
+    text = stream.read() if hasattr(stream, 'read') else stream
+    if isinstance(text, bytes):
+        text = text.decode('utf-8')
+    try:
+        raw = yaml.safe_load(text)
+    except yaml.YAMLError as exc:
+        raise ManifestError(f"Not valid YAML: {exc}") from None
+    if not isinstance(raw, dict):
+        raise ManifestError("Manifest is not a mapping")
+    if raw.get('format') != MANIFEST_FORMAT:
+        raise ManifestError(f"Unsupported manifest format {raw.get('format')!r} "
+                            f"(expected {MANIFEST_FORMAT!r})")
+    snippets = raw.get('snippets')
+    if not isinstance(snippets, list) or not snippets:
+        raise ManifestError("Manifest lists no snippets")
+
+    entries: List[ManifestEntry] = []
+    schedules: Dict[str, str] = {}
+    for number, item in enumerate(snippets, 1):
+        try:
+            entry = ManifestEntry.from_dict(item, base_dir)
+        except ValueError as exc:
+            raise ManifestError(f"Entry {number}: {exc}", number) from None
+        if entry.schedule:
+            other = schedules.setdefault(entry.slot, entry.schedule)
+            if other != entry.schedule:
+                raise ManifestError(f"Entry {number} ({entry.label}): slot '{entry.slot}' is "
+                                    f"already given the schedule '{other}'", number)
+        entries.append(entry)
+    return entries
+
+
+def write_manifest(entries: List[ManifestEntry], out: TextIO):
+    """Write `entries` to `out` as a manifest, multi-line sources as literal blocks."""
+    _require_yaml()
+    yaml.dump({'format': MANIFEST_FORMAT, 'snippets': [e.to_dict() for e in entries]},
+              out, Dumper=_Dumper, sort_keys=False, allow_unicode=True, default_flow_style=False)
+
+
+def _read_source(path: Any, base_dir: Optional[str]) -> str:
+    if base_dir is None:
+        raise ValueError("source_file needs a base directory; give the source inline")
+    if not isinstance(path, str) or not path.strip():
+        raise ValueError("'source_file' must be a relative path")
+    if os.path.isabs(path):
+        raise ValueError(f"source_file '{path}' must be relative to the manifest")
+    root = os.path.realpath(base_dir)
+    full = os.path.realpath(os.path.join(root, path))
+    if os.path.commonpath([root, full]) != root:
+        raise ValueError(f"source_file '{path}' is outside the manifest's directory")
+    try:
+        with open(full, encoding='utf-8') as f:
+            source = f.read()
+    except OSError as exc:
+        raise ValueError(f"Cannot read source_file '{path}': {exc.strerror}") from None
+    if not source.strip():
+        raise ValueError(f"source_file '{path}' is empty")
+    return source
+
+
+def _require_yaml():
+    if yaml is None:
+        raise RuntimeError("PyYAML is not installed — pip install PyYAML to use manifests")
+
+
+if yaml is not None:
+    class _Dumper(yaml.SafeDumper):
+        """SafeDumper writing multi-line strings (sources) as `|` blocks."""
+
+    def _represent_str(dumper, value):
+        style = '|' if '\n' in value else None
+        return dumper.represent_scalar('tag:yaml.org,2002:str', value, style=style)
+
+    _Dumper.add_representer(str, _represent_str)
@@ -0,0 +1,294 @@
+"""
+Snippet Metrics — Prometheus instrumentation for the staging pipeline.
+
+    snippet_promotions_total{language, slot, spec_result}    counter
+    snippet_rollbacks_total{language, slot}                  counter
+    snippet_replays_total{language, slot, spec_result}       counter
+    snippet_result_cache_lookups_total{language, outcome}    counter
+    snippet_spec_duration_seconds{language}                  histogram
+    snippet_staging_queue_depth                              gauge
+
+The pipeline records into a PipelineMetrics whether or not anything is
+scraping it: the values are plain in-process counters, so an unregistered
+(or prometheus_client-less) install pays a dict update per event and
+nothing else.  Exposing them is opt-in:
+
+    from prometheus_client import REGISTRY
+    register_metrics(REGISTRY)            # default pipeline metrics
+    register_metrics(REGISTRY, metrics)   # a specific instance
+
+prometheus_client is optional (like python-dotenv).  Without it
+register_metrics() raises RuntimeError, but render_text() still produces
+the text exposition format for a /metrics endpoint.
+"""
+
+import bisect
+import threading
+from typing import Callable, Dict, Iterable, List, Optional, Tuple
+
+try:
+    from prometheus_client.core import (
+        CounterMetricFamily, GaugeMetricFamily, HistogramMetricFamily,
+    )
+except ImportError:  # prometheus_client not installed — render_text() only
+    CounterMetricFamily = GaugeMetricFamily = HistogramMetricFamily = None
+
+
+# Speculative runs range from a few ms (Python) to tens of seconds (cold
+# Rust / Kotlin compiles), so the buckets spread wider than the defaults
+SPEC_DURATION_BUCKETS = (0.01, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0, 30.0, 60.0)
+
+PROMOTIONS = 'snippet_promotions_total'
+ROLLBACKS = 'snippet_rollbacks_total'
+REPLAYS = 'snippet_replays_total'
+CACHE_LOOKUPS = 'snippet_result_cache_lookups_total'
+SPEC_DURATION = 'snippet_spec_duration_seconds'
+QUEUE_DEPTH = 'snippet_staging_queue_depth'
+
+
+class _Histogram:
+    """Cumulative-bucket histogram for one label set."""
+
+    def __init__(self, buckets: Tuple[float, ...]):
+        self.buckets = buckets
+        self.counts = [0] * len(buckets)     # Non-cumulative; summed on export
+        self.count = 0
+        self.sum = 0.0
+
+    def observe(self, value: float):
+        idx = bisect.bisect_left(self.buckets, value)
+        if idx < len(self.counts):
+            self.counts[idx] += 1
+        self.count += 1
+        self.sum += value
+
+    def cumulative(self) -> List[Tuple[str, int]]:
+        """[(le, count), …] including the +Inf bucket."""
+        out, running = [], 0
+        for bound, n in zip(self.buckets, self.counts):
+            running += n
+            out.append((_format_bound(bound), running))
+        out.append(('+Inf', self.count))
+        return out
+
+
+def _format_bound(value: float) -> str:
+    return repr(float(value))
+
+
+class PipelineMetrics:
+    """Thread-safe metric values for one StagingPipeline."""
+
+    def __init__(self, buckets: Iterable[float] = SPEC_DURATION_BUCKETS):
+        self._lock = threading.Lock()
+        self._buckets = tuple(sorted(buckets))
+        self._promotions: Dict[Tuple[str, str, str], int] = {}
+        self._rollbacks: Dict[Tuple[str, str], int] = {}
+        self._replays: Dict[Tuple[str, str, str], int] = {}
+        self._cache_lookups: Dict[Tuple[str, str], int] = {}
+        self._spec_durations: Dict[str, _Histogram] = {}
+        self._queue_depth = 0
+        self._queue_depth_fn: Optional[Callable[[], int]] = None
+
+    # ── Recording (called by the pipeline) ───────────────────────────
+
+    def record_promotion(self, language: str, slot: str, spec_result: str):
+        key = (language, slot, spec_result)
+        with self._lock:
+            self._promotions[key] = self._promotions.get(key, 0) + 1
+
+    def record_rollback(self, language: str, slot: str):
+        key = (language, slot)
+        with self._lock:
+            self._rollbacks[key] = self._rollbacks.get(key, 0) + 1
+
+    def record_replay(self, language: str, slot: str, spec_result: str):
+        key = (language, slot, spec_result)
+        with self._lock:
+            self._replays[key] = self._replays.get(key, 0) + 1
+
+    def record_cache_lookup(self, language: str, hit: bool):
+        key = (language, 'hit' if hit else 'miss')
+        with self._lock:
+            self._cache_lookups[key] = self._cache_lookups.get(key, 0) + 1
+
+    def observe_spec_duration(self, language: str, seconds: float):
+        with self._lock:
+            hist = self._spec_durations.get(language)
+            if hist is None:
+                hist = self._spec_durations[language] = _Histogram(self._buckets)
+            hist.observe(max(0.0, seconds))
+
+    def set_queue_depth(self, depth: int):
+        with self._lock:
+            self._queue_depth = depth
+
+    def track_queue_depth(self, fn: Callable[[], int]):
+        """Read the gauge from `fn` at scrape time instead of set_queue_depth()."""
+        with self._lock:
+            self._queue_depth_fn = fn
+
+    # ── Reading ──────────────────────────────────────────────────────
+
+    def queue_depth(self) -> int:
+        with self._lock:
+            fn = self._queue_depth_fn
+            if fn is None:
+                return self._queue_depth
+        return fn()
+
+    def snapshot(self) -> Dict:
+        """Plain-dict copy of every value (for tests and JSON endpoints)."""
+        depth = self.queue_depth()
+        with self._lock:
+            return {
+                PROMOTIONS: [
+                    {'language': l, 'slot': s, 'spec_result': r, 'value': n}
+                    for (l, s, r), n in sorted(self._promotions.items())
+                ],
+                ROLLBACKS: [
+                    {'language': l, 'slot': s, 'value': n}
+                    for (l, s), n in sorted(self._rollbacks.items())
+                ],
+                REPLAYS: [
+                    {'language': l, 'slot': s, 'spec_result': r, 'value': n}
+                    for (l, s, r), n in sorted(self._replays.items())
+                ],
+                CACHE_LOOKUPS: [
+                    {'language': l, 'outcome': o, 'value': n}
+                    for (l, o), n in sorted(self._cache_lookups.items())
+                ],
+                SPEC_DURATION: [
+                    {'language': l, 'count': h.count, 'sum': h.sum,
+                     'buckets': dict(h.cumulative())}
+                    for l, h in sorted(self._spec_durations.items())
+                ],
+                QUEUE_DEPTH: depth,
+            }
+
+    def render_text(self) -> str:
+        """Prometheus text exposition format (version 0.0.4)."""
+        snap = self.snapshot()
+        lines = [
+            f'# HELP {PROMOTIONS} Snippets promoted to production.',
+            f'# TYPE {PROMOTIONS} counter',
+        ]
+        for row in snap[PROMOTIONS]:
+            lines.append(f'{PROMOTIONS}{_labels(row, "language", "slot", "spec_result")} '
+                         f'{row["value"]}')
+        lines += [
+            f'# HELP {ROLLBACKS} Promoted snippets rolled back.',
+            f'# TYPE {ROLLBACKS} counter',
+        ]
+        for row in snap[ROLLBACKS]:
+            lines.append(f'{ROLLBACKS}{_labels(row, "language", "slot")} {row["value"]}')
+        lines += [
+            f'# HELP {REPLAYS} Historical promotions replayed.',
+            f'# TYPE {REPLAYS} counter',
+        ]
+        for row in snap[REPLAYS]:
+            lines.append(f'{REPLAYS}{_labels(row, "language", "slot", "spec_result")} '
+                         f'{row["value"]}')
+        lines += [
+            f'# HELP {CACHE_LOOKUPS} Result cache lookups for deterministic snippets.',
+            f'# TYPE {CACHE_LOOKUPS} counter',
+        ]
+        for row in snap[CACHE_LOOKUPS]:
+            lines.append(f'{CACHE_LOOKUPS}{_labels(row, "language", "outcome")} {row["value"]}')
+        lines += [
+            f'# HELP {SPEC_DURATION} Wall time of speculative executions.',
+            f'# TYPE {SPEC_DURATION} histogram',
+        ]
+        for row in snap[SPEC_DURATION]:
+            lang = _escape(row['language'])
+            for le, n in row['buckets'].items():
+                lines.append(f'{SPEC_DURATION}_bucket{{language="{lang}",le="{le}"}} {n}')
+            lines.append(f'{SPEC_DURATION}_sum{{language="{lang}"}} {row["sum"]}')
+            lines.append(f'{SPEC_DURATION}_count{{language="{lang}"}} {row["count"]}')
+        lines += [
+            f'# HELP {QUEUE_DEPTH} Snippets in the staging pipeline (not yet promoted or rejected).',
+            f'# TYPE {QUEUE_DEPTH} gauge',
+            f'{QUEUE_DEPTH} {snap[QUEUE_DEPTH]}',
+        ]
+        return '\n'.join(lines) + '\n'
+
+
+def _escape(value: str) -> str:
+    return value.replace('\\', '\\\\').replace('"', '\\"').replace('\n', '\\n')
+
+
+def _labels(row: Dict, *names: str) -> str:
+    return '{' + ','.join(f'{n}="{_escape(str(row[n]))}"' for n in names) + '}'
+
+
+# Shared by every pipeline that isn't given its own instance
+DEFAULT_METRICS = PipelineMetrics()
+
+
+class PipelineCollector:
+    """prometheus_client custom collector over a PipelineMetrics."""
+
+    def __init__(self, metrics: PipelineMetrics):
+        self._metrics = metrics
+
+    def describe(self):
+        # Returning families here lets the registry detect name clashes at
+        # register() time without a scrape
+        return self.collect()
+
+    def collect(self):
+        snap = self._metrics.snapshot()
+
+        promotions = CounterMetricFamily(
+            PROMOTIONS, 'Snippets promoted to production.',
+            labels=['language', 'slot', 'spec_result'])
+        for row in snap[PROMOTIONS]:
+            promotions.add_metric([row['language'], row['slot'], row['spec_result']],
+                                  row['value'])
+        yield promotions
+
+        rollbacks = CounterMetricFamily(
+            ROLLBACKS, 'Promoted snippets rolled back.',
+            labels=['language', 'slot'])
+        for row in snap[ROLLBACKS]:
+            rollbacks.add_metric([row['language'], row['slot']], row['value'])
+        yield rollbacks
+
+        replays = CounterMetricFamily(
+            REPLAYS, 'Historical promotions replayed.',
+            labels=['language', 'slot', 'spec_result'])
+        for row in snap[REPLAYS]:
+            replays.add_metric([row['language'], row['slot'], row['spec_result']], row['value'])
+        yield replays
+
+        lookups = CounterMetricFamily(
+            CACHE_LOOKUPS, 'Result cache lookups for deterministic snippets.',
+            labels=['language', 'outcome'])
+        for row in snap[CACHE_LOOKUPS]:
+            lookups.add_metric([row['language'], row['outcome']], row['value'])
+        yield lookups
+
+        durations = HistogramMetricFamily(
+            SPEC_DURATION, 'Wall time of speculative executions.', labels=['language'])
+        for row in snap[SPEC_DURATION]:
+            durations.add_metric([row['language']], list(row['buckets'].items()),
+                                 sum_value=row['sum'])
+        yield durations
+
+        yield GaugeMetricFamily(
+            QUEUE_DEPTH, 'Snippets in the staging pipeline (not yet promoted or rejected).',
+            value=snap[QUEUE_DEPTH])
+
+
+def register_metrics(registry, metrics: Optional[PipelineMetrics] = None) -> PipelineCollector:
+    """
+    Register the pipeline collectors with a prometheus_client registry.
+
+    Safe to skip entirely — the pipeline keeps recording either way.
+    Raises RuntimeError if prometheus_client is not installed.
+    """
+    if CounterMetricFamily is None:
+        raise RuntimeError("prometheus_client is not installed (pip install prometheus-client)")
+    collector = PipelineCollector(metrics if metrics is not None else DEFAULT_METRICS)
+    registry.register(collector)
+    return collector
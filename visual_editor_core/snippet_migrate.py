@@ -0,0 +1,132 @@
+"""
+Snippet ID Migration — move staging IDs from one prefix to another.
+
+Staging IDs are `<prefix><12 hex>`, with the pipeline's staging_id_prefix
+('stg-' by default).  A deployment that partitions IDs by team or
+service renames the existing ones in one step:
+
+    report = admin.migrate_id_prefix('stg-', 'team-alpha/stg-',
+                                     MigrateOptions(dry_run=True))
+    report.mapping                    # {'stg-a270a5243225': 'team-alpha/stg-a270a5243225', …}
+    admin.migrate_id_prefix('stg-', 'team-alpha/stg-')
+
+Every ID starting with from_prefix has that prefix replaced, and every
+reference the pipeline holds is rewritten with it: the records (and the
+index), superseded_ids, rolled_back_to, canary_baseline_id and resolved
+dependencies, approvals, rerun history, the promotion history rollback
+walks, canary rollouts, A/B tests, graceful swaps, output streams, and
+the undelivered and dead-lettered webhook payloads (re-signed).  Each
+renamed record gets an ID_MIGRATED audit entry under its old ID (naming
+the new one) and its new ID (naming the old one); get_audit_trail()
+follows them back, so a record's trail stays whole.  The audit log, the
+session ledger and cold-storage files themselves are append-only and
+keep the IDs they were written with.
+
+The migration is all or nothing.  It is refused (MigrationConflictError,
+nothing changed) when
+
+    a new ID is already taken   by a record that isn't being renamed itself
+                                — unless options.force, which drops that
+                                record (never a live or in-flight one)
+    a record is in flight       speculating, promoting, a running canary
+                                or A/B test, or a pending graceful swap
+
+and if applying it fails part way, the references renamed so far are
+renamed back.  With options.dry_run the plan is checked and the report
+returned without changing anything.
+
+New IDs still use staging_id_prefix: change that setting too if new
+snippets should land under the new prefix.
+"""
+
+import re
+from dataclasses import dataclass, field
+from typing import Dict, Iterable, List
+
+
+DEFAULT_ID_PREFIX = 'stg-'
+
+# Letters, digits, '_', '.', '-' and '/' (namespacing); no empty path segments
+_PREFIX = re.compile(r'^[A-Za-z0-9][A-Za-z0-9_.\-/]{0,62}$')
+
+
+class MigrationConflictError(ValueError):
+    """A prefix migration would overwrite existing IDs or move in-flight snippets."""
+
+    def __init__(self, message: str, report: 'MigrationReport'):
+        super().__init__(message)
+        self.report = report
+
+
+def validate_id_prefix(prefix: str) -> str:
+    """`prefix` stripped; ValueError if it can't start a staging ID."""
+    prefix = (prefix or '').strip()
+    if not _PREFIX.match(prefix) or '//' in prefix or '..' in prefix:
+        raise ValueError(f"Invalid staging ID prefix '{prefix}': use 1-63 of A-Z, a-z, 0-9, "
+                         f"'_', '.', '-', '/' starting with a letter or digit")
+    return prefix
+
+
+@dataclass
+class MigrateOptions:
+    dry_run: bool = False                # Report only; change nothing
+    force: bool = False                  # Drop records whose ID the migration takes over
+
+    @classmethod
+    def from_dict(cls, d: Dict) -> 'MigrateOptions':
+        return cls(dry_run=bool(d.get('dry_run', False)), force=bool(d.get('force', False)))
+
+    def to_dict(self) -> Dict:
+        return {'dry_run': self.dry_run, 'force': self.force}
+
+
+@dataclass
+class MigrationReport:
+    """What a prefix migration renamed (or, for a dry run, would rename)."""
+    from_prefix: str
+    to_prefix: str
+    dry_run: bool
+    mapping: Dict[str, str] = field(default_factory=dict)        # old ID → new ID
+    conflicts: List[str] = field(default_factory=list)           # New IDs already taken
+    overwritten: List[str] = field(default_factory=list)         # Taken IDs dropped (force)
+    in_flight: List[str] = field(default_factory=list)           # Old IDs that block it
+    references: Dict[str, int] = field(default_factory=dict)     # Kind → references rewritten
+    applied: bool = False
+
+    @property
+    def renamed(self) -> int:
+        return len(self.mapping)
+
+    def to_dict(self) -> Dict:
+        return {
+            'from_prefix': self.from_prefix,
+            'to_prefix': self.to_prefix,
+            'dry_run': self.dry_run,
+            'renamed': self.renamed,
+            'mapping': dict(self.mapping),
+            'conflicts': list(self.conflicts),
+            'overwritten': list(self.overwritten),
+            'in_flight': list(self.in_flight),
+            'references': dict(self.references),
+            'applied': self.applied,
+        }
+
+
+def plan_prefix_migration(staging_ids: Iterable[str], from_prefix: str,
+                          to_prefix: str) -> Dict[str, str]:
+    """old ID → new ID for every ID in `staging_ids` that starts with from_prefix."""
+    from_prefix, to_prefix = validate_id_prefix(from_prefix), validate_id_prefix(to_prefix)
+    if from_prefix == to_prefix:
+        raise ValueError("from_prefix and to_prefix are the same")
+    return {sid: to_prefix + sid[len(from_prefix):]
+            for sid in sorted(set(staging_ids)) if sid.startswith(from_prefix)}
+
+
+def rename_list(ids: List[str], mapping: Dict[str, str]) -> int:
+    """Rewrite the IDs in `ids` in place; returns how many changed."""
+    changed = 0
+    for i, sid in enumerate(ids):
+        if sid in mapping:
+            ids[i] = mapping[sid]
+            changed += 1
+    return changed
@@ -0,0 +1,28 @@
+-- Snippet metadata: one row per staged snippet.
+-- Languages and slot positions are normalised into lookup tables.
+
+CREATE TABLE languages (
+    id          INTEGER PRIMARY KEY,
+    name        TEXT    NOT NULL UNIQUE          -- e.g. 'go'
+);
+
+CREATE TABLE slots (
+    id             INTEGER PRIMARY KEY,
+    engine_letter  TEXT    NOT NULL,             -- e.g. 'i'
+    engine         TEXT    NOT NULL DEFAULT '',  -- EngineID name, e.g. 'GO'
+    position       INTEGER NOT NULL,
+    UNIQUE (engine_letter, position)
+);
+
+CREATE TABLE snippets (
+    staging_id    TEXT    PRIMARY KEY,
+    language_id   INTEGER NOT NULL REFERENCES languages(id),
+    slot_id       INTEGER NOT NULL REFERENCES slots(id),
+    label         TEXT    NOT NULL DEFAULT '',
+    code_hash     TEXT    NOT NULL,
+    created_at    REAL    NOT NULL,
+    promoted_at   REAL,                          -- NULL until promoted
+    spec_time_ms  INTEGER,                       -- NULL until speculated
+    spec_result   TEXT    NOT NULL DEFAULT 'FAIL',
+    source_path   TEXT    NOT NULL DEFAULT ''    -- promoted file on disk
+);
@@ -0,0 +1,7 @@
+-- Indexes backing SnippetFilter queries and cursor pagination.
+
+CREATE INDEX idx_snippets_cursor      ON snippets (created_at, staging_id);
+CREATE INDEX idx_snippets_code_hash   ON snippets (code_hash);
+CREATE INDEX idx_snippets_language    ON snippets (language_id);
+CREATE INDEX idx_snippets_slot        ON snippets (slot_id);
+CREATE INDEX idx_snippets_spec_result ON snippets (spec_result);
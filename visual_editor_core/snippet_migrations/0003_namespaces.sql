@@ -0,0 +1,5 @@
+-- Tenant namespace per snippet; rows from before namespaces belong to 'default'.
+
+ALTER TABLE snippets ADD COLUMN namespace TEXT NOT NULL DEFAULT 'default';
+
+CREATE INDEX idx_snippets_namespace ON snippets (namespace, created_at, staging_id);
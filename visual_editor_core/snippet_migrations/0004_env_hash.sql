@@ -0,0 +1,4 @@
+-- Hash of the environment variables a snippet runs with ('' = none), so the
+-- same source staged with different envs is told apart.
+
+ALTER TABLE snippets ADD COLUMN env_hash TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+-- Set when an ArchivalPolicy moves a record to cold storage; archived
+-- rows only match queries that ask for them (include_archived).
+
+ALTER TABLE snippets ADD COLUMN archived_at REAL;
@@ -0,0 +1,10 @@
+-- Hierarchical tags (snippet_tags), one row per snippet and tag.  The tag
+-- index serves exact lookups and the prefix range scans of `prefix/**`.
+
+CREATE TABLE snippet_tags (
+    staging_id  TEXT NOT NULL REFERENCES snippets (staging_id) ON DELETE CASCADE,
+    tag         TEXT NOT NULL,
+    PRIMARY KEY (staging_id, tag)
+);
+
+CREATE INDEX idx_snippet_tags_tag ON snippet_tags (tag);
@@ -0,0 +1,289 @@
+"""
+Snippet Mutation Testing — whether a Go snippet's tests would notice a bug.
+
+Coverage says which statements the tests ran, not whether they check
+what those statements compute.  With a MutationConfig on the executor
+(or GoEngine) — the mutation_test setting —
+
+    GoExecutor(mutation=MutationConfig(max_mutations=20, threshold=0.25))
+
+a Go snippet that declares `func TestXxx(t *testing.T)` functions is,
+once its program has run cleanly, split into main.go and main_test.go
+(snippet_coverage.split_go_tests()).  Its tests are run once as they
+are, then once per mutant: a copy of main.go with one small change
+(find_mutations()):
+
+    negate_bool     true  → false, false → true
+    plus_to_minus   a + b → a - b    (also += → -=)
+    lt_to_le        a < b → a <= b
+    remove_return   a `return …` statement that starts its line is dropped
+
+Strings, runes and comments are never mutated, and the tests are never
+mutated.  A mutant the tests fail on (or time out on) is killed; one
+they still pass is a survivor; one that no longer compiles is stillborn
+and not counted.  At most max_mutations mutants are run, spread evenly
+over the candidates in source order.
+
+The survivors are stored on the snippet as `survived_mutations`, the
+counted mutants as `mutants_tested`.  When more than `threshold` of the
+counted mutants survive, the run fails with spec_result WEAK_SPEC —
+the tests pass, but would also pass a broken snippet.  Snippets without
+tests are not mutation tested; tests that fail as written fail the run
+as FAIL.
+"""
+
+import os
+import re
+import shutil
+import tempfile
+import threading
+from dataclasses import dataclass, asdict, field
+from enum import Enum
+from typing import Dict, List, Optional
+
+from .snippet_coverage import split_go_tests
+
+
+DEFAULT_MAX_MUTATIONS = 20
+DEFAULT_MUTATION_THRESHOLD = 0.25        # Survivors / counted mutants allowed
+DEFAULT_MUTANT_TIMEOUT = 60.0            # Seconds `go test` may take per mutant
+
+NEGATE_BOOL = 'negate_bool'
+PLUS_TO_MINUS = 'plus_to_minus'
+LT_TO_LE = 'lt_to_le'
+REMOVE_RETURN = 'remove_return'
+
+_IDENT = re.compile(r'[A-Za-z_0-9]')
+_BUILD_FAILED = ('[build failed]', '[setup failed]')
+
+
+@dataclass
+class MutationConfig:
+    """How a Go snippet's tests are held to mutants of its source."""
+    max_mutations: int = DEFAULT_MAX_MUTATIONS
+    threshold: float = DEFAULT_MUTATION_THRESHOLD
+    timeout: float = DEFAULT_MUTANT_TIMEOUT
+
+    def __post_init__(self):
+        if isinstance(self.max_mutations, bool) or self.max_mutations < 1:
+            raise ValueError("max_mutations must be >= 1")
+        if isinstance(self.threshold, bool) or not 0 <= self.threshold <= 1:
+            raise ValueError("Mutation threshold must be between 0 and 1")
+        if self.timeout <= 0:
+            raise ValueError("Mutant timeout must be > 0")
+
+    def describe(self, report: 'MutationReport') -> str:
+        """The WEAK_SPEC message for `report` ('' if it passes)."""
+        if not report.counted or report.survival_ratio <= self.threshold:
+            return ''
+        shown = '; '.join(m.describe() for m in report.survived[:5])
+        more = f" (+{len(report.survived) - 5} more)" if len(report.survived) > 5 else ''
+        return (f"{len(report.survived)} of {report.counted} mutants survived the tests "
+                f"({100 * report.survival_ratio:.0f}%, over the {100 * self.threshold:g}% "
+                f"threshold): {shown}{more}")
+
+    def to_dict(self) -> Dict:
+        return asdict(self)
+
+
+@dataclass
+class Mutation:
+    """One change to a snippet's main.go."""
+    operator: str                        # NEGATE_BOOL | PLUS_TO_MINUS | LT_TO_LE | REMOVE_RETURN
+    line: int                            # 1-based, in main.go
+    original: str
+    replacement: str
+    offset: int = 0                      # Character offset of `original` in main.go
+
+    def apply(self, source: str) -> str:
+        return source[:self.offset] + self.replacement + source[self.offset + len(self.original):]
+
+    def describe(self) -> str:
+        if self.operator == REMOVE_RETURN:
+            return f"line {self.line}: removed `{self.original}`"
+        return f"line {self.line}: `{self.original}` → `{self.replacement}`"
+
+    def to_dict(self) -> Dict:
+        return asdict(self)
+
+
+class MutantOutcome(str, Enum):
+    KILLED = 'killed'                    # The tests failed (or timed out)
+    SURVIVED = 'survived'                # The tests still passed
+    STILLBORN = 'stillborn'              # The mutant didn't compile; not counted
+
+
+@dataclass
+class MutationReport:
+    """Outcome of running a snippet's tests against its mutants."""
+    tests_passed: bool                   # The unmutated tests passed
+    outcomes: List[tuple] = field(default_factory=list)   # (Mutation, MutantOutcome)
+    error: str = ''
+    timed_out: bool = False
+
+    def _with(self, outcome: MutantOutcome) -> List[Mutation]:
+        return [m for m, o in self.outcomes if o == outcome]
+
+    @property
+    def survived(self) -> List[Mutation]:
+        return self._with(MutantOutcome.SURVIVED)
+
+    @property
+    def killed(self) -> List[Mutation]:
+        return self._with(MutantOutcome.KILLED)
+
+    @property
+    def counted(self) -> int:
+        """Mutants that compiled."""
+        return len(self.survived) + len(self.killed)
+
+    @property
+    def survival_ratio(self) -> float:
+        return len(self.survived) / self.counted if self.counted else 0.0
+
+
+def find_mutations(source: str) -> List[Mutation]:
+    """Every candidate mutation of `source`, in source order."""
+    mutations = []
+    line, i, n = 1, 0, len(source)
+    line_start = True                    # Only whitespace so far on this line
+    while i < n:
+        ch = source[i]
+        if ch == '\n':
+            line, line_start = line + 1, True
+            i += 1
+            continue
+        if ch in ' \t\r':
+            i += 1
+            continue
+        if source.startswith('//', i):
+            i = source.find('\n', i)
+            i = n if i < 0 else i
+            continue
+        if source.startswith('/*', i):
+            end = source.find('*/', i + 2)
+            end = n if end < 0 else end + 2
+            line += source.count('\n', i, end)
+            i = end
+            line_start = False
+            continue
+        if ch in '"\'`':
+            end = _literal_end(source, i)
+            line += source.count('\n', i, end)
+            i = end
+            line_start = False
+            continue
+        starts_line, line_start = line_start, False
+        if _IDENT.match(ch):
+            end = i
+            while end < n and _IDENT.match(source[end]):
+                end += 1
+            word = source[i:end]
+            if word in ('true', 'false') and (i == 0 or source[i - 1] != '.'):
+                mutations.append(Mutation(NEGATE_BOOL, line, word,
+                                          'false' if word == 'true' else 'true', i))
+            elif word == 'return' and starts_line:
+                statement = _return_statement(source, i)
+                if statement:
+                    mutations.append(Mutation(REMOVE_RETURN, line, statement, '', i))
+            i = end
+            continue
+        if ch == '+' and source[i + 1:i + 2] != '+' and source[i - 1:i] != '+':
+            mutations.append(Mutation(PLUS_TO_MINUS, line, '+', '-', i))
+        elif ch == '<' and source[i + 1:i + 2] not in ('<', '-', '=') and source[i - 1:i] != '<':
+            mutations.append(Mutation(LT_TO_LE, line, '<', '<=', i))
+        i += 1
+    return mutations
+
+
+def select_mutations(mutations: List[Mutation], limit: int) -> List[Mutation]:
+    """At most `limit` of `mutations`, spread evenly over them."""
+    if len(mutations) <= limit:
+        return list(mutations)
+    return [mutations[k * len(mutations) // limit] for k in range(limit)]
+
+
+def run_go_mutations(go_path: str, code: str, config: MutationConfig,
+                     env: Optional[Dict[str, str]] = None, kill_grace: float = 2.0,
+                     cancel_event: Optional[threading.Event] = None) -> MutationReport:
+    """Split `code`, then run its tests unmutated and against each selected mutant."""
+    from .execution_engine import _run_with_deadline
+    main_src, test_src = split_go_tests(code)
+    tmp_dir = tempfile.mkdtemp(prefix='vpyd_mutate_')
+    try:
+        with open(os.path.join(tmp_dir, 'main_test.go'), 'w', encoding='utf-8') as f:
+            f.write(test_src)
+
+        def go_test(source: str):
+            with open(os.path.join(tmp_dir, 'main.go'), 'w', encoding='utf-8') as f:
+                f.write(source)
+            return _run_with_deadline(
+                [go_path, 'test', '-count=1', '-vet=off', '-run=.', 'main.go', 'main_test.go'],
+                timeout=config.timeout, kill_grace=kill_grace, cwd=tmp_dir,
+                cancel_event=cancel_event,
+                env={**os.environ, **env} if env else None)
+
+        proc, timed_out = go_test(main_src)
+        output = f"{proc.stdout or ''}{proc.stderr or ''}".strip()
+        if timed_out:
+            return MutationReport(False, timed_out=True,
+                                  error=f"go test timed out after {config.timeout:g}s")
+        if proc.returncode != 0:
+            return MutationReport(False, error=f"Snippet tests failed:\n{output}")
+
+        report = MutationReport(True)
+        for mutation in select_mutations(find_mutations(main_src), config.max_mutations):
+            if cancel_event is not None and cancel_event.is_set():
+                break
+            proc, timed_out = go_test(mutation.apply(main_src))
+            output = f"{proc.stdout or ''}{proc.stderr or ''}"
+            if timed_out:
+                outcome = MutantOutcome.KILLED
+            elif proc.returncode == 0:
+                outcome = MutantOutcome.SURVIVED
+            elif any(marker in output for marker in _BUILD_FAILED):
+                outcome = MutantOutcome.STILLBORN
+            else:
+                outcome = MutantOutcome.KILLED
+            report.outcomes.append((mutation, outcome))
+        return report
+    finally:
+        shutil.rmtree(tmp_dir, ignore_errors=True)
+
+
+def _literal_end(source: str, start: int) -> int:
+    """Index just past the string, raw string or rune literal at `start`."""
+    quote, i = source[start], start + 1
+    while i < len(source):
+        ch = source[i]
+        if ch == '\\' and quote != '`':
+            i += 2
+            continue
+        if ch == quote or (ch == '\n' and quote != '`'):
+            return i + 1
+        i += 1
+    return len(source)
+
+
+def _return_statement(source: str, start: int) -> str:
+    """The `return …` at `start` if it ends on its own line, else ''."""
+    end = source.find('\n', start)
+    end = len(source) if end < 0 else end
+    depth, i = 0, start
+    while i < end:
+        ch = source[i]
+        if ch in '"\'`':
+            i = _literal_end(source, i)
+            if i > end:
+                return ''                # A raw string running past the line
+            continue
+        if source.startswith('//', i) or source.startswith('/*', i):
+            break
+        depth += ch in '([{'
+        depth -= ch in ')]}'
+        if depth < 0:
+            return ''                    # `return x }` closing a one-line block
+        i += 1
+    if depth != 0:
+        return ''
+    return source[start:i].rstrip().rstrip(';').rstrip()
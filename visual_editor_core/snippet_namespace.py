@@ -0,0 +1,140 @@
+"""
+Snippet Namespaces — keep teams sharing one pipeline out of each other's snippets.
+
+Every snippet belongs to one namespace, fixed when it is queued:
+
+    pipeline.queue_snippet('i', 'go', code, 'svc', namespace='payments')
+
+The pipeline itself enforces the boundary.  Its lookups (get_snippet,
+get_source, query, the history views) and actions (speculate, promote,
+rollback, verdict, …) take a `namespace`; a snippet from any other
+namespace is treated exactly like one that does not exist, so guessing a
+staging_id or code_hash reveals nothing.  Labels are per namespace too:
+two teams can both run a `svc` label on slot `i` without conflicting.
+
+`namespace=None` means unscoped.  That is what in-process components
+holding a snippet already (the speculation queue, the canary controller)
+pass; everything serving a remote caller passes that caller's namespace.
+Operators get unscoped access through NamespaceAdmin, which is only
+issued for the pipeline's admin credential:
+
+    admin = NamespaceAdmin(pipeline, credential)     # NamespaceAdminError if wrong
+    admin.namespaces()                                # {'default': 12, 'payments': 3}
+    admin.get_snippet(staging_id)                     # any namespace
+
+Remote callers only name a namespace; they must also prove they belong
+to it.  The pipeline holds one credential per namespace (stored hashed,
+like the admin credential):
+
+    StagingPipeline(..., namespace_credentials={'payments': 'tok-p'})
+    pipeline.check_namespace_credential('payments', 'tok-p')    # True
+
+A namespace with a credential needs it; 'default' is open while it has
+none; any other namespace is closed until one is configured.
+"""
+
+import re
+import hmac
+import hashlib
+from typing import Dict, List, Optional
+
+from .snippet_query import SnippetFilter, QueryPage
+
+
+DEFAULT_NAMESPACE = 'default'
+
+_NAME = re.compile(r'^[a-z0-9][a-z0-9_.-]{0,62}$')
+
+
+class NamespaceAdminError(PermissionError):
+    """The admin credential was missing, wrong, or admin access is disabled."""
+
+
+class NamespaceAuthError(PermissionError):
+    """The caller named a namespace without that namespace's credential."""
+
+
+def validate_namespace(name: Optional[str]) -> str:
+    """Normalised namespace name ('' / None → DEFAULT_NAMESPACE); ValueError if invalid."""
+    name = (name or '').strip().lower() or DEFAULT_NAMESPACE
+    if not _NAME.match(name):
+        raise ValueError(f"Invalid namespace '{name}': use 1-63 of a-z, 0-9, '_', '.', '-' "
+                         f"starting with a letter or digit")
+    return name
+
+
+def parse_namespace_credentials(spec: str) -> Dict[str, str]:
+    """'payments=tok-p,search=tok-s' → {'payments': 'tok-p', …}; ValueError if malformed."""
+    credentials: Dict[str, str] = {}
+    for pair in (spec or '').split(','):
+        if not pair.strip():
+            continue
+        name, sep, credential = pair.partition('=')
+        if not sep or not name.strip() or not credential.strip():
+            raise ValueError(f"Namespace credential '{pair.strip()}' is not namespace=credential")
+        credentials[validate_namespace(name)] = credential.strip()
+    return credentials
+
+
+def hash_credential(credential: str) -> str:
+    return hashlib.sha256(credential.encode('utf-8')).hexdigest()
+
+
+def check_credential(credential: str, credential_hash: str) -> bool:
+    """Constant-time comparison of `credential` against a hash_credential() digest."""
+    if not credential or not credential_hash:
+        return False
+    return hmac.compare_digest(hash_credential(credential), credential_hash)
+
+
+class NamespaceAdmin:
+    """
+    Cross-namespace access for operators.
+
+    Constructing one checks `credential` against the pipeline's
+    namespace_admin_credential; every method then runs unscoped.
+    """
+
+    def __init__(self, pipeline, credential: str):
+        if not pipeline.check_admin_credential(credential):
+            raise NamespaceAdminError("Invalid namespace admin credential")
+        self._pipeline = pipeline
+
+    def namespaces(self) -> Dict[str, int]:
+        """Namespace → number of snippets the pipeline holds for it."""
+        return self._pipeline.namespaces()
+
+    def query(self, snippet_filter: SnippetFilter) -> QueryPage:
+        """Search every namespace (set snippet_filter.namespace to pick one)."""
+        return self._pipeline.query(snippet_filter)
+
+    def get_snippet(self, staging_id: str):
+        return self._pipeline.get_snippet(staging_id)
+
+    def get_source(self, code_hash: str) -> str:
+        return self._pipeline.get_source(code_hash)
+
+    def get_active(self, namespace: Optional[str] = None) -> List:
+        return self._pipeline.get_active(namespace=namespace)
+
+    def promote(self, staging_id: str, **kwargs):
+        return self._pipeline.promote(staging_id, **kwargs)
+
+    def rollback(self, staging_id: str, reason: str = ''):
+        return self._pipeline.rollback(staging_id, reason)
+
+    def verdict(self, staging_id: str, action: str = 'auto', reason: str = ''):
+        return self._pipeline.verdict(staging_id, action, reason)
+
+    def retag(self, staging_id: str, tags: List[str]):
+        """Replace a snippet's tags, promoted or not (see snippet_tags)."""
+        return self._pipeline.retag(staging_id, tags)
+
+    def migrate_id_prefix(self, from_prefix: str, to_prefix: str, options=None):
+        """Move staging IDs from one prefix to another (see snippet_migrate)."""
+        return self._pipeline.migrate_id_prefix(from_prefix, to_prefix, options)
+
+    def clear_result_cache(self) -> int:
+        """Drop every cached speculation (see snippet_cache); how many there were."""
+        cache = self._pipeline.result_cache
+        return cache.clear() if cache is not None else 0
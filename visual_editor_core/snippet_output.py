@@ -0,0 +1,68 @@
+"""
+Snippet Output — typed results a snippet writes to file descriptor 3.
+
+stdout is for people; a snippet that wants to hand a value to whatever
+consumes it writes one JSON document to fd 3 instead:
+
+    f := os.NewFile(3, "result")
+    json.NewEncoder(f).Encode(map[string]any{"total": 42, "currency": "EUR"})
+
+Engines with supports_result_fd (Go) give the snippet's process a pipe
+on fd 3 and capture it separately from stdout and stderr (capped like
+them by max_output_bytes).  After a successful run the pipeline parses
+it into the snippet's `spec_output_value`.
+
+Staging with an output schema makes the result mandatory and checked:
+
+    pipeline.queue_snippet('i', 'go', code, 'pricing',
+                           output_schema='{"type": "object", "required": ["total"]}')
+
+The schema is a JSON Schema document (see snippet_schema).  A run whose
+fd 3 is empty, isn't JSON or doesn't conform fails with spec_result
+SCHEMA_FAIL and the failures in `spec_output_errors`.  Without a schema
+nothing fails: an unparseable result just leaves spec_output_value None
+and says why in spec_output_errors.
+"""
+
+import json
+from dataclasses import dataclass, field
+from typing import Any, Dict, List, Optional
+
+from .snippet_schema import ValidationError, validate
+
+
+RESULT_FD = 3
+
+
+@dataclass
+class OutputCheck:
+    """What check_output() made of a run's fd 3."""
+    value: Any = None                    # Parsed result (None if absent or invalid)
+    present: bool = False                # Anything was written to fd 3
+    errors: List[ValidationError] = field(default_factory=list)
+
+    @property
+    def passed(self) -> bool:
+        return not self.errors
+
+    def describe(self) -> str:
+        return '; '.join(str(e) for e in self.errors)
+
+    def error_dicts(self) -> List[Dict[str, str]]:
+        return [e.to_dict() for e in self.errors]
+
+
+def check_output(raw: str, schema: Optional[Dict[str, Any]] = None) -> OutputCheck:
+    """Parse the text a run wrote to fd 3 and, with `schema`, validate it."""
+    if not (raw or '').strip():
+        if schema is None:
+            return OutputCheck()
+        return OutputCheck(errors=[ValidationError('', f'no result was written to fd {RESULT_FD}')])
+    try:
+        value = json.loads(raw)
+    except ValueError as exc:
+        return OutputCheck(present=True, errors=[
+            ValidationError('', f'result on fd {RESULT_FD} is not valid JSON: {exc}')])
+    if schema is None:
+        return OutputCheck(value=value, present=True)
+    return OutputCheck(value=value, present=True, errors=validate(value, schema))
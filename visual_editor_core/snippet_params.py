@@ -0,0 +1,410 @@
+"""
+Snippet Parameters — typed runtime arguments for Go snippets.
+
+A snippet declares the inputs it expects instead of hard-coding them:
+
+    specs = [ParameterSpec('n', ParamType.INT),
+             ParameterSpec('names', ParamType.STRING_SLICE, required=False, default=[])]
+
+and references them as ordinary package-level variables:
+
+    func main() { fmt.Println(fib(n), len(names)) }
+
+At execution time the caller passes {'n': 10, 'names': ['a']}.  The
+arguments are checked against the specs first (unknown names, missing
+required values, wrong types, out-of-range ints) and only then is a
+`var` block of literals injected directly above `func main()`:
+
+    // ── spokedpy parameters (generated) ──
+    var (
+        n     int      = 10
+        names []string = []string{"a"}
+    )
+
+The injection is hygienic: it introduces no identifiers besides the
+declared parameter names, needs no imports, and a parameter whose name
+is already declared at package level in the source — or bound by an
+import, such as `fmt` or the alias in `strs "strings"` — is rejected
+rather than silently shadowed or redeclared.
+
+A spec can also carry a JSON Schema document (see snippet_schema) for
+what the type alone can't say:
+
+    ParameterSpec('code', ParamType.STRING, schema='{"pattern": "^[A-Z]{3}$"}')
+    ParameterSpec('n', ParamType.INT, schema={'minimum': 1, 'maximum': 90})
+
+Once every argument has its type, each one is validated against its
+spec's schema; all the failures come back together as one
+ParameterSchemaError whose `errors` point into the argument map
+(`/code`, `/names/2`).  parameters_schema_hash() identifies a
+snippet's parameter schemas for the audit log.
+"""
+
+import re
+import json
+import math
+import hashlib
+from enum import Enum
+from dataclasses import dataclass, asdict
+from typing import Any, Dict, Iterable, List, Optional, Set
+
+from .snippet_schema import SchemaError, ValidationError, load_schema, pointer, validate
+
+
+class ParamType(str, Enum):
+    """Go types a parameter may be declared as."""
+    INT          = 'int'
+    FLOAT64      = 'float64'
+    STRING       = 'string'
+    STRING_SLICE = '[]string'
+
+
+class ParameterError(ValueError):
+    """A parameter spec or argument set violates the declared schema."""
+
+
+class ParameterSchemaError(ParameterError):
+    """Arguments of the right type fail their specs' JSON Schemas."""
+
+    def __init__(self, errors: List[ValidationError]):
+        self.errors = errors
+        super().__init__("Arguments fail their schema: " + '; '.join(str(e) for e in errors))
+
+    def error_dicts(self) -> List[Dict[str, str]]:
+        return [e.to_dict() for e in self.errors]
+
+
+GO_KEYWORDS = {
+    'break', 'case', 'chan', 'const', 'continue', 'default', 'defer', 'else',
+    'fallthrough', 'for', 'func', 'go', 'goto', 'if', 'import', 'interface',
+    'map', 'package', 'range', 'return', 'select', 'struct', 'switch', 'type', 'var',
+}
+
+# Predeclared identifiers a parameter must not shadow package-wide
+GO_PREDECLARED = {
+    'any', 'append', 'bool', 'byte', 'cap', 'clear', 'close', 'comparable',
+    'complex', 'complex64', 'complex128', 'copy', 'delete', 'error', 'false',
+    'float32', 'float64', 'imag', 'int', 'int8', 'int16', 'int32', 'int64',
+    'iota', 'len', 'make', 'max', 'min', 'new', 'nil', 'panic', 'print',
+    'println', 'real', 'recover', 'rune', 'string', 'true', 'uint', 'uint8',
+    'uint16', 'uint32', 'uint64', 'uintptr',
+}
+
+# Package-level names the Go toolchain gives meaning to
+GO_RESERVED = {'_', 'main', 'init'}
+
+INJECTION_MARKER = '// ── spokedpy parameters (generated) ──'
+
+_IDENT = re.compile(r'^[A-Za-z_][A-Za-z0-9_]*$')
+_MAIN_FUNC = re.compile(r'^func\s+main\s*\(\s*\)', re.MULTILINE)
+_INT64_MIN, _INT64_MAX = -2 ** 63, 2 ** 63 - 1
+
+
+@dataclass
+class ParameterSpec:
+    """One named, typed input a snippet declares."""
+    name: str
+    type: ParamType
+    required: bool = True
+    default: Any = None
+    description: str = ''
+    schema: str = ''                     # JSON Schema the value must satisfy ('' = none)
+
+    def __post_init__(self):
+        self.type = ParamType(self.type)
+        if not _IDENT.match(self.name or ''):
+            raise ParameterError(f"Invalid parameter name '{self.name}'")
+        if self.name in GO_KEYWORDS or self.name in GO_PREDECLARED or self.name in GO_RESERVED:
+            raise ParameterError(f"Parameter name '{self.name}' is reserved in Go")
+        if self.schema:
+            try:
+                self.schema = json.dumps(load_schema(self.schema), sort_keys=True,
+                                         separators=(',', ':'))
+            except SchemaError as exc:
+                raise ParameterError(f"Parameter '{self.name}' has an invalid schema: {exc}") from None
+        if not self.required:
+            if self.default is None:
+                raise ParameterError(f"Optional parameter '{self.name}' needs a default")
+            self.default = coerce(self, self.default)
+            errors = self.schema_errors(self.default)
+            if errors:
+                raise ParameterError(f"Default of '{self.name}' fails its schema: "
+                                     + '; '.join(str(e) for e in errors))
+
+    def schema_errors(self, value: Any) -> List[ValidationError]:
+        """How a coerced `value` fails the spec's schema, pointed into the argument map."""
+        if not self.schema:
+            return []
+        return [ValidationError(pointer([self.name]) + e.path, e.message)
+                for e in validate(value, self.schema)]
+
+    def to_dict(self) -> Dict:
+        d = asdict(self)
+        d['type'] = self.type.value
+        return d
+
+    @classmethod
+    def from_dict(cls, d: Dict) -> 'ParameterSpec':
+        return cls(name=d.get('name', ''), type=d.get('type', ''),
+                   required=d.get('required', True), default=d.get('default'),
+                   description=d.get('description', ''), schema=d.get('schema') or '')
+
+
+def coerce(spec: ParameterSpec, value: Any) -> Any:
+    """Check `value` against `spec.type`, returning it in canonical form."""
+    t = spec.type
+    if t == ParamType.INT:
+        if isinstance(value, bool) or not isinstance(value, int):
+            if isinstance(value, float) and value.is_integer():
+                value = int(value)
+            else:
+                raise ParameterError(f"'{spec.name}' must be an int (got {value!r})")
+        if not _INT64_MIN <= value <= _INT64_MAX:
+            raise ParameterError(f"'{spec.name}' is out of range for a 64-bit int")
+        return value
+    if t == ParamType.FLOAT64:
+        if isinstance(value, bool) or not isinstance(value, (int, float)):
+            raise ParameterError(f"'{spec.name}' must be a float64 (got {value!r})")
+        value = float(value)
+        if not math.isfinite(value):
+            raise ParameterError(f"'{spec.name}' must be finite (got {value!r})")
+        return value
+    if t == ParamType.STRING:
+        if not isinstance(value, str):
+            raise ParameterError(f"'{spec.name}' must be a string (got {value!r})")
+        return value
+    if not isinstance(value, (list, tuple)) or not all(isinstance(v, str) for v in value):
+        raise ParameterError(f"'{spec.name}' must be a list of strings (got {value!r})")
+    return list(value)
+
+
+def infer_param_type(value: Any) -> ParamType:
+    """ParamType for an untyped argument value (engine.run() without specs)."""
+    if isinstance(value, bool):
+        raise ParameterError(f"No Go parameter type for bool value {value!r}")
+    if isinstance(value, int):
+        return ParamType.INT
+    if isinstance(value, float):
+        return ParamType.FLOAT64
+    if isinstance(value, str):
+        return ParamType.STRING
+    if isinstance(value, (list, tuple)) and all(isinstance(v, str) for v in value):
+        return ParamType.STRING_SLICE
+    raise ParameterError(f"No Go parameter type for {type(value).__name__} value {value!r}")
+
+
+def validate_specs(specs: Iterable[ParameterSpec]) -> List[ParameterSpec]:
+    specs = list(specs)
+    seen: Set[str] = set()
+    for spec in specs:
+        if spec.name in seen:
+            raise ParameterError(f"Duplicate parameter '{spec.name}'")
+        seen.add(spec.name)
+    return specs
+
+
+def validate_arguments(specs: Iterable[ParameterSpec],
+                       arguments: Optional[Dict[str, Any]]) -> Dict[str, Any]:
+    """Resolve the final argument values (defaults applied); raise on any violation."""
+    specs = validate_specs(specs)
+    arguments = dict(arguments or {})
+    unknown = sorted(set(arguments) - {s.name for s in specs})
+    if unknown:
+        raise ParameterError(f"Unknown parameter(s): {', '.join(unknown)}")
+    resolved = {}
+    for spec in specs:
+        if spec.name in arguments:
+            resolved[spec.name] = coerce(spec, arguments[spec.name])
+        elif spec.required:
+            raise ParameterError(f"Missing required parameter '{spec.name}'")
+        else:
+            resolved[spec.name] = spec.default
+    errors = [e for spec in specs for e in spec.schema_errors(resolved[spec.name])]
+    if errors:
+        raise ParameterSchemaError(errors)
+    return resolved
+
+
+def parameters_schema_hash(specs: Iterable[ParameterSpec]) -> str:
+    """sha256 over every spec's name and schema ('' when none has a schema)."""
+    schemas = {s.name: json.loads(s.schema) for s in specs if s.schema}
+    if not schemas:
+        return ''
+    canonical = json.dumps(schemas, sort_keys=True, separators=(',', ':'))
+    return hashlib.sha256(canonical.encode('utf-8')).hexdigest()
+
+
+# ── Go source handling ──────────────────────────────────────────────────
+
+def _strip_comments_and_strings(code: str, strings: bool = True) -> str:
+    """
+    Blank out comments and (unless `strings` is False) string / rune
+    literals, keeping line structure and character offsets.
+    """
+    out, i, n = [], 0, len(code)
+    while i < n:
+        c = code[i]
+        if code.startswith('//', i):
+            j = code.find('\n', i)
+            j = n if j < 0 else j
+            out.append(' ' * (j - i))
+            i = j
+        elif code.startswith('/*', i):
+            j = code.find('*/', i + 2)
+            j = n if j < 0 else j + 2
+            out.append(re.sub(r'[^\n]', ' ', code[i:j]))
+            i = j
+        elif c == '`':
+            j = code.find('`', i + 1)
+            j = n if j < 0 else j + 1
+            out.append(re.sub(r'[^\n]', ' ', code[i:j]) if strings else code[i:j])
+            i = j
+        elif c in '"\'':
+            j = i + 1
+            while j < n and code[j] != c and code[j] != '\n':
+                j += 2 if code[j] == '\\' else 1
+            j = min(j + 1, n)
+            out.append(' ' * (j - i) if strings else code[i:j])
+            i = j
+        else:
+            out.append(c)
+            i += 1
+    return ''.join(out)
+
+
+def package_level_names(code: str) -> Set[str]:
+    """Identifiers declared at package scope (func / var / const / type)."""
+    names: Set[str] = set()
+    depth, group = 0, None          # group: 'var' | 'const' | 'type' inside `kw ( … )`
+    for line in _strip_comments_and_strings(code).splitlines():
+        stripped = line.strip()
+        if depth == 0 and group is None:
+            m = re.match(r'^func\s+([A-Za-z_]\w*)', stripped)        # not methods: `func (r T)`
+            if m:
+                names.add(m.group(1))
+            m = re.match(r'^(var|const|type)\s*\(', stripped)
+            if m:
+                group = m.group(1)
+            else:
+                m = re.match(r'^(var|const|type)\s+(.*)$', stripped)
+                if m:
+                    names.update(_declared(m.group(1), m.group(2)))
+        elif depth == 0 and group is not None:
+            if stripped.startswith(')'):
+                group = None
+            elif stripped:
+                names.update(_declared(group, stripped))
+        depth += line.count('{') - line.count('}')
+    return names
+
+
+_IMPORT_SPEC = re.compile(r'(?:([A-Za-z_]\w*|\.)\s+)?"([^"]+)"')
+
+
+def imported_names(code: str) -> Set[str]:
+    """
+    Identifiers the file's imports bind: the alias when one is given,
+    otherwise the last element of the import path (`fmt`, `rand` for
+    "math/rand").  Blank and dot imports bind nothing nameable.
+    """
+    names: Set[str] = set()
+    text = _strip_comments_and_strings(code, strings=False)
+    for m in re.finditer(r'^[ \t]*import[ \t]*(\((?:[^)]*)\)|[^\n]*)', text, re.M):
+        for alias, path in _IMPORT_SPEC.findall(m.group(1)):
+            if alias == '.':
+                continue
+            parts = path.rstrip('/').split('/')
+            if len(parts) > 1 and re.fullmatch(r'v\d+', parts[-1]):
+                parts.pop()                        # "…/yaml/v3" is package yaml
+            name = alias or parts[-1]
+            if name != '_':
+                names.add(name)
+    return names
+
+
+def _declared(kind: str, spec: str) -> List[str]:
+    if kind == 'type':
+        m = re.match(r'^([A-Za-z_]\w*)', spec)
+        return [m.group(1)] if m else []
+    m = re.match(r'^([A-Za-z_]\w*(?:\s*,\s*[A-Za-z_]\w*)*)', spec)
+    return [n.strip() for n in m.group(1).split(',')] if m else []
+
+
+def go_literal(spec: ParameterSpec, value: Any) -> str:
+    if spec.type == ParamType.INT:
+        return str(value)
+    if spec.type == ParamType.FLOAT64:
+        return repr(value)
+    if spec.type == ParamType.STRING:
+        return go_quote(value)
+    return '[]string{' + ', '.join(go_quote(v) for v in value) + '}'
+
+
+def go_quote(value: str) -> str:
+    """Go interpreted string literal for `value`."""
+    out = ['"']
+    for ch in value:
+        cp = ord(ch)
+        if ch == '"' or ch == '\\':
+            out.append('\\' + ch)
+        elif ch == '\n':
+            out.append('\\n')
+        elif ch == '\t':
+            out.append('\\t')
+        elif ch == '\r':
+            out.append('\\r')
+        elif cp < 0x20 or cp == 0x7f:
+            out.append(f'\\x{cp:02x}')
+        elif 0xd800 <= cp <= 0xdfff:
+            out.append('\\ufffd')      # Lone surrogate — not valid UTF-8
+        else:
+            out.append(ch)
+    out.append('"')
+    return ''.join(out)
+
+
+def check_source(code: str, specs: Iterable[ParameterSpec]):
+    """Raise ParameterError if `code` can't take the parameter block."""
+    specs = validate_specs(specs)
+    if not specs:
+        return
+    if not _MAIN_FUNC.search(_strip_comments_and_strings(code)):
+        raise ParameterError("Parameterised Go snippets need a `func main()`")
+    clashes = sorted(package_level_names(code) & {s.name for s in specs})
+    if clashes:
+        raise ParameterError(
+            f"Parameter(s) already declared at package level: {', '.join(clashes)}")
+    shadowed = sorted(imported_names(code) & {s.name for s in specs})
+    if shadowed:
+        raise ParameterError(
+            f"Parameter(s) would shadow an imported package: {', '.join(shadowed)}")
+
+
+def bind_parameters(code: str, specs: Iterable[ParameterSpec],
+                    arguments: Optional[Dict[str, Any]]) -> str:
+    """
+    Return `code` with a generated `var` block binding `arguments`
+    inserted directly above `func main()`.
+
+    Raises ParameterError for schema violations or identifier clashes;
+    nothing is executed.  With no specs the source is returned unchanged.
+    """
+    specs = validate_specs(specs)
+    if not specs:
+        if arguments:
+            raise ParameterError("Snippet declares no parameters")
+        return code
+    values = validate_arguments(specs, arguments)
+    check_source(code, specs)
+
+    width_name = max(len(s.name) for s in specs)
+    width_type = max(len(s.type.value) for s in specs)
+    lines = [INJECTION_MARKER, 'var (']
+    for spec in specs:
+        lines.append(f"\t{spec.name.ljust(width_name)} {spec.type.value.ljust(width_type)} "
+                     f"= {go_literal(spec, values[spec.name])}")
+    lines += [')', '', '']
+
+    # Offsets match between the stripped copy and the source
+    m = _MAIN_FUNC.search(_strip_comments_and_strings(code))
+    return code[:m.start()] + '\n'.join(lines) + code[m.start():]
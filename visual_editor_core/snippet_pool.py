@@ -0,0 +1,162 @@
+"""
+Snippet Execution Pool — concurrent speculative execution for batches.
+
+Staging a batch of snippets (e.g. 50 Go snippets queued at once) used to
+run every speculative execution back-to-back, so the batch took the SUM
+of all spec_times.  The pool fans the batch out over a bounded set of
+worker threads instead:
+
+    queue_snippet() × N ──►  SnippetExecutionPool.run_batch(ids)
+                                 │
+                 ┌───────────────┼───────────────┐
+                 ▼               ▼               ▼
+             worker 1        worker 2   …    worker K      (K = max_workers)
+          speculate(id)   speculate(id)   speculate(id)
+                 └───────────────┼───────────────┘
+                                 ▼
+               results merged back in slot order (engine, position)
+
+Guarantees:
+    • A worker that raises (the Python equivalent of a goroutine panic)
+      is recorded as a FAIL spec result — it never takes the host down.
+    • Every in-flight execution has its own cancel event.  When the
+      per-snippet deadline passes, or the batch-wide cancel event is set,
+      the snippet is marked FAILED immediately and the batch moves on.
+      The event reaches the executor, so a `cancellable` one (Go) kills
+      the straggling process and frees its worker; the late result of any
+      other run is discarded.
+    • The deadline counts from when a snippet starts running — time spent
+      waiting for a free worker or the in-process lock does not count.
+    • In-process engines (Python) share redirect_stdout, which is process
+      global, so those runs are serialized behind a lock.  Subprocess
+      engines run fully in parallel.
+"""
+
+import time
+import threading
+import traceback
+from concurrent.futures import ThreadPoolExecutor, FIRST_COMPLETED, wait
+from typing import Dict, List, Optional
+
+from .snippet_staging import SpecResult
+from .snippet_breaker import CircuitOpenError
+
+
+# Languages whose executor runs in-process and captures stdout globally.
+IN_PROCESS_LANGUAGES = {'python'}
+
+DEFAULT_MAX_WORKERS = 4
+
+
+class SnippetExecutionPool:
+    """Bounded worker pool that speculates many staged snippets at once.
+
+    The pool does not own any snippet state — it drives
+    StagingPipeline.speculate() and uses the pipeline's cancellation
+    hook so a timed-out snippet does not block the rest of the batch.
+    """
+
+    POLL_INTERVAL = 0.05   # seconds between deadline / cancel checks
+
+    def __init__(self, pipeline, max_workers: int = DEFAULT_MAX_WORKERS):
+        if max_workers < 1:
+            raise ValueError(f"max_workers must be >= 1 (got {max_workers})")
+        self._pipeline = pipeline
+        self._max_workers = max_workers
+        self._in_process_lock = threading.Lock()
+
+    @property
+    def max_workers(self) -> int:
+        return self._max_workers
+
+    def run_batch(self, staging_ids: List[str],
+                  timeout_per_snippet: Optional[float] = None,
+                  cancel_event: Optional[threading.Event] = None) -> List:
+        """
+        Speculatively execute every snippet in `staging_ids` concurrently.
+
+        Args:
+            staging_ids:          IDs of QUEUED (or FAILED) snippets.
+            timeout_per_snippet:  Seconds each snippet may run before it is
+                                  cancelled and marked FAILED (None = no limit).
+            cancel_event:         Batch-wide cancellation — once set, every
+                                  snippet still in flight is cancelled.
+
+        Returns the StagedSnippets ordered by slot (engine, position).
+        """
+        # De-duplicate while keeping the caller's order
+        ids = list(dict.fromkeys(staging_ids))
+        if not ids:
+            return []
+
+        cancels: Dict[str, threading.Event] = {sid: threading.Event() for sid in ids}
+        started_at: Dict[str, float] = {}
+
+        # Not a context manager: its __exit__ would join abandoned workers,
+        # which is exactly the blocking we are trying to avoid.
+        executor = ThreadPoolExecutor(max_workers=self._max_workers,
+                                      thread_name_prefix='spec-pool')
+        try:
+            futures = {}
+            for sid in ids:
+                fut = executor.submit(self._run_one, sid, cancels[sid], started_at)
+                futures[fut] = sid
+
+            pending = set(futures)
+            while pending:
+                done, pending = wait(pending, timeout=self.POLL_INTERVAL,
+                                     return_when=FIRST_COMPLETED)
+
+                batch_cancelled = cancel_event is not None and cancel_event.is_set()
+                now = time.time()
+                for fut in list(pending):
+                    sid = futures[fut]
+                    started = started_at.get(sid)
+                    if batch_cancelled:
+                        reason, result = 'Batch cancelled', SpecResult.FAIL
+                    elif (timeout_per_snippet is not None and started is not None
+                          and now - started >= timeout_per_snippet):
+                        reason = f'Speculative execution exceeded {timeout_per_snippet:.1f}s deadline'
+                        result = SpecResult.TIMEOUT
+                    else:
+                        continue
+                    cancels[sid].set()
+                    fut.cancel()
+                    self._pipeline.cancel_speculation(sid, reason, spec_result=result)
+                    # Don't wait on the abandoned worker — its result is discarded
+                    pending.discard(fut)
+        finally:
+            executor.shutdown(wait=False, cancel_futures=True)
+
+        snippets = [self._pipeline.get_snippet(sid) for sid in ids]
+        snippets = [s for s in snippets if s is not None]
+        snippets.sort(key=lambda s: (s.reserved_engine, s.reserved_position))
+        return snippets
+
+    def _run_one(self, staging_id: str, cancel: threading.Event,
+                 started_at: Dict[str, float]):
+        """Worker body — never raises; failures become FAIL spec results."""
+        if cancel.is_set():
+            return None
+        try:
+            snippet = self._pipeline.get_snippet(staging_id)
+            if snippet is not None and snippet.language in IN_PROCESS_LANGUAGES:
+                with self._in_process_lock:
+                    if cancel.is_set():
+                        return None
+                    # The deadline starts once the snippet really runs, not
+                    # while it waits for a worker or the in-process lock
+                    started_at[staging_id] = time.time()
+                    return self._pipeline.speculate(staging_id, cancel_event=cancel)
+            started_at[staging_id] = time.time()
+            return self._pipeline.speculate(staging_id, cancel_event=cancel)
+        except CircuitOpenError as exc:
+            self._pipeline.cancel_speculation(staging_id, str(exc))
+            return None
+        except (Exception, SystemExit) as exc:
+            self._pipeline.cancel_speculation(
+                staging_id,
+                f'Worker crashed: {exc}',
+                tb=traceback.format_exc(),
+            )
+            return None
@@ -0,0 +1,205 @@
+"""
+Snippet Query — filtered, paginated search over staged and promoted snippets.
+
+    page = pipeline.query(SnippetFilter(language='go', label='fib', limit=50))
+    while True:
+        handle(page.snippets)
+        if not page.next_page_token:
+            break
+        page = pipeline.query(SnippetFilter(language='go', label='fib', limit=50,
+                                            page_token=page.next_page_token))
+
+Results are ordered oldest first by (created_at, staging_id).  The page
+token is an opaque cursor encoding the last key returned, so snippets
+queued while a caller is paging land on a later page instead of shifting
+ones already seen.
+
+SnippetIndex is the storage seam: the pipeline calls put() whenever a
+filterable field of a snippet changes (queue, speculative result, lint
+failure, promotion) and remove() when a snippet ages out of history.  The
+in-memory index is the default; SQLiteSnippetIndex (snippet_sqlite)
+persists the same records across restarts.  Both index tags (see
+snippet_tags), so a SnippetFilter with `tags` looks its candidates up
+instead of scanning every record.
+"""
+
+import json
+import base64
+import bisect
+import threading
+from abc import ABC, abstractmethod
+from dataclasses import dataclass, field
+from typing import Dict, List, Optional, Tuple
+
+from .snippet_tags import TagIndex, parse_tag_query
+
+
+DEFAULT_PAGE_SIZE = 50
+MAX_PAGE_SIZE = 1000
+
+
+@dataclass
+class SnippetFilter:
+    """Match criteria for SnippetIndex.query().  Empty fields match everything."""
+    language: str = ''
+    label: str = ''                          # Case-insensitive substring
+    slot: str = ''                           # Engine letter, e.g. 'i'
+    spec_result: str = ''                    # SpecResult value, e.g. 'PASS'
+    created_after: float = 0.0               # Unix timestamp, exclusive
+    created_before: float = 0.0              # Unix timestamp, exclusive
+    code_hash_prefix: str = ''
+    tags: str = ''                           # Tag query, e.g. 'math/** AND pure' (snippet_tags)
+    namespace: str = ''                      # Exact match; pipeline.query() pins it for scoped callers
+    include_archived: bool = False           # Also match records an ArchivalPolicy archived
+    limit: int = DEFAULT_PAGE_SIZE
+    page_token: str = ''
+
+    def __post_init__(self):
+        if not 1 <= self.limit <= MAX_PAGE_SIZE:
+            raise ValueError(f"limit must be between 1 and {MAX_PAGE_SIZE} (got {self.limit})")
+        self.language = self.language.lower().strip()
+        self.spec_result = self.spec_result.upper().strip()
+        self.code_hash_prefix = self.code_hash_prefix.lower().strip()
+        self.namespace = self.namespace.lower().strip()
+        self.tags = self.tags.strip()
+        if self.tags:
+            parse_tag_query(self.tags)
+
+    def matches(self, snippet) -> bool:
+        if self.language and snippet.language != self.language:
+            return False
+        if self.label and self.label.lower() not in snippet.label.lower():
+            return False
+        if self.slot and snippet.engine_letter != self.slot:
+            return False
+        if self.spec_result and snippet.spec_result.value != self.spec_result:
+            return False
+        if self.created_after and snippet.created_at <= self.created_after:
+            return False
+        if self.created_before and snippet.created_at >= self.created_before:
+            return False
+        if self.code_hash_prefix and not snippet.code_hash.startswith(self.code_hash_prefix):
+            return False
+        if self.namespace and snippet.namespace != self.namespace:
+            return False
+        if self.tags and not parse_tag_query(self.tags).matches(snippet.tags):
+            return False
+        if not self.include_archived and snippet.archived_at:
+            return False
+        return True
+
+
+@dataclass
+class QueryPage:
+    """One page of query results."""
+    snippets: List = field(default_factory=list)    # StagedSnippets
+    next_page_token: str = ''                       # '' on the last page
+
+    def to_dict(self) -> Dict:
+        return {
+            'snippets': [s.to_dict() for s in self.snippets],
+            'count': len(self.snippets),
+            'next_page_token': self.next_page_token,
+        }
+
+
+def encode_page_token(created_at: float, staging_id: str) -> str:
+    raw = json.dumps([created_at, staging_id]).encode('utf-8')
+    return base64.urlsafe_b64encode(raw).decode('ascii').rstrip('=')
+
+
+def decode_page_token(token: str) -> Tuple[float, str]:
+    """Inverse of encode_page_token(); raises ValueError for a malformed token."""
+    try:
+        padded = token + '=' * (-len(token) % 4)
+        created_at, staging_id = json.loads(base64.urlsafe_b64decode(padded))
+        return float(created_at), str(staging_id)
+    except (ValueError, TypeError) as exc:
+        raise ValueError(f"Invalid page_token '{token}'") from exc
+
+
+class SnippetIndex(ABC):
+    """Queryable store of snippet records."""
+
+    # Persistent indexes keep records of snippets that age out of history
+    persistent = False
+
+    def bind(self, pipeline):
+        """Called once by the StagingPipeline that owns this index."""
+
+    @abstractmethod
+    def put(self, snippet):
+        """Insert or refresh the record for `snippet`."""
+
+    @abstractmethod
+    def get(self, staging_id: str):
+        """The record for `staging_id`, or None."""
+
+    @abstractmethod
+    def remove(self, staging_id: str):
+        """Drop a record (no-op if absent)."""
+
+    @abstractmethod
+    def query(self, snippet_filter: SnippetFilter) -> QueryPage:
+        """Return the page of matches after `snippet_filter.page_token`."""
+
+
+class InMemorySnippetIndex(SnippetIndex):
+    """Snippets kept sorted by (created_at, staging_id); thread-safe."""
+
+    def __init__(self):
+        self._lock = threading.Lock()
+        self._keys: List[Tuple[float, str]] = []
+        self._snippets: Dict[str, object] = {}
+        self._tags = TagIndex()
+
+    def put(self, snippet):
+        with self._lock:
+            if snippet.staging_id not in self._snippets:
+                bisect.insort(self._keys, (snippet.created_at, snippet.staging_id))
+            self._snippets[snippet.staging_id] = snippet
+            self._tags.put(snippet.staging_id, getattr(snippet, 'tags', ()))
+
+    def get(self, staging_id: str):
+        with self._lock:
+            return self._snippets.get(staging_id)
+
+    def remove(self, staging_id: str):
+        with self._lock:
+            snippet = self._snippets.pop(staging_id, None)
+            if snippet is None:
+                return
+            self._tags.remove(staging_id)
+            idx = bisect.bisect_left(self._keys, (snippet.created_at, staging_id))
+            if idx < len(self._keys) and self._keys[idx][1] == staging_id:
+                del self._keys[idx]
+
+    def query(self, snippet_filter: SnippetFilter) -> QueryPage:
+        cursor = decode_page_token(snippet_filter.page_token) if snippet_filter.page_token \
+            else None
+        with self._lock:
+            if snippet_filter.tags:
+                # Candidates from the tag index rather than every record
+                tagged = self._tags.query(parse_tag_query(snippet_filter.tags))
+                keys = sorted((self._snippets[i].created_at, i) for i in tagged)
+                if cursor is not None:
+                    keys = keys[bisect.bisect_right(keys, cursor):]
+            else:
+                keys = self._keys[bisect.bisect_right(self._keys, cursor):] \
+                    if cursor is not None else self._keys
+            matches = []
+            for key in keys:
+                snippet = self._snippets[key[1]]
+                if not snippet_filter.matches(snippet):
+                    continue
+                if len(matches) == snippet_filter.limit:
+                    # One more match exists, so there is a next page
+                    last = matches[-1]
+                    return QueryPage(matches, encode_page_token(last.created_at,
+                                                                last.staging_id))
+                matches.append(snippet)
+        return QueryPage(matches)
+
+    def __len__(self) -> int:
+        with self._lock:
+            return len(self._snippets)
@@ -0,0 +1,341 @@
+"""
+Snippet Execution Queue — asynchronous, prioritised speculative execution.
+
+run_full_pipeline() blocks the caller until the snippet has been
+speculated.  The queue decouples the two: stage() queues the snippet
+into the pipeline and returns its staging_id immediately, and a fixed
+pool of background workers drains the pending jobs, highest priority
+first (FIFO within a level):
+
+    stage(code, priority=HIGH) ──► staging_id        (returns at once)
+                                        │
+                 pending heap  [HIGH …][NORMAL …][LOW …]   ≤ max_queue_depth
+                                        │
+              worker 1 … worker K  ──►  pipeline.speculate(id)
+                                        │  (+ promote if auto_promote)
+    wait_for_result(id, timeout) ◄──────┘  SpecResult
+
+Backpressure: once `max_queue_depth` jobs are pending, stage() either
+blocks until a worker frees a place (block=True) or raises
+QueueFullError.  close() stops intake and lets the workers finish what
+is already pending before they exit.
+
+Finished jobs stay answerable by wait_for_result() / status() until
+`retain_results` newer jobs have finished; after that the queue forgets
+them and wait_for_result() falls back to the pipeline's record.
+
+As in SnippetExecutionPool, in-process (Python) runs share the process-
+global stdout redirect and are serialised; subprocess engines run in
+parallel.
+"""
+
+import heapq
+import itertools
+import threading
+import time
+import traceback
+from collections import OrderedDict
+from dataclasses import dataclass, field
+from enum import IntEnum
+from typing import Any, Dict, List, Optional
+
+from .snippet_staging import SpecResult, StagingPhase
+from .snippet_lint import LintFailedError
+from .snippet_breaker import CircuitOpenError
+from .snippet_pool import IN_PROCESS_LANGUAGES
+
+
+DEFAULT_WORKERS = 4
+DEFAULT_MAX_QUEUE_DEPTH = 100
+DEFAULT_RETAINED_RESULTS = 1000
+
+
+class Priority(IntEnum):
+    LOW    = 0
+    NORMAL = 1
+    HIGH   = 2
+
+
+class QueueFullError(RuntimeError):
+    """max_queue_depth jobs are already pending."""
+
+
+class QueueClosedError(RuntimeError):
+    """The queue has been closed and accepts no more work."""
+
+
+@dataclass(order=True)
+class _Job:
+    sort_key: tuple                                   # (-priority, sequence)
+    staging_id: str = field(compare=False)
+    priority: Priority = field(compare=False)
+    arguments: Optional[Dict[str, Any]] = field(default=None, compare=False)
+    auto_promote: bool = field(default=False, compare=False)
+    skip_lint: bool = field(default=False, compare=False)
+    enqueued_at: float = field(default_factory=time.time, compare=False)
+
+
+class SpeculationQueue:
+    """Priority queue of pending speculations drained by worker threads."""
+
+    def __init__(self, pipeline, workers: int = DEFAULT_WORKERS,
+                 max_queue_depth: int = DEFAULT_MAX_QUEUE_DEPTH,
+                 block: bool = False, retain_results: int = DEFAULT_RETAINED_RESULTS):
+        if workers < 1:
+            raise ValueError(f"workers must be >= 1 (got {workers})")
+        if max_queue_depth < 1:
+            raise ValueError(f"max_queue_depth must be >= 1 (got {max_queue_depth})")
+        if retain_results < 0:
+            raise ValueError(f"retain_results must be >= 0 (got {retain_results})")
+        self._pipeline = pipeline
+        self._max_depth = max_queue_depth
+        self._block = block
+        self._retain = retain_results
+
+        self._lock = threading.Lock()
+        self._not_empty = threading.Condition(self._lock)
+        self._not_full = threading.Condition(self._lock)
+        self._heap: List[_Job] = []
+        self._reserved = 0                            # places held by in-progress stage() calls
+        self._running: Dict[str, _Job] = {}
+        self._done: Dict[str, threading.Event] = {}
+        self._finished: 'OrderedDict[str, None]' = OrderedDict()   # oldest first
+        self._seq = itertools.count()
+        self._closed = False
+        self._in_process_lock = threading.Lock()
+
+        self._workers = [threading.Thread(target=self._loop, daemon=True,
+                                          name=f'spec-queue-{n}')
+                         for n in range(workers)]
+        for worker in self._workers:
+            worker.start()
+
+    @property
+    def max_queue_depth(self) -> int:
+        return self._max_depth
+
+    @property
+    def depth(self) -> int:
+        """Jobs waiting for a worker (not counting those being run)."""
+        with self._lock:
+            return len(self._heap) + self._reserved
+
+    @property
+    def closed(self) -> bool:
+        return self._closed
+
+    # ── Intake ───────────────────────────────────────────────────────
+
+    def stage(self, engine_letter: str, language: str, code: str, label: str = '',
+              priority: Priority = Priority.NORMAL,
+              block: Optional[bool] = None, timeout: Optional[float] = None,
+              arguments: Optional[Dict[str, Any]] = None,
+              auto_promote: bool = False, skip_lint: bool = False,
+              **queue_kwargs) -> str:
+        """
+        Queue a snippet and schedule its speculation; returns the staging_id.
+
+        `queue_kwargs` (label_policy, parameters, namespace, env, requires,
+        output_schema, tags, test_cases, suppress_credential_check) go to
+        queue_snippet().
+        Raises QueueFullError when the queue is full and not blocking (or
+        the block `timeout` expires), QueueClosedError after close(), and
+        whatever queue_snippet() raises for a bad snippet.
+        """
+        priority = Priority(priority)
+        self._reserve(self._block if block is None else block, timeout)
+        try:
+            snippet = self._pipeline.queue_snippet(engine_letter, language, code, label,
+                                                   **queue_kwargs)
+        except BaseException:
+            with self._lock:
+                self._reserved -= 1
+                self._not_full.notify()
+            raise
+        self._push(snippet.staging_id, priority, arguments, auto_promote, skip_lint)
+        return snippet.staging_id
+
+    def submit(self, staging_id: str, priority: Priority = Priority.NORMAL,
+               block: Optional[bool] = None, timeout: Optional[float] = None,
+               arguments: Optional[Dict[str, Any]] = None,
+               auto_promote: bool = False, skip_lint: bool = False) -> str:
+        """Schedule speculation of an already-queued snippet."""
+        snippet = self._pipeline.get_snippet(staging_id)
+        if snippet is None:
+            raise ValueError(f"No staged snippet with id '{staging_id}'")
+        with self._lock:
+            if staging_id in self._running or any(j.staging_id == staging_id for j in self._heap):
+                raise ValueError(f"Snippet {staging_id} is already scheduled")
+        self._reserve(self._block if block is None else block, timeout)
+        self._push(staging_id, Priority(priority), arguments, auto_promote, skip_lint)
+        return staging_id
+
+    def _reserve(self, block: bool, timeout: Optional[float]):
+        deadline = None if timeout is None else time.time() + timeout
+        with self._lock:
+            while True:
+                if self._closed:
+                    raise QueueClosedError("Speculation queue is closed")
+                if len(self._heap) + self._reserved < self._max_depth:
+                    self._reserved += 1
+                    return
+                if not block:
+                    raise QueueFullError(
+                        f"Speculation queue is full ({self._max_depth} pending)")
+                remaining = None if deadline is None else deadline - time.time()
+                if remaining is not None and remaining <= 0:
+                    raise QueueFullError(
+                        f"Speculation queue still full after {timeout:g}s")
+                self._not_full.wait(remaining)
+
+    def _push(self, staging_id: str, priority: Priority,
+              arguments: Optional[Dict[str, Any]], auto_promote: bool,
+              skip_lint: bool):
+        """Turn a place taken by _reserve() into a pending job."""
+        job = _Job(sort_key=(-priority, next(self._seq)), staging_id=staging_id,
+                   priority=priority, arguments=arguments,
+                   auto_promote=auto_promote, skip_lint=skip_lint)
+        with self._lock:
+            self._reserved -= 1
+            self._finished.pop(staging_id, None)
+            self._done[staging_id] = threading.Event()
+            heapq.heappush(self._heap, job)
+            self._not_empty.notify()
+
+    # ── Results ──────────────────────────────────────────────────────
+
+    def wait_for_result(self, staging_id: str,
+                        timeout: Optional[float] = None) -> SpecResult:
+        """
+        Block until the snippet's speculation (and promotion, if requested)
+        has finished; returns its SpecResult.
+
+        Raises TimeoutError if `timeout` seconds pass first, ValueError for
+        an id the queue has never seen that has no result yet.
+        """
+        with self._lock:
+            done = self._done.get(staging_id)
+        if done is None:
+            snippet = self._pipeline.get_snippet(staging_id)
+            if snippet is None:
+                raise ValueError(f"No staged snippet with id '{staging_id}'")
+            if snippet.phase in (StagingPhase.QUEUED, StagingPhase.SPECULATING):
+                raise ValueError(f"Snippet {staging_id} is not scheduled on this queue")
+            return snippet.spec_result
+        if not done.wait(timeout):
+            raise TimeoutError(f"No result for {staging_id} after {timeout:g}s")
+        return self._pipeline.get_snippet(staging_id).spec_result
+
+    def status(self, staging_id: str) -> str:
+        """'pending' | 'running' | 'done' | 'unknown'."""
+        with self._lock:
+            if staging_id in self._running:
+                return 'running'
+            if any(j.staging_id == staging_id for j in self._heap):
+                return 'pending'
+            done = self._done.get(staging_id)
+        if done is not None and done.is_set():
+            return 'done'
+        return 'unknown'
+
+    def pending(self) -> List[Dict]:
+        """Pending jobs in the order workers will take them."""
+        with self._lock:
+            jobs = sorted(self._heap)
+        return [{'staging_id': j.staging_id, 'priority': j.priority.name.lower(),
+                 'enqueued_at': j.enqueued_at} for j in jobs]
+
+    def get_summary(self) -> Dict[str, Any]:
+        with self._lock:
+            by_priority = {p.name.lower(): 0 for p in Priority}
+            for job in self._heap:
+                by_priority[job.priority.name.lower()] += 1
+            return {
+                'depth': len(self._heap) + self._reserved,
+                'running': len(self._running),
+                'max_queue_depth': self._max_depth,
+                'workers': len(self._workers),
+                'blocking': self._block,
+                'closed': self._closed,
+                'by_priority': by_priority,
+            }
+
+    # ── Workers ──────────────────────────────────────────────────────
+
+    def _loop(self):
+        while True:
+            with self._lock:
+                while not self._heap and not self._closed:
+                    self._not_empty.wait()
+                if not self._heap:
+                    return                                    # closed and drained
+                job = heapq.heappop(self._heap)
+                self._running[job.staging_id] = job
+                self._not_full.notify()
+            try:
+                self._run(job)
+            finally:
+                with self._lock:
+                    self._running.pop(job.staging_id, None)
+                    self._finish(job.staging_id)
+
+    def _finish(self, staging_id: str):
+        """Wake waiters and retire the oldest results beyond `retain_results`.  Lock held."""
+        done = self._done.get(staging_id)
+        if done is not None:
+            done.set()
+        self._finished[staging_id] = None
+        while len(self._finished) > self._retain:
+            oldest, _ = self._finished.popitem(last=False)
+            self._done.pop(oldest, None)
+
+    def _run(self, job: _Job):
+        """Worker body — never raises; failures become FAIL spec results."""
+        pipeline = self._pipeline
+        try:
+            snippet = pipeline.get_snippet(job.staging_id)
+            if snippet is None:
+                return
+            if snippet.language in IN_PROCESS_LANGUAGES:
+                with self._in_process_lock:
+                    snippet = pipeline.speculate(job.staging_id, arguments=job.arguments)
+            else:
+                snippet = pipeline.speculate(job.staging_id, arguments=job.arguments)
+            if job.auto_promote and snippet.phase == StagingPhase.PASSED:
+                try:
+                    pipeline.promote(job.staging_id, skip_lint=job.skip_lint)
+                except LintFailedError:
+                    pass                                      # recorded as LINT_FAIL
+        except CircuitOpenError as exc:
+            pipeline.cancel_speculation(job.staging_id, str(exc))
+        except (Exception, SystemExit) as exc:
+            pipeline.cancel_speculation(job.staging_id, f'Worker crashed: {exc}',
+                                        tb=traceback.format_exc())
+
+    # ── Shutdown ─────────────────────────────────────────────────────
+
+    def close(self, timeout: Optional[float] = None, drain: bool = True) -> bool:
+        """
+        Stop accepting work and shut the workers down.
+
+        With `drain` every pending job is still run; otherwise pending jobs
+        are cancelled (FAILED, 'Queue closed').  Blocked stage() callers get
+        QueueClosedError.  Returns True if every worker exited within
+        `timeout` seconds.  Safe to call more than once.
+        """
+        with self._lock:
+            self._closed = True
+            dropped = [] if drain else list(self._heap)
+            if not drain:
+                self._heap.clear()
+            self._not_empty.notify_all()
+            self._not_full.notify_all()
+        for job in dropped:
+            self._pipeline.cancel_speculation(job.staging_id, 'Queue closed')
+            with self._lock:
+                self._finish(job.staging_id)
+
+        deadline = None if timeout is None else time.time() + timeout
+        for worker in self._workers:
+            worker.join(None if deadline is None else max(0.0, deadline - time.time()))
+        return not any(w.is_alive() for w in self._workers)
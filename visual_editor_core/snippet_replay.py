@@ -0,0 +1,119 @@
+"""
+Snippet Replay — run a historical promotion again, exactly as it ran.
+
+    result = pipeline.replay('stg-6ecfd6d20bfe', ReplayOptions(record_as='before-upgrade'))
+    result.spec_result                 # 'PASS'
+    result.matches_original            # False: the output changed since promotion
+    pipeline.replay_snapshot('before-upgrade')
+
+Any snippet that was promoted can be replayed — live, superseded, rolled
+back or evicted.  The source is read from the content-addressable store
+by the snippet's code_hash, so it is byte-for-byte the version that was
+promoted (not whatever is live on the slot now), and it runs with the
+env and the parameter arguments its speculation was given, in a fresh
+execution of its slot's isolation level.
+
+A replay is not a promotion and leaves the slot alone: the snippet's
+phase, spec_* fields and rerun history don't change, nothing is written
+to the registry or ledger, and it is counted in snippet_replays_total
+rather than snippet_promotions_total.  Each replay is audited as
+SNIPPET_REPLAYED.
+
+With ReplayOptions.record_as the result is also kept as a named snapshot
+(replay_snapshot(), replay_snapshots()), so runs of the same promotion
+at different times — before and after a dependency or host change — can
+be compared with each other and with the original speculation.
+"""
+
+import re
+from dataclasses import dataclass
+from typing import Any, Dict
+
+
+# Snapshot names: like labels, letters, digits, '_', '.', '-'
+_NAME = re.compile(r'^[A-Za-z0-9][A-Za-z0-9_.\-]{0,63}$')
+
+
+class ReplayError(ValueError):
+    """A snippet can't be replayed (never promoted, or its payload is gone)."""
+
+
+def validate_snapshot_name(name: str) -> str:
+    """`name` stripped; ValueError if it can't name a replay snapshot."""
+    name = (name or '').strip()
+    if not _NAME.match(name):
+        raise ValueError(f"Invalid snapshot name '{name}': use 1-64 of A-Z, a-z, 0-9, "
+                         f"'_', '.', '-' starting with a letter or digit")
+    return name
+
+
+@dataclass
+class ReplayOptions:
+    record_as: str = ''                  # Keep the result as this named snapshot ('' = don't)
+
+    def __post_init__(self):
+        if self.record_as:
+            self.record_as = validate_snapshot_name(self.record_as)
+
+    @classmethod
+    def from_dict(cls, d: Dict) -> 'ReplayOptions':
+        return cls(record_as=str(d.get('record_as') or ''))
+
+    def to_dict(self) -> Dict:
+        return {'record_as': self.record_as}
+
+
+@dataclass
+class ReplayResult:
+    """One replay of a promoted snippet, next to what its speculation produced."""
+    staging_id: str
+    slot: str                            # Engine letter
+    label: str
+    code_hash: str
+    replayed_at: float
+    isolation: str                       # IsolationLevel value it ran at
+    spec_result: str                     # SpecResult value ('PASS', 'FAIL', …)
+    success: bool
+    output: str = ''
+    error: str = ''
+    execution_time: float = 0.0
+    output_value: Any = None             # Parsed fd 3 result (snippet_output)
+    original_result: str = ''            # The promoted speculation's spec_result
+    original_output: str = ''
+    snapshot: str = ''                   # ReplayOptions.record_as
+
+    @property
+    def matches_original(self) -> bool:
+        """Same verdict and the same output as the speculation it was promoted on."""
+        return (self.spec_result == self.original_result
+                and self.output == self.original_output)
+
+    def to_dict(self) -> Dict:
+        return {
+            'staging_id': self.staging_id,
+            'slot': self.slot,
+            'label': self.label,
+            'code_hash': self.code_hash,
+            'replayed_at': self.replayed_at,
+            'isolation': self.isolation,
+            'spec_result': self.spec_result,
+            'success': self.success,
+            'output': self.output[:5000],
+            'error': self.error,
+            'execution_time': self.execution_time,
+            'output_value': self.output_value,
+            'original_result': self.original_result,
+            'matches_original': self.matches_original,
+            'snapshot': self.snapshot,
+        }
+
+
+def compare_snapshots(a: ReplayResult, b: ReplayResult) -> Dict[str, Dict[str, Any]]:
+    """field → {'a': …, 'b': …} for each replayed field that differs (empty when equal)."""
+    changes: Dict[str, Dict[str, Any]] = {}
+    for name in ('staging_id', 'code_hash', 'isolation', 'spec_result', 'output',
+                 'error', 'output_value'):
+        old, new = getattr(a, name), getattr(b, name)
+        if old != new:
+            changes[name] = {'a': old, 'b': new}
+    return changes
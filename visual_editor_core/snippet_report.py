@@ -0,0 +1,159 @@
+"""
+Snippet Report — aggregate spec results for one slot into a health summary.
+
+    report = pipeline.slot_report('i', since=time.time() - 86400)
+    report.pass_count, report.spec_time_p95, report.trend     # … TrendDirection.DEGRADING
+
+Every snippet in the slot whose speculative run finished at or after
+`since` is a sample; promotions come from the slot's promotion history.
+The trend is the slope of a least-squares line through the spec times
+of the last TREND_WINDOW samples (oldest first), taken relative to their
+mean: the line rising by more than TREND_TOLERANCE of the mean over the
+window is DEGRADING, falling by as much is IMPROVING, anything flatter
+(or fewer than TREND_MIN_SAMPLES samples) is STABLE.
+
+build_slot_report() is a pure function over snippets and promotion
+records, so it can be tested without a pipeline.
+"""
+
+import math
+from enum import Enum
+from dataclasses import dataclass, asdict
+from typing import Dict, Iterable, List, Sequence
+
+
+TREND_WINDOW = 20            # Most recent spec_time samples the regression uses
+TREND_MIN_SAMPLES = 3        # Fewer than this is always STABLE
+TREND_TOLERANCE = 0.10       # Rise / fall across the window, as a share of the mean
+
+
+class TrendDirection(str, Enum):
+    IMPROVING = 'improving'          # spec times falling
+    DEGRADING = 'degrading'          # spec times rising
+    STABLE    = 'stable'
+
+
+@dataclass
+class SlotReport:
+    """Health of one slot (engine letter) since a point in time."""
+    slot: str
+    since: float
+    samples: int = 0                     # Finished speculative runs counted
+    total_promotions: int = 0
+    pass_count: int = 0
+    fail_count: int = 0
+    timeout_count: int = 0
+    lint_fail_count: int = 0
+    resource_exceeded_count: int = 0
+    schema_fail_count: int = 0
+    coverage_fail_count: int = 0
+    bench_regression_count: int = 0
+    weak_spec_count: int = 0
+    case_fail_count: int = 0
+    spec_time_p50: float = 0.0           # Seconds
+    spec_time_p95: float = 0.0
+    spec_time_p99: float = 0.0
+    last_promoted_label: str = ''
+    last_promoted_at: float = 0.0
+    slowest_label: str = ''              # Worst average spec time
+    slowest_label_avg_time: float = 0.0
+    trend: TrendDirection = TrendDirection.STABLE
+    trend_slope: float = 0.0             # Seconds per sample
+
+    def to_dict(self) -> Dict:
+        d = asdict(self)
+        d['trend'] = self.trend.value
+        return d
+
+
+def percentile(values: Sequence[float], q: float) -> float:
+    """q-th percentile (0–100) of `values`, linearly interpolated; 0.0 if empty."""
+    if not values:
+        return 0.0
+    ordered = sorted(values)
+    rank = (len(ordered) - 1) * q / 100.0
+    lo, hi = math.floor(rank), math.ceil(rank)
+    return ordered[lo] + (ordered[hi] - ordered[lo]) * (rank - lo)
+
+
+def linear_slope(values: Sequence[float]) -> float:
+    """Least-squares slope of `values` against their index (0.0 for < 2 values)."""
+    n = len(values)
+    if n < 2:
+        return 0.0
+    mean_x = (n - 1) / 2.0
+    mean_y = sum(values) / n
+    sxx = sum((x - mean_x) ** 2 for x in range(n))
+    sxy = sum((x - mean_x) * (y - mean_y) for x, y in enumerate(values))
+    return sxy / sxx
+
+
+def trend_direction(values: Sequence[float],
+                    tolerance: float = TREND_TOLERANCE) -> TrendDirection:
+    """Direction of the regression line through `values` (oldest first)."""
+    if len(values) < TREND_MIN_SAMPLES:
+        return TrendDirection.STABLE
+    mean = sum(values) / len(values)
+    if mean <= 0:
+        return TrendDirection.STABLE
+    drift = linear_slope(values) * (len(values) - 1) / mean
+    if drift > tolerance:
+        return TrendDirection.DEGRADING
+    if drift < -tolerance:
+        return TrendDirection.IMPROVING
+    return TrendDirection.STABLE
+
+
+def build_slot_report(slot: str, since: float, snippets: Iterable,
+                      promotions: Iterable, window: int = TREND_WINDOW) -> SlotReport:
+    """
+    Aggregate `snippets` (StagedSnippets of the slot) and `promotions`
+    (its PromotionRecords) from `since` onwards.
+    """
+    report = SlotReport(slot=slot, since=since)
+
+    runs = sorted((s for s in snippets
+                   if s.engine_letter == slot and s.spec_completed_at
+                   and s.spec_completed_at >= since),
+                  key=lambda s: s.spec_completed_at)
+    report.samples = len(runs)
+    counts = {'PASS': 0, 'FAIL': 0, 'TIMEOUT': 0, 'LINT_FAIL': 0, 'RESOURCE_EXCEEDED': 0,
+              'SCHEMA_FAIL': 0, 'COVERAGE_FAIL': 0, 'BENCH_REGRESSION': 0,
+              'WEAK_SPEC': 0, 'CASE_FAIL': 0}
+    by_label: Dict[str, List[float]] = {}
+    for s in runs:
+        counts[s.spec_result.value] = counts.get(s.spec_result.value, 0) + 1
+        by_label.setdefault(s.label, []).append(s.spec_execution_time)
+    report.pass_count = counts['PASS']
+    report.fail_count = counts['FAIL']
+    report.timeout_count = counts['TIMEOUT']
+    report.lint_fail_count = counts['LINT_FAIL']
+    report.resource_exceeded_count = counts['RESOURCE_EXCEEDED']
+    report.schema_fail_count = counts['SCHEMA_FAIL']
+    report.coverage_fail_count = counts['COVERAGE_FAIL']
+    report.bench_regression_count = counts['BENCH_REGRESSION']
+    report.weak_spec_count = counts['WEAK_SPEC']
+    report.case_fail_count = counts['CASE_FAIL']
+
+    times = [s.spec_execution_time for s in runs]
+    report.spec_time_p50 = percentile(times, 50)
+    report.spec_time_p95 = percentile(times, 95)
+    report.spec_time_p99 = percentile(times, 99)
+
+    if by_label:
+        averages = {label: sum(t) / len(t) for label, t in by_label.items()}
+        report.slowest_label = max(sorted(averages), key=averages.get)
+        report.slowest_label_avg_time = averages[report.slowest_label]
+
+    promoted = sorted((r for r in promotions
+                       if r.event == 'promote' and r.timestamp >= since),
+                      key=lambda r: r.timestamp)
+    report.total_promotions = len(promoted)
+    if promoted:
+        report.last_promoted_label = promoted[-1].label
+        report.last_promoted_at = promoted[-1].timestamp
+
+    recent = times[-window:] if window > 0 else []
+    report.trend = trend_direction(recent)
+    report.trend_slope = linear_slope(recent)
+    return report
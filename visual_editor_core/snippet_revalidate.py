@@ -0,0 +1,228 @@
+"""
+Snippet Revalidation — re-run every production snippet's spec after an engine upgrade.
+
+A new Go toolchain, interpreter or engine release can break snippets that
+passed when they were promoted.  revalidate_all() runs the spec of every
+PROMOTED snippet (of one slot, or all of them) again against the engines
+as they are now, and streams the outcome of each as it finishes:
+
+    run = pipeline.revalidate_all('i', RevalidateOptions(concurrency=8, fail_fast=True))
+    for event in run:
+        if event.summary is None:
+            print(event.staging_id, event.original_result, '→', event.spec_result)
+        else:
+            print(event.summary.passed, '/', event.summary.total)
+
+Each run is isolated at its slot's level and given the env and parameter
+arguments its speculation had, exactly like a scheduled rerun.  Events
+arrive in completion order; the last one carries the RevalidateSummary
+(total, passed, failed, skipped, and the mean spec_time delta against the
+times recorded at speculation).
+
+    concurrency      snippets run at once
+    fail_fast        stop starting snippets after the first failure — the
+                     rest are counted as skipped
+    update_metadata  overwrite each snippet's stored spec_result and
+                     spec_execution_time with the new run's (otherwise
+                     nothing about the snippet changes)
+
+run.cancel() stops starting snippets too; ones already running finish.
+"""
+
+import queue
+import threading
+import time
+from concurrent.futures import FIRST_COMPLETED, ThreadPoolExecutor, wait
+from dataclasses import dataclass
+from typing import Callable, Dict, Iterator, List, Optional
+
+
+DEFAULT_REVALIDATE_CONCURRENCY = 4
+MAX_REVALIDATE_CONCURRENCY = 64
+
+STOPPED_FAIL_FAST = 'fail_fast'
+STOPPED_CANCELLED = 'cancelled'
+
+
+@dataclass
+class RevalidateOptions:
+    concurrency: int = DEFAULT_REVALIDATE_CONCURRENCY   # Snippets run at once
+    fail_fast: bool = False              # Start no more snippets after the first failure
+    update_metadata: bool = False        # Overwrite the stored spec_result / spec_execution_time
+
+    def __post_init__(self):
+        if not 1 <= self.concurrency <= MAX_REVALIDATE_CONCURRENCY:
+            raise ValueError(f"concurrency must be between 1 and {MAX_REVALIDATE_CONCURRENCY}")
+
+    @classmethod
+    def from_dict(cls, d: Dict) -> 'RevalidateOptions':
+        return cls(
+            concurrency=int(d.get('concurrency', DEFAULT_REVALIDATE_CONCURRENCY)),
+            fail_fast=bool(d.get('fail_fast', False)),
+            update_metadata=bool(d.get('update_metadata', False)),
+        )
+
+    def to_dict(self) -> Dict:
+        return {
+            'concurrency': self.concurrency,
+            'fail_fast': self.fail_fast,
+            'update_metadata': self.update_metadata,
+        }
+
+
+@dataclass
+class RevalidateSummary:
+    """How a revalidate_all() run went, once every snippet finished or was skipped."""
+    total: int                           # Snippets selected
+    passed: int = 0
+    failed: int = 0
+    skipped: int = 0                     # Never started (fail_fast or cancel())
+    mean_spec_time_delta: float = 0.0    # Mean of (new − original) spec_time over the runs
+    stopped: str = ''                    # STOPPED_FAIL_FAST | STOPPED_CANCELLED | ''
+    started_at: float = 0.0
+    finished_at: float = 0.0
+
+    def to_dict(self) -> Dict:
+        return {
+            'total': self.total,
+            'passed': self.passed,
+            'failed': self.failed,
+            'skipped': self.skipped,
+            'mean_spec_time_delta': self.mean_spec_time_delta,
+            'stopped': self.stopped,
+            'started_at': self.started_at,
+            'finished_at': self.finished_at,
+        }
+
+
+@dataclass
+class RevalidateProgress:
+    """
+    One event of a run: a snippet's new result — or, as the last event,
+    the run's summary (every other field empty).
+    """
+    staging_id: str = ''
+    slot: str = ''                       # Engine letter
+    address: str = ''
+    label: str = ''
+    spec_result: str = ''                # This run's SpecResult value ('' = the run errored)
+    original_result: str = ''            # The spec_result it carried before the run
+    spec_time: float = 0.0
+    original_spec_time: float = 0.0
+    success: bool = False
+    error: str = ''
+    updated: bool = False                # The stored spec_* fields were overwritten
+    completed: int = 0                   # Snippets finished so far, this one included
+    total: int = 0
+    summary: Optional[RevalidateSummary] = None
+
+    @property
+    def spec_time_delta(self) -> float:
+        """New minus original spec_time (0 when the run itself errored)."""
+        return self.spec_time - self.original_spec_time if self.spec_result else 0.0
+
+    def to_dict(self) -> Dict:
+        if self.summary is not None:
+            return {'event': 'summary', **self.summary.to_dict()}
+        return {
+            'event': 'result',
+            'staging_id': self.staging_id,
+            'slot': self.slot,
+            'address': self.address,
+            'label': self.label,
+            'spec_result': self.spec_result,
+            'original_result': self.original_result,
+            'spec_time': self.spec_time,
+            'original_spec_time': self.original_spec_time,
+            'spec_time_delta': self.spec_time_delta,
+            'success': self.success,
+            'error': self.error,
+            'updated': self.updated,
+            'completed': self.completed,
+            'total': self.total,
+        }
+
+
+class RevalidateRun:
+    """
+    The event stream of one revalidate_all() call.  Iterating blocks for
+    the next event and ends after the summary; events are produced
+    whether or not anyone reads them.
+    """
+
+    def __init__(self, targets: List[str], run_one: Callable[[str], RevalidateProgress],
+                 options: RevalidateOptions):
+        self.options = options
+        self.total = len(targets)
+        self._targets = list(targets)
+        self._run_one = run_one
+        self._events: 'queue.Queue[RevalidateProgress]' = queue.Queue()
+        self._cancel = threading.Event()
+        self._done = threading.Event()
+        self._summary: Optional[RevalidateSummary] = None
+        self._thread = threading.Thread(target=self._drive, name='revalidate', daemon=True)
+
+    def start(self) -> 'RevalidateRun':
+        self._thread.start()
+        return self
+
+    def cancel(self):
+        """Start no more snippets; the ones running finish and are reported."""
+        self._cancel.set()
+
+    def __iter__(self) -> Iterator[RevalidateProgress]:
+        while True:
+            event = self._events.get()
+            yield event
+            if event.summary is not None:
+                return
+
+    def wait(self, timeout: Optional[float] = None) -> Optional[RevalidateSummary]:
+        """The summary once the run is over (None if `timeout` passed first)."""
+        self._done.wait(timeout)
+        return self._summary
+
+    def _drive(self):
+        summary = RevalidateSummary(total=self.total, started_at=time.time())
+        deltas: List[float] = []
+        pending = iter(self._targets)
+        with ThreadPoolExecutor(max_workers=self.options.concurrency,
+                                thread_name_prefix='revalidate') as pool:
+            running = set()
+
+            def fill():
+                while len(running) < self.options.concurrency and not summary.stopped:
+                    if self._cancel.is_set():
+                        summary.stopped = STOPPED_CANCELLED
+                        return
+                    staging_id = next(pending, None)
+                    if staging_id is None:
+                        return
+                    running.add(pool.submit(self._run_one, staging_id))
+
+            fill()
+            while running:
+                finished, _ = wait(running, return_when=FIRST_COMPLETED)
+                for future in finished:
+                    running.discard(future)
+                    event = future.result()
+                    if event.success:
+                        summary.passed += 1
+                    else:
+                        summary.failed += 1
+                        if self.options.fail_fast and not summary.stopped:
+                            summary.stopped = STOPPED_FAIL_FAST
+                    if event.spec_result:
+                        deltas.append(event.spec_time_delta)
+                    event.completed = summary.passed + summary.failed
+                    event.total = self.total
+                    self._events.put(event)
+                fill()
+
+        summary.skipped = self.total - summary.passed - summary.failed
+        summary.mean_spec_time_delta = sum(deltas) / len(deltas) if deltas else 0.0
+        summary.finished_at = time.time()
+        self._summary = summary
+        self._events.put(RevalidateProgress(total=self.total, completed=summary.passed
+                                            + summary.failed, summary=summary))
+        self._done.set()
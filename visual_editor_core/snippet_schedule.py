@@ -0,0 +1,272 @@
+"""
+Snippet Schedule — re-run promoted snippets on a cron schedule.
+
+A snippet is speculated once, before it is promoted.  Snippets that check
+live data (an upstream API, a file that changes) can go stale after
+that, so a slot may ask for its live entries to be re-run:
+
+    pipeline.configure_slot('i', SlotConfig(schedule='*/15 * * * *'))
+    scheduler = RerunScheduler(pipeline, interval=60)
+    scheduler.start()
+
+`schedule` is a five-field cron expression — minute, hour, day of month,
+month, day of week — evaluated in UTC.  Fields take `*`, numbers, ranges
+(`1-5`), lists (`1,15`) and steps (`*/10`, `0-30/5`); months and days
+also take names (`jan`, `mon`).  Sunday is 0 or 7.  As in cron, when both
+day fields are restricted a day matching either one fires.  The
+shorthands @hourly, @daily, @weekly, @monthly and @yearly work too.
+
+On each tick every PROMOTED snippet on a scheduled slot whose schedule
+fired since its last rerun (or its promotion) runs again, isolated, with
+the arguments it was speculated with.  The run never changes the
+snippet's phase or spec_* fields: its spec_result and spec_time go into
+the snippet's RerunHistory (the newest DEFAULT_RERUN_HISTORY kept) and
+last_rerun_at moves.  A rerun that fails — any result but PASS — right
+after one that passed (or, for the first rerun, after a passing
+speculation) is logged as HEALTH_ALERT and published to webhook targets
+subscribed to 'health_alert'.
+
+CronSchedule and due_for_rerun() are pure, so schedules can be tested
+without a pipeline; the RerunScheduler just calls
+run_scheduled_reruns() on a timer (tick() runs one pass synchronously).
+"""
+
+import calendar
+import threading
+import time
+from collections import deque
+from dataclasses import dataclass
+from datetime import datetime, timedelta, timezone
+from typing import Callable, Dict, FrozenSet, List, Optional
+
+
+DEFAULT_RERUN_INTERVAL = 60.0            # Seconds between scheduler ticks (cron resolution)
+DEFAULT_RERUN_HISTORY = 100              # Reruns kept per snippet
+
+# How far ahead next_after() looks before deciding a schedule never fires
+# (Feb 29 on a given weekday can be 28 years away)
+_SEARCH_YEARS = 30
+
+_MONTH_NAMES = {name.lower(): n for n, name in enumerate(calendar.month_abbr) if name}
+_DAY_NAMES = {'sun': 0, 'mon': 1, 'tue': 2, 'wed': 3, 'thu': 4, 'fri': 5, 'sat': 6}
+
+_SHORTHANDS = {
+    '@hourly':   '0 * * * *',
+    '@daily':    '0 0 * * *',
+    '@midnight': '0 0 * * *',
+    '@weekly':   '0 0 * * 0',
+    '@monthly':  '0 0 1 * *',
+    '@yearly':   '0 0 1 1 *',
+    '@annually': '0 0 1 1 *',
+}
+
+
+class CronError(ValueError):
+    """A schedule isn't a valid cron expression."""
+
+
+def _parse_field(text: str, low: int, high: int, names: Dict[str, int]) -> FrozenSet[int]:
+    values = set()
+    for part in text.split(','):
+        body, _, step_text = part.partition('/')
+        step = 1
+        if step_text:
+            if not step_text.isdigit() or int(step_text) < 1:
+                raise CronError(f"Bad step in '{part}'")
+            step = int(step_text)
+        if body == '*':
+            start, end = low, high
+        else:
+            first, dash, last = body.partition('-')
+            start = _parse_value(first, low, high, names)
+            end = _parse_value(last, low, high, names) if dash else (high if step_text else start)
+            if end < start:
+                raise CronError(f"Range '{body}' runs backwards")
+        values.update(range(start, end + 1, step))
+    return frozenset(values)
+
+
+def _parse_value(text: str, low: int, high: int, names: Dict[str, int]) -> int:
+    value = names.get(text.lower())
+    if value is None:
+        if not text.isdigit():
+            raise CronError(f"'{text}' is not a number")
+        value = int(text)
+    if not low <= value <= high:
+        raise CronError(f"{value} is outside {low}-{high}")
+    return value
+
+
+@dataclass(frozen=True)
+class CronSchedule:
+    """A parsed five-field cron expression (UTC)."""
+    expression: str
+    minutes: FrozenSet[int]
+    hours: FrozenSet[int]
+    days: FrozenSet[int]                 # Day of month, 1-31
+    months: FrozenSet[int]
+    weekdays: FrozenSet[int]             # 0 = Sunday
+    days_restricted: bool                # Day of month wasn't '*'
+    weekdays_restricted: bool
+
+    @classmethod
+    def parse(cls, expression: str) -> 'CronSchedule':
+        """Parse `expression`; raises CronError if it isn't valid."""
+        if not isinstance(expression, str) or not expression.strip():
+            raise CronError("A schedule must be a non-empty cron expression")
+        text = expression.strip()
+        fields = _SHORTHANDS.get(text.lower(), text).split()
+        if len(fields) != 5:
+            raise CronError(f"'{expression}' has {len(fields)} fields; cron needs 5 "
+                            f"(minute hour day-of-month month day-of-week)")
+        minute, hour, day, month, weekday = fields
+        try:
+            weekdays = _parse_field(weekday, 0, 7, _DAY_NAMES)
+            return cls(
+                expression=text,
+                minutes=_parse_field(minute, 0, 59, {}),
+                hours=_parse_field(hour, 0, 23, {}),
+                days=_parse_field(day, 1, 31, {}),
+                months=_parse_field(month, 1, 12, _MONTH_NAMES),
+                weekdays=frozenset(d % 7 for d in weekdays),
+                days_restricted=not day.startswith('*'),
+                weekdays_restricted=not weekday.startswith('*'),
+            )
+        except CronError as exc:
+            raise CronError(f"Bad schedule '{expression}': {exc}") from None
+
+    def _day_matches(self, moment: datetime) -> bool:
+        day_ok = moment.day in self.days
+        weekday_ok = (moment.weekday() + 1) % 7 in self.weekdays
+        if self.days_restricted and self.weekdays_restricted:
+            return day_ok or weekday_ok
+        return day_ok and weekday_ok
+
+    def matches(self, ts: float) -> bool:
+        """True if the schedule fires in the minute containing `ts`."""
+        moment = datetime.fromtimestamp(ts, timezone.utc)
+        return (moment.minute in self.minutes and moment.hour in self.hours
+                and moment.month in self.months and self._day_matches(moment))
+
+    def next_after(self, ts: float) -> Optional[float]:
+        """The first minute strictly after `ts` the schedule fires in (None if never)."""
+        moment = (datetime.fromtimestamp(ts, timezone.utc).replace(second=0, microsecond=0)
+                  + timedelta(minutes=1))
+        limit = moment.replace(year=moment.year + _SEARCH_YEARS, month=1, day=1)
+        while moment < limit:
+            if moment.month not in self.months:
+                year, month = divmod(moment.month, 12)
+                moment = moment.replace(year=moment.year + year, month=month + 1, day=1,
+                                        hour=0, minute=0)
+            elif not self._day_matches(moment):
+                moment = moment.replace(hour=0, minute=0) + timedelta(days=1)
+            elif moment.hour not in self.hours:
+                moment = moment.replace(minute=0) + timedelta(hours=1)
+            elif moment.minute not in self.minutes:
+                moment += timedelta(minutes=1)
+            else:
+                return moment.timestamp()
+        return None
+
+
+def due_for_rerun(schedule: CronSchedule, last_run: float, now: float) -> bool:
+    """True if `schedule` fired after `last_run` and at or before `now`."""
+    fire = schedule.next_after(last_run)
+    return fire is not None and fire <= now
+
+
+@dataclass
+class RerunRecord:
+    """One scheduled rerun of a promoted snippet."""
+    staging_id: str
+    ran_at: float
+    spec_result: str                     # SpecResult value ('PASS', 'FAIL', …)
+    spec_execution_time: float
+    success: bool
+    error: str = ''
+    health_alert: bool = False           # This rerun failed right after a pass
+
+    def to_dict(self) -> Dict:
+        return {
+            'staging_id': self.staging_id,
+            'ran_at': self.ran_at,
+            'spec_result': self.spec_result,
+            'spec_execution_time': self.spec_execution_time,
+            'success': self.success,
+            'error': self.error,
+            'health_alert': self.health_alert,
+        }
+
+
+class RerunHistory:
+    """The newest `size` RerunRecords of one snippet, oldest first."""
+
+    def __init__(self, size: int = DEFAULT_RERUN_HISTORY):
+        if size < 1:
+            raise ValueError("Rerun history size must be >= 1")
+        self._records = deque(maxlen=size)
+
+    def append(self, record: RerunRecord):
+        self._records.append(record)
+
+    def last(self) -> Optional[RerunRecord]:
+        return self._records[-1] if self._records else None
+
+    def records(self) -> List[RerunRecord]:
+        return list(self._records)
+
+    def __len__(self) -> int:
+        return len(self._records)
+
+
+class RerunScheduler:
+    """Runs a pipeline's scheduled reruns on a background thread."""
+
+    def __init__(self, pipeline, interval: float = DEFAULT_RERUN_INTERVAL,
+                 clock: Callable[[], float] = time.time):
+        if interval <= 0:
+            raise ValueError("interval must be > 0")
+        self._pipeline = pipeline
+        self._interval = interval
+        self._clock = clock
+        self._stop = threading.Event()
+        self._thread: Optional[threading.Thread] = None
+        self.last_tick_at = 0.0
+        self.reruns_total = 0
+        self.alerts_total = 0
+
+    def tick(self) -> List[RerunRecord]:
+        """Re-run every snippet whose slot schedule is due now; returns the reruns."""
+        now = self._clock()
+        records = self._pipeline.run_scheduled_reruns(now=now)
+        self.last_tick_at = now
+        self.reruns_total += len(records)
+        self.alerts_total += sum(1 for r in records if r.health_alert)
+        return records
+
+    def start(self):
+        if self._thread is None:
+            self._thread = threading.Thread(target=self._loop, daemon=True,
+                                            name='rerun-scheduler')
+            self._thread.start()
+
+    def close(self, timeout: Optional[float] = None):
+        self._stop.set()
+        if self._thread is not None:
+            self._thread.join(timeout)
+
+    def _loop(self):
+        while not self._stop.wait(self._interval):
+            try:
+                self.tick()
+            except Exception:
+                pass                                  # next tick retries
+
+    def status(self) -> Dict:
+        return {
+            'interval': self._interval,
+            'running': self._thread is not None and not self._stop.is_set(),
+            'last_tick_at': self.last_tick_at,
+            'reruns_total': self.reruns_total,
+            'alerts_total': self.alerts_total,
+        }
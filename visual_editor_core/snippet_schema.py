@@ -0,0 +1,299 @@
+"""
+Snippet Schema — a small JSON Schema validator for snippet data.
+
+    schema = load_schema('{"type": "object", "required": ["total"],'
+                         ' "properties": {"total": {"type": "integer", "minimum": 0}}}')
+    validate({'total': -1}, schema)
+        → [ValidationError('/total', 'must be >= 0')]
+
+Schemas are JSON Schema documents (a JSON string or the decoded dict).
+The subset understood covers the structural and range keywords:
+
+    type  enum  const                        any value
+    properties  required  additionalProperties
+    minProperties  maxProperties             objects
+    items  minItems  maxItems  uniqueItems   arrays
+    minLength  maxLength  pattern            strings
+    minimum  maximum  exclusiveMinimum
+    exclusiveMaximum  multipleOf             numbers
+    allOf  anyOf  oneOf  not                 combinators
+
+Annotations ($schema, $id, title, description, default, examples,
+$comment) are accepted and ignored.  Anything else — $ref included — is
+a SchemaError when the schema is loaded, so a schema never silently
+checks less than it says.
+
+Every ValidationError carries the JSON Pointer (RFC 6901) of the value
+that failed ('' is the document itself).  schema_hash() identifies a
+schema by its canonical JSON, independent of key order and whitespace.
+"""
+
+import hashlib
+import json
+import math
+import re
+from dataclasses import dataclass
+from typing import Any, Dict, List, Sequence
+
+
+_TYPES = ('object', 'array', 'string', 'integer', 'number', 'boolean', 'null')
+
+_ANNOTATIONS = {'$schema', '$id', '$comment', 'title', 'description', 'default', 'examples'}
+
+# keyword → what its value must be ('schema', 'schemas', 'props', …)
+_KEYWORDS = {
+    'type': 'type', 'enum': 'array', 'const': 'any',
+    'properties': 'props', 'required': 'names', 'additionalProperties': 'bool_or_schema',
+    'minProperties': 'count', 'maxProperties': 'count',
+    'items': 'schema', 'minItems': 'count', 'maxItems': 'count', 'uniqueItems': 'bool',
+    'minLength': 'count', 'maxLength': 'count', 'pattern': 'regex',
+    'minimum': 'number', 'maximum': 'number',
+    'exclusiveMinimum': 'number', 'exclusiveMaximum': 'number', 'multipleOf': 'positive',
+    'allOf': 'schemas', 'anyOf': 'schemas', 'oneOf': 'schemas', 'not': 'schema',
+}
+
+
+class SchemaError(ValueError):
+    """The schema itself is malformed or uses an unsupported keyword."""
+
+
+@dataclass(frozen=True)
+class ValidationError:
+    """One way a value fails its schema."""
+    path: str                            # JSON Pointer to the failing value
+    message: str
+
+    def to_dict(self) -> Dict[str, str]:
+        return {'path': self.path, 'message': self.message}
+
+    def __str__(self) -> str:
+        return f"{self.path or '/'}: {self.message}"
+
+
+def load_schema(schema) -> Dict[str, Any]:
+    """The schema as a dict, checked; SchemaError if it isn't a usable one."""
+    if isinstance(schema, (bytes, bytearray)):
+        schema = schema.decode('utf-8')
+    if isinstance(schema, str):
+        try:
+            schema = json.loads(schema)
+        except ValueError as exc:
+            raise SchemaError(f"Schema is not valid JSON: {exc}") from None
+    _check_schema(schema, '')
+    return schema
+
+
+def schema_hash(schema) -> str:
+    """sha256 of the schema's canonical JSON ('' for no schema)."""
+    if schema is None or schema == '':
+        return ''
+    canonical = json.dumps(load_schema(schema), sort_keys=True, separators=(',', ':'))
+    return hashlib.sha256(canonical.encode('utf-8')).hexdigest()
+
+
+def pointer(parts: Sequence) -> str:
+    """JSON Pointer for a path of keys and indexes."""
+    return ''.join('/' + str(p).replace('~', '~0').replace('/', '~1') for p in parts)
+
+
+def validate(instance: Any, schema) -> List[ValidationError]:
+    """Every way `instance` fails `schema` (an empty list means it conforms)."""
+    if not isinstance(schema, (dict, bool)):
+        schema = load_schema(schema)
+    errors: List[ValidationError] = []
+    _validate(instance, schema, [], errors)
+    return errors
+
+
+# ── Schema checks ─────────────────────────────────────────────────────────
+
+def _check_schema(schema, at: str):
+    if isinstance(schema, bool):
+        return
+    if not isinstance(schema, dict):
+        raise SchemaError(f"Schema{at and ' at ' + at} must be an object or a boolean")
+    for key, value in schema.items():
+        if key in _ANNOTATIONS:
+            continue
+        kind = _KEYWORDS.get(key)
+        where = f"{at}/{key}"
+        if kind is None:
+            raise SchemaError(f"Unsupported schema keyword '{key}' at {where}")
+        if kind == 'type':
+            names = value if isinstance(value, list) else [value]
+            if not names or any(n not in _TYPES for n in names):
+                raise SchemaError(f"{where} must name types from: {', '.join(_TYPES)}")
+        elif kind == 'array' and not isinstance(value, list):
+            raise SchemaError(f"{where} must be an array")
+        elif kind == 'props':
+            if not isinstance(value, dict):
+                raise SchemaError(f"{where} must be an object")
+            for name, sub in value.items():
+                _check_schema(sub, f"{where}/{name}")
+        elif kind == 'names' and (not isinstance(value, list)
+                                  or not all(isinstance(n, str) for n in value)):
+            raise SchemaError(f"{where} must be an array of property names")
+        elif kind in ('schema', 'bool_or_schema'):
+            _check_schema(value, where)
+        elif kind == 'schemas':
+            if not isinstance(value, list) or not value:
+                raise SchemaError(f"{where} must be a non-empty array of schemas")
+            for i, sub in enumerate(value):
+                _check_schema(sub, f"{where}/{i}")
+        elif kind == 'count' and (isinstance(value, bool) or not isinstance(value, int)
+                                  or value < 0):
+            raise SchemaError(f"{where} must be an integer >= 0")
+        elif kind == 'bool' and not isinstance(value, bool):
+            raise SchemaError(f"{where} must be a boolean")
+        elif kind in ('number', 'positive') and not _is_number(value):
+            raise SchemaError(f"{where} must be a number")
+        elif kind == 'positive' and value <= 0:
+            raise SchemaError(f"{where} must be > 0")
+        elif kind == 'regex':
+            try:
+                re.compile(value)
+            except (TypeError, re.error) as exc:
+                raise SchemaError(f"{where} is not a valid pattern: {exc}") from None
+
+
+# ── Validation ────────────────────────────────────────────────────────────
+
+def _is_number(value) -> bool:
+    return isinstance(value, (int, float)) and not isinstance(value, bool)
+
+
+def _type_of(value) -> str:
+    if value is None:
+        return 'null'
+    if isinstance(value, bool):
+        return 'boolean'
+    if isinstance(value, int):
+        return 'integer'
+    if isinstance(value, float):
+        return 'integer' if value.is_integer() else 'number'
+    if isinstance(value, str):
+        return 'string'
+    if isinstance(value, (list, tuple)):
+        return 'array'
+    if isinstance(value, dict):
+        return 'object'
+    return type(value).__name__
+
+
+def _matches_type(value, name: str) -> bool:
+    actual = _type_of(value)
+    return actual == name or (name == 'number' and actual == 'integer')
+
+
+def _equal(a, b) -> bool:
+    """JSON equality: 1 == 1.0, but True != 1."""
+    if isinstance(a, bool) or isinstance(b, bool):
+        return type(a) is type(b) and a == b
+    if isinstance(a, dict) and isinstance(b, dict):
+        return a.keys() == b.keys() and all(_equal(a[k], b[k]) for k in a)
+    if isinstance(a, (list, tuple)) and isinstance(b, (list, tuple)):
+        return len(a) == len(b) and all(_equal(x, y) for x, y in zip(a, b))
+    return a == b
+
+
+def _validate(value, schema, path: list, errors: List[ValidationError]):
+    at = pointer(path)
+    if schema is True:
+        return
+    if schema is False:
+        errors.append(ValidationError(at, 'no value is allowed here'))
+        return
+
+    if 'type' in schema:
+        names = schema['type'] if isinstance(schema['type'], list) else [schema['type']]
+        if not any(_matches_type(value, n) for n in names):
+            errors.append(ValidationError(
+                at, f"expected {' or '.join(names)}, got {_type_of(value)}"))
+            return                       # the remaining keywords would only repeat it
+    if 'enum' in schema and not any(_equal(value, e) for e in schema['enum']):
+        errors.append(ValidationError(
+            at, f"must be one of: {', '.join(json.dumps(e) for e in schema['enum'])}"))
+    if 'const' in schema and not _equal(value, schema['const']):
+        errors.append(ValidationError(at, f"must be {json.dumps(schema['const'])}"))
+
+    if isinstance(value, dict):
+        _validate_object(value, schema, path, errors)
+    elif isinstance(value, (list, tuple)):
+        _validate_array(value, schema, path, errors)
+    elif isinstance(value, str):
+        _validate_string(value, schema, at, errors)
+    elif _is_number(value):
+        _validate_number(value, schema, at, errors)
+
+    for sub in schema.get('allOf', ()):
+        _validate(value, sub, path, errors)
+    if 'anyOf' in schema and not any(not validate(value, s) for s in schema['anyOf']):
+        errors.append(ValidationError(at, 'must match at least one schema in anyOf'))
+    if 'oneOf' in schema:
+        matched = sum(1 for s in schema['oneOf'] if not validate(value, s))
+        if matched != 1:
+            errors.append(ValidationError(
+                at, f"must match exactly one schema in oneOf (matched {matched})"))
+    if 'not' in schema and not validate(value, schema['not']):
+        errors.append(ValidationError(at, "must not match the schema in 'not'"))
+
+
+def _validate_object(value: dict, schema, path, errors):
+    at = pointer(path)
+    for name in schema.get('required', ()):
+        if name not in value:
+            errors.append(ValidationError(at, f"missing required property '{name}'"))
+    properties = schema.get('properties', {})
+    additional = schema.get('additionalProperties', True)
+    for name, item in value.items():
+        if name in properties:
+            _validate(item, properties[name], path + [name], errors)
+        elif additional is False:
+            errors.append(ValidationError(pointer(path + [name]), 'unexpected property'))
+        elif isinstance(additional, dict):
+            _validate(item, additional, path + [name], errors)
+    if 'minProperties' in schema and len(value) < schema['minProperties']:
+        errors.append(ValidationError(at, f"must have at least {schema['minProperties']} "
+                                          f"properties"))
+    if 'maxProperties' in schema and len(value) > schema['maxProperties']:
+        errors.append(ValidationError(at, f"must have at most {schema['maxProperties']} "
+                                          f"properties"))
+
+
+def _validate_array(value, schema, path, errors):
+    at = pointer(path)
+    if 'items' in schema:
+        for i, item in enumerate(value):
+            _validate(item, schema['items'], path + [i], errors)
+    if 'minItems' in schema and len(value) < schema['minItems']:
+        errors.append(ValidationError(at, f"must have at least {schema['minItems']} items"))
+    if 'maxItems' in schema and len(value) > schema['maxItems']:
+        errors.append(ValidationError(at, f"must have at most {schema['maxItems']} items"))
+    if schema.get('uniqueItems'):
+        for i, item in enumerate(value):
+            if any(_equal(item, earlier) for earlier in value[:i]):
+                errors.append(ValidationError(pointer(path + [i]), 'duplicate item'))
+
+
+def _validate_string(value: str, schema, at, errors):
+    if 'minLength' in schema and len(value) < schema['minLength']:
+        errors.append(ValidationError(at, f"must be at least {schema['minLength']} characters"))
+    if 'maxLength' in schema and len(value) > schema['maxLength']:
+        errors.append(ValidationError(at, f"must be at most {schema['maxLength']} characters"))
+    if 'pattern' in schema and not re.search(schema['pattern'], value):
+        errors.append(ValidationError(at, f"must match pattern {schema['pattern']!r}"))
+
+
+def _validate_number(value, schema, at, errors):
+    if 'minimum' in schema and value < schema['minimum']:
+        errors.append(ValidationError(at, f"must be >= {schema['minimum']}"))
+    if 'maximum' in schema and value > schema['maximum']:
+        errors.append(ValidationError(at, f"must be <= {schema['maximum']}"))
+    if 'exclusiveMinimum' in schema and value <= schema['exclusiveMinimum']:
+        errors.append(ValidationError(at, f"must be > {schema['exclusiveMinimum']}"))
+    if 'exclusiveMaximum' in schema and value >= schema['exclusiveMaximum']:
+        errors.append(ValidationError(at, f"must be < {schema['exclusiveMaximum']}"))
+    if 'multipleOf' in schema:
+        quotient = value / schema['multipleOf']
+        if not math.isclose(quotient, round(quotient), rel_tol=0, abs_tol=1e-9):
+            errors.append(ValidationError(at, f"must be a multiple of {schema['multipleOf']}"))
@@ -0,0 +1,178 @@
+"""
+Snippet Slot Diff — what a slot would look like after a set of promotions.
+
+Before promoting several staged snippets together, a release job can ask
+for the plan:
+
+    report = pipeline.slot_diff('i', ['stg-a', 'stg-b', 'stg-c'])
+    report.added            # ['report']          new to the slot
+    report.updated          # ['fib']             source changed
+    report.unchanged        # ['mathutils']       live, same source
+    report.size_delta       # +412 bytes
+    report.cycle            # ['fib', 'report', 'fib'] if the requires would loop
+
+The pending snippets are applied in the order given, each the way
+_promote_locked() would apply it under its label policy: OVERWRITE
+replaces the live version of its label, VERSION_SUFFIX labels are new by
+construction, and a REJECT label that is (or would become) live raises
+LabelConflictError.  Two pending snippets with one label leave the later
+one live.
+
+`removed` lists labels live now that would not be afterwards.  None of
+the label policies takes a label off a slot on promotion, so for now it
+is always empty.
+
+Sizes are the snippets' source bytes, counted as the slot's capacity
+limits count them.  `cycle` is the requires chain the planned state
+would close (see snippet_depgraph) — one that is already in the live
+state is reported as `existing_cycle` instead — and `missing` lists
+labels that would be required without being live.
+
+This is a read-only plan: nothing is speculated or promoted and no
+promotion lock is taken, so a promotion racing it can make it stale.
+"""
+
+from dataclasses import dataclass, field
+from typing import Dict, Iterable, List
+
+from .snippet_depgraph import DepGraph
+from .snippet_deps import CyclicDependencyError
+
+
+@dataclass
+class LabelPlan:
+    """One label's entry before and after the plan ('' = absent)."""
+    label: str
+    before_id: str = ''
+    after_id: str = ''
+    before_hash: str = ''
+    after_hash: str = ''
+    before_bytes: int = 0
+    after_bytes: int = 0
+
+    @property
+    def change(self) -> str:
+        if not self.before_id:
+            return 'added'
+        if not self.after_id:
+            return 'removed'
+        return 'unchanged' if self.before_hash == self.after_hash else 'updated'
+
+    def to_dict(self) -> Dict:
+        return {
+            'label': self.label,
+            'change': self.change,
+            'before_id': self.before_id,
+            'after_id': self.after_id,
+            'before_hash': self.before_hash,
+            'after_hash': self.after_hash,
+            'before_bytes': self.before_bytes,
+            'after_bytes': self.after_bytes,
+        }
+
+
+@dataclass
+class SlotDiffReport:
+    """The planned state of a slot if `pending_ids` were all promoted."""
+    slot: str
+    namespace: str
+    pending_ids: List[str]
+    labels: Dict[str, LabelPlan] = field(default_factory=dict)
+    cycle: List[str] = field(default_factory=list)           # Introduced by the plan
+    existing_cycle: List[str] = field(default_factory=list)  # Already in the live state
+    missing: List[str] = field(default_factory=list)         # Required, not live after
+
+    def _with(self, change: str) -> List[str]:
+        return sorted(label for label, plan in self.labels.items() if plan.change == change)
+
+    @property
+    def added(self) -> List[str]:
+        return self._with('added')
+
+    @property
+    def removed(self) -> List[str]:
+        return self._with('removed')
+
+    @property
+    def updated(self) -> List[str]:
+        return self._with('updated')
+
+    @property
+    def unchanged(self) -> List[str]:
+        return self._with('unchanged')
+
+    @property
+    def size_before(self) -> int:
+        return sum(p.before_bytes for p in self.labels.values())
+
+    @property
+    def size_after(self) -> int:
+        return sum(p.after_bytes for p in self.labels.values())
+
+    @property
+    def size_delta(self) -> int:
+        return self.size_after - self.size_before
+
+    @property
+    def introduces_cycle(self) -> bool:
+        return bool(self.cycle)
+
+    def to_dict(self) -> Dict:
+        return {
+            'slot': self.slot,
+            'namespace': self.namespace,
+            'pending_ids': list(self.pending_ids),
+            'added': self.added,
+            'removed': self.removed,
+            'updated': self.updated,
+            'unchanged': self.unchanged,
+            'size_before': self.size_before,
+            'size_after': self.size_after,
+            'size_delta': self.size_delta,
+            'introduces_cycle': self.introduces_cycle,
+            'cycle': list(self.cycle),
+            'existing_cycle': list(self.existing_cycle),
+            'missing': list(self.missing),
+            'labels': [self.labels[label].to_dict() for label in sorted(self.labels)],
+        }
+
+
+def _size(snippet) -> int:
+    return len(snippet.code.encode('utf-8'))
+
+
+def _cycle(slot: str, namespace: str, snippets: Iterable) -> List[str]:
+    try:
+        DepGraph.build(slot, namespace, snippets)
+    except CyclicDependencyError as exc:
+        return exc.chain
+    return []
+
+
+def plan(slot: str, namespace: str, live: Dict[str, object],
+         pending: List) -> SlotDiffReport:
+    """
+    The report for promoting `pending` (StagedSnippets, in order) onto a
+    slot whose live entries are `live` (label → newest StagedSnippet).
+    Label policies must already have been checked.
+    """
+    after = dict(live)
+    for snippet in pending:
+        after[snippet.label] = snippet
+    report = SlotDiffReport(slot, namespace, [s.staging_id for s in pending])
+    for label in set(live) | set(after):
+        entry = LabelPlan(label)
+        if label in live:
+            old = live[label]
+            entry.before_id, entry.before_hash, entry.before_bytes = (
+                old.staging_id, old.code_hash, _size(old))
+        if label in after:
+            new = after[label]
+            entry.after_id, entry.after_hash, entry.after_bytes = (
+                new.staging_id, new.code_hash, _size(new))
+        report.labels[label] = entry
+    report.existing_cycle = _cycle(slot, namespace, live.values())
+    if not report.existing_cycle:
+        report.cycle = _cycle(slot, namespace, after.values())
+    report.missing = sorted({dep for s in after.values() for dep in s.requires} - set(after))
+    return report
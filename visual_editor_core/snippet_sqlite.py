@@ -0,0 +1,390 @@
+"""
+Snippet SQLite Index — persistent metadata records behind pipeline.query().
+
+The in-memory index forgets everything on restart and once a snippet
+ages out of history.  SQLiteSnippetIndex keeps one row per snippet in a
+SQLite database instead:
+
+    languages (id, name)                         ◄─┐
+    slots     (id, engine_letter, engine, position) ◄─┤
+    snippets  (staging_id, language_id, slot_id, ─────┘
+               label, code_hash, created_at, promoted_at,
+               spec_time_ms, spec_result, source_path, namespace, env_hash,
+               archived_at)
+    snippet_tags (staging_id, tag)               ── snippet_tags paths
+
+The schema lives in snippet_migrations/NNNN_<name>.sql.  Opening an index
+applies, in order and each in its own transaction, every migration not
+yet recorded in `schema_migrations`, so an existing database is upgraded
+in place.
+
+Query results are the pipeline's live StagedSnippets while it still
+holds them (the index is bound to the pipeline), and SnippetRecords —
+the persisted columns only — for snippets from earlier runs.
+"""
+
+import os
+import re
+import sqlite3
+import threading
+import time
+from dataclasses import dataclass, asdict, field
+from functools import lru_cache
+from typing import Callable, Dict, List, Optional, Tuple
+
+from .snippet_query import (
+    SnippetIndex, SnippetFilter, QueryPage, encode_page_token, decode_page_token,
+)
+from .snippet_namespace import DEFAULT_NAMESPACE
+from .snippet_tags import SEPARATOR, TagPattern, parse_tag_query
+
+
+MIGRATIONS_DIR = os.path.join(os.path.dirname(os.path.abspath(__file__)), 'snippet_migrations')
+
+_MIGRATION_FILE = re.compile(r'^(\d+)_([A-Za-z0-9_]+)\.sql$')
+
+
+class MigrationError(RuntimeError):
+    """A schema migration failed to apply (the database is left at the prior version)."""
+
+
+@dataclass
+class SnippetRecord:
+    """Persisted metadata for one snippet."""
+    staging_id: str
+    language: str
+    engine: str                              # EngineID name, e.g. 'GO'
+    slot: str                                # Engine letter, e.g. 'i'
+    position: int
+    label: str
+    code_hash: str
+    created_at: float
+    promoted_at: Optional[float] = None
+    spec_time_ms: Optional[int] = None
+    spec_result: str = 'FAIL'
+    source_path: str = ''
+    namespace: str = DEFAULT_NAMESPACE
+    env_hash: str = ''                       # snippet_env.env_hash() of its injected env
+    archived_at: Optional[float] = None      # NULL until an ArchivalPolicy archives it
+    tags: List[str] = field(default_factory=list)
+
+    @property
+    def engine_letter(self) -> str:
+        return self.slot
+
+    @property
+    def reserved_address(self) -> str:
+        return f"{self.slot}{self.position}"
+
+    @classmethod
+    def from_snippet(cls, snippet) -> 'SnippetRecord':
+        spec_ran = bool(snippet.spec_completed_at)
+        return cls(
+            staging_id=snippet.staging_id,
+            language=snippet.language,
+            engine=snippet.reserved_engine,
+            slot=snippet.engine_letter,
+            position=snippet.reserved_position,
+            label=snippet.label,
+            code_hash=snippet.code_hash,
+            created_at=snippet.created_at,
+            promoted_at=snippet.promoted_at or None,
+            spec_time_ms=round(snippet.spec_execution_time * 1000) if spec_ran else None,
+            spec_result=snippet.spec_result.value,
+            source_path=snippet.saved_file_path,
+            namespace=snippet.namespace,
+            env_hash=snippet.env_hash,
+            archived_at=snippet.archived_at or None,
+            tags=list(snippet.tags),
+        )
+
+    def to_dict(self) -> Dict:
+        d = asdict(self)
+        d['reserved_address'] = self.reserved_address
+        return d
+
+
+def load_migrations(directory: str = MIGRATIONS_DIR) -> List[Tuple[int, str, str]]:
+    """(version, name, sql) for every migration file, in version order."""
+    migrations = []
+    for filename in os.listdir(directory):
+        m = _MIGRATION_FILE.match(filename)
+        if not m:
+            continue
+        with open(os.path.join(directory, filename), 'r', encoding='utf-8') as f:
+            migrations.append((int(m.group(1)), m.group(2), f.read()))
+    migrations.sort()
+    versions = [v for v, _, _ in migrations]
+    if len(set(versions)) != len(versions):
+        raise MigrationError(f"Duplicate migration versions in {directory}")
+    return migrations
+
+
+def migrate(conn: sqlite3.Connection, directory: str = MIGRATIONS_DIR) -> List[int]:
+    """Apply pending migrations; returns the versions applied."""
+    conn.execute('''
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version     INTEGER PRIMARY KEY,
+            name        TEXT NOT NULL,
+            applied_at  REAL NOT NULL
+        )
+    ''')
+    conn.commit()
+    applied = {row[0] for row in conn.execute('SELECT version FROM schema_migrations')}
+    newly = []
+    for version, name, sql in load_migrations(directory):
+        if version in applied:
+            continue
+        try:
+            conn.execute('BEGIN')
+            for statement in _split_statements(sql):
+                conn.execute(statement)
+            conn.execute('INSERT INTO schema_migrations (version, name, applied_at) '
+                         'VALUES (?, ?, ?)', (version, name, time.time()))
+            conn.commit()
+        except sqlite3.Error as exc:
+            conn.rollback()
+            raise MigrationError(f"Migration {version:04d}_{name} failed: {exc}") from exc
+        newly.append(version)
+    return newly
+
+
+def _split_statements(sql: str) -> List[str]:
+    # executescript() would COMMIT mid-migration; run statement by statement instead
+    statements, buffer = [], ''
+    for line in sql.splitlines(keepends=True):
+        if line.lstrip().startswith('--') and not buffer.strip():
+            continue
+        buffer += line
+        if sqlite3.complete_statement(buffer):
+            statements.append(buffer.strip())
+            buffer = ''
+    if buffer.strip():
+        statements.append(buffer.strip())
+    return statements
+
+
+class SQLiteSnippetIndex(SnippetIndex):
+    """SnippetIndex persisted to SQLite; thread-safe."""
+
+    persistent = True
+
+    def __init__(self, path: str, migrations_dir: str = MIGRATIONS_DIR):
+        if path != ':memory:':
+            os.makedirs(os.path.dirname(os.path.abspath(path)), exist_ok=True)
+        self._path = path
+        self._lock = threading.Lock()
+        self._conn = sqlite3.connect(path, check_same_thread=False, isolation_level=None)
+        self._conn.row_factory = sqlite3.Row
+        if path != ':memory:':
+            self._conn.execute('PRAGMA journal_mode=WAL')
+        self._conn.create_function('tag_match', 2, _tag_match, deterministic=True)
+        self._conn.execute('PRAGMA foreign_keys=ON')
+        with self._lock:
+            migrate(self._conn, migrations_dir)
+        self._resolve: Optional[Callable[[str], object]] = None
+
+    def bind(self, pipeline):
+        self._resolve = pipeline.get_snippet
+
+    @property
+    def path(self) -> str:
+        return self._path
+
+    def schema_version(self) -> int:
+        with self._lock:
+            row = self._conn.execute('SELECT MAX(version) FROM schema_migrations').fetchone()
+        return row[0] or 0
+
+    # ── CRUD ─────────────────────────────────────────────────────────
+
+    def put(self, snippet):
+        record = snippet if isinstance(snippet, SnippetRecord) else SnippetRecord.from_snippet(snippet)
+        with self._lock:
+            conn = self._conn
+            conn.execute('BEGIN')
+            try:
+                language_id = self._lookup_id(
+                    'SELECT id FROM languages WHERE name = ?',
+                    'INSERT INTO languages (name) VALUES (?)', (record.language,))
+                slot_id = self._lookup_id(
+                    'SELECT id FROM slots WHERE engine_letter = ? AND position = ?',
+                    'INSERT INTO slots (engine_letter, position) VALUES (?, ?)',
+                    (record.slot, record.position))
+                if record.engine:
+                    conn.execute('UPDATE slots SET engine = ? WHERE id = ?',
+                                 (record.engine, slot_id))
+                conn.execute('''
+                    INSERT INTO snippets (staging_id, language_id, slot_id, label, code_hash,
+                                          created_at, promoted_at, spec_time_ms,
+                                          spec_result, source_path, namespace, env_hash,
+                                          archived_at)
+                    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+                    ON CONFLICT (staging_id) DO UPDATE SET
+                        language_id = excluded.language_id,
+                        slot_id = excluded.slot_id,
+                        label = excluded.label,
+                        code_hash = excluded.code_hash,
+                        created_at = excluded.created_at,
+                        promoted_at = excluded.promoted_at,
+                        spec_time_ms = excluded.spec_time_ms,
+                        spec_result = excluded.spec_result,
+                        source_path = excluded.source_path,
+                        namespace = excluded.namespace,
+                        env_hash = excluded.env_hash,
+                        archived_at = excluded.archived_at
+                ''', (record.staging_id, language_id, slot_id, record.label, record.code_hash,
+                      record.created_at, record.promoted_at, record.spec_time_ms,
+                      record.spec_result, record.source_path, record.namespace,
+                      record.env_hash, record.archived_at))
+                conn.execute('DELETE FROM snippet_tags WHERE staging_id = ?',
+                             (record.staging_id,))
+                conn.executemany('INSERT INTO snippet_tags (staging_id, tag) VALUES (?, ?)',
+                                 [(record.staging_id, tag) for tag in record.tags])
+                conn.execute('COMMIT')
+            except BaseException:
+                conn.execute('ROLLBACK')
+                raise
+
+    def _lookup_id(self, select_sql: str, insert_sql: str, params: tuple) -> int:
+        row = self._conn.execute(select_sql, params).fetchone()
+        if row is not None:
+            return row[0]
+        return self._conn.execute(insert_sql, params).lastrowid
+
+    def get(self, staging_id: str) -> Optional[SnippetRecord]:
+        with self._lock:
+            row = self._conn.execute(_SELECT + ' WHERE s.staging_id = ?',
+                                     (staging_id,)).fetchone()
+        return _record(row) if row is not None else None
+
+    def remove(self, staging_id: str):
+        with self._lock:
+            self._conn.execute('DELETE FROM snippets WHERE staging_id = ?', (staging_id,))
+
+    def query(self, snippet_filter: SnippetFilter) -> QueryPage:
+        where, params = [], []
+        f = snippet_filter
+        if f.language:
+            where.append('l.name = ?')
+            params.append(f.language)
+        if f.label:
+            where.append('instr(lower(s.label), ?) > 0')
+            params.append(f.label.lower())
+        if f.slot:
+            where.append('p.engine_letter = ?')
+            params.append(f.slot)
+        if f.spec_result:
+            where.append('s.spec_result = ?')
+            params.append(f.spec_result)
+        if f.created_after:
+            where.append('s.created_at > ?')
+            params.append(f.created_after)
+        if f.created_before:
+            where.append('s.created_at < ?')
+            params.append(f.created_before)
+        if f.code_hash_prefix:
+            where.append('substr(s.code_hash, 1, ?) = ?')
+            params += [len(f.code_hash_prefix), f.code_hash_prefix]
+        if f.namespace:
+            where.append('s.namespace = ?')
+            params.append(f.namespace)
+        if f.tags:
+            groups = []
+            for group in parse_tag_query(f.tags).any_of:
+                clauses = [_tag_clause(pattern, params) for pattern in group]
+                groups.append('(' + ' AND '.join(clauses) + ')')
+            where.append('(' + ' OR '.join(groups) + ')')
+        if not f.include_archived:
+            where.append('s.archived_at IS NULL')
+        if f.page_token:
+            created_at, staging_id = decode_page_token(f.page_token)
+            where.append('(s.created_at > ? OR (s.created_at = ? AND s.staging_id > ?))')
+            params += [created_at, created_at, staging_id]
+
+        sql = _SELECT
+        if where:
+            sql += ' WHERE ' + ' AND '.join(where)
+        sql += ' ORDER BY s.created_at, s.staging_id LIMIT ?'
+        params.append(f.limit + 1)                # one extra row = there is a next page
+        with self._lock:
+            rows = self._conn.execute(sql, params).fetchall()
+
+        records = [_record(row) for row in rows[:f.limit]]
+        token = ''
+        if len(rows) > f.limit:
+            last = records[-1]
+            token = encode_page_token(last.created_at, last.staging_id)
+        return QueryPage([self._live(r) for r in records], token)
+
+    def _live(self, record: SnippetRecord):
+        snippet = self._resolve(record.staging_id) if self._resolve else None
+        return snippet if snippet is not None else record
+
+    def __len__(self) -> int:
+        with self._lock:
+            return self._conn.execute('SELECT COUNT(*) FROM snippets').fetchone()[0]
+
+    def close(self):
+        with self._lock:
+            self._conn.close()
+
+
+_SELECT = '''
+    SELECT s.staging_id, l.name AS language, p.engine, p.engine_letter, p.position,
+           s.label, s.code_hash, s.created_at, s.promoted_at, s.spec_time_ms,
+           s.spec_result, s.source_path, s.namespace, s.env_hash, s.archived_at,
+           (SELECT group_concat(t.tag, ' ') FROM snippet_tags t
+             WHERE t.staging_id = s.staging_id) AS tags
+      FROM snippets s
+      JOIN languages l ON l.id = s.language_id
+      JOIN slots p     ON p.id = s.slot_id
+'''
+
+
+def _record(row) -> SnippetRecord:
+    return SnippetRecord(
+        staging_id=row['staging_id'],
+        language=row['language'],
+        engine=row['engine'],
+        slot=row['engine_letter'],
+        position=row['position'],
+        label=row['label'],
+        code_hash=row['code_hash'],
+        created_at=row['created_at'],
+        promoted_at=row['promoted_at'],
+        spec_time_ms=row['spec_time_ms'],
+        spec_result=row['spec_result'],
+        source_path=row['source_path'],
+        namespace=row['namespace'],
+        env_hash=row['env_hash'],
+        archived_at=row['archived_at'],
+        tags=sorted((row['tags'] or '').split()),
+    )
+
+
+def _tag_clause(pattern: TagPattern, params: list) -> str:
+    """`s.staging_id IN (…)` for one pattern, driven by idx_snippet_tags_tag."""
+    if pattern.exact:
+        params.append(pattern.text)
+        return 's.staging_id IN (SELECT staging_id FROM snippet_tags WHERE tag = ?)'
+    conditions = []
+    prefix = pattern.literal_prefix
+    if prefix:
+        # prefix itself, or prefix/… ('0' sorts right after the separator)
+        conditions.append('(tag = ? OR (tag > ? AND tag < ?))')
+        params += [prefix, prefix + SEPARATOR, prefix + chr(ord(SEPARATOR) + 1)]
+    if pattern.subtree is None:
+        conditions.append('tag_match(?, tag)')
+        params.append(pattern.text)
+    where = ' AND '.join(conditions) or '1'
+    return f's.staging_id IN (SELECT staging_id FROM snippet_tags WHERE {where})'
+
+
+@lru_cache(maxsize=256)
+def _parsed(pattern: str) -> TagPattern:
+    return TagPattern.parse(pattern)
+
+
+def _tag_match(pattern: str, tag: str) -> bool:
+    return _parsed(pattern).matches(tag)
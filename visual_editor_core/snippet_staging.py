@@ -20,7 +20,7 @@ the engine tab system:
     │    If manual → hold for human approval                      │
     │                                                              │
     │  Phase 4: PROMOTE TO PRODUCTION                             │
-    │    • Code written to `snippets/<lang>/<staging_id>.ext`     │
+    │    • Source stored once, by code_hash, in the snippet store │
     │    • Synthetic node created in the SessionLedger            │
     │    • Node committed to the NodeRegistry (reserved slot)     │
     │    • Full audit trail: staging log, speculative output,     │
@@ -32,30 +32,99 @@ Path is configurable via:  DB setting → SPOKEDPY_AUDIT_LOG env → data/stagin
 
 The staging folder structure (configurable via DB setting → SPOKEDPY_SNIPPETS_DIR env → data/snippets/):
     data/snippets/
-        python/    → .py files
-        javascript/ → .js files
-        typescript/ → .ts files
-        java/      → .java files
-        go/        → .go files
-        csharp/    → .cs files
-        bash/      → .sh files
-        rust/      → .rs files
-        ...
+        .objects/  → content-addressable source payloads (see snippet_store),
+                     the only on-disk copy of a promoted snippet's body
 """
 
 import os
+import re
 import json
 import time
+import logging
 import uuid
 import hashlib
-import shutil
+import itertools
+import collections
 import threading
 import traceback
 from enum import Enum
-from dataclasses import dataclass, field, asdict
-from typing import Any, Dict, List, Optional, Tuple
+from dataclasses import dataclass, field, asdict, replace
+from typing import Any, Callable, Dict, List, Optional, Tuple
 from pathlib import Path
 
+from .snippet_store import SnippetStore, FileSnippetStore, PayloadNotFoundError, compute_code_hash
+from .snippet_history import PromotionHistory, DEFAULT_HISTORY_DEPTH
+from .snippet_capacity import SlotConfig, choose_victims
+from .snippet_depgraph import DepGraph
+from .snippet_isolation import (
+    IsolationLevel, IsolationStrategy, NoIsolation, select_strategy, warn_not_isolatable,
+)
+from .snippet_webhooks import WebhookDispatcher, WebhookTarget
+from .snippet_lint import SnippetLinter, LintFailedError
+from .snippet_metrics import PipelineMetrics, DEFAULT_METRICS
+from .snippet_query import (
+    SnippetIndex, InMemorySnippetIndex, SnippetFilter, QueryPage, MAX_PAGE_SIZE,
+)
+from .snippet_engines import EngineRegistry, Engine, RunOptions, DEFAULT_ENGINES
+from .snippet_tracing import span, traced
+from .snippet_diff import VersionRecord, DiffPreview, DiffResult, diff, preview
+from .snippet_equivalence import equivalent
+from .snippet_breaker import CircuitBreaker, BreakerState, CircuitOpenError
+from .snippet_canary import CanaryController, CanaryConfig, CanaryRollout, CanaryStatus
+from .snippet_swap import ExecutionLease, GracefulSwap, SlotSwap, SwapController, SwapStatus
+from .snippet_abtest import (
+    ABTest, ABTestController, ABTestError, ABTestReport, ABTestStatus, ABVariantStats,
+    DEFAULT_MIN_SAMPLES, VARIANT_TREATMENT, VARIANTS,
+)
+from .snippet_report import SlotReport, build_slot_report
+from .snippet_archive import ArchivalPolicy, ArchiveAction, ARCHIVABLE_PHASES, select_for_archive
+from .snippet_schedule import DEFAULT_RERUN_HISTORY, RerunHistory, RerunRecord, due_for_rerun
+from .snippet_replay import ReplayError, ReplayOptions, ReplayResult
+from .snippet_revalidate import RevalidateOptions, RevalidateProgress, RevalidateRun
+from .snippet_slotdiff import SlotDiffReport, plan
+from .snippet_log import audit_level, default_logger, log, log_audit
+from .snippet_credentials import CredentialDetectedError, CredentialScanner
+from .snippet_cases import CaseResult, TestCase, check_case, describe_failures, validate_cases
+from .snippet_bench import BenchmarkRegressionError, BenchmarkResult, find_regressions
+from .snippet_limits import ResourceLimits
+from .snippet_cache import ResultCache, cache_key
+from .snippet_encryption import AgeKeyProvider, EncryptionError, KeyProvider, is_sealed, seal, unseal
+from .snippet_lock import (
+    DEFAULT_LOCK_TTL, DEFAULT_LOCK_WAIT, DistributedLocker, LocalLocker, LockError, LockToken,
+    slot_lock_resource,
+)
+from .snippet_manifest import ManifestEntry, ManifestError, parse_manifest, write_manifest
+from .snippet_migrate import (
+    DEFAULT_ID_PREFIX, MigrateOptions, MigrationConflictError, MigrationReport,
+    plan_prefix_migration, rename_list, validate_id_prefix,
+)
+from .snippet_namespace import (
+    DEFAULT_NAMESPACE, validate_namespace, hash_credential, check_credential,
+)
+from .snippet_stream import OutputStream, StreamHub
+from .snippet_env import EnvSpecError, validate_env, env_hash
+from .snippet_schema import SchemaError, load_schema, schema_hash
+from .snippet_output import OutputCheck, check_output
+from .snippet_tags import TagsImmutableError, validate_tags
+from .snippet_transfer import (
+    ConflictPolicy, ExportRecord, ImportOptions, ImportRecordResult, ImportReport,
+    ImportStatus, SnippetImportError,
+)
+from .snippet_dryrun import (
+    DryRunReport, GateResult, GateStatus, GATE_PHASE, GATE_CIRCUIT, GATE_LABEL, GATE_DEPENDENCIES, GATE_CAPACITY,
+    GATE_APPROVAL, GATE_FORMAT, GATE_PARAMETERS, GATE_SPECULATION, GATE_LINT, GATE_BENCHMARKS,
+)
+from .snippet_approvals import ApprovalError, ApprovalRecord, ApprovalStatus, PendingApprovalError
+from .snippet_deps import (
+    DependencyError, ResolvedDependency, StaleDependencyError,
+    resolve_dependencies, source_hash, validate_requires,
+)
+from .snippet_params import (
+    ParameterSpec, ParameterError, ParameterSchemaError, parameters_schema_hash,
+    validate_specs, validate_arguments,
+    check_source, bind_parameters,
+)
+
 
 # ── File extensions per language ────────────────────────────────────────────
 LANG_EXTENSIONS = {
@@ -97,10 +166,54 @@ class StagingPhase(str, Enum):
     SPECULATING  = 'speculating'     # Dry-run executing
     PASSED       = 'passed'          # Speculative run succeeded
     FAILED       = 'failed'          # Speculative run failed
-    PROMOTING    = 'promoting'       # Writing to disk / ledger / registry
+    CANARY       = 'canary'          # Serving a growing share of its slot's executions
+    PENDING_SWAP = 'pending_swap'    # Waiting for the live version's executions to drain
+    PROMOTING    = 'promoting'       # Storing payload / ledger / registry
     PROMOTED     = 'promoted'        # Live in production
     REJECTED     = 'rejected'        # Manually or auto-rejected
     ROLLED_BACK  = 'rolled_back'     # Was promoted, then rolled back
+    SUPERSEDED   = 'superseded'      # Replaced in production by a newer version of its label
+    EVICTED      = 'evicted'         # Removed from production to free slot capacity
+    ARCHIVED     = 'archived'        # Expired by an ArchivalPolicy, or lost an A/B test
+
+
+class SpecResult(str, Enum):
+    """Verdict of a speculative run, as written to `spec_result:` headers."""
+    PASS     = 'PASS'
+    FAIL     = 'FAIL'
+    TIMEOUT  = 'TIMEOUT'             # Killed for exceeding the execution deadline
+    LINT_FAIL = 'LINT_FAIL'          # Ran clean but blocked by the pre-promotion lint gate
+    RESOURCE_EXCEEDED = 'RESOURCE_EXCEEDED'   # Stopped by a ResourceLimits cap (see snippet_limits)
+    SCHEMA_FAIL = 'SCHEMA_FAIL'      # Ran clean but its fd 3 result broke output_schema (snippet_output)
+    COVERAGE_FAIL = 'COVERAGE_FAIL'  # Its tests covered less than the CoverageGate minimum (snippet_coverage)
+    BENCH_REGRESSION = 'BENCH_REGRESSION'   # Benchmarks slower than the live version's (snippet_bench)
+    WEAK_SPEC = 'WEAK_SPEC'          # Too many mutants of it survived its tests (snippet_mutation)
+    CASE_FAIL = 'CASE_FAIL'          # One of its table-driven test cases failed (snippet_cases)
+
+
+class LabelConflictPolicy(str, Enum):
+    """What to do when a staged label is already in production on its slot."""
+    REJECT          = 'reject'           # Refuse the snippet (LabelConflictError)
+    OVERWRITE       = 'overwrite'        # Replace the live entry in place on promotion
+    VERSION_SUFFIX  = 'version_suffix'   # Stage under the next free `<label>-vN`
+
+
+DEFAULT_LABEL_POLICY = LabelConflictPolicy.OVERWRITE
+
+
+class LabelConflictError(ValueError):
+    """A label is already live on the target slot and the policy is REJECT."""
+
+
+class PhaseError(ValueError):
+    """The snippet is in the wrong phase for the requested transition."""
+
+
+# Phases a snippet can be diffed against production in (it hasn't gone live)
+_PREVIEWABLE_PHASES = (
+    StagingPhase.QUEUED, StagingPhase.SPECULATING, StagingPhase.PASSED, StagingPhase.FAILED,
+    StagingPhase.CANARY, StagingPhase.PENDING_SWAP,
+)
 
 
 class AuditEventType(str, Enum):
@@ -113,6 +226,10 @@ class AuditEventType(str, Enum):
     VERDICT_PASS           = 'verdict_pass'
     VERDICT_FAIL           = 'verdict_fail'
     VERDICT_MANUAL_HOLD    = 'verdict_manual_hold'
+    LINT_PASSED            = 'lint_passed'
+    LINT_FAILED            = 'lint_failed'
+    LINT_SKIPPED           = 'lint_skipped'
+    BENCHMARK_REGRESSED    = 'benchmark_regressed'
     PROMOTION_STARTED      = 'promotion_started'
     FILE_WRITTEN           = 'file_written'
     LEDGER_NODE_CREATED    = 'ledger_node_created'
@@ -121,6 +238,34 @@ class AuditEventType(str, Enum):
     REJECTION              = 'rejection'
     ROLLBACK               = 'rollback'
     SLOT_RELEASED          = 'slot_released'
+    SUPERSEDED             = 'superseded'
+    EVICTED                = 'evicted'
+    BATCH_PROMOTION_STARTED   = 'batch_promotion_started'
+    BATCH_PROMOTION_COMPLETED = 'batch_promotion_completed'
+    BATCH_PROMOTION_ABORTED   = 'batch_promotion_aborted'
+    CANARY_STARTED         = 'canary_started'
+    CANARY_STEPPED         = 'canary_stepped'
+    CANARY_COMPLETED       = 'canary_completed'
+    CANARY_ROLLED_BACK     = 'canary_rolled_back'
+    CIRCUIT_OPENED         = 'circuit_opened'
+    CIRCUIT_CLOSED         = 'circuit_closed'
+    ARCHIVED               = 'archived'
+    APPROVAL_REQUESTED     = 'approval_requested'
+    APPROVAL_GRANTED       = 'approval_granted'
+    APPROVAL_REJECTED      = 'approval_rejected'
+    AB_TEST_STARTED        = 'ab_test_started'
+    AB_TEST_ENDED          = 'ab_test_ended'
+    AB_TEST_ABORTED        = 'ab_test_aborted'
+    SWAP_PENDING           = 'swap_pending'
+    SWAP_COMPLETED         = 'swap_completed'
+    SNIPPET_IMPORTED       = 'snippet_imported'
+    MANIFEST_LOADED        = 'manifest_loaded'
+    SNIPPET_RETAGGED       = 'snippet_retagged'
+    SNIPPET_RERUN          = 'snippet_rerun'
+    SNIPPET_REPLAYED       = 'snippet_replayed'
+    SNIPPET_REVALIDATED    = 'snippet_revalidated'
+    HEALTH_ALERT           = 'health_alert'
+    ID_MIGRATED            = 'id_migrated'
     ERROR                  = 'error'
 
 
@@ -138,7 +283,23 @@ class StagedSnippet:
     engine_letter: str                       # e.g. 'a'
     label: str                               # Human-readable name
     code: str                                # The snippet source code
-    code_hash: str                           # SHA-256 of the code
+    code_hash: str                           # SHA-256 of the code (merged, with requires)
+    label_policy: LabelConflictPolicy = DEFAULT_LABEL_POLICY
+    parameters: List[Dict[str, Any]] = field(default_factory=list)  # ParameterSpec dicts
+    namespace: str = DEFAULT_NAMESPACE       # Tenant; invisible to other namespaces
+    env: Dict[str, str] = field(default_factory=dict)  # Injected into the snippet's process
+    env_hash: str = ''                       # snippet_env.env_hash(env); '' when none
+    requires: List[str] = field(default_factory=list)   # Labels merged in first (snippet_deps)
+    dependencies: List[Dict[str, str]] = field(default_factory=list)  # Resolved, merge order
+    merged_code: str = ''                    # Dependencies + code as one program ('' = none)
+    output_schema: str = ''                  # JSON Schema the fd 3 result must meet ('' = none)
+    output_schema_hash: str = ''             # snippet_schema.schema_hash(output_schema)
+    parameters_schema_hash: str = ''         # snippet_params.parameters_schema_hash(parameters)
+    tags: List[str] = field(default_factory=list)   # Hierarchical paths, sorted (snippet_tags)
+    resource_limits: Dict[str, float] = field(default_factory=dict)  # Own ResourceLimits ({} = executor's)
+    deterministic: bool = False              # Same inputs, same run: speculations cacheable
+    test_cases: List[Dict[str, Any]] = field(default_factory=list)  # TestCase dicts (snippet_cases)
+    suppress_credential_check: bool = False  # Staged past the credential scan (snippet_credentials)
 
     # ── Lifecycle ─────────────────────────────────────────────────────────
     phase: StagingPhase = StagingPhase.QUEUED
@@ -155,30 +316,90 @@ class StagedSnippet:
     spec_error: str = ''
     spec_execution_time: float = 0.0
     spec_success: bool = False
+    spec_result: SpecResult = SpecResult.FAIL
     spec_variables: Dict[str, Any] = field(default_factory=dict)
     spec_started_at: float = 0.0
     spec_completed_at: float = 0.0
+    lint_diagnostics: List[Dict[str, Any]] = field(default_factory=list)
+    spec_arguments: Dict[str, Any] = field(default_factory=dict)  # Values bound for the run
+    resource_violation: str = ''             # Limit a RESOURCE_EXCEEDED run hit (cpu_time, memory, output)
+    spec_output_value: Any = None            # Parsed fd 3 result of the run (snippet_output)
+    spec_output_errors: List[Dict[str, str]] = field(default_factory=list)  # {path, message}
+    coverage_percent: Optional[float] = None # Statement coverage of its tests (snippet_coverage)
+    benchmarks: List[Dict[str, Any]] = field(default_factory=list)  # BenchmarkResult dicts (snippet_bench)
+    mutants_tested: int = 0                  # Compiling mutants its tests ran against (snippet_mutation)
+    survived_mutations: List[Dict[str, Any]] = field(default_factory=list)  # Mutation dicts
+    spec_cases: List[Dict[str, Any]] = field(default_factory=list)  # CaseResult dicts, in case order
+    spec_cached: bool = False                # Result served by the ResultCache (snippet_cache)
 
     # ── Promotion details ─────────────────────────────────────────────────
     saved_file_path: str = ''                # Path where snippet was saved
     ledger_node_id: str = ''                 # Node ID in the SessionLedger
     registry_slot_id: str = ''               # Slot ID in the NodeRegistry (nra##)
     promoted_at: float = 0.0
+    superseded_ids: List[str] = field(default_factory=list)  # Entries this one replaced
+
+    # ── Canary rollout ────────────────────────────────────────────────────
+    canary_weight: float = 0.0               # Share of the slot's executions it serves
+    canary_baseline_id: str = ''             # Live version the canary is replacing
 
     # ── Rejection / rollback ──────────────────────────────────────────────
     rejection_reason: str = ''
     rejection_at: float = 0.0
+    rolled_back_to: str = ''                 # staging_id re-installed by rollback
+    archived_at: float = 0.0                 # When an ArchivalPolicy archived it
+
+    # ── Scheduled reruns ──────────────────────────────────────────────────
+    last_rerun_at: float = 0.0               # When its slot's schedule last re-ran it
 
     def to_dict(self) -> Dict[str, Any]:
         d = asdict(self)
         d['phase'] = self.phase.value
+        d['spec_result'] = self.spec_result.value
+        d['label_policy'] = self.label_policy.value
+        # Values may be secrets: API responses only name the variables
+        d['env'] = sorted(self.env)
         # Truncate large fields for API responses
         if len(d.get('spec_output', '')) > 5000:
             d['spec_output'] = d['spec_output'][:5000] + '\n…(truncated)'
         if len(d.get('code', '')) > 10000:
             d['code'] = d['code'][:10000] + '\n…(truncated)'
+        if len(d.get('merged_code', '')) > 10000:
+            d['merged_code'] = d['merged_code'][:10000] + '\n…(truncated)'
         return d
 
+    @property
+    def program(self) -> str:
+        """The source that runs, is linted and is promoted: merged_code or code."""
+        return self.merged_code or self.code
+
+
+@dataclass
+class PromotionResult:
+    """Outcome of one snippet inside a batch promotion."""
+    staging_id: str
+    success: bool
+    snippet: Optional[StagedSnippet] = None
+    error: str = ''
+    rolled_back: bool = False                # Promoted, then undone because the batch failed
+
+    def to_dict(self) -> Dict[str, Any]:
+        return {
+            'staging_id': self.staging_id,
+            'success': self.success,
+            'error': self.error,
+            'rolled_back': self.rolled_back,
+            'snippet': self.snippet.to_dict() if self.snippet else None,
+        }
+
+
+class BatchPromotionError(ValueError):
+    """A batch promotion failed and every snippet in it was rolled back."""
+
+    def __init__(self, message: str, results: List[PromotionResult]):
+        super().__init__(message)
+        self.results = results
+
 
 # ═══════════════════════════════════════════════════════════════════════════
 # AUDIT LOGGER — append-only forensic event trail
@@ -189,11 +410,16 @@ class AuditLogger:
 
     Every event — queue, reserve, spec-exec, verdict, promote, reject —
     gets an immutable line in the log. These are never modified or deleted.
+    Each is also sent to `logger` (see snippet_log), with the slot and
+    label `describe(staging_id)` returns.
     """
 
-    def __init__(self, log_path: str):
+    def __init__(self, log_path: str, logger: Optional[logging.Logger] = None,
+                 describe: Optional[Callable[[str], Dict[str, str]]] = None):
         self._path = log_path
         self._lock = threading.Lock()
+        self._logger = logger or default_logger()
+        self._describe = describe
         # Ensure directory exists
         os.makedirs(os.path.dirname(log_path) or '.', exist_ok=True)
 
@@ -210,6 +436,9 @@ class AuditLogger:
         with self._lock:
             with open(self._path, 'a', encoding='utf-8') as f:
                 f.write(json.dumps(entry, default=str) + '\n')
+        if self._logger.isEnabledFor(audit_level(event_type.value, entry['data'])):
+            log_audit(self._logger, event_type.value, staging_id, entry['data'],
+                      self._describe(staging_id) if self._describe else None)
 
     def read_all(self, limit: int = 500) -> List[Dict]:
         """Read the last N audit entries (newest first)."""
@@ -249,19 +478,169 @@ class StagingPipeline:
         - executors: Dict[str, executor]    — language → executor instance
         - node_registry: NodeRegistry       — for slot reservation & commit
         - session_ledger: SessionLedger     — for creating synthetic nodes
-        - snippets_dir: str                 — where promoted snippets are saved
+        - snippets_dir: str                 — root of the default payload store
         - audit_log_path: str               — path to the JSONL audit file
+        - snippet_store: SnippetStore       — content-addressable payload store
+                                              (default: <snippets_dir>/.objects)
+        - history_depth: int                — promotions kept per slot lineage
+                                              for rollback (default 10)
+        - label_policies: Dict[str, policy] — per-engine LabelConflictPolicy
+                                              (engine letter → policy)
+        - default_label_policy              — policy for engines not listed
+        - slot_configs: Dict[str, SlotConfig] — capacity limits and isolation
+                                              per slot (engine letter → SlotConfig)
+        - webhooks: WebhookDispatcher       — promotion notifications (created
+                                              on the first add_webhook_target())
+        - linters: Dict[str, SnippetLinter] — pre-promotion lint gate per
+                                              language (none = no gate)
+        - metrics: PipelineMetrics          — Prometheus counters / gauges
+                                              (default: the shared DEFAULT_METRICS)
+        - snippet_index: SnippetIndex       — backs query() (default: in-memory;
+                                              SQLiteSnippetIndex persists records)
+        - engines: EngineRegistry           — language → Engine for speculation of
+                                              languages without an executor
+                                              (default: DEFAULT_ENGINES)
+        - circuit_breaker: CircuitBreaker   — refuses runs / promotions of code
+                                              that keeps failing (default: 3
+                                              failures in 5 min, 60 s recovery)
+        - canary_controller: CanaryController — steps canary promotions
+                                              (promote(canary=...))
+        - ab_test_controller: ABTestController — routes and counts A/B tests
+                                              (start_ab_test())
+        - swap_controller: SwapController   — counts in-flight slot executions and
+                                              drains them for promote(graceful_swap=...)
+        - namespace_admin_credential: str   — issues NamespaceAdmin handles for
+                                              cross-namespace access ('' = none)
+        - namespace_credentials: {namespace: credential} — what remote callers
+                                              present to act in a namespace
+        - staging_id_prefix: str            — what new staging IDs start with
+                                              (default 'stg-'; see snippet_migrate)
+        - benchmark_regression_gate: float  — % a benchmark may slow down against
+                                              the live version (0 = off; snippet_bench)
+        - result_cache: ResultCache         — serves speculations of deterministic
+                                              snippets (None = always run; snippet_cache)
+        - key_providers: dict               — slot letter → KeyProvider sealing that
+                                              slot's payloads (snippet_encryption)
+        - age_identities: list              — age secret keys that open payloads sealed
+                                              to SlotConfig recipients
+        - locker: DistributedLocker         — per-slot promotion locks shared between
+                                              instances (default LocalLocker; snippet_lock)
+        - promotion_lock_ttl / promotion_lock_wait — seconds a slot lock lasts /
+                                              a promotion waits for it
+        - logger: logging.Logger            — where audit events (and the engines'
+                                              and default store's records) are logged
+                                              (default: snippet_log.default_logger())
+        - credential_scanner: CredentialScanner — refuses sources with hard-coded
+                                              secrets at queue time (default: the
+                                              built-in patterns; snippet_credentials)
     """
 
     def __init__(self, executors: Dict, node_registry, session_ledger,
                  snippets_dir: str = 'web_interface/snippets',
-                 audit_log_path: str = 'web_interface/staging_audit.jsonl'):
+                 audit_log_path: str = 'web_interface/staging_audit.jsonl',
+                 snippet_store: Optional[SnippetStore] = None,
+                 history_depth: int = DEFAULT_HISTORY_DEPTH,
+                 label_policies: Optional[Dict[str, LabelConflictPolicy]] = None,
+                 default_label_policy: LabelConflictPolicy = DEFAULT_LABEL_POLICY,
+                 slot_configs: Optional[Dict[str, SlotConfig]] = None,
+                 webhooks: Optional[WebhookDispatcher] = None,
+                 linters: Optional[Dict[str, SnippetLinter]] = None,
+                 metrics: Optional[PipelineMetrics] = None,
+                 snippet_index: Optional[SnippetIndex] = None,
+                 engines: Optional[EngineRegistry] = None,
+                 circuit_breaker: Optional[CircuitBreaker] = None,
+                 canary_controller: Optional[CanaryController] = None,
+                 ab_test_controller: Optional[ABTestController] = None,
+                 swap_controller: Optional[SwapController] = None,
+                 namespace_admin_credential: str = '',
+                 namespace_credentials: Optional[Dict[str, str]] = None,
+                 rerun_history_size: int = DEFAULT_RERUN_HISTORY,
+                 staging_id_prefix: str = DEFAULT_ID_PREFIX,
+                 benchmark_regression_gate: float = 0.0,
+                 result_cache: Optional[ResultCache] = None,
+                 key_providers: Optional[Dict[str, KeyProvider]] = None,
+                 age_identities: Optional[List[str]] = None,
+                 locker: Optional[DistributedLocker] = None,
+                 promotion_lock_ttl: float = DEFAULT_LOCK_TTL,
+                 promotion_lock_wait: float = DEFAULT_LOCK_WAIT,
+                 logger: Optional[logging.Logger] = None,
+                 credential_scanner: Optional[CredentialScanner] = None):
         self._executors = executors
+        self._logger = logger or default_logger()
+        self._credentials = (credential_scanner if credential_scanner is not None
+                             else CredentialScanner())
+        self._id_prefix = validate_id_prefix(staging_id_prefix)
         self._registry = node_registry
         self._ledger = session_ledger
         self._snippets_dir = snippets_dir
-        self._audit = AuditLogger(audit_log_path)
+        self._audit = AuditLogger(audit_log_path, self._logger, self._log_identity)
+        self._audit_log_path = audit_log_path
         self._lock = threading.RLock()
+        self._webhooks = webhooks
+        self._linters: Dict[str, SnippetLinter] = dict(linters or {})
+        self._metrics = metrics if metrics is not None else DEFAULT_METRICS
+        self._index = snippet_index if snippet_index is not None else InMemorySnippetIndex()
+        self._index.bind(self)
+        self._engines = engines if engines is not None else DEFAULT_ENGINES
+        self._engines.bind_all(self)
+        self._breaker = circuit_breaker if circuit_breaker is not None else CircuitBreaker()
+        self._canaries = canary_controller if canary_controller is not None else CanaryController()
+        self._canaries.bind(self)
+        self._ab_tests = ab_test_controller if ab_test_controller is not None else ABTestController()
+        self._swaps = swap_controller if swap_controller is not None else SwapController()
+        self._swaps.bind(self)
+        self._admin_credential_hash = (hash_credential(namespace_admin_credential)
+                                       if namespace_admin_credential else '')
+        self._namespace_credential_hashes = {
+            validate_namespace(name): hash_credential(credential)
+            for name, credential in (namespace_credentials or {}).items() if credential}
+        self._streams = StreamHub()
+
+        # Source payloads, stored once per code_hash
+        self._store = snippet_store or FileSnippetStore(
+            os.path.join(snippets_dir, '.objects'), logger=self._logger)
+
+        # Promote / rollback log per (slot, label) — drives rollback
+        self._promotions = PromotionHistory(depth=history_depth)
+
+        # Label uniqueness: engine letter → LabelConflictPolicy
+        self._default_label_policy = LabelConflictPolicy(default_label_policy)
+        self._label_policies: Dict[str, LabelConflictPolicy] = {
+            letter: LabelConflictPolicy(policy)
+            for letter, policy in (label_policies or {}).items()
+        }
+
+        # Capacity limits: engine letter → SlotConfig (absent = unlimited)
+        self._slot_configs: Dict[str, SlotConfig] = dict(slot_configs or {})
+        # Isolation strategy per slot, chosen once for the slot's level and this host
+        self._isolation: Dict[str, IsolationStrategy] = {
+            letter: select_strategy(config.isolation, letter)
+            for letter, config in self._slot_configs.items()
+            if config.isolation != IsolationLevel.NONE
+        }
+
+        # Scheduled reruns of promoted snippets: staging_id → RerunHistory
+        if rerun_history_size < 1:
+            raise ValueError("rerun_history_size must be >= 1")
+        if benchmark_regression_gate < 0:
+            raise ValueError("benchmark_regression_gate must be >= 0")
+        self._benchmark_regression_gate = benchmark_regression_gate
+        self._result_cache = result_cache
+        self._key_providers: Dict[str, KeyProvider] = dict(key_providers or {})
+        self._age_identities = AgeKeyProvider(identities=age_identities or ()).identities
+        if promotion_lock_ttl <= 0 or promotion_lock_wait < 0:
+            raise ValueError("promotion_lock_ttl must be > 0 and promotion_lock_wait >= 0")
+        self._locker = locker or LocalLocker()
+        self._promotion_lock_ttl = promotion_lock_ttl
+        self._promotion_lock_wait = promotion_lock_wait
+        self._rerun_history_size = rerun_history_size
+        self._reruns: Dict[str, RerunHistory] = {}
+
+        # Named replay results (snippet_replay): (namespace, name) → ReplayResult
+        self._replay_snapshots: Dict[Tuple[str, str], ReplayResult] = {}
+
+        # Promotion sign-off on protected slots: staging_id → ApprovalRecord
+        self._approvals: Dict[str, ApprovalRecord] = {}
 
         # Active staging entries: staging_id → StagedSnippet
         self._staged: Dict[str, StagedSnippet] = {}
@@ -272,70 +651,233 @@ class StagingPipeline:
         # Reserved positions: engine_name → set of positions held
         self._reserved_positions: Dict[str, set] = {}
 
-        # Ensure snippet directories exist
-        for lang in LANG_EXTENSIONS:
-            os.makedirs(os.path.join(self._snippets_dir, lang), exist_ok=True)
+        # Per-snippet locks held for the duration of a batch promotion so
+        # overlapping batches serialize on the IDs they share; each entry is
+        # [lock, batches holding or waiting on it] and goes when that hits 0
+        self._promotion_locks: Dict[str, list] = {}
+
+        # Promotions whose webhook and metric a batch_promote() on this
+        # thread holds back until the whole batch commits
+        self._held = threading.local()
+
+        # Queue depth is read at scrape time rather than tracked per event
+        self._metrics.track_queue_depth(lambda: len(self._staged))
 
     # ─────────────────────────────────────────────────────────────────────
     # PHASE 1: QUEUE — receive snippet, reserve a slot
     # ─────────────────────────────────────────────────────────────────────
 
+    @traced('StageSnippet')
     def queue_snippet(self, engine_letter: str, language: str, code: str,
-                      label: str = '') -> StagedSnippet:
+                      label: str = '',
+                      label_policy: Optional[LabelConflictPolicy] = None,
+                      parameters: Optional[List] = None,
+                      namespace: str = DEFAULT_NAMESPACE,
+                      env: Optional[Dict[str, str]] = None,
+                      requires: Optional[List[str]] = None,
+                      output_schema=None,
+                      tags: Optional[List[str]] = None,
+                      position: int = 0,
+                      resource_limits: Optional[ResourceLimits] = None,
+                      deterministic: bool = False,
+                      test_cases: Optional[List] = None,
+                      suppress_credential_check: bool = False) -> StagedSnippet:
         """
         Accept a snippet into the staging pipeline.
 
-        1. Generates a staging_id
-        2. Computes a SHA-256 hash of the code
-        3. Reserves the next free slot on the target engine row
-        4. Returns the StagedSnippet in QUEUED phase
-
-        Raises ValueError if the engine row is full.
+        1. Generates a staging_id and scans the source as submitted for
+           hard-coded credentials (see snippet_credentials)
+        2. Normalises the code through its Engine's format_for_stage()
+           (gofmt for Go when format_on_stage is on), then computes a
+           SHA-256 hash of it
+        3. Applies the label conflict policy (`label_policy` overrides the
+           engine's configured one for this call)
+        4. Reserves the next free slot on the target engine row (or
+           `position` on it, when given)
+        5. Returns the StagedSnippet in QUEUED phase
+
+        `parameters` (ParameterSpecs or their dicts) declares typed inputs
+        bound at execution time — see snippet_params.  Go only.
+        `namespace` is the tenant the snippet belongs to; labels conflict
+        only with labels of the same namespace (see snippet_namespace).
+        `env` is injected into the snippet's own process when it runs —
+        see snippet_env.  Only for engines with supports_env (Go).
+        `requires` names labels whose live versions are merged in front of
+        the source — see snippet_deps; code_hash is then the merged program's.
+        `output_schema` (a JSON Schema string or dict) is what the result
+        the snippet writes to fd 3 must conform to — see snippet_output.
+        Only for engines with supports_result_fd (Go).
+        `tags` are hierarchical paths such as 'math/number-theory' that
+        tag_query() and SnippetFilter.tags search — see snippet_tags.
+        `resource_limits` replace the executor's ResourceLimits for this
+        snippet's runs — see snippet_limits.  Only for executors that are
+        `limitable` and engines with supports_limits (Go).
+        `deterministic` lets the pipeline's ResultCache answer its
+        speculations — see snippet_cache.
+        `test_cases` (TestCases or their dicts) are run by speculate() after
+        the snippet's own run, each with its params bound — see snippet_cases.
+        `suppress_credential_check` stages a source the credential scanner
+        flags (an intentional test fixture); what it found is audited and
+        logged at WARNING.
+
+        Raises CredentialDetectedError if the source looks like it carries
+        a credential, ValueError if the engine row is full, `position` is taken
+        or off the row, or the language can't take resource_limits, SlotFullError if the
+        slot's SlotConfig limits would be exceeded, LabelConflictError
+        if the label is already live on the slot and the policy is REJECT,
+        ParameterError if the parameter specs don't fit the source (or a
+        test case's params don't fit the specs),
+        EnvSpecError if `env` has a bad or reserved name,
+        SchemaError if `output_schema` isn't a usable schema,
+        TagError for a malformed tag,
+        FormatFailedError if formatting is on and the source isn't valid Go,
+        and MissingDependencyError / CyclicDependencyError if `requires`
+        can't be resolved.
         """
         now = time.time()
-        staging_id = f"stg-{uuid.uuid4().hex[:12]}"
-        code_hash = hashlib.sha256(code.encode('utf-8')).hexdigest()
+        uid = uuid.uuid4().hex[:12]
+        staging_id = f"{self._id_prefix}{uid}"
+        namespace = validate_namespace(namespace)
+        tags = validate_tags(tags)
         lang = language.lower().strip()
+        submitted = code
+        with span('scan_credentials', code_length=len(code)):
+            credentials = self._credentials.scan(code)
+        if credentials and not suppress_credential_check:
+            log(self._logger, logging.WARNING, 'credential_detected', slot=engine_letter,
+                label=label, pattern=credentials[0].pattern, line=credentials[0].line)
+            raise CredentialDetectedError(credentials)
+        plugin = self._engines.get(lang)
+        if plugin is not None:
+            with span('format_source', language=lang):
+                formatted = plugin.format_for_stage(code.encode('utf-8'))
+            code = formatted.decode('utf-8') if isinstance(formatted, bytes) else formatted
+        with span('hash_source', code_length=len(code)):
+            code_hash = hashlib.sha256(code.encode('utf-8')).hexdigest()
 
         # Resolve engine
         from .node_registry import LETTER_TO_ENGINE, LANGUAGE_STRING_TO_ENGINE
         engine = LETTER_TO_ENGINE.get(engine_letter)
         if engine is None:
             engine = LANGUAGE_STRING_TO_ENGINE.get(lang)
+        if engine is None and plugin is not None:
+            # Plugin language: promotes into the row its Engine borrows
+            engine_letter = engine_letter or plugin.engine_letter
+            engine = LETTER_TO_ENGINE.get(engine_letter)
         if engine is None:
             raise ValueError(f"Unknown engine for letter='{engine_letter}' language='{lang}'")
 
         engine_name = engine.name
-
-        # Reserve a slot position (don't actually commit yet)
-        reserved_pos = self._reserve_position(engine_name)
-        address = f"{engine_letter}{reserved_pos}"
-
-        snippet = StagedSnippet(
-            staging_id=staging_id,
-            language=lang,
-            engine_letter=engine_letter,
-            label=label or f"snippet-{staging_id[:8]}",
-            code=code,
-            code_hash=code_hash,
-            phase=StagingPhase.QUEUED,
-            created_at=now,
-            updated_at=now,
-            reserved_engine=engine_name,
-            reserved_position=reserved_pos,
-            reserved_address=address,
-        )
-
+        specs = validate_specs(p if isinstance(p, ParameterSpec) else ParameterSpec.from_dict(p)
+                               for p in (parameters or []))
+        if specs:
+            if lang != 'go':
+                raise ParameterError(f"Parameter binding is only supported for Go (got '{lang}')")
+            check_source(code, specs)
+        cases = validate_cases(test_cases, specs)
+        env = validate_env(env)
+        if env and (plugin is None or not plugin.supports_env):
+            raise EnvSpecError(f"Environment injection is not supported for '{lang}'")
+        schema = load_schema(output_schema) if output_schema not in (None, '') else None
+        if schema is not None and (plugin is None or not plugin.supports_result_fd):
+            raise SchemaError(f"Structured output is not supported for '{lang}'")
+        if resource_limits is not None and not isinstance(resource_limits, ResourceLimits):
+            resource_limits = ResourceLimits(**resource_limits)
+        if resource_limits is not None and resource_limits.enabled \
+                and not self._supports_limits(lang):
+            raise ValueError(f"Per-snippet resource limits are not supported for '{lang}'")
+        policy = (LabelConflictPolicy(label_policy) if label_policy
+                  else self.get_label_policy(engine_letter))
+        requested_label = label or f"snippet-{uid[:8]}"
+
+        requires = validate_requires(requires)
+        dependencies: List[ResolvedDependency] = []
+        merged_code = ''
+        if requires:
+            if plugin is None:
+                raise DependencyError(f"Dependencies are not supported for '{lang}'")
+            with span('resolve_dependencies', requires=len(requires)):
+                deps = resolve_dependencies(
+                    requested_label, requires,
+                    lambda name: self._live_dependency(engine_letter, name, namespace))
+            dependencies = [ResolvedDependency(d.label, d.staging_id, source_hash(d.code))
+                            for d in deps]
+            merged_code = plugin.merge_sources([d.code for d in deps] + [code])
+            code_hash = source_hash(merged_code)
+
+        # Label resolution and registration happen under one lock so two
+        # concurrent VERSION_SUFFIX calls can't both claim the same `-vN`
         with self._lock:
+            final_label = self._resolve_label(engine_letter, requested_label, policy, namespace)
+            self._check_capacity(engine_letter, code, final_label, policy, namespace)
+
+            # Reserve a slot position (don't actually commit yet)
+            reserved_pos = self._reserve_position(engine_name, position)
+            address = f"{engine_letter}{reserved_pos}"
+
+            snippet = StagedSnippet(
+                staging_id=staging_id,
+                language=lang,
+                engine_letter=engine_letter,
+                label=final_label,
+                code=code,
+                code_hash=code_hash,
+                label_policy=policy,
+                parameters=[spec.to_dict() for spec in specs],
+                namespace=namespace,
+                env=env,
+                env_hash=env_hash(env),
+                requires=requires,
+                dependencies=[d.to_dict() for d in dependencies],
+                merged_code=merged_code,
+                output_schema=(json.dumps(schema, sort_keys=True, separators=(',', ':'))
+                               if schema is not None else ''),
+                output_schema_hash=schema_hash(schema),
+                parameters_schema_hash=parameters_schema_hash(specs),
+                tags=tags,
+                resource_limits=(resource_limits.to_dict()
+                                 if resource_limits is not None and resource_limits.enabled
+                                 else {}),
+                deterministic=bool(deterministic),
+                test_cases=[case.to_dict() for case in cases],
+                suppress_credential_check=bool(suppress_credential_check),
+                phase=StagingPhase.QUEUED,
+                created_at=now,
+                updated_at=now,
+                reserved_engine=engine_name,
+                reserved_position=reserved_pos,
+                reserved_address=address,
+            )
             self._staged[staging_id] = snippet
+        self._index.put(snippet)
 
         self._audit.log(AuditEventType.SNIPPET_QUEUED, staging_id, {
             'language': lang,
             'engine_letter': engine_letter,
+            'namespace': namespace,
             'label': snippet.label,
+            'requested_label': requested_label,
+            'label_policy': policy.value,
             'code_hash': code_hash,
             'code_length': len(code),
+            'formatted': code != submitted,
+            'env_keys': sorted(env),
+            'env_hash': snippet.env_hash,
+            'requires': requires,
+            'dependencies': snippet.dependencies,
+            'output_schema_hash': snippet.output_schema_hash,
+            'parameters_schema_hash': snippet.parameters_schema_hash,
+            'tags': tags,
+            'resource_limits': snippet.resource_limits,
+            'deterministic': snippet.deterministic,
+            'test_cases': [case.name for case in cases],
+            'credential_check_suppressed': bool(suppress_credential_check),
+            'credential_findings': [c.to_dict() for c in credentials],
         })
+        if suppress_credential_check:
+            log(self._logger, logging.WARNING, 'credential_check_suppressed',
+                staging_id=staging_id, slot=engine_letter, label=snippet.label,
+                findings=','.join(sorted({c.pattern for c in credentials})) or 'none')
         self._audit.log(AuditEventType.SLOT_RESERVED, staging_id, {
             'engine': engine_name,
             'position': reserved_pos,
@@ -344,11 +886,11 @@ class StagingPipeline:
 
         return snippet
 
-    def _reserve_position(self, engine_name: str) -> int:
+    def _reserve_position(self, engine_name: str, position: int = 0) -> int:
         """
-        Find and reserve the next free slot position on an engine row.
-        The reservation is held in memory — the registry slot is NOT
-        created until promotion.
+        Find and reserve the next free slot position on an engine row
+        (exactly `position`, when given).  The reservation is held in
+        memory — the registry slot is NOT created until promotion.
         """
         with self._lock:
             row = self._registry.get_engine_row(engine_name)
@@ -357,6 +899,16 @@ class StagingPipeline:
 
             reserved = self._reserved_positions.get(engine_name, set())
 
+            if position:
+                if not 1 <= position <= row.max_slots:
+                    raise ValueError(f"Position {position} is off engine '{engine_name}' "
+                                     f"(max={row.max_slots})")
+                existing_slot = row.slots.get(position)
+                if (existing_slot and existing_slot.node_id is not None) or position in reserved:
+                    raise ValueError(f"Position {position} on engine '{engine_name}' is taken")
+                self._reserved_positions.setdefault(engine_name, set()).add(position)
+                return position
+
             for pos in range(1, row.max_slots + 1):
                 # Skip positions that are already committed OR reserved
                 existing_slot = row.slots.get(pos)
@@ -380,46 +932,757 @@ class StagingPipeline:
             reserved = self._reserved_positions.get(engine_name, set())
             reserved.discard(position)
 
+    # ─────────────────────────────────────────────────────────────────────
+    # LABEL UNIQUENESS — one live production entry per (slot, label)
+    # ─────────────────────────────────────────────────────────────────────
+
+    def get_label_policy(self, engine_letter: str) -> LabelConflictPolicy:
+        """The label conflict policy configured for an engine."""
+        return self._label_policies.get(engine_letter, self._default_label_policy)
+
+    def set_label_policy(self, engine_letter: str,
+                         policy: Optional[LabelConflictPolicy]):
+        """Configure an engine's label conflict policy (None = use the default)."""
+        with self._lock:
+            if policy is None:
+                self._label_policies.pop(engine_letter, None)
+            else:
+                self._label_policies[engine_letter] = LabelConflictPolicy(policy)
+
+    def get_label_policies(self) -> Dict[str, str]:
+        """Per-engine overrides plus the default (key '*')."""
+        with self._lock:
+            policies = {k: v.value for k, v in self._label_policies.items()}
+        policies['*'] = self._default_label_policy.value
+        return policies
+
+    def _live_entries(self, engine_letter: str, label: str,
+                      exclude: str = '',
+                      namespace: str = DEFAULT_NAMESPACE) -> List[StagedSnippet]:
+        """PROMOTED snippets of `namespace` carrying `label` on a slot, oldest first."""
+        with self._lock:
+            candidates = list(self._history) + list(self._staged.values())
+        live = [s for s in candidates
+                if s.phase == StagingPhase.PROMOTED
+                and s.engine_letter == engine_letter
+                and s.label == label
+                and s.namespace == namespace
+                and s.staging_id != exclude]
+        return sorted(live, key=lambda s: s.promoted_at)
+
+    def _live_dependency(self, engine_letter: str, label: str,
+                         namespace: str) -> Optional[StagedSnippet]:
+        """The production version a required label resolves to (newest live entry)."""
+        live = self._live_entries(engine_letter, label, namespace=namespace)
+        return live[-1] if live else None
+
+    def _check_dependencies(self, snippet: StagedSnippet):
+        """StaleDependencyError if a required label now resolves to another version."""
+        current = resolve_dependencies(
+            snippet.label, snippet.requires,
+            lambda name: self._live_dependency(snippet.engine_letter, name, snippet.namespace))
+        resolved = {d['label']: d['staging_id'] for d in snippet.dependencies}
+        for dep in current:
+            if resolved.get(dep.label) != dep.staging_id:
+                raise StaleDependencyError(
+                    f"Dependency '{dep.label}' is now {dep.staging_id} (staged against "
+                    f"{resolved.get(dep.label) or 'none'}); stage '{snippet.staging_id}' again")
+
+    def _live_labels(self, engine_letter: str,
+                     namespace: str) -> Dict[str, StagedSnippet]:
+        """label → newest PROMOTED snippet of `namespace` on a slot."""
+        with self._lock:
+            candidates = list(self._history) + list(self._staged.values())
+        live: Dict[str, StagedSnippet] = {}
+        for s in sorted(candidates, key=lambda s: s.promoted_at):
+            if (s.phase == StagingPhase.PROMOTED and s.engine_letter == engine_letter
+                    and s.namespace == namespace):
+                live[s.label] = s
+        return live
+
+    def dependency_graph(self, engine_letter: str,
+                         namespace: str = DEFAULT_NAMESPACE) -> DepGraph:
+        """
+        The label → required label graph of a slot's live snippets in
+        `namespace` (see snippet_depgraph).  ValueError for an unknown slot,
+        CyclicDependencyError if the live snippets' requires form a cycle.
+        """
+        from .node_registry import LETTER_TO_ENGINE
+        if engine_letter not in LETTER_TO_ENGINE:
+            raise ValueError(f"Unknown slot '{engine_letter}'")
+        live = self._live_labels(engine_letter, namespace)
+        promotions = collections.Counter(
+            r.label for r in self._promotions.records(engine_letter, None, namespace)
+            if r.event == 'promote')
+        return DepGraph.build(engine_letter, namespace, live.values(), promotions)
+
+    def _resolve_label(self, engine_letter: str, label: str,
+                       policy: LabelConflictPolicy,
+                       namespace: str = DEFAULT_NAMESPACE) -> str:
+        """Apply `policy` to a requested label and return the label to stage under."""
+        live = self._live_entries(engine_letter, label, namespace=namespace)
+        if policy == LabelConflictPolicy.REJECT and live:
+            raise LabelConflictError(
+                f"Label '{label}' is already in production on slot '{engine_letter}' "
+                f"({live[-1].reserved_address}, {live[-1].staging_id})"
+            )
+        if policy != LabelConflictPolicy.VERSION_SUFFIX:
+            return label
+
+        # Labels already taken on this slot: live entries plus snippets
+        # still working their way through the pipeline
+        in_flight = (StagingPhase.QUEUED, StagingPhase.SPECULATING,
+                     StagingPhase.PASSED, StagingPhase.FAILED, StagingPhase.PENDING_SWAP,
+                     StagingPhase.PROMOTING, StagingPhase.PROMOTED)
+        with self._lock:
+            taken = {s.label for s in list(self._history) + list(self._staged.values())
+                     if s.engine_letter == engine_letter and s.namespace == namespace
+                     and s.phase in in_flight}
+        if label not in taken:
+            return label
+        base = re.sub(r'-v\d+$', '', label)
+        n = 2
+        while f"{base}-v{n}" in taken:
+            n += 1
+        return f"{base}-v{n}"
+
+    # ─────────────────────────────────────────────────────────────────────
+    # SLOT CAPACITY — SlotConfig limits & eviction
+    # ─────────────────────────────────────────────────────────────────────
+
+    def configure_slot(self, engine_letter: str, config: Optional[SlotConfig]):
+        """Register capacity limits for a slot (None removes them)."""
+        if config is not None and config.encrypt_at_rest and not config.recipient \
+                and engine_letter not in self._key_providers:
+            raise ValueError(f"Slot '{engine_letter}' encrypts at rest but has no recipient "
+                             f"(age public key) or key provider")
+        strategy = None
+        if config is not None and config.isolation != IsolationLevel.NONE:
+            strategy = select_strategy(config.isolation, engine_letter)
+        with self._lock:
+            if config is None:
+                self._slot_configs.pop(engine_letter, None)
+            else:
+                self._slot_configs[engine_letter] = config
+            if strategy is None:
+                self._isolation.pop(engine_letter, None)
+            else:
+                self._isolation[engine_letter] = strategy
+
+    def get_slot_config(self, engine_letter: str) -> Optional[SlotConfig]:
+        return self._slot_configs.get(engine_letter)
+
+    def _key_provider(self, engine_letter: str) -> Optional[KeyProvider]:
+        """What seals the slot's payloads, or None if it doesn't encrypt at rest."""
+        config = self._slot_configs.get(engine_letter)
+        if config is None or not config.encrypt_at_rest:
+            return None
+        return (self._key_providers.get(engine_letter)
+                or AgeKeyProvider(config.recipient, self._age_identities))
+
+    def seal_source(self, engine_letter: str, source: str) -> Optional[bytes]:
+        """`source` sealed for the slot, or None if the slot doesn't encrypt at rest."""
+        provider = self._key_provider(engine_letter)
+        return seal(provider, source.encode('utf-8')) if provider is not None else None
+
+    def open_sealed(self, blob: bytes, engine_letter: str = '',
+                    code_hash: str = '') -> bytes:
+        """
+        The plaintext of a seal_source() blob (a plain `blob` as it is).
+
+        The slot's own provider is tried first, then every other one and
+        the age_identities; with `code_hash` the plaintext must hash to it.
+        Raises EncryptionError if none of them opens it.
+        """
+        if not is_sealed(blob):
+            return blob
+        own = self._key_provider(engine_letter) if engine_letter else None
+        candidates = [p for p in (own, *self._key_providers.values()) if p is not None]
+        if self._age_identities:
+            candidates.append(AgeKeyProvider(identities=self._age_identities))
+        for provider in candidates:
+            try:
+                plaintext = unseal(provider, blob)
+            except (EncryptionError, RuntimeError):
+                continue
+            if not code_hash or compute_code_hash(plaintext) == code_hash:
+                return plaintext
+        what = f"payload {code_hash[:12]}" if code_hash else "sealed payload"
+        raise EncryptionError(f"Cannot decrypt {what}: no key provider or age identity opens it")
+
+    def _load_payload(self, code_hash: str, engine_letter: str = '') -> bytes:
+        """The stored payload for `code_hash`, decrypted if it is sealed."""
+        return self.open_sealed(self._store.get(code_hash), engine_letter, code_hash)
+
+    def isolation_for(self, engine_letter: str) -> IsolationStrategy:
+        """The slot's isolation strategy (NAMESPACE may have been downgraded to PROCESS)."""
+        return self._isolation.get(engine_letter) or NoIsolation()
+
+    def slot_isolation(self, slot_id: str) -> IsolationStrategy:
+        """isolation_for() the engine row a registry slot is on."""
+        slot = self._registry.get_slot(slot_id)
+        return self.isolation_for(slot.address.rstrip('0123456789') if slot else '')
+
+    def _slot_occupants(self, engine_letter: str) -> List[StagedSnippet]:
+        """Live production entries plus snippets still in the pipeline for a slot."""
+        holding = (StagingPhase.QUEUED, StagingPhase.SPECULATING, StagingPhase.PASSED,
+                   StagingPhase.FAILED, StagingPhase.PENDING_SWAP, StagingPhase.PROMOTING,
+                   StagingPhase.PROMOTED)
+        with self._lock:
+            candidates = list(self._history) + list(self._staged.values())
+        return [s for s in candidates
+                if s.engine_letter == engine_letter and s.phase in holding]
+
+    def get_slot_usage(self, engine_letter: str) -> Dict[str, Any]:
+        """Current snippet count / byte total for a slot against its limits."""
+        occupants = self._slot_occupants(engine_letter)
+        config = self.get_slot_config(engine_letter)
+        return {
+            'slot': engine_letter,
+            'snippets': len(occupants),
+            'total_bytes': sum(len(s.code.encode('utf-8')) for s in occupants),
+            'live': sum(1 for s in occupants if s.phase == StagingPhase.PROMOTED),
+            'config': config.to_dict() if config else None,
+        }
+
+    def slot_report(self, engine_letter: str, since: float = 0.0,
+                    namespace: Optional[str] = None) -> SlotReport:
+        """
+        Spec result counts, spec_time percentiles, label stats and trend for
+        a slot, over snippets whose speculative run finished at or after
+        `since` (Unix seconds).  Raises ValueError for an unknown slot.
+        """
+        from .node_registry import LETTER_TO_ENGINE
+        if engine_letter not in LETTER_TO_ENGINE:
+            raise ValueError(f"Unknown slot '{engine_letter}'")
+        with self._lock:
+            candidates = list(self._history) + list(self._staged.values())
+        snippets = [s for s in candidates if self._in_namespace(s, namespace) is not None]
+        return build_slot_report(engine_letter, since, snippets,
+                                 self._promotions.records(engine_letter, None, namespace))
+
+    def _check_capacity(self, engine_letter: str, code: str, label: str,
+                        policy: LabelConflictPolicy,
+                        namespace: str = DEFAULT_NAMESPACE, exclude: str = ''):
+        """Raise SlotFullError if staging `code` would break the slot's limits."""
+        config = self._slot_configs.get(engine_letter)
+        if config is None:
+            return
+        occupants = [s for s in self._slot_occupants(engine_letter) if s.staging_id != exclude]
+        if policy == LabelConflictPolicy.OVERWRITE:
+            # The entries this snippet will replace free their share on promotion
+            occupants = [s for s in occupants
+                         if not (s.phase == StagingPhase.PROMOTED and s.label == label
+                                 and s.namespace == namespace)]
+        config.check(engine_letter, len(occupants),
+                     sum(len(s.code.encode('utf-8')) for s in occupants),
+                     len(code.encode('utf-8')))
+
+    def evict(self, engine_letter: str, count: int) -> List[StagedSnippet]:
+        """
+        Remove up to `count` live entries from a slot to free capacity.
+
+        Victims are chosen by the slot's EvictionPolicy (LRU uses the
+        registry's last execution time).  Their registry slots are cleared
+        and they move to EVICTED; saved files are kept.  Raises
+        SlotEvictionError if the policy is MANUAL.
+        """
+        config = self._slot_configs.get(engine_letter) or SlotConfig()
+
+        def last_used(s: StagedSnippet) -> float:
+            slot = self._registry.get_slot(s.registry_slot_id) if s.registry_slot_id else None
+            return slot.last_executed_at if slot and slot.last_executed_at else s.promoted_at
+
+        with self._lock:
+            live = [s for s in self._slot_occupants(engine_letter)
+                    if s.phase == StagingPhase.PROMOTED]
+            victims = choose_victims(config.eviction, live, count, last_used)
+            for snippet in victims:
+                if snippet.registry_slot_id:
+                    self._registry.clear_slot(snippet.registry_slot_id)
+                snippet.phase = StagingPhase.EVICTED
+                snippet.updated_at = time.time()
+
+        for snippet in victims:
+            self._audit.log(AuditEventType.EVICTED, snippet.staging_id, {
+                'slot_id': snippet.registry_slot_id,
+                'address': snippet.reserved_address,
+                'label': snippet.label,
+                'policy': config.eviction.value,
+            })
+        return victims
+
+    # ─────────────────────────────────────────────────────────────────────
+    # ARCHIVAL — expire old promotions (see snippet_archive)
+    # ─────────────────────────────────────────────────────────────────────
+
+    def apply_archival_policy(self, policy: ArchivalPolicy,
+                              now: Optional[float] = None) -> List[StagedSnippet]:
+        """
+        Archive the promotion records of every slot that `policy` selects
+        and return them (now in the ARCHIVED phase).
+
+        A live record's registry slot is cleared.  MOVE relocates its
+        saved file to cold_storage_path/<namespace>/<slot>/ and keeps the
+        record; DELETE removes both.  The baseline of a running canary, and
+        the control of a running A/B test, is left alone until it finishes.
+        """
+        if not policy.enabled:
+            return []
+        now = time.time() if now is None else now
+        baselines = ({r.baseline_id for r in self._canaries.running()}
+                     | {t.control.staging_id for t in self._ab_tests.running()})
+        with self._lock:
+            by_slot: Dict[str, List[StagedSnippet]] = {}
+            for s in list(self._history) + list(self._staged.values()):
+                if s.staging_id not in baselines:
+                    by_slot.setdefault(s.engine_letter, []).append(s)
+
+        archived = []
+        for slot in sorted(by_slot):
+            for snippet, reason in select_for_archive(policy, by_slot[slot], now):
+                if not self._archive_record(snippet, reason, policy, now):
+                    continue
+                archived.append(snippet)
+                if policy.on_archive is not None:
+                    try:
+                        policy.on_archive(snippet, reason)
+                    except Exception:
+                        pass                      # A failing handler doesn't stop the sweep
+        return archived
+
+    def _archive_record(self, snippet: StagedSnippet, reason: str,
+                        policy: Optional[ArchivalPolicy], now: float) -> bool:
+        """Archive one record; without a policy its file stays where it is."""
+        action = policy.action if policy is not None else None
+        with self._lock:
+            if snippet.phase.value not in ARCHIVABLE_PHASES:
+                return False                      # Changed since it was selected
+            was_live = snippet.phase == StagingPhase.PROMOTED
+            if was_live and snippet.registry_slot_id:
+                self._registry.clear_slot(snippet.registry_slot_id)
+            if action == ArchiveAction.MOVE and snippet.promoted_at:
+                # The store's blob may be shared with other versions, so
+                # cold storage gets a self-describing copy (header + body)
+                cold_dir = os.path.join(policy.cold_storage_path, snippet.namespace,
+                                        snippet.engine_letter)
+                os.makedirs(cold_dir, exist_ok=True)
+                plugin = self._engines.get(snippet.language)
+                ext = LANG_EXTENSIONS.get(snippet.language,
+                                          plugin.file_extension if plugin else '.txt')
+                target = os.path.join(cold_dir, f"{snippet.reserved_address or 'x0'}_"
+                                                f"{snippet.staging_id}{ext}")
+                text = self._make_file_header(snippet) + snippet.program
+                sealed = self.seal_source(snippet.engine_letter, text)
+                if sealed is not None:
+                    target += '.sealed'
+                with open(target, 'wb') as f:
+                    f.write(sealed if sealed is not None else text.encode('utf-8'))
+                snippet.saved_file_path = target
+            elif action == ArchiveAction.DELETE:
+                self._staged.pop(snippet.staging_id, None)
+                if snippet in self._history:
+                    self._history.remove(snippet)
+                if not any(s.code_hash == snippet.code_hash
+                           for s in itertools.chain(self._staged.values(), self._history)):
+                    self._store.delete(snippet.code_hash)
+            snippet.phase = StagingPhase.ARCHIVED
+            snippet.archived_at = now
+            snippet.updated_at = now
+
+        if action == ArchiveAction.DELETE:
+            self._index.remove(snippet.staging_id)
+        else:
+            self._index.put(snippet)
+        self._audit.log(AuditEventType.ARCHIVED, snippet.staging_id, {
+            'reason': reason,
+            'action': action.value if action is not None else 'keep',
+            'label': snippet.label,
+            'address': snippet.reserved_address,
+            'was_live': was_live,
+            'path': snippet.saved_file_path if action != ArchiveAction.DELETE else '',
+        })
+        return True
+
+    # ─────────────────────────────────────────────────────────────────────
+    # SCHEDULED RERUNS — re-execute live snippets (see snippet_schedule)
+    # ─────────────────────────────────────────────────────────────────────
+
+    def run_scheduled_reruns(self, now: Optional[float] = None) -> List[RerunRecord]:
+        """
+        Re-run every PROMOTED snippet on a slot with a SlotConfig.schedule
+        that fired since the snippet last re-ran (or was promoted).
+        Returns the new RerunRecords; a snippet whose rerun raised is
+        logged as ERROR and skipped.
+        """
+        now = time.time() if now is None else now
+        with self._lock:
+            schedules = {letter: config.cron for letter, config in self._slot_configs.items()
+                         if config.schedule}
+            due = [s for s in self._history
+                   if s.phase == StagingPhase.PROMOTED and s.engine_letter in schedules
+                   and due_for_rerun(schedules[s.engine_letter],
+                                     s.last_rerun_at or s.promoted_at, now)]
+        records = []
+        for snippet in due:
+            try:
+                records.append(self.rerun_snippet(snippet.staging_id, now=now))
+            except Exception as exc:
+                self._audit.log(AuditEventType.ERROR, snippet.staging_id, {
+                    'step': 'scheduled_rerun',
+                    'error': str(exc),
+                })
+        return records
+
+    def rerun_snippet(self, staging_id: str, now: Optional[float] = None,
+                      namespace: Optional[str] = None) -> RerunRecord:
+        """
+        Run a PROMOTED snippet again, isolated and with the arguments it
+        was speculated with, then its test cases, and append the outcome
+        to its rerun history (a failing case makes it CASE_FAIL).
+
+        The snippet's phase and spec_* fields are left alone; only
+        last_rerun_at moves.  A failing rerun right after a passing one
+        (or after the passing speculation, for the first rerun) is logged
+        as HEALTH_ALERT and published to webhook targets.  Raises
+        ValueError for an unknown snippet, PhaseError if it isn't live.
+        """
+        now = time.time() if now is None else now
+        with self._lock:
+            snippet = self._in_namespace(self.get_snippet(staging_id), namespace)
+            if snippet is None:
+                raise ValueError(f"No staged snippet with id '{staging_id}'")
+            if snippet.phase != StagingPhase.PROMOTED:
+                raise PhaseError(
+                    f"Snippet {staging_id} is in phase '{snippet.phase.value}', "
+                    f"cannot rerun (must be PROMOTED)"
+                )
+            specs = [ParameterSpec.from_dict(p) for p in snippet.parameters]
+            code = bind_parameters(snippet.program, specs, snippet.spec_arguments or None)
+            history = self._reruns.setdefault(staging_id,
+                                              RerunHistory(self._rerun_history_size))
+            previous = history.last()
+            previously_passed = (previous.spec_result == SpecResult.PASS.value
+                                 if previous is not None
+                                 else snippet.spec_result == SpecResult.PASS)
+
+        with span('rerun', language=snippet.language, staging_id=staging_id):
+            result = self._run_isolated(snippet.language, code, snippet.env,
+                                        isolation=self.isolation_for(snippet.engine_letter),
+                                        limits=self._snippet_limits(snippet))
+            cases = (self._run_cases(snippet)
+                     if result.get('success') and snippet.test_cases else [])
+        success, error, spec_result, _ = self._classify_run(snippet, result, cases)
+        record = RerunRecord(
+            staging_id=staging_id, ran_at=now, spec_result=spec_result.value,
+            spec_execution_time=result.get('execution_time', 0.0), success=success,
+            error=error[:2000], health_alert=previously_passed and not success)
+
+        with self._lock:
+            history.append(record)
+            snippet.last_rerun_at = now
+            snippet.updated_at = time.time()
+        self._index.put(snippet)
+        self._audit.log(AuditEventType.SNIPPET_RERUN, staging_id, record.to_dict())
+        if record.health_alert:
+            self._audit.log(AuditEventType.HEALTH_ALERT, staging_id, {
+                'address': snippet.reserved_address,
+                'label': snippet.label,
+                'spec_result': record.spec_result,
+                'error': record.error,
+            })
+            self._notify(AuditEventType.HEALTH_ALERT, snippet, {
+                'spec_result': record.spec_result,
+                'previous_result': SpecResult.PASS.value,
+                'rerun_at': now,
+                'error': record.error,
+            })
+        return record
+
+    def rerun_history(self, staging_id: str,
+                      namespace: Optional[str] = None) -> List[RerunRecord]:
+        """A snippet's scheduled reruns, oldest first (ValueError if unknown)."""
+        with self._lock:
+            if self._in_namespace(self.get_snippet(staging_id), namespace) is None:
+                raise ValueError(f"No staged snippet with id '{staging_id}'")
+            history = self._reruns.get(staging_id)
+            return history.records() if history is not None else []
+
+    # ─────────────────────────────────────────────────────────────────────
+    # REPLAY — re-execute a historical promotion (see snippet_replay)
+    # ─────────────────────────────────────────────────────────────────────
+
+    def replay(self, staging_id: str, options: Optional[ReplayOptions] = None,
+               now: Optional[float] = None,
+               namespace: Optional[str] = None) -> ReplayResult:
+        """
+        Run a snippet that was promoted again: the source stored under its
+        code_hash, with its env and the arguments it was speculated with,
+        isolated at its slot's level, then its test cases against the same
+        source.
+
+        Nothing about the snippet or its slot changes.  With
+        options.record_as the result is kept as a named snapshot of the
+        snippet's namespace (ValueError if the name is taken).  Raises
+        ValueError for an unknown snippet, ReplayError if it was never
+        promoted or its payload is no longer stored.
+        """
+        options = options or ReplayOptions()
+        now = time.time() if now is None else now
+        with self._lock:
+            snippet = self._in_namespace(self.get_snippet(staging_id), namespace)
+            if snippet is None:
+                raise ValueError(f"No staged snippet with id '{staging_id}'")
+            if not snippet.promoted_at:
+                raise ReplayError(
+                    f"Snippet {staging_id} was never promoted (phase "
+                    f"'{snippet.phase.value}'), nothing to replay")
+            key = (snippet.namespace, options.record_as)
+            if options.record_as and key in self._replay_snapshots:
+                raise ValueError(f"A replay snapshot named '{options.record_as}' already exists")
+            try:
+                source = self._load_payload(snippet.code_hash,
+                                            snippet.engine_letter).decode('utf-8')
+            except PayloadNotFoundError:
+                raise ReplayError(f"The payload of {staging_id} ({snippet.code_hash[:12]}) "
+                                  f"is no longer stored")
+            specs = [ParameterSpec.from_dict(p) for p in snippet.parameters]
+            code = bind_parameters(source, specs, snippet.spec_arguments or None)
+            isolation = self.isolation_for(snippet.engine_letter)
+
+        with span('replay', language=snippet.language, staging_id=staging_id):
+            result = self._run_isolated(snippet.language, code, snippet.env, isolation=isolation,
+                                        limits=self._snippet_limits(snippet))
+            cases = (self._run_cases(snippet, source=source)
+                     if result.get('success') and snippet.test_cases else [])
+        success, error, spec_result, check = self._classify_run(snippet, result, cases)
+        replayed = ReplayResult(
+            staging_id=staging_id, slot=snippet.engine_letter, label=snippet.label,
+            code_hash=snippet.code_hash, replayed_at=now, isolation=isolation.level.value,
+            spec_result=spec_result.value,
+            success=success, output=result.get('output', ''), error=error[:2000],
+            execution_time=result.get('execution_time', 0.0), output_value=check.value,
+            original_result=snippet.spec_result.value, original_output=snippet.spec_output,
+            snapshot=options.record_as)
+
+        if options.record_as:
+            with self._lock:
+                if key in self._replay_snapshots:
+                    raise ValueError(
+                        f"A replay snapshot named '{options.record_as}' already exists")
+                self._replay_snapshots[key] = replayed
+        self._metrics.record_replay(snippet.language, snippet.engine_letter,
+                                    replayed.spec_result)
+        data = replayed.to_dict()
+        data.pop('output')
+        self._audit.log(AuditEventType.SNIPPET_REPLAYED, staging_id, data)
+        return replayed
+
+    def replay_snapshot(self, name: str,
+                        namespace: Optional[str] = DEFAULT_NAMESPACE) -> ReplayResult:
+        """The replay recorded as `name` in `namespace` (ValueError if there is none)."""
+        with self._lock:
+            snapshot = self._replay_snapshots.get((namespace or DEFAULT_NAMESPACE, name))
+        if snapshot is None:
+            raise ValueError(f"No replay snapshot named '{name}'")
+        return snapshot
+
+    def replay_snapshots(self, namespace: Optional[str] = DEFAULT_NAMESPACE,
+                         staging_id: str = '') -> List[ReplayResult]:
+        """The recorded replays of `namespace` (of one snippet), oldest first."""
+        namespace = namespace or DEFAULT_NAMESPACE
+        with self._lock:
+            snapshots = [s for (ns, _), s in self._replay_snapshots.items()
+                         if ns == namespace and (not staging_id or s.staging_id == staging_id)]
+        return sorted(snapshots, key=lambda s: (s.replayed_at, s.snapshot))
+
+    # ─────────────────────────────────────────────────────────────────────
+    # REVALIDATION — re-run every live spec after an engine upgrade
+    # ─────────────────────────────────────────────────────────────────────
+
+    def revalidate_all(self, slot_filter: str = '',
+                       options: Optional[RevalidateOptions] = None,
+                       namespace: Optional[str] = None) -> RevalidateRun:
+        """
+        Re-run the spec of every PROMOTED snippet (on slot `slot_filter`;
+        '' = every slot) against the engines as they are now, in the
+        background, `options.concurrency` at a time.
+
+        Returns the started RevalidateRun: iterate it for one
+        RevalidateProgress per snippet, in completion order, then the
+        summary.  Phases never change; with options.update_metadata each
+        snippet's spec_result and spec_execution_time are overwritten.
+        Raises ValueError for an unknown slot.
+        """
+        from .node_registry import LETTER_TO_ENGINE
+        options = options or RevalidateOptions()
+        slot_filter = (slot_filter or '').strip()
+        if slot_filter and slot_filter not in LETTER_TO_ENGINE:
+            raise ValueError(f"Unknown slot '{slot_filter}'")
+        with self._lock:
+            candidates = list(self._history) + list(self._staged.values())
+        live = [s for s in candidates
+                if s.phase == StagingPhase.PROMOTED
+                and (not slot_filter or s.engine_letter == slot_filter)
+                and self._in_namespace(s, namespace) is not None]
+        live.sort(key=lambda s: (s.engine_letter, s.reserved_position, s.promoted_at))
+        return RevalidateRun([s.staging_id for s in live],
+                             lambda sid: self._revalidate(sid, options.update_metadata),
+                             options).start()
+
+    def _revalidate(self, staging_id: str, update_metadata: bool) -> RevalidateProgress:
+        """Run one live snippet's spec again; errors come back as a failed event."""
+        with self._lock:
+            snippet = self.get_snippet(staging_id)
+            progress = RevalidateProgress(
+                staging_id=staging_id, slot=snippet.engine_letter,
+                address=snippet.reserved_address, label=snippet.label,
+                original_result=snippet.spec_result.value,
+                original_spec_time=snippet.spec_execution_time)
+            specs = [ParameterSpec.from_dict(p) for p in snippet.parameters]
+        try:
+            code = bind_parameters(snippet.program, specs, snippet.spec_arguments or None)
+            with span('revalidate', language=snippet.language, staging_id=staging_id):
+                result = self._run_isolated(snippet.language, code, snippet.env,
+                                            isolation=self.isolation_for(snippet.engine_letter),
+                                            limits=self._snippet_limits(snippet))
+            cases = (self._run_cases(snippet)
+                     if result.get('success') and snippet.test_cases else [])
+        except Exception as exc:
+            progress.error = str(exc)
+            self._audit.log(AuditEventType.ERROR, staging_id, {
+                'step': 'revalidate',
+                'error': progress.error,
+            })
+            return progress
+        success, error, spec_result, _ = self._classify_run(snippet, result, cases)
+        progress.spec_result = spec_result.value
+        progress.spec_time = result.get('execution_time', 0.0)
+        progress.success = success
+        progress.error = error[:2000]
+
+        if update_metadata:
+            with self._lock:
+                if snippet.phase == StagingPhase.PROMOTED:
+                    snippet.spec_result = spec_result
+                    snippet.spec_execution_time = progress.spec_time
+                    snippet.updated_at = time.time()
+                    progress.updated = True
+            if progress.updated:
+                self._index.put(snippet)
+        data = progress.to_dict()
+        for key in ('event', 'staging_id', 'completed', 'total'):
+            data.pop(key)
+        self._audit.log(AuditEventType.SNIPPET_REVALIDATED, staging_id, data)
+        return progress
+
     # ─────────────────────────────────────────────────────────────────────
     # PHASE 2: SPECULATIVE EXECUTION — isolated dry-run
     # ─────────────────────────────────────────────────────────────────────
 
-    def speculate(self, staging_id: str) -> StagedSnippet:
+    @traced('RunSpec', staging_id_arg=0)
+    def speculate(self, staging_id: str,
+                  cancel_event: Optional[threading.Event] = None,
+                  arguments: Optional[Dict[str, Any]] = None,
+                  namespace: Optional[str] = None,
+                  force_run: bool = False) -> StagedSnippet:
         """
         Run the snippet in an ISOLATED executor (not the production one).
 
         For Python: creates a fresh PythonExecutor with an empty namespace.
         For subprocess languages: they're already isolated by design.
 
+        A snippet that declares parameters runs with `arguments` bound
+        (defaults fill the gaps).  They are validated before anything
+        executes: a violation raises ParameterError and leaves the
+        snippet in its current phase.
+
+        Raises CircuitOpenError, also leaving the phase alone, while the
+        circuit breaker for this code is open.
+
+        If `cancel_event` is set by the time the run finishes, the result
+        is discarded — whoever cancelled has already recorded the FAIL
+        (see cancel_speculation()).
+
+        The run's output can be followed live through output_stream().
+
+        A deterministic snippet's run is first looked up in the result
+        cache and, on a hit, not executed (spec_cached is set);
+        `force_run` runs it anyway and replaces the cached entry.
+
+        A snippet with test_cases then runs once per case (never from the
+        cache); their CaseResults land in spec_cases, and a failing case
+        makes the verdict CASE_FAIL.
+
         Returns the snippet with spec_* fields populated.
         """
         with self._lock:
-            snippet = self._staged.get(staging_id)
+            snippet = self._in_namespace(self._staged.get(staging_id), namespace)
             if snippet is None:
                 raise ValueError(f"No staged snippet with id '{staging_id}'")
             if snippet.phase not in (StagingPhase.QUEUED, StagingPhase.FAILED):
-                raise ValueError(
+                raise PhaseError(
                     f"Snippet {staging_id} is in phase '{snippet.phase.value}', "
                     f"cannot speculate (must be QUEUED or FAILED)"
                 )
+            code = self._bind_arguments(snippet, arguments)
+            self._breaker.allow(self._breaker_key(snippet))
+            key = (cache_key(snippet.code_hash, snippet.spec_arguments, snippet.env,
+                             snippet.resource_limits,
+                             self.isolation_for(snippet.engine_letter).level.value,
+                             snippet.namespace)
+                   if snippet.deterministic and self._result_cache is not None else '')
             snippet.phase = StagingPhase.SPECULATING
             snippet.updated_at = time.time()
             snippet.spec_started_at = time.time()
+        stream = self._streams.open(staging_id)
 
         self._audit.log(AuditEventType.SPEC_EXEC_STARTED, staging_id, {
             'language': snippet.language,
             'code_hash': snippet.code_hash,
             'reserved_address': snippet.reserved_address,
+            'arguments': snippet.spec_arguments,
         })
 
         try:
-            result = self._run_isolated(snippet.language, snippet.code)
+            result = None
+            if key and force_run:
+                self._result_cache.invalidate(key)
+            elif key:
+                result = self._result_cache.get(key)
+                self._metrics.record_cache_lookup(snippet.language, result is not None)
+            if result is None:
+                with span('execute', language=snippet.language, staging_id=staging_id):
+                    result = self._run_isolated(snippet.language, code, snippet.env,
+                                                on_output=stream.write,
+                                                cancel_event=cancel_event,
+                                                isolation=self.isolation_for(snippet.engine_letter),
+                                                limits=self._snippet_limits(snippet))
+                snippet.spec_cached = False
+                if key and not (result.get('timed_out') or result.get('resource_violation')
+                                or (cancel_event is not None and cancel_event.is_set())):
+                    self._result_cache.put(key, result)
+            else:
+                snippet.spec_cached = True
+            cases = (self._run_cases(snippet, cancel_event)
+                     if result.get('success') and snippet.test_cases else [])
 
             with self._lock:
+                if cancel_event is not None and cancel_event.is_set():
+                    return snippet
                 snippet.spec_output = result.get('output', '')
-                snippet.spec_error = result.get('error', '')
                 snippet.spec_execution_time = result.get('execution_time', 0.0)
-                snippet.spec_success = result.get('success', False)
+                snippet.resource_violation = result.get('resource_violation', '')
+                snippet.coverage_percent = result.get('coverage_percent')
+                snippet.benchmarks = list(result.get('benchmarks') or [])
+                snippet.mutants_tested = result.get('mutants_tested', 0)
+                snippet.survived_mutations = list(result.get('survived_mutations') or [])
+                snippet.spec_success, snippet.spec_error, snippet.spec_result, check = \
+                    self._classify_run(snippet, result, cases)
+                snippet.spec_output_value = check.value
+                snippet.spec_output_errors = check.error_dicts()
+                snippet.spec_cases = ([c.to_dict() for c in cases] if snippet.spec_result
+                                      in (SpecResult.PASS, SpecResult.CASE_FAIL) else [])
                 snippet.spec_variables = result.get('variables', {})
                 snippet.spec_completed_at = time.time()
                 snippet.updated_at = time.time()
@@ -431,20 +1694,46 @@ class StagingPipeline:
                         'execution_time': snippet.spec_execution_time,
                         'output_length': len(snippet.spec_output),
                         'variables_count': len(snippet.spec_variables),
+                        'structured_output': check.present,
+                        'coverage_percent': snippet.coverage_percent,
+                        'benchmarks': snippet.benchmarks,
+                        'mutants_tested': snippet.mutants_tested,
+                        'survived_mutations': snippet.survived_mutations,
+                        'cases_passed': len(snippet.spec_cases),
+                        'cached': snippet.spec_cached,
                     })
                 else:
                     snippet.phase = StagingPhase.FAILED
                     self._audit.log(AuditEventType.SPEC_EXEC_FAILED, staging_id, {
                         'success': False,
+                        'cached': snippet.spec_cached,
+                        'spec_result': snippet.spec_result.value,
+                        'resource_violation': snippet.resource_violation,
+                        'coverage_percent': snippet.coverage_percent,
+                        'survived_mutations': snippet.survived_mutations,
+                        'failed_cases': [c['name'] for c in snippet.spec_cases if not c['passed']],
+                        'output_errors': snippet.spec_output_errors,
                         'error': snippet.spec_error[:2000],
                         'execution_time': snippet.spec_execution_time,
                     })
 
         except Exception as exc:
             with self._lock:
+                if cancel_event is not None and cancel_event.is_set():
+                    return snippet
                 snippet.spec_error = str(exc)
                 snippet.spec_completed_at = time.time()
                 snippet.spec_success = False
+                snippet.spec_result = SpecResult.FAIL
+                snippet.spec_cached = False
+                snippet.resource_violation = ''
+                snippet.coverage_percent = None
+                snippet.benchmarks = []
+                snippet.mutants_tested = 0
+                snippet.survived_mutations = []
+                snippet.spec_cases = []
+                snippet.spec_output_value = None
+                snippet.spec_output_errors = []
                 snippet.phase = StagingPhase.FAILED
                 snippet.updated_at = time.time()
 
@@ -454,70 +1743,321 @@ class StagingPipeline:
                 'traceback': traceback.format_exc()[:3000],
             })
 
+        stream.close(snippet.spec_result.value, snippet.spec_execution_time,
+                     snippet.spec_output, snippet.spec_error)
+        self._record_run(snippet, snippet.spec_success)
+        if not snippet.spec_cached:
+            self._metrics.observe_spec_duration(
+                snippet.language, snippet.spec_completed_at - snippet.spec_started_at)
+        self._index.put(snippet)
+        return snippet
+
+    @property
+    def result_cache(self) -> Optional[ResultCache]:
+        return self._result_cache
+
+    @property
+    def locker(self) -> DistributedLocker:
+        return self._locker
+
+    @property
+    def logger(self) -> logging.Logger:
+        return self._logger
+
+    def speculate_batch(self, staging_ids: List[str], max_workers: int = 4,
+                        timeout_per_snippet: Optional[float] = None,
+                        cancel_event: Optional[threading.Event] = None,
+                        namespace: Optional[str] = None) -> List[StagedSnippet]:
+        """
+        Speculatively execute many snippets concurrently.
+
+        Runs up to `max_workers` snippets at a time (see SnippetExecutionPool)
+        so a batch takes roughly max(spec_time) per wave instead of the sum.
+        Crashed or timed-out snippets come back FAILED; the rest of the
+        batch is unaffected.
+
+        Returns the snippets ordered by slot (engine, position).
+        """
+        for sid in staging_ids:
+            if self._in_namespace(self._staged.get(sid), namespace) is None:
+                raise ValueError(f"No staged snippet with id '{sid}'")
+        from .snippet_pool import SnippetExecutionPool
+        pool = SnippetExecutionPool(self, max_workers=max_workers)
+        return pool.run_batch(staging_ids, timeout_per_snippet=timeout_per_snippet,
+                              cancel_event=cancel_event)
+
+    def cancel_speculation(self, staging_id: str, reason: str, tb: str = '',
+                           spec_result: SpecResult = SpecResult.FAIL) -> Optional[StagedSnippet]:
+        """
+        Abort a speculative run that is queued or in flight.
+
+        The snippet is marked FAILED with `reason` as its spec_error and
+        `spec_result` (TIMEOUT when a deadline was the cause).  A
+        result that arrives later from the abandoned run is ignored.
+        Snippets that already finished speculating are left untouched.
+        """
+        with self._lock:
+            snippet = self._staged.get(staging_id)
+            if snippet is None:
+                return None
+            if snippet.phase not in (StagingPhase.QUEUED, StagingPhase.SPECULATING):
+                return snippet
+            was_running = snippet.phase == StagingPhase.SPECULATING
+            now = time.time()
+            snippet.spec_error = reason
+            snippet.spec_success = False
+            snippet.spec_result = spec_result
+            snippet.spec_completed_at = now
+            if snippet.spec_started_at:
+                snippet.spec_execution_time = now - snippet.spec_started_at
+            snippet.phase = StagingPhase.FAILED
+            snippet.updated_at = now
+
+        data = {
+            'success': False,
+            'cancelled': True,
+            'spec_result': spec_result.value,
+            'error': reason,
+            'execution_time': snippet.spec_execution_time,
+        }
+        if tb:
+            data['traceback'] = tb[:3000]
+        self._audit.log(AuditEventType.SPEC_EXEC_FAILED, staging_id, data)
+        stream = self._streams.get(staging_id)
+        if stream is not None:
+            stream.close(spec_result.value, snippet.spec_execution_time, error=reason)
+        if was_running:
+            self._record_run(snippet, False)
+        self._index.put(snippet)
         return snippet
 
-    def _run_isolated(self, language: str, code: str) -> Dict[str, Any]:
+    def output_stream(self, staging_id: str,
+                      namespace: Optional[str] = None) -> OutputStream:
+        """
+        The OutputStream of a snippet's speculative run: live while it
+        runs, a replay once it has finished.  For a QUEUED snippet the
+        stream opens now and fills when speculation starts.  Runs the hub
+        no longer holds are replayed from spec_output / spec_error.
+        """
+        snippet = self.get_snippet(staging_id, namespace)
+        if snippet is None:
+            raise ValueError(f"No staged snippet with id '{staging_id}'")
+        stream = self._streams.get(staging_id)
+        if stream is not None:
+            return stream
+        if snippet.phase in (StagingPhase.QUEUED, StagingPhase.SPECULATING):
+            return self._streams.open(staging_id)
+        stream = OutputStream(staging_id)
+        stream.close(snippet.spec_result.value, snippet.spec_execution_time,
+                     snippet.spec_output, snippet.spec_error)
+        return stream
+
+    def _bind_arguments(self, snippet: StagedSnippet,
+                        arguments: Optional[Dict[str, Any]]) -> str:
+        """Source to execute for `snippet` with `arguments` bound (raises ParameterError)."""
+        specs = [ParameterSpec.from_dict(p) for p in snippet.parameters]
+        code = bind_parameters(snippet.program, specs, arguments)
+        snippet.spec_arguments = validate_arguments(specs, arguments) if specs else {}
+        return code
+
+    def _run_cases(self, snippet: StagedSnippet,
+                   cancel_event: Optional[threading.Event] = None,
+                   source: Optional[str] = None) -> List[CaseResult]:
+        """
+        Run `snippet` (or `source`, its stored payload) once per test case,
+        in order (stops early if cancelled).
+        """
+        specs = [ParameterSpec.from_dict(p) for p in snippet.parameters]
+        isolation = self.isolation_for(snippet.engine_letter)
+        results = []
+        for case in (TestCase.from_dict(c) for c in snippet.test_cases):
+            if cancel_event is not None and cancel_event.is_set():
+                break
+            code = bind_parameters(snippet.program if source is None else source, specs,
+                                   case.params or None)
+            with span('test_case', language=snippet.language, staging_id=snippet.staging_id,
+                      case=case.name):
+                result = self._run_isolated(snippet.language, code, snippet.env,
+                                            cancel_event=cancel_event, isolation=isolation,
+                                            limits=self._snippet_limits(snippet))
+            results.append(check_case(case, result))
+        return results
+
+    def bind_slot_arguments(self, slot_id: str, code: str,
+                            arguments: Optional[Dict[str, Any]],
+                            snippet: Optional[StagedSnippet] = None) -> str:
+        """
+        Bind `arguments` into the source committed to a registry slot,
+        using the parameters of the snippet promoted there (or of
+        `snippet`, the canary route_slot() picked).  Code from slots
+        without a parameterised snippet is returned unchanged (arguments
+        given for it raise ParameterError).
+        """
+        with self._lock:
+            if snippet is None:
+                snippet = next((h for h in reversed(self._history)
+                                if h.registry_slot_id == slot_id
+                                and h.phase == StagingPhase.PROMOTED), None)
+        specs = [ParameterSpec.from_dict(p) for p in snippet.parameters] if snippet else []
+        return bind_parameters(code, specs, arguments)
+
+    def slot_env(self, slot_id: str,
+                 snippet: Optional[StagedSnippet] = None) -> Dict[str, str]:
+        """
+        Environment to run a registry slot's code with: the env of the
+        snippet promoted there (or of `snippet`, the canary route_slot()
+        picked); {} for slots without one.
+        """
+        with self._lock:
+            if snippet is None:
+                snippet = next((h for h in reversed(self._history)
+                                if h.registry_slot_id == slot_id
+                                and h.phase == StagingPhase.PROMOTED), None)
+        return dict(snippet.env) if snippet else {}
+
+    def _run_isolated(self, language: str, code: str,
+                      env: Optional[Dict[str, str]] = None,
+                      on_output=None,
+                      cancel_event: Optional[threading.Event] = None,
+                      isolation: Optional[IsolationStrategy] = None,
+                      limits: Optional[ResourceLimits] = None) -> Dict[str, Any]:
         """
         Execute code in an ISOLATED environment.
 
-        For Python: creates a FRESH PythonExecutor (empty namespace) so
-        that the speculative run cannot see or pollute the production
-        namespace.
+        Subprocess-based languages (JS, Go, Rust, etc.) with an executor
+        in `executors` use it directly: it already runs in an isolated
+        child process, so the shared instance is safe.
+
+        Everything else dispatches to the Engine registered for the
+        language.  Python always does — its `executors` entry is the live
+        REPL, while PythonEngine runs in a FRESH executor (empty namespace)
+        that cannot see or pollute the production namespace.
+
+        `env` (already validated) is handed to the child process only.
+        `on_output(stream, text)` receives output as it is produced, from
+        executors with streams_output; the caller gets it all at the end
+        from the rest.  Setting `cancel_event` kills the run of a
+        `cancellable` executor (Go); other runs finish and the caller
+        discards their result.
+
+        `isolation` is the slot's strategy (snippet_isolation): `isolatable`
+        executors and engines with supports_isolation start the snippet's
+        process with it — Python then runs in a child interpreter.
 
-        For all subprocess-based languages (JS, Go, Rust, etc.): the
-        executor already runs in an isolated child process, so we can
-        use the shared instance safely.
+        `limits` are the snippet's own ResourceLimits, for `limitable`
+        executors and engines with supports_limits.
         """
         lang = language.lower().strip()
 
-        if lang == 'python':
-            # Create a disposable executor with a clean namespace
-            from .execution_engine import PythonExecutor
-            sandbox = PythonExecutor()
-            result = sandbox.execute(code)
-            variables = {}
-            if result.variables:
-                for k, v in result.variables.items():
-                    if k.startswith('__'):
-                        continue
-                    try:
-                        json.dumps(v)
-                        variables[k] = v
-                    except (TypeError, ValueError):
-                        variables[k] = repr(v)[:200]
+        executor = self._executors.get(lang) if lang != 'python' else None
+        if executor is not None:
+            kwargs: Dict[str, Any] = {'env': env} if env else {}
+            if on_output is not None and getattr(executor, 'streams_output', False):
+                kwargs['on_output'] = on_output
+            if cancel_event is not None and getattr(executor, 'cancellable', False):
+                kwargs['cancel_event'] = cancel_event
+            if isolation is not None and isolation.level != IsolationLevel.NONE:
+                if getattr(executor, 'isolatable', False):
+                    kwargs['isolation'] = isolation
+                else:
+                    warn_not_isolatable(lang, isolation)
+            if limits is not None and getattr(executor, 'limitable', False):
+                kwargs['resource_limits'] = limits
+            result = executor.execute(code, **kwargs)
             return {
                 'success': result.success,
                 'output': result.output or '',
                 'error': str(result.error) if result.error else '',
                 'execution_time': result.execution_time,
-                'variables': variables,
+                'variables': {},
+                'timed_out': getattr(result, 'timed_out', False),
+                'resource_violation': getattr(result, 'resource_violation', ''),
+                'structured_output': getattr(result, 'structured_output', ''),
+                'coverage_percent': getattr(result, 'coverage_percent', None),
+                'coverage_failed': getattr(result, 'coverage_failed', False),
+                'benchmarks': [b.to_dict() for b in getattr(result, 'benchmarks', None) or []],
+                'mutants_tested': getattr(result, 'mutants_tested', 0),
+                'survived_mutations': [m.to_dict() for m in
+                                       getattr(result, 'survived_mutations', None) or []],
+                'weak_spec': getattr(result, 'weak_spec', False),
+                'exit_code': getattr(result, 'exit_code', None),
             }
-        else:
-            # Subprocess-based: already isolated
-            executor = self._executors.get(lang)
-            if executor is None:
-                return {
-                    'success': False,
-                    'output': '',
-                    'error': f'No executor for language "{lang}"',
-                    'execution_time': 0,
-                    'variables': {},
-                }
-            result = executor.execute(code)
+
+        engine = self._engines.get(lang)
+        if engine is None:
             return {
-                'success': result.success,
-                'output': result.output or '',
-                'error': str(result.error) if result.error else '',
-                'execution_time': result.execution_time,
+                'success': False,
+                'output': '',
+                'error': f'No executor for language "{lang}"',
+                'execution_time': 0,
                 'variables': {},
             }
+        return engine.run_with(code.encode('utf-8'),
+                               RunOptions(env=env or {}, isolation=isolation,
+                                          resource_limits=limits)).to_dict()
+
+    def _supports_limits(self, language: str) -> bool:
+        """Whether _run_isolated() can apply a snippet's own ResourceLimits for `language`."""
+        executor = self._executors.get(language) if language != 'python' else None
+        if executor is not None:
+            return getattr(executor, 'limitable', False)
+        engine = self._engines.get(language)
+        return engine is not None and engine.supports_limits
+
+    @staticmethod
+    def _snippet_limits(snippet: StagedSnippet) -> Optional[ResourceLimits]:
+        return ResourceLimits(**snippet.resource_limits) if snippet.resource_limits else None
+
+    @staticmethod
+    def _spec_result(result: Dict[str, Any], success: bool, schema_failed: bool) -> SpecResult:
+        """How a _run_isolated() result is classified."""
+        return (
+            SpecResult.PASS if success
+            else SpecResult.SCHEMA_FAIL if schema_failed
+            else SpecResult.RESOURCE_EXCEEDED if result.get('resource_violation')
+            else SpecResult.TIMEOUT if result.get('timed_out')
+            else SpecResult.COVERAGE_FAIL if result.get('coverage_failed')
+            else SpecResult.WEAK_SPEC if result.get('weak_spec')
+            else SpecResult.FAIL
+        )
+
+    def _classify_run(self, snippet: StagedSnippet, result: Dict[str, Any],
+                      cases: Optional[List[CaseResult]] = None
+                      ) -> Tuple[bool, str, SpecResult, OutputCheck]:
+        """
+        (success, error, spec_result, output check) of a _run_isolated()
+        result of `snippet` and the CaseResults of its test cases: a
+        successful run whose fd 3 result misses the snippet's output_schema
+        is SCHEMA_FAIL, one with a failing case CASE_FAIL, the rest go by
+        _spec_result().
+        """
+        success = result.get('success', False)
+        error = result.get('error', '')
+        check = self._output_check(snippet, result)
+        schema_failed = success and bool(snippet.output_schema) and not check.passed
+        if schema_failed:
+            success = False
+            error = f"Structured output failed its schema: {check.describe()}"
+        spec_result = self._spec_result(result, success, schema_failed)
+        case_failures = describe_failures(cases or []) if success else ''
+        if case_failures:
+            success, error, spec_result = False, case_failures, SpecResult.CASE_FAIL
+        return success, error, spec_result, check
+
+    @staticmethod
+    def _output_check(snippet: StagedSnippet, result: Dict[str, Any]) -> OutputCheck:
+        """The run's fd 3 result, parsed and held to the snippet's output_schema."""
+        if not result.get('success'):
+            return OutputCheck()
+        schema = json.loads(snippet.output_schema) if snippet.output_schema else None
+        return check_output(result.get('structured_output', ''), schema)
 
     # ─────────────────────────────────────────────────────────────────────
     # PHASE 3: VERDICT — pass / fail / manual hold
     # ─────────────────────────────────────────────────────────────────────
 
     def verdict(self, staging_id: str, action: str = 'auto',
-                reason: str = '') -> StagedSnippet:
+                reason: str = '', namespace: Optional[str] = None) -> StagedSnippet:
         """
         Issue a verdict on a speculated snippet.
 
@@ -530,7 +2070,7 @@ class StagingPipeline:
         Returns the updated snippet.
         """
         with self._lock:
-            snippet = self._staged.get(staging_id)
+            snippet = self._in_namespace(self._staged.get(staging_id), namespace)
             if snippet is None:
                 raise ValueError(f"No staged snippet '{staging_id}'")
 
@@ -555,7 +2095,7 @@ class StagingPipeline:
                     self._archive_snippet(snippet)
                     return snippet
                 else:
-                    raise ValueError(
+                    raise PhaseError(
                         f"Cannot auto-verdict snippet in phase '{snippet.phase.value}' "
                         f"(must be PASSED or FAILED)"
                     )
@@ -592,60 +2132,179 @@ class StagingPipeline:
                 raise ValueError(f"Unknown verdict action: '{action}'")
 
     # ─────────────────────────────────────────────────────────────────────
-    # PHASE 4: PROMOTE — write to disk, ledger, registry
+    # PHASE 4: PROMOTE — store, ledger, registry
     # ─────────────────────────────────────────────────────────────────────
 
-    def promote(self, staging_id: str) -> StagedSnippet:
+    @traced('PromoteSnippet', staging_id_arg=0)
+    def promote(self, staging_id: str, skip_lint: bool = False,
+                canary: Optional[CanaryConfig] = None,
+                namespace: Optional[str] = None,
+                graceful_swap: Optional[GracefulSwap] = None) -> StagedSnippet:
         """
         Promote a PASSED snippet to production.
 
+        If a lint gate is configured for the snippet's language it runs
+        first; findings fail the snippet with spec_result LINT_FAIL and
+        the diagnostics in `lint_diagnostics`.  `skip_lint` bypasses the
+        gate for emergency overrides (the bypass is audited).  With a
+        benchmark_regression_gate, benchmarks that slowed down past it
+        against the live version of the label fail the snippet with
+        spec_result BENCH_REGRESSION.
+
+        With `canary` the snippet does not take over its slot at once: it
+        enters the CANARY phase and serves a growing share of the live
+        version's executions until the controller promotes it or rolls it
+        back (see snippet_canary).  Returns the snippet in CANARY phase.
+
         Steps:
-            1. Write the code to `snippets/<lang>/<staging_id>.<ext>`
-            2. Create a synthetic node in the SessionLedger
+            1. Store the source payload in the content-addressable store
+               (skipped if an identical body is already stored; sealed
+               first on a slot that encrypts at rest); the record's
+               saved_file_path is the stored blob
+            2. Create a synthetic node in the SessionLedger, pointing at
+               the payload's digest
             3. Commit the node to the reserved slot in the NodeRegistry
             4. Log every step to the audit trail
 
+        Under the OVERWRITE label policy an entry with the same label that
+        is already live on the slot is superseded: the new version takes
+        over its position (so the address keeps serving that label) and
+        the old one can be brought back with rollback().
+
+        With `graceful_swap` such a supersession waits for the executions of
+        the live version already running to finish: the snippet is
+        PENDING_SWAP until they drain (or drain_timeout passes and they are
+        cancelled), and is promoted then (see snippet_swap).  Returns the
+        snippet in PENDING_SWAP phase while it waits.
+
+        On a slot whose SlotConfig has require_approvals, the gates run
+        and then a pending ApprovalRecord is opened instead: the snippet
+        stays PASSED and is promoted by the approve_promotion() call that
+        completes it (see snippet_approvals).
+
+        Steps 1-4 hold the slot's promotion lock (`locker`), so instances
+        sharing a locker backend promote onto a slot one at a time.
+
         Returns the snippet in PROMOTED phase.
-        Raises ValueError if the snippet is not in PASSED phase.
+        Raises ValueError if the snippet is not in PASSED phase,
+        CircuitOpenError if its code's circuit breaker is not closed,
+        LintFailedError if the lint gate rejects it, BenchmarkRegressionError
+        if its benchmarks fall behind the live version's, LabelConflictError
+        if the policy is REJECT and the label went live after this snippet
+        was queued, PendingApprovalError while approvals are missing, and
+        LockUnavailableError if another promotion holds the slot for longer
+        than promotion_lock_wait.
         """
+        snippet = self._staged.get(staging_id)
+        if snippet is not None and self._in_namespace(snippet, namespace) is None:
+            raise ValueError(f"No staged snippet '{staging_id}'")
+        if snippet is not None:
+            self._breaker.check(self._breaker_key(snippet))
+            if snippet.requires:
+                self._check_dependencies(snippet)
+
+        with span('lint_gate', staging_id=staging_id, skip_lint=skip_lint):
+            self._lint_gate(staging_id, skip_lint)
+        self._benchmark_gate(staging_id)
+        if canary is not None and graceful_swap is not None:
+            raise ValueError("A promotion is either a canary or a graceful swap, not both")
+        self._approval_gate(staging_id, skip_lint, canary, graceful_swap)
+
+        if canary is not None:
+            return self._start_canary(staging_id, canary)
+        if graceful_swap is not None:
+            return self._start_swap(staging_id, graceful_swap)
+        return self._promote(staging_id)
+
+    def _promote(self, staging_id: str) -> StagedSnippet:
+        """promote() once the gates have passed: steps 1-4 under the slot's lock."""
+        snippet = self._staged.get(staging_id)
+        if snippet is None:
+            raise ValueError(f"No staged snippet '{staging_id}'")
+        token = self._lock_slot(snippet)
+        try:
+            return self._promote_locked(staging_id)
+        finally:
+            self._unlock_slot(token, staging_id)
+
+    def _lock_slot(self, snippet: StagedSnippet) -> LockToken:
+        """Take the promotion lock of the snippet's slot (LockUnavailableError if busy)."""
+        try:
+            return self._locker.lock(slot_lock_resource(snippet.engine_letter),
+                                    ttl=self._promotion_lock_ttl,
+                                    wait=self._promotion_lock_wait)
+        except LockError as exc:
+            self._audit.log(AuditEventType.ERROR, snippet.staging_id, {
+                'step': 'slot_lock',
+                'error': str(exc),
+            })
+            raise
+
+    def _unlock_slot(self, token: LockToken, staging_id: str):
+        """Release a promotion lock; a lease that ran out is audited, not raised."""
+        try:
+            self._locker.unlock(token)
+        except LockError as exc:
+            # The promotion outlived promotion_lock_ttl — another instance
+            # may have promoted onto the slot alongside it
+            self._audit.log(AuditEventType.ERROR, staging_id, {
+                'step': 'slot_unlock',
+                'error': str(exc),
+                'lock': token.to_dict(),
+            })
+
+    def _promote_locked(self, staging_id: str) -> StagedSnippet:
+        """Steps 1-4 of promote(), with the slot's promotion lock held."""
         with self._lock:
             snippet = self._staged.get(staging_id)
             if snippet is None:
                 raise ValueError(f"No staged snippet '{staging_id}'")
             if snippet.phase != StagingPhase.PASSED:
-                raise ValueError(
+                raise PhaseError(
                     f"Cannot promote snippet in phase '{snippet.phase.value}' "
                     f"(must be PASSED)"
                 )
+            live = self._live_entries(snippet.engine_letter, snippet.label,
+                                      exclude=staging_id, namespace=snippet.namespace)
+            if live and snippet.label_policy == LabelConflictPolicy.REJECT:
+                raise LabelConflictError(
+                    f"Label '{snippet.label}' went live on slot '{snippet.engine_letter}' "
+                    f"({live[-1].reserved_address}) after {staging_id} was queued"
+                )
             snippet.phase = StagingPhase.PROMOTING
             snippet.updated_at = time.time()
 
+            # OVERWRITE: take over the position of the newest live entry,
+            # keeping our own reservation until the promotion succeeds
+            superseded = live if snippet.label_policy == LabelConflictPolicy.OVERWRITE else []
+            own_position, own_address = snippet.reserved_position, snippet.reserved_address
+            if superseded:
+                snippet.reserved_position = superseded[-1].reserved_position
+                snippet.reserved_address = superseded[-1].reserved_address
+
         self._audit.log(AuditEventType.PROMOTION_STARTED, staging_id, {
             'reserved_address': snippet.reserved_address,
             'code_hash': snippet.code_hash,
+            'supersedes': [s.staging_id for s in superseded],
         })
 
         try:
-            # ── Step 1: Write code to disk ────────────────────────────────
-            ext = LANG_EXTENSIONS.get(snippet.language, '.txt')
-            # Traceable filename: <slot_address>_<staging_id>_<timestamp>.<ext>
-            ts = time.strftime('%Y%m%dT%H%M%S', time.gmtime(time.time()))
-            addr = snippet.reserved_address or 'x0'
-            filename = f"{addr}_{snippet.staging_id}_{ts}{ext}"
-            lang_dir = os.path.join(self._snippets_dir, snippet.language)
-            os.makedirs(lang_dir, exist_ok=True)
-            file_path = os.path.join(lang_dir, filename)
-
-            # Write with header comment
-            header = self._make_file_header(snippet)
-            with open(file_path, 'w', encoding='utf-8') as f:
-                f.write(header + snippet.code)
-
-            snippet.saved_file_path = file_path
+            # ── Step 1: Store the payload (once per code_hash) ──────────
+            # The body lives only in the content-addressed store; the
+            # record, index and ledger refer to it by its digest
+            # A slot that encrypts at rest gets the sealed body; code_hash
+            # stays the plaintext's
+            body = snippet.program.encode('utf-8')
+            sealed = self.seal_source(snippet.engine_letter, snippet.program)
+            newly_stored = self._store.put(snippet.code_hash, sealed if sealed is not None
+                                           else body, sealed=sealed is not None)
+            snippet.saved_file_path = self._store.locate(snippet.code_hash)
             self._audit.log(AuditEventType.FILE_WRITTEN, staging_id, {
-                'path': file_path,
-                'size': len(header) + len(snippet.code),
+                'path': snippet.saved_file_path,
+                'size': len(body),
                 'code_hash': snippet.code_hash,
+                'payload_deduplicated': not newly_stored,
+                'sealed': sealed is not None,
             })
 
             # ── Step 2: Create a synthetic node in the SessionLedger ──────
@@ -659,12 +2318,17 @@ class StagingPipeline:
 
             # ── Step 3: Commit node to the reserved registry slot ─────────
             from .node_registry import SlotPermissionSet
-            slot = self._registry.commit_node(
-                node_id=node_id,
-                engine_name=snippet.reserved_engine,
-                position=snippet.reserved_position,
-                permissions=SlotPermissionSet(get=True, push=True, post=False, delete=False),
-            )
+            with self._lock:
+                # Held across clear + commit so no queue_snippet() can grab
+                # the superseded position in between
+                for old in superseded:
+                    self._supersede(old, snippet)
+                slot = self._registry.commit_node(
+                    node_id=node_id,
+                    engine_name=snippet.reserved_engine,
+                    position=snippet.reserved_position,
+                    permissions=SlotPermissionSet(get=True, push=True, post=False, delete=False),
+                )
 
             if slot:
                 snippet.registry_slot_id = slot.slot_id
@@ -694,8 +2358,10 @@ class StagingPipeline:
                 snippet.phase = StagingPhase.PROMOTED
                 snippet.promoted_at = time.time()
                 snippet.updated_at = time.time()
+                snippet.superseded_ids = [s.staging_id for s in superseded]
                 # Release the reservation (the real slot is now committed)
-                self._release_position(snippet.reserved_engine, snippet.reserved_position)
+                self._release_position(snippet.reserved_engine, own_position)
+            self._promotions.record_promotion(snippet)
 
             self._audit.log(AuditEventType.PROMOTION_COMPLETED, staging_id, {
                 'file_path': snippet.saved_file_path,
@@ -705,6 +2371,8 @@ class StagingPipeline:
                 'promoted_at': snippet.promoted_at,
                 'total_staging_time': snippet.promoted_at - snippet.created_at,
             })
+            self._announce_promotion(snippet)
+            self._index.put(snippet)
 
             self._archive_snippet(snippet)
             return snippet
@@ -714,6 +2382,15 @@ class StagingPipeline:
                 snippet.phase = StagingPhase.FAILED
                 snippet.spec_error = f"Promotion failed: {exc}"
                 snippet.updated_at = time.time()
+                if superseded:
+                    if snippet.registry_slot_id:
+                        self._registry.clear_slot(snippet.registry_slot_id)
+                        snippet.registry_slot_id = ''
+                    snippet.reserved_position = own_position
+                    snippet.reserved_address = own_address
+            for old in superseded:
+                if old.phase == StagingPhase.SUPERSEDED:
+                    self._reinstall(old)
             self._audit.log(AuditEventType.ERROR, staging_id, {
                 'step': 'promote',
                 'error': str(exc),
@@ -721,17 +2398,574 @@ class StagingPipeline:
             })
             raise
 
-    def _make_file_header(self, snippet: StagedSnippet) -> str:
-        """Generate a metadata header comment for saved snippet files."""
-        lang = snippet.language
-        # Pick comment style
-        if lang in ('python', 'ruby', 'r', 'bash'):
-            prefix = '#'
-        elif lang in ('javascript', 'typescript', 'java', 'go',
-                       'csharp', 'kotlin', 'swift', 'rust', 'c', 'cpp'):
-            prefix = '//'
-        else:
-            prefix = '#'
+    def _lint_gate(self, staging_id: str, skip_lint: bool):
+        """Run the pre-promotion linter for a PASSED snippet (raises LintFailedError)."""
+        snippet = self._staged.get(staging_id)
+        if snippet is None or snippet.phase != StagingPhase.PASSED:
+            return   # promote() reports the real problem
+        linter = self._linters.get(snippet.language)
+        if linter is None:
+            return
+        if skip_lint:
+            self._audit.log(AuditEventType.LINT_SKIPPED, staging_id, {
+                'reason': 'skip_lint override',
+                'code_hash': snippet.code_hash,
+            })
+            return
+
+        # Parameterised snippets are linted as they ran (unbound they don't compile)
+        code = snippet.program
+        if snippet.parameters:
+            code = bind_parameters(code, [ParameterSpec.from_dict(p) for p in snippet.parameters],
+                                   snippet.spec_arguments)
+        result = linter.lint(code)
+        diagnostics = [d.to_dict() for d in result.diagnostics]
+        if result.passed:
+            with self._lock:
+                snippet.lint_diagnostics = []
+            self._audit.log(AuditEventType.LINT_PASSED, staging_id, {
+                'skipped_analyzers': result.skipped,
+                'duration': result.duration,
+            })
+            return
+
+        with self._lock:
+            if snippet.phase == StagingPhase.PASSED:
+                snippet.phase = StagingPhase.FAILED
+                snippet.spec_result = SpecResult.LINT_FAIL
+                snippet.spec_error = '\n'.join(
+                    f"{d['line']}:{d['column']}: [{d['analyzer']}] {d['message']}"
+                    for d in diagnostics)
+                snippet.lint_diagnostics = diagnostics
+                snippet.updated_at = time.time()
+        self._index.put(snippet)
+        self._audit.log(AuditEventType.LINT_FAILED, staging_id, {
+            'spec_result': SpecResult.LINT_FAIL.value,
+            'diagnostics': diagnostics,
+            'skipped_analyzers': result.skipped,
+        })
+        raise LintFailedError(
+            f"Lint gate rejected {staging_id}: {len(diagnostics)} diagnostic(s)", result)
+
+    def _benchmark_regressions(self, snippet: StagedSnippet, benchmarks: List[Dict[str, Any]]):
+        """(live version, regressions) of `benchmarks` against the label's live version."""
+        live = self._live_entries(snippet.engine_letter, snippet.label,
+                                  exclude=snippet.staging_id, namespace=snippet.namespace)
+        if not live or not live[-1].benchmarks or not benchmarks:
+            return (live[-1] if live else None), []
+        return live[-1], find_regressions(
+            [BenchmarkResult.from_dict(b) for b in live[-1].benchmarks],
+            [BenchmarkResult.from_dict(b) for b in benchmarks],
+            self._benchmark_regression_gate)
+
+    def _benchmark_gate(self, staging_id: str):
+        """Hold a PASSED snippet's benchmarks to the live version's (raises BenchmarkRegressionError)."""
+        if not self._benchmark_regression_gate:
+            return
+        with self._lock:
+            snippet = self._staged.get(staging_id)
+            if snippet is None or snippet.phase != StagingPhase.PASSED:
+                return   # promote() reports the real problem
+            baseline, regressions = self._benchmark_regressions(snippet, snippet.benchmarks)
+            if not regressions:
+                return
+            snippet.phase = StagingPhase.FAILED
+            snippet.spec_result = SpecResult.BENCH_REGRESSION
+            snippet.spec_error = '\n'.join(r.describe() for r in regressions)
+            snippet.updated_at = time.time()
+        self._index.put(snippet)
+        self._audit.log(AuditEventType.BENCHMARK_REGRESSED, staging_id, {
+            'spec_result': SpecResult.BENCH_REGRESSION.value,
+            'baseline_id': baseline.staging_id,
+            'max_percent': self._benchmark_regression_gate,
+            'regressions': [r.to_dict() for r in regressions],
+        })
+        raise BenchmarkRegressionError(
+            f"{staging_id} is slower than {baseline.staging_id} by more than "
+            f"{self._benchmark_regression_gate:g}%: "
+            + '; '.join(r.describe() for r in regressions), regressions)
+
+    # ─────────────────────────────────────────────────────────────────────
+    # DRY RUN — every promotion gate, nothing committed (see snippet_dryrun)
+    # ─────────────────────────────────────────────────────────────────────
+
+    def dry_run_promote(self, staging_id: str, skip_lint: bool = False,
+                        arguments: Optional[Dict[str, Any]] = None,
+                        namespace: Optional[str] = None) -> DryRunReport:
+        """
+        Check whether speculating and promoting the snippet would succeed,
+        without doing either: returns a DryRunReport with one GateResult
+        per gate.  `arguments` default to the ones its last run was bound
+        with.  The snippet, the registry, the store, the audit trail, the
+        breaker and metrics are left exactly as they were.
+        """
+        with self._lock:
+            snippet = self._in_namespace(self._staged.get(staging_id), namespace)
+            if snippet is None:
+                raise ValueError(f"No staged snippet '{staging_id}'")
+            snap = replace(snippet)
+        if arguments is None:
+            arguments = snap.spec_arguments or None
+        report = DryRunReport(staging_id)
+        program = {'code': snap.program}
+
+        def run(name, check) -> GateStatus:
+            started = time.perf_counter()
+            try:
+                status, detail, data = check()
+            except Exception as exc:
+                status, detail, data = GateStatus.FAILED, str(exc), {}
+            report.gates.append(GateResult(name, status, detail,
+                                           round(time.perf_counter() - started, 6), data))
+            return status
+
+        def phase():
+            if snap.phase in (StagingPhase.QUEUED, StagingPhase.FAILED, StagingPhase.PASSED):
+                return GateStatus.PASSED, f"phase '{snap.phase.value}'", {}
+            return GateStatus.FAILED, f"Cannot promote snippet in phase '{snap.phase.value}'", {}
+
+        def circuit():
+            self._breaker.check(self._breaker_key(snap))
+            return GateStatus.PASSED, 'closed', {}
+
+        def label():
+            live = self._live_entries(snap.engine_letter, snap.label,
+                                      exclude=staging_id, namespace=snap.namespace)
+            if live and snap.label_policy == LabelConflictPolicy.REJECT:
+                return (GateStatus.FAILED, f"Label '{snap.label}' is already in production "
+                        f"({live[-1].reserved_address}, {live[-1].staging_id})", {})
+            supersedes = [s.staging_id for s in live
+                          if snap.label_policy == LabelConflictPolicy.OVERWRITE]
+            return GateStatus.PASSED, '', {'supersedes': supersedes} if supersedes else {}
+
+        def dependencies():
+            if not snap.requires:
+                return GateStatus.SKIPPED, 'no requires', {}
+            self._check_dependencies(snap)
+            return GateStatus.PASSED, '', {'requires': snap.requires}
+
+        def capacity():
+            if self._slot_configs.get(snap.engine_letter) is None:
+                return GateStatus.SKIPPED, 'no slot limits', {}
+            self._check_capacity(snap.engine_letter, snap.code, snap.label, snap.label_policy,
+                                 snap.namespace, exclude=staging_id)
+            return GateStatus.PASSED, '', self.get_slot_usage(snap.engine_letter)
+
+        def approval():
+            config = self._slot_configs.get(snap.engine_letter)
+            if config is None or not config.require_approvals:
+                return GateStatus.SKIPPED, 'slot not protected', {}
+            record = self._approvals.get(staging_id)
+            data = record.to_dict() if record is not None else {}
+            if record is not None and record.status == ApprovalStatus.APPROVED:
+                return GateStatus.PASSED, 'approved', data
+            given = len(record.approvals) if record is not None else 0
+            return (GateStatus.FAILED, f"{given}/{config.require_approvals} approvals", data)
+
+        def format_():
+            plugin = self._engines.get(snap.language)
+            if plugin is None:
+                return GateStatus.SKIPPED, f"no engine for '{snap.language}'", {}
+            formatted = plugin.format_for_stage(snap.code.encode('utf-8'))
+            if isinstance(formatted, bytes):
+                formatted = formatted.decode('utf-8')
+            return (GateStatus.PASSED, 'unchanged' if formatted == snap.code else 'would reformat',
+                    {})
+
+        def parameters():
+            specs = [ParameterSpec.from_dict(p) for p in snap.parameters]
+            if not specs and not arguments:
+                return GateStatus.SKIPPED, 'no parameters', {}
+            try:
+                program['code'] = bind_parameters(snap.program, specs, arguments)
+            except ParameterSchemaError as pe:
+                return GateStatus.FAILED, str(pe), {'errors': pe.error_dicts()}
+            return GateStatus.PASSED, '', {'arguments': validate_arguments(specs, arguments)}
+
+        def speculation():
+            result = self._run_isolated(snap.language, program['code'], snap.env,
+                                        isolation=self.isolation_for(snap.engine_letter),
+                                        limits=self._snippet_limits(snap))
+            check = self._output_check(snap, result)
+            schema_failed = bool(snap.output_schema) and result.get('success') and not check.passed
+            spec_result = self._spec_result(result, result.get('success') and not schema_failed,
+                                            schema_failed)
+            cases = (self._run_cases(snap)
+                     if spec_result == SpecResult.PASS and snap.test_cases else [])
+            case_failures = describe_failures(cases)
+            if case_failures:
+                spec_result = SpecResult.CASE_FAIL
+            data = {'spec_result': spec_result.value,
+                    'execution_time': result.get('execution_time', 0.0),
+                    'output': (result.get('output') or '')[:5000]}
+            if result.get('coverage_percent') is not None:
+                data['coverage_percent'] = result['coverage_percent']
+            if result.get('benchmarks'):
+                data['benchmarks'] = program['benchmarks'] = result['benchmarks']
+            if result.get('mutants_tested'):
+                data['mutants_tested'] = result['mutants_tested']
+                data['survived_mutations'] = result.get('survived_mutations') or []
+            if check.present:
+                data['output_value'] = check.value
+            if cases:
+                data['cases'] = [c.to_dict() for c in cases]
+            if spec_result == SpecResult.PASS:
+                return GateStatus.PASSED, '', data
+            if case_failures:
+                return GateStatus.FAILED, case_failures[:2000], data
+            if schema_failed:
+                data['output_errors'] = check.error_dicts()
+                return GateStatus.FAILED, check.describe()[:2000], data
+            return GateStatus.FAILED, (result.get('error') or spec_result.value)[:2000], data
+
+        def lint():
+            linter = self._linters.get(snap.language)
+            if linter is None:
+                return GateStatus.SKIPPED, f"no linter for '{snap.language}'", {}
+            if skip_lint:
+                return GateStatus.SKIPPED, 'skip_lint override', {}
+            result = linter.lint(program['code'])
+            data = {'diagnostics': [d.to_dict() for d in result.diagnostics],
+                    'skipped_analyzers': result.skipped}
+            if result.passed:
+                return GateStatus.PASSED, '', data
+            return GateStatus.FAILED, f"{len(result.diagnostics)} diagnostic(s)", data
+
+        def benchmarks():
+            if not self._benchmark_regression_gate:
+                return GateStatus.SKIPPED, 'no benchmark regression gate', {}
+            if not program.get('benchmarks'):
+                return GateStatus.SKIPPED, 'no benchmarks', {}
+            baseline, regressions = self._benchmark_regressions(snap, program['benchmarks'])
+            if baseline is None or not baseline.benchmarks:
+                return GateStatus.SKIPPED, 'no benchmarked live version', {}
+            data = {'baseline_id': baseline.staging_id,
+                    'regressions': [r.to_dict() for r in regressions]}
+            if regressions:
+                return GateStatus.FAILED, '; '.join(r.describe() for r in regressions), data
+            return GateStatus.PASSED, '', data
+
+        for name, check in ((GATE_PHASE, phase), (GATE_CIRCUIT, circuit), (GATE_LABEL, label),
+                            (GATE_DEPENDENCIES, dependencies), (GATE_CAPACITY, capacity),
+                            (GATE_APPROVAL, approval), (GATE_FORMAT, format_)):
+            run(name, check)
+        if run(GATE_PARAMETERS, parameters) == GateStatus.FAILED:
+            for name in (GATE_SPECULATION, GATE_LINT, GATE_BENCHMARKS):
+                report.gates.append(GateResult(name, GateStatus.SKIPPED,
+                                               'parameters gate failed'))
+        else:
+            run(GATE_SPECULATION, speculation)
+            run(GATE_LINT, lint)
+            run(GATE_BENCHMARKS, benchmarks)
+        return report
+
+    # ── Approvals (protected slots) ──────────────────────────────────────
+
+    def _needs_approval(self, snippet: StagedSnippet) -> bool:
+        config = self._slot_configs.get(snippet.engine_letter)
+        if config is None or not config.require_approvals:
+            return False
+        record = self._approvals.get(snippet.staging_id)
+        return record is None or record.status != ApprovalStatus.APPROVED
+
+    def _approval_gate(self, staging_id: str, skip_lint: bool,
+                       canary: Optional[CanaryConfig],
+                       graceful_swap: Optional[GracefulSwap] = None):
+        """Open (or repeat) the pending approval of a PASSED snippet on a protected slot."""
+        with self._lock:
+            snippet = self._staged.get(staging_id)
+            if (snippet is None or snippet.phase != StagingPhase.PASSED
+                    or not self._needs_approval(snippet)):
+                return
+            record = self._approvals.get(staging_id)
+            if record is None or record.status == ApprovalStatus.REJECTED:
+                record = ApprovalRecord(
+                    staging_id=staging_id, slot=snippet.engine_letter,
+                    namespace=snippet.namespace,
+                    required=self._slot_configs[snippet.engine_letter].require_approvals,
+                    skip_lint=skip_lint, canary=canary, graceful_swap=graceful_swap)
+                self._approvals[staging_id] = record
+                self._audit.log(AuditEventType.APPROVAL_REQUESTED, staging_id, {
+                    'slot': record.slot,
+                    'required': record.required,
+                    'requested_at': record.requested_at,
+                })
+        raise PendingApprovalError(record)
+
+    def _approval_record(self, staging_id: str, namespace: Optional[str]) -> ApprovalRecord:
+        record = self._approvals.get(staging_id)
+        if record is None or (namespace is not None and record.namespace != namespace):
+            raise ApprovalError(f"No approval requested for '{staging_id}'")
+        return record
+
+    def approve_promotion(self, staging_id: str, approver_id: str,
+                          namespace: Optional[str] = None) -> ApprovalRecord:
+        """
+        Record `approver_id`'s approval of a pending promotion.  The
+        approval that reaches the slot's require_approvals promotes the
+        snippet before this returns (errors from that promotion are
+        raised; the record stays APPROVED, so promote() can be retried).
+        Raises ApprovalError for an unknown or closed record, or an
+        approver who has already approved it.
+        """
+        with self._lock:
+            record = self._approval_record(staging_id, namespace)
+            complete = record.approve(approver_id)
+            approval = record.approvals[-1]
+            if complete:
+                record.status = ApprovalStatus.APPROVED
+                record.resolved_at = approval.approved_at
+            self._audit.log(AuditEventType.APPROVAL_GRANTED, staging_id, {
+                'approver_id': approval.approver_id,
+                'approved_at': approval.approved_at,
+                'approvals': len(record.approvals),
+                'required': record.required,
+            })
+        if complete:
+            self.promote(staging_id, skip_lint=record.skip_lint, canary=record.canary,
+                         graceful_swap=record.graceful_swap)
+        return record
+
+    def reject_promotion(self, staging_id: str, approver_id: str, reason: str = '',
+                         namespace: Optional[str] = None) -> ApprovalRecord:
+        """Cancel a pending promotion: the record is REJECTED and so is the snippet."""
+        with self._lock:
+            record = self._approval_record(staging_id, namespace)
+            record.reject(approver_id, reason)
+            self._audit.log(AuditEventType.APPROVAL_REJECTED, staging_id, {
+                'approver_id': record.rejected_by,
+                'rejected_at': record.resolved_at,
+                'reason': reason,
+            })
+            if staging_id in self._staged:
+                self.verdict(staging_id, 'reject',
+                             reason or f"Promotion rejected by {record.rejected_by}")
+        return record
+
+    def get_approval(self, staging_id: str,
+                     namespace: Optional[str] = None) -> Optional[ApprovalRecord]:
+        record = self._approvals.get(staging_id)
+        if record is None or (namespace is not None and record.namespace != namespace):
+            return None
+        return record
+
+    def list_approvals(self, status: Optional[str] = None,
+                       namespace: Optional[str] = None) -> List[ApprovalRecord]:
+        """Approval records, oldest request first, optionally of one status."""
+        wanted = ApprovalStatus(status) if status else None
+        with self._lock:
+            records = list(self._approvals.values())
+        return sorted((r for r in records
+                       if (wanted is None or r.status == wanted)
+                       and (namespace is None or r.namespace == namespace)),
+                      key=lambda r: r.requested_at)
+
+    def batch_promote(self, staging_ids: List[str],
+                      skip_lint: bool = False,
+                      namespace: Optional[str] = None) -> List[PromotionResult]:
+        """
+        Promote several PASSED snippets atomically — all or nothing.
+
+        Every snippet is validated up front, then promoted in order.  If any
+        single promotion fails, the ones already promoted in this batch are
+        undone (registry slot cleared, reservation re-held, phase back to
+        PASSED) so production looks exactly as it did before the call.
+
+        Concurrent callers with overlapping ID sets are serialized on the
+        IDs they share (locks are taken in sorted order, so no deadlock).
+        The PROMOTION_COMPLETED webhooks and promotion metrics of the batch
+        are emitted only once all of it has committed.
+
+        Returns one PromotionResult per ID, in the caller's order.
+        Raises BatchPromotionError (carrying the results) if the batch
+        was rejected or rolled back.
+        """
+        ids = list(dict.fromkeys(staging_ids))
+        if not ids:
+            raise ValueError("batch_promote requires at least one staging_id")
+
+        batch_id = f"batch-{uuid.uuid4().hex[:12]}"
+        self._audit.log(AuditEventType.BATCH_PROMOTION_STARTED, batch_id, {
+            'staging_ids': ids,
+        })
+
+        with self._lock:
+            entries = [self._promotion_locks.setdefault(sid, [threading.Lock(), 0])
+                       for sid in sorted(ids)]
+            for entry in entries:
+                entry[1] += 1
+        for entry in entries:
+            entry[0].acquire()
+        try:
+            # ── Validate everything before touching production ───────────
+            problems = {}
+            with self._lock:
+                for sid in ids:
+                    snippet = self._in_namespace(self._staged.get(sid), namespace)
+                    if snippet is None:
+                        problems[sid] = f"No staged snippet '{sid}'"
+                    elif snippet.phase != StagingPhase.PASSED:
+                        problems[sid] = (f"Snippet in phase '{snippet.phase.value}' "
+                                         f"(must be PASSED)")
+                    elif self._breaker.state(self._breaker_key(snippet)) != BreakerState.CLOSED:
+                        problems[sid] = "Circuit breaker is open for this code"
+                    elif self._needs_approval(snippet):
+                        problems[sid] = (f"Slot '{snippet.engine_letter}' requires approval — "
+                                         f"promote it on its own")
+            if problems:
+                results = [PromotionResult(
+                    staging_id=sid, success=False,
+                    snippet=self.get_snippet(sid),
+                    error=problems.get(sid, 'Not promoted: batch rejected'),
+                ) for sid in ids]
+                self._audit.log(AuditEventType.BATCH_PROMOTION_ABORTED, batch_id, {
+                    'stage': 'validate',
+                    'problems': problems,
+                })
+                raise BatchPromotionError(
+                    f"Batch {batch_id} rejected: {len(problems)} snippet(s) not promotable",
+                    results)
+
+            # ── Promote in order, undo on the first failure ──────────────
+            # Webhooks and metrics wait for the commit: subscribers never
+            # hear of a promotion the batch then rolls back
+            promoted: List[StagedSnippet] = []
+            failed_id, failure = '', ''
+            self._held.promotions = []
+            for sid in ids:
+                try:
+                    promoted.append(self.promote(sid, skip_lint=skip_lint))
+                except Exception as exc:
+                    failed_id, failure = sid, str(exc)
+                    break
+
+            held, self._held.promotions = self._held.promotions, None
+            if not failed_id:
+                self._audit.log(AuditEventType.BATCH_PROMOTION_COMPLETED, batch_id, {
+                    'staging_ids': ids,
+                    'addresses': [s.reserved_address for s in promoted],
+                })
+                for snippet in held:
+                    self._announce_promotion(snippet)
+                return [PromotionResult(staging_id=s.staging_id, success=True, snippet=s)
+                        for s in promoted]
+
+            failed = self._staged.get(failed_id)
+            if failed is not None and failed.registry_slot_id:
+                # Partial promotion — the slot was committed before the failure
+                self._registry.clear_slot(failed.registry_slot_id)
+                failed.registry_slot_id = ''
+            for snippet in reversed(promoted):
+                self._undo_promotion(snippet, batch_id)
+
+            undone = {s.staging_id for s in promoted}
+            results = []
+            for sid in ids:
+                if sid == failed_id:
+                    results.append(PromotionResult(staging_id=sid, success=False,
+                                                   snippet=self.get_snippet(sid),
+                                                   error=failure))
+                else:
+                    results.append(PromotionResult(
+                        staging_id=sid, success=False,
+                        snippet=self.get_snippet(sid),
+                        error=f'Rolled back: {failed_id} failed' if sid in undone
+                              else 'Not promoted: batch aborted',
+                        rolled_back=sid in undone,
+                    ))
+            self._audit.log(AuditEventType.BATCH_PROMOTION_ABORTED, batch_id, {
+                'stage': 'promote',
+                'failed_staging_id': failed_id,
+                'error': failure,
+                'rolled_back': sorted(undone),
+            })
+            raise BatchPromotionError(
+                f"Batch {batch_id} rolled back: {failed_id} failed ({failure})", results)
+        finally:
+            self._held.promotions = None
+            for entry in reversed(entries):
+                entry[0].release()
+            with self._lock:
+                for sid, entry in zip(sorted(ids), entries):
+                    entry[1] -= 1
+                    if not entry[1]:
+                        del self._promotion_locks[sid]
+
+    def _undo_promotion(self, snippet: StagedSnippet, batch_id: str):
+        """
+        Revert a promotion made earlier in a failed batch.
+
+        The registry slot is cleared and the position re-reserved, and the
+        snippet returns to the active set in PASSED phase so it can be
+        promoted again.  Entries it superseded are re-installed in their
+        position (the snippet then reserves a fresh one).  The saved file
+        is kept for forensics.  Holds the slot's promotion lock throughout.
+        """
+        token = self._lock_slot(snippet)
+        try:
+            self._undo_promotion_locked(snippet, batch_id)
+        finally:
+            self._unlock_slot(token, snippet.staging_id)
+
+    def _undo_promotion_locked(self, snippet: StagedSnippet, batch_id: str):
+        with self._lock:
+            if snippet.registry_slot_id:
+                self._registry.clear_slot(snippet.registry_slot_id)
+
+            restored = [s for s in (self.get_snippet(sid) for sid in snippet.superseded_ids)
+                        if s is not None and s.phase == StagingPhase.SUPERSEDED]
+            for old in restored:
+                self._reinstall(old)
+            if restored:
+                snippet.reserved_position = self._reserve_position(snippet.reserved_engine)
+                snippet.reserved_address = f"{snippet.engine_letter}{snippet.reserved_position}"
+            else:
+                self._reserved_positions.setdefault(
+                    snippet.reserved_engine, set()).add(snippet.reserved_position)
+            snippet.superseded_ids = []
+            if snippet in self._history:
+                self._history.remove(snippet)
+            self._staged[snippet.staging_id] = snippet
+            old_slot = snippet.registry_slot_id
+            snippet.phase = StagingPhase.PASSED
+            snippet.registry_slot_id = ''
+            snippet.promoted_at = 0.0
+            snippet.updated_at = time.time()
+
+        self._promotions.record_rollback(snippet, restored[-1] if restored else None)
+        self._audit.log(AuditEventType.ROLLBACK, snippet.staging_id, {
+            'reason': 'batch_abort',
+            'batch_id': batch_id,
+            'slot_id': old_slot,
+            'address': snippet.reserved_address,
+            'restored_staging_ids': [s.staging_id for s in restored],
+        })
+
+    def _supersede(self, old: StagedSnippet, replacement: StagedSnippet):
+        """Take a live entry out of production because `replacement` overwrites it."""
+        if old.registry_slot_id:
+            self._registry.clear_slot(old.registry_slot_id)
+        with self._lock:
+            old.phase = StagingPhase.SUPERSEDED
+            old.updated_at = time.time()
+        self._audit.log(AuditEventType.SUPERSEDED, old.staging_id, {
+            'slot_id': old.registry_slot_id,
+            'address': old.reserved_address,
+            'label': old.label,
+            'superseded_by': replacement.staging_id,
+        })
+
+    def _make_file_header(self, snippet: StagedSnippet) -> str:
+        """Metadata header comment for the cold-storage copy of an archived snippet."""
+        lang = snippet.language
+        # Pick comment style
+        if lang in ('python', 'ruby', 'r', 'bash'):
+            prefix = '#'
+        elif lang in ('javascript', 'typescript', 'java', 'go',
+                       'csharp', 'kotlin', 'swift', 'rust', 'c', 'cpp'):
+            prefix = '//'
+        else:
+            prefix = '#'
 
         lines = [
             f"{prefix} ═══════════════════════════════════════════════════════",
@@ -743,9 +2977,31 @@ class StagingPipeline:
             f"{prefix}  label:       {snippet.label}",
             f"{prefix}  code_hash:   {snippet.code_hash[:16]}…",
             f"{prefix}  created:     {time.strftime('%Y-%m-%dT%H:%M:%SZ', time.gmtime(snippet.created_at))}",
-            f"{prefix}  promoted:    {time.strftime('%Y-%m-%dT%H:%M:%SZ', time.gmtime(time.time()))}",
+            f"{prefix}  promoted:    {time.strftime('%Y-%m-%dT%H:%M:%SZ', time.gmtime(snippet.promoted_at or time.time()))}",
             f"{prefix}  spec_time:   {snippet.spec_execution_time:.4f}s",
-            f"{prefix}  spec_result: {'PASS' if snippet.spec_success else 'FAIL'}",
+            f"{prefix}  spec_result: {snippet.spec_result.value}",
+        ]
+        if snippet.parameters:
+            lines.append(f"{prefix}  parameters:  " + ', '.join(
+                f"{p['name']} {p['type']}" for p in snippet.parameters))
+        if snippet.parameters_schema_hash:
+            lines.append(f"{prefix}  param_schema: {snippet.parameters_schema_hash[:16]}…")
+        if snippet.output_schema_hash:
+            lines.append(f"{prefix}  output_schema: {snippet.output_schema_hash[:16]}…")
+        if snippet.coverage_percent is not None:
+            lines.append(f"{prefix}  coverage:    {snippet.coverage_percent:.1f}%")
+        for bench in snippet.benchmarks:
+            lines.append(f"{prefix}  benchmark:   {bench['name']} {bench['ns_per_op']:g} ns/op")
+        if snippet.mutants_tested:
+            lines.append(f"{prefix}  mutations:   {len(snippet.survived_mutations)} of "
+                         f"{snippet.mutants_tested} survived")
+        if snippet.spec_cases:
+            passed = sum(1 for c in snippet.spec_cases if c['passed'])
+            lines.append(f"{prefix}  test_cases:  {passed} of {len(snippet.spec_cases)} passed")
+        if snippet.spec_output_value is not None:
+            result = json.dumps(snippet.spec_output_value, sort_keys=True)
+            lines.append(f"{prefix}  spec_output: {result[:200]}{'…' if len(result) > 200 else ''}")
+        lines += [
             f"{prefix} ═══════════════════════════════════════════════════════",
             f"",
         ]
@@ -759,12 +3015,15 @@ class StagingPipeline:
         """
         from .session_ledger import resolve_language_id
         node_id = f"snippet-{snippet.staging_id}"
+        # The payload's address in the store, not a copy of its own: the
+        # node's source is the working copy the registry slot executes
+        digest = f"sha256:{snippet.code_hash}"
 
         # Begin a synthetic import session for this snippet
         import_session = self._ledger.begin_import(
-            source_file=snippet.saved_file_path or f"snippet:{snippet.staging_id}",
+            source_file=digest,
             source_language=snippet.language,
-            file_content=snippet.code,
+            file_content=snippet.program,
             dependency_strategy='preserve',
         )
 
@@ -774,13 +3033,14 @@ class StagingPipeline:
             node_type='snippet',
             display_name=snippet.label,
             raw_name=snippet.staging_id,
-            source_code=snippet.code,
+            source_code=snippet.program,
             source_language=snippet.language,
-            source_file=snippet.saved_file_path or f"snippet:{snippet.staging_id}",
+            source_file=digest,
             import_session_number=import_session,
             metadata={
                 'staging_id': snippet.staging_id,
                 'code_hash': snippet.code_hash,
+                'payload': digest,
                 'engine_letter': snippet.engine_letter,
                 'reserved_address': snippet.reserved_address,
                 'spec_success': snippet.spec_success,
@@ -805,14 +3065,24 @@ class StagingPipeline:
     # ROLLBACK — Unpromote a snippet from production
     # ─────────────────────────────────────────────────────────────────────
 
-    def rollback(self, staging_id: str, reason: str = '') -> StagedSnippet:
+    @traced('Rollback', staging_id_arg=0)
+    def rollback(self, staging_id: str, reason: str = '',
+                 namespace: Optional[str] = None) -> StagedSnippet:
         """
         Rollback a promoted snippet from production.
 
         1. Clears the registry slot
-        2. Marks the snippet as ROLLED_BACK
-        3. Does NOT delete the saved file (forensics)
-        4. Logs everything
+        2. Re-installs the version of the same label that was promoted
+           immediately before it (from the promotion history), if any —
+           into the same position, so the slot keeps serving that label
+        3. Marks the snippet as ROLLED_BACK and records the rollback in
+           the promotion history (so successive rollbacks walk back further)
+        4. Does NOT delete the saved file (forensics)
+        5. Logs everything
+
+        Steps 1-3 hold the slot's promotion lock, so a rollback never
+        interleaves with a promotion onto the same slot
+        (LockUnavailableError if it stays busy).
         """
         with self._lock:
             # Check active staged first, then history
@@ -822,28 +3092,47 @@ class StagingPipeline:
                     if h.staging_id == staging_id:
                         snippet = h
                         break
+            snippet = self._in_namespace(snippet, namespace)
             if snippet is None:
                 raise ValueError(f"No snippet with staging_id '{staging_id}'")
-            if snippet.phase != StagingPhase.PROMOTED:
-                raise ValueError(
-                    f"Cannot rollback snippet in phase '{snippet.phase.value}' "
-                    f"(must be PROMOTED)"
-                )
+            self._check_rollback_phase(snippet)
 
-        # Clear the registry slot
-        if snippet.registry_slot_id:
-            self._registry.clear_slot(snippet.registry_slot_id)
-            self._audit.log(AuditEventType.SLOT_RELEASED, staging_id, {
-                'slot_id': snippet.registry_slot_id,
-                'address': snippet.reserved_address,
-            })
+        for rollout in self._canaries.running():
+            if rollout.baseline_id == staging_id:
+                self._canaries.abort(rollout.canary_id, f'Baseline {staging_id} rolled back')
+        test = self._ab_tests.involving(staging_id)
+        if test is not None and test.control.staging_id == staging_id:
+            self.abort_ab_test(test.test_id, f'Control {staging_id} rolled back')
 
-        with self._lock:
-            snippet.phase = StagingPhase.ROLLED_BACK
-            snippet.rejection_reason = reason or 'Rolled back from production'
-            snippet.rejection_at = time.time()
-            snippet.updated_at = time.time()
+        token = self._lock_slot(snippet)
+        try:
+            with self._lock:
+                # A promotion or rollback may have changed the chain meanwhile
+                self._check_rollback_phase(snippet)
+                prior_id = self._promotions.prior_promotion(snippet, self._is_reinstatable)
+                prior = self.get_snippet(prior_id) if prior_id else None
+
+            # Clear the registry slot
+            if snippet.registry_slot_id:
+                self._registry.clear_slot(snippet.registry_slot_id)
+                self._audit.log(AuditEventType.SLOT_RELEASED, staging_id, {
+                    'slot_id': snippet.registry_slot_id,
+                    'address': snippet.reserved_address,
+                })
+
+            if prior is not None:
+                self._reinstall(prior, replacing=snippet)
 
+            with self._lock:
+                snippet.phase = StagingPhase.ROLLED_BACK
+                snippet.rejection_reason = reason or 'Rolled back from production'
+                snippet.rejection_at = time.time()
+                snippet.rolled_back_to = prior.staging_id if prior else ''
+                snippet.updated_at = time.time()
+
+            self._promotions.record_rollback(snippet, prior)
+        finally:
+            self._unlock_slot(token, staging_id)
         self._audit.log(AuditEventType.ROLLBACK, staging_id, {
             'reason': reason,
             'slot_id': snippet.registry_slot_id,
@@ -851,17 +3140,564 @@ class StagingPipeline:
             'node_id': snippet.ledger_node_id,
             'was_promoted_at': snippet.promoted_at,
             'time_in_production': time.time() - snippet.promoted_at,
+            'restored_staging_id': snippet.rolled_back_to,
+        })
+        self._notify(AuditEventType.ROLLBACK, snippet)
+        self._metrics.record_rollback(snippet.language, snippet.engine_letter)
+
+        return snippet
+
+    @staticmethod
+    def _check_rollback_phase(snippet: StagedSnippet):
+        if snippet.phase != StagingPhase.PROMOTED:
+            raise PhaseError(
+                f"Cannot rollback snippet in phase '{snippet.phase.value}' "
+                f"(must be PROMOTED)"
+            )
+
+    def _is_reinstatable(self, staging_id: str) -> bool:
+        """A prior version can be re-installed unless it was itself rolled back."""
+        prior = self.get_snippet(staging_id)
+        return prior is not None and prior.phase in (StagingPhase.PROMOTED,
+                                                     StagingPhase.SUPERSEDED)
+
+    def _reinstall(self, prior: StagedSnippet,
+                   replacing: Optional[StagedSnippet] = None):
+        """
+        Make `prior` the live production entry again.
+
+        If it is still committed to its own registry slot there is nothing
+        to do; otherwise its ledger node is committed into the position
+        vacated by `replacing` (or back into its own position).  Callers
+        hold the slot's promotion lock (_promote_locked(), rollback(),
+        _undo_promotion()).
+        """
+        current = self._registry.get_slot(prior.registry_slot_id) if prior.registry_slot_id else None
+        if current is not None and current.node_id == prior.ledger_node_id:
+            if prior.phase == StagingPhase.SUPERSEDED:
+                prior.phase = StagingPhase.PROMOTED
+            return
+
+        target = replacing or prior
+        from .node_registry import SlotPermissionSet
+        slot = self._registry.commit_node(
+            node_id=prior.ledger_node_id,
+            engine_name=target.reserved_engine,
+            position=target.reserved_position,
+            permissions=SlotPermissionSet(get=True, push=True, post=False, delete=False),
+        )
+        if slot is None:
+            self._audit.log(AuditEventType.ERROR, prior.staging_id, {
+                'step': 'rollback_reinstall',
+                'error': 'commit_node returned None',
+            })
+            return
+
+        self._registry.record_execution(
+            slot_id=slot.slot_id,
+            success=prior.spec_success,
+            output=prior.spec_output,
+            error=prior.spec_error,
+            execution_time=prior.spec_execution_time,
+        )
+        with self._lock:
+            prior.registry_slot_id = slot.slot_id
+            prior.reserved_position = target.reserved_position
+            prior.reserved_address = target.reserved_address
+            prior.phase = StagingPhase.PROMOTED
+            prior.updated_at = time.time()
+
+        self._audit.log(AuditEventType.REGISTRY_SLOT_COMMITTED, prior.staging_id, {
+            'slot_id': slot.slot_id,
+            'address': slot.address,
+            'engine': prior.reserved_engine,
+            'position': prior.reserved_position,
+            'reinstalled_by_rollback_of': replacing.staging_id if replacing else '',
+        })
+
+    # ─────────────────────────────────────────────────────────────────────
+    # WEBHOOKS — notify external systems of promotions
+    # ─────────────────────────────────────────────────────────────────────
+
+    def add_webhook_target(self, url: str, secret: str,
+                           events: Optional[List] = None) -> WebhookTarget:
+        """
+        Register a URL to receive signed payloads for `events`
+        (AuditEventTypes, default PROMOTION_COMPLETED).
+
+        Starts a dispatcher logging to `webhook_deliveries.jsonl` beside
+        the audit log if the pipeline was not given one.
+        """
+        with self._lock:
+            if self._webhooks is None:
+                self._webhooks = WebhookDispatcher(os.path.join(
+                    os.path.dirname(self._audit_log_path) or '.',
+                    'webhook_deliveries.jsonl'))
+                self._webhooks.start()
+        return self._webhooks.add_target(url, secret, events)
+
+    @property
+    def metrics(self) -> PipelineMetrics:
+        return self._metrics
+
+    @property
+    def webhooks(self) -> Optional[WebhookDispatcher]:
+        return self._webhooks
+
+    def _announce_promotion(self, snippet: StagedSnippet):
+        """PROMOTION_COMPLETED webhook and metric — held while a batch is in progress."""
+        held = getattr(self._held, 'promotions', None)
+        if held is not None:
+            held.append(snippet)
+            return
+        self._notify(AuditEventType.PROMOTION_COMPLETED, snippet)
+        self._metrics.record_promotion(snippet.language, snippet.engine_letter,
+                                       snippet.spec_result.value)
+
+    def _notify(self, event: AuditEventType, snippet: StagedSnippet,
+                extra: Optional[Dict[str, Any]] = None):
+        if self._webhooks is None:
+            return
+        try:
+            self._webhooks.publish(event, {
+                'staging_id': snippet.staging_id,
+                'label': snippet.label,
+                'promoted_at': snippet.promoted_at,
+                'spec_result': snippet.spec_result.value,
+                'slot': snippet.reserved_address,
+                **(extra or {}),
+            })
+        except Exception as exc:
+            # A broken webhook log must never fail a promotion
+            self._audit.log(AuditEventType.ERROR, snippet.staging_id, {
+                'step': 'webhook_publish',
+                'error': str(exc),
+            })
+
+    # ─────────────────────────────────────────────────────────────────────
+    # CANARY — shift a slot's executions to a new version gradually
+    # ─────────────────────────────────────────────────────────────────────
+
+    @property
+    def canaries(self) -> CanaryController:
+        return self._canaries
+
+    def _start_canary(self, staging_id: str, config: CanaryConfig) -> StagedSnippet:
+        with self._lock:
+            snippet = self._staged.get(staging_id)
+            if snippet is None:
+                raise ValueError(f"No staged snippet '{staging_id}'")
+            if snippet.phase != StagingPhase.PASSED:
+                raise PhaseError(
+                    f"Cannot promote snippet in phase '{snippet.phase.value}' "
+                    f"(must be PASSED)"
+                )
+            live = self._live_entries(snippet.engine_letter, snippet.label,
+                                      exclude=staging_id, namespace=snippet.namespace)
+            if not live or not live[-1].registry_slot_id:
+                raise ValueError(
+                    f"Canary promotion needs a live version of label '{snippet.label}' "
+                    f"on slot '{snippet.engine_letter}' to shift executions from"
+                )
+            if snippet.label_policy != LabelConflictPolicy.OVERWRITE:
+                raise ValueError(
+                    f"Canary promotion requires the 'overwrite' label policy "
+                    f"(snippet has '{snippet.label_policy.value}')"
+                )
+            baseline = live[-1]
+            test = self._ab_tests.for_slot(baseline.registry_slot_id)
+            if test is not None:
+                raise ValueError(
+                    f"Slot {baseline.registry_slot_id} has an A/B test running ({test.test_id})")
+            rollout = self._canaries.start(staging_id, baseline.staging_id,
+                                           baseline.registry_slot_id, config)
+            snippet.phase = StagingPhase.CANARY
+            snippet.canary_weight = rollout.weight
+            snippet.canary_baseline_id = baseline.staging_id
+            snippet.updated_at = time.time()
+
+        self._audit.log(AuditEventType.CANARY_STARTED, staging_id, {
+            'baseline_id': baseline.staging_id,
+            'slot_id': baseline.registry_slot_id,
+            'address': baseline.reserved_address,
+            'config': config.to_dict(),
+        })
+        return snippet
+
+    def canary_stepped(self, rollout: CanaryRollout):
+        """Controller callback: the canary's weight grew."""
+        snippet = self._staged.get(rollout.canary_id)
+        if snippet is not None:
+            snippet.canary_weight = rollout.weight
+        self._audit.log(AuditEventType.CANARY_STEPPED, rollout.canary_id, {
+            'weight': rollout.weight,
+            'error_rate': rollout.error_rate,
+            'canary_runs': rollout.canary_runs,
+        })
+
+    def finish_canary(self, rollout: CanaryRollout):
+        """Controller callback: promote the canary for real, or reject it."""
+        with self._lock:
+            snippet = self._staged.get(rollout.canary_id)
+            if snippet is None or snippet.phase != StagingPhase.CANARY:
+                return
+            snippet.canary_weight = rollout.weight
+            if rollout.status == CanaryStatus.COMPLETED:
+                snippet.phase = StagingPhase.PASSED
+        data = {
+            'baseline_id': rollout.baseline_id,
+            'canary_runs': rollout.canary_runs,
+            'canary_errors': rollout.canary_errors,
+            'error_rate': rollout.error_rate,
+            'baseline_error_rate': rollout.baseline_error_rate,
+        }
+        if rollout.status == CanaryStatus.COMPLETED:
+            self._audit.log(AuditEventType.CANARY_COMPLETED, rollout.canary_id, data)
+            with span('promote', staging_id=rollout.canary_id, canary=True):
+                self._promote(rollout.canary_id)
+        else:
+            self._audit.log(AuditEventType.CANARY_ROLLED_BACK, rollout.canary_id, {
+                **data, 'status': rollout.status.value, 'reason': rollout.reason,
+            })
+            self.verdict(rollout.canary_id, 'reject', rollout.reason)
+
+    def get_canary(self, staging_id: str,
+                   namespace: Optional[str] = None) -> Optional[CanaryRollout]:
+        if self.get_snippet(staging_id, namespace=namespace) is None:
+            return None
+        return self._canaries.get(staging_id)
+
+    def abort_canary(self, staging_id: str, reason: str = '',
+                     namespace: Optional[str] = None) -> CanaryRollout:
+        """Stop a running canary; the live version keeps its slot."""
+        if self.get_snippet(staging_id, namespace=namespace) is None:
+            raise ValueError(f"No running canary '{staging_id}'")
+        return self._canaries.abort(staging_id, reason)
+
+    def route_slot(self, slot_id: str) -> Optional[StagedSnippet]:
+        """
+        The canary (or A/B treatment) to run for this execution of
+        `slot_id`, or None for the committed code.  Weighted random: the
+        canary is picked with probability equal to its current weight, the
+        treatment with its test's split_ratio.
+        """
+        staging_id = self._canaries.choose(slot_id) or self._ab_tests.choose(slot_id)
+        snippet = self._staged.get(staging_id) if staging_id else None
+        if snippet is None or snippet.phase not in (StagingPhase.CANARY, StagingPhase.PASSED):
+            return None                       # e.g. a treatment promoted on its own
+        return snippet
+
+    def record_slot_run(self, slot_id: str, canary: Optional[StagedSnippet],
+                        success: bool, execution_time: float = 0.0):
+        """Report an execution routed by route_slot() (feeds error rates and A/B stats)."""
+        self._canaries.record(slot_id, canary is not None, success)
+        self._ab_tests.record(slot_id, canary is not None, success, execution_time)
+
+    # ─────────────────────────────────────────────────────────────────────
+    # GRACEFUL SWAP — supersede a live version once its executions drain
+    # ─────────────────────────────────────────────────────────────────────
+
+    @property
+    def swaps(self) -> SwapController:
+        return self._swaps
+
+    def _start_swap(self, staging_id: str, config: GracefulSwap) -> StagedSnippet:
+        with self._lock:
+            snippet = self._staged.get(staging_id)
+            if snippet is None:
+                raise ValueError(f"No staged snippet '{staging_id}'")
+            if snippet.phase != StagingPhase.PASSED:
+                raise PhaseError(
+                    f"Cannot promote snippet in phase '{snippet.phase.value}' "
+                    f"(must be PASSED)"
+                )
+            live = self._live_entries(snippet.engine_letter, snippet.label,
+                                      exclude=staging_id, namespace=snippet.namespace)
+            replaced = live[-1] if live else None
+            if (replaced is None or not replaced.registry_slot_id
+                    or snippet.label_policy != LabelConflictPolicy.OVERWRITE):
+                replaced = None              # Nothing is superseded: nothing to drain
+            else:
+                swap = self._swaps.start(staging_id, replaced.staging_id,
+                                         replaced.registry_slot_id, config)
+                if swap.status == SwapStatus.PENDING:
+                    snippet.phase = StagingPhase.PENDING_SWAP
+                    snippet.updated_at = time.time()
+        if replaced is None:
+            return self._promote(staging_id)
+        if swap.status != SwapStatus.PENDING:
+            promoted = self._promote(staging_id)
+            swap.installed_slot_id = promoted.registry_slot_id
+            return promoted
+
+        self._audit.log(AuditEventType.SWAP_PENDING, staging_id, {
+            'replaces': swap.replaces,
+            'slot_id': swap.slot_id,
+            'in_flight': swap.in_flight_at_request,
+            'drain_timeout': swap.drain_timeout,
         })
+        return snippet
 
+    def finish_swap(self, swap: SlotSwap):
+        """Controller callback: the slot drained (or timed out) — install the new version."""
+        with self._lock:
+            snippet = self._staged.get(swap.staging_id)
+            if snippet is None or snippet.phase != StagingPhase.PENDING_SWAP:
+                return
+            snippet.phase = StagingPhase.PASSED
+        self._audit.log(AuditEventType.SWAP_COMPLETED, swap.staging_id, {
+            'replaces': swap.replaces,
+            'slot_id': swap.slot_id,
+            'status': swap.status.value,
+            'waited': swap.completed_at - swap.requested_at,
+            'cancelled': swap.cancelled,
+            'reason': swap.reason,
+        })
+        with span('promote', staging_id=swap.staging_id, graceful_swap=True):
+            swap.installed_slot_id = self._promote(swap.staging_id).registry_slot_id
+
+    def get_swap(self, staging_id: str,
+                 namespace: Optional[str] = None) -> Optional[SlotSwap]:
+        if self.get_snippet(staging_id, namespace=namespace) is None:
+            return None
+        return self._swaps.get(staging_id)
+
+    def list_swaps(self, namespace: Optional[str] = None) -> List[SlotSwap]:
+        return [s for s in self._swaps.all()
+                if self.get_snippet(s.staging_id, namespace=namespace) is not None]
+
+    def begin_slot_run(self, slot_id: str) -> ExecutionLease:
+        """
+        Count an execution of `slot_id` in — call before reading the code
+        it runs.  Blocks while a graceful swap of the slot is pending; the
+        lease then names the new version's slot (lease.swapped).
+        """
+        return self._swaps.acquire(slot_id)
+
+    def end_slot_run(self, lease: ExecutionLease):
+        """Count the execution out; the last one out completes a pending swap."""
+        self._swaps.release(lease)
+
+    # ─────────────────────────────────────────────────────────────────────
+    # A/B TESTS — two labels sharing one slot's executions (see snippet_abtest)
+    # ─────────────────────────────────────────────────────────────────────
+
+    @property
+    def ab_tests(self) -> ABTestController:
+        return self._ab_tests
+
+    def start_ab_test(self, engine_letter: str, control_label: str, treatment_label: str,
+                      split_ratio: float = 0.5, min_samples: int = DEFAULT_MIN_SAMPLES,
+                      namespace: str = DEFAULT_NAMESPACE) -> ABTest:
+        """
+        Split the executions of `control_label`'s live version between it
+        and the newest PASSED snippet labelled `treatment_label` on the
+        same slot: the treatment runs with probability `split_ratio`.
+        """
+        if control_label == treatment_label:
+            raise ABTestError("control_label and treatment_label must differ")
+        live = self._live_entries(engine_letter, control_label, namespace=namespace)
+        if not live or not live[-1].registry_slot_id:
+            raise ABTestError(f"Label '{control_label}' is not live on slot '{engine_letter}'")
+        control = live[-1]
+        with self._lock:
+            candidates = [s for s in self._staged.values()
+                          if s.phase == StagingPhase.PASSED
+                          and s.engine_letter == engine_letter
+                          and s.label == treatment_label
+                          and s.namespace == namespace]
+        if not candidates:
+            raise ABTestError(
+                f"No PASSED snippet labelled '{treatment_label}' on slot '{engine_letter}'")
+        treatment = max(candidates, key=lambda s: s.spec_completed_at)
+        if self._canaries.for_slot(control.registry_slot_id) is not None:
+            raise ABTestError(f"Slot {control.registry_slot_id} has a canary running")
+        if self._ab_tests.involving(treatment.staging_id) is not None:
+            raise ABTestError(f"{treatment.staging_id} is already in an A/B test")
+
+        test = self._ab_tests.start(
+            engine_letter, namespace, control.registry_slot_id, split_ratio, min_samples,
+            ABVariantStats(control.staging_id, control_label),
+            ABVariantStats(treatment.staging_id, treatment_label))
+        self._audit.log(AuditEventType.AB_TEST_STARTED, treatment.staging_id, {
+            'test_id': test.test_id,
+            'control_id': control.staging_id,
+            'slot_id': control.registry_slot_id,
+            'address': control.reserved_address,
+            'split_ratio': test.split_ratio,
+            'min_samples': test.min_samples,
+        })
+        return test
+
+    def get_ab_test(self, test_id: str, namespace: Optional[str] = None) -> Optional[ABTest]:
+        test = self._ab_tests.get(test_id)
+        if test is None or (namespace is not None and test.namespace != namespace):
+            return None
+        return test
+
+    def _require_ab_test(self, test_id: str, namespace: Optional[str]) -> ABTest:
+        test = self.get_ab_test(test_id, namespace)
+        if test is None:
+            raise ABTestError(f"No A/B test '{test_id}'")
+        return test
+
+    def list_ab_tests(self, namespace: Optional[str] = None) -> List[ABTest]:
+        return [t for t in self._ab_tests.all()
+                if namespace is None or t.namespace == namespace]
+
+    def ab_test_result(self, test_id: str, namespace: Optional[str] = None) -> ABTestReport:
+        """Per-variant runs, pass rate and mean / p95 execution time, plus the winner."""
+        self._require_ab_test(test_id, namespace)
+        return self._ab_tests.report(test_id)
+
+    def end_ab_test(self, test_id: str, winner: Optional[str] = None,
+                    namespace: Optional[str] = None) -> ABTest:
+        """
+        Apply the result of a running test: the recommended winner, or
+        `winner` ('control' / 'treatment') to decide before min_samples.
+
+        A winning treatment goes through promote() — so on a protected
+        slot this raises PendingApprovalError and the test keeps running
+        until it is ended again once approved — and the control is then
+        archived.  A winning control stays live; the treatment is rejected.
+        """
+        test = self._require_ab_test(test_id, namespace)
+        if test.status != ABTestStatus.RUNNING:
+            raise ABTestError(f"A/B test {test_id} is already {test.status.value}")
+        report = self._ab_tests.report(test_id)
+        if winner is None:
+            winner = report.recommended_winner
+            if not winner:
+                raise ABTestError(
+                    f"A/B test {test_id} needs {test.min_samples} runs per variant "
+                    f"(control {report.control.runs}, treatment {report.treatment.runs}); "
+                    f"pass winner to decide now")
+        elif winner not in VARIANTS:
+            raise ABTestError(f"winner must be one of: {', '.join(VARIANTS)}")
+
+        control = self.get_snippet(test.control.staging_id)
+        treatment = self.get_snippet(test.treatment.staging_id)
+        reason = f"Lost A/B test {test_id}"
+        if winner == VARIANT_TREATMENT:
+            if treatment is None or treatment.phase not in (StagingPhase.PASSED,
+                                                            StagingPhase.PROMOTED):
+                raise ABTestError(f"Treatment {test.treatment.staging_id} can no longer "
+                                  f"be promoted")
+            if treatment.phase == StagingPhase.PASSED:
+                self.promote(treatment.staging_id)
+            self._ab_tests.finish(test_id, ABTestStatus.ENDED, winner)
+            if control is not None:
+                self._archive_record(control, reason, None, time.time())
+        else:
+            self._ab_tests.finish(test_id, ABTestStatus.ENDED, winner)
+            if treatment is not None and treatment.phase == StagingPhase.PASSED:
+                self.verdict(treatment.staging_id, 'reject', reason)
+
+        self._audit.log(AuditEventType.AB_TEST_ENDED, test.treatment.staging_id, {
+            'test_id': test_id,
+            'control_id': test.control.staging_id,
+            'winner': winner,
+            'recommended_winner': report.recommended_winner,
+            'control': report.control.to_dict(),
+            'treatment': report.treatment.to_dict(),
+        })
+        return test
+
+    def abort_ab_test(self, test_id: str, reason: str = '',
+                      namespace: Optional[str] = None) -> ABTest:
+        """Stop a running test without a winner; both variants stay as they are."""
+        self._require_ab_test(test_id, namespace)
+        test = self._ab_tests.finish(test_id, ABTestStatus.ABORTED,
+                                     reason=reason or 'Aborted by operator')
+        if test is None:
+            raise ABTestError(f"A/B test {test_id} is not running")
+        self._audit.log(AuditEventType.AB_TEST_ABORTED, test.treatment.staging_id, {
+            'test_id': test_id,
+            'control_id': test.control.staging_id,
+            'reason': test.reason,
+        })
+        return test
+
+    # ─────────────────────────────────────────────────────────────────────
+    # CIRCUIT BREAKER — stop retrying code that keeps failing
+    # ─────────────────────────────────────────────────────────────────────
+
+    @property
+    def circuit_breaker(self) -> CircuitBreaker:
+        return self._breaker
+
+    @staticmethod
+    def _breaker_key(snippet: StagedSnippet) -> str:
+        # The same source under a different env is a different execution
+        key = f"{snippet.engine_letter}:{snippet.code_hash}"
+        return f"{key}:{snippet.env_hash[:16]}" if snippet.env_hash else key
+
+    def _require_snippet(self, staging_id: str,
+                         namespace: Optional[str] = None) -> StagedSnippet:
+        snippet = self.get_snippet(staging_id, namespace=namespace)
+        if snippet is None:
+            raise ValueError(f"No staged snippet with id '{staging_id}'")
         return snippet
 
+    def circuit_state(self, staging_id: str,
+                      namespace: Optional[str] = None) -> BreakerState:
+        """Breaker state for the snippet's code on its slot."""
+        return self._breaker.state(self._breaker_key(
+            self._require_snippet(staging_id, namespace)))
+
+    def describe_circuit(self, staging_id: str,
+                         namespace: Optional[str] = None) -> Dict[str, Any]:
+        snippet = self._require_snippet(staging_id, namespace)
+        key = self._breaker_key(snippet)
+        return {'staging_id': staging_id, 'key': key, **self._breaker.describe(key)}
+
+    def reset_circuit(self, staging_id: str, namespace: Optional[str] = None) -> bool:
+        """Close the snippet's circuit by hand (audited)."""
+        snippet = self._require_snippet(staging_id, namespace)
+        cleared = self._breaker.reset(self._breaker_key(snippet))
+        if cleared:
+            self._audit.log(AuditEventType.CIRCUIT_CLOSED, staging_id, {
+                'key': self._breaker_key(snippet),
+                'reason': 'manual reset',
+            })
+        return cleared
+
+    def _record_run(self, snippet: StagedSnippet, passed: bool):
+        key = self._breaker_key(snippet)
+        if passed:
+            changed = self._breaker.record_success(key)
+        else:
+            changed = self._breaker.record_failure(key)
+        if changed == BreakerState.OPEN:
+            self._audit.log(AuditEventType.CIRCUIT_OPENED, snippet.staging_id, {
+                'key': key,
+                **self._breaker.describe(key),
+            })
+        elif changed == BreakerState.CLOSED:
+            self._audit.log(AuditEventType.CIRCUIT_CLOSED, snippet.staging_id, {
+                'key': key,
+                'reason': 'trial run passed',
+            })
+
     # ─────────────────────────────────────────────────────────────────────
     # FULL PIPELINE — queue → speculate → verdict → promote (one call)
     # ─────────────────────────────────────────────────────────────────────
 
     def run_full_pipeline(self, engine_letter: str, language: str,
                           code: str, label: str = '',
-                          auto_promote: bool = True) -> StagedSnippet:
+                          auto_promote: bool = True,
+                          label_policy: Optional[LabelConflictPolicy] = None,
+                          skip_lint: bool = False,
+                          parameters: Optional[List] = None,
+                          arguments: Optional[Dict[str, Any]] = None,
+                          namespace: str = DEFAULT_NAMESPACE,
+                          env: Optional[Dict[str, str]] = None,
+                          requires: Optional[List[str]] = None,
+                          output_schema=None,
+                          tags: Optional[List[str]] = None,
+                          deterministic: bool = False,
+                          test_cases: Optional[List] = None,
+                          suppress_credential_check: bool = False) -> StagedSnippet:
         """
         Run the complete staging pipeline in one call:
 
@@ -870,20 +3706,31 @@ class StagingPipeline:
         If auto_promote is False, stops after verdict (returns PASSED
         or FAILED — caller must call promote() separately).
 
-        Returns the final StagedSnippet.
+        Returns the final StagedSnippet.  If the code's circuit breaker is
+        open the new snippet is rejected (freeing its position) and
+        CircuitOpenError is raised.
         """
         # Phase 1: Queue
-        snippet = self.queue_snippet(engine_letter, language, code, label)
+        snippet = self.queue_snippet(engine_letter, language, code, label,
+                                     label_policy=label_policy, parameters=parameters,
+                                     namespace=namespace, env=env, requires=requires,
+                                     output_schema=output_schema, tags=tags,
+                                     deterministic=deterministic, test_cases=test_cases,
+                                     suppress_credential_check=suppress_credential_check)
 
         # Phase 2: Speculate
-        snippet = self.speculate(snippet.staging_id)
+        try:
+            snippet = self.speculate(snippet.staging_id, arguments=arguments)
+        except CircuitOpenError as exc:
+            self.verdict(snippet.staging_id, 'reject', str(exc))
+            raise
 
         # Phase 3: Verdict
         snippet = self.verdict(snippet.staging_id, action='auto')
 
         # Phase 4: Promote (if passed and auto_promote)
         if snippet.phase == StagingPhase.PASSED and auto_promote:
-            snippet = self.promote(snippet.staging_id)
+            snippet = self.promote(snippet.staging_id, skip_lint=skip_lint)
 
         return snippet
 
@@ -891,30 +3738,719 @@ class StagingPipeline:
     # QUERIES
     # ─────────────────────────────────────────────────────────────────────
 
-    def get_snippet(self, staging_id: str) -> Optional[StagedSnippet]:
+    def get_snippet(self, staging_id: str,
+                    namespace: Optional[str] = None) -> Optional[StagedSnippet]:
         """Get a snippet by staging_id (checks active + history)."""
         snippet = self._staged.get(staging_id)
         if snippet:
-            return snippet
+            return self._in_namespace(snippet, namespace)
         for h in self._history:
             if h.staging_id == staging_id:
-                return h
+                return self._in_namespace(h, namespace)
+        return None
+
+    def _log_identity(self, staging_id: str) -> Dict[str, str]:
+        """The slot and label log records of `staging_id` carry."""
+        snippet = self._staged.get(staging_id)
+        if snippet is None:
+            snippet = next((h for h in reversed(self._history) if h.staging_id == staging_id),
+                           None)
+        if snippet is None:
+            return {}
+        return {'slot': snippet.engine_letter, 'label': snippet.label}
+
+    @staticmethod
+    def _in_namespace(snippet: Optional[StagedSnippet],
+                      namespace: Optional[str]) -> Optional[StagedSnippet]:
+        """`snippet` if `namespace` may see it (None = unscoped), else None."""
+        if snippet is None or namespace is None or snippet.namespace == namespace:
+            return snippet
         return None
 
-    def get_active(self) -> List[StagedSnippet]:
+    def get_source(self, code_hash: str, namespace: Optional[str] = None) -> str:
+        """
+        Fetch a promoted source payload from the content-addressable store.
+
+        Payloads are shared between namespaces, so a scoped caller only
+        gets one that a snippet of its own namespace carries; any other
+        hash raises PayloadNotFoundError as if it were never stored.
+        """
+        if namespace is not None:
+            owners = self._index.query(SnippetFilter(code_hash_prefix=code_hash,
+                                                     namespace=namespace, limit=1))
+            if not any(s.code_hash == code_hash for s in owners.snippets):
+                raise PayloadNotFoundError(f"No payload stored for {code_hash}")
+        return self._load_payload(code_hash).decode('utf-8')
+
+    def get_promotion_history(self, slot: str, label: Optional[str] = None,
+                              namespace: Optional[str] = None) -> List[Dict]:
+        """Chronological promote / rollback records for a slot (engine letter)."""
+        return [r.to_dict() for r in self._promotions.records(slot, label, namespace)]
+
+    def get_version_history(self, label: str, slot: str,
+                            namespace: Optional[str] = DEFAULT_NAMESPACE) -> List[VersionRecord]:
+        """
+        Every retained promotion of `label` on `slot` (engine letter) in
+        `namespace`, oldest first, with its source bytes loaded from the
+        payload store.
+        """
+        records = self._promotions.records(slot, label, namespace)
+        rolled_back = {r.staging_id for r in records if r.event == 'rollback'}
+        versions = []
+        for record in records:
+            if record.event != 'promote':
+                continue
+            try:
+                source = self._load_payload(record.code_hash, slot)
+            except PayloadNotFoundError:
+                source = None
+            versions.append(VersionRecord(
+                version=len(versions) + 1,
+                staging_id=record.staging_id,
+                slot=record.slot,
+                label=record.label,
+                address=record.address,
+                code_hash=record.code_hash,
+                promoted_at=record.timestamp,
+                rolled_back=record.staging_id in rolled_back,
+                source=source,
+            ))
+        return versions
+
+    def diff_versions(self, label: str, slot: str,
+                      old_version: Optional[int] = None,
+                      new_version: Optional[int] = None,
+                      namespace: Optional[str] = DEFAULT_NAMESPACE) -> DiffResult:
+        """
+        Diff two versions of a label (defaults: the last two promotions).
+
+        Raises ValueError if the label has fewer than two versions or a
+        version number is out of range.
+        """
+        versions = self.get_version_history(label, slot, namespace)
+        if len(versions) < 2 and (old_version is None or new_version is None):
+            raise ValueError(
+                f"'{label}' on slot '{slot}' has {len(versions)} version(s); need two to diff")
+        new_version = new_version if new_version is not None else versions[-1].version
+        old_version = old_version if old_version is not None else new_version - 1
+
+        def pick(n: int) -> VersionRecord:
+            if not 1 <= n <= len(versions):
+                raise ValueError(f"No version {n} of '{label}' on slot '{slot}'")
+            return versions[n - 1]
+        return diff(pick(old_version), pick(new_version))
+
+    def diff_against_production(self, staging_id: str,
+                                namespace: Optional[str] = None) -> DiffPreview:
+        """
+        What promoting a staged snippet would change: its source against
+        the production version of its label on its slot (see
+        snippet_diff.preview()).  Nothing is speculated or promoted.
+
+        Raises ValueError for an unknown snippet and PhaseError for one
+        that is already past promotion (diff its versions instead).
+        """
+        snippet = self.get_snippet(staging_id, namespace)
+        if snippet is None:
+            raise ValueError(f"No staged snippet '{staging_id}'")
+        if snippet.phase not in _PREVIEWABLE_PHASES:
+            raise PhaseError(f"Snippet {staging_id} is '{snippet.phase.value}'; a diff preview "
+                             f"is for snippets that are not yet promoted")
+        live = self._live_entries(snippet.engine_letter, snippet.label,
+                                  exclude=staging_id, namespace=snippet.namespace)
+        if not live:
+            return preview(staging_id, snippet.engine_letter, snippet.label, snippet.program)
+        production = live[-1]
+        return preview(staging_id, snippet.engine_letter, snippet.label, snippet.program,
+                       production_id=production.staging_id,
+                       production_hash=production.code_hash,
+                       production=production.program,
+                       equivalence=equivalent(production.program, snippet.program,
+                                              snippet.language))
+
+    def slot_diff(self, engine_letter: str, staging_ids: List[str],
+                  namespace: Optional[str] = None) -> SlotDiffReport:
+        """
+        What a slot would look like if every one of `staging_ids` were
+        promoted onto it at once, in that order (see snippet_slotdiff):
+        labels added, updated and unchanged, the byte delta, and any
+        dependency cycle the promotions would close.  Read-only — no
+        promotion lock is taken.
+
+        Raises ValueError for an unknown slot, an unknown or repeated
+        staging ID, or a snippet staged for another slot or namespace,
+        PhaseError for one that is already past promotion, and
+        LabelConflictError if a REJECT label would already be live.
+        """
+        from .node_registry import LETTER_TO_ENGINE
+        if engine_letter not in LETTER_TO_ENGINE:
+            raise ValueError(f"Unknown slot '{engine_letter}'")
+        target = namespace or DEFAULT_NAMESPACE
+        if len(set(staging_ids)) != len(staging_ids):
+            raise ValueError("Each staging ID may appear only once")
+        pending: List[StagedSnippet] = []
+        for staging_id in staging_ids:
+            snippet = self.get_snippet(staging_id, namespace)
+            if snippet is None:
+                raise ValueError(f"No staged snippet '{staging_id}'")
+            if snippet.engine_letter != engine_letter or snippet.namespace != target:
+                raise ValueError(f"Snippet {staging_id} is staged for slot "
+                                 f"'{snippet.engine_letter}' in namespace "
+                                 f"'{snippet.namespace}'")
+            if snippet.phase not in _PREVIEWABLE_PHASES:
+                raise PhaseError(f"Snippet {staging_id} is '{snippet.phase.value}'; a slot "
+                                 f"diff is for snippets that are not yet promoted")
+            pending.append(snippet)
+
+        live = self._live_labels(engine_letter, target)
+        taken = dict(live)
+        for snippet in pending:
+            if snippet.label_policy == LabelConflictPolicy.REJECT and snippet.label in taken:
+                raise LabelConflictError(
+                    f"Label '{snippet.label}' would already be live on slot '{engine_letter}' "
+                    f"({taken[snippet.label].staging_id}) when {snippet.staging_id} is promoted")
+            taken[snippet.label] = snippet
+        return plan(engine_letter, target, live, pending)
+
+    def query(self, snippet_filter: SnippetFilter,
+              namespace: Optional[str] = None) -> QueryPage:
+        """
+        Search active and historical snippets.
+
+        Returns one page, oldest first; pass `next_page_token` back as
+        `page_token` for the next.  Raises ValueError for a bad token.
+        A scoped call (`namespace` given) only ever matches that
+        namespace, whatever snippet_filter.namespace says.
+        """
+        if namespace is not None:
+            snippet_filter = replace(snippet_filter, namespace=namespace)
+        return self._index.query(snippet_filter)
+
+    # ─────────────────────────────────────────────────────────────────────
+    # TAGS — hierarchical categories (see snippet_tags)
+    # ─────────────────────────────────────────────────────────────────────
+
+    def tag_query(self, pattern: str, namespace: Optional[str] = None,
+                  include_archived: bool = False) -> List:
+        """
+        Every snippet whose tags match `pattern` ('math/*',
+        'math/** AND pure', 'io/file OR io/net/*'), oldest first — live
+        StagedSnippets, or index records of earlier runs.  Looked up
+        through the index's tag index; raises TagError for a bad pattern.
+        """
+        page_filter = SnippetFilter(tags=pattern, include_archived=include_archived,
+                                    limit=MAX_PAGE_SIZE)
+        matches = []
+        while True:
+            page = self.query(page_filter, namespace)
+            matches += page.snippets
+            if not page.next_page_token:
+                return matches
+            page_filter = replace(page_filter, page_token=page.next_page_token)
+
+    def retag(self, staging_id: str, tags: List[str],
+              namespace: Optional[str] = None) -> StagedSnippet:
+        """
+        Replace a snippet's tags.  Once it has been promoted (or is in
+        canary) its tags are immutable to scoped callers — TagsImmutableError;
+        unscoped ones (in-process code, the namespace admin) may still
+        retag it.  The change is audited with the old and new tags.
+        """
+        tags = validate_tags(tags)
+        with self._lock:
+            snippet = self.get_snippet(staging_id, namespace)
+            if snippet is None:
+                raise ValueError(f"No staged snippet '{staging_id}'")
+            promoted = bool(snippet.promoted_at) or snippet.phase == StagingPhase.CANARY
+            if promoted and namespace is not None:
+                raise TagsImmutableError(
+                    f"Tags of {staging_id} are immutable once promoted; "
+                    f"retagging it needs the namespace admin credential")
+            previous, snippet.tags = snippet.tags, tags
+            snippet.updated_at = time.time()
+        self._index.put(snippet)
+        self._audit.log(AuditEventType.SNIPPET_RETAGGED, staging_id, {
+            'previous': previous,
+            'tags': tags,
+            'promoted': promoted,
+        })
+        return snippet
+
+    def get_engine(self, language: str) -> Optional[Engine]:
+        """Registered Engine for a language, if any."""
+        return self._engines.get(language)
+
+    def get_active(self, namespace: Optional[str] = None) -> List[StagedSnippet]:
         """Get all snippets currently in the pipeline."""
-        return list(self._staged.values())
+        return [s for s in list(self._staged.values())
+                if self._in_namespace(s, namespace) is not None]
 
-    def get_history(self, limit: int = 100) -> List[StagedSnippet]:
+    def get_history(self, limit: int = 100,
+                    namespace: Optional[str] = None) -> List[StagedSnippet]:
         """Get completed/rejected snippets (most recent first)."""
-        return list(reversed(self._history[-limit:]))
+        history = [h for h in self._history if self._in_namespace(h, namespace) is not None]
+        return list(reversed(history[-limit:]))
 
     def get_audit_trail(self, staging_id: Optional[str] = None,
-                        limit: int = 500) -> List[Dict]:
+                        limit: int = 500,
+                        namespace: Optional[str] = None) -> List[Dict]:
         """Get audit log entries, optionally filtered by staging_id."""
         if staging_id:
-            return self._audit.read_for_staging_id(staging_id)
-        return self._audit.read_all(limit=limit)
+            if namespace is not None and self.get_snippet(staging_id, namespace) is None:
+                return []
+            return self._migrated_trail(staging_id)
+        entries = self._audit.read_all(limit=limit)
+        if namespace is None:
+            return entries
+        return [e for e in entries
+                if self.get_snippet(e.get('staging_id', ''), namespace) is not None]
+
+    # ─────────────────────────────────────────────────────────────────────
+    # TRANSFER — JSON Lines export / import (see snippet_transfer)
+    # ─────────────────────────────────────────────────────────────────────
+
+    def export_snippets(self, snippet_filter: SnippetFilter, writer,
+                        namespace: Optional[str] = None) -> int:
+        """
+        Write every snippet matching `snippet_filter` to `writer` (a text
+        stream), one JSON Lines record each, oldest first.  Pages through
+        the whole result — snippet_filter.limit is the page size.
+
+        Only snippets the pipeline still holds have a source to export;
+        index records of earlier runs are left out.  Returns the number
+        of records written.
+        """
+        page_filter = replace(snippet_filter, page_token='')
+        written = 0
+        while True:
+            page = self.query(page_filter, namespace)
+            for snippet in page.snippets:
+                if not isinstance(snippet, StagedSnippet):
+                    continue
+                history = [r.to_dict() for r in self._promotions.records(
+                               snippet.engine_letter, snippet.label, snippet.namespace)
+                           if snippet.staging_id in (r.staging_id, r.restored_staging_id)]
+                writer.write(ExportRecord.from_snippet(snippet, history).encode() + '\n')
+                written += 1
+            if not page.next_page_token:
+                return written
+            page_filter = replace(page_filter, page_token=page.next_page_token)
+
+    def import_snippets(self, reader, options: Optional[ImportOptions] = None,
+                        namespace: Optional[str] = None) -> ImportReport:
+        """
+        Stage (and, where they were live, promote) the records of an
+        export_snippets() stream, in order.
+
+        `namespace` pins every record to one namespace; None keeps the
+        namespace each was exported from.  A record that fails — bad
+        format or hash, lint findings, an unresolvable dependency, a
+        failed run — is reported and the import moves on, unless
+        options.stop_on_error makes it raise SnippetImportError (carrying
+        the report so far).
+        """
+        options = options or ImportOptions()
+        report = ImportReport()
+        for number, line in enumerate(reader, 1):
+            if isinstance(line, bytes):
+                line = line.decode('utf-8')
+            if not line.strip():
+                continue
+            result = self._import_record(number, line, options, namespace)
+            report.records.append(result)
+            if result.status == ImportStatus.FAILED and options.stop_on_error:
+                raise SnippetImportError(f"Import stopped at line {number}: {result.error}",
+                                         report)
+        return report
+
+    def _import_record(self, number: int, line: str, options: ImportOptions,
+                       namespace: Optional[str]) -> ImportRecordResult:
+        result = ImportRecordResult(line=number, status=ImportStatus.FAILED)
+        try:
+            record = ExportRecord.decode(line)
+            meta = record.snippet
+            result.source_staging_id = meta.get('staging_id', '')
+            result.label = meta.get('label', '')
+            target = validate_namespace(namespace if namespace is not None
+                                        else meta.get('namespace'))
+            names = meta.get('env') or []
+            missing = [n for n in names if n not in options.env]
+            if missing:
+                raise EnvSpecError(f"No value given for exported env {', '.join(missing)}")
+
+            letter = meta.get('engine_letter', '')
+            if options.conflict_policy == ConflictPolicy.SKIP and \
+                    self._live_entries(letter, result.label, namespace=target):
+                result.status = ImportStatus.SKIPPED
+                return result
+            policy = (LabelConflictPolicy(options.conflict_policy.value)
+                      if options.conflict_policy != ConflictPolicy.SKIP
+                      else LabelConflictPolicy.REJECT)
+            snippet = self.queue_snippet(letter, meta['language'], record.code, result.label,
+                                         label_policy=policy,
+                                         parameters=meta.get('parameters') or None,
+                                         namespace=target,
+                                         env={n: options.env[n] for n in names} or None,
+                                         requires=meta.get('requires') or None,
+                                         output_schema=meta.get('output_schema') or None,
+                                         tags=meta.get('tags') or None,
+                                         resource_limits=meta.get('resource_limits') or None,
+                                         deterministic=bool(meta.get('deterministic')),
+                                         test_cases=meta.get('test_cases') or None,
+                                         suppress_credential_check=bool(
+                                             meta.get('suppress_credential_check')))
+            result.staging_id = snippet.staging_id
+            result.label = snippet.label
+            self._audit.log(AuditEventType.SNIPPET_IMPORTED, snippet.staging_id, {
+                'line': number,
+                'source_staging_id': result.source_staging_id,
+                'source_namespace': meta.get('namespace', ''),
+                'source_phase': meta.get('phase', ''),
+                'promotion_history': record.promotion_history,
+            })
+
+            snippet = self.speculate(snippet.staging_id,
+                                     arguments=meta.get('spec_arguments') or None)
+            result.spec_result = snippet.spec_result.value
+            live = options.promote and meta.get('phase') == StagingPhase.PROMOTED.value
+            if snippet.phase != StagingPhase.PASSED:
+                if live:
+                    result.error = snippet.spec_error or snippet.spec_result.value
+                    return result
+                result.status = ImportStatus.STAGED
+                return result
+            if not live:
+                self._lint_gate(snippet.staging_id, options.skip_lint)
+                result.status = ImportStatus.STAGED
+                return result
+            try:
+                self.promote(snippet.staging_id, skip_lint=options.skip_lint)
+            except PendingApprovalError:
+                result.status = ImportStatus.PENDING_APPROVAL
+                return result
+            result.status = ImportStatus.PROMOTED
+        except Exception as exc:
+            result.status = ImportStatus.FAILED
+            result.error = str(exc)
+            snippet = self._staged.get(result.staging_id)
+            if snippet is not None:
+                result.spec_result = snippet.spec_result.value
+        return result
+
+    # ─────────────────────────────────────────────────────────────────────
+    # MANIFESTS — many snippets declared in one YAML file (see snippet_manifest)
+    # ─────────────────────────────────────────────────────────────────────
+
+    def load_manifest(self, reader, base_dir: Optional[str] = None,
+                      namespace: str = DEFAULT_NAMESPACE) -> List[str]:
+        """
+        Queue every snippet the manifest in `reader` declares, in order, into
+        `namespace`; returns their staging IDs in declaration order.
+
+        `source_file` paths are read relative to `base_dir` (None allows
+        inline sources only).  The whole
+        file is checked first; if an entry is then refused by
+        queue_snippet(), the entries already queued are rejected and
+        ManifestError (with `entry`, 1-based) is raised — the manifest is
+        staged entirely or not at all.  Entry schedules are applied to
+        their slots' SlotConfig last.
+        """
+        namespace = validate_namespace(namespace)
+        entries = parse_manifest(reader, base_dir)
+        staged: List[StagedSnippet] = []
+        for number, entry in enumerate(entries, 1):
+            try:
+                snippet = self.queue_snippet(entry.slot, entry.language, entry.source,
+                                             entry.label, parameters=entry.parameters or None,
+                                             namespace=namespace, tags=entry.tags or None,
+                                             position=entry.position,
+                                             resource_limits=entry.resource_limits,
+                                             test_cases=entry.test_cases or None)
+            except ValueError as exc:
+                message = f"Entry {number} ({entry.label}): {exc}"
+                for done in reversed(staged):
+                    self.verdict(done.staging_id, 'reject',
+                                 f"Manifest rolled back: {message}", namespace)
+                raise ManifestError(message, number) from exc
+            staged.append(snippet)
+
+        for entry in entries:
+            if entry.schedule:
+                config = self.get_slot_config(entry.slot) or SlotConfig()
+                if config.schedule != entry.schedule:
+                    self.configure_slot(entry.slot, replace(config, schedule=entry.schedule))
+        for number, (entry, snippet) in enumerate(zip(entries, staged), 1):
+            self._audit.log(AuditEventType.MANIFEST_LOADED, snippet.staging_id, {
+                'entry': number,
+                'source_file': entry.source_file,
+                'schedule': entry.schedule,
+                'manifest_size': len(entries),
+            })
+        return [s.staging_id for s in staged]
+
+    def dump_manifest(self, snippet_filter: SnippetFilter, writer,
+                      namespace: Optional[str] = None) -> int:
+        """
+        Write the snippets matching `snippet_filter` to `writer` as one
+        manifest, oldest first, sources inline; load_manifest() reads it
+        back.  Like export_snippets(), only snippets the pipeline still
+        holds are written.  Returns the number of entries.
+        """
+        page_filter = replace(snippet_filter, page_token='')
+        entries: List[ManifestEntry] = []
+        while True:
+            page = self.query(page_filter, namespace)
+            for snippet in page.snippets:
+                if not isinstance(snippet, StagedSnippet):
+                    continue
+                config = self.get_slot_config(snippet.engine_letter)
+                entries.append(ManifestEntry.from_snippet(
+                    snippet, config.schedule if config is not None else ''))
+            if not page.next_page_token:
+                break
+            page_filter = replace(page_filter, page_token=page.next_page_token)
+        if not entries:
+            raise ValueError("No snippets match the filter")
+        write_manifest(entries, writer)
+        return len(entries)
+
+    # ─────────────────────────────────────────────────────────────────────
+    # NAMESPACES — tenant isolation (see snippet_namespace)
+    # ─────────────────────────────────────────────────────────────────────
+
+    def namespaces(self) -> Dict[str, int]:
+        """Namespace → number of snippets held (active + history)."""
+        counts: Dict[str, int] = {}
+        with self._lock:
+            for s in list(self._history) + list(self._staged.values()):
+                counts[s.namespace] = counts.get(s.namespace, 0) + 1
+        return dict(sorted(counts.items()))
+
+    def slot_namespace(self, slot_id: str) -> Optional[str]:
+        """
+        Namespace of the snippet live in registry slot `slot_id`, or None
+        if the slot's code did not come through the pipeline.
+        """
+        with self._lock:
+            owner = next((h for h in reversed(self._history)
+                          if h.registry_slot_id == slot_id
+                          and h.phase == StagingPhase.PROMOTED), None)
+        return owner.namespace if owner is not None else None
+
+    def check_admin_credential(self, credential: str) -> bool:
+        """True if `credential` is the namespace admin credential."""
+        return check_credential(credential, self._admin_credential_hash)
+
+    def check_namespace_credential(self, namespace: str, credential: Optional[str]) -> bool:
+        """
+        True if a remote caller presenting `credential` may act in `namespace`.
+
+        A namespace with a configured credential needs it; the default
+        namespace is open while it has none; every other namespace is
+        closed until one is configured.
+        """
+        credential_hash = self._namespace_credential_hashes.get(namespace)
+        if credential_hash is None:
+            return namespace == DEFAULT_NAMESPACE
+        return check_credential(credential or '', credential_hash)
+
+    # ─────────────────────────────────────────────────────────────────────
+    # ID MIGRATION — move staging IDs to another prefix (see snippet_migrate)
+    # ─────────────────────────────────────────────────────────────────────
+
+    @property
+    def staging_id_prefix(self) -> str:
+        return self._id_prefix
+
+    def migrate_id_prefix(self, from_prefix: str, to_prefix: str,
+                          options: Optional[MigrateOptions] = None) -> MigrationReport:
+        """
+        Rename every staging ID starting with `from_prefix` to start with
+        `to_prefix`, rewriting every reference the pipeline holds to it.
+        A dry run returns the report, conflicts included, and changes
+        nothing.  Otherwise raises MigrationConflictError (nothing renamed)
+        if a new ID is already taken and options.force isn't set, if force
+        would drop a live or in-flight record, or if a snippet to rename is
+        in flight.  Unscoped: remote callers go through NamespaceAdmin.
+        """
+        options = options or MigrateOptions()
+        with self._lock:
+            records = {s.staging_id: s
+                       for s in itertools.chain(self._history, self._staged.values())}
+            mapping = plan_prefix_migration(records, from_prefix, to_prefix)
+            report = MigrationReport(from_prefix=validate_id_prefix(from_prefix),
+                                     to_prefix=validate_id_prefix(to_prefix),
+                                     dry_run=options.dry_run, mapping=mapping)
+            busy = self._in_flight_ids()
+            report.in_flight = [sid for sid in mapping if sid in busy]
+            report.conflicts = sorted(
+                new for new in mapping.values()
+                if new not in mapping and (new in records or self._index.get(new) is not None))
+            if options.force:
+                report.overwritten = list(report.conflicts)
+            report.references = self._rename_references(mapping, dry_run=True)
+            if options.dry_run:
+                return report
+
+            if report.in_flight:
+                raise MigrationConflictError(
+                    f"Cannot migrate while {len(report.in_flight)} snippet(s) are in flight: "
+                    f"{', '.join(report.in_flight[:5])}", report)
+            if report.conflicts and not options.force:
+                raise MigrationConflictError(
+                    f"{len(report.conflicts)} ID(s) already exist under '{report.to_prefix}': "
+                    f"{', '.join(report.conflicts[:5])} (set force to overwrite)", report)
+            live = [sid for sid in report.overwritten
+                    if sid in busy or (sid in records
+                                       and records[sid].phase == StagingPhase.PROMOTED)]
+            if live:
+                raise MigrationConflictError(
+                    f"Cannot overwrite live or in-flight snippet(s): {', '.join(live[:5])}", report)
+
+            dropped = [(records[sid], records[sid] in self._history)
+                       for sid in report.overwritten if sid in records]
+            for snippet, in_history in dropped:
+                if in_history:
+                    self._history.remove(snippet)
+                else:
+                    del self._staged[snippet.staging_id]
+            try:
+                self._rename_references(mapping)
+                self._rekey_index(list(mapping) + report.overwritten,
+                                  [records[old] for old in mapping])
+            except Exception:
+                # Rename back whatever had been renamed, restore the dropped records
+                self._rename_references({new: old for old, new in mapping.items()})
+                for snippet, in_history in dropped:
+                    if in_history:
+                        self._history.append(snippet)
+                    else:
+                        self._staged[snippet.staging_id] = snippet
+                self._rekey_index(list(mapping.values()),
+                                  [records[old] for old in mapping] + [s for s, _ in dropped])
+                raise
+            report.applied = True
+
+        for old, new in mapping.items():
+            # One entry where the record leaves, one where it arrives
+            self._audit.log(AuditEventType.ID_MIGRATED, old, {
+                'new_id': new,
+                'from_prefix': report.from_prefix,
+                'to_prefix': report.to_prefix,
+            })
+            self._audit.log(AuditEventType.ID_MIGRATED, new, {
+                'previous_id': old,
+                'from_prefix': report.from_prefix,
+                'to_prefix': report.to_prefix,
+                'overwrote': new in report.overwritten,
+            })
+        return report
+
+    def _rekey_index(self, removed: List[str], snippets: List[StagedSnippet]):
+        for sid in removed:
+            self._index.remove(sid)
+        for snippet in snippets:
+            self._index.put(snippet)
+
+    def _in_flight_ids(self) -> set:
+        """Staging IDs a migration must not move: running, rolling out or swapping."""
+        busy = {sid for sid, s in itertools.chain(((s.staging_id, s) for s in self._history),
+                                                  self._staged.items())
+                if s.phase in (StagingPhase.SPECULATING, StagingPhase.PROMOTING,
+                               StagingPhase.CANARY, StagingPhase.PENDING_SWAP)}
+        busy.update(self._promotion_locks)
+        for rollout in self._canaries.running():
+            busy.update((rollout.canary_id, rollout.baseline_id))
+        for test in self._ab_tests.running():
+            busy.update((test.control.staging_id, test.treatment.staging_id))
+        for swap in self._swaps.pending():
+            busy.update((swap.staging_id, swap.replaces))
+        return busy
+
+    def _rename_references(self, mapping: Dict[str, str],
+                           dry_run: bool = False) -> Dict[str, int]:
+        """
+        Rewrite staging IDs per `mapping` in the records and every
+        component holding them (the caller holds `_lock`).  Returns the
+        references rewritten — or, for a dry run, that would be — by kind.
+        The index is re-keyed by the caller, outside the lock.
+        """
+        counts = {'snippets': 0, 'snippet_references': 0, 'approvals': 0, 'reruns': 0}
+        for snippet in itertools.chain(self._history, self._staged.values()):
+            if snippet.staging_id in mapping:
+                counts['snippets'] += 1
+            refs = [snippet.rolled_back_to, snippet.canary_baseline_id]
+            refs += [dep.get('staging_id', '') for dep in snippet.dependencies]
+            counts['snippet_references'] += sum(
+                1 for sid in refs + snippet.superseded_ids if sid in mapping)
+            if dry_run:
+                continue
+            snippet.staging_id = mapping.get(snippet.staging_id, snippet.staging_id)
+            snippet.rolled_back_to = mapping.get(snippet.rolled_back_to, snippet.rolled_back_to)
+            snippet.canary_baseline_id = mapping.get(snippet.canary_baseline_id,
+                                                     snippet.canary_baseline_id)
+            rename_list(snippet.superseded_ids, mapping)
+            for dep in snippet.dependencies:
+                if dep.get('staging_id') in mapping:
+                    dep['staging_id'] = mapping[dep['staging_id']]
+
+        counts['approvals'] = sum(1 for sid in self._approvals if sid in mapping)
+        counts['reruns'] = sum(len(h) for sid, h in self._reruns.items() if sid in mapping)
+        counts['replays'] = sum(1 for s in self._replay_snapshots.values()
+                                if s.staging_id in mapping)
+        if not dry_run:
+            self._staged = {s.staging_id: s for s in self._staged.values()}
+            for record in self._approvals.values():
+                record.staging_id = mapping.get(record.staging_id, record.staging_id)
+            self._approvals = {r.staging_id: r for r in self._approvals.values()}
+            self._reruns = {mapping.get(sid, sid): history
+                            for sid, history in self._reruns.items()}
+            for history in self._reruns.values():
+                for record in history.records():
+                    record.staging_id = mapping.get(record.staging_id, record.staging_id)
+            for snapshot in self._replay_snapshots.values():
+                snapshot.staging_id = mapping.get(snapshot.staging_id, snapshot.staging_id)
+
+        counts['promotion_history'] = self._promotions.rename_ids(mapping, dry_run)
+        counts['canaries'] = self._canaries.rename_ids(mapping, dry_run)
+        counts['ab_tests'] = self._ab_tests.rename_ids(mapping, dry_run)
+        counts['swaps'] = self._swaps.rename_ids(mapping, dry_run)
+        counts['streams'] = self._streams.rename_ids(mapping, dry_run)
+        counts['webhooks'] = (self._webhooks.rename_ids(mapping, dry_run)
+                              if self._webhooks is not None else 0)
+        return counts
+
+    def _migrated_trail(self, staging_id: str) -> List[Dict]:
+        """
+        Audit entries of the record now holding `staging_id`, newest
+        first, followed by those it had under earlier IDs.  ID_MIGRATED
+        entries mark where a record arrived at an ID (previous_id) and
+        left one (new_id); anything under an ID from before another
+        record left it belongs to that other record.
+        """
+        trail: List[Dict] = []
+        before, came_from = float('inf'), ''
+        while True:
+            kept = []
+            for entry in self._audit.read_for_staging_id(staging_id):
+                if entry.get('timestamp', 0) > before:
+                    continue
+                if entry.get('event') == AuditEventType.ID_MIGRATED.value:
+                    data = entry.get('data', {})
+                    if came_from and data.get('new_id') == came_from:
+                        kept.append(entry)               # Where this record left the ID
+                        came_from = ''
+                        continue
+                    if data.get('previous_id'):
+                        kept.append(entry)
+                        staging_id, came_from = data['previous_id'], staging_id
+                        before = entry['timestamp']
+                        break
+                    return trail + kept                  # Another record left it before
+                kept.append(entry)
+            else:
+                return trail + kept
+            trail += kept
 
     def get_reserved_positions(self) -> Dict[str, List[int]]:
         """Get currently reserved (but not yet committed) positions."""
@@ -937,6 +4473,9 @@ class StagingPipeline:
         promoted_count = sum(1 for h in self._history if h.phase == StagingPhase.PROMOTED)
         rejected_count = sum(1 for h in self._history if h.phase == StagingPhase.REJECTED)
         rolled_back = sum(1 for h in self._history if h.phase == StagingPhase.ROLLED_BACK)
+        superseded = sum(1 for h in self._history if h.phase == StagingPhase.SUPERSEDED)
+        evicted = sum(1 for h in self._history if h.phase == StagingPhase.EVICTED)
+        archived = sum(1 for h in self._history if h.phase == StagingPhase.ARCHIVED)
 
         return {
             'active_count': len(active),
@@ -945,7 +4484,12 @@ class StagingPipeline:
             'promoted_total': promoted_count,
             'rejected_total': rejected_count,
             'rolled_back_total': rolled_back,
+            'superseded_total': superseded,
+            'evicted_total': evicted,
+            'archived_total': archived,
             'reserved_positions': self.get_reserved_positions(),
+            'open_circuits': len(self._breaker.open_circuits()),
+            'running_canaries': len(self._canaries.running()),
         }
 
     # ─────────────────────────────────────────────────────────────────────
@@ -959,4 +4503,7 @@ class StagingPipeline:
             self._history.append(snippet)
             # Cap history at 1000
             if len(self._history) > 1000:
+                if not self._index.persistent:
+                    for dropped in self._history[:-1000]:
+                        self._index.remove(dropped.staging_id)
                 self._history = self._history[-1000:]
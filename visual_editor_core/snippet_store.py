@@ -0,0 +1,235 @@
+"""
+Snippet Store — content-addressable storage for snippet source payloads.
+
+Every staged snippet already carries a SHA-256 `code_hash`.  The store
+uses that hash as the storage key, so the same source body submitted
+under different staging IDs or labels is persisted exactly ONCE and every
+staging record simply references the payload by its hash:
+
+    stg-6ecfd6d20bfe ─┐
+    stg-31367575baa8 ─┼──►  ae7a346e04d83e05…  (one blob on disk)
+    stg-07740f950eed ─┘
+
+Layout of the on-disk store (git-style fan-out to keep directories small):
+
+    <root>/
+        ae/
+            ae7a346e04d83e05…       ← raw source bytes, no header
+        ad/
+            adf8bbb5993bf7ae…
+
+Writes verify the payload against its hash.  If a hash is already present
+the stored bytes are compared with the incoming ones — a mismatch means a
+genuine collision (or on-disk corruption) and raises HashCollisionError
+instead of silently keeping either copy.
+
+A sealed payload (snippet_encryption) is stored under the hash of its
+plaintext and is not checked against it — the pipeline hashes before it
+seals and verifies after it opens.  It replaces a plain copy of the same
+hash, and a plain put onto a sealed one keeps the sealed copy.
+
+FileSnippetStore logs writes at DEBUG and refused or failed writes at
+ERROR (see snippet_log).
+"""
+
+import os
+import hashlib
+import logging
+import tempfile
+import threading
+from abc import ABC, abstractmethod
+from typing import Dict, Optional
+
+from .snippet_encryption import is_sealed
+from .snippet_log import default_logger, log
+
+
+class SnippetStoreError(ValueError):
+    """Base class for snippet store failures."""
+
+
+class PayloadNotFoundError(SnippetStoreError, KeyError):
+    """No payload is stored under the requested hash."""
+
+    def __str__(self) -> str:  # KeyError would repr() the message
+        return self.args[0] if self.args else ''
+
+
+class HashMismatchError(SnippetStoreError):
+    """The payload's SHA-256 does not match the hash it was stored under."""
+
+
+class HashCollisionError(SnippetStoreError):
+    """A different payload is already stored under the same hash."""
+
+
+def compute_code_hash(body: bytes) -> str:
+    """SHA-256 hex digest used as the content address of a payload."""
+    return hashlib.sha256(body).hexdigest()
+
+
+class SnippetStore(ABC):
+    """Content-addressable blob store keyed by code_hash (SHA-256 hex)."""
+
+    @abstractmethod
+    def put(self, code_hash: str, body: bytes, sealed: bool = False) -> bool:
+        """
+        Store `body` under `code_hash`.
+
+        Returns True if the payload was newly written, False if an
+        identical payload was already present (deduplicated).
+
+        Raises HashMismatchError if sha256(body) != code_hash and
+        HashCollisionError if different bytes already live at that hash.
+        With `sealed`, `body` is the encrypted payload whose plaintext
+        hashes to `code_hash`: it is not verified, and it replaces a
+        plain copy; any sealed copy already stored is kept.
+        """
+
+    @abstractmethod
+    def get(self, code_hash: str) -> bytes:
+        """Return the payload for `code_hash` (PayloadNotFoundError if absent)."""
+
+    @abstractmethod
+    def contains(self, code_hash: str) -> bool:
+        """True if a payload is stored under `code_hash`."""
+
+    def locate(self, code_hash: str) -> str:
+        """Where the payload lives: a file path for on-disk stores, else 'sha256:<hash>'."""
+        return f"sha256:{code_hash}"
+
+    def delete(self, code_hash: str) -> bool:
+        """Drop a payload nothing references any more; True if one was removed."""
+        return False
+
+    @staticmethod
+    def _replaces(code_hash: str, existing: Optional[bytes], body: bytes, sealed: bool) -> bool:
+        """Whether `body` should be written over `existing` (None: nothing stored)."""
+        if existing is None:
+            return True
+        if is_sealed(existing):
+            return False                 # Sealed copies are kept, whoever stores next
+        if sealed:
+            return True                  # Don't leave the plain copy behind
+        if existing != body:
+            raise HashCollisionError(f"Hash collision on {code_hash[:16]}…")
+        return False
+
+    @staticmethod
+    def _verify(code_hash: str, body: bytes):
+        actual = compute_code_hash(body)
+        if actual != code_hash:
+            raise HashMismatchError(
+                f"Payload hash {actual[:16]}… does not match key {code_hash[:16]}…"
+            )
+
+
+class MemorySnippetStore(SnippetStore):
+    """In-process store — used by tests and ephemeral pipelines."""
+
+    def __init__(self):
+        self._blobs: Dict[str, bytes] = {}
+        self._lock = threading.Lock()
+
+    def put(self, code_hash: str, body: bytes, sealed: bool = False) -> bool:
+        if not sealed:
+            self._verify(code_hash, body)
+        with self._lock:
+            if not self._replaces(code_hash, self._blobs.get(code_hash), body, sealed):
+                return False
+            self._blobs[code_hash] = bytes(body)
+            return True
+
+    def get(self, code_hash: str) -> bytes:
+        with self._lock:
+            body = self._blobs.get(code_hash)
+        if body is None:
+            raise PayloadNotFoundError(f"No payload stored for hash {code_hash[:16]}…")
+        return body
+
+    def contains(self, code_hash: str) -> bool:
+        with self._lock:
+            return code_hash in self._blobs
+
+    def delete(self, code_hash: str) -> bool:
+        with self._lock:
+            return self._blobs.pop(code_hash, None) is not None
+
+
+class FileSnippetStore(SnippetStore):
+    """On-disk store with two-character fan-out directories.
+
+    Writes go to a temp file in the target directory and are renamed into
+    place, so a crash mid-write never leaves a truncated payload behind.
+    """
+
+    def __init__(self, root: str, logger: Optional[logging.Logger] = None):
+        self._root = root
+        self._lock = threading.Lock()
+        self._logger = logger or default_logger()
+        os.makedirs(root, exist_ok=True)
+
+    @property
+    def root(self) -> str:
+        return self._root
+
+    def _path(self, code_hash: str) -> str:
+        if len(code_hash) < 3 or not all(c in '0123456789abcdef' for c in code_hash):
+            raise SnippetStoreError(f"Invalid code_hash '{code_hash}'")
+        return os.path.join(self._root, code_hash[:2], code_hash)
+
+    def put(self, code_hash: str, body: bytes, sealed: bool = False) -> bool:
+        try:
+            if not sealed:
+                self._verify(code_hash, body)
+            path = self._path(code_hash)
+            with self._lock:
+                existing = None
+                if os.path.exists(path):
+                    with open(path, 'rb') as f:
+                        existing = f.read()
+                if not self._replaces(code_hash, existing, body, sealed):
+                    log(self._logger, logging.DEBUG, 'payload_deduplicated',
+                        code_hash=code_hash[:16])
+                    return False
+
+                os.makedirs(os.path.dirname(path), exist_ok=True)
+                fd, tmp = tempfile.mkstemp(dir=os.path.dirname(path), prefix='.tmp-')
+                try:
+                    with os.fdopen(fd, 'wb') as f:
+                        f.write(body)
+                    os.replace(tmp, path)
+                except Exception:
+                    if os.path.exists(tmp):
+                        os.unlink(tmp)
+                    raise
+        except Exception as exc:
+            log(self._logger, logging.ERROR, 'payload_write_failed',
+                code_hash=code_hash[:16], error=str(exc))
+            raise
+        log(self._logger, logging.DEBUG, 'payload_stored', code_hash=code_hash[:16],
+            bytes=len(body), sealed=sealed)
+        return True
+
+    def get(self, code_hash: str) -> bytes:
+        path = self._path(code_hash)
+        try:
+            with open(path, 'rb') as f:
+                return f.read()
+        except FileNotFoundError:
+            raise PayloadNotFoundError(f"No payload stored for hash {code_hash[:16]}…")
+
+    def contains(self, code_hash: str) -> bool:
+        return os.path.exists(self._path(code_hash))
+
+    def locate(self, code_hash: str) -> str:
+        return self._path(code_hash)
+
+    def delete(self, code_hash: str) -> bool:
+        path = self._path(code_hash)
+        with self._lock:
+            try:
+                os.unlink(path)
+            except FileNotFoundError:
+                return False
+            return True
@@ -0,0 +1,196 @@
+"""
+Snippet Stream — live stdout / stderr of a speculative run, frame by frame.
+
+speculate() opens an OutputStream for the snippet and the executor writes
+into it while the program runs (executors with streams_output = True, such
+as GoExecutor, line by line; every other one in a single piece when it
+returns).  Any number of readers can follow it:
+
+    stream = pipeline.output_stream(staging_id)
+    for frame in stream.follow():          # blocks until the next frame
+        if frame is None:                  # idle poll — check the client is still there
+            continue
+        send(frame.to_dict())              # {seq, stream: 'stdout'|'stderr', data, timestamp}
+    stream.close_code, stream.close_info() # CloseCode.PASS, {spec_result, spec_time}
+
+Frames are numbered by one `seq` counter across both pipes, so a reader
+that reconnects resumes with follow(after_seq=last_seen).  A finished
+stream keeps its frames (up to MAX_STREAM_BYTES) so late readers get a
+replay; the hub keeps the most recent MAX_FINISHED_STREAMS of them, and
+for older runs output_stream() rebuilds one from the snippet's recorded
+spec_output / spec_error.
+
+CloseCode values sit in the WebSocket private range (4000–4999) so the
+REST layer can close the socket with them directly.
+"""
+
+import threading
+import time
+from collections import OrderedDict
+from dataclasses import dataclass, asdict
+from enum import IntEnum
+from typing import Dict, Iterator, List, Optional
+
+
+MAX_STREAM_BYTES = 1024 * 1024          # Frame data kept per stream
+MAX_FINISHED_STREAMS = 256               # Finished streams the hub keeps for replay
+FOLLOW_POLL_INTERVAL = 1.0               # Seconds follow() waits before yielding None
+
+STDOUT = 'stdout'
+STDERR = 'stderr'
+
+
+class CloseCode(IntEnum):
+    """How a stream ended, one code per spec_result."""
+    PASS      = 4000
+    FAIL      = 4001
+    TIMEOUT   = 4002
+    LINT_FAIL = 4003
+    RESOURCE_EXCEEDED = 4004
+    SCHEMA_FAIL = 4005
+    COVERAGE_FAIL = 4006
+    BENCH_REGRESSION = 4007
+    WEAK_SPEC = 4008
+    CASE_FAIL = 4009
+
+    @classmethod
+    def for_result(cls, spec_result: str) -> 'CloseCode':
+        return cls.__members__.get(spec_result, cls.FAIL)
+
+
+@dataclass
+class StreamFrame:
+    seq: int
+    stream: str                          # STDOUT | STDERR
+    data: str
+    timestamp: float
+
+    def to_dict(self) -> Dict:
+        return asdict(self)
+
+
+class OutputStream:
+    """The frames of one snippet's run; thread-safe, one writer, many readers."""
+
+    def __init__(self, staging_id: str, max_bytes: int = MAX_STREAM_BYTES):
+        self.staging_id = staging_id
+        self._max_bytes = max_bytes
+        self._frames: List[StreamFrame] = []
+        self._bytes = 0
+        self._seq = 0
+        self._cond = threading.Condition()
+        self.truncated = False           # Output past max_bytes was dropped
+        self.closed = False
+        self.close_code: Optional[CloseCode] = None
+        self.spec_result = ''
+        self.spec_time = 0.0
+
+    def write(self, stream: str, data: str):
+        """Append `data` from `stream`; ignored once the stream is closed."""
+        if not data:
+            return
+        with self._cond:
+            if self.closed:
+                return
+            size = len(data.encode('utf-8', 'replace'))
+            if self._bytes + size > self._max_bytes:
+                self.truncated = True
+                return
+            self._seq += 1
+            self._bytes += size
+            self._frames.append(StreamFrame(self._seq, stream, data, time.time()))
+            self._cond.notify_all()
+
+    def close(self, spec_result: str, spec_time: float,
+              output: str = '', error: str = ''):
+        """
+        End the stream with the run's verdict.  `output` / `error` are the
+        run's full stdout / stderr, written first when nothing was streamed
+        live (executors that only report at the end).
+        """
+        with self._cond:
+            if self.closed:
+                return
+            if not self._frames:
+                for stream, data in ((STDOUT, output), (STDERR, error)):
+                    if data:
+                        self._seq += 1
+                        self._frames.append(StreamFrame(self._seq, stream, data, time.time()))
+            self.closed = True
+            self.spec_result = spec_result
+            self.spec_time = spec_time
+            self.close_code = CloseCode.for_result(spec_result)
+            self._cond.notify_all()
+
+    def frames(self, after_seq: int = 0) -> List[StreamFrame]:
+        """Frames written so far with seq > after_seq."""
+        with self._cond:
+            return [f for f in self._frames if f.seq > after_seq]
+
+    def follow(self, after_seq: int = 0,
+               poll_interval: float = FOLLOW_POLL_INTERVAL) -> Iterator[Optional[StreamFrame]]:
+        """
+        Yield frames after `after_seq` as they arrive, ending once the
+        stream is closed and drained.  Yields None every `poll_interval`
+        seconds without output.
+        """
+        last = after_seq
+        while True:
+            with self._cond:
+                pending = [f for f in self._frames if f.seq > last]
+                if not pending and not self.closed:
+                    self._cond.wait(poll_interval)
+                    pending = [f for f in self._frames if f.seq > last]
+                done = self.closed
+            for frame in pending:
+                last = frame.seq
+                yield frame
+            if done and not pending:
+                return
+            if not pending:
+                yield None
+
+    def close_info(self) -> Dict:
+        return {'spec_result': self.spec_result, 'spec_time': self.spec_time,
+                'truncated': self.truncated}
+
+
+class StreamHub:
+    """staging_id → OutputStream for running snippets and recently finished ones."""
+
+    def __init__(self, max_finished: int = MAX_FINISHED_STREAMS):
+        self._max_finished = max_finished
+        self._streams: 'OrderedDict[str, OutputStream]' = OrderedDict()
+        self._lock = threading.Lock()
+
+    def open(self, staging_id: str) -> OutputStream:
+        """The stream a new run writes to (readers waiting on an open one keep it)."""
+        with self._lock:
+            stream = self._streams.get(staging_id)
+            if stream is None or stream.closed:
+                stream = OutputStream(staging_id)
+                self._streams[staging_id] = stream
+            self._streams.move_to_end(staging_id)
+            self._trim()
+            return stream
+
+    def get(self, staging_id: str) -> Optional[OutputStream]:
+        with self._lock:
+            return self._streams.get(staging_id)
+
+    def rename_ids(self, mapping: Dict[str, str], dry_run: bool = False) -> int:
+        """Re-key the streams of renamed snippets (see snippet_migrate); returns the count."""
+        with self._lock:
+            changed = sum(1 for sid in self._streams if sid in mapping)
+            if changed and not dry_run:
+                renamed = OrderedDict()
+                for sid, stream in self._streams.items():
+                    stream.staging_id = mapping.get(sid, sid)
+                    renamed[stream.staging_id] = stream
+                self._streams = renamed
+            return changed
+
+    def _trim(self):
+        finished = [sid for sid, s in self._streams.items() if s.closed]
+        for sid in finished[:max(0, len(finished) - self._max_finished)]:
+            del self._streams[sid]
@@ -0,0 +1,294 @@
+"""
+Snippet Swap — replace a live version without cutting off its executions.
+
+A normal OVERWRITE promotion swaps the code behind a slot at once, even
+while executions of the old version are still running.  A graceful swap
+waits for them:
+
+    pipeline.promote(staging_id, graceful_swap=GracefulSwap(drain_timeout=30))
+
+        in-flight executions of v1 ──► finish ──► count hits 0 ──► v2 installed
+        executions arriving meanwhile ─ wait ───────────────────►  run v2
+                                 │
+                                 └── drain_timeout passes ──► v1's executions
+                                     cancelled, v2 installed (status FORCED)
+
+Every execution of a slot holds an ExecutionLease for its duration
+(StagingPipeline.begin_slot_run() / end_slot_run()); the controller keeps
+the count per registry slot.  While a swap is pending the new snippet is
+in the PENDING_SWAP phase and begin_slot_run() blocks, so the drain
+always finishes — new executions are held back, not admitted to the old
+version — and the held executions then run the new one (the registry
+commits it to a new slot; their leases name that slot).
+
+A forced swap sets each outstanding lease's cancel_event: executors that
+are `cancellable` (Go) kill the run, and the result of any other run is
+reported as cancelled.
+
+The controller enforces drain timeouts on a background thread; tick()
+performs one pass synchronously (tests drive it that way).
+"""
+
+import itertools
+import threading
+import time
+from enum import Enum
+from dataclasses import dataclass, field, asdict
+from typing import Callable, Dict, List, Optional
+
+
+DEFAULT_DRAIN_TIMEOUT = 30.0         # seconds in-flight executions get to finish
+DEFAULT_POLL_INTERVAL = 0.25         # seconds between timeout checks
+
+
+class SwapStatus(str, Enum):
+    PENDING   = 'pending'            # Waiting for in-flight executions to finish
+    COMPLETED = 'completed'          # Drained; new version installed
+    FORCED    = 'forced'             # drain_timeout passed; in-flight executions cancelled
+    ABORTED   = 'aborted'            # The new version could not be installed
+
+
+@dataclass
+class GracefulSwap:
+    """Drain settings for one promotion (promote(graceful_swap=...))."""
+    drain_timeout: float = DEFAULT_DRAIN_TIMEOUT
+
+    def __post_init__(self):
+        if isinstance(self.drain_timeout, bool) or not isinstance(self.drain_timeout, (int, float)) \
+                or self.drain_timeout <= 0:
+            raise ValueError("drain_timeout must be > 0")
+
+    @classmethod
+    def from_dict(cls, d: Dict) -> 'GracefulSwap':
+        known = {k: d[k] for k in cls.__dataclass_fields__ if k in d}
+        unknown = sorted(set(d) - set(known))
+        if unknown:
+            raise ValueError(f"Unknown graceful_swap option(s): {', '.join(unknown)}")
+        return cls(**known)
+
+    def to_dict(self) -> Dict:
+        return asdict(self)
+
+
+@dataclass
+class ExecutionLease:
+    """One execution of a registry slot, from begin_slot_run() to end_slot_run()."""
+    lease_id: int
+    slot_id: str
+    started_at: float
+    cancel_event: threading.Event = field(default_factory=threading.Event, repr=False)
+    swapped: bool = False                # A swap completed while this execution waited
+                                         # (slot_id is then the new version's slot)
+
+    @property
+    def cancelled(self) -> bool:
+        return self.cancel_event.is_set()
+
+
+@dataclass
+class SlotSwap:
+    """One graceful swap in progress (or finished)."""
+    staging_id: str                      # The version being installed
+    replaces: str                        # The live version it supersedes
+    slot_id: str
+    drain_timeout: float
+    requested_at: float
+    in_flight_at_request: int = 0
+    status: SwapStatus = SwapStatus.PENDING
+    completed_at: float = 0.0
+    cancelled: int = 0                   # Executions cancelled by a forced swap
+    reason: str = ''
+    installed_slot_id: str = ''          # Registry slot the new version was committed to
+
+    @property
+    def deadline(self) -> float:
+        return self.requested_at + self.drain_timeout
+
+    def to_dict(self) -> Dict:
+        return {
+            'staging_id': self.staging_id,
+            'replaces': self.replaces,
+            'slot_id': self.slot_id,
+            'drain_timeout': self.drain_timeout,
+            'requested_at': self.requested_at,
+            'in_flight_at_request': self.in_flight_at_request,
+            'status': self.status.value,
+            'completed_at': self.completed_at,
+            'cancelled': self.cancelled,
+            'reason': self.reason,
+            'installed_slot_id': self.installed_slot_id,
+        }
+
+
+class SwapController:
+    """
+    Counts in-flight executions per registry slot and completes pending
+    swaps once their slot drains (or their drain_timeout passes).
+
+    bind() attaches the pipeline; it calls start() from
+    promote(graceful_swap=...), and the controller calls finish_swap()
+    back on it — from the thread whose end_slot_run() drained the slot,
+    or from the timeout pass.
+    """
+
+    def __init__(self, poll_interval: float = DEFAULT_POLL_INTERVAL,
+                 clock: Callable[[], float] = time.time,
+                 background: bool = True):
+        self._poll_interval = poll_interval
+        self._clock = clock
+        self._background = background
+        self._pipeline = None
+        self._cond = threading.Condition()
+        self._ids = itertools.count(1)
+        self._leases: Dict[str, Dict[int, ExecutionLease]] = {}   # slot_id → in flight
+        self._pending: Dict[str, SlotSwap] = {}                  # slot_id → pending swap
+        self._finishing: Dict[str, SlotSwap] = {}                # slot_id → being installed
+        self._swaps: Dict[str, SlotSwap] = {}                     # staging_id → swap
+        self._stop = threading.Event()
+        self._thread: Optional[threading.Thread] = None
+
+    def bind(self, pipeline):
+        self._pipeline = pipeline
+
+    # ── Executions ───────────────────────────────────────────────────
+
+    def acquire(self, slot_id: str) -> ExecutionLease:
+        """
+        Count an execution in, waiting first while the slot has a swap
+        pending.  Once the swap installs the new version the execution is
+        counted on (and runs) the new version's slot instead.
+        """
+        with self._cond:
+            swapped = False
+            while slot_id in self._pending or slot_id in self._finishing:
+                swap = self._pending.get(slot_id) or self._finishing[slot_id]
+                swapped = True
+                self._cond.wait(self._poll_interval)
+                if (swap.installed_slot_id and slot_id not in self._pending
+                        and slot_id not in self._finishing):
+                    slot_id = swap.installed_slot_id
+            lease = ExecutionLease(lease_id=next(self._ids), slot_id=slot_id,
+                                   started_at=self._clock(), swapped=swapped)
+            self._leases.setdefault(slot_id, {})[lease.lease_id] = lease
+            return lease
+
+    def release(self, lease: ExecutionLease):
+        with self._cond:
+            in_flight = self._leases.get(lease.slot_id, {})
+            in_flight.pop(lease.lease_id, None)
+            swap = self._pending.get(lease.slot_id)
+            if swap is None or in_flight:
+                return
+            self._begin_finish(swap, SwapStatus.COMPLETED)
+        self._finish(swap)
+
+    def in_flight(self, slot_id: str) -> int:
+        with self._cond:
+            return len(self._leases.get(slot_id, {}))
+
+    # ── Swaps ────────────────────────────────────────────────────────
+
+    def start(self, staging_id: str, replaces: str, slot_id: str,
+              config: GracefulSwap) -> SlotSwap:
+        """
+        Register a swap.  If nothing is in flight it is COMPLETED at once
+        and the caller installs the new version itself; otherwise it is
+        PENDING until finish_swap() is called back.
+        """
+        with self._cond:
+            if slot_id in self._pending or slot_id in self._finishing:
+                raise ValueError(f"Slot {slot_id} already has a swap pending")
+            in_flight = len(self._leases.get(slot_id, {}))
+            swap = SlotSwap(staging_id=staging_id, replaces=replaces, slot_id=slot_id,
+                            drain_timeout=float(config.drain_timeout),
+                            requested_at=self._clock(), in_flight_at_request=in_flight)
+            self._swaps[staging_id] = swap
+            if not in_flight:
+                swap.status = SwapStatus.COMPLETED
+                swap.completed_at = swap.requested_at
+                return swap
+            self._pending[slot_id] = swap
+            if self._background and self._thread is None:
+                self._thread = threading.Thread(target=self._loop, daemon=True,
+                                                name='swap-controller')
+                self._thread.start()
+        return swap
+
+    def get(self, staging_id: str) -> Optional[SlotSwap]:
+        with self._cond:
+            return self._swaps.get(staging_id)
+
+    def pending(self) -> List[SlotSwap]:
+        with self._cond:
+            return list(self._pending.values())
+
+    def all(self) -> List[SlotSwap]:
+        with self._cond:
+            return sorted(self._swaps.values(), key=lambda s: s.requested_at)
+
+    def rename_ids(self, mapping: Dict[str, str], dry_run: bool = False) -> int:
+        """Rewrite staging IDs in the swaps (see snippet_migrate); returns the count."""
+        changed = 0
+        with self._cond:
+            for swap in list(self._swaps.values()):
+                for attr in ('staging_id', 'replaces'):
+                    sid = getattr(swap, attr)
+                    if sid in mapping:
+                        changed += 1
+                        if not dry_run:
+                            setattr(swap, attr, mapping[sid])
+            if not dry_run:
+                self._swaps = {s.staging_id: s for s in self._swaps.values()}
+        return changed
+
+    def tick(self):
+        """Force every pending swap whose drain_timeout has passed."""
+        now = self._clock()
+        due = []
+        with self._cond:
+            for swap in list(self._pending.values()):
+                if now < swap.deadline:
+                    continue
+                leases = list(self._leases.get(swap.slot_id, {}).values())
+                for lease in leases:
+                    lease.cancel_event.set()
+                swap.cancelled = len(leases)
+                swap.reason = (f"{len(leases)} execution(s) still running after "
+                               f"{swap.drain_timeout:g}s")
+                self._begin_finish(swap, SwapStatus.FORCED)
+                due.append(swap)
+        for swap in due:
+            self._finish(swap)
+
+    def close(self, timeout: Optional[float] = None):
+        self._stop.set()
+        if self._thread is not None:
+            self._thread.join(timeout)
+
+    # ── Internals ────────────────────────────────────────────────────
+
+    def _begin_finish(self, swap: SlotSwap, status: SwapStatus):
+        """Under the lock: stop waiting on the slot, keep new executions held."""
+        swap.status = status
+        swap.completed_at = self._clock()
+        self._pending.pop(swap.slot_id, None)
+        self._finishing[swap.slot_id] = swap
+
+    def _finish(self, swap: SlotSwap):
+        try:
+            if self._pipeline is not None:
+                self._pipeline.finish_swap(swap)
+        except Exception as exc:
+            swap.status = SwapStatus.ABORTED
+            swap.reason = str(exc)
+        finally:
+            with self._cond:
+                self._finishing.pop(swap.slot_id, None)
+                self._cond.notify_all()
+
+    def _loop(self):
+        while not self._stop.wait(self._poll_interval):
+            try:
+                self.tick()
+            except Exception:
+                pass                     # One bad pass must not stop the controller
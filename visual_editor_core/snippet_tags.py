@@ -0,0 +1,250 @@
+"""
+Snippet Tags — hierarchical categories for large snippet libraries.
+
+Labels name one thing; tags file it.  A tag is a path of segments:
+
+    pipeline.queue_snippet('i', 'go', code, 'gcd', tags=['math/number-theory', 'pure'])
+
+    pipeline.tag_query('math/*')                      # direct children of math
+    pipeline.tag_query('math/**')                     # math and everything under it
+    pipeline.tag_query('math/** AND pure')            # both
+    pipeline.tag_query('io/file OR io/net/*')         # either
+
+Segments are 1-63 of a-z, 0-9, '_', '.', '-' (lower-cased on the way in),
+at most MAX_TAG_DEPTH of them.  A pattern is a path whose segments may
+use glob syntax (`*`, `?`, `[...]`, matched within one segment) or be
+`**`, which matches any number of segments — zero included.  A query is
+patterns joined by AND / OR; AND binds tighter, so `a AND b OR c` is
+"(a and b) or c".  The same expression filters /api/staging/query
+(SnippetFilter.tags).
+
+Tags are fixed at queue time and immutable once a snippet has been
+promoted: retag() changes them before, and afterwards only for unscoped
+callers (in-process code and the namespace admin credential).
+
+TagIndex keeps, per tag and per path prefix, the set of snippets carrying
+it, so exact tags and `prefix/**` are dictionary lookups; other wildcards
+only scan the tags under their literal prefix, never the records.
+"""
+
+import fnmatch
+import re
+from dataclasses import dataclass
+from functools import lru_cache
+from typing import Dict, Iterable, List, Optional, Set, Tuple
+
+
+MAX_TAGS = 32
+MAX_TAG_DEPTH = 8
+SEPARATOR = '/'
+DEEP = '**'
+
+_SEGMENT = re.compile(r'^[a-z0-9][a-z0-9_.-]{0,62}$')
+_GLOB_CHARS = set('*?[')
+_OPERATOR = re.compile(r'\s+(AND|OR)\s+', re.IGNORECASE)
+
+
+class TagError(ValueError):
+    """A malformed tag, pattern or tag query."""
+
+
+class TagsImmutableError(TagError):
+    """Tags of a promoted snippet were changed by a scoped caller."""
+
+
+def validate_tag(tag: str) -> str:
+    """The normalised tag (lower-case, no empty segments); TagError if invalid."""
+    if not isinstance(tag, str):
+        raise TagError(f"Tag must be a string (got {type(tag).__name__})")
+    normalised = tag.strip().lower()
+    segments = normalised.split(SEPARATOR)
+    if len(segments) > MAX_TAG_DEPTH:
+        raise TagError(f"Tag '{tag}' is deeper than {MAX_TAG_DEPTH} segments")
+    for segment in segments:
+        if not _SEGMENT.match(segment):
+            raise TagError(f"Invalid tag '{tag}': segments are 1-63 of a-z, 0-9, '_', '.', '-' "
+                           f"starting with a letter or digit")
+    return normalised
+
+
+def validate_tags(tags: Optional[Iterable[str]]) -> List[str]:
+    """Normalised, de-duplicated and sorted; TagError for a bad or excess tag."""
+    if tags is None:
+        return []
+    if isinstance(tags, str):
+        raise TagError("tags must be a list of tag paths, not a string")
+    result = sorted({validate_tag(t) for t in tags})
+    if len(result) > MAX_TAGS:
+        raise TagError(f"At most {MAX_TAGS} tags per snippet (got {len(result)})")
+    return result
+
+
+def prefixes(tag: str) -> List[str]:
+    """'a/b/c' → ['', 'a', 'a/b', 'a/b/c']."""
+    segments = tag.split(SEPARATOR)
+    return [''] + [SEPARATOR.join(segments[:i]) for i in range(1, len(segments) + 1)]
+
+
+@dataclass(frozen=True)
+class TagPattern:
+    """One path pattern of a tag query."""
+    text: str
+    segments: Tuple[str, ...]
+
+    @classmethod
+    def parse(cls, text: str) -> 'TagPattern':
+        normalised = text.strip().lower()
+        segments = tuple(normalised.split(SEPARATOR))
+        if len(segments) > MAX_TAG_DEPTH + 1:
+            raise TagError(f"Tag pattern '{text}' is deeper than {MAX_TAG_DEPTH} segments")
+        for segment in segments:
+            if segment == DEEP:
+                continue
+            if not segment or DEEP in segment:
+                raise TagError(f"Invalid tag pattern '{text}': empty segment or '**' "
+                               f"inside a segment")
+            literal = re.sub(r'[*?]|\[[^\]]*\]', 'x', segment)
+            if not _SEGMENT.match(literal):
+                raise TagError(f"Invalid tag pattern '{text}'")
+        return cls(normalised, segments)
+
+    @property
+    def literal_prefix(self) -> str:
+        """The leading segments without wildcards ('' if the first one has any)."""
+        literal = []
+        for segment in self.segments:
+            if segment == DEEP or _GLOB_CHARS & set(segment):
+                break
+            literal.append(segment)
+        return SEPARATOR.join(literal)
+
+    @property
+    def exact(self) -> bool:
+        return self.literal_prefix == self.text
+
+    @property
+    def subtree(self) -> Optional[str]:
+        """For `prefix/**` (or a lone `**`): the prefix; None for other patterns."""
+        if self.segments[-1] != DEEP:
+            return None
+        head = self.segments[:-1]
+        if any(s == DEEP or _GLOB_CHARS & set(s) for s in head):
+            return None
+        return SEPARATOR.join(head)
+
+    def matches(self, tag: str) -> bool:
+        return _match(self.segments, tuple(tag.split(SEPARATOR)))
+
+
+def _match(pattern: Tuple[str, ...], segments: Tuple[str, ...]) -> bool:
+    if not pattern:
+        return not segments
+    head, rest = pattern[0], pattern[1:]
+    if head == DEEP:
+        return any(_match(rest, segments[i:]) for i in range(len(segments) + 1))
+    return bool(segments) and fnmatch.fnmatchcase(segments[0], head) \
+        and _match(rest, segments[1:])
+
+
+@dataclass(frozen=True)
+class TagQuery:
+    """Patterns in disjunctive normal form: any of the groups, all of each group."""
+    text: str
+    any_of: Tuple[Tuple[TagPattern, ...], ...]
+
+    def matches(self, tags: Iterable[str]) -> bool:
+        tags = list(tags)
+        return any(all(any(p.matches(t) for t in tags) for p in group) for group in self.any_of)
+
+    def patterns(self) -> List[TagPattern]:
+        return [p for group in self.any_of for p in group]
+
+
+@lru_cache(maxsize=256)
+def parse_tag_query(text: str) -> TagQuery:
+    """Parse `math/* AND pure OR io/**`; TagError if malformed."""
+    if not isinstance(text, str) or not text.strip():
+        raise TagError("Tag query is empty")
+    tokens = _OPERATOR.split(' ' + text.strip() + ' ')
+    # split() keeps the operators: [pattern, op, pattern, op, …]
+    operands, operators = tokens[0::2], [t.upper() for t in tokens[1::2]]
+    if any(not o.strip() for o in operands):
+        raise TagError(f"Tag query '{text}' has an operator without a pattern")
+    groups, group = [], [TagPattern.parse(operands[0])]
+    for op, operand in zip(operators, operands[1:]):
+        if op == 'OR':
+            groups.append(tuple(group))
+            group = []
+        group.append(TagPattern.parse(operand))
+    groups.append(tuple(group))
+    return TagQuery(text.strip(), tuple(groups))
+
+
+class TagIndex:
+    """
+    staging_id sets by tag and by tag prefix.  Not thread-safe: the
+    SnippetIndex that owns it holds its own lock around every call.
+    """
+
+    def __init__(self):
+        self._tags_of: Dict[str, Tuple[str, ...]] = {}
+        self._by_tag: Dict[str, Set[str]] = {}
+        self._under: Dict[str, Dict[str, Set[str]]] = {}     # prefix → tag → ids
+
+    def put(self, staging_id: str, tags: Iterable[str]):
+        tags = tuple(tags)
+        if self._tags_of.get(staging_id) == tags:
+            return
+        self.remove(staging_id)
+        if not tags:
+            return
+        self._tags_of[staging_id] = tags
+        for tag in tags:
+            self._by_tag.setdefault(tag, set()).add(staging_id)
+            for prefix in prefixes(tag):
+                self._under.setdefault(prefix, {}).setdefault(tag, set()).add(staging_id)
+
+    def remove(self, staging_id: str):
+        for tag in self._tags_of.pop(staging_id, ()):
+            _discard(self._by_tag, tag, staging_id)
+            for prefix in prefixes(tag):
+                under = self._under.get(prefix, {})
+                _discard(under, tag, staging_id)
+                if not under:
+                    self._under.pop(prefix, None)
+
+    def lookup(self, pattern: TagPattern) -> Set[str]:
+        if pattern.exact:
+            return set(self._by_tag.get(pattern.text, ()))
+        candidates = self._under.get(pattern.literal_prefix, {})
+        if pattern.subtree is not None:
+            tags = candidates
+        else:
+            tags = {t: ids for t, ids in candidates.items() if pattern.matches(t)}
+        return set().union(*tags.values()) if tags else set()
+
+    def query(self, tag_query: TagQuery) -> Set[str]:
+        matched: Set[str] = set()
+        for group in tag_query.any_of:
+            ids = self.lookup(group[0])
+            for pattern in group[1:]:
+                if not ids:
+                    break
+                ids &= self.lookup(pattern)
+            matched |= ids
+        return matched
+
+    def tags(self) -> Dict[str, int]:
+        """Tag → number of snippets carrying it."""
+        return {tag: len(ids) for tag, ids in sorted(self._by_tag.items())}
+
+    def __len__(self) -> int:
+        return len(self._by_tag)
+
+
+def _discard(index: Dict[str, Set[str]], key: str, staging_id: str):
+    ids = index.get(key)
+    if ids is not None:
+        ids.discard(staging_id)
+        if not ids:
+            del index[key]
@@ -0,0 +1,105 @@
+"""
+Snippet Tracing — OpenTelemetry spans for the staging pipeline.
+
+    StageSnippet ── hash_source
+    RunSpec ─────── execute ── compile        (compiled languages, e.g. Go)
+    PromoteSnippet ─ lint_gate
+    Rollback
+
+Top-level spans carry `staging_id` and `slot` (engine letter) plus
+`slot.address`, `phase` and `spec_result` once the operation finishes;
+exceptions are recorded on the span that raised them.
+
+Only the opentelemetry API is used: spans go to whatever tracer provider
+is installed globally (`trace.set_tracer_provider(...)`), so the exporter
+— Jaeger, OTLP, console — is chosen by the application at startup and
+nothing here depends on one.  Without opentelemetry-api installed every
+span is a no-op.
+"""
+
+import functools
+from contextlib import contextmanager
+from typing import Any, Optional
+
+try:
+    from opentelemetry import trace
+except ImportError:  # opentelemetry-api not installed — spans are no-ops
+    trace = None
+
+
+TRACER_NAME = 'spokedpy.staging'
+
+
+class _NoopSpan:
+    """Stand-in span when opentelemetry is unavailable."""
+
+    def set_attribute(self, key: str, value: Any):
+        pass
+
+    def add_event(self, name: str, attributes: Optional[dict] = None):
+        pass
+
+    def record_exception(self, exc: BaseException):
+        pass
+
+    def is_recording(self) -> bool:
+        return False
+
+
+_NOOP_SPAN = _NoopSpan()
+
+
+def _clean(attributes: dict) -> dict:
+    # OTel attribute values must be str / bool / int / float (or sequences of them)
+    return {k: v for k, v in attributes.items()
+            if v is not None and v != '' and isinstance(v, (str, bool, int, float))}
+
+
+@contextmanager
+def span(name: str, **attributes):
+    """Start a span as a child of the current one (no-op without opentelemetry)."""
+    if trace is None:
+        yield _NOOP_SPAN
+        return
+    tracer = trace.get_tracer(TRACER_NAME)
+    with tracer.start_as_current_span(name, attributes=_clean(attributes)) as current:
+        yield current
+
+
+def annotate(current, snippet):
+    """Copy a StagedSnippet's identifying fields onto `current`."""
+    if snippet is None or not hasattr(snippet, 'staging_id'):
+        return
+    for key, value in _clean({
+        'staging_id': snippet.staging_id,
+        'slot': snippet.engine_letter,
+        'slot.address': snippet.reserved_address,
+        'language': snippet.language,
+        'phase': getattr(snippet.phase, 'value', None),
+        'spec_result': getattr(snippet.spec_result, 'value', None),
+    }).items():
+        current.set_attribute(key, value)
+
+
+def traced(name: str, staging_id_arg: Optional[int] = None):
+    """
+    Run a StagingPipeline method inside a top-level span.
+
+    `staging_id_arg` is the positional index of the staging_id argument,
+    recorded before the call so it is present even if the call raises.
+    The returned snippet (if any) is annotated onto the span.
+    """
+    def decorator(fn):
+        @functools.wraps(fn)
+        def wrapper(self, *args, **kwargs):
+            with span(name) as current:
+                if staging_id_arg is not None:
+                    staging_id = (args[staging_id_arg] if len(args) > staging_id_arg
+                                  else kwargs.get('staging_id'))
+                    if staging_id:
+                        current.set_attribute('staging_id', staging_id)
+                result = fn(self, *args, **kwargs)
+                annotate(current, result)
+                return result
+        return wrapper
+    return decorator
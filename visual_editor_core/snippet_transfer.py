@@ -0,0 +1,188 @@
+"""
+Snippet Transfer — move snippet collections between environments.
+
+    with open('pricing.jsonl', 'w') as f:
+        dev.export_snippets(SnippetFilter(label='pricing'), f)
+
+    with open('pricing.jsonl') as f:
+        report = prod.import_snippets(f, ImportOptions(conflict_policy='overwrite'))
+    report.counts()                       # {'promoted': 3, 'staged': 1, 'skipped': 0, ...}
+
+The format is JSON Lines, one record per snippet, oldest first:
+
+    {"format": "spokedpy-snippet/1",
+     "source": "<base64 of the snippet's own code>",
+     "source_hash": "<sha256 of those bytes>",
+     "snippet": {... every StagedSnippet field but code / merged_code ...},
+     "promotion_history": [{"event": "promote", ...}, ...]}
+
+Env values are secrets and never leave the pipeline: `snippet.env` lists
+the names only, and an import takes the values from ImportOptions.env.
+
+Importing checks each record's format and source_hash before anything
+is staged, then queues it with the exported label, parameters, requires,
+output_schema, tags, resource_limits, test cases, deterministic flag and
+suppress_credential_check and speculates it.  A record whose run passes then goes
+through the language's lint gate (ImportOptions.skip_lint bypasses it):
+records that were live (PROMOTED) when exported are promoted again, the
+rest stay PASSED / FAILED for the target to decide on.  A label already live on
+the target slot is handled by ImportOptions.conflict_policy:
+
+    skip            leave the target's version, report the record skipped
+    reject          report the record failed
+    overwrite       stage under LabelConflictPolicy.OVERWRITE
+    version_suffix  stage under LabelConflictPolicy.VERSION_SUFFIX
+
+Records are imported in file order, so a label's dependencies (exported
+before it) are live by the time it is staged.  The promotion history is
+carried for the audit trail; the target's own history starts afresh.
+"""
+
+import base64
+import binascii
+import hashlib
+import json
+from enum import Enum
+from dataclasses import dataclass, field
+from typing import Any, Dict, List, Optional
+
+
+FORMAT_VERSION = 'spokedpy-snippet/1'
+
+# StagedSnippet fields the source travels in instead (or that are derived from it)
+_SOURCE_FIELDS = ('code', 'merged_code')
+
+
+class ConflictPolicy(str, Enum):
+    """What an import does with a label that is already live on the target slot."""
+    SKIP           = 'skip'
+    REJECT         = 'reject'
+    OVERWRITE      = 'overwrite'
+    VERSION_SUFFIX = 'version_suffix'
+
+
+class ImportStatus(str, Enum):
+    PROMOTED         = 'promoted'        # Live again on the target
+    STAGED           = 'staged'          # Queued and speculated, not promoted
+    PENDING_APPROVAL = 'pending_approval'   # Promotion waits on a protected slot
+    SKIPPED          = 'skipped'         # Label live on the target (SKIP policy)
+    FAILED           = 'failed'          # Bad record, lint finding or pipeline error
+
+
+class SnippetImportError(ValueError):
+    """An import stopped at a failed record (ImportOptions.stop_on_error)."""
+
+    def __init__(self, message: str, report: 'ImportReport'):
+        super().__init__(message)
+        self.report = report
+
+
+@dataclass
+class ImportOptions:
+    conflict_policy: ConflictPolicy = ConflictPolicy.SKIP
+    promote: bool = True                 # Re-promote records that were live when exported
+    skip_lint: bool = False              # Bypass the lint gate (audited, as for promote())
+    env: Dict[str, str] = field(default_factory=dict)   # Values for exported env names
+    stop_on_error: bool = False          # Raise SnippetImportError at the first failure
+
+    def __post_init__(self):
+        self.conflict_policy = ConflictPolicy(self.conflict_policy)
+
+
+@dataclass
+class ImportRecordResult:
+    """What happened to one line of the import."""
+    line: int                            # 1-based line number
+    status: ImportStatus
+    source_staging_id: str = ''          # staging_id in the exporting environment
+    label: str = ''
+    staging_id: str = ''                 # staging_id in the target ('' if not staged)
+    spec_result: str = ''
+    error: str = ''
+
+    def to_dict(self) -> Dict:
+        return {
+            'line': self.line,
+            'status': self.status.value,
+            'source_staging_id': self.source_staging_id,
+            'label': self.label,
+            'staging_id': self.staging_id,
+            'spec_result': self.spec_result,
+            'error': self.error,
+        }
+
+
+@dataclass
+class ImportReport:
+    records: List[ImportRecordResult] = field(default_factory=list)
+
+    def counts(self) -> Dict[str, int]:
+        counts = {s.value: 0 for s in ImportStatus}
+        for r in self.records:
+            counts[r.status.value] += 1
+        return counts
+
+    @property
+    def failed(self) -> List[ImportRecordResult]:
+        return [r for r in self.records if r.status == ImportStatus.FAILED]
+
+    def to_dict(self) -> Dict:
+        return {
+            'records': [r.to_dict() for r in self.records],
+            'counts': self.counts(),
+        }
+
+
+@dataclass
+class ExportRecord:
+    """One snippet as it travels: its source, its metadata and its promotions."""
+    source: bytes
+    snippet: Dict[str, Any]
+    promotion_history: List[Dict[str, Any]] = field(default_factory=list)
+
+    def encode(self) -> str:
+        return json.dumps({
+            'format': FORMAT_VERSION,
+            'source': base64.b64encode(self.source).decode('ascii'),
+            'source_hash': hashlib.sha256(self.source).hexdigest(),
+            'snippet': self.snippet,
+            'promotion_history': self.promotion_history,
+        }, sort_keys=True)
+
+    @classmethod
+    def from_snippet(cls, snippet, promotion_history: List[Dict[str, Any]]) -> 'ExportRecord':
+        metadata = snippet.to_dict()
+        for name in _SOURCE_FIELDS:
+            metadata.pop(name, None)
+        return cls(source=snippet.code.encode('utf-8'), snippet=metadata,
+                   promotion_history=promotion_history)
+
+    @classmethod
+    def decode(cls, line: str) -> 'ExportRecord':
+        """Parse and check one line; ValueError says what is wrong with it."""
+        try:
+            raw = json.loads(line)
+        except ValueError as exc:
+            raise ValueError(f"Not valid JSON: {exc}") from None
+        if not isinstance(raw, dict):
+            raise ValueError("Record is not a JSON object")
+        if raw.get('format') != FORMAT_VERSION:
+            raise ValueError(f"Unsupported record format {raw.get('format')!r} "
+                             f"(expected {FORMAT_VERSION!r})")
+        snippet = raw.get('snippet')
+        if not isinstance(snippet, dict) or not snippet.get('language'):
+            raise ValueError("Record has no snippet metadata")
+        try:
+            source = base64.b64decode(raw.get('source') or '', validate=True)
+        except (binascii.Error, TypeError):
+            raise ValueError("source is not valid base64") from None
+        if hashlib.sha256(source).hexdigest() != raw.get('source_hash'):
+            raise ValueError("source does not match its source_hash")
+        history = raw.get('promotion_history') or []
+        if not isinstance(history, list):
+            raise ValueError("promotion_history must be a list")
+        return cls(source=source, snippet=snippet, promotion_history=history)
+
+    @property
+    def code(self) -> str:
+        return self.source.decode('utf-8')
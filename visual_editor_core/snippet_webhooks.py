@@ -0,0 +1,361 @@
+"""
+Snippet Webhooks — notify external systems when snippets are promoted.
+
+Targets register a URL, a shared secret and the audit events they care
+about (default: promotion_completed).  Each matching event becomes a
+*delivery*: a signed JSON payload POSTed by a background dispatcher.
+
+    pipeline.promote() ──► publish(event, payload)
+                                 │  one delivery per matching target
+                                 ▼
+                     webhook_deliveries.jsonl   (append-only)
+                                 │
+                      dispatcher thread ──► POST url
+                                 │          X-SpokedPy-Signature: sha256=<hmac>
+                  2xx ──► delivered          X-SpokedPy-Event:     promotion_completed
+                  else ─► retry after base·2^(n-1) (± jitter), up to max_retries
+                          then dead-lettered
+
+Payload:
+    { "event", "staging_id", "label", "promoted_at", "spec_result", "slot" }
+
+The signature is HMAC-SHA256 of the raw request body keyed by the
+target's secret.  Receivers should recompute it and compare with
+hmac.compare_digest().
+
+Every delivery state change is appended to the log, so events that were
+not delivered before a restart are replayed when the dispatcher is next
+constructed.  The log stores the signed body, never the secret.
+Targets themselves are in-memory and must be re-registered on startup.
+"""
+
+import os
+import json
+import time
+import hmac
+import uuid
+import random
+import hashlib
+import threading
+import urllib.request
+import urllib.error
+from dataclasses import dataclass, asdict
+from typing import Callable, Dict, Iterable, List, Optional
+
+
+DEFAULT_EVENTS = ('promotion_completed',)
+DEFAULT_MAX_RETRIES = 5
+
+SIGNATURE_HEADER = 'X-SpokedPy-Signature'
+EVENT_HEADER = 'X-SpokedPy-Event'
+DELIVERY_HEADER = 'X-SpokedPy-Delivery'
+
+# transport(url, body, headers, timeout) -> HTTP status code
+Transport = Callable[[str, bytes, Dict[str, str], float], int]
+
+
+def sign_payload(secret: str, body: bytes) -> str:
+    """Value of the signature header for `body`."""
+    digest = hmac.new(secret.encode('utf-8'), body, hashlib.sha256).hexdigest()
+    return f"sha256={digest}"
+
+
+def _rename_value(value, mapping: Dict[str, str]):
+    if isinstance(value, str):
+        return mapping.get(value, value)
+    if isinstance(value, list):
+        return [mapping.get(v, v) if isinstance(v, str) else v for v in value]
+    return value
+
+
+def _urllib_transport(url: str, body: bytes, headers: Dict[str, str],
+                      timeout: float) -> int:
+    req = urllib.request.Request(url, data=body, headers=headers, method='POST')
+    try:
+        with urllib.request.urlopen(req, timeout=timeout) as resp:
+            return resp.status
+    except urllib.error.HTTPError as exc:
+        return exc.code
+
+
+@dataclass
+class WebhookTarget:
+    """A registered receiver."""
+    target_id: str
+    url: str
+    secret: str
+    events: List[str]
+    created_at: float = 0.0
+
+    def to_dict(self) -> Dict:
+        d = asdict(self)
+        d.pop('secret')
+        return d
+
+
+@dataclass
+class WebhookDelivery:
+    """One payload queued for one target."""
+    delivery_id: str
+    target_id: str
+    url: str
+    event: str
+    body: str                                # Exact bytes that were signed (utf-8)
+    signature: str
+    attempts: int = 0
+    next_attempt_at: float = 0.0
+    last_error: str = ''
+    created_at: float = 0.0
+    status: str = 'pending'                  # 'pending' | 'delivered' | 'dead'
+
+    def to_dict(self) -> Dict:
+        return asdict(self)
+
+
+class WebhookDispatcher:
+    """Background delivery of signed webhook payloads with retry.
+
+    Thread-safe.  Call start() to run the dispatcher thread, or drive it
+    directly with dispatch_due() (tests, one-shot flushes).
+    """
+
+    POLL_INTERVAL = 0.5   # seconds the thread sleeps when nothing is due
+
+    def __init__(self, log_path: str, max_retries: int = DEFAULT_MAX_RETRIES,
+                 base_delay: float = 1.0, max_delay: float = 300.0,
+                 timeout: float = 5.0, transport: Optional[Transport] = None):
+        if max_retries < 0:
+            raise ValueError(f"max_retries must be >= 0 (got {max_retries})")
+        self._path = log_path
+        self._max_retries = max_retries
+        self._base_delay = base_delay
+        self._max_delay = max_delay
+        self._timeout = timeout
+        self._transport = transport or _urllib_transport
+        self._lock = threading.Lock()
+        self._wake = threading.Event()
+        self._stop = threading.Event()
+        self._thread: Optional[threading.Thread] = None
+
+        self._targets: Dict[str, WebhookTarget] = {}
+        self._pending: Dict[str, WebhookDelivery] = {}
+        self._in_flight: set = set()
+        self._dead: List[WebhookDelivery] = []
+
+        os.makedirs(os.path.dirname(log_path) or '.', exist_ok=True)
+        self._replay()
+
+    # ── Targets ──────────────────────────────────────────────────────
+
+    def add_target(self, url: str, secret: str,
+                   events: Optional[Iterable] = None) -> WebhookTarget:
+        """Register a receiver for `events` (audit event types or their values)."""
+        if not url.startswith(('http://', 'https://')):
+            raise ValueError(f"Webhook URL must be http(s): '{url}'")
+        if not secret:
+            raise ValueError("Webhook secret is required")
+        names = [getattr(e, 'value', e) for e in (events or DEFAULT_EVENTS)]
+        target = WebhookTarget(
+            target_id=f"whk-{uuid.uuid4().hex[:12]}",
+            url=url, secret=secret, events=names, created_at=time.time(),
+        )
+        with self._lock:
+            self._targets[target.target_id] = target
+        return target
+
+    def remove_target(self, target_id: str) -> bool:
+        with self._lock:
+            return self._targets.pop(target_id, None) is not None
+
+    def targets(self) -> List[WebhookTarget]:
+        with self._lock:
+            return list(self._targets.values())
+
+    # ── Publishing ───────────────────────────────────────────────────
+
+    def publish(self, event, payload: Dict) -> List[WebhookDelivery]:
+        """Queue `payload` for every target subscribed to `event`."""
+        name = getattr(event, 'value', event)
+        body = json.dumps({'event': name, **payload}, sort_keys=True, default=str)
+        now = time.time()
+        with self._lock:
+            targets = [t for t in self._targets.values() if name in t.events]
+            deliveries = []
+            for target in targets:
+                delivery = WebhookDelivery(
+                    delivery_id=f"dlv-{uuid.uuid4().hex[:12]}",
+                    target_id=target.target_id,
+                    url=target.url,
+                    event=name,
+                    body=body,
+                    signature=sign_payload(target.secret, body.encode('utf-8')),
+                    next_attempt_at=now,
+                    created_at=now,
+                )
+                self._pending[delivery.delivery_id] = delivery
+                self._append({'op': 'enqueue', 'delivery': delivery.to_dict()})
+                deliveries.append(delivery)
+        if deliveries:
+            self._wake.set()
+        return deliveries
+
+    # ── Delivery ─────────────────────────────────────────────────────
+
+    def backoff(self, attempt: int) -> float:
+        """Delay before retry number `attempt` (1-based): exponential, half jittered."""
+        delay = min(self._max_delay, self._base_delay * (2 ** (attempt - 1)))
+        return delay / 2 + random.uniform(0, delay / 2)
+
+    def dispatch_due(self, now: Optional[float] = None) -> int:
+        """Attempt every delivery whose next attempt is due.  Returns attempts made."""
+        now = time.time() if now is None else now
+        with self._lock:
+            due = [d for d in self._pending.values()
+                   if d.next_attempt_at <= now and d.delivery_id not in self._in_flight]
+            self._in_flight.update(d.delivery_id for d in due)
+        for delivery in due:
+            try:
+                self._attempt(delivery)
+            finally:
+                with self._lock:
+                    self._in_flight.discard(delivery.delivery_id)
+        return len(due)
+
+    def _attempt(self, delivery: WebhookDelivery):
+        headers = {
+            'Content-Type': 'application/json',
+            SIGNATURE_HEADER: delivery.signature,
+            EVENT_HEADER: delivery.event,
+            DELIVERY_HEADER: delivery.delivery_id,
+        }
+        try:
+            status = self._transport(delivery.url, delivery.body.encode('utf-8'),
+                                     headers, self._timeout)
+            error = '' if 200 <= status < 300 else f'HTTP {status}'
+        except Exception as exc:
+            error = str(exc) or type(exc).__name__
+
+        with self._lock:
+            delivery.attempts += 1
+            delivery.last_error = error
+            if not error:
+                delivery.status = 'delivered'
+                self._pending.pop(delivery.delivery_id, None)
+                self._append({'op': 'delivered', 'delivery_id': delivery.delivery_id,
+                              'attempts': delivery.attempts})
+            elif delivery.attempts > self._max_retries:
+                delivery.status = 'dead'
+                self._pending.pop(delivery.delivery_id, None)
+                self._dead.append(delivery)
+                self._append({'op': 'dead', 'delivery_id': delivery.delivery_id,
+                              'attempts': delivery.attempts, 'error': error})
+            else:
+                delivery.next_attempt_at = time.time() + self.backoff(delivery.attempts)
+                self._append({'op': 'retry', 'delivery_id': delivery.delivery_id,
+                              'attempts': delivery.attempts, 'error': error,
+                              'next_attempt_at': delivery.next_attempt_at})
+
+    def pending(self) -> List[WebhookDelivery]:
+        with self._lock:
+            return sorted(self._pending.values(), key=lambda d: d.created_at)
+
+    def dead_letters(self) -> List[WebhookDelivery]:
+        with self._lock:
+            return list(self._dead)
+
+    def rename_ids(self, mapping: Dict[str, str], dry_run: bool = False) -> int:
+        """
+        Rewrite staging IDs in undelivered and dead-lettered payloads (see
+        snippet_migrate) and re-sign them; returns the deliveries changed.
+        Deliveries whose target has been removed can't be re-signed and
+        are left as they are.
+        """
+        changed = 0
+        with self._lock:
+            for delivery in list(self._pending.values()) + self._dead:
+                target = self._targets.get(delivery.target_id)
+                payload = json.loads(delivery.body)
+                renamed = {key: _rename_value(value, mapping) for key, value in payload.items()}
+                if target is None or renamed == payload:
+                    continue
+                changed += 1
+                if dry_run:
+                    continue
+                delivery.body = json.dumps(renamed, sort_keys=True, default=str)
+                delivery.signature = sign_payload(target.secret, delivery.body.encode('utf-8'))
+                self._append({'op': 'rewrite', 'delivery_id': delivery.delivery_id,
+                              'body': delivery.body, 'signature': delivery.signature})
+        return changed
+
+    # ── Background thread ────────────────────────────────────────────
+
+    def start(self):
+        """Start the dispatcher thread (no-op if already running)."""
+        with self._lock:
+            if self._thread and self._thread.is_alive():
+                return
+            self._stop.clear()
+            self._thread = threading.Thread(target=self._loop, daemon=True,
+                                            name='webhook-dispatcher')
+            self._thread.start()
+
+    def stop(self, timeout: float = 5.0):
+        self._stop.set()
+        self._wake.set()
+        if self._thread and self._thread.is_alive():
+            self._thread.join(timeout=timeout)
+
+    def _loop(self):
+        while not self._stop.is_set():
+            self.dispatch_due()
+            with self._lock:
+                upcoming = min((d.next_attempt_at for d in self._pending.values()),
+                               default=None)
+            wait = self.POLL_INTERVAL if upcoming is None else max(0.0, upcoming - time.time())
+            self._wake.wait(timeout=min(wait, self.POLL_INTERVAL))
+            self._wake.clear()
+
+    # ── Persistence ──────────────────────────────────────────────────
+
+    def _append(self, record: Dict):
+        record['timestamp'] = time.time()
+        with open(self._path, 'a', encoding='utf-8') as f:
+            f.write(json.dumps(record, default=str) + '\n')
+
+    def _replay(self):
+        """Rebuild the undelivered set from the append-only log."""
+        if not os.path.exists(self._path):
+            return
+        with open(self._path, 'r', encoding='utf-8') as f:
+            for line in f:
+                line = line.strip()
+                if not line:
+                    continue
+                try:
+                    record = json.loads(line)
+                except json.JSONDecodeError:
+                    continue   # torn final line from a crash mid-write
+                op = record.get('op')
+                if op == 'enqueue':
+                    delivery = WebhookDelivery(**record['delivery'])
+                    self._pending[delivery.delivery_id] = delivery
+                    continue
+                if op == 'rewrite':
+                    delivery = self._pending.get(record.get('delivery_id', '')) or next(
+                        (d for d in self._dead if d.delivery_id == record.get('delivery_id')),
+                        None)
+                    if delivery is not None:
+                        delivery.body, delivery.signature = record['body'], record['signature']
+                    continue
+                delivery = self._pending.get(record.get('delivery_id', ''))
+                if delivery is None:
+                    continue
+                delivery.attempts = record.get('attempts', delivery.attempts)
+                delivery.last_error = record.get('error', delivery.last_error)
+                if op == 'retry':
+                    delivery.next_attempt_at = record.get('next_attempt_at', 0.0)
+                elif op in ('delivered', 'dead'):
+                    delivery.status = op
+                    del self._pending[delivery.delivery_id]
+                    if op == 'dead':
+                        self._dead.append(delivery)
@@ -3650,11 +3650,29 @@ def _shutdown_checkpoint():
 
 _atexit.register(_shutdown_checkpoint)
 
+
+def _shutdown_spec_queue():
+    """Let the async speculation workers finish pending jobs."""
+    try:
+        from web_interface.runtime import spec_queue
+        if spec_queue is not None and not spec_queue.close(timeout=10):
+            print("  [QUEUE] Speculation workers still busy at exit.")
+    except Exception as exc:
+        print(f"  [QUEUE] Shutdown drain FAILED: {exc}")
+
+# atexit is LIFO — drain the queue before the checkpoint is written
+_atexit.register(_shutdown_spec_queue)
+
 # ==================== SETTINGS HUB ====================
 # Centralized settings, logging, test runner, and change-history.
 from web_interface.settings_hub import register_settings_hub
 register_settings_hub(app)
 
+# ==================== SNIPPET REST API (v1) ====================
+# Resource-style /api/v1/snippets endpoints + their OpenAPI YAML.
+from web_interface.snippet_api import register_snippet_api
+register_snippet_api(app)
+
 # ==================== SWAGGER / OPENAPI ====================
 # Must be registered AFTER all other blueprints so the spec generator
 # can enumerate every route.  New endpoints are documented automatically.
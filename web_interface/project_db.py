@@ -207,6 +207,53 @@ def delete_project(project_id: str) -> bool:
 #   snippets_dir   – directory for promoted snippet files
 #   audit_log      – path to the staging audit JSONL file
 #   marshal_ttl    – default marshal-token TTL in seconds
+#   spec_concurrency – max concurrent speculative executions per batch
+#   execution_timeout – hard per-snippet execution deadline in seconds
+#   snippet_max_cpu_time – CPU seconds a Go snippet process may use (0 = unlimited)
+#   snippet_max_memory_bytes – address space a Go snippet process may map (0 = unlimited)
+#   snippet_max_output_bytes – stdout / stderr bytes kept per run; more kills it (0 = unlimited)
+#   label_policy   – default label conflict policy (reject / overwrite / version_suffix)
+#   webhook_log    – path to the append-only webhook delivery log
+#   snippet_index_db – SQLite file of snippet metadata records behind /api/staging/query
+#   webhook_max_retries – delivery attempts after the first before dead-lettering
+#   lint_gate      – 1 to lint Go snippets (printf / shadow / unusedresult) before promotion
+#   lint_allow_unavailable – 1 to let the lint gate pass when an analyzer cannot run
+#   coverage_gate – 1 to run Go snippets' Test functions under go test -cover after speculation
+#   coverage_min_percent – statement coverage (0-100) a Go snippet with tests needs when coverage_gate is on
+#   benchmark_on_promote – 1 to run Go snippets' Benchmark functions under go test -bench after speculation
+#   benchmark_regression_gate – percent a Go benchmark may slow down against the live version before promotion is blocked (0 = off)
+#   result_cache_ttl – seconds a cached speculation of a deterministic snippet is served (0 = no result cache)
+#   result_cache_max_entries – results the cache holds before evicting the least recently used
+#   mutation_test – 1 to run Go snippets' Test functions against mutants of the snippet after speculation
+#   mutation_max_mutations – most mutants one Go snippet's tests are run against when mutation_test is on
+#   mutation_threshold – fraction (0-1) of compiling mutants that may survive a Go snippet's tests before the run fails as WEAK_SPEC
+#   go_format_on_stage – 1 to gofmt Go snippets before they are hashed and staged
+#   circuit_failure_threshold – consecutive failed runs that open a snippet's circuit (0 = off)
+#   circuit_window – seconds the failures must fall within to open the circuit
+#   circuit_recovery_delay – seconds before an open circuit allows one trial run
+#   spec_workers   – background workers draining the async speculation queue
+#   spec_max_queue_depth – pending jobs allowed before /api/staging/enqueue pushes back
+#   spec_queue_block – 1 to block when the queue is full instead of returning 429
+#   grpc_address   – gRPC SnippetService listen address (empty = disabled)
+#   grpc_tokens    – comma-separated bearer tokens for the gRPC service
+#   grpc_tls_cert / grpc_tls_key – PEM paths enabling TLS on the gRPC port
+#   namespace_admin_credential – X-SpokedPy-Admin-Credential value granting cross-namespace access
+#   namespace_credentials – namespace=credential pairs checked against X-SpokedPy-Namespace-Credential
+#   staging_id_prefix – what new staging IDs start with; POST /api/staging/migrate-prefix renames existing ones
+#   archive_interval – seconds between Archivist sweeps of old promotions (0 = disabled)
+#   archive_max_age – archive promotions older than this many seconds (0 = no limit)
+#   archive_max_versions – promotions kept per label; older ones are archived (0 = no limit)
+#   archive_max_slot_bytes – source bytes a slot keeps across its promotions (0 = no limit)
+#   archive_action – move (to archive_dir, still queryable) or delete
+#   archive_dir – cold-storage directory archived snippet files move to
+#   rerun_interval – seconds between RerunScheduler ticks for slots with a schedule (0 = disabled)
+#   manifest_dir – directory `source_file` paths in POST /api/staging/manifest resolve under (empty = inline sources only)
+#   age_identity_file – age-keygen identity file whose secret keys open the sealed payloads of encrypt_at_rest slots (empty = none)
+#   rerun_history_size – scheduled reruns kept per promoted snippet (oldest dropped first)
+#   lock_backend – where slot promotion locks live: local (one instance), redis or etcd (shared by every instance)
+#   lock_url – redis://… or etcd://host:port of the lock_backend (unused for local)
+#   promotion_lock_ttl – seconds a slot promotion lock lasts if its holder never releases it (must outlast a promotion)
+#   promotion_lock_wait – seconds a promotion waits for another promotion on the same slot before giving up
 #
 # The resolution order everywhere is:
 #   1. Database setting  (set via web UI / API)
@@ -11,7 +11,10 @@ Contains:
 
 Call  init_runtime(app, session_ledger, socketio)  from app.py to wire everything up.
 """
-from flask import Blueprint, request, jsonify
+from flask import Blueprint, request, jsonify, g
+import base64
+import collections
+import io
 import json
 import os
 import uuid
@@ -30,6 +33,7 @@ from visual_editor_core.node_registry import (
 )
 from visual_editor_core.execution_engine import (
     PythonExecutor as _PythonExecutor,
+    PythonProcessExecutor as _PythonProcessExecutor,
     JavaScriptExecutor as _JavaScriptExecutor,
     RustExecutor as _RustExecutor,
     BashExecutor as _BashExecutor,
@@ -44,6 +48,7 @@ from visual_editor_core.execution_engine import (
     SwiftExecutor as _SwiftExecutor,
     TypeScriptExecutor as _TypeScriptExecutor,
     PerlExecutor as _PerlExecutor,
+    ExecutionResult,
     get_executor_for_language,
     get_supported_languages,
     EXECUTOR_ALIASES,
@@ -55,10 +60,53 @@ from visual_editor_core.snippet_staging import (
     StagingPipeline,
     StagingPhase,
     StagedSnippet,
+    BatchPromotionError,
+    LabelConflictPolicy,
+)
+from visual_editor_core.snippet_capacity import SlotConfig
+from visual_editor_core.snippet_encryption import read_identities
+from visual_editor_core.snippet_lock import LockUnavailableError, locker_from_settings
+from visual_editor_core.snippet_deps import CyclicDependencyError
+from visual_editor_core.snippet_params import ParameterSchemaError
+from visual_editor_core.snippet_isolation import IsolationLevel, warn_not_isolatable
+from visual_editor_core.snippet_webhooks import WebhookDispatcher
+from visual_editor_core.snippet_lint import GoVetLinter, LintFailedError
+from visual_editor_core.snippet_bench import BenchmarkConfig, BenchmarkRegressionError
+from visual_editor_core.snippet_metrics import register_metrics
+from visual_editor_core.snippet_query import SnippetFilter
+from visual_editor_core.snippet_transfer import ImportOptions, SnippetImportError
+from visual_editor_core.snippet_manifest import ManifestError
+from visual_editor_core.snippet_cache import ResultCache
+from visual_editor_core.snippet_sqlite import SQLiteSnippetIndex
+from visual_editor_core.snippet_breaker import CircuitBreaker, BreakerConfig, CircuitOpenError
+from visual_editor_core.snippet_canary import CanaryConfig
+from visual_editor_core.snippet_abtest import DEFAULT_MIN_SAMPLES
+from visual_editor_core.snippet_archive import ArchivalPolicy, Archivist
+from visual_editor_core.snippet_schedule import RerunScheduler
+from visual_editor_core.snippet_replay import ReplayError, ReplayOptions, compare_snapshots
+from visual_editor_core.snippet_revalidate import RevalidateOptions
+from visual_editor_core.snippet_engines import DEFAULT_ENGINES, EngineExecutor
+from visual_editor_core.snippet_limits import ResourceLimits
+from visual_editor_core.snippet_coverage import CoverageGate
+from visual_editor_core.snippet_mutation import MutationConfig
+from visual_editor_core.snippet_approvals import PendingApprovalError
+from visual_editor_core.snippet_swap import GracefulSwap, SwapStatus
+from visual_editor_core.snippet_tags import TagsImmutableError
+from visual_editor_core.snippet_credentials import CredentialDetectedError
+from visual_editor_core.snippet_format import (
+    FormatFailedError, FormatUnavailableError, dry_run_format,
+)
+from visual_editor_core.snippet_namespace import (
+    DEFAULT_NAMESPACE, NamespaceAdmin, NamespaceAdminError, validate_namespace,
+    parse_namespace_credentials,
+)
+from visual_editor_core.snippet_migrate import MigrateOptions, MigrationConflictError
+from visual_editor_core.snippet_queue import (
+    SpeculationQueue, Priority, QueueFullError, QueueClosedError,
 )
 from web_interface.project_db import resolve_setting
 from web_interface.state_persistence import (
-    StatePersistence, build_promoted_snapshots,
+    StatePersistence, build_promoted_snapshots, restore_env,
 )
 from visual_editor_core.mesh_relay import (
     MeshRelay, MeshRole, MeshTopology, PeerInfo,
@@ -84,6 +132,11 @@ multi_debugger = None    # MultiDebuggerManager
 staging_pipeline = None  # StagingPipeline — speculative execution & promotion
 active_debug_sessions = {}
 mesh_relay = None        # MeshRelay — distributed instance interconnect
+grpc_server = None       # grpc.Server — SnippetService (None unless grpc_address is set)
+spec_queue = None        # SpeculationQueue — async prioritised speculation workers
+archivist = None         # Archivist — expires old promotions (None unless archive_interval > 0)
+rerun_scheduler = None   # RerunScheduler — re-runs live snippets on slot schedules (None if rerun_interval = 0)
+manifest_dir = None      # Directory manifest source_file paths resolve under (None = inline sources only)
 
 # ---------------------------------------------------------------------------
 # State Persistence — crash-resilient checkpoint/restore
@@ -169,12 +222,16 @@ def _purge_expired_tokens():
 def _get_executor(language: str):
     """Get the live executor for a language.  Returns the shared Python
     executor for 'python'; creates/caches subprocess executors for others.
+    Languages provided only by a registered Engine plugin get an adapter.
     """
     lang = language.lower().strip()
     lang = EXECUTOR_ALIASES.get(lang, lang)
 
     if lang in _executors:
         return _executors[lang]
+    engine = DEFAULT_ENGINES.get(lang)
+    if engine is not None:
+        return EngineExecutor(engine)
     return None
 
 
@@ -233,6 +290,15 @@ def _restore_state():
     # ── 1. Restore promoted snippets ────────────────────────────────
     for snap in promoted:
         code = snap.get('code', '')
+        if snap.get('sealed_code'):
+            try:
+                code = staging_pipeline.open_sealed(
+                    base64.b64decode(snap['sealed_code']), snap.get('engine_letter', ''),
+                    snap.get('code_hash', '')).decode('utf-8')
+            except Exception as exc:
+                print(f"  [STATE]   Cannot open sealed snippet {snap.get('staging_id', '')}: {exc}")
+                failed_count += 1
+                continue
         language = snap.get('language', '')
         engine_letter = snap.get('engine_letter', '')
         label = snap.get('label', '')
@@ -243,10 +309,25 @@ def _restore_state():
             failed_count += 1
             continue
 
+        try:
+            env = restore_env(snap)
+        except ValueError as exc:
+            print(f"  [STATE]   Cannot restore env of snippet {staging_id}: {exc}")
+            failed_count += 1
+            continue
+
         try:
             # Re-run through the full pipeline (queue → speculate → verdict → promote)
             snippet = staging_pipeline.run_full_pipeline(
-                engine_letter, language, code, label, auto_promote=True
+                engine_letter, language, code, label, auto_promote=True,
+                parameters=snap.get('parameters') or None,
+                arguments=snap.get('spec_arguments') or None,
+                namespace=snap.get('namespace', DEFAULT_NAMESPACE),
+                env=env or None,
+                requires=snap.get('requires') or None,
+                output_schema=snap.get('output_schema') or None,
+                tags=snap.get('tags') or None,
+                suppress_credential_check=bool(snap.get('suppress_credential_check')),
             )
 
             if snippet.phase.value == 'promoted':
@@ -341,7 +422,8 @@ def init_runtime(app, session_ledger, socketio):
     Must be called once, after the app and session_ledger are ready.
     """
     global _session_ledger, _socketio, node_registry, _live_executor, multi_debugger, _executors, staging_pipeline
-    global _state_persistence, mesh_relay
+    global _state_persistence, mesh_relay, grpc_server, spec_queue, archivist, rerun_scheduler
+    global manifest_dir
 
     _session_ledger = session_ledger
     _socketio = socketio
@@ -352,6 +434,36 @@ def init_runtime(app, session_ledger, socketio):
     # Persistent Python executor — holds variables across runs (REPL-style)
     _live_executor = _PythonExecutor()
 
+    # CPU / memory / output caps for Go snippet processes (0 = unlimited)
+    resource_limits = ResourceLimits(
+        max_cpu_time=float(resolve_setting('snippet_max_cpu_time',
+                                           'SPOKEDPY_SNIPPET_MAX_CPU_TIME', '0')),
+        max_memory_bytes=int(resolve_setting('snippet_max_memory_bytes',
+                                             'SPOKEDPY_SNIPPET_MAX_MEMORY_BYTES', '0')),
+        max_output_bytes=int(resolve_setting('snippet_max_output_bytes',
+                                             'SPOKEDPY_SNIPPET_MAX_OUTPUT_BYTES', '0')),
+    )
+
+    # `go test -cover` over the Test functions a Go snippet carries (None = not measured)
+    coverage_gate = (CoverageGate(min_coverage_percent=float(resolve_setting(
+                         'coverage_min_percent', 'SPOKEDPY_COVERAGE_MIN_PERCENT', '80')))
+                     if resolve_setting('coverage_gate', 'SPOKEDPY_COVERAGE_GATE', '0') == '1'
+                     else None)
+
+    # `go test -bench` over the Benchmark functions a Go snippet carries (None = not run)
+    benchmark = (BenchmarkConfig() if resolve_setting(
+                     'benchmark_on_promote', 'SPOKEDPY_BENCHMARK_ON_PROMOTE', '0') == '1'
+                 else None)
+
+    # The Test functions a Go snippet carries, run against mutants of it (None = not run)
+    mutation = (MutationConfig(
+                    max_mutations=int(resolve_setting('mutation_max_mutations',
+                                                      'SPOKEDPY_MUTATION_MAX_MUTATIONS', '20')),
+                    threshold=float(resolve_setting('mutation_threshold',
+                                                    'SPOKEDPY_MUTATION_THRESHOLD', '0.25')))
+                if resolve_setting('mutation_test', 'SPOKEDPY_MUTATION_TEST', '0') == '1'
+                else None)
+
     # Per-language executor pool — all 15 engines
     _executors = {
         'python':     _live_executor,            # shared REPL namespace
@@ -361,7 +473,14 @@ def init_runtime(app, session_ledger, socketio):
         'bash':       _BashExecutor(),            # bash / sh / cmd subprocess
         'c':          _CExecutor(),               # gcc / cc compile + run
         'cpp':        _CppExecutor(),             # g++ / clang++ compile + run
-        'go':         _GoExecutor(),              # go run subprocess
+        'go':         _GoExecutor(               # go build + run subprocess (hard deadline)
+            execution_timeout=float(resolve_setting(
+                'execution_timeout', 'SPOKEDPY_EXECUTION_TIMEOUT', '10')),
+            resource_limits=resource_limits,
+            coverage_gate=coverage_gate,
+            benchmark=benchmark,
+            mutation=mutation,
+        ),
         'java':       _JavaExecutor(),            # javac + java
         'ruby':       _RubyExecutor(),            # ruby subprocess
         'r':          _RExecutor(),               # Rscript subprocess
@@ -392,8 +511,44 @@ def init_runtime(app, session_ledger, socketio):
     os.makedirs(snippets_dir, exist_ok=True)
     os.makedirs(os.path.dirname(audit_log_path) or '.', exist_ok=True)
 
+    webhook_log_path = resolve_setting(
+        'webhook_log',
+        'SPOKEDPY_WEBHOOK_LOG',
+        os.path.join(_data_dir, 'webhook_deliveries.jsonl'),
+    )
+
+    snippet_index_path = resolve_setting(
+        'snippet_index_db',
+        'SPOKEDPY_SNIPPET_INDEX_DB',
+        os.path.join(_data_dir, 'snippet_index.db'),
+    )
+
     print(f"  Snippets dir:  {snippets_dir}")
     print(f"  Audit log:     {audit_log_path}")
+    print(f"  Webhook log:   {webhook_log_path}")
+    print(f"  Snippet index: {snippet_index_path}")
+
+    # Promotion webhooks — undelivered events from the last run are replayed
+    webhooks = WebhookDispatcher(
+        webhook_log_path,
+        max_retries=int(resolve_setting('webhook_max_retries', 'SPOKEDPY_WEBHOOK_MAX_RETRIES', '5')),
+    )
+    webhooks.start()
+
+    # gofmt Go sources before they are hashed (whitespace-only edits keep their code_hash)
+    DEFAULT_ENGINES.get('go').format_on_stage = resolve_setting(
+        'go_format_on_stage', 'SPOKEDPY_GO_FORMAT_ON_STAGE', '0') == '1'
+    DEFAULT_ENGINES.get('go').resource_limits = resource_limits
+    DEFAULT_ENGINES.get('go').coverage_gate = coverage_gate
+    DEFAULT_ENGINES.get('go').benchmark = benchmark
+    DEFAULT_ENGINES.get('go').mutation = mutation
+
+    # Speculations of deterministic snippets are served from this cache (0 = off)
+    result_cache_ttl = float(resolve_setting('result_cache_ttl', 'SPOKEDPY_RESULT_CACHE_TTL', '300'))
+
+    # Secret keys that open the stored source of encrypt_at_rest slots
+    age_identity_file = resolve_setting('age_identity_file', 'SPOKEDPY_AGE_IDENTITY_FILE', '')
+    age_identities = read_identities(age_identity_file) if age_identity_file else []
 
     # Staging pipeline — speculative execution & promotion to production
     staging_pipeline = StagingPipeline(
@@ -402,8 +557,84 @@ def init_runtime(app, session_ledger, socketio):
         session_ledger=session_ledger,
         snippets_dir=snippets_dir,
         audit_log_path=audit_log_path,
+        default_label_policy=LabelConflictPolicy(
+            resolve_setting('label_policy', 'SPOKEDPY_LABEL_POLICY', 'overwrite')),
+        webhooks=webhooks,
+        linters=({'go': GoVetLinter(allow_unavailable=resolve_setting(
+                     'lint_allow_unavailable', 'SPOKEDPY_LINT_ALLOW_UNAVAILABLE', '0') == '1')}
+                 if resolve_setting('lint_gate', 'SPOKEDPY_LINT_GATE', '1') == '1' else None),
+        snippet_index=SQLiteSnippetIndex(snippet_index_path),
+        circuit_breaker=CircuitBreaker(BreakerConfig(
+            failure_threshold=int(resolve_setting('circuit_failure_threshold',
+                                                  'SPOKEDPY_CIRCUIT_FAILURE_THRESHOLD', '3')),
+            window=float(resolve_setting('circuit_window', 'SPOKEDPY_CIRCUIT_WINDOW', '300')),
+            recovery_delay=float(resolve_setting('circuit_recovery_delay',
+                                                 'SPOKEDPY_CIRCUIT_RECOVERY_DELAY', '60')),
+        )),
+        namespace_admin_credential=resolve_setting(
+            'namespace_admin_credential', 'SPOKEDPY_NAMESPACE_ADMIN_CREDENTIAL', ''),
+        namespace_credentials=parse_namespace_credentials(resolve_setting(
+            'namespace_credentials', 'SPOKEDPY_NAMESPACE_CREDENTIALS', '')),
+        rerun_history_size=int(resolve_setting('rerun_history_size',
+                                               'SPOKEDPY_RERUN_HISTORY_SIZE', '100')),
+        staging_id_prefix=resolve_setting('staging_id_prefix', 'SPOKEDPY_STAGING_ID_PREFIX', 'stg-'),
+        benchmark_regression_gate=float(resolve_setting(
+            'benchmark_regression_gate', 'SPOKEDPY_BENCHMARK_REGRESSION_GATE', '0')),
+        result_cache=(ResultCache(ttl=result_cache_ttl, max_entries=int(resolve_setting(
+                          'result_cache_max_entries', 'SPOKEDPY_RESULT_CACHE_MAX_ENTRIES', '1000')))
+                      if result_cache_ttl > 0 else None),
+        age_identities=age_identities,
+        locker=locker_from_settings(resolve_setting('lock_backend', 'SPOKEDPY_LOCK_BACKEND', 'local'),
+                                    resolve_setting('lock_url', 'SPOKEDPY_LOCK_URL', '')),
+        promotion_lock_ttl=float(resolve_setting('promotion_lock_ttl',
+                                                 'SPOKEDPY_PROMOTION_LOCK_TTL', '30')),
+        promotion_lock_wait=float(resolve_setting('promotion_lock_wait',
+                                                  'SPOKEDPY_PROMOTION_LOCK_WAIT', '10')),
+    )
+
+    # Async speculation queue — /api/staging/enqueue returns before the spec runs
+    spec_queue = SpeculationQueue(
+        staging_pipeline,
+        workers=int(resolve_setting('spec_workers', 'SPOKEDPY_SPEC_WORKERS', '4')),
+        max_queue_depth=int(resolve_setting('spec_max_queue_depth',
+                                            'SPOKEDPY_SPEC_MAX_QUEUE_DEPTH', '100')),
+        block=resolve_setting('spec_queue_block', 'SPOKEDPY_SPEC_QUEUE_BLOCK', '0') == '1',
     )
 
+    # Archivist — moves (or deletes) promotions the archival policy expires
+    archive_interval = float(resolve_setting('archive_interval', 'SPOKEDPY_ARCHIVE_INTERVAL', '0'))
+    if archive_interval > 0:
+        archivist = Archivist(staging_pipeline, ArchivalPolicy(
+            max_age=float(resolve_setting('archive_max_age', 'SPOKEDPY_ARCHIVE_MAX_AGE', '0')),
+            max_versions_per_label=int(resolve_setting('archive_max_versions',
+                                                       'SPOKEDPY_ARCHIVE_MAX_VERSIONS', '0')),
+            max_size_per_slot_bytes=int(resolve_setting('archive_max_slot_bytes',
+                                                        'SPOKEDPY_ARCHIVE_MAX_SLOT_BYTES', '0')),
+            action=resolve_setting('archive_action', 'SPOKEDPY_ARCHIVE_ACTION', 'move'),
+            cold_storage_path=resolve_setting('archive_dir', 'SPOKEDPY_ARCHIVE_DIR',
+                                              os.path.join(_data_dir, 'snippets_archive')),
+        ), interval=archive_interval)
+        archivist.start()
+
+    # RerunScheduler — re-runs promoted snippets on slots with a cron schedule
+    rerun_interval = float(resolve_setting('rerun_interval', 'SPOKEDPY_RERUN_INTERVAL', '60'))
+    if rerun_interval > 0:
+        rerun_scheduler = RerunScheduler(staging_pipeline, interval=rerun_interval)
+        rerun_scheduler.start()
+
+    # Manifests posted to /api/staging/manifest may name source files under this directory
+    manifest_dir = resolve_setting('manifest_dir', 'SPOKEDPY_MANIFEST_DIR', '') or None
+
+    # Expose pipeline metrics on prometheus_client's default registry when
+    # it is installed (otherwise /api/staging/metrics renders them itself)
+    try:
+        from prometheus_client import REGISTRY as _prom_registry
+        register_metrics(_prom_registry, staging_pipeline.metrics)
+    except ImportError:
+        pass
+    except ValueError:
+        pass  # Already registered by an earlier init in this process
+
     # ── State persistence — restore promoted slots from last checkpoint ──
     _state_persistence = StatePersistence()
     print(f"  State file:    {_state_persistence.path}")
@@ -414,6 +645,25 @@ def init_runtime(app, session_ledger, socketio):
     mesh_relay = MeshRelay(node_registry, session_ledger, instance_name)
     print(f"  Mesh relay:    {mesh_relay.instance_id} ({mesh_relay.instance_name})")
 
+    # ── gRPC SnippetService — remote staging API (opt-in) ───────────
+    grpc_address = resolve_setting('grpc_address', 'SPOKEDPY_GRPC_ADDRESS', '')
+    if grpc_address:
+        try:
+            from visual_editor_core.snippet_grpc import serve as _serve_grpc
+            tokens = [t.strip() for t in resolve_setting(
+                'grpc_tokens', 'SPOKEDPY_GRPC_TOKENS', '').split(',') if t.strip()]
+            cert_path = resolve_setting('grpc_tls_cert', 'SPOKEDPY_GRPC_TLS_CERT', '')
+            key_path = resolve_setting('grpc_tls_key', 'SPOKEDPY_GRPC_TLS_KEY', '')
+            tls_cert = open(cert_path, 'rb').read() if cert_path else None
+            tls_key = open(key_path, 'rb').read() if key_path else None
+            grpc_server = _serve_grpc(staging_pipeline, grpc_address, tokens=tokens,
+                                      tls_cert=tls_cert, tls_key=tls_key)
+            print(f"  gRPC:          {grpc_address} "
+                  f"({'TLS' if tls_cert else 'plaintext'}, "
+                  f"{'token auth' if tokens else 'NO AUTH'})")
+        except Exception as exc:
+            print(f"  gRPC:          disabled ({exc})")
+
     # Register the blueprint (all routes become active)
     app.register_blueprint(runtime_bp)
 
@@ -633,7 +883,7 @@ def get_registry_matrix():
     try:
         if node_registry is None:
             return jsonify({'success': False, 'error': 'Runtime not initialized: node_registry is None'}), 500
-        return jsonify({'success': True, **node_registry.get_matrix_summary()})
+        return jsonify({'success': True, **_visible_matrix(node_registry.get_matrix_summary())})
     except Exception as e:
         return jsonify({'success': False, 'error': str(e)}), 500
 
@@ -717,7 +967,7 @@ def get_registry_slot(slot_id):
             return jsonify({'success': False, 'error': 'Runtime not initialized: node_registry is None'}), 500
 
         slot = node_registry.get_slot(slot_id)
-        if not slot:
+        if not slot or not _slot_visible(slot_id):
             return jsonify({'success': False, 'error': 'Slot not found'}), 404
         return jsonify({'success': True, 'slot': slot.to_dict()})
     except Exception as e:
@@ -732,7 +982,7 @@ def clear_registry_slot(slot_id):
             return jsonify({'success': False, 'error': 'Runtime not initialized: node_registry is None'}), 500
 
         slot = node_registry.get_slot(slot_id)
-        if not slot:
+        if not slot or not _slot_visible(slot_id):
             return jsonify({'success': False, 'error': 'Slot not found'}), 404
 
         if not slot.permissions.has(SlotPermission.DEL):
@@ -754,6 +1004,8 @@ def push_to_registry_slot(slot_id):
         data = request.get_json() or {}
         payload = data.get('data')
         source = data.get('source_slot')
+        if not _slot_visible(slot_id):
+            return jsonify({'success': False, 'error': 'Slot not found'}), 404
 
         ok = node_registry.push_to_slot(slot_id, payload, source)
         if not ok:
@@ -770,6 +1022,8 @@ def read_registry_slot_output(slot_id):
         if node_registry is None:
             return jsonify({'success': False, 'error': 'Runtime not initialized: node_registry is None'}), 500
 
+        if not _slot_visible(slot_id):
+            return jsonify({'success': False, 'error': 'Slot not found'}), 404
         last_n = request.args.get('last', 10, type=int)
         output = node_registry.read_slot_output(slot_id, last_n)
         return jsonify({'success': True, 'output': output})
@@ -784,65 +1038,159 @@ def execute_registry_slot(slot_id):
     This triggers immediate execution — outside the normal engine loop.
     Useful for manual testing / debugging.
     Supports Python, JavaScript, and Rust engines.
+
+    Body (optional): { arguments: {name: value} } — bound into the code if
+    the slot holds a parameterised snippet.
+
+    While a canary promotion is running on the slot, each call runs the
+    canary instead with probability equal to its weight (`canary` in the
+    result names it); during an A/B test the treatment runs with the
+    test's split_ratio and is named the same way.  Slots holding another namespace's snippet are 404.
+
+    Each call is counted as in flight while it runs.  During a graceful
+    swap of the slot it waits for the swap, then runs the new version; a
+    forced swap cancels it (`cancelled` in the result).
     """
     try:
         if node_registry is None or _session_ledger is None:
             return jsonify({'success': False, 'error': 'Runtime not initialized'}), 500
 
         slot = node_registry.get_slot(slot_id)
-        if not slot:
+        if not slot or not _slot_visible(slot_id):
             return jsonify({'success': False, 'error': 'Slot not found'}), 404
         if not slot.node_id:
             return jsonify({'success': False, 'error': 'Slot is empty'}), 400
 
-        snapshot = _session_ledger.get_node_snapshot(slot.node_id)
-        if not snapshot:
-            return jsonify({'success': False, 'error': 'Node not in ledger'}), 404
+        lease = staging_pipeline.begin_slot_run(slot_id) if staging_pipeline is not None else None
+        try:
+            return _execute_slot(slot_id, slot, lease)
+        finally:
+            if lease is not None:
+                staging_pipeline.end_slot_run(lease)
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
 
-        lang = resolve_language_string(LanguageID(snapshot.current_language_id))
-        executor = _get_executor(lang)
-        if executor is None:
-            supported = ', '.join(get_supported_languages())
-            return jsonify({
-                'success': False,
-                'error': f'No live executor for "{lang}". Supported: {supported}'
-            }), 400
 
-        code = snapshot.current_source_code
-        if not code or not code.strip():
-            return jsonify({'success': False, 'error': 'No source code'}), 400
+class _SlotRunRefused(Exception):
+    """_run_slot() stopped before executing anything; `status` is the HTTP code."""
 
-        # Inject input buffer as variable if present (Python only — has namespace)
-        inputs = node_registry.drain_input_buffer(slot_id)
-        if inputs and hasattr(executor, 'set_variable_value'):
-            executor.set_variable_value('_slot_input', [i['data'] for i in inputs])
+    def __init__(self, message, status=400, errors=None):
+        super().__init__(message)
+        self.status = status
+        self.errors = errors                              # ParameterSchemaError details
 
-        result = executor.execute(code)
 
-        node_registry.record_execution(
-            slot_id=slot_id,
-            success=result.success,
-            output=result.output or '',
-            error=str(result.error) if result.error else '',
-            execution_time=result.execution_time,
-        )
+_SlotRun = collections.namedtuple('_SlotRun', ('slot_id', 'slot', 'lang', 'canary', 'result'))
 
-        return jsonify({
-            'success': True,
-            'result': {
-                'slot_id': slot_id,
-                'address': slot.address,
-                'node_name': slot.node_name,
-                'language': lang,
-                'output': result.output or '',
-                'error': str(result.error) if result.error else '',
-                'execution_time': round(result.execution_time, 4),
-                'version': slot.committed_version,
-                'hot_swapped': slot.last_executed_version < slot.committed_version,
-            }
-        })
-    except Exception as e:
-        return jsonify({'success': False, 'error': str(e)}), 500
+
+def _run_slot(slot_id, slot, lease, arguments=None):
+    """
+    Run one committed slot once its execution is counted in (`lease` None
+    without a pipeline): canary routing, argument binding, per-slot env,
+    the input buffer, the slot's isolation level, and the run / registry
+    bookkeeping.  Shared by execute-slot and run-all-slots.
+    """
+    if lease is not None and lease.swapped:
+        slot_id = lease.slot_id                             # Swapped while waiting
+        slot = node_registry.get_slot(slot_id) or slot
+    snapshot = _session_ledger.get_node_snapshot(slot.node_id)
+    if not snapshot:
+        raise _SlotRunRefused('Node not in ledger', 404)
+
+    lang = resolve_language_string(LanguageID(snapshot.current_language_id))
+    executor = _get_executor(lang)
+    if executor is None:
+        supported = ', '.join(get_supported_languages())
+        raise _SlotRunRefused(f'No live executor for "{lang}". Supported: {supported}')
+
+    code = snapshot.current_source_code
+    if not code or not code.strip():
+        raise _SlotRunRefused('No source code')
+
+    canary = None
+    env = {}
+    kwargs = {}
+    if staging_pipeline is not None:
+        canary = staging_pipeline.route_slot(slot_id)
+        if canary is not None:
+            code = canary.program
+        try:
+            code = staging_pipeline.bind_slot_arguments(slot_id, code, arguments, canary)
+        except ParameterSchemaError as pe:
+            raise _SlotRunRefused(str(pe), errors=pe.error_dicts()) from pe
+        except ValueError as ve:
+            raise _SlotRunRefused(str(ve)) from ve
+        env = staging_pipeline.slot_env(slot_id, canary)
+        isolation = staging_pipeline.slot_isolation(slot_id)
+        if isolation.level != IsolationLevel.NONE:
+            if lang == 'python':
+                executor = _PythonProcessExecutor()         # Not the live REPL
+            if getattr(executor, 'isolatable', False):
+                kwargs['isolation'] = isolation
+            else:
+                warn_not_isolatable(lang, isolation)
+    if env:
+        kwargs['env'] = env
+
+    # Inject input buffer as variable if present (Python only — has namespace)
+    inputs = node_registry.drain_input_buffer(slot_id)
+    if inputs and hasattr(executor, 'set_variable_value'):
+        executor.set_variable_value('_slot_input', [i['data'] for i in inputs])
+
+    result = _run_leased(executor, code, lease, **kwargs)
+    if staging_pipeline is not None:
+        staging_pipeline.record_slot_run(slot_id, canary, result.success,
+                                         result.execution_time)
+
+    node_registry.record_execution(
+        slot_id=slot_id,
+        success=result.success,
+        output=result.output or '',
+        error=str(result.error) if result.error else '',
+        execution_time=result.execution_time,
+    )
+    return _SlotRun(slot_id, slot, lang, canary, result)
+
+
+def _execute_slot(slot_id, slot, lease):
+    """execute_registry_slot() once the execution is counted in (`lease` None without a pipeline)."""
+    arguments = (request.get_json(silent=True) or {}).get('arguments')
+    try:
+        slot_id, slot, lang, canary, result = _run_slot(slot_id, slot, lease, arguments)
+    except _SlotRunRefused as refused:
+        body = {'success': False, 'error': str(refused)}
+        if refused.errors:
+            body['errors'] = refused.errors
+        return jsonify(body), refused.status
+
+    return jsonify({
+        'success': True,
+        'result': {
+            'slot_id': slot_id,
+            'address': slot.address,
+            'node_name': slot.node_name,
+            'language': lang,
+            'output': result.output or '',
+            'error': str(result.error) if result.error else '',
+            'execution_time': round(result.execution_time, 4),
+            'version': slot.committed_version,
+            'hot_swapped': slot.last_executed_version < slot.committed_version,
+            'canary': canary.staging_id if canary is not None else None,
+            'cancelled': result.cancelled,
+        }
+    })
+
+
+def _run_leased(executor, code, lease, **kwargs):
+    """executor.execute() under a slot lease: a forced swap cancels the run."""
+    if lease is not None and getattr(executor, 'cancellable', False):
+        kwargs['cancel_event'] = lease.cancel_event
+    result = executor.execute(code, **kwargs)
+    if lease is not None and lease.cancelled and not result.cancelled:
+        result = ExecutionResult(success=False, output=result.output or '',
+                                 error=Exception('Execution cancelled by a forced swap'),
+                                 execution_time=result.execution_time, cancelled=True)
+    return result
 
 
 @runtime_bp.route('/api/registry/slot/<slot_id>/permissions', methods=['PUT'])
@@ -852,6 +1200,8 @@ def update_slot_permissions(slot_id):
         if node_registry is None:
             return jsonify({'success': False, 'error': 'Runtime not initialized: node_registry is None'}), 500
 
+        if not _slot_visible(slot_id):
+            return jsonify({'success': False, 'error': 'Slot not found'}), 404
         data = request.get_json() or {}
         perms = SlotPermissionSet.from_dict(data)
         ok = node_registry.set_slot_permissions(slot_id, perms)
@@ -869,6 +1219,8 @@ def rollback_registry_slot(slot_id):
         if node_registry is None:
             return jsonify({'success': False, 'error': 'Runtime not initialized: node_registry is None'}), 500
 
+        if not _slot_visible(slot_id):
+            return jsonify({'success': False, 'error': 'Slot not found'}), 404
         data = request.get_json() or {}
         version = data.get('version')
         if version is None:
@@ -893,6 +1245,8 @@ def subscribe_slot(slot_id):
         publisher = data.get('publisher_slot_id')
         if not publisher:
             return jsonify({'success': False, 'error': 'publisher_slot_id required'}), 400
+        if not _slot_visible(slot_id) or not _slot_visible(publisher):
+            return jsonify({'success': False, 'error': 'Slot not found'}), 404
 
         # Delegate subscription to the underlying registry implementation.
         # Prefer a well-defined public API on NodeRegistry; fall back to a no-op
@@ -934,7 +1288,12 @@ def get_engine_row(engine_name):
         if not row:
             return jsonify({'success': False, 'error': 'Engine not found'}), 404
 
-        return jsonify({'success': True, 'engine': row.to_dict()})
+        engine = row.to_dict()
+        engine['slots'] = {pos: s for pos, s in engine['slots'].items()
+                           if _slot_visible(s['slot_id'])}
+        engine['positions'] = [p for p in engine['positions'] if str(p) in engine['slots']]
+        engine['occupied'] = len(engine['positions'])
+        return jsonify({'success': True, 'engine': engine})
     except Exception as e:
         return jsonify({'success': False, 'error': str(e)}), 500
 
@@ -961,7 +1320,7 @@ def get_dirty_slots():
         if node_registry is None:
             return jsonify({'success': False, 'error': 'Runtime not initialized: node_registry is None'}), 500
 
-        dirty = node_registry.get_dirty_slots()
+        dirty = [s for s in node_registry.get_dirty_slots() if _slot_visible(s.slot_id)]
         return jsonify({
             'success': True,
             'count': len(dirty),
@@ -979,7 +1338,7 @@ def get_slot_for_node(node_id):
             return jsonify({'success': False, 'error': 'Runtime not initialized: node_registry is None'}), 500
 
         slot = node_registry.get_slot_by_node(node_id)
-        if not slot:
+        if not slot or not _slot_visible(slot.slot_id):
             return jsonify({'success': False, 'error': 'Node not committed to any slot'}), 404
         return jsonify({'success': True, 'slot': slot.to_dict()})
     except Exception as e:
@@ -1001,7 +1360,7 @@ def record_registry_execution():
             return jsonify({'success': False, 'error': 'node_id required'}), 400
 
         slot = node_registry.get_slot_by_node(node_id)
-        if not slot:
+        if not slot or not _slot_visible(slot.slot_id):
             return jsonify({'success': False, 'error': 'Node not in registry'}), 404
 
         ok = node_registry.record_execution(
@@ -2061,9 +2420,15 @@ def run_all_registry_slots():
     Body (optional):
         {
             "engines":       ["a","e","g","i","m"],  // filter to specific engines
-            "reset_before":  false                   // reset namespaces first
+            "reset_before":  false,                  // reset namespaces first
+            "arguments":     {"g3": {"n": 10}}       // per-slot arguments by address
         }
 
+    Each slot runs exactly as execute-slot would run it — canary routing,
+    parameter binding, the slot's env and its lease.
+
+    Only slots the caller's namespace can see are run.
+
     Returns per-slot results with slot address, language, output, error, time.
     """
     import concurrent.futures
@@ -2075,6 +2440,9 @@ def run_all_registry_slots():
         data = request.get_json(silent=True) or {}
         engine_filter = set(data.get('engines', []))
         reset_before = data.get('reset_before', False)
+        slot_arguments = data.get('arguments') or {}
+        if not isinstance(slot_arguments, dict):
+            return jsonify({'success': False, 'error': 'arguments must map slot addresses to objects'}), 400
 
         if reset_before:
             for executor in _executors.values():
@@ -2083,7 +2451,7 @@ def run_all_registry_slots():
 
         # Collect all committed slots with code
         slots_to_run = []
-        matrix = node_registry.get_matrix_summary()
+        matrix = _visible_matrix(node_registry.get_matrix_summary())
         engines_dict = matrix.get('engines', {})
         for engine_name, engine_info in engines_dict.items():
             letter = engine_info.get('letter', '')
@@ -2123,7 +2491,6 @@ def run_all_registry_slots():
 
         def execute_slot(s):
             lang = s['language']
-            code = s['code']
             executor = _get_executor(lang)
             if executor is None:
                 return {
@@ -2140,16 +2507,28 @@ def run_all_registry_slots():
                     'skip_reason': f'No executor for "{lang}"',
                 }
 
-            result = executor.execute(code)
-
-            # Record into registry
-            node_registry.record_execution(
-                slot_id=s['slot_id'],
-                success=result.success,
-                output=result.output or '',
-                error=str(result.error) if result.error else '',
-                execution_time=result.execution_time,
-            )
+            slot = node_registry.get_slot(s['slot_id'])
+            if slot is None or not slot.node_id:
+                raise ValueError('Slot was emptied before it ran')
+            lease = staging_pipeline.begin_slot_run(s['slot_id']) if staging_pipeline else None
+            try:
+                run = _run_slot(s['slot_id'], slot, lease, slot_arguments.get(s['address']))
+            except _SlotRunRefused as refused:
+                return {
+                    'slot_id': s['slot_id'],
+                    'address': s['address'],
+                    'engine_letter': s['engine_letter'],
+                    'language': lang,
+                    'label': s['label'],
+                    'success': False,
+                    'output': '',
+                    'error': str(refused),
+                    'execution_time': 0,
+                }
+            finally:
+                if lease is not None:
+                    staging_pipeline.end_slot_run(lease)
+            s['slot_id'], canary, result = run.slot_id, run.canary, run.result
 
             variables = {}
             if result.variables:
@@ -2173,6 +2552,8 @@ def run_all_registry_slots():
                 'error': str(result.error) if result.error else '',
                 'execution_time': round(result.execution_time, 4),
                 'variables': variables,
+                'canary': canary.staging_id if canary is not None else None,
+                'cancelled': result.cancelled,
             }
 
         # Execute all slots concurrently (each slot is independent)
@@ -2233,7 +2614,7 @@ def hydrate_registry_slots():
         if node_registry is None or _session_ledger is None:
             return jsonify({'success': False, 'error': 'Runtime not initialized'}), 500
 
-        matrix = node_registry.get_matrix_summary()
+        matrix = _visible_matrix(node_registry.get_matrix_summary())
         tabs = []
 
         engines_dict = matrix.get('engines', {})
@@ -2397,11 +2778,110 @@ def api_engines_available():
 
 # ==================== STAGING PIPELINE ====================
 
+# Callers pick their namespace with a header (absent = 'default') and
+# prove membership with that namespace's credential.  An admin
+# credential lifts the boundary: reads see every namespace.
+NAMESPACE_HEADER = 'X-SpokedPy-Namespace'
+NAMESPACE_CREDENTIAL_HEADER = 'X-SpokedPy-Namespace-Credential'
+ADMIN_CREDENTIAL_HEADER = 'X-SpokedPy-Admin-Credential'
+
+
+@runtime_bp.before_request
+def _resolve_namespace():
+    """Set g.stage_namespace (where new snippets go) and g.namespace (what is visible)."""
+    if not request.path.startswith(('/api/staging/', '/api/registry/',
+                                    '/api/execution/registry/')):
+        return None
+    try:
+        g.stage_namespace = validate_namespace(request.headers.get(NAMESPACE_HEADER))
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
+    g.namespace = g.stage_namespace
+    if staging_pipeline is None:
+        return None
+    credential = request.headers.get(ADMIN_CREDENTIAL_HEADER)
+    if credential is not None:
+        if not staging_pipeline.check_admin_credential(credential):
+            return jsonify({'success': False,
+                            'error': 'Invalid namespace admin credential'}), 403
+        g.namespace = None                        # unscoped
+    elif not staging_pipeline.check_namespace_credential(
+            g.stage_namespace, request.headers.get(NAMESPACE_CREDENTIAL_HEADER)):
+        return jsonify({'success': False,
+                        'error': f"Namespace '{g.stage_namespace}' needs a valid "
+                                 f"{NAMESPACE_CREDENTIAL_HEADER}"}), 401
+    return None
+
+
+def _namespace():
+    """Namespace the caller may see, or None for an admin."""
+    return g.get('namespace', DEFAULT_NAMESPACE)
+
+
+def _stage_namespace() -> str:
+    """Namespace new snippets from this request are queued into."""
+    return g.get('stage_namespace', DEFAULT_NAMESPACE)
+
+
+def _slot_visible(slot_id: str) -> bool:
+    """False if the slot's live snippet belongs to a namespace the caller can't see."""
+    namespace = _namespace()
+    if staging_pipeline is None or namespace is None:
+        return True
+    owner = staging_pipeline.slot_namespace(slot_id)
+    return owner is None or owner == namespace
+
+
+def _visible_matrix(matrix):
+    """get_matrix_summary() with the slots the caller can't see shown as empty."""
+    if staging_pipeline is None or _namespace() is None:
+        return matrix
+    for row in matrix.get('engines', {}).values():
+        slots = row.get('slots', {})
+        for pos, slot_data in slots.items():
+            if slot_data and not _slot_visible(slot_data.get('slot_id', '')):
+                slots[pos] = None
+                matrix['total_committed'] -= 1
+                matrix['total_dirty'] -= 1 if slot_data.get('needs_swap') else 0
+    return matrix
+
+
+def _address_visible(address: str) -> bool:
+    """_slot_visible() for a matrix address ('g3' or 'g-3'); unknown addresses are visible."""
+    letter, _, pos = address.partition('-') if '-' in address else (address[:1], '', address[1:])
+    slot = node_registry.get_slot_by_address(letter, int(pos)) if pos.isdigit() else None
+    return slot is None or _slot_visible(slot.slot_id)
+
+
 @runtime_bp.route('/api/staging/queue', methods=['POST'])
 def staging_queue():
     """Queue a snippet into the staging pipeline.
 
-    Body: { engine_letter, language, code, label? }
+    Body: { engine_letter, language, code, label?, label_policy?, parameters?, env?,
+            requires?, output_schema?, tags?, deterministic?, test_cases?,
+            suppress_credential_check? }
+
+    label_policy ('reject'|'overwrite'|'version_suffix') overrides the
+    engine's configured policy for this call.  The snippet is queued into
+    the X-SpokedPy-Namespace namespace ('default' when absent).
+    parameters: [{name, type: int|float64|string|[]string, required?, default?}]
+    declares typed inputs bound at execution time (Go only).
+    env: {NAME: value} is injected into the snippet's own process (Go only);
+    responses list the names, never the values.
+    requires: ['mathutils', ...] merges the live versions of those labels
+    (and whatever they require) in front of the code; 400 if one isn't
+    live or the chain is cyclic.
+    output_schema: a JSON Schema the JSON result the snippet writes to fd 3
+    must match (Go only); a run that doesn't is SCHEMA_FAIL.
+    tags: ['math/number-theory', ...] files the snippet for tag queries.
+    deterministic: true lets a run with the same source, arguments and env
+    be answered from the result cache.
+    test_cases: [{name, params?, expected_output? | expected_output_regex?,
+    expected_exit_code?}] are run after the snippet's own speculation; a
+    failing case makes it CASE_FAIL.
+    A source that looks like it holds a credential (AWS / GCP keys, tokens,
+    `password = '…'`) is refused with 400 and its findings (pattern, line);
+    suppress_credential_check: true stages an intentional fixture anyway.
     Returns the staged snippet with reserved slot address.
     """
     try:
@@ -2412,39 +2892,103 @@ def staging_queue():
         language = data.get('language', '')
         code = data.get('code', '')
         label = data.get('label', '')
+        label_policy = data.get('label_policy') or None
 
         if not code.strip():
             return jsonify({'success': False, 'error': 'No code provided'}), 400
         if not engine_letter and not language:
             return jsonify({'success': False, 'error': 'engine_letter or language required'}), 400
 
-        snippet = staging_pipeline.queue_snippet(engine_letter, language, code, label)
+        snippet = staging_pipeline.queue_snippet(engine_letter, language, code, label,
+                                                 label_policy=label_policy,
+                                                 parameters=data.get('parameters'),
+                                                 namespace=_stage_namespace(),
+                                                 env=data.get('env'),
+                                                 requires=data.get('requires'),
+                                                 output_schema=data.get('output_schema'),
+                                                 tags=data.get('tags'),
+                                                 deterministic=bool(data.get('deterministic')),
+                                                 test_cases=data.get('test_cases'),
+                                                 suppress_credential_check=bool(
+                                                     data.get('suppress_credential_check')))
         return jsonify({'success': True, 'snippet': snippet.to_dict()})
+    except CredentialDetectedError as cd:
+        return jsonify({'success': False, 'error': str(cd),
+                        'findings': [x.to_dict() for x in cd.findings]}), 400
     except ValueError as ve:
         return jsonify({'success': False, 'error': str(ve)}), 400
     except Exception as e:
         return jsonify({'success': False, 'error': str(e)}), 500
 
 
-@runtime_bp.route('/api/staging/speculate/<staging_id>', methods=['POST'])
+@runtime_bp.route('/api/staging/speculate/<path:staging_id>', methods=['POST'])
 def staging_speculate(staging_id):
     """Run speculative (dry-run) execution of a queued snippet.
 
     The snippet is executed in an ISOLATED sandbox. The production
     namespace is NOT touched.
+
+    Body (optional): { arguments: {name: value}, force_run? } — arguments for
+    parameterised snippets; force_run runs a deterministic snippet even
+    when the result cache has its run (and replaces the cached entry).
     """
     try:
         if staging_pipeline is None:
             return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
-        snippet = staging_pipeline.speculate(staging_id)
+        data = request.get_json(silent=True) or {}
+        snippet = staging_pipeline.speculate(staging_id, arguments=data.get('arguments'),
+                                             namespace=_namespace(),
+                                             force_run=bool(data.get('force_run')))
         return jsonify({'success': True, 'snippet': snippet.to_dict()})
+    except CircuitOpenError as co:
+        return jsonify({'success': False, 'error': str(co), 'retry_at': co.retry_at or None}), 503
+    except ParameterSchemaError as pe:
+        return jsonify({'success': False, 'error': str(pe), 'errors': pe.error_dicts()}), 400
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/speculate-batch', methods=['POST'])
+def staging_speculate_batch():
+    """Speculatively execute many queued snippets concurrently.
+
+    Body: { staging_ids: [...], concurrency?, timeout_per_snippet? }
+
+    Runs up to `concurrency` snippets at once (default: spec_concurrency
+    setting).  A crashed or timed-out snippet comes back FAILED without
+    blocking the rest of the batch.  Results are in slot order.
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        data = request.get_json() or {}
+        staging_ids = data.get('staging_ids') or []
+        if not isinstance(staging_ids, list) or not staging_ids:
+            return jsonify({'success': False, 'error': 'staging_ids (non-empty list) required'}), 400
+        concurrency = int(data.get('concurrency') or resolve_setting(
+            'spec_concurrency', 'SPOKEDPY_SPEC_CONCURRENCY', '4'))
+        timeout = data.get('timeout_per_snippet')
+        snippets = staging_pipeline.speculate_batch(
+            staging_ids,
+            max_workers=concurrency,
+            timeout_per_snippet=float(timeout) if timeout is not None else None,
+            namespace=_namespace(),
+        )
+        return jsonify({
+            'success': True,
+            'snippets': [s.to_dict() for s in snippets],
+            'passed': sum(1 for s in snippets if s.phase == StagingPhase.PASSED),
+            'failed': sum(1 for s in snippets if s.phase == StagingPhase.FAILED),
+        })
     except ValueError as ve:
         return jsonify({'success': False, 'error': str(ve)}), 400
     except Exception as e:
         return jsonify({'success': False, 'error': str(e)}), 500
 
 
-@runtime_bp.route('/api/staging/verdict/<staging_id>', methods=['POST'])
+@runtime_bp.route('/api/staging/verdict/<path:staging_id>', methods=['POST'])
 def staging_verdict(staging_id):
     """Issue a verdict on a speculated snippet.
 
@@ -2456,7 +3000,7 @@ def staging_verdict(staging_id):
         data = request.get_json() or {}
         action = data.get('action', 'auto')
         reason = data.get('reason', '')
-        snippet = staging_pipeline.verdict(staging_id, action, reason)
+        snippet = staging_pipeline.verdict(staging_id, action, reason, namespace=_namespace())
         return jsonify({'success': True, 'snippet': snippet.to_dict()})
     except ValueError as ve:
         return jsonify({'success': False, 'error': str(ve)}), 400
@@ -2464,167 +3008,1477 @@ def staging_verdict(staging_id):
         return jsonify({'success': False, 'error': str(e)}), 500
 
 
-@runtime_bp.route('/api/staging/promote/<staging_id>', methods=['POST'])
+@runtime_bp.route('/api/staging/promote/<path:staging_id>', methods=['POST'])
 def staging_promote(staging_id):
     """Promote a PASSED snippet to production.
 
+    Body: { skip_lint?, canary?: { start_weight, step_size, step_interval,
+                                   error_threshold, min_executions },
+            graceful_swap?: { drain_timeout }, dry_run?, arguments? }
+
     Writes code to disk, creates ledger node, commits to registry slot.
+    With `canary` the snippet instead serves a growing share of the live
+    version's executions and is promoted (or rolled back) automatically.
+    With `graceful_swap` an overwrite waits for the live version's
+    in-flight executions: the snippet comes back PENDING_SWAP until they
+    finish, or drain_timeout passes and they are cancelled (see
+    /api/staging/swaps).
+    If the lint gate finds problems the snippet is FAILED with
+    spec_result LINT_FAIL and the diagnostics are returned (422).
+    503 while the circuit breaker for the snippet's code is open.
+    409 if another promotion holds the slot's lock past promotion_lock_wait.
+    With `dry_run` nothing is promoted: every gate (speculation included)
+    is checked and the report comes back with `would_succeed`.
+    On a slot with require_approvals nothing is promoted either: 202 with
+    the pending `approval` (see /api/staging/approvals).
     """
     try:
         if staging_pipeline is None:
             return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
-        snippet = staging_pipeline.promote(staging_id)
+        data = request.get_json(silent=True) or {}
+        if data.get('dry_run'):
+            report = staging_pipeline.dry_run_promote(
+                staging_id, skip_lint=bool(data.get('skip_lint')),
+                arguments=data.get('arguments'), namespace=_namespace())
+            return jsonify({'success': True, 'dry_run': report.to_dict()})
+        canary = CanaryConfig.from_dict(data['canary']) if data.get('canary') else None
+        swap = GracefulSwap.from_dict(data['graceful_swap']) \
+            if isinstance(data.get('graceful_swap'), dict) else None
+        snippet = staging_pipeline.promote(staging_id, skip_lint=bool(data.get('skip_lint')),
+                                           canary=canary, namespace=_namespace(),
+                                           graceful_swap=swap)
         return jsonify({'success': True, 'snippet': snippet.to_dict()})
+    except LintFailedError as le:
+        return jsonify({
+            'success': False,
+            'error': str(le),
+            'lint': le.result.to_dict(),
+            'snippet': staging_pipeline.get_snippet(staging_id, _namespace()).to_dict(),
+        }), 422
+    except BenchmarkRegressionError as be:
+        return jsonify({
+            'success': False,
+            'error': str(be),
+            'regressions': [r.to_dict() for r in be.regressions],
+            'snippet': staging_pipeline.get_snippet(staging_id, _namespace()).to_dict(),
+        }), 422
+    except CircuitOpenError as co:
+        return jsonify({'success': False, 'error': str(co), 'retry_at': co.retry_at or None}), 503
+    except LockUnavailableError as lu:
+        return jsonify({'success': False, 'error': str(lu), 'resource': lu.resource}), 409
+    except PendingApprovalError as pa:
+        return jsonify({'success': True, 'approval': pa.record.to_dict()}), 202
     except ValueError as ve:
         return jsonify({'success': False, 'error': str(ve)}), 400
     except Exception as e:
         return jsonify({'success': False, 'error': str(e)}), 500
 
 
-@runtime_bp.route('/api/staging/run-full', methods=['POST'])
-def staging_run_full():
-    """Run the FULL staging pipeline in one call.
-
-    Body: { engine_letter, language, code, label?, auto_promote? }
+@runtime_bp.route('/api/staging/swaps', methods=['GET'])
+def staging_swaps():
+    """Graceful swaps, oldest first.
 
-    queue → speculate → verdict → promote (if pass & auto_promote=true)
+    Query: ?status=pending|completed|forced|aborted (default: all)
     """
     try:
         if staging_pipeline is None:
             return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
-        data = request.get_json() or {}
-        engine_letter = data.get('engine_letter', '')
-        language = data.get('language', '')
-        code = data.get('code', '')
-        label = data.get('label', '')
-        auto_promote = data.get('auto_promote', True)
-
-        if not code.strip():
-            return jsonify({'success': False, 'error': 'No code provided'}), 400
-
-        snippet = staging_pipeline.run_full_pipeline(
-            engine_letter, language, code, label, auto_promote
-        )
-        return jsonify({'success': True, 'snippet': snippet.to_dict()})
+        status = request.args.get('status')
+        swaps = staging_pipeline.list_swaps(namespace=_namespace())
+        if status:
+            swaps = [s for s in swaps if s.status == SwapStatus(status)]
+        return jsonify({'success': True, 'swaps': [s.to_dict() for s in swaps]})
     except ValueError as ve:
         return jsonify({'success': False, 'error': str(ve)}), 400
     except Exception as e:
         return jsonify({'success': False, 'error': str(e)}), 500
 
 
-@runtime_bp.route('/api/staging/rollback/<staging_id>', methods=['POST'])
-def staging_rollback(staging_id):
-    """Rollback a promoted snippet from production.
-
-    Body: { reason? }
-    """
+@runtime_bp.route('/api/staging/swaps/<path:staging_id>', methods=['GET'])
+def staging_swap(staging_id):
+    """The graceful swap installing one snippet, with the slot's in-flight count."""
     try:
         if staging_pipeline is None:
             return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
-        data = request.get_json() or {}
-        reason = data.get('reason', '')
-        snippet = staging_pipeline.rollback(staging_id, reason)
-        return jsonify({'success': True, 'snippet': snippet.to_dict()})
+        swap = staging_pipeline.get_swap(staging_id, namespace=_namespace())
+        if swap is None:
+            return jsonify({'success': False, 'error': 'No swap for this snippet'}), 404
+        return jsonify({'success': True, 'swap': swap.to_dict(),
+                        'in_flight': staging_pipeline.swaps.in_flight(swap.slot_id)})
     except ValueError as ve:
         return jsonify({'success': False, 'error': str(ve)}), 400
     except Exception as e:
         return jsonify({'success': False, 'error': str(e)}), 500
 
 
-@runtime_bp.route('/api/staging/snippets', methods=['GET'])
-def staging_list():
-    """List active staged snippets + optional history.
+@runtime_bp.route('/api/staging/approvals', methods=['GET'])
+def staging_approvals():
+    """Promotion approvals on protected slots.
 
-    Query: ?include_history=1&limit=100
+    Query: ?status=pending|approved|rejected (default: all)
     """
     try:
         if staging_pipeline is None:
             return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
-        include_history = request.args.get('include_history', '0') == '1'
-        limit = int(request.args.get('limit', '100'))
-
-        active = [s.to_dict() for s in staging_pipeline.get_active()]
-        result = {'success': True, 'active': active}
-
-        if include_history:
-            result['history'] = [s.to_dict() for s in staging_pipeline.get_history(limit)]
-
-        result['summary'] = staging_pipeline.get_pipeline_summary()
-        return jsonify(result)
+        records = staging_pipeline.list_approvals(request.args.get('status') or None,
+                                                  namespace=_namespace())
+        return jsonify({'success': True, 'approvals': [r.to_dict() for r in records]})
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
     except Exception as e:
         return jsonify({'success': False, 'error': str(e)}), 500
 
 
-@runtime_bp.route('/api/staging/snippet/<staging_id>', methods=['GET'])
-def staging_get_snippet(staging_id):
-    """Get a single snippet by staging_id (with full audit trail)."""
+@runtime_bp.route('/api/staging/approvals/<path:staging_id>/approve', methods=['POST'])
+def staging_approve_promotion(staging_id):
+    """Approve a pending promotion.
+
+    Body: { approver_id }
+    The approval that reaches the slot's require_approvals promotes the
+    snippet, which comes back promoted (or in canary).
+    """
     try:
         if staging_pipeline is None:
             return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
-        snippet = staging_pipeline.get_snippet(staging_id)
-        if snippet is None:
-            return jsonify({'success': False, 'error': 'Not found'}), 404
-
-        audit = staging_pipeline.get_audit_trail(staging_id)
+        data = request.get_json() or {}
+        record = staging_pipeline.approve_promotion(staging_id, data.get('approver_id'),
+                                                    namespace=_namespace())
         return jsonify({
             'success': True,
-            'snippet': snippet.to_dict(),
-            'audit_trail': audit,
+            'approval': record.to_dict(),
+            'snippet': staging_pipeline.get_snippet(staging_id, _namespace()).to_dict(),
         })
+    except LintFailedError as le:
+        return jsonify({'success': False, 'error': str(le), 'lint': le.result.to_dict()}), 422
+    except CircuitOpenError as co:
+        return jsonify({'success': False, 'error': str(co), 'retry_at': co.retry_at or None}), 503
+    except LockUnavailableError as lu:
+        return jsonify({'success': False, 'error': str(lu), 'resource': lu.resource}), 409
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
     except Exception as e:
         return jsonify({'success': False, 'error': str(e)}), 500
 
 
-@runtime_bp.route('/api/staging/audit', methods=['GET'])
-def staging_audit():
-    """Get the full audit trail (most recent first).
+@runtime_bp.route('/api/staging/approvals/<path:staging_id>/reject', methods=['POST'])
+def staging_reject_promotion(staging_id):
+    """Reject a pending promotion; the snippet is REJECTED.
 
-    Query: ?staging_id=xxx&limit=500
+    Body: { approver_id, reason? }
     """
     try:
         if staging_pipeline is None:
             return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
-        sid = request.args.get('staging_id')
-        limit = int(request.args.get('limit', '500'))
-        entries = staging_pipeline.get_audit_trail(sid, limit)
-        return jsonify({'success': True, 'entries': entries, 'count': len(entries)})
+        data = request.get_json() or {}
+        record = staging_pipeline.reject_promotion(staging_id, data.get('approver_id'),
+                                                   data.get('reason', ''),
+                                                   namespace=_namespace())
+        return jsonify({'success': True, 'approval': record.to_dict()})
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
     except Exception as e:
         return jsonify({'success': False, 'error': str(e)}), 500
 
 
-@runtime_bp.route('/api/staging/summary', methods=['GET'])
-def staging_summary():
-    """Get pipeline summary: active counts, history stats, reserved slots."""
+@runtime_bp.route('/api/staging/promote-batch', methods=['POST'])
+def staging_promote_batch():
+    """Promote several PASSED snippets atomically — all or nothing.
+
+    Body: { staging_ids: [...], skip_lint? }
+
+    If any promotion fails, every snippet already promoted by this call
+    is rolled back and the response lists per-snippet results (409).
+    """
     try:
         if staging_pipeline is None:
             return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
-        return jsonify({'success': True, **staging_pipeline.get_pipeline_summary()})
+        data = request.get_json() or {}
+        staging_ids = data.get('staging_ids') or []
+        if not isinstance(staging_ids, list) or not staging_ids:
+            return jsonify({'success': False, 'error': 'staging_ids (non-empty list) required'}), 400
+        results = staging_pipeline.batch_promote(staging_ids,
+                                                 skip_lint=bool(data.get('skip_lint')),
+                                                 namespace=_namespace())
+        return jsonify({'success': True, 'results': [r.to_dict() for r in results]})
+    except BatchPromotionError as be:
+        return jsonify({
+            'success': False,
+            'error': str(be),
+            'results': [r.to_dict() for r in be.results],
+        }), 409
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
     except Exception as e:
         return jsonify({'success': False, 'error': str(e)}), 500
 
 
-# ==================== SETTINGS API ====================
-# Three-tier configuration: DB (web UI) → .env → hard-coded default.
-# The web interface can override any setting; deleting it reverts to
-# the env / default value.
+@runtime_bp.route('/api/staging/run-full', methods=['POST'])
+def staging_run_full():
+    """Run the FULL staging pipeline in one call.
 
-from web_interface.project_db import (
-    get_setting as _db_get_setting,
-    set_setting as _db_set_setting,
-    get_all_settings as _db_get_all_settings,
-    delete_setting as _db_delete_setting,
-)
+    Body: { engine_letter, language, code, label?, auto_promote?, label_policy?, skip_lint?,
+            parameters?, arguments?, env?, requires?, output_schema?, tags?, deterministic?,
+            test_cases?, suppress_credential_check? }
 
-# Keys that can be managed through the API, with their env-var name
-# and hard-coded fallback.
-_KNOWN_SETTINGS = {
-    'snippets_dir': {
-        'env': 'SPOKEDPY_SNIPPETS_DIR',
-        'default': os.path.join(os.path.dirname(os.path.dirname(__file__)), 'data', 'snippets'),
-        'label': 'Snippet output directory',
-        'restart_required': True,
-    },
-    'audit_log': {
+    queue → speculate → verdict → promote (if pass & auto_promote=true)
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        data = request.get_json() or {}
+        engine_letter = data.get('engine_letter', '')
+        language = data.get('language', '')
+        code = data.get('code', '')
+        label = data.get('label', '')
+        auto_promote = data.get('auto_promote', True)
+        label_policy = data.get('label_policy') or None
+
+        if not code.strip():
+            return jsonify({'success': False, 'error': 'No code provided'}), 400
+
+        snippet = staging_pipeline.run_full_pipeline(
+            engine_letter, language, code, label, auto_promote,
+            label_policy=label_policy,
+            skip_lint=bool(data.get('skip_lint')),
+            parameters=data.get('parameters'),
+            arguments=data.get('arguments'),
+            namespace=_stage_namespace(),
+            env=data.get('env'),
+            requires=data.get('requires'),
+            output_schema=data.get('output_schema'),
+            tags=data.get('tags'),
+            deterministic=bool(data.get('deterministic')),
+            test_cases=data.get('test_cases'),
+            suppress_credential_check=bool(data.get('suppress_credential_check')),
+        )
+        return jsonify({'success': True, 'snippet': snippet.to_dict()})
+    except LintFailedError as le:
+        return jsonify({'success': False, 'error': str(le), 'lint': le.result.to_dict()}), 422
+    except BenchmarkRegressionError as be:
+        return jsonify({'success': False, 'error': str(be),
+                        'regressions': [r.to_dict() for r in be.regressions]}), 422
+    except CircuitOpenError as co:
+        return jsonify({'success': False, 'error': str(co), 'retry_at': co.retry_at or None}), 503
+    except ParameterSchemaError as pe:
+        return jsonify({'success': False, 'error': str(pe), 'errors': pe.error_dicts()}), 400
+    except CredentialDetectedError as cd:
+        return jsonify({'success': False, 'error': str(cd),
+                        'findings': [x.to_dict() for x in cd.findings]}), 400
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+def _parse_priority(value) -> Priority:
+    if isinstance(value, int) and not isinstance(value, bool):
+        return Priority(value)
+    try:
+        return Priority[str(value or 'normal').upper()]
+    except KeyError:
+        raise ValueError(f"Unknown priority '{value}' (low / normal / high)")
+
+
+@runtime_bp.route('/api/staging/enqueue', methods=['POST'])
+def staging_enqueue():
+    """Queue a snippet for asynchronous speculation; returns at once (202).
+
+    Body: { engine_letter, language, code, label?, priority?: 'low'|'normal'|'high',
+            auto_promote?, label_policy?, skip_lint?, parameters?, arguments?,
+            env?, requires?, output_schema?, tags?, test_cases?, suppress_credential_check?,
+            block?, block_timeout? }
+
+    Poll or block on GET /api/staging/result/{staging_id}.  When the queue
+    already holds spec_max_queue_depth jobs the call waits for a free place
+    (block / spec_queue_block) or fails with 429.
+    """
+    try:
+        if staging_pipeline is None or spec_queue is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        data = request.get_json() or {}
+        code = data.get('code', '')
+        if not code.strip():
+            return jsonify({'success': False, 'error': 'No code provided'}), 400
+        if not data.get('engine_letter') and not data.get('language'):
+            return jsonify({'success': False, 'error': 'engine_letter or language required'}), 400
+        block_timeout = data.get('block_timeout')
+
+        staging_id = spec_queue.stage(
+            data.get('engine_letter', ''), data.get('language', ''), code,
+            data.get('label', ''),
+            priority=_parse_priority(data.get('priority')),
+            block=data.get('block'),
+            timeout=float(block_timeout) if block_timeout is not None else None,
+            arguments=data.get('arguments'),
+            auto_promote=bool(data.get('auto_promote', False)),
+            skip_lint=bool(data.get('skip_lint')),
+            label_policy=data.get('label_policy') or None,
+            parameters=data.get('parameters'),
+            namespace=_stage_namespace(),
+            env=data.get('env'),
+            requires=data.get('requires'),
+            output_schema=data.get('output_schema'),
+            tags=data.get('tags'),
+            test_cases=data.get('test_cases'),
+            suppress_credential_check=bool(data.get('suppress_credential_check')),
+        )
+        return jsonify({
+            'success': True,
+            'staging_id': staging_id,
+            'snippet': staging_pipeline.get_snippet(staging_id).to_dict(),
+            'queue': spec_queue.get_summary(),
+        }), 202
+    except QueueFullError as qe:
+        return jsonify({'success': False, 'error': str(qe), 'queue': spec_queue.get_summary()}), 429
+    except QueueClosedError as ce:
+        return jsonify({'success': False, 'error': str(ce)}), 503
+    except CredentialDetectedError as cd:
+        return jsonify({'success': False, 'error': str(cd),
+                        'findings': [x.to_dict() for x in cd.findings]}), 400
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/result/<path:staging_id>', methods=['GET'])
+def staging_result(staging_id):
+    """Result of an enqueued speculation.
+
+    Query: ?wait=<seconds> blocks up to that long (default 0 = just poll).
+    Returns 202 with status 'pending' / 'running' while unfinished.
+    """
+    try:
+        if staging_pipeline is None or spec_queue is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        if staging_pipeline.get_snippet(staging_id, _namespace()) is None:
+            return jsonify({'success': False, 'error': f"No staged snippet '{staging_id}'"}), 404
+        wait = max(0.0, min(request.args.get('wait', 0.0, type=float), 60.0))
+        try:
+            result = spec_queue.wait_for_result(staging_id, timeout=wait)
+        except TimeoutError:
+            return jsonify({'success': True, 'status': spec_queue.status(staging_id),
+                            'staging_id': staging_id}), 202
+        return jsonify({
+            'success': True,
+            'status': 'done',
+            'spec_result': result.value,
+            'snippet': staging_pipeline.get_snippet(staging_id).to_dict(),
+        })
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/exec-queue', methods=['GET'])
+def staging_exec_queue():
+    """Speculation queue depth, workers and pending jobs (in run order)."""
+    try:
+        if spec_queue is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        return jsonify({'success': True, 'queue': spec_queue.get_summary(),
+                        'pending': spec_queue.pending()})
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/canaries', methods=['GET'])
+def staging_canaries():
+    """Canary promotions, running and finished (oldest first)."""
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        rollouts = [r for r in staging_pipeline.canaries.all()
+                    if staging_pipeline.get_snippet(r.canary_id, _namespace()) is not None]
+        return jsonify({'success': True, 'canaries': [r.to_dict() for r in rollouts]})
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/canary/<path:staging_id>', methods=['GET'])
+def staging_canary(staging_id):
+    """Weight, error rates and step history of one canary promotion."""
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        rollout = staging_pipeline.get_canary(staging_id, _namespace())
+        if rollout is None:
+            return jsonify({'success': False, 'error': f"No canary for '{staging_id}'"}), 404
+        return jsonify({'success': True, 'canary': rollout.to_dict()})
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/canary/<path:staging_id>/abort', methods=['POST'])
+def staging_canary_abort(staging_id):
+    """Stop a running canary; the live version keeps the slot.
+
+    Body (optional): { reason? }
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        data = request.get_json(silent=True) or {}
+        rollout = staging_pipeline.abort_canary(staging_id, data.get('reason', ''),
+                                                namespace=_namespace())
+        return jsonify({'success': True, 'canary': rollout.to_dict(),
+                        'snippet': staging_pipeline.get_snippet(staging_id).to_dict()})
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/ab-tests', methods=['GET'])
+def staging_ab_tests():
+    """A/B tests, running and finished (oldest first)."""
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        tests = staging_pipeline.list_ab_tests(_namespace())
+        return jsonify({'success': True, 'ab_tests': [t.to_dict() for t in tests]})
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/ab-tests', methods=['POST'])
+def staging_ab_test_start():
+    """Split a slot's executions between two labels.
+
+    Body: { slot, control_label, treatment_label, split_ratio?: 0.5,
+            min_samples?: 30 }
+
+    control_label must be live on the slot; the newest PASSED snippet
+    labelled treatment_label serves `split_ratio` of its executions.
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        data = request.get_json(silent=True) or {}
+        missing = [k for k in ('slot', 'control_label', 'treatment_label') if not data.get(k)]
+        if missing:
+            return jsonify({'success': False,
+                            'error': f"Missing field(s): {', '.join(missing)}"}), 400
+        test = staging_pipeline.start_ab_test(
+            data['slot'], data['control_label'], data['treatment_label'],
+            split_ratio=data.get('split_ratio', 0.5),
+            min_samples=data.get('min_samples', DEFAULT_MIN_SAMPLES),
+            namespace=_stage_namespace())
+        return jsonify({'success': True, 'ab_test': test.to_dict()})
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/ab-tests/<test_id>', methods=['GET'])
+def staging_ab_test_result(test_id):
+    """Runs, pass rate and mean / p95 time per variant, and the recommended winner."""
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        report = staging_pipeline.ab_test_result(test_id, _namespace())
+        return jsonify({'success': True, 'result': report.to_dict(),
+                        'ab_test': staging_pipeline.get_ab_test(test_id).to_dict()})
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 404
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/ab-tests/<test_id>/end', methods=['POST'])
+def staging_ab_test_end(test_id):
+    """Promote the winner and archive (or reject) the loser.
+
+    Body (optional): { winner?: "control" | "treatment" } — defaults to the
+    recommended winner, which needs min_samples runs per variant.
+    A treatment winner on a protected slot answers 202 with the pending
+    approval; end the test again once it is approved.
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        data = request.get_json(silent=True) or {}
+        test = staging_pipeline.end_ab_test(test_id, data.get('winner'),
+                                            namespace=_namespace())
+        return jsonify({'success': True, 'ab_test': test.to_dict()})
+    except PendingApprovalError as pa:
+        return jsonify({'success': True, 'approval': pa.record.to_dict()}), 202
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/ab-tests/<test_id>/abort', methods=['POST'])
+def staging_ab_test_abort(test_id):
+    """Stop a running A/B test without a winner.
+
+    Body (optional): { reason? }
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        data = request.get_json(silent=True) or {}
+        test = staging_pipeline.abort_ab_test(test_id, data.get('reason', ''),
+                                              namespace=_namespace())
+        return jsonify({'success': True, 'ab_test': test.to_dict()})
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/circuit/<path:staging_id>', methods=['GET'])
+def staging_circuit(staging_id):
+    """Circuit breaker state for a snippet's code (closed / open / half_open)."""
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        return jsonify({'success': True,
+                        'circuit': staging_pipeline.describe_circuit(staging_id, _namespace())})
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 404
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/circuit/<path:staging_id>/reset', methods=['POST'])
+def staging_circuit_reset(staging_id):
+    """Close a snippet's circuit by hand so it can run (and promote) again."""
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        cleared = staging_pipeline.reset_circuit(staging_id, _namespace())
+        return jsonify({'success': True, 'cleared': cleared,
+                        'circuit': staging_pipeline.describe_circuit(staging_id, _namespace())})
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 404
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/rollback/<path:staging_id>', methods=['POST'])
+def staging_rollback(staging_id):
+    """Rollback a promoted snippet from production.
+
+    Body: { reason? }
+
+    The version of the same label promoted immediately before this one
+    (if any) is re-installed and returned as `restored`.
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        data = request.get_json() or {}
+        reason = data.get('reason', '')
+        snippet = staging_pipeline.rollback(staging_id, reason, namespace=_namespace())
+        restored = (staging_pipeline.get_snippet(snippet.rolled_back_to, _namespace())
+                    if snippet.rolled_back_to else None)
+        return jsonify({
+            'success': True,
+            'snippet': snippet.to_dict(),
+            'restored': restored.to_dict() if restored else None,
+        })
+    except LockUnavailableError as lu:
+        return jsonify({'success': False, 'error': str(lu), 'resource': lu.resource}), 409
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/promotions/<slot>', methods=['GET'])
+def staging_promotion_history(slot):
+    """Promote / rollback history for a slot (engine letter, e.g. 'i').
+
+    Query: ?label=Fibonacci
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        label = request.args.get('label')
+        records = staging_pipeline.get_promotion_history(slot.lower(), label, _namespace())
+        return jsonify({'success': True, 'records': records, 'count': len(records)})
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/versions/<slot>/<label>', methods=['GET'])
+def staging_version_history(slot, label):
+    """Promoted versions of a label on a slot, oldest first.
+
+    Query: ?include_source=1 to inline each version's source
+
+    Labels are per namespace: this is the caller's X-SpokedPy-Namespace.
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        include_source = request.args.get('include_source', '0') in ('1', 'true', 'yes')
+        versions = staging_pipeline.get_version_history(label, slot.lower(), _stage_namespace())
+        return jsonify({
+            'success': True,
+            'versions': [v.to_dict(include_source=include_source) for v in versions],
+            'count': len(versions),
+        })
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/versions/<slot>/<label>/diff', methods=['GET'])
+def staging_version_diff(slot, label):
+    """Unified diff between two versions of a label.
+
+    Query: ?old=1&new=2 (default: the last two versions)
+           &format=patch to get the raw patch as text/x-diff
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        old = request.args.get('old', type=int)
+        new = request.args.get('new', type=int)
+        result = staging_pipeline.diff_versions(label, slot.lower(), old, new,
+                                                _stage_namespace())
+        if request.args.get('format') == 'patch':
+            return (result.patch, 200, {'Content-Type': 'text/x-diff; charset=utf-8'})
+        return jsonify({'success': True, 'diff': result.to_dict()})
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/diff/<path:staging_id>', methods=['GET'])
+def staging_diff_preview(staging_id):
+    """A staged snippet's source against the production version of its label.
+
+    Returns lines_added / lines_removed, the unified_diff (and its hunks),
+    is_new when the label has no production version on the slot, and
+    is_semantically_equivalent when the two differ only in layout and
+    comments (Go and Python syntax trees; other languages compare text).
+    Query: ?format=patch to get the raw patch as text/x-diff
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        result = staging_pipeline.diff_against_production(staging_id, _namespace())
+        if request.args.get('format') == 'patch':
+            return (result.unified_diff, 200, {'Content-Type': 'text/x-diff; charset=utf-8'})
+        return jsonify({'success': True, 'diff': result.to_dict()})
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/slot-diff/<slot>', methods=['POST'])
+def staging_slot_diff(slot):
+    """What a slot would look like if a set of staged snippets were all promoted.
+
+    Body: { staging_ids: [...] } — applied in that order
+
+    Returns added / removed / updated / unchanged labels, size_before,
+    size_after and size_delta (source bytes), introduces_cycle with the
+    cycle it would close, and missing dependencies.  Nothing is promoted
+    and no promotion lock is taken.
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        data = request.get_json() or {}
+        staging_ids = data.get('staging_ids') or []
+        if not isinstance(staging_ids, list):
+            return jsonify({'success': False, 'error': 'staging_ids must be a list'}), 400
+        report = staging_pipeline.slot_diff(slot.lower(), staging_ids, _namespace())
+        return jsonify({'success': True, 'diff': report.to_dict()})
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/label-policies', methods=['GET'])
+def staging_label_policies():
+    """Label conflict policy per engine letter ('*' = default)."""
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        return jsonify({'success': True, 'policies': staging_pipeline.get_label_policies()})
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/label-policies/<engine_letter>', methods=['PUT'])
+def staging_set_label_policy(engine_letter):
+    """Set an engine's label conflict policy.
+
+    Body: { policy: 'reject'|'overwrite'|'version_suffix'|null }
+    A null policy reverts the engine to the default.
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        data = request.get_json() or {}
+        staging_pipeline.set_label_policy(engine_letter.lower(), data.get('policy') or None)
+        return jsonify({'success': True, 'policies': staging_pipeline.get_label_policies()})
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/slots/<slot>', methods=['GET'])
+def staging_slot_usage(slot):
+    """Snippet count / byte usage of a slot against its capacity limits."""
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        return jsonify({'success': True, 'usage': staging_pipeline.get_slot_usage(slot.lower())})
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/slots/<slot>/report', methods=['GET'])
+def staging_slot_report(slot):
+    """Health report for a slot: spec result counts, spec_time p50/p95/p99, trend.
+
+    Query: ?since=<unix seconds> (default 0 = everything retained)
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        since = float(request.args.get('since', '0'))
+        report = staging_pipeline.slot_report(slot.lower(), since, namespace=_namespace())
+        return jsonify({'success': True, 'report': report.to_dict()})
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/slots/<slot>/dependencies', methods=['GET'])
+def staging_slot_dependencies(slot):
+    """Dependency graph of a slot's live snippets in the caller's namespace.
+
+    Query: ?format=json (default) | dot — Graphviz DOT as text/vnd.graphviz
+    A cycle answers 409 with the labels in it.
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        fmt = request.args.get('format', 'json')
+        if fmt not in ('json', 'dot'):
+            return jsonify({'success': False, 'error': "format must be 'json' or 'dot'"}), 400
+        graph = staging_pipeline.dependency_graph(slot.lower(), namespace=_stage_namespace())
+        if fmt == 'dot':
+            return graph.to_dot(), 200, {'Content-Type': 'text/vnd.graphviz; charset=utf-8'}
+        return jsonify({'success': True, 'graph': graph.to_dict()})
+    except CyclicDependencyError as ce:
+        return jsonify({'success': False, 'error': str(ce), 'cycle': ce.chain}), 409
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/slots/<slot>/config', methods=['PUT'])
+def staging_configure_slot(slot):
+    """Set a slot's capacity limits.
+
+    Body: { max_snippets?, max_total_bytes?, eviction?: 'lru'|'oldest_first'|'manual',
+            require_approvals?, schedule?: '<cron expression>',
+            isolation?: 'none'|'process'|'namespace',
+            encrypt_at_rest?, recipient?: '<age X25519 public key>' }
+    An empty body removes the limits.
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        data = request.get_json() or {}
+        config = None
+        if data:
+            config = SlotConfig(
+                max_snippets=int(data.get('max_snippets') or 0),
+                max_total_bytes=int(data.get('max_total_bytes') or 0),
+                eviction=data.get('eviction') or 'manual',
+                require_approvals=int(data.get('require_approvals') or 0),
+                schedule=data.get('schedule') or '',
+                isolation=data.get('isolation') or 'none',
+                encrypt_at_rest=bool(data.get('encrypt_at_rest')),
+                recipient=data.get('recipient') or '',
+            )
+        staging_pipeline.configure_slot(slot.lower(), config)
+        return jsonify({'success': True, 'usage': staging_pipeline.get_slot_usage(slot.lower()),
+                        'isolation': staging_pipeline.isolation_for(slot.lower()).describe()})
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/slots/<slot>/evict', methods=['POST'])
+def staging_evict(slot):
+    """Evict live entries from a slot according to its eviction policy.
+
+    Body: { count }
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        data = request.get_json() or {}
+        evicted = staging_pipeline.evict(slot.lower(), int(data.get('count', 1)))
+        return jsonify({
+            'success': True,
+            'evicted': [s.to_dict() for s in evicted],
+            'usage': staging_pipeline.get_slot_usage(slot.lower()),
+        })
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/archive', methods=['GET'])
+def staging_archive_status():
+    """The archival policy, sweep interval and how much has been archived."""
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        if archivist is None:
+            return jsonify({'success': True, 'enabled': False})
+        return jsonify({'success': True, 'enabled': True, **archivist.status()})
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/archive/sweep', methods=['POST'])
+def staging_archive_sweep():
+    """Run one archival sweep now instead of waiting for the next interval."""
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        if archivist is None:
+            return jsonify({'success': False,
+                            'error': 'Archiving is disabled (archive_interval = 0)'}), 400
+        archived = archivist.sweep()
+        return jsonify({
+            'success': True,
+            'archived': [s.to_dict() for s in archived],
+            'archived_total': archivist.archived_total,
+        })
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/reruns', methods=['GET'])
+def staging_reruns_status():
+    """The rerun scheduler's interval, last tick and rerun / alert totals."""
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        if rerun_scheduler is None:
+            return jsonify({'success': True, 'enabled': False})
+        return jsonify({'success': True, 'enabled': True, **rerun_scheduler.status()})
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/reruns/tick', methods=['POST'])
+def staging_reruns_tick():
+    """Run every due scheduled rerun now instead of waiting for the next tick."""
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        if rerun_scheduler is None:
+            return jsonify({'success': False,
+                            'error': 'Scheduled reruns are disabled (rerun_interval = 0)'}), 400
+        records = rerun_scheduler.tick()
+        return jsonify({
+            'success': True,
+            'reruns': [r.to_dict() for r in records],
+            'reruns_total': rerun_scheduler.reruns_total,
+        })
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/reruns/<path:staging_id>', methods=['GET'])
+def staging_rerun_history(staging_id):
+    """A promoted snippet's scheduled reruns, oldest first."""
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        records = staging_pipeline.rerun_history(staging_id, namespace=_namespace())
+        snippet = staging_pipeline.get_snippet(staging_id)
+        return jsonify({
+            'success': True,
+            'staging_id': staging_id,
+            'last_rerun_at': snippet.last_rerun_at,
+            'reruns': [r.to_dict() for r in records],
+        })
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 404
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/replay/<path:staging_id>', methods=['POST'])
+def staging_replay(staging_id):
+    """Re-run a historical promotion with its original source, env and arguments.
+
+    Body: { record_as }  — keep the result as a named snapshot
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        data = request.get_json() or {}
+        result = staging_pipeline.replay(staging_id, ReplayOptions.from_dict(data),
+                                         namespace=_namespace())
+        return jsonify({'success': True, 'replay': result.to_dict()})
+    except ReplayError as rp:
+        return jsonify({'success': False, 'error': str(rp)}), 409
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/replays', methods=['GET'])
+def staging_replay_snapshots():
+    """Recorded replay snapshots of the caller's namespace; ?staging_id= narrows to one snippet."""
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        snapshots = staging_pipeline.replay_snapshots(
+            _stage_namespace(), staging_id=request.args.get('staging_id', ''))
+        return jsonify({'success': True, 'snapshots': [s.to_dict() for s in snapshots]})
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/replays/<name>', methods=['GET'])
+def staging_replay_snapshot(name):
+    """One replay snapshot; ?against=<name> adds the fields that differ from another."""
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        snapshot = staging_pipeline.replay_snapshot(name, _stage_namespace())
+        body = {'success': True, 'snapshot': snapshot.to_dict()}
+        against = request.args.get('against')
+        if against:
+            other = staging_pipeline.replay_snapshot(against, _stage_namespace())
+            body['changes'] = compare_snapshots(other, snapshot)
+        return jsonify(body)
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 404
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/revalidate', methods=['POST'])
+def staging_revalidate():
+    """Re-run the spec of every promoted snippet against the current engines.
+
+    Body: { slot?, concurrency?, fail_fast?, update_metadata? }
+
+    Streams JSON Lines as the snippets finish: one {event: 'result', ...}
+    per snippet, then {event: 'summary', total, passed, failed, skipped,
+    mean_spec_time_delta, stopped}.  A client that disconnects stops the
+    run from starting more snippets.
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        data = request.get_json() or {}
+        run = staging_pipeline.revalidate_all(data.get('slot', ''),
+                                              RevalidateOptions.from_dict(data),
+                                              namespace=_namespace())
+
+        def lines():
+            try:
+                for event in run:
+                    yield json.dumps(event.to_dict()) + '\n'
+            finally:
+                run.cancel()
+
+        return lines(), 200, {'Content-Type': 'application/x-ndjson; charset=utf-8'}
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/webhooks', methods=['GET'])
+def staging_webhooks_list():
+    """Registered webhook targets plus pending / dead-lettered deliveries."""
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        dispatcher = staging_pipeline.webhooks
+        if dispatcher is None:
+            return jsonify({'success': True, 'targets': [], 'pending': 0, 'dead': []})
+        return jsonify({
+            'success': True,
+            'targets': [t.to_dict() for t in dispatcher.targets()],
+            'pending': len(dispatcher.pending()),
+            'dead': [d.to_dict() for d in dispatcher.dead_letters()[-50:]],
+        })
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/webhooks', methods=['POST'])
+def staging_webhooks_add():
+    """Register a webhook target.
+
+    Body: { url, secret, events?: ['promotion_completed', 'rollback', ...] }
+
+    Each payload is signed: X-SpokedPy-Signature: sha256=<HMAC of body>.
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        data = request.get_json() or {}
+        target = staging_pipeline.add_webhook_target(
+            data.get('url', ''), data.get('secret', ''), data.get('events') or None)
+        return jsonify({'success': True, 'target': target.to_dict()})
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/webhooks/<target_id>', methods=['DELETE'])
+def staging_webhooks_remove(target_id):
+    """Unregister a webhook target (queued deliveries are still attempted)."""
+    try:
+        if staging_pipeline is None or staging_pipeline.webhooks is None:
+            return jsonify({'success': False, 'error': 'Webhooks not initialized'}), 500
+        if not staging_pipeline.webhooks.remove_target(target_id):
+            return jsonify({'success': False, 'error': f"No webhook target '{target_id}'"}), 404
+        return jsonify({'success': True})
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/snippets', methods=['GET'])
+def staging_list():
+    """List active staged snippets + optional history.
+
+    Query: ?include_history=1&limit=100
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        include_history = request.args.get('include_history', '0') == '1'
+        limit = int(request.args.get('limit', '100'))
+
+        active = [s.to_dict() for s in staging_pipeline.get_active(_namespace())]
+        result = {'success': True, 'active': active}
+
+        if include_history:
+            result['history'] = [s.to_dict() for s in
+                                 staging_pipeline.get_history(limit, _namespace())]
+
+        result['summary'] = staging_pipeline.get_pipeline_summary()
+        return jsonify(result)
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/query', methods=['GET'])
+def staging_query():
+    """Search active + historical snippets, oldest first, one page at a time.
+
+    Query: ?language=go&label=fib&slot=i&spec_result=PASS
+           &created_after=<unix>&created_before=<unix>&code_hash_prefix=ab12
+           &limit=50&page_token=<next_page_token from the previous page>
+           &namespace=<name> (admin credential only; others always search their own)
+           &include_archived=1 (archived promotions are left out by default)
+           &tags=math/** AND pure (a tag query, as for /api/staging/tags)
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        page = staging_pipeline.query(_snippet_filter(request.args), _namespace())
+        return jsonify({'success': True, **page.to_dict()})
+    except ValueError as e:
+        return jsonify({'success': False, 'error': str(e)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/tags', methods=['GET'])
+def staging_tag_query():
+    """Every snippet whose tags match a tag query, oldest first (unpaginated).
+
+    Query: ?q=math/* (children of math), math/** (math and below),
+           patterns joined by AND / OR — 'math/** AND pure OR io/file'
+           &include_archived=1
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        snippets = staging_pipeline.tag_query(
+            request.args.get('q', ''), _namespace(),
+            include_archived=request.args.get('include_archived', '0') in ('1', 'true', 'yes'))
+        return jsonify({'success': True, 'snippets': [s.to_dict() for s in snippets],
+                        'count': len(snippets)})
+    except ValueError as e:
+        return jsonify({'success': False, 'error': str(e)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/tags/<path:staging_id>', methods=['PUT'])
+def staging_retag(staging_id):
+    """Replace a snippet's tags.
+
+    Body: { tags: ['math/number-theory', ...] }
+    403 once the snippet has been promoted, unless the caller presents
+    the namespace admin credential.
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        data = request.get_json() or {}
+        if 'tags' not in data:
+            return jsonify({'success': False, 'error': 'tags required'}), 400
+        snippet = staging_pipeline.retag(staging_id, data['tags'], _namespace())
+        return jsonify({'success': True, 'snippet': snippet.to_dict()})
+    except TagsImmutableError as te:
+        return jsonify({'success': False, 'error': str(te)}), 403
+    except ValueError as e:
+        return jsonify({'success': False, 'error': str(e)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+def _snippet_filter(args) -> SnippetFilter:
+    """SnippetFilter from /api/staging/query-style query parameters."""
+    return SnippetFilter(
+        language=args.get('language', ''),
+        label=args.get('label', ''),
+        slot=args.get('slot', ''),
+        spec_result=args.get('spec_result', ''),
+        created_after=float(args.get('created_after', '0')),
+        created_before=float(args.get('created_before', '0')),
+        code_hash_prefix=args.get('code_hash_prefix', ''),
+        tags=args.get('tags', ''),
+        limit=int(args.get('limit', '50')),
+        page_token=args.get('page_token', ''),
+        namespace=args.get('namespace', ''),
+        include_archived=args.get('include_archived', '0') in ('1', 'true', 'yes'),
+    )
+
+
+@runtime_bp.route('/api/staging/export', methods=['GET'])
+def staging_export():
+    """Download the matching snippets as JSON Lines (one record per snippet).
+
+    Query: the /api/staging/query filters (page_token is ignored; every
+    match is exported, `limit` at a time).  Records carry the source
+    (base64), the snippet's metadata and its promotion history — env
+    names, never values.  Feed the file to POST /api/staging/import.
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        out = io.StringIO()
+        staging_pipeline.export_snippets(_snippet_filter(request.args), out, _namespace())
+        return (out.getvalue(), 200,
+                {'Content-Type': 'application/x-ndjson; charset=utf-8',
+                 'Content-Disposition': 'attachment; filename="snippets.jsonl"'})
+    except ValueError as e:
+        return jsonify({'success': False, 'error': str(e)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/import', methods=['POST'])
+def staging_import():
+    """Stage, speculate and (where they were live) promote exported snippets.
+
+    Body: { jsonl, conflict_policy?: 'skip'|'reject'|'overwrite'|'version_suffix',
+            promote?, skip_lint?, stop_on_error?, env?: {NAME: value} }
+
+    jsonl is the text GET /api/staging/export returned.  Records land in
+    the X-SpokedPy-Namespace namespace.  conflict_policy (default skip)
+    decides what happens to a label already live on the target slot; env
+    supplies the values of the env names records were exported with.
+    Returns a per-line report; with stop_on_error the first failed record
+    ends the import with 400 and the report so far.
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        data = request.get_json() or {}
+        options = ImportOptions(conflict_policy=data.get('conflict_policy') or 'skip',
+                                promote=bool(data.get('promote', True)),
+                                skip_lint=bool(data.get('skip_lint')),
+                                stop_on_error=bool(data.get('stop_on_error')),
+                                env=dict(data.get('env') or {}))
+        report = staging_pipeline.import_snippets((data.get('jsonl') or '').splitlines(),
+                                                  options, _stage_namespace())
+        return jsonify({'success': True, 'report': report.to_dict()})
+    except SnippetImportError as ie:
+        return jsonify({'success': False, 'error': str(ie), 'report': ie.report.to_dict()}), 400
+    except ValueError as e:
+        return jsonify({'success': False, 'error': str(e)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/manifest', methods=['POST'])
+def staging_load_manifest():
+    """Queue every snippet a YAML manifest declares, all or nothing.
+
+    Body: { manifest }  — the YAML text (format: spokedpy-manifest/1)
+
+    Entries are queued in order into the X-SpokedPy-Namespace namespace;
+    source_file paths resolve under the manifest_dir setting (unset:
+    sources must be inline).  An entry that is refused rolls back the
+    ones queued before it: 400 with the 1-based `entry` at fault.
+    Returns the staging IDs in declaration order.
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        data = request.get_json() or {}
+        ids = staging_pipeline.load_manifest(data.get('manifest') or '', manifest_dir,
+                                             _stage_namespace())
+        return jsonify({'success': True, 'staging_ids': ids})
+    except ManifestError as me:
+        return jsonify({'success': False, 'error': str(me), 'entry': me.entry}), 400
+    except ValueError as e:
+        return jsonify({'success': False, 'error': str(e)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/manifest', methods=['GET'])
+def staging_dump_manifest():
+    """Download the matching snippets as one YAML manifest.
+
+    Query: the /api/staging/query filters (every match is written).
+    Sources are inline, so POST /api/staging/manifest loads the file
+    into another server as it is.
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        out = io.StringIO()
+        staging_pipeline.dump_manifest(_snippet_filter(request.args), out, _namespace())
+        return (out.getvalue(), 200,
+                {'Content-Type': 'application/yaml; charset=utf-8',
+                 'Content-Disposition': 'attachment; filename="snippets.yaml"'})
+    except ValueError as e:
+        return jsonify({'success': False, 'error': str(e)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/snippet/<path:staging_id>', methods=['GET'])
+def staging_get_snippet(staging_id):
+    """Get a single snippet by staging_id (with full audit trail)."""
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        snippet = staging_pipeline.get_snippet(staging_id, _namespace())
+        if snippet is None:
+            return jsonify({'success': False, 'error': 'Not found'}), 404
+
+        audit = staging_pipeline.get_audit_trail(staging_id, namespace=_namespace())
+        return jsonify({
+            'success': True,
+            'snippet': snippet.to_dict(),
+            'audit_trail': audit,
+        })
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/audit', methods=['GET'])
+def staging_audit():
+    """Get the full audit trail (most recent first).
+
+    Query: ?staging_id=xxx&limit=500
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        sid = request.args.get('staging_id')
+        limit = int(request.args.get('limit', '500'))
+        entries = staging_pipeline.get_audit_trail(sid, limit, _namespace())
+        return jsonify({'success': True, 'entries': entries, 'count': len(entries)})
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/summary', methods=['GET'])
+def staging_summary():
+    """Get pipeline summary: active counts, history stats, reserved slots."""
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        return jsonify({'success': True, **staging_pipeline.get_pipeline_summary()})
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/cache', methods=['GET'])
+def staging_cache():
+    """Result cache settings and counters (hits, misses, evictions, entries)."""
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        cache = staging_pipeline.result_cache
+        return jsonify({'success': True, 'enabled': cache is not None,
+                        'cache': cache.describe() if cache is not None else None})
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/cache', methods=['DELETE'])
+def staging_cache_clear():
+    """Drop every cached speculation (needs X-SpokedPy-Admin-Credential)."""
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        admin = NamespaceAdmin(staging_pipeline, request.headers.get(ADMIN_CREDENTIAL_HEADER, ''))
+        return jsonify({'success': True, 'cleared': admin.clear_result_cache()})
+    except NamespaceAdminError as ne:
+        return jsonify({'success': False, 'error': str(ne)}), 403
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/namespaces', methods=['GET'])
+def staging_namespaces():
+    """Every namespace with its snippet count (needs X-SpokedPy-Admin-Credential)."""
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        admin = NamespaceAdmin(staging_pipeline, request.headers.get(ADMIN_CREDENTIAL_HEADER, ''))
+        return jsonify({'success': True, 'namespaces': admin.namespaces()})
+    except NamespaceAdminError as ne:
+        return jsonify({'success': False, 'error': str(ne)}), 403
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/migrate-prefix', methods=['POST'])
+def staging_migrate_prefix():
+    """Move staging IDs to a new prefix (needs X-SpokedPy-Admin-Credential).
+
+    Body: { from_prefix, to_prefix, dry_run?, force? }
+    409 with the report if new IDs are taken (without force) or snippets are in flight.
+    """
+    try:
+        if staging_pipeline is None:
+            return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+        admin = NamespaceAdmin(staging_pipeline, request.headers.get(ADMIN_CREDENTIAL_HEADER, ''))
+        data = request.get_json(force=True) or {}
+        options = MigrateOptions.from_dict(data)
+        if not options.dry_run and spec_queue is not None and spec_queue.depth:
+            # Queued jobs hold the old IDs
+            return jsonify({'success': False, 'error': f"{spec_queue.depth} speculation job(s) "
+                            f"are still queued; retry once the queue drains"}), 409
+        report = admin.migrate_id_prefix(data.get('from_prefix', ''), data.get('to_prefix', ''),
+                                         options)
+        return jsonify({'success': True, 'report': report.to_dict()})
+    except NamespaceAdminError as ne:
+        return jsonify({'success': False, 'error': str(ne)}), 403
+    except MigrationConflictError as mc:
+        return jsonify({'success': False, 'error': str(mc), 'report': mc.report.to_dict()}), 409
+    except ValueError as ve:
+        return jsonify({'success': False, 'error': str(ve)}), 400
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/engines', methods=['GET'])
+def staging_engines():
+    """List the registered language engines (built-in and plugins)."""
+    return jsonify({'success': True, 'engines': [
+        DEFAULT_ENGINES.get(name).describe() for name in DEFAULT_ENGINES.names()
+    ]})
+
+
+@runtime_bp.route('/api/staging/engines/<language>/validate', methods=['POST'])
+def staging_engine_validate(language):
+    """Run an engine's static checks without staging anything.
+
+    Body: { code }
+    """
+    try:
+        engine = DEFAULT_ENGINES.get(language)
+        if engine is None:
+            return jsonify({'success': False, 'error': f"No engine registered for '{language}'"}), 404
+        code = (request.get_json() or {}).get('code', '')
+        if not code.strip():
+            return jsonify({'success': False, 'error': 'No code provided'}), 400
+        diagnostics = engine.validate(code.encode('utf-8'))
+        return jsonify({'success': True, 'valid': not diagnostics,
+                        'diagnostics': [d.to_dict() for d in diagnostics]})
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/format', methods=['POST'])
+def staging_format():
+    """Preview the gofmt'd source go_format_on_stage would stage.
+
+    Body: { code }
+    """
+    try:
+        code = (request.get_json() or {}).get('code', '')
+        if not code.strip():
+            return jsonify({'success': False, 'error': 'No code provided'}), 400
+        formatted = dry_run_format(code.encode('utf-8')).decode('utf-8')
+        return jsonify({'success': True, 'formatted': formatted, 'changed': formatted != code})
+    except FormatFailedError as fe:
+        return jsonify({'success': False, 'error': str(fe), 'cause': fe.cause,
+                        'diagnostics': [d.to_dict() for d in fe.diagnostics]}), 400
+    except FormatUnavailableError as fu:
+        return jsonify({'success': False, 'error': str(fu)}), 503
+    except Exception as e:
+        return jsonify({'success': False, 'error': str(e)}), 500
+
+
+@runtime_bp.route('/api/staging/metrics', methods=['GET'])
+def staging_metrics():
+    """Pipeline metrics in the Prometheus text exposition format."""
+    if staging_pipeline is None:
+        return jsonify({'success': False, 'error': 'Staging pipeline not initialized'}), 500
+    return (staging_pipeline.metrics.render_text(), 200,
+            {'Content-Type': 'text/plain; version=0.0.4; charset=utf-8'})
+
+
+# ==================== SETTINGS API ====================
+# Three-tier configuration: DB (web UI) → .env → hard-coded default.
+# The web interface can override any setting; deleting it reverts to
+# the env / default value.
+
+from web_interface.project_db import (
+    get_setting as _db_get_setting,
+    set_setting as _db_set_setting,
+    get_all_settings as _db_get_all_settings,
+    delete_setting as _db_delete_setting,
+)
+
+# Keys that can be managed through the API, with their env-var name
+# and hard-coded fallback.
+_KNOWN_SETTINGS = {
+    'snippets_dir': {
+        'env': 'SPOKEDPY_SNIPPETS_DIR',
+        'default': os.path.join(os.path.dirname(os.path.dirname(__file__)), 'data', 'snippets'),
+        'label': 'Snippet output directory',
+        'restart_required': True,
+    },
+    'audit_log': {
         'env': 'SPOKEDPY_AUDIT_LOG',
         'default': os.path.join(os.path.dirname(os.path.dirname(__file__)), 'data', 'staging_audit.jsonl'),
         'label': 'Staging audit log path',
@@ -2636,6 +4490,294 @@ _KNOWN_SETTINGS = {
         'label': 'Marshal token TTL (seconds)',
         'restart_required': False,
     },
+    'spec_concurrency': {
+        'env': 'SPOKEDPY_SPEC_CONCURRENCY',
+        'default': '4',
+        'label': 'Max concurrent speculative executions per batch',
+        'restart_required': False,
+    },
+    'execution_timeout': {
+        'env': 'SPOKEDPY_EXECUTION_TIMEOUT',
+        'default': '10',
+        'label': 'Hard per-snippet execution deadline (seconds)',
+        'restart_required': True,
+    },
+    'snippet_max_cpu_time': {
+        'env': 'SPOKEDPY_SNIPPET_MAX_CPU_TIME',
+        'default': '0',
+        'label': 'CPU seconds a Go snippet process may use (0 = unlimited)',
+        'restart_required': True,
+    },
+    'snippet_max_memory_bytes': {
+        'env': 'SPOKEDPY_SNIPPET_MAX_MEMORY_BYTES',
+        'default': '0',
+        'label': 'Address space a Go snippet process may map, in bytes (0 = unlimited)',
+        'restart_required': True,
+    },
+    'snippet_max_output_bytes': {
+        'env': 'SPOKEDPY_SNIPPET_MAX_OUTPUT_BYTES',
+        'default': '0',
+        'label': 'Bytes of stdout / stderr each kept from a snippet run (0 = unlimited)',
+        'restart_required': True,
+    },
+    'label_policy': {
+        'env': 'SPOKEDPY_LABEL_POLICY',
+        'default': 'overwrite',
+        'label': 'Default label conflict policy (reject / overwrite / version_suffix)',
+        'restart_required': True,
+    },
+    'webhook_log': {
+        'env': 'SPOKEDPY_WEBHOOK_LOG',
+        'default': os.path.join(os.path.dirname(os.path.dirname(__file__)), 'data', 'webhook_deliveries.jsonl'),
+        'label': 'Webhook delivery log path',
+        'restart_required': True,
+    },
+    'snippet_index_db': {
+        'env': 'SPOKEDPY_SNIPPET_INDEX_DB',
+        'default': os.path.join(os.path.dirname(os.path.dirname(__file__)), 'data', 'snippet_index.db'),
+        'label': 'SQLite database of snippet metadata records (query API)',
+        'restart_required': True,
+    },
+    'webhook_max_retries': {
+        'env': 'SPOKEDPY_WEBHOOK_MAX_RETRIES',
+        'default': '5',
+        'label': 'Webhook delivery retries before dead-lettering',
+        'restart_required': True,
+    },
+    'lint_gate': {
+        'env': 'SPOKEDPY_LINT_GATE',
+        'default': '1',
+        'label': 'Run go vet analyzers before promoting Go snippets (0/1)',
+        'restart_required': True,
+    },
+    'lint_allow_unavailable': {
+        'env': 'SPOKEDPY_LINT_ALLOW_UNAVAILABLE',
+        'default': '0',
+        'label': 'Let the lint gate pass when an analyzer cannot run (0/1)',
+        'restart_required': True,
+    },
+    'coverage_gate': {
+        'env': 'SPOKEDPY_COVERAGE_GATE',
+        'default': '0',
+        'label': "Measure Go snippets' Test functions with go test -cover (0/1)",
+        'restart_required': True,
+    },
+    'coverage_min_percent': {
+        'env': 'SPOKEDPY_COVERAGE_MIN_PERCENT',
+        'default': '80',
+        'label': 'Statement coverage a Go snippet with tests needs to pass (coverage_gate = 1)',
+        'restart_required': True,
+    },
+    'benchmark_on_promote': {
+        'env': 'SPOKEDPY_BENCHMARK_ON_PROMOTE',
+        'default': '0',
+        'label': "Run Go snippets' Benchmark functions with go test -bench -benchtime=3s (0/1)",
+        'restart_required': True,
+    },
+    'benchmark_regression_gate': {
+        'env': 'SPOKEDPY_BENCHMARK_REGRESSION_GATE',
+        'default': '0',
+        'label': 'Percent a benchmark may slow down against the live version before promotion is blocked (0 = off)',
+        'restart_required': True,
+    },
+    'result_cache_ttl': {
+        'env': 'SPOKEDPY_RESULT_CACHE_TTL',
+        'default': '300',
+        'label': "Seconds a deterministic snippet's speculation is served from the result cache (0 = off)",
+        'restart_required': True,
+    },
+    'result_cache_max_entries': {
+        'env': 'SPOKEDPY_RESULT_CACHE_MAX_ENTRIES',
+        'default': '1000',
+        'label': 'Speculation results the cache keeps before evicting the least recently used',
+        'restart_required': True,
+    },
+    'mutation_test': {
+        'env': 'SPOKEDPY_MUTATION_TEST',
+        'default': '0',
+        'label': "Run Go snippets' Test functions against mutants of their source (0/1)",
+        'restart_required': True,
+    },
+    'mutation_max_mutations': {
+        'env': 'SPOKEDPY_MUTATION_MAX_MUTATIONS',
+        'default': '20',
+        'label': "Mutants a Go snippet's tests are run against at most (mutation_test = 1)",
+        'restart_required': True,
+    },
+    'mutation_threshold': {
+        'env': 'SPOKEDPY_MUTATION_THRESHOLD',
+        'default': '0.25',
+        'label': 'Fraction of mutants that may survive the tests before the run is WEAK_SPEC (mutation_test = 1)',
+        'restart_required': True,
+    },
+    'go_format_on_stage': {
+        'env': 'SPOKEDPY_GO_FORMAT_ON_STAGE',
+        'default': '0',
+        'label': 'Run Go snippets through gofmt before hashing and staging them (0/1)',
+        'restart_required': True,
+    },
+    'circuit_failure_threshold': {
+        'env': 'SPOKEDPY_CIRCUIT_FAILURE_THRESHOLD',
+        'default': '3',
+        'label': 'Consecutive failed runs that open a snippet\'s circuit breaker (0 = off)',
+        'restart_required': True,
+    },
+    'circuit_window': {
+        'env': 'SPOKEDPY_CIRCUIT_WINDOW',
+        'default': '300',
+        'label': 'Seconds within which the failures must fall to open the circuit',
+        'restart_required': True,
+    },
+    'circuit_recovery_delay': {
+        'env': 'SPOKEDPY_CIRCUIT_RECOVERY_DELAY',
+        'default': '60',
+        'label': 'Seconds an open circuit waits before allowing one trial run',
+        'restart_required': True,
+    },
+    'spec_workers': {
+        'env': 'SPOKEDPY_SPEC_WORKERS',
+        'default': '4',
+        'label': 'Background workers draining the async speculation queue',
+        'restart_required': True,
+    },
+    'spec_max_queue_depth': {
+        'env': 'SPOKEDPY_SPEC_MAX_QUEUE_DEPTH',
+        'default': '100',
+        'label': 'Max pending jobs in the async speculation queue',
+        'restart_required': True,
+    },
+    'spec_queue_block': {
+        'env': 'SPOKEDPY_SPEC_QUEUE_BLOCK',
+        'default': '0',
+        'label': 'Block /api/staging/enqueue when the queue is full instead of 429 (0/1)',
+        'restart_required': True,
+    },
+    'grpc_address': {
+        'env': 'SPOKEDPY_GRPC_ADDRESS',
+        'default': '',
+        'label': 'gRPC SnippetService listen address (empty = disabled)',
+        'restart_required': True,
+    },
+    'grpc_tokens': {
+        'env': 'SPOKEDPY_GRPC_TOKENS',
+        'default': '',
+        'label': 'Comma-separated bearer tokens accepted by the gRPC service',
+        'restart_required': True,
+    },
+    'grpc_tls_cert': {
+        'env': 'SPOKEDPY_GRPC_TLS_CERT',
+        'default': '',
+        'label': 'gRPC TLS certificate (PEM path)',
+        'restart_required': True,
+    },
+    'grpc_tls_key': {
+        'env': 'SPOKEDPY_GRPC_TLS_KEY',
+        'default': '',
+        'label': 'gRPC TLS private key (PEM path)',
+        'restart_required': True,
+    },
+    'namespace_admin_credential': {
+        'env': 'SPOKEDPY_NAMESPACE_ADMIN_CREDENTIAL',
+        'default': '',
+        'label': 'Admin credential for cross-namespace snippet access (empty = disabled)',
+        'restart_required': True,
+    },
+    'namespace_credentials': {
+        'env': 'SPOKEDPY_NAMESPACE_CREDENTIALS',
+        'default': '',
+        'label': 'Comma-separated namespace=credential pairs callers present to use a namespace',
+        'restart_required': True,
+    },
+    'staging_id_prefix': {
+        'env': 'SPOKEDPY_STAGING_ID_PREFIX',
+        'default': 'stg-',
+        'label': 'Prefix of new staging IDs (e.g. team-alpha/stg-)',
+        'restart_required': True,
+    },
+    'archive_interval': {
+        'env': 'SPOKEDPY_ARCHIVE_INTERVAL',
+        'default': '0',
+        'label': 'Seconds between archival sweeps of old promotions (0 = disabled)',
+        'restart_required': True,
+    },
+    'archive_max_age': {
+        'env': 'SPOKEDPY_ARCHIVE_MAX_AGE',
+        'default': '0',
+        'label': 'Archive promotions older than this many seconds (0 = no limit)',
+        'restart_required': True,
+    },
+    'archive_max_versions': {
+        'env': 'SPOKEDPY_ARCHIVE_MAX_VERSIONS',
+        'default': '0',
+        'label': 'Promotions kept per label before older ones are archived (0 = no limit)',
+        'restart_required': True,
+    },
+    'archive_max_slot_bytes': {
+        'env': 'SPOKEDPY_ARCHIVE_MAX_SLOT_BYTES',
+        'default': '0',
+        'label': 'Source bytes a slot keeps across its promotions (0 = no limit)',
+        'restart_required': True,
+    },
+    'archive_action': {
+        'env': 'SPOKEDPY_ARCHIVE_ACTION',
+        'default': 'move',
+        'label': 'What archiving does: move (to archive_dir) or delete',
+        'restart_required': True,
+    },
+    'archive_dir': {
+        'env': 'SPOKEDPY_ARCHIVE_DIR',
+        'default': os.path.join(os.path.dirname(os.path.dirname(__file__)), 'data', 'snippets_archive'),
+        'label': 'Cold-storage directory archived snippet files move to',
+        'restart_required': True,
+    },
+    'rerun_interval': {
+        'env': 'SPOKEDPY_RERUN_INTERVAL',
+        'default': '60',
+        'label': 'Seconds between checks for due scheduled reruns (0 = disabled)',
+        'restart_required': True,
+    },
+    'manifest_dir': {
+        'env': 'SPOKEDPY_MANIFEST_DIR',
+        'default': '',
+        'label': 'Manifest source directory',
+        'restart_required': True,
+    },
+    'age_identity_file': {
+        'env': 'SPOKEDPY_AGE_IDENTITY_FILE',
+        'default': '',
+        'label': 'age identity file',
+        'restart_required': True,
+    },
+    'rerun_history_size': {
+        'env': 'SPOKEDPY_RERUN_HISTORY_SIZE',
+        'default': '100',
+        'label': 'Scheduled reruns kept per promoted snippet',
+        'restart_required': True,
+    },
+    'lock_backend': {
+        'env': 'SPOKEDPY_LOCK_BACKEND',
+        'default': 'local',
+        'label': 'Slot promotion lock backend (local / redis / etcd)',
+        'restart_required': True,
+    },
+    'lock_url': {
+        'env': 'SPOKEDPY_LOCK_URL',
+        'default': '',
+        'label': 'Lock backend URL (redis://… or etcd://host:port)',
+        'restart_required': True,
+    },
+    'promotion_lock_ttl': {
+        'env': 'SPOKEDPY_PROMOTION_LOCK_TTL',
+        'default': '30',
+        'label': 'Seconds a slot promotion lock lasts if never released',
+        'restart_required': True,
+    },
+    'promotion_lock_wait': {
+        'env': 'SPOKEDPY_PROMOTION_LOCK_WAIT',
+        'default': '10',
+        'label': 'Seconds a promotion waits for its slot lock',
+        'restart_required': True,
+    },
 }
 
 
@@ -3098,7 +5240,8 @@ def get_enriched_matrix():
         if node_registry is None:
             return jsonify({'success': False, 'error': 'Registry not initialized'}), 500
 
-        matrix = node_registry.get_matrix_summary()
+        matrix = _visible_matrix(node_registry.get_matrix_summary())
+        namespace = _namespace()
 
         # Build a reverse lookup: staging_id → token record + token string
         token_by_staging = {}
@@ -3120,9 +5263,9 @@ def get_enriched_matrix():
         # Build staging snippet lookup
         snippet_by_staging = {}
         if staging_pipeline:
-            for sn in staging_pipeline.get_active():
+            for sn in staging_pipeline.get_active(namespace):
                 snippet_by_staging[sn.staging_id] = sn
-            for sn in staging_pipeline.get_history(limit=500):
+            for sn in staging_pipeline.get_history(500, namespace):
                 snippet_by_staging[sn.staging_id] = sn
 
         # Enrich each occupied slot
@@ -3209,7 +5352,7 @@ def get_enriched_matrix():
         # Include active staging pipeline entries (snippets in flight)
         in_flight = []
         if staging_pipeline:
-            for sn in staging_pipeline.get_active():
+            for sn in staging_pipeline.get_active(namespace):
                 in_flight.append({
                     'staging_id': sn.staging_id,
                     'phase': sn.phase.value,
@@ -3240,6 +5383,8 @@ def lock_slot(address):
     """
     try:
         addr = address.lower().strip()
+        if node_registry is not None and not _address_visible(addr):
+            return jsonify({'success': False, 'error': 'Slot not found'}), 404
         with _locked_slots_lock:
             body = request.get_json(silent=True) or {}
             _locked_slots[addr] = {
@@ -3266,6 +5411,8 @@ def unlock_slot(address):
     """Unlock a previously pinned slot — restores normal TTL expiration."""
     try:
         addr = address.lower().strip()
+        if node_registry is not None and not _address_visible(addr):
+            return jsonify({'success': False, 'error': 'Slot not found'}), 404
         with _locked_slots_lock:
             removed = _locked_slots.pop(addr, None)
         if _socketio:
@@ -3313,7 +5460,8 @@ def evict_slot(address):
                 target_slot = row.get('slots', {}).get(pos_str)
                 break
 
-        if not target_slot or not target_slot.get('node_id'):
+        if (not target_slot or not target_slot.get('node_id')
+                or not _slot_visible(target_slot.get('slot_id', ''))):
             return jsonify({'success': False, 'error': f'No snippet in slot {addr.upper()}'}), 404
 
         node_id = target_slot['node_id']
@@ -3406,6 +5554,8 @@ def slot_info(address):
         if not slot_data or not slot_data.get('node_id'):
             return jsonify({'success': True, 'address': addr.upper(),
                             'occupied': False, 'message': 'Slot is empty'})
+        if not _slot_visible(slot_data.get('slot_id', '')):
+            return jsonify({'success': False, 'error': 'Slot not found'}), 404
 
         nid = slot_data.get('node_id', '')
         staging_id = nid.replace('snippet-', '') if nid.startswith('snippet-') else None
@@ -3433,7 +5583,7 @@ def slot_info(address):
         # Full staging snippet
         snippet_details = {}
         if staging_id and staging_pipeline:
-            sn = staging_pipeline.get_snippet(staging_id)
+            sn = staging_pipeline.get_snippet(staging_id, _namespace())
             if sn:
                 snippet_details = sn.to_dict()
 
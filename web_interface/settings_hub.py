@@ -189,6 +189,391 @@ SETTINGS_MANIFEST: Dict[str, Dict[str, Any]] = {
         'type': 'number',
         'restart': False,
     },
+    # ── Staging ──────────────────────────────────────────────────────
+    'spec_concurrency': {
+        'env': 'SPOKEDPY_SPEC_CONCURRENCY',
+        'default': '4',
+        'label': 'Max concurrent speculative executions per batch',
+        'group': 'staging',
+        'type': 'number',
+        'restart': False,
+    },
+    'execution_timeout': {
+        'env': 'SPOKEDPY_EXECUTION_TIMEOUT',
+        'default': '10',
+        'label': 'Hard per-snippet execution deadline (seconds)',
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'snippet_max_cpu_time': {
+        'env': 'SPOKEDPY_SNIPPET_MAX_CPU_TIME',
+        'default': '0',
+        'label': 'CPU seconds a Go snippet process may use (0 = unlimited)',
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'snippet_max_memory_bytes': {
+        'env': 'SPOKEDPY_SNIPPET_MAX_MEMORY_BYTES',
+        'default': '0',
+        'label': 'Address space a Go snippet process may map, in bytes (0 = unlimited)',
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'snippet_max_output_bytes': {
+        'env': 'SPOKEDPY_SNIPPET_MAX_OUTPUT_BYTES',
+        'default': '0',
+        'label': 'Bytes of stdout / stderr each kept from a snippet run (0 = unlimited)',
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'label_policy': {
+        'env': 'SPOKEDPY_LABEL_POLICY',
+        'default': 'overwrite',
+        'label': 'Default label conflict policy (reject / overwrite / version_suffix)',
+        'group': 'staging',
+        'type': 'string',
+        'restart': True,
+    },
+    'webhook_log': {
+        'env': 'SPOKEDPY_WEBHOOK_LOG',
+        'default': os.path.join(os.path.dirname(os.path.dirname(__file__)), 'data', 'webhook_deliveries.jsonl'),
+        'label': 'Webhook delivery log path',
+        'group': 'staging',
+        'type': 'path',
+        'restart': True,
+    },
+    'snippet_index_db': {
+        'env': 'SPOKEDPY_SNIPPET_INDEX_DB',
+        'default': os.path.join(os.path.dirname(os.path.dirname(__file__)), 'data', 'snippet_index.db'),
+        'label': 'SQLite database of snippet metadata records (query API)',
+        'group': 'staging',
+        'type': 'path',
+        'restart': True,
+    },
+    'webhook_max_retries': {
+        'env': 'SPOKEDPY_WEBHOOK_MAX_RETRIES',
+        'default': '5',
+        'label': 'Webhook delivery retries before dead-lettering',
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'lint_gate': {
+        'env': 'SPOKEDPY_LINT_GATE',
+        'default': '1',
+        'label': 'Run go vet analyzers before promoting Go snippets (0/1)',
+        'group': 'staging',
+        'type': 'boolean',
+        'restart': True,
+    },
+    'lint_allow_unavailable': {
+        'env': 'SPOKEDPY_LINT_ALLOW_UNAVAILABLE',
+        'default': '0',
+        'label': 'Let the lint gate pass when an analyzer cannot run (0/1)',
+        'group': 'staging',
+        'type': 'boolean',
+        'restart': True,
+    },
+    'coverage_gate': {
+        'env': 'SPOKEDPY_COVERAGE_GATE',
+        'default': '0',
+        'label': "Measure Go snippets' Test functions with go test -cover (0/1)",
+        'group': 'staging',
+        'type': 'boolean',
+        'restart': True,
+    },
+    'coverage_min_percent': {
+        'env': 'SPOKEDPY_COVERAGE_MIN_PERCENT',
+        'default': '80',
+        'label': 'Statement coverage a Go snippet with tests needs to pass (coverage_gate = 1)',
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'benchmark_on_promote': {
+        'env': 'SPOKEDPY_BENCHMARK_ON_PROMOTE',
+        'default': '0',
+        'label': "Run Go snippets' Benchmark functions with go test -bench -benchtime=3s (0/1)",
+        'group': 'staging',
+        'type': 'boolean',
+        'restart': True,
+    },
+    'benchmark_regression_gate': {
+        'env': 'SPOKEDPY_BENCHMARK_REGRESSION_GATE',
+        'default': '0',
+        'label': 'Percent a benchmark may slow down against the live version before promotion is blocked (0 = off)',
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'result_cache_ttl': {
+        'env': 'SPOKEDPY_RESULT_CACHE_TTL',
+        'default': '300',
+        'label': "Seconds a deterministic snippet's speculation is served from the result cache (0 = off)",
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'result_cache_max_entries': {
+        'env': 'SPOKEDPY_RESULT_CACHE_MAX_ENTRIES',
+        'default': '1000',
+        'label': 'Speculation results the cache keeps before evicting the least recently used',
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'mutation_test': {
+        'env': 'SPOKEDPY_MUTATION_TEST',
+        'default': '0',
+        'label': "Run Go snippets' Test functions against mutants of their source (0/1)",
+        'group': 'staging',
+        'type': 'boolean',
+        'restart': True,
+    },
+    'mutation_max_mutations': {
+        'env': 'SPOKEDPY_MUTATION_MAX_MUTATIONS',
+        'default': '20',
+        'label': "Mutants a Go snippet's tests are run against at most (mutation_test = 1)",
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'mutation_threshold': {
+        'env': 'SPOKEDPY_MUTATION_THRESHOLD',
+        'default': '0.25',
+        'label': 'Fraction of mutants that may survive the tests before the run is WEAK_SPEC (mutation_test = 1)',
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'go_format_on_stage': {
+        'env': 'SPOKEDPY_GO_FORMAT_ON_STAGE',
+        'default': '0',
+        'label': 'Run Go snippets through gofmt before hashing and staging them (0/1)',
+        'group': 'staging',
+        'type': 'boolean',
+        'restart': True,
+    },
+    'circuit_failure_threshold': {
+        'env': 'SPOKEDPY_CIRCUIT_FAILURE_THRESHOLD',
+        'default': '3',
+        'label': 'Consecutive failed runs that open a snippet\'s circuit breaker (0 = off)',
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'circuit_window': {
+        'env': 'SPOKEDPY_CIRCUIT_WINDOW',
+        'default': '300',
+        'label': 'Seconds within which the failures must fall to open the circuit',
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'circuit_recovery_delay': {
+        'env': 'SPOKEDPY_CIRCUIT_RECOVERY_DELAY',
+        'default': '60',
+        'label': 'Seconds an open circuit waits before allowing one trial run',
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'spec_workers': {
+        'env': 'SPOKEDPY_SPEC_WORKERS',
+        'default': '4',
+        'label': 'Background workers draining the async speculation queue',
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'spec_max_queue_depth': {
+        'env': 'SPOKEDPY_SPEC_MAX_QUEUE_DEPTH',
+        'default': '100',
+        'label': 'Max pending jobs in the async speculation queue',
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'spec_queue_block': {
+        'env': 'SPOKEDPY_SPEC_QUEUE_BLOCK',
+        'default': '0',
+        'label': 'Block /api/staging/enqueue when the queue is full instead of 429 (0/1)',
+        'group': 'staging',
+        'type': 'boolean',
+        'restart': True,
+    },
+    'grpc_address': {
+        'env': 'SPOKEDPY_GRPC_ADDRESS',
+        'default': '',
+        'label': 'gRPC SnippetService listen address (empty = disabled)',
+        'group': 'staging',
+        'type': 'string',
+        'restart': True,
+    },
+    'grpc_tokens': {
+        'env': 'SPOKEDPY_GRPC_TOKENS',
+        'default': '',
+        'label': 'Comma-separated bearer tokens accepted by the gRPC service',
+        'group': 'staging',
+        'type': 'string',
+        'restart': True,
+    },
+    'grpc_tls_cert': {
+        'env': 'SPOKEDPY_GRPC_TLS_CERT',
+        'default': '',
+        'label': 'gRPC TLS certificate (PEM path)',
+        'group': 'staging',
+        'type': 'path',
+        'restart': True,
+    },
+    'grpc_tls_key': {
+        'env': 'SPOKEDPY_GRPC_TLS_KEY',
+        'default': '',
+        'label': 'gRPC TLS private key (PEM path)',
+        'group': 'staging',
+        'type': 'path',
+        'restart': True,
+    },
+    'namespace_admin_credential': {
+        'env': 'SPOKEDPY_NAMESPACE_ADMIN_CREDENTIAL',
+        'default': '',
+        'label': 'Admin credential for cross-namespace snippet access (empty = disabled)',
+        'group': 'staging',
+        'type': 'string',
+        'restart': True,
+    },
+    'namespace_credentials': {
+        'env': 'SPOKEDPY_NAMESPACE_CREDENTIALS',
+        'default': '',
+        'label': 'Comma-separated namespace=credential pairs callers present to use a namespace',
+        'group': 'staging',
+        'type': 'secret',
+        'restart': True,
+    },
+    'staging_id_prefix': {
+        'env': 'SPOKEDPY_STAGING_ID_PREFIX',
+        'default': 'stg-',
+        'label': 'Prefix of new staging IDs (e.g. team-alpha/stg-)',
+        'group': 'staging',
+        'type': 'string',
+        'restart': True,
+    },
+    'archive_interval': {
+        'env': 'SPOKEDPY_ARCHIVE_INTERVAL',
+        'default': '0',
+        'label': 'Seconds between archival sweeps of old promotions (0 = disabled)',
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'archive_max_age': {
+        'env': 'SPOKEDPY_ARCHIVE_MAX_AGE',
+        'default': '0',
+        'label': 'Archive promotions older than this many seconds (0 = no limit)',
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'archive_max_versions': {
+        'env': 'SPOKEDPY_ARCHIVE_MAX_VERSIONS',
+        'default': '0',
+        'label': 'Promotions kept per label before older ones are archived (0 = no limit)',
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'archive_max_slot_bytes': {
+        'env': 'SPOKEDPY_ARCHIVE_MAX_SLOT_BYTES',
+        'default': '0',
+        'label': 'Source bytes a slot keeps across its promotions (0 = no limit)',
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'archive_action': {
+        'env': 'SPOKEDPY_ARCHIVE_ACTION',
+        'default': 'move',
+        'label': 'What archiving does: move (to archive_dir) or delete',
+        'group': 'staging',
+        'type': 'string',
+        'restart': True,
+    },
+    'archive_dir': {
+        'env': 'SPOKEDPY_ARCHIVE_DIR',
+        'default': os.path.join(os.path.dirname(os.path.dirname(__file__)), 'data', 'snippets_archive'),
+        'label': 'Cold-storage directory archived snippet files move to',
+        'group': 'staging',
+        'type': 'path',
+        'restart': True,
+    },
+    'rerun_interval': {
+        'env': 'SPOKEDPY_RERUN_INTERVAL',
+        'default': '60',
+        'label': 'Seconds between checks for due scheduled reruns (0 = disabled)',
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'manifest_dir': {
+        'env': 'SPOKEDPY_MANIFEST_DIR',
+        'default': '',
+        'label': 'Manifest source directory',
+        'group': 'paths',
+        'type': 'path',
+        'restart': True,
+    },
+    'age_identity_file': {
+        'env': 'SPOKEDPY_AGE_IDENTITY_FILE',
+        'default': '',
+        'label': 'age identity file',
+        'group': 'paths',
+        'type': 'path',
+        'restart': True,
+    },
+    'rerun_history_size': {
+        'env': 'SPOKEDPY_RERUN_HISTORY_SIZE',
+        'default': '100',
+        'label': 'Scheduled reruns kept per promoted snippet',
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'lock_backend': {
+        'env': 'SPOKEDPY_LOCK_BACKEND',
+        'default': 'local',
+        'label': 'Slot promotion lock backend (local / redis / etcd)',
+        'group': 'staging',
+        'type': 'string',
+        'restart': True,
+    },
+    'lock_url': {
+        'env': 'SPOKEDPY_LOCK_URL',
+        'default': '',
+        'label': 'Lock backend URL (redis://… or etcd://host:port)',
+        'group': 'staging',
+        'type': 'url',
+        'restart': True,
+    },
+    'promotion_lock_ttl': {
+        'env': 'SPOKEDPY_PROMOTION_LOCK_TTL',
+        'default': '30',
+        'label': 'Seconds a slot promotion lock lasts if never released',
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
+    'promotion_lock_wait': {
+        'env': 'SPOKEDPY_PROMOTION_LOCK_WAIT',
+        'default': '10',
+        'label': 'Seconds a promotion waits for its slot lock',
+        'group': 'staging',
+        'type': 'number',
+        'restart': True,
+    },
     # ── AI Agent ─────────────────────────────────────────────────────
     'ai_endpoint': {
         'env': 'SPOKEDPY_AI_ENDPOINT',
@@ -277,6 +662,7 @@ SETTING_GROUPS = [
     {'key': 'paths',   'label': 'Paths & Storage',   'icon': 'folder'},
     {'key': 'server',  'label': 'Server',             'icon': 'server'},
     {'key': 'marshal', 'label': 'Marshal Tokens',     'icon': 'key'},
+    {'key': 'staging', 'label': 'Staging Pipeline',   'icon': 'layers'},
     {'key': 'ai',      'label': 'AI Agent',           'icon': 'bot'},
     {'key': 'canvas',  'label': 'Canvas & Visuals',   'icon': 'layout'},
 ]
@@ -0,0 +1,509 @@
+"""
+Snippet REST API (v1) — resource-oriented HTTP layer over the staging pipeline.
+
+The /api/staging/* routes mirror the pipeline's phases one call at a
+time; this blueprint exposes snippets as resources instead:
+
+    POST   /api/v1/snippets/stage               queue (+ speculate, + promote)
+    GET    /api/v1/snippets                     search, cursor-paginated
+    GET    /api/v1/snippets/{id}                one snippet
+    DELETE /api/v1/snippets/{id}                withdraw an unpromoted snippet
+    POST   /api/v1/snippets/{id}/promote
+    POST   /api/v1/snippets/{id}/rollback
+    POST   /api/v1/snippets/{id}/approve        sign off a promotion on a protected slot
+    POST   /api/v1/snippets/{id}/reject
+    GET    /api/v1/snippets/{id}/stream         WebSocket: live stdout / stderr of the run
+    GET    /api/v1/openapi.yaml                 snippet_api.yaml, as shipped
+
+Bodies are validated against the request types in snippet_api_types
+before the pipeline is called.  Every error is
+    { success: false, error_code, error, details? }
+and GET responses carry an ETag; a matching If-None-Match gets 304.
+Requests act inside their X-SpokedPy-Namespace once
+X-SpokedPy-Namespace-Credential proves membership (see runtime.py); other
+namespaces' snippets are 404 unless X-SpokedPy-Admin-Credential is sent.
+
+Usage:
+    from web_interface.snippet_api import register_snippet_api
+    register_snippet_api(app)
+"""
+
+import json
+
+from flask import Blueprint, Response, jsonify, request, make_response, url_for
+from werkzeug.exceptions import HTTPException
+
+from visual_editor_core.snippet_staging import StagingPhase
+from visual_editor_core.snippet_lint import LintFailedError
+from visual_editor_core.snippet_bench import BenchmarkRegressionError
+from visual_editor_core.snippet_breaker import CircuitOpenError
+from visual_editor_core.snippet_lock import LockUnavailableError
+from visual_editor_core.snippet_format import FormatFailedError
+from visual_editor_core.snippet_canary import CanaryConfig
+from visual_editor_core.snippet_approvals import ApprovalError, PendingApprovalError
+from visual_editor_core.snippet_swap import GracefulSwap
+from visual_editor_core.snippet_tags import TagsImmutableError
+from visual_editor_core.snippet_query import SnippetFilter
+from visual_editor_core.snippet_stream import CloseCode
+from visual_editor_core.snippet_namespace import validate_namespace
+from visual_editor_core.snippet_params import ParameterSchemaError
+from visual_editor_core.snippet_credentials import CredentialDetectedError
+from web_interface.snippet_api_types import (
+    ApiError, StageRequest, PromoteRequest, RollbackRequest, DeleteRequest,
+    ApproveRequest, RejectRequest, RetagRequest, OPENAPI_PATH, compute_etag, etag_matches,
+    invalid,
+)
+
+snippet_api_bp = Blueprint('snippet_api', __name__, url_prefix='/api/v1')
+
+# ─────────────────────────────────────────────────────────────────────
+# Helpers
+# ─────────────────────────────────────────────────────────────────────
+
+def _pipeline():
+    from web_interface import runtime
+    if runtime.staging_pipeline is None:
+        raise ApiError(503, 'pipeline_unavailable', 'Staging pipeline not initialized')
+    return runtime.staging_pipeline
+
+
+def _namespaces(pipeline):
+    """(namespace new snippets go to, namespace visible — None for an admin)."""
+    from web_interface.runtime import (
+        NAMESPACE_HEADER, NAMESPACE_CREDENTIAL_HEADER, ADMIN_CREDENTIAL_HEADER,
+    )
+    try:
+        stage_namespace = validate_namespace(request.headers.get(NAMESPACE_HEADER))
+    except ValueError as ve:
+        raise invalid(str(ve), header=NAMESPACE_HEADER)
+    credential = request.headers.get(ADMIN_CREDENTIAL_HEADER)
+    if credential is not None:
+        if not pipeline.check_admin_credential(credential):
+            raise ApiError(403, 'forbidden', 'Invalid namespace admin credential')
+        return stage_namespace, None
+    if not pipeline.check_namespace_credential(
+            stage_namespace, request.headers.get(NAMESPACE_CREDENTIAL_HEADER)):
+        raise ApiError(401, 'unauthenticated',
+                       f"Namespace '{stage_namespace}' needs a valid {NAMESPACE_CREDENTIAL_HEADER}")
+    return stage_namespace, stage_namespace
+
+
+def _body(request_type):
+    """Decode and validate the JSON body (an absent body is `{}`)."""
+    if not request.get_data():
+        return request_type.from_json({})
+    data = request.get_json(silent=True)
+    if data is None:
+        raise ApiError(400, 'invalid_json', 'Request body is not valid JSON')
+    return request_type.from_json(data)
+
+
+def _circuit_open(err: CircuitOpenError) -> ApiError:
+    return ApiError(503, 'circuit_open', str(err), {'retry_at': err.retry_at or None})
+
+
+def _snippet_or_404(pipeline, staging_id: str, namespace):
+    snippet = pipeline.get_snippet(staging_id, namespace)
+    if snippet is None:
+        raise ApiError(404, 'not_found', f"No snippet with id '{staging_id}'")
+    return snippet
+
+
+def _conditional(body, etag: str):
+    """`body` with an ETag; 304 if the client's If-None-Match already has it."""
+    if etag_matches(request.headers.get('If-None-Match'), etag):
+        response = make_response('', 304)
+    else:
+        response = make_response(body, 200)
+    response.headers['ETag'] = etag
+    return response
+
+
+def _cached_json(payload):
+    return _conditional(jsonify(payload), compute_etag(payload))
+
+
+@snippet_api_bp.errorhandler(ApiError)
+def _api_error(err: ApiError):
+    return jsonify(err.to_dict()), err.status
+
+
+@snippet_api_bp.errorhandler(Exception)
+def _unexpected_error(err: Exception):
+    if isinstance(err, HTTPException):
+        return jsonify({'success': False, 'error_code': 'invalid_request',
+                        'error': err.description}), err.code
+    return jsonify({'success': False, 'error_code': 'internal_error', 'error': str(err)}), 500
+
+
+# ─────────────────────────────────────────────────────────────────────
+# Routes
+# ─────────────────────────────────────────────────────────────────────
+
+@snippet_api_bp.route('/snippets/stage', methods=['POST'])
+def stage_snippet():
+    """Queue a snippet, run it speculatively and optionally promote it.
+
+    Body (StageRequest): { code, language | engine_letter, label?, label_policy?,
+                           parameters?, arguments?, env?, requires?, output_schema?, tags?,
+                           deterministic?, test_cases?, speculate?=true, auto_promote?=false,
+                           skip_lint?, suppress_credential_check? }
+    201 with the snippet and a Location header.
+    """
+    pipeline = _pipeline()
+    stage_namespace, _ = _namespaces(pipeline)
+    req = _body(StageRequest)
+    try:
+        snippet = pipeline.queue_snippet(req.engine_letter, req.language, req.code, req.label,
+                                         label_policy=req.label_policy or None,
+                                         parameters=req.parameters or None,
+                                         namespace=stage_namespace, env=req.env or None,
+                                         requires=req.requires or None,
+                                         output_schema=req.output_schema or None,
+                                         tags=req.tags or None,
+                                         deterministic=req.deterministic,
+                                         test_cases=req.test_cases or None,
+                                         suppress_credential_check=req.suppress_credential_check)
+        if req.speculate:
+            try:
+                snippet = pipeline.speculate(snippet.staging_id, arguments=req.arguments or None)
+            except CircuitOpenError:
+                pipeline.verdict(snippet.staging_id, 'reject', 'Circuit breaker open')
+                raise
+            if req.auto_promote and snippet.phase == StagingPhase.PASSED:
+                snippet = pipeline.promote(snippet.staging_id, skip_lint=req.skip_lint)
+    except CircuitOpenError as co:
+        raise _circuit_open(co)
+    except LintFailedError as le:
+        raise ApiError(422, 'lint_failed', str(le), {'lint': le.result.to_dict()})
+    except BenchmarkRegressionError as be:
+        raise ApiError(422, 'benchmark_regressed', str(be),
+                       {'regressions': [r.to_dict() for r in be.regressions]})
+    except FormatFailedError as fe:
+        raise ApiError(422, 'format_failed', str(fe),
+                       {'diagnostics': [d.to_dict() for d in fe.diagnostics]})
+    except ParameterSchemaError as pe:
+        raise invalid(str(pe), field='arguments', errors=pe.error_dicts())
+    except CredentialDetectedError as cd:
+        raise invalid(str(cd), field='code', findings=[x.to_dict() for x in cd.findings])
+    except ValueError as ve:
+        raise invalid(str(ve))
+
+    payload = {'success': True, 'snippet': snippet.to_dict()}
+    response = make_response(jsonify(payload), 201)
+    response.headers['Location'] = url_for('snippet_api.get_snippet',
+                                           staging_id=snippet.staging_id)
+    response.headers['ETag'] = compute_etag(payload)
+    return response
+
+
+@snippet_api_bp.route('/snippets', methods=['GET'])
+def list_snippets():
+    """Search staged and promoted snippets, oldest first.
+
+    Query: ?language=&label=&slot=&spec_result=&tags=&limit=50&page_token=&include_archived=
+    `tags` is a tag query ('math/** AND pure', see snippet_tags).
+    Supports If-None-Match.
+    """
+    pipeline = _pipeline()
+    _, namespace = _namespaces(pipeline)
+    args = request.args
+    try:
+        page = pipeline.query(SnippetFilter(
+            language=args.get('language', ''),
+            label=args.get('label', ''),
+            slot=args.get('slot', '').lower(),
+            spec_result=args.get('spec_result', ''),
+            tags=args.get('tags', ''),
+            limit=int(args.get('limit', 50)),
+            page_token=args.get('page_token', ''),
+            include_archived=args.get('include_archived', '') in ('1', 'true'),
+        ), namespace)
+    except ValueError as ve:
+        raise invalid(str(ve))
+    return _cached_json({'success': True, **page.to_dict()})
+
+
+@snippet_api_bp.route('/snippets/<path:staging_id>', methods=['GET'])
+def get_snippet(staging_id):
+    """Fetch one snippet.  Supports If-None-Match."""
+    pipeline = _pipeline()
+    _, namespace = _namespaces(pipeline)
+    snippet = _snippet_or_404(pipeline, staging_id, namespace)
+    return _cached_json({'success': True, 'snippet': snippet.to_dict()})
+
+
+@snippet_api_bp.route('/snippets/<path:staging_id>/diff', methods=['GET'])
+def diff_snippet(staging_id):
+    """Preview a staged snippet's source against its label's production version.
+
+    409 if the snippet has already been promoted (or retired).
+    """
+    pipeline = _pipeline()
+    _, namespace = _namespaces(pipeline)
+    _snippet_or_404(pipeline, staging_id, namespace)
+    try:
+        result = pipeline.diff_against_production(staging_id, namespace)
+    except ValueError as ve:
+        raise ApiError(409, 'invalid_state', str(ve))
+    return jsonify({'success': True, 'diff': result.to_dict()})
+
+
+@snippet_api_bp.route('/snippets/<path:staging_id>', methods=['DELETE'])
+def delete_snippet(staging_id):
+    """Withdraw a snippet that has not been promoted (204).
+
+    Body (DeleteRequest, optional): { reason? }
+    Promoted snippets must be rolled back instead (409).
+    """
+    pipeline = _pipeline()
+    _, namespace = _namespaces(pipeline)
+    req = _body(DeleteRequest)
+    snippet = _snippet_or_404(pipeline, staging_id, namespace)
+    if snippet.phase not in (StagingPhase.QUEUED, StagingPhase.PASSED, StagingPhase.FAILED):
+        raise ApiError(409, 'invalid_state',
+                       f"Snippet {staging_id} is '{snippet.phase.value}' and cannot be withdrawn",
+                       {'phase': snippet.phase.value})
+    try:
+        pipeline.verdict(staging_id, 'reject', req.reason or 'Withdrawn via REST API',
+                         namespace=namespace)
+    except ValueError as ve:
+        raise ApiError(409, 'invalid_state', str(ve))
+    return '', 204
+
+
+@snippet_api_bp.route('/snippets/<path:staging_id>/promote', methods=['POST'])
+def promote_snippet(staging_id):
+    """Promote a PASSED snippet into its registry slot.
+
+    Body (PromoteRequest, optional): { skip_lint?, canary?, graceful_swap?, dry_run?,
+                                       arguments? }
+    With `canary` (CanaryConfig fields) the snippet comes back in the
+    CANARY phase and is promoted gradually; with `graceful_swap`
+    (drain_timeout) it is PENDING_SWAP until the live version's in-flight
+    executions have finished.  With `dry_run` nothing is
+    promoted: 200 with the DryRunReport of every gate (`would_succeed`).
+    202 with the pending `approval` when the slot requires approvals.
+    409 if the snippet is not PASSED or a label it requires was promoted
+    again after it was staged, or (`slot_locked`) while another promotion
+    holds the slot; 422 if the lint gate rejects it;
+    503 while the circuit breaker for its code is open.
+    """
+    pipeline = _pipeline()
+    _, namespace = _namespaces(pipeline)
+    req = _body(PromoteRequest)
+    _snippet_or_404(pipeline, staging_id, namespace)
+    if req.dry_run:
+        try:
+            report = pipeline.dry_run_promote(staging_id, skip_lint=req.skip_lint,
+                                              arguments=req.arguments or None,
+                                              namespace=namespace)
+        except ValueError as ve:
+            raise ApiError(409, 'invalid_state', str(ve))
+        return jsonify({'success': True, 'dry_run': report.to_dict()})
+    try:
+        canary = CanaryConfig.from_dict(req.canary) if req.canary else None
+    except (TypeError, ValueError) as exc:
+        raise invalid(f"Invalid canary: {exc}", field='canary')
+    try:
+        swap = GracefulSwap.from_dict(req.graceful_swap) if req.graceful_swap else None
+    except (TypeError, ValueError) as exc:
+        raise invalid(f"Invalid graceful_swap: {exc}", field='graceful_swap')
+    try:
+        snippet = pipeline.promote(staging_id, skip_lint=req.skip_lint, canary=canary,
+                                   namespace=namespace, graceful_swap=swap)
+    except CircuitOpenError as co:
+        raise _circuit_open(co)
+    except LintFailedError as le:
+        raise ApiError(422, 'lint_failed', str(le), {'lint': le.result.to_dict()})
+    except BenchmarkRegressionError as be:
+        raise ApiError(422, 'benchmark_regressed', str(be),
+                       {'regressions': [r.to_dict() for r in be.regressions]})
+    except PendingApprovalError as pa:
+        return jsonify({'success': True, 'approval': pa.record.to_dict(),
+                        'snippet': pipeline.get_snippet(staging_id, namespace).to_dict()}), 202
+    except LockUnavailableError as lu:
+        raise ApiError(409, 'slot_locked', str(lu), {'resource': lu.resource})
+    except ValueError as ve:
+        raise ApiError(409, 'invalid_state', str(ve))
+    return jsonify({'success': True, 'snippet': snippet.to_dict()})
+
+
+@snippet_api_bp.route('/snippets/<path:staging_id>/approve', methods=['POST'])
+def approve_promotion(staging_id):
+    """Approve a promotion that is waiting on a protected slot.
+
+    Body (ApproveRequest): { approver_id }
+    The approval that completes the record promotes the snippet (the
+    same 409 / 422 / 503 as /promote if that fails).  409 if no approval
+    is pending or this approver has already given theirs.
+    """
+    pipeline = _pipeline()
+    _, namespace = _namespaces(pipeline)
+    req = _body(ApproveRequest)
+    _snippet_or_404(pipeline, staging_id, namespace)
+    try:
+        record = pipeline.approve_promotion(staging_id, req.approver_id, namespace=namespace)
+    except CircuitOpenError as co:
+        raise _circuit_open(co)
+    except LintFailedError as le:
+        raise ApiError(422, 'lint_failed', str(le), {'lint': le.result.to_dict()})
+    except LockUnavailableError as lu:
+        raise ApiError(409, 'slot_locked', str(lu), {'resource': lu.resource})
+    except ValueError as ve:
+        raise ApiError(409, 'invalid_state', str(ve))
+    return jsonify({'success': True, 'approval': record.to_dict(),
+                    'snippet': pipeline.get_snippet(staging_id, namespace).to_dict()})
+
+
+@snippet_api_bp.route('/snippets/<path:staging_id>/reject', methods=['POST'])
+def reject_promotion(staging_id):
+    """Reject a promotion that is waiting on a protected slot; the snippet is REJECTED.
+
+    Body (RejectRequest): { approver_id, reason? }
+    """
+    pipeline = _pipeline()
+    _, namespace = _namespaces(pipeline)
+    req = _body(RejectRequest)
+    _snippet_or_404(pipeline, staging_id, namespace)
+    try:
+        record = pipeline.reject_promotion(staging_id, req.approver_id, req.reason,
+                                           namespace=namespace)
+    except ApprovalError as ae:
+        raise ApiError(409, 'invalid_state', str(ae))
+    return jsonify({'success': True, 'approval': record.to_dict(),
+                    'snippet': pipeline.get_snippet(staging_id, namespace).to_dict()})
+
+
+@snippet_api_bp.route('/snippets/<path:staging_id>/tags', methods=['PUT'])
+def retag_snippet(staging_id):
+    """Replace a snippet's tags.
+
+    Body (RetagRequest): { tags }
+    403 once the snippet has been promoted, unless the caller holds the
+    namespace admin credential.
+    """
+    pipeline = _pipeline()
+    _, namespace = _namespaces(pipeline)
+    req = _body(RetagRequest)
+    _snippet_or_404(pipeline, staging_id, namespace)
+    try:
+        snippet = pipeline.retag(staging_id, req.tags, namespace=namespace)
+    except TagsImmutableError as te:
+        raise ApiError(403, 'forbidden', str(te))
+    except ValueError as ve:
+        raise invalid(str(ve), field='tags')
+    return jsonify({'success': True, 'snippet': snippet.to_dict()})
+
+
+@snippet_api_bp.route('/snippets/<path:staging_id>/rollback', methods=['POST'])
+def rollback_snippet(staging_id):
+    """Roll a promoted snippet back; the prior version of its label is re-installed.
+
+    Body (RollbackRequest, optional): { reason? }
+    """
+    pipeline = _pipeline()
+    _, namespace = _namespaces(pipeline)
+    req = _body(RollbackRequest)
+    _snippet_or_404(pipeline, staging_id, namespace)
+    try:
+        snippet = pipeline.rollback(staging_id, req.reason, namespace=namespace)
+    except LockUnavailableError as lu:
+        raise ApiError(409, 'slot_locked', str(lu), {'resource': lu.resource})
+    except ValueError as ve:
+        raise ApiError(409, 'invalid_state', str(ve))
+    restored = (pipeline.get_snippet(snippet.rolled_back_to, namespace)
+                if snippet.rolled_back_to else None)
+    return jsonify({
+        'success': True,
+        'snippet': snippet.to_dict(),
+        'restored': restored.to_dict() if restored else None,
+    })
+
+
+@snippet_api_bp.route('/snippets/<path:staging_id>/stream', methods=['GET'])
+def stream_snippet(staging_id):
+    """WebSocket: follow a snippet's speculative run as it produces output.
+
+    Query: after_seq? — resume after the last frame already received.
+    Each message is one frame, { seq, stream: 'stdout'|'stderr', data,
+    timestamp }, in `seq` order.  A finished run is replayed from its
+    buffered output.  The socket closes when the run ends with a
+    CloseCode (4000 PASS, 4001 FAIL, 4002 TIMEOUT, 4003 LINT_FAIL,
+    4004 RESOURCE_EXCEEDED, 4005 SCHEMA_FAIL, 4006 COVERAGE_FAIL,
+    4007 BENCH_REGRESSION, 4008 WEAK_SPEC, 4009 CASE_FAIL) whose reason is
+    JSON { spec_result, spec_time, truncated }.
+    426 without a WebSocket upgrade.
+    """
+    pipeline = _pipeline()
+    _, namespace = _namespaces(pipeline)
+    _snippet_or_404(pipeline, staging_id, namespace)
+    if request.headers.get('Upgrade', '').lower() != 'websocket':
+        raise ApiError(426, 'upgrade_required', 'This endpoint only speaks WebSocket')
+    try:
+        after_seq = int(request.args.get('after_seq', 0))
+    except ValueError:
+        raise invalid("Query parameter 'after_seq' must be an integer", field='after_seq')
+    try:
+        from simple_websocket import Server, ConnectionClosed
+    except ImportError:
+        raise ApiError(501, 'not_implemented', 'WebSocket support needs simple-websocket')
+
+    stream = pipeline.output_stream(staging_id, namespace)
+    ws = Server(request.environ)
+    try:
+        for frame in stream.follow(after_seq):
+            if not ws.connected:
+                break
+            if frame is not None:
+                ws.send(json.dumps(frame.to_dict()))
+                continue
+            # Idle: a snippet withdrawn before it ever ran won't close its stream
+            snippet = pipeline.get_snippet(staging_id, namespace)
+            if snippet is None:
+                stream.close('FAIL', 0.0)
+            elif snippet.phase not in (StagingPhase.QUEUED, StagingPhase.SPECULATING):
+                stream.close(snippet.spec_result.value, snippet.spec_execution_time,
+                             snippet.spec_output, snippet.spec_error)
+        if ws.connected:
+            ws.close(int(stream.close_code or CloseCode.FAIL), json.dumps(stream.close_info()))
+    except ConnectionClosed:
+        pass
+    return _WebSocketResponse(ws)
+
+
+class _WebSocketResponse(Response):
+    """What a view returns once it has taken over the socket (as flask-sock does)."""
+
+    def __init__(self, ws):
+        super().__init__()
+        self._ws = ws
+
+    def __call__(self, environ, start_response):
+        # The server must not write an HTTP response onto the upgraded socket
+        if self._ws.mode == 'werkzeug':
+            raise ConnectionError()
+        if self._ws.mode == 'gunicorn':
+            raise StopIteration()
+        return []
+
+
+@snippet_api_bp.route('/openapi.yaml', methods=['GET'])
+def openapi_yaml():
+    """OpenAPI 3 description of this API (YAML)."""
+    with open(OPENAPI_PATH, 'r', encoding='utf-8') as f:
+        body = f.read()
+    response = _conditional(body, compute_etag(body))
+    if response.status_code == 200:
+        response.headers['Content-Type'] = 'application/yaml; charset=utf-8'
+    return response
+
+
+# ─────────────────────────────────────────────────────────────────────
+# Registration helper
+# ─────────────────────────────────────────────────────────────────────
+
+def register_snippet_api(app):
+    """Register the v1 snippet API blueprint."""
+    app.register_blueprint(snippet_api_bp)
+    print(f"  Snippet API:   /api/v1/snippets  (spec: /api/v1/openapi.yaml)")
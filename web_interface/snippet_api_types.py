@@ -0,0 +1,250 @@
+"""
+Request types and HTTP helpers for the v1 Snippet REST API.
+
+Each request body has a dataclass mirroring its schema in
+snippet_api.yaml (components/schemas); `from_json()` validates a decoded
+body against it — unknown fields, missing required fields and wrong
+types are rejected with an ApiError before the pipeline is touched.
+tests/test_snippet_api.py checks the dataclasses and the YAML agree.
+
+Kept free of Flask so the validation and ETag logic can be tested on
+their own.
+"""
+
+import os
+import json
+import hashlib
+from dataclasses import dataclass, field, fields
+from typing import Any, Dict, List, Optional
+
+
+OPENAPI_PATH = os.path.join(os.path.dirname(os.path.abspath(__file__)), 'snippet_api.yaml')
+
+
+class ApiError(Exception):
+    """An error response: HTTP status + machine-readable error_code."""
+
+    def __init__(self, status: int, error_code: str, message: str,
+                 details: Optional[Dict[str, Any]] = None):
+        super().__init__(message)
+        self.status = status
+        self.error_code = error_code
+        self.message = message
+        self.details = details or {}
+
+    def to_dict(self) -> Dict[str, Any]:
+        body = {'success': False, 'error_code': self.error_code, 'error': self.message}
+        if self.details:
+            body['details'] = self.details
+        return body
+
+
+def invalid(message: str, **details) -> ApiError:
+    return ApiError(400, 'invalid_request', message, details or None)
+
+
+# (python type, JSON type name) for field checks
+_JSON_TYPES = {
+    str:   (str, 'string'),
+    bool:  (bool, 'boolean'),
+    list:  (list, 'array'),
+    dict:  (dict, 'object'),
+}
+
+LABEL_POLICIES = ('reject', 'overwrite', 'version_suffix')
+
+
+class _RequestType:
+    """Base for request dataclasses: strict decoding from a JSON object."""
+
+    # field name → (python type, required)
+    _SCHEMA: Dict[str, tuple] = {}
+
+    @classmethod
+    def from_json(cls, data: Any):
+        if data is None:
+            data = {}
+        if not isinstance(data, dict):
+            raise invalid('Request body must be a JSON object')
+        unknown = sorted(set(data) - set(cls._SCHEMA))
+        if unknown:
+            raise invalid(f"Unknown field(s): {', '.join(unknown)}", fields=unknown)
+        values = {}
+        for name, (py_type, required) in cls._SCHEMA.items():
+            if name not in data or data[name] is None:
+                if required:
+                    raise invalid(f"Missing required field '{name}'", field=name)
+                continue
+            value = data[name]
+            expected, json_name = _JSON_TYPES[py_type]
+            if not isinstance(value, expected):
+                raise invalid(f"Field '{name}' must be a {json_name}", field=name)
+            values[name] = value
+        request = cls(**values)
+        request.validate()
+        return request
+
+    def validate(self):
+        """Cross-field checks beyond the per-field types."""
+
+
+@dataclass
+class StageRequest(_RequestType):
+    code: str
+    language: str = ''
+    engine_letter: str = ''
+    label: str = ''
+    label_policy: str = ''
+    parameters: List[Dict[str, Any]] = field(default_factory=list)
+    arguments: Dict[str, Any] = field(default_factory=dict)
+    env: Dict[str, str] = field(default_factory=dict)
+    requires: List[str] = field(default_factory=list)
+    output_schema: Dict[str, Any] = field(default_factory=dict)
+    tags: List[str] = field(default_factory=list)
+    deterministic: bool = False
+    test_cases: List[Dict[str, Any]] = field(default_factory=list)
+    speculate: bool = True
+    auto_promote: bool = False
+    skip_lint: bool = False
+    suppress_credential_check: bool = False
+
+    _SCHEMA = {
+        'code': (str, True),
+        'language': (str, False),
+        'engine_letter': (str, False),
+        'label': (str, False),
+        'label_policy': (str, False),
+        'parameters': (list, False),
+        'arguments': (dict, False),
+        'env': (dict, False),
+        'requires': (list, False),
+        'output_schema': (dict, False),
+        'tags': (list, False),
+        'deterministic': (bool, False),
+        'test_cases': (list, False),
+        'speculate': (bool, False),
+        'auto_promote': (bool, False),
+        'skip_lint': (bool, False),
+        'suppress_credential_check': (bool, False),
+    }
+
+    def validate(self):
+        if not self.code.strip():
+            raise invalid("Field 'code' must not be empty", field='code')
+        if not self.language and not self.engine_letter:
+            raise invalid("One of 'language' or 'engine_letter' is required")
+        if len(self.engine_letter) > 1:
+            raise invalid("Field 'engine_letter' must be a single letter", field='engine_letter')
+        if self.label_policy and self.label_policy not in LABEL_POLICIES:
+            raise invalid(f"Field 'label_policy' must be one of {', '.join(LABEL_POLICIES)}",
+                          field='label_policy')
+        if not all(isinstance(p, dict) for p in self.parameters):
+            raise invalid("Field 'parameters' must be an array of objects", field='parameters')
+        if not all(isinstance(v, str) for v in self.env.values()):
+            raise invalid("Field 'env' must map names to strings", field='env')
+        if not all(isinstance(r, str) for r in self.requires):
+            raise invalid("Field 'requires' must be an array of labels", field='requires')
+        if not all(isinstance(t, str) for t in self.tags):
+            raise invalid("Field 'tags' must be an array of tag paths", field='tags')
+        if self.auto_promote and not self.speculate:
+            raise invalid("'auto_promote' requires 'speculate'")
+
+
+@dataclass
+class PromoteRequest(_RequestType):
+    skip_lint: bool = False
+    canary: Dict[str, Any] = field(default_factory=dict)
+    graceful_swap: Dict[str, Any] = field(default_factory=dict)
+    dry_run: bool = False
+    arguments: Dict[str, Any] = field(default_factory=dict)
+
+    _SCHEMA = {'skip_lint': (bool, False), 'canary': (dict, False),
+               'graceful_swap': (dict, False), 'dry_run': (bool, False),
+               'arguments': (dict, False)}
+
+
+@dataclass
+class RollbackRequest(_RequestType):
+    reason: str = ''
+
+    _SCHEMA = {'reason': (str, False)}
+
+
+@dataclass
+class DeleteRequest(_RequestType):
+    reason: str = ''
+
+    _SCHEMA = {'reason': (str, False)}
+
+
+@dataclass
+class ApproveRequest(_RequestType):
+    approver_id: str = ''
+
+    _SCHEMA = {'approver_id': (str, True)}
+
+    def validate(self):
+        if not self.approver_id.strip():
+            raise invalid("Field 'approver_id' must not be empty", field='approver_id')
+
+
+@dataclass
+class RejectRequest(_RequestType):
+    approver_id: str = ''
+    reason: str = ''
+
+    _SCHEMA = {'approver_id': (str, True), 'reason': (str, False)}
+
+    def validate(self):
+        if not self.approver_id.strip():
+            raise invalid("Field 'approver_id' must not be empty", field='approver_id')
+
+
+@dataclass
+class RetagRequest(_RequestType):
+    tags: List[str] = field(default_factory=list)
+
+    _SCHEMA = {'tags': (list, True)}
+
+    def validate(self):
+        if not all(isinstance(t, str) for t in self.tags):
+            raise invalid("Field 'tags' must be an array of tag paths", field='tags')
+
+
+REQUEST_TYPES = {
+    'StageRequest': StageRequest,
+    'PromoteRequest': PromoteRequest,
+    'RollbackRequest': RollbackRequest,
+    'DeleteRequest': DeleteRequest,
+    'ApproveRequest': ApproveRequest,
+    'RejectRequest': RejectRequest,
+    'RetagRequest': RetagRequest,
+}
+
+
+def request_fields(request_type) -> List[str]:
+    return [f.name for f in fields(request_type)]
+
+
+# ── ETags ────────────────────────────────────────────────────────────
+
+def compute_etag(payload: Any) -> str:
+    """Strong ETag of a JSON-serialisable response body."""
+    canonical = json.dumps(payload, sort_keys=True, separators=(',', ':'), default=str)
+    return '"' + hashlib.sha256(canonical.encode('utf-8')).hexdigest()[:32] + '"'
+
+
+def etag_matches(if_none_match: Optional[str], etag: str) -> bool:
+    """True if an If-None-Match header value matches `etag` (weak comparison)."""
+    if not if_none_match:
+        return False
+    if if_none_match.strip() == '*':
+        return True
+    bare = etag[2:] if etag.startswith('W/') else etag
+    for candidate in if_none_match.split(','):
+        candidate = candidate.strip()
+        if candidate.startswith('W/'):
+            candidate = candidate[2:]
+        if candidate == bare:
+            return True
+    return False
@@ -5,6 +5,15 @@ Persists the following across server reboots:
   - Locked (pinned) slots
   - Marshal tokens with remaining TTL
   - Promoted snippet metadata (staging_id, code, language, engine, slot address)
+    — sealed (base64 `sealed_code`) on slots that encrypt at rest
+  - Each snippet's parameters and the arguments it was speculated with,
+    so a restored snippet is bound and run the way it was promoted
+  - The names of each snippet's env and its env_hash — never the values
+
+Env values are secrets and stay off disk.  restore_env() takes them back
+from the server's environment, one SPOKEDPY_SNIPPET_ENV_<NAME> variable
+per name, and a snippet whose values are missing or no longer hash to
+the checkpointed env_hash is not restored.
 
 The checkpoint file is written atomically (write → rename) to avoid corruption
 on crash.  On startup, the restore phase replays promoted snippets back through
@@ -26,12 +35,16 @@ via a background thread (coalesce window = 1 second).
 import os
 import json
 import time
+import base64
 import threading
 import traceback
-from typing import Any, Dict, List, Optional
+from typing import Any, Dict, List, Mapping, Optional
 
+from visual_editor_core.snippet_env import env_hash
 from web_interface.project_db import resolve_setting
 
+RESTORE_ENV_PREFIX = 'SPOKEDPY_SNIPPET_ENV_'
+
 
 # ─────────────────────────────────────────────────────────────────────────
 # Checkpoint file path resolution
@@ -218,6 +231,42 @@ def _serialize_marshal_tokens(marshal_tokens: dict, now: float) -> dict:
     return out
 
 
+def _code_fields(staging_pipeline, sn) -> dict:
+    """The snapshot's source: in the clear, or sealed for a slot that encrypts at rest."""
+    sealed = staging_pipeline.seal_source(sn.engine_letter, sn.code)
+    if sealed is None:
+        return {'code': sn.code}
+    return {'code': '', 'sealed_code': base64.b64encode(sealed).decode('ascii')}
+
+
+def _env_fields(sn) -> dict:
+    """The snapshot's env: names and env_hash only, so no value reaches the checkpoint."""
+    return {'env_names': sorted(sn.env), 'env_hash': sn.env_hash}
+
+
+def restore_env(snap: dict, environ: Optional[Mapping[str, str]] = None) -> Dict[str, str]:
+    """The env a checkpointed snippet was promoted with, read back from `environ`.
+
+    Each name in the snapshot's env_names is looked up as
+    RESTORE_ENV_PREFIX + name (os.environ by default).  ValueError names
+    the missing variables, or says the values changed when they don't hash
+    to the snapshot's env_hash.  Checkpoints written before env values were
+    kept off disk still carry `env` and are taken as they are.
+    """
+    if 'env_names' not in snap:
+        return dict(snap.get('env') or {})
+    environ = os.environ if environ is None else environ
+    names = snap.get('env_names') or []
+    missing = [name for name in names if RESTORE_ENV_PREFIX + name not in environ]
+    if missing:
+        raise ValueError('env values not supplied: set '
+                         + ', '.join(RESTORE_ENV_PREFIX + name for name in missing))
+    env = {name: environ[RESTORE_ENV_PREFIX + name] for name in names}
+    if env_hash(env) != snap.get('env_hash', ''):
+        raise ValueError('env values do not match the checkpointed env_hash')
+    return env
+
+
 def build_promoted_snapshots(staging_pipeline, marshal_tokens: dict,
                              locked_slots: dict) -> List[dict]:
     """Build the list of promoted snippet snapshots for checkpointing.
@@ -258,8 +307,16 @@ def build_promoted_snapshots(staging_pipeline, marshal_tokens: dict,
             'staging_id': sn.staging_id,
             'language': sn.language,
             'engine_letter': sn.engine_letter,
-            'code': sn.code,
+            **_code_fields(staging_pipeline, sn),
             'label': sn.label,
+            'namespace': sn.namespace,
+            **_env_fields(sn),
+            'parameters': list(sn.parameters),
+            'spec_arguments': dict(sn.spec_arguments),
+            'suppress_credential_check': sn.suppress_credential_check,
+            'requires': list(sn.requires),
+            'output_schema': sn.output_schema,
+            'tags': list(sn.tags),
             'address': sn.reserved_address,
             'position': sn.reserved_position,
             'engine_name': sn.reserved_engine,
@@ -304,8 +361,16 @@ def build_promoted_snapshots(staging_pipeline, marshal_tokens: dict,
             'staging_id': sn.staging_id,
             'language': sn.language,
             'engine_letter': sn.engine_letter,
-            'code': sn.code,
+            **_code_fields(staging_pipeline, sn),
             'label': sn.label,
+            'namespace': sn.namespace,
+            **_env_fields(sn),
+            'parameters': list(sn.parameters),
+            'spec_arguments': dict(sn.spec_arguments),
+            'suppress_credential_check': sn.suppress_credential_check,
+            'requires': list(sn.requires),
+            'output_schema': sn.output_schema,
+            'tags': list(sn.tags),
             'address': sn.reserved_address,
             'position': sn.reserved_position,
             'engine_name': sn.reserved_engine,
@@ -45,6 +45,7 @@ _TAG_MAP = [
     ('/api/engines',              'Engines'),
     ('/api/marshal',              'Marshal Tokens'),
     ('/api/staging',              'Staging Pipeline'),
+    ('/api/v1/snippets',          'Snippets (REST v1)'),
     ('/api/registry',             'Node Registry'),
     ('/api/execution',            'Execution'),
     ('/api/settings',             'Settings'),